@@ -15,10 +15,41 @@ import {
   getPaths,
   pathExists
 } from './lib/cli/cli-config.js'
+import { parseCommandLine, parseFlags } from './lib/cli/cli-flags.js'
+import { shutdown } from './lib/cli/shutdown.js'
 import {
   handleInit,
   handleStatus,
   handleCleanIndex,
+  handleBuild,
+  handleExport,
+  handleQuery,
+  handleGrep,
+  handleDeadCode,
+  handleDupes,
+  handleApi,
+  handleApiDiff,
+  handleHistory,
+  handleTodos,
+  handleDiagnostics,
+  handleStrings,
+  handleLandmarks,
+  handleImports,
+  handleDeprecations,
+  handleAudit,
+  handleImpacted,
+  handleStats,
+  handleConvertIndex,
+  handleMerge,
+  handleVerify,
+  handleMigrate,
+  handlePush,
+  handlePull,
+  handleServe,
+  handleDaemon,
+  forwardToQueryDaemon,
+  limitFlags,
+  handleLsp,
   handlePruneAll,
   handleMcp,
   handleUninstall,
@@ -35,6 +66,7 @@ import {
 import {
   isDaemonRunning
 } from './lib/cli/daemon-manager.js'
+import { flushTracing } from './lib/utils/tracing.js'
 
 const __filename = fileURLToPath(import.meta.url)
 const __dirname = path.dirname(__filename)
@@ -42,29 +74,13 @@ const pkgPath = path.resolve(__dirname, '../package.json')
 const pkg = JSON.parse(fs.readFileSync(pkgPath, 'utf-8'))
 
 const args = process.argv.slice(2)
-let command: string | null = null
-let projectPathArg: string | null = null
-let mcpHttpMode: boolean = false
-let mcpPort: string | null = null
-
-const cleanArgs: string[] = []
-for (let i = 0; i < args.length; i++) {
-  const arg = args[i]
-  if (arg.startsWith('--project=')) {
-    projectPathArg = arg.split('=')[1]
-  } else if (arg === '--mcp-http') {
-    mcpHttpMode = true
-  } else if (arg === '--port' && i + 1 < args.length) {
-    mcpPort = args[i + 1]
-    i++ // Skip the next argument as it's the port value
-  } else if (arg.startsWith('--port=')) {
-    mcpPort = arg.split('=')[1]
-  } else if (!command) {
-    command = arg
-  } else {
-    cleanArgs.push(arg)
-  }
-}
+const commandLine = parseCommandLine(args)
+const command: string | null = commandLine.command
+const projectPathArg: string | null = commandLine.projectPath
+const mcpHttpMode: boolean = commandLine.mcpHttp
+const mcpPort: string | null = commandLine.port
+const watchMode: boolean = commandLine.watch
+const cleanArgs: string[] = commandLine.args
 
 const startCwd = process.cwd()
 
@@ -75,14 +91,15 @@ const exitHandler = (): void => {
 
 process.on('exit', exitHandler)
 
+// Long-running commands finish their shutdown hooks first
 process.on('SIGINT', () => {
   exitHandler()
-  process.exit(130)
+  void shutdown(130)
 })
 
 process.on('SIGTERM', () => {
   exitHandler()
-  process.exit(143)
+  void shutdown(143)
 })
 
 process.on('uncaughtException', (err: Error) => {
@@ -223,6 +240,13 @@ async function main(): Promise<void> {
     return
   }
 
+  // A running query daemon answers read-only commands from memory
+  const daemonExit = await forwardToQueryDaemon(startCwd, command, cleanArgs)
+  if (daemonExit !== null) {
+    await flushTracing()
+    process.exit(daemonExit)
+  }
+
   switch (command) {
     case 'init':
       await handleInit(startCwd).catch((e: Error) => {
@@ -233,7 +257,93 @@ async function main(): Promise<void> {
     case 'index':
     case 'clean':
     case 'clear':
-      await handleCleanIndex(startCwd)
+      await handleCleanIndex(startCwd, {
+        watch: watchMode,
+        deps: cleanArgs.includes('--deps'),
+        locals: cleanArgs.includes('--locals'),
+        limits: limitFlags(parseFlags(cleanArgs).flags)
+      })
+      break
+    case 'build':
+      await handleBuild(startCwd, cleanArgs)
+      break
+    case 'export':
+      await handleExport(startCwd, cleanArgs)
+      break
+    case 'query':
+      await handleQuery(startCwd, cleanArgs)
+      break
+    case 'grep':
+      await handleGrep(startCwd, cleanArgs)
+      break
+    case 'deadcode':
+      await handleDeadCode(startCwd, cleanArgs)
+      break
+    case 'dupes':
+      await handleDupes(startCwd, cleanArgs)
+      break
+    case 'api':
+      await handleApi(startCwd, cleanArgs)
+      break
+    case 'apidiff':
+      await handleApiDiff(startCwd, cleanArgs)
+      break
+    case 'todos':
+      await handleTodos(startCwd, cleanArgs)
+      break
+    case 'diagnostics':
+      await handleDiagnostics(startCwd, cleanArgs)
+      break
+    case 'strings':
+      await handleStrings(startCwd, cleanArgs)
+      break
+    case 'landmarks':
+      await handleLandmarks(startCwd, cleanArgs)
+      break
+    case 'history':
+      await handleHistory(startCwd, cleanArgs)
+      break
+    case 'imports':
+      await handleImports(startCwd, cleanArgs)
+      break
+    case 'deprecations':
+      await handleDeprecations(startCwd, cleanArgs)
+      break
+    case 'audit':
+      await handleAudit(startCwd, cleanArgs)
+      break
+    case 'impacted':
+      await handleImpacted(startCwd, cleanArgs)
+      break
+    case 'stats':
+      await handleStats(startCwd, cleanArgs)
+      break
+    case 'convert-index':
+      await handleConvertIndex(startCwd, cleanArgs)
+      break
+    case 'merge':
+      await handleMerge(startCwd, cleanArgs)
+      break
+    case 'verify':
+      await handleVerify(startCwd, cleanArgs)
+      break
+    case 'migrate':
+      await handleMigrate(startCwd, cleanArgs)
+      break
+    case 'push':
+      await handlePush(startCwd, cleanArgs)
+      break
+    case 'pull':
+      await handlePull(startCwd, cleanArgs)
+      break
+    case 'lsp':
+      await handleLsp(startCwd, cleanArgs, mcpPort)
+      break
+    case 'serve':
+      await handleServe(startCwd, cleanArgs, mcpPort)
+      break
+    case 'daemon':
+      await handleDaemon(startCwd, cleanArgs)
       break
     case 'logs':
     case 'log':
@@ -286,7 +396,9 @@ async function main(): Promise<void> {
       fail(`Unknown command: ${command}`)
   }
 
-  if (command !== 'mcp' && command !== 'logs') {
+  const daemonRun = command === 'daemon' && cleanArgs[0] === 'run'
+  if (command !== 'mcp' && command !== 'logs' && command !== 'lsp' && command !== 'serve' && !daemonRun && !watchMode) {
+    await flushTracing()
     process.exit(0)
   }
 }
@@ -36,6 +36,35 @@ export {
   handleInit,
   handleStatus,
   handleCleanIndex,
+  handleBuild,
+  handleExport,
+  handleQuery,
+  handleGrep,
+  handleDeadCode,
+  handleDupes,
+  handleApi,
+  handleApiDiff,
+  handleHistory,
+  handleTodos,
+  handleDiagnostics,
+  handleStrings,
+  handleLandmarks,
+  handleImports,
+  handleDeprecations,
+  handleAudit,
+  handleImpacted,
+  handleStats,
+  handleConvertIndex,
+  handleMerge,
+  handleVerify,
+  handleMigrate,
+  handlePush,
+  handlePull,
+  handleServe,
+  handleDaemon,
+  forwardToQueryDaemon,
+  limitFlags,
+  handleLsp,
   handleLogs,
   handleUninstall,
   handleMcp,
@@ -4,7 +4,7 @@ import path from 'path'
 import { spawn } from 'child_process'
 import { fileURLToPath } from 'url'
 
-import { fail, log, warn } from './cli-ui.js'
+import { fail, log, printSnippets, printTable, warn } from './cli-ui.js'
 import {
   ensureGitignoreEntry,
   findProjectRoot,
@@ -25,14 +25,88 @@ import {
 } from './cli-config.js'
 
 import { detectProjectIndexConfig, renderToIndex } from '../core/project-detector.js'
-import { dropCollections, runOneOffIndex, deleteCollectionByName } from '../core/indexer-core.js'
+import { dropCollections, runOneOffIndex, deleteCollectionByName, indexFile } from '../core/indexer-core.js'
 import { checkSystemRequirements, setupOllamaModel } from '../utils/system-check.js'
 import {
   addProjectToConfig,
   getProjectCollectionName,
+  getQueryDaemonLogPath,
+  getQueryDaemonSocketPath,
   loadGlobalConfig
 } from '../utils/config-global.js'
 import { deleteSnapshot } from '../utils/snapshot-manager.js'
+import {
+  SYMBOL_STORE_KINDS,
+  createSymbolStore,
+  getSymbolStore,
+  symbolStoreKind,
+  type SymbolStoreKind
+} from '../utils/symbol-store.js'
+import { openSymbolIndex } from '../core/symbol-index.js'
+import { openRevisionIndex, revisionReader } from '../core/revision-index.js'
+import { readIndexCache } from '../core/index-cache.js'
+import { parseByteRate, type IndexLimits } from '../core/index-limits.js'
+import { pathCaseCollisions, toIndexPath } from '../core/index-paths.js'
+import { pullRevisionIndex, pushRevisionIndex } from '../core/index-registry.js'
+import { watchSymbolIndex, type SymbolIndexWatcher } from '../services/symbol-index-watcher.js'
+import { WebhookNotifier } from '../services/index-webhooks.js'
+import { QueryDaemon, queryDaemonStatus, runInQueryDaemon, stopQueryDaemon, type DaemonCommand, type QueryDaemonStatus } from '../services/query-daemon.js'
+import { commandContext } from './command-context.js'
+import { onShutdown } from './shutdown.js'
+import { deletePointsByPath } from '../core/qdrant-client.js'
+import { exportLsif } from '../exporters/lsif.js'
+import { exportScip } from '../exporters/scip.js'
+import { exportCtags, exportEtags, readSources } from '../exporters/tags.js'
+import { exportDocs } from '../exporters/docs.js'
+import { streamJsonl } from '../exporters/jsonl.js'
+import { exportRecords } from '../exporters/records.js'
+import { exportCallGraphDot } from '../exporters/dot.js'
+import { exportImportGraphDot, exportImportGraphJson } from '../exporters/import-graph.js'
+import { readPackageInfo } from '../exporters/package-info.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+import { parseFlags, resolvePathFlags } from './cli-flags.js'
+import { querySymbols, type SymbolQuery } from '../core/symbol-query.js'
+import { parseQuery, selectSymbols, type QueryNode } from '../core/query-language.js'
+import { INTERFACE_KINDS, TYPE_KINDS } from '../core/implementations.js'
+import { DEFAULT_SCOPES, parseScope, type SearchScope } from '../core/search-scope.js'
+import { ENUM_KINDS } from '../core/enum-sets.js'
+import { formatTag, type FieldTags } from '../utils/field-tags.js'
+import { blameAuthors, resolveRevision, topAuthor } from '../utils/git.js'
+import { projectFileSource, revisionFileSource } from '../core/file-source.js'
+import { archiveFileSource, openArchiveIndex } from '../core/archive-index.js'
+import { mapConcurrent } from '../core/parse-pool.js'
+import { SignatureMatcher, parseSignatureQuery, type ParsedSignature } from '../core/signature-search.js'
+import { findDeadCode, loadAllowlist } from '../core/dead-code.js'
+import { DEFAULT_MIN_SIZE, DEFAULT_SIMILARITY, findDuplicates } from '../core/duplicates.js'
+import { findTodos, type TodoEntry } from '../core/todo-comments.js'
+import { diagnosticSource, findParseDiagnostics } from '../core/parse-diagnostics.js'
+import { USAGE_SORTS, type UsageSort } from '../core/usage-stats.js'
+import { PRECISIONS, resolveReferences, type Precision } from '../core/type-resolution.js'
+import { DISPATCH_ALGORITHMS, type DispatchAlgorithm } from '../core/call-graph.js'
+import { DEFAULT_MIN_STRING_LENGTH, findStrings, type StringMatch } from '../core/string-literals.js'
+import { findLandmarks } from '../core/landmark-sites.js'
+import { LANDMARK_KINDS, parseLandmarkFilter, type LandmarkKind } from '../utils/landmarks.js'
+import { diffApiSurface, extractApiSurface, parseApiManifest, type ApiManifest } from '../core/api-surface.js'
+import { computeImportGraph, findImportCycles, importersOf, importsOf, packageOf } from '../core/import-graph.js'
+import { resolvePackageGraph } from '../core/package-graph.js'
+import { excludeGenerated } from '../core/generated-code.js'
+import { deprecationNotice, findDeprecatedUses } from '../core/deprecations.js'
+import { verifyShards, type ShardDamage } from '../core/index-integrity.js'
+import { mergeShards, openIndexFile, parseMergeInput, readMergeInput, writeIndexFile } from '../core/index-merge.js'
+import { migrateIndex, migrateIndexFile } from '../core/index-migrate.js'
+import { diffGenerations, generationFile, listGenerations, loadHistoryPolicy, resolveGeneration, type Generation } from '../core/index-history.js'
+import { storedFormat } from '../core/index-format.js'
+import { SnippetCache, parseContextLines } from '../core/snippets.js'
+import { SENSITIVE_CATEGORIES, auditSecurity, type SensitiveCategory } from '../core/security-audit.js'
+import { computeImpact, goTestArgs, resolveImpactTargets } from '../core/impact.js'
+import { computePackageStats, packageStatsCsv } from '../core/package-stats.js'
+import { redactIndex, redactShard, redactionPolicy, type RedactionClass } from '../core/redaction.js'
+import { serveStdio, serveTcp } from '../lsp/lsp-server.js'
+import { serveGrpc } from '../rpc/grpc-server.js'
+import { serveHttp, serveMetrics } from '../rpc/http-server.js'
+import { ServerMetrics } from '../rpc/metrics.js'
+import { WorkspaceSet, checkWorkspaceRoots, parseWorkspaceSpecs, type WorkspaceSpec } from '../rpc/workspaces.js'
 import {
   renderMcpProxyScript
 } from './cli-config.js'
@@ -44,6 +118,8 @@ const __dirname = path.dirname(__filename)
 const pkgPath = path.resolve(__dirname, '../../../package.json')
 const pkg = JSON.parse(fsSync.readFileSync(pkgPath, 'utf-8'))
 
+const DEFAULT_GRPC_PORT = 50051
+
 export async function ensureInitialized(startCwd: string, failOnMissing = true) {
   const root = await findProjectRoot(startCwd)
   const paths = getPaths(root)
@@ -55,7 +131,7 @@ export async function ensureInitialized(startCwd: string, failOnMissing = true)
 }
 
 export async function checkAndAutoUpdate(command: string | null) {
-  if (command === 'mcp' || command === 'logs') {
+  if (command === 'mcp' || command === 'logs' || command === 'lsp' || command === 'serve' || command === 'daemon') {
     return
   }
 
@@ -222,7 +298,10 @@ export async function handleStatus(startCwd: string) {
   }
 }
 
-export async function handleCleanIndex(startCwd: string) {
+export async function handleCleanIndex(
+  startCwd: string,
+  opts: { watch?: boolean, deps?: boolean, locals?: boolean, limits?: IndexLimits } = {}
+) {
   const { root, paths } = await ensureInitialized(startCwd)
   const collectionName = getProjectCollectionName(root)
 
@@ -238,10 +317,2010 @@ export async function handleCleanIndex(startCwd: string) {
 
   try {
     await runOneOffIndex(root, collectionName, { reset: true })
+    await getSymbolStore(root).clear()
     log('Index cleaned and rebuilt.')
   } catch (e: any) {
     fail(`Indexer failed: ${e.message}`)
   }
+
+  if (opts.watch) {
+    await watchProjectIndex(root, collectionName, { deps: opts.deps, locals: opts.locals }, opts.limits)
+  }
+}
+
+/**
+ * Keep the symbol index and the vector index hot while files are edited.
+ * Every update is printed to stdout as one JSON line.
+ */
+async function watchProjectIndex(
+  root: string,
+  collectionName: string,
+  options: { deps?: boolean, locals?: boolean } = {},
+  limits: IndexLimits = {}
+) {
+  const { index } = await openSymbolIndex(root, undefined, { ...options, ...limits })
+  const watcher = watchSymbolIndex(root, index, async (event) => {
+    const { added, modified, removed } = event.update
+    for (const file of [...added, ...modified]) {
+      try {
+        await indexFile(root, file, collectionName)
+      } catch (e: any) {
+        warn(`Failed to index ${file}: ${e.message}`)
+      }
+    }
+    for (const file of removed) {
+      try {
+        await deletePointsByPath(collectionName, file)
+      } catch (e: any) {
+        warn(`Failed to remove ${file}: ${e.message}`)
+      }
+    }
+    process.stdout.write(JSON.stringify({ type: 'index_update', ...event.update, durationMs: event.durationMs }) + '\n')
+  }, limits)
+  checkpointOnShutdown(watcher)
+  log('Watch mode enabled. Press Ctrl+C to stop.')
+}
+
+// On SIGTERM / SIGINT, apply a watcher's pending changes and checkpoint its
+// index, so the next start reads only what changed after (see index-checkpoint)
+function checkpointOnShutdown(watcher: SymbolIndexWatcher, label = '') {
+  onShutdown(async () => {
+    if (await watcher.shutdown()) log(`Checkpointed the symbol index${label}`)
+  })
+}
+
+function listFlag(value: string | boolean | undefined): string[] | undefined {
+  if (typeof value !== 'string') return undefined
+  return value.split(',').map(v => v.trim()).filter(Boolean)
+}
+
+type SourceFileReader = (relPath: string) => Promise<string | null>
+
+// Minimum literal length of --strings[=N]; 0 when the flag is absent
+function stringsFlag(value: string | boolean | undefined): number {
+  if (value === undefined || value === false) return 0
+  return typeof value === 'string' && Number(value) > 0 ? Number(value) : DEFAULT_MIN_STRING_LENGTH
+}
+
+// --precision=fast|full; fast when the flag is absent
+function precisionFlag(value: string | boolean | undefined): Precision {
+  if (value === undefined) return 'fast'
+  if (!PRECISIONS.includes(value as Precision)) fail(`Unknown --precision "${value}". Use --precision=${PRECISIONS.join('|')}`)
+  return value as Precision
+}
+
+// --dispatch=name|cha|rta|vta; name when the flag is absent
+function dispatchFlag(value: string | boolean | undefined): DispatchAlgorithm {
+  if (value === undefined) return 'name'
+  if (!DISPATCH_ALGORITHMS.includes(value as DispatchAlgorithm)) {
+    fail(`Unknown --dispatch "${value}". Use --dispatch=${DISPATCH_ALGORITHMS.join('|')}`)
+  }
+  return value as DispatchAlgorithm
+}
+
+// --context=N, the lines of source to show on either side of each result; null when absent
+function contextFlag(value: string | boolean | undefined): number | null {
+  if (value === undefined) return null
+  try {
+    return parseContextLines(typeof value === 'string' ? value : '')
+  } catch (e: any) {
+    fail(`Usage: --context=N; ${e.message}`)
+  }
+}
+
+// Shards of --cache-from=<file>, a previous build's index file
+async function cacheFromFlag(startCwd: string, value: string | boolean | undefined): Promise<FileShard[] | undefined> {
+  if (value === undefined) return undefined
+  if (typeof value !== 'string') fail('Usage: --cache-from=<file>, an index file written by indexer export --format=idx')
+  return readIndexCache(path.resolve(startCwd, value)).catch((e: Error) => fail(e.message))
+}
+
+/**
+ * --concurrency=N (files parsed at once) and --io-rate=<bytes/s> (such as
+ * 20MB) of index builds and reindexes; limits not given take their defaults
+ */
+export function limitFlags(flags: Record<string, string | boolean>): IndexLimits {
+  const limits: IndexLimits = {}
+  if (flags.concurrency !== undefined) {
+    const concurrency = Number(flags.concurrency)
+    if (!Number.isInteger(concurrency) || concurrency < 1) fail('Usage: --concurrency=N, the number of files to parse at once')
+    limits.concurrency = concurrency
+  }
+  if (flags['io-rate'] !== undefined) {
+    if (typeof flags['io-rate'] !== 'string') fail('Usage: --io-rate=<bytes per second>, such as 20MB or 512k')
+    try {
+      limits.ioRate = parseByteRate(flags['io-rate'])
+    } catch (e: any) {
+      fail(e.message)
+    }
+  }
+  return limits
+}
+
+function reportCache(fromCache: string[] | undefined, flags: Record<string, string | boolean>) {
+  if (!fromCache) return
+  const packages = new Set(fromCache.map(packageOf))
+  warn(`Reused ${fromCache.length} files in ${packages.size} unchanged packages from ${flags['cache-from']}`)
+}
+
+/**
+ * Open the index the flags ask for: an index file (such as one written by
+ * indexer merge) with --index=<file>, the tree at a git revision with
+ * --rev=<rev>, else the working tree (plus dependencies with --deps,
+ * parameters and locals with --locals, string literals with --strings, and
+ * references bound by the type checker with --precision=full, checking the
+ * project file given by --tsconfig). --dispatch picks how the call graph
+ * resolves calls through receivers. --cache-from=<file> takes the
+ * unchanged packages of a revision or the working tree from a previous
+ * build's index file instead of parsing them. --concurrency and --io-rate
+ * limit the build (see limitFlags). File flags are relative to startCwd,
+ * where the command was run.
+ */
+async function openIndex(
+  startCwd: string,
+  root: string,
+  flags: Record<string, string | boolean>,
+  packages?: string[]
+): Promise<{ index: SymbolIndex, readSource: SourceFileReader }> {
+  const precision = precisionFlag(flags.precision)
+  const dispatch = dispatchFlag(flags.dispatch)
+  const generation = generationFlag(flags)
+  if (precision === 'full' && (typeof flags.index === 'string' || typeof flags.rev === 'string' || typeof flags.archive === 'string' || generation)) {
+    fail('--precision=full type-checks the working tree; it cannot be combined with --index, --rev, --archive, --generation or --as-of')
+  }
+  if (typeof flags.index === 'string' && flags['cache-from'] !== undefined) {
+    fail('--cache-from builds an index; it cannot be combined with --index')
+  }
+  if (generation) {
+    if (typeof flags.index === 'string' || typeof flags.rev === 'string' || typeof flags.archive === 'string') {
+      fail('--generation and --as-of cannot be combined with --index, --rev or --archive')
+    }
+    const { index } = await openGeneration(root, generation)
+    warnCaseCollisions(index)
+    index.dispatch = dispatch
+    // Generations carry no sources
+    return { index, readSource: async () => null }
+  }
+  if (typeof flags.archive === 'string') {
+    if (typeof flags.index === 'string' || typeof flags.rev === 'string') fail('--archive cannot be combined with --index or --rev')
+    const { index, readSource } = await openArchiveIndex(path.resolve(startCwd, flags.archive), limitFlags(flags))
+      .catch((e: Error) => fail(`Cannot read archive ${flags.archive}: ${e.message}`))
+    warnCaseCollisions(index)
+    index.dispatch = dispatch
+    return { index, readSource }
+  }
+  if (typeof flags.index === 'string') {
+    const { index, damaged } = await openIndexFile(path.resolve(startCwd, flags.index)).catch((e: Error) => fail(e.message))
+    warnDamaged(damaged)
+    warnCaseCollisions(index)
+    index.dispatch = dispatch
+    // Index files carry no sources
+    return { index, readSource: async () => null }
+  }
+  const cache = await cacheFromFlag(startCwd, flags['cache-from'])
+  const limits = limitFlags(flags)
+  if (typeof flags.rev === 'string') {
+    const { index, commit, fromCache } = await openRevisionIndex(root, flags.rev, { cache, ...limits })
+    reportCache(fromCache, flags)
+    warnCaseCollisions(index)
+    index.dispatch = dispatch
+    return { index, readSource: revisionReader(root, commit) }
+  }
+  const readSource: SourceFileReader = relPath => fs.readFile(path.join(root, relPath), 'utf8').catch(() => null)
+  const options = { deps: !!flags.deps, locals: !!flags.locals, strings: stringsFlag(flags.strings) }
+  // In the query daemon, reuse its index when it was built with the same
+  // passes; it holds every package, so commands still filter by --package.
+  // Its index follows the working tree, so it is never type-checked.
+  const resident = commandContext.getStore()?.resident
+  if (resident && resident.root === root && precision === 'fast' &&
+      resident.deps === options.deps && resident.locals === options.locals && resident.strings === options.strings) {
+    if (dispatch === 'name') return { index: resident.index.snapshot(), readSource }
+    // Snapshots are shared between commands; a fork takes its own algorithm
+    const index = resident.index.fork()
+    index.dispatch = dispatch
+    return { index, readSource }
+  }
+  const { index, damaged, update } = await openSymbolIndex(root, undefined, { ...options, packages, cache, ...limits })
+  warnDamaged(damaged)
+  if (cache) reportCache(update.cached || [], flags)
+  if (precision === 'full') {
+    const checker = {
+      tsconfig: typeof flags.tsconfig === 'string' ? flags.tsconfig : process.env.INDEXER_TSCONFIG,
+      compilerFlags: process.env.INDEXER_TSFLAGS
+    }
+    await resolveReferences(index, root, checker).catch((e: Error) => fail(e.message))
+  }
+  index.dispatch = dispatch
+  return { index, readSource }
+}
+
+// Generation named by --generation=<n> or --as-of=<time>
+function generationFlag(flags: Record<string, string | boolean>): string | undefined {
+  if (typeof flags.generation === 'string' && typeof flags['as-of'] === 'string') fail('--generation and --as-of cannot be combined')
+  if (typeof flags.generation === 'string') {
+    if (!/^\d+$/.test(flags.generation)) fail(`Invalid --generation "${flags.generation}". Use a generation number from "indexer history"`)
+    return flags.generation
+  }
+  return typeof flags['as-of'] === 'string' ? flags['as-of'] : undefined
+}
+
+// Index of a recorded generation (see resolveGeneration)
+async function openGeneration(root: string, spec: string): Promise<{ index: SymbolIndex, generation: Generation }> {
+  const generations = await listGenerations(root)
+  let generation: Generation
+  try {
+    generation = resolveGeneration(generations, spec)
+  } catch (e: any) {
+    fail(e.message)
+  }
+  const { index, damaged } = await openIndexFile(generationFile(root, generation)).catch((e: Error) => fail(e.message))
+  warnDamaged(damaged)
+  return { index, generation }
+}
+
+interface ExportFormat {
+  defaultOutput: string
+  render: (index: SymbolIndex, root: string, flags: Record<string, string | boolean>, readSource: SourceFileReader) => Promise<string | Buffer>
+}
+
+const EXPORT_FORMATS: Record<string, ExportFormat> = {
+  lsif: {
+    defaultOutput: 'dump.lsif',
+    render: async (index, root) => exportLsif(index, {
+      projectRoot: root,
+      toolVersion: pkg.version,
+      packageInfo: await readPackageInfo(root)
+    })
+  },
+  scip: {
+    defaultOutput: 'index.scip',
+    render: async (index, root) => exportScip(index, {
+      projectRoot: root,
+      toolVersion: pkg.version,
+      packageInfo: await readPackageInfo(root)
+    })
+  },
+  proto: {
+    defaultOutput: 'index.records.pb',
+    render: async (index, root) => exportRecords(index, {
+      projectRoot: root,
+      toolVersion: pkg.version
+    })
+  },
+  ctags: {
+    defaultOutput: 'tags',
+    render: async (index, root, _flags, readSource) => exportCtags(index, {
+      readSource: await readSources(root, index.listFiles(), readSource),
+      toolVersion: pkg.version
+    })
+  },
+  etags: {
+    defaultOutput: 'TAGS',
+    render: async (index, root, _flags, readSource) => exportEtags(index, {
+      readSource: await readSources(root, index.listFiles(), readSource)
+    })
+  },
+  docs: {
+    defaultOutput: 'docs.json',
+    render: async (index, root, flags, readSource) => exportDocs(index, {
+      readSource: await readSources(root, index.listFiles(), readSource),
+      toolVersion: pkg.version,
+      packageInfo: await readPackageInfo(root),
+      packages: listFlag(flags.package)
+    })
+  },
+  dot: {
+    defaultOutput: 'callgraph.dot',
+    render: async (index, _root, flags) => exportCallGraphDot(index, {
+      focus: (listFlag(flags.symbol) || []).flatMap(name => index.findSymbols(name).map(s => s.id)),
+      depth: typeof flags.depth === 'string' ? parseInt(flags.depth, 10) || undefined : undefined
+    })
+  },
+  'imports-dot': {
+    defaultOutput: 'imports.dot',
+    render: async (index, root, flags, readSource) => exportImportGraphDot(await computeImportGraph(index, readSource, {
+      packageGraph: await resolvePackageGraph(root)
+    }), {
+      external: !!flags.external
+    })
+  },
+  'imports-json': {
+    defaultOutput: 'imports.json',
+    render: async (index, root, flags, readSource) => exportImportGraphJson(await computeImportGraph(index, readSource, {
+      packageGraph: await resolvePackageGraph(root)
+    }), {
+      external: !!flags.external
+    })
+  }
+}
+
+/**
+ * Index the tree at a git revision and store it keyed by commit:
+ * indexer build [--rev=<rev>|--rev <rev>] [--base=<rev>] [--cache-from=<file>] [--rebuild]
+ * With --base, the index is derived from the base revision's index by
+ * re-parsing only the files that changed in between; with --cache-from, the
+ * packages unchanged since a previous build's index file are taken from it.
+ */
+export async function handleBuild(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const rev = typeof flags.rev === 'string' ? flags.rev : flags.rev ? positional[0] : 'HEAD'
+  if (!rev) {
+    fail('Usage: indexer build [--rev=<rev>] [--base=<rev>] [--cache-from=<file>] [--rebuild]')
+  }
+
+  const root = await findProjectRoot(startCwd)
+  const base = typeof flags.base === 'string' ? flags.base : undefined
+  const cache = await cacheFromFlag(startCwd, flags['cache-from'])
+  const { index, commit, cached, base: derived, fromCache } = await openRevisionIndex(root, rev, { rebuild: !!flags.rebuild, base, cache })
+  reportCache(fromCache, flags)
+  if (derived) {
+    const { added, modified, removed } = derived.update
+    log(`Updated from ${base} (${derived.commit.slice(0, 12)}): ${added.length} added, ${modified.length} modified, ${removed.length} removed`)
+  }
+  log(`${cached ? 'Index already stored' : 'Indexed'} for ${rev} (${commit.slice(0, 12)}): ${index.listFiles().length} files, ${index.allSymbols().length} symbols`)
+}
+
+/**
+ * Stream symbol and reference records as JSON Lines while indexing, without
+ * keeping the index in memory; see exporters/jsonl
+ */
+// Text to strip from an index leaving the project: --redact (bare: all), else redact: entries in .indexer/to-index
+async function redactFlag(root: string, flags: Record<string, string | boolean>): Promise<RedactionClass[]> {
+  const flag = flags.redact === true ? 'all' : typeof flags.redact === 'string' ? flags.redact : undefined
+  return redactionPolicy(root, flag).catch((e: Error) => fail(e.message))
+}
+
+async function exportJsonl(startCwd: string, flags: Record<string, string | boolean>) {
+  const root = await findProjectRoot(startCwd)
+  const source = typeof flags.archive === 'string'
+    ? await archiveFileSource(path.resolve(startCwd, flags.archive)).catch((e: Error) => fail(`Cannot read archive ${flags.archive}: ${e.message}`))
+    : typeof flags.rev === 'string'
+      ? revisionFileSource(root, await resolveRevision(root, flags.rev).catch((e: Error) => fail(e.message)))
+      : projectFileSource(root)
+  const options = { references: flags.references !== 'false', generated: flags.generated !== 'false', redact: await redactFlag(root, flags) }
+  const output = typeof flags.output === 'string' ? flags.output : '-'
+  if (output === '-') {
+    await streamJsonl(source, process.stdout, options)
+    return
+  }
+  const outPath = path.resolve(startCwd, output)
+  const out = fsSync.createWriteStream(outPath)
+  const update = await streamJsonl(source, out, options)
+  await new Promise<void>((resolve, reject) => {
+    out.on('error', reject)
+    out.end(resolve)
+  })
+  log(`Exported ${update.added.length} files as jsonl to ${outPath}`)
+}
+
+/**
+ * Write the index as an index file (a symbol pack) that indexer merge and
+ * --index=<file> read
+ */
+async function exportIndexFile(startCwd: string, flags: Record<string, string | boolean>) {
+  const root = await findProjectRoot(startCwd)
+  const redact = await redactFlag(root, flags)
+  const opened = await openIndex(startCwd, root, flags)
+  const index = flags.generated === 'false' ? excludeGenerated(opened.index) : opened.index
+  const outPath = path.resolve(startCwd, typeof flags.output === 'string' ? flags.output : `${path.basename(root)}.idx`)
+  await writeIndexFile(outPath, index.listShards().map(shard => redactShard(shard, redact)))
+  log(`Exported ${index.listFiles().length} files as idx to ${outPath}`)
+}
+
+/**
+ * Export the project's symbol index:
+ * indexer export --format=<fmt> [--output=<file>|-] [--generated=false] [--deps] [--rev=<rev>] [--redact=strings,comments,contents]
+ * indexer export --format=jsonl [--output=<file>] [--references=false] [--generated=false] [--rev=<rev>] [--redact=...]
+ * indexer export --format=idx [--output=<name>.idx] [--generated=false] [--deps] [--rev=<rev>] [--redact=...]
+ */
+export async function handleExport(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const formatName = typeof flags.format === 'string' ? flags.format : ''
+  if (formatName === 'jsonl') {
+    await exportJsonl(startCwd, flags)
+    return
+  }
+  if (formatName === 'idx') {
+    await exportIndexFile(startCwd, flags)
+    return
+  }
+  const format = EXPORT_FORMATS[formatName]
+  if (!format) {
+    fail(`Unknown export format "${formatName}". Use --format=${[...Object.keys(EXPORT_FORMATS), 'jsonl', 'idx'].join('|')}`)
+  }
+
+  const root = await findProjectRoot(startCwd)
+  const output = typeof flags.output === 'string' ? flags.output : format.defaultOutput
+  const toStdout = output === '-'
+
+  const redact = await redactFlag(root, flags)
+  const opened = await openIndex(startCwd, root, flags)
+  const index = redactIndex(flags.generated === 'false' ? excludeGenerated(opened.index) : opened.index, redact)
+  // Exporters that embed source lines fall back to line numbers
+  const readSource: SourceFileReader = redact.includes('contents') ? async () => null : opened.readSource
+  const data = await format.render(index, root, flags, readSource)
+
+  if (toStdout) {
+    process.stdout.write(data)
+    return
+  }
+  const outPath = path.resolve(startCwd, output)
+  await fs.writeFile(outPath, data)
+  log(`Exported ${index.listFiles().length} files as ${formatName} to ${outPath}`)
+}
+
+/**
+ * Query the project's symbol index:
+ * indexer query [--kind=a,b] [--exported[=false]] [--package=./lib/...] [--name=<glob>] [--lang=<lang>]
+ *   [--generated[=false]] [--external[=false]] [--deprecated[=false]] [--callers=<fn>] [--callees=<fn>] [--throws[=false]] [--wraps=<ErrorType>]
+ *   [--landmark=goto,'label>2']
+ *   [--fuzzy=<text>] [--tag=json:user_id,db] [--fact=sqlcheck.raw_query=true]
+ *   [--owner=@team,@user] [--blame] [--context=N]
+ *   [--sig=<signature> [--sig-mode=unordered,assignable]] [--where=<expr> [--scope=module|workspace|all]] [--sort=refs|packages] [--limit=N] [--offset=N] [--deps] [--rev=<rev>] [--json|--format=jsonl]
+ * indexer query --members=<Type> [--json]
+ * indexer query --values=<Type> [--json]
+ * indexer query --tests-for=<symbol> [--json]
+ * indexer query --throw-path=<fn> [--json]
+ * indexer query --supertypes=<Type>|--subtypes=<Type> [--transitive] [--scope=module|workspace|all] [--json]
+ * indexer query --at=<file>:<line>:<col> [--json]
+ */
+export async function handleQuery(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  // --sig also takes its value as the next argument: --sig 'func(string) (User, error)'
+  const signature = typeof flags.sig === 'string' ? flags.sig : flags.sig === true ? positional.shift() : undefined
+  const query: SymbolQuery = {
+    kinds: listFlag(flags.kind),
+    packages: listFlag(flags.package),
+    name: typeof flags.name === 'string' ? flags.name : positional[0],
+    lang: typeof flags.lang === 'string' ? flags.lang : undefined,
+    fuzzy: typeof flags.fuzzy === 'string' ? flags.fuzzy : undefined,
+    limit: typeof flags.limit === 'string' ? parseInt(flags.limit, 10) || undefined : undefined,
+    offset: typeof flags.offset === 'string' ? parseInt(flags.offset, 10) || undefined : undefined,
+    callersOf: typeof flags.callers === 'string' ? flags.callers : undefined,
+    calleesOf: typeof flags.callees === 'string' ? flags.callees : undefined,
+    wraps: typeof flags.wraps === 'string' ? flags.wraps : undefined,
+    landmarks: listFlag(flags.landmark),
+    tags: listFlag(flags.tag),
+    facts: listFlag(flags.fact),
+    owners: listFlag(flags.owner)
+  }
+  if (flags.exported !== undefined) {
+    query.exported = flags.exported !== 'false'
+  }
+  if (flags.generated !== undefined) {
+    query.generated = flags.generated !== 'false'
+  }
+  if (flags.external !== undefined) {
+    query.external = flags.external !== 'false'
+  }
+  if (flags.deprecated !== undefined) {
+    query.deprecated = flags.deprecated !== 'false'
+  }
+  if (flags.throws !== undefined) {
+    query.throws = flags.throws !== 'false'
+  }
+  if (flags.sort !== undefined) {
+    if (!USAGE_SORTS.includes(flags.sort as UsageSort)) fail(`Unknown --sort "${flags.sort}". Use --sort=${USAGE_SORTS.join('|')}`)
+    query.sort = flags.sort as UsageSort
+  }
+  for (const spec of query.landmarks || []) {
+    try {
+      parseLandmarkFilter(spec)
+    } catch (e: any) {
+      fail(e.message)
+    }
+  }
+  let where: QueryNode | null = null
+  if (typeof flags.where === 'string') {
+    try {
+      where = parseQuery(flags.where)
+    } catch (e: any) {
+      fail(`Invalid --where expression: ${e.message}`)
+    }
+  }
+  let parsedSignature: ParsedSignature | null = null
+  if (signature !== undefined) {
+    try {
+      parsedSignature = parseSignatureQuery(signature)
+    } catch (e: any) {
+      fail(`Invalid --sig signature: ${e.message}`)
+    }
+  }
+  const context = contextFlag(flags.context)
+  const sigModes = listFlag(flags['sig-mode']) || []
+  const unknownMode = sigModes.find(m => m !== 'unordered' && m !== 'assignable' && m !== 'exact')
+  if (unknownMode) {
+    fail(`Unknown --sig-mode "${unknownMode}". Use --sig-mode=exact|unordered|assignable (comma-separated)`)
+  }
+
+  const root = await findProjectRoot(startCwd)
+  // Plain package-scoped queries only need that package's shards
+  const crossPackage = ['members', 'values', 'tests-for', 'throw-path', 'supertypes', 'subtypes', 'at', 'callers', 'callees', 'throws', 'where', 'sig'].some(f => flags[f] !== undefined)
+  const { index, readSource } = await openIndex(startCwd, root, flags, crossPackage ? undefined : query.packages)
+  if (parsedSignature) {
+    const matcher = new SignatureMatcher(index, parsedSignature, {
+      unordered: sigModes.includes('unordered'),
+      assignable: sigModes.includes('assignable')
+    })
+    query.filter = sym => matcher.matches(sym)
+  }
+  if (typeof flags.members === 'string') {
+    printMembers(index, flags.members, !!(flags.json || flags.format === 'json'))
+    return
+  }
+  if (typeof flags.values === 'string') {
+    printEnumValues(index, flags.values, !!(flags.json || flags.format === 'json'))
+    return
+  }
+  if (typeof flags['tests-for'] === 'string') {
+    printTests(index, flags['tests-for'], !!(flags.json || flags.format === 'json'))
+    return
+  }
+  if (typeof flags['throw-path'] === 'string') {
+    printThrowPaths(index, flags['throw-path'], !!(flags.json || flags.format === 'json'))
+    return
+  }
+  if (typeof flags.at === 'string') {
+    printSymbolAt(index, flags.at, !!(flags.json || flags.format === 'json'))
+    return
+  }
+  for (const direction of ['supertypes', 'subtypes'] as const) {
+    if (typeof flags[direction] === 'string') {
+      const scope = scopeFlag(flags.scope, DEFAULT_SCOPES[direction])
+      printHierarchy(index, flags[direction] as string, direction, !!flags.transitive, scope, !!(flags.json || flags.format === 'json'))
+      return
+    }
+  }
+  const results = where
+    ? selectSymbols(index, where, query, scopeFlag(flags.scope, DEFAULT_SCOPES.implementations))
+    : querySymbols(index, query)
+  const authors = flags.blame ? await blameResults(root, results) : null
+  const snippets = context === null ? null : await new SnippetCache(readSource).snippets(index, results, context)
+
+  if (flags.json || flags.format === 'json' || flags.format === 'jsonl') {
+    const rows = results.map((s, i) => ({
+      name: s.name,
+      kind: s.kind,
+      path: s.path,
+      line: s.line,
+      end_line: s.end_line,
+      exported: !!s.exported,
+      ...(s.generated ? { generated: true } : {}),
+      ...(s.external ? { external: true } : {}),
+      ...(deprecationNotice(s) !== undefined ? { deprecated: deprecationNotice(s) } : {}),
+      ...(s.module ? { module: s.module, module_version: s.module_version } : {}),
+      ...(s.signature ? { signature: s.signature } : {}),
+      ...(s.type_params ? { type_params: s.type_params } : {}),
+      ...(s.tags ? { tags: s.tags } : {}),
+      ...(index.owners(s.path).length > 0 ? { owners: index.owners(s.path) } : {}),
+      ...(authors?.get(s.id) ? { author: authors.get(s.id) } : {}),
+      ...(query.sort ? { usage: index.usage(s.id) } : {}),
+      ...(snippets?.[i] ? { context: snippets[i] } : {})
+    }))
+    process.stdout.write(flags.format === 'jsonl'
+      ? rows.map(row => JSON.stringify(row) + '\n').join('')
+      : JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+
+  if (results.length === 0) {
+    log('No matching symbols.')
+    return
+  }
+  if (snippets) {
+    printSnippets(results.map((s, i) => ({ title: `${s.kind} ${s.name} ${s.path}:${s.line}`, line: s.line, snippet: snippets[i] })))
+    return
+  }
+  if (parsedSignature) {
+    printTable(
+      ['KIND', 'NAME', 'LOCATION', 'SIGNATURE'],
+      results.map(s => [s.kind, s.name, `${s.path}:${s.line}`, s.signature || ''])
+    )
+    return
+  }
+  if (query.tags) {
+    printTable(
+      ['KIND', 'NAME', 'LOCATION', 'TAGS'],
+      results.map(s => [
+        s.kind,
+        s.name,
+        `${s.path}:${s.line}`,
+        Object.entries((s.tags || {}) as FieldTags).map(([key, tag]) => formatTag(key, tag)).join(' ')
+      ])
+    )
+    return
+  }
+  if (query.owners || authors) {
+    printTable(
+      ['KIND', 'NAME', 'LOCATION', 'OWNERS', ...(authors ? ['AUTHOR'] : [])],
+      results.map(s => [
+        s.kind,
+        s.name,
+        `${s.path}:${s.line}`,
+        index.owners(s.path).join(' '),
+        ...(authors ? [authors.get(s.id) || ''] : [])
+      ])
+    )
+    return
+  }
+  if (query.sort) {
+    printTable(
+      ['KIND', 'NAME', 'LOCATION', 'REFS', 'FILES', 'PACKAGES'],
+      results.map(s => {
+        const usage = index.usage(s.id)
+        return [s.kind, s.name, `${s.path}:${s.line}`, String(usage.refs), String(usage.files), String(usage.packages)]
+      })
+    )
+    return
+  }
+  printTable(
+    ['KIND', 'NAME', 'LOCATION', 'EXPORTED'],
+    results.map(s => [s.kind, s.name, `${s.path}:${s.line}`, s.exported ? 'yes' : 'no'])
+  )
+}
+
+/**
+ * Top `git blame` author of each symbol's lines, by symbol id
+ */
+async function blameResults(root: string, symbols: IndexedSymbol[]): Promise<Map<string, string>> {
+  const paths = [...new Set(symbols.map(s => s.path))]
+  const blamed = new Map<string, string[]>()
+  await mapConcurrent(paths, 8, async (relPath) => {
+    blamed.set(relPath, await blameAuthors(root, relPath))
+  })
+  const authors = new Map<string, string>()
+  for (const sym of symbols) {
+    const author = topAuthor(blamed.get(sym.path) || [], sym.line, sym.end_line || sym.line)
+    if (author) authors.set(sym.id, author)
+  }
+  return authors
+}
+
+/**
+ * Method set and fields of a type, promoted members marked with the base
+ * type they come from
+ */
+function printMembers(index: SymbolIndex, typeName: string, json: boolean) {
+  const types = index.findSymbols(typeName).filter(s => TYPE_KINDS.has(s.kind))
+  const rows = types.flatMap(type => [...index.methodSet(type.id), ...index.fields(type.id)].map(m => ({
+    type: type.name,
+    member: m.name,
+    kind: m.symbol.kind,
+    path: m.symbol.path,
+    line: m.symbol.line,
+    promoted: m.promoted,
+    via: m.via
+  })))
+
+  if (json) {
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (rows.length === 0) {
+    log(`No members found for ${typeName}.`)
+    return
+  }
+  printTable(
+    ['TYPE', 'KIND', 'MEMBER', 'LOCATION', 'VIA'],
+    rows.map(r => [r.type, r.kind, r.member, `${r.path}:${r.line}`, r.via.join(' > ')])
+  )
+}
+
+/**
+ * Values of the enums and literal union types with a name
+ */
+function printEnumValues(index: SymbolIndex, typeName: string, json: boolean) {
+  const types = index.findSymbols(typeName).filter(s => ENUM_KINDS.has(s.kind))
+  const rows = types.flatMap(type => index.enumValues(type.id).map(v => ({
+    type: type.name,
+    name: v.name,
+    value: v.value ?? null,
+    path: v.symbol?.path ?? type.path,
+    line: v.symbol?.line ?? type.line
+  })))
+
+  if (json) {
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (rows.length === 0) {
+    log(`No values found for ${typeName}.`)
+    return
+  }
+  printTable(
+    ['TYPE', 'NAME', 'VALUE', 'LOCATION'],
+    rows.map(r => [r.type, r.name, r.value === null ? '' : JSON.stringify(r.value), `${r.path}:${r.line}`])
+  )
+}
+
+/**
+ * --scope=module|workspace|all, or the query's default
+ */
+function scopeFlag(value: string | boolean | undefined, fallback: SearchScope): SearchScope {
+  if (typeof value !== 'string') return fallback
+  try {
+    return parseScope(value)
+  } catch (e: any) {
+    fail(e.message)
+  }
+}
+
+/**
+ * Supertypes or subtypes of the types and interfaces with a name
+ */
+function printHierarchy(
+  index: SymbolIndex,
+  typeName: string,
+  direction: 'supertypes' | 'subtypes',
+  transitive: boolean,
+  scope: SearchScope,
+  json: boolean
+) {
+  const types = index.findSymbols(typeName).filter(s => TYPE_KINDS.has(s.kind) || INTERFACE_KINDS.has(s.kind))
+  const rows = types.flatMap(type => {
+    const items = direction === 'supertypes' ? index.supertypes(type.id, transitive, scope) : index.subtypes(type.id, transitive, scope)
+    return items.map(item => ({
+      type: type.name,
+      name: item.symbol.name,
+      kind: item.symbol.kind,
+      path: item.symbol.path,
+      line: item.symbol.line,
+      relation: item.relation,
+      depth: item.depth
+    }))
+  })
+
+  if (json) {
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (rows.length === 0) {
+    log(`No ${direction} found for ${typeName}.`)
+    return
+  }
+  printTable(
+    ['TYPE', 'KIND', direction === 'supertypes' ? 'SUPERTYPE' : 'SUBTYPE', 'LOCATION', 'RELATION', 'DEPTH'],
+    rows.map(r => [r.type, r.kind, r.name, `${r.path}:${r.line}`, r.relation, String(r.depth)])
+  )
+}
+
+/**
+ * Definitions of the identifier at a <file>:<line>:<col> position
+ */
+function printSymbolAt(index: SymbolIndex, position: string, json: boolean) {
+  const match = position.match(/^(.+):(\d+):(\d+)$/)
+  if (!match) fail('Usage: indexer query --at=<file>:<line>:<col>')
+  const [, filePath, line, column] = match
+  const at = index.symbolAt(index.resolvePath(filePath) ?? toIndexPath(filePath), parseInt(line, 10), parseInt(column, 10))
+
+  if (json) {
+    process.stdout.write(JSON.stringify(at && {
+      name: at.name,
+      line: at.line,
+      column: at.column,
+      end_column: at.end_column,
+      declaration: at.declaration,
+      symbols: at.symbols.map(s => ({ id: s.id, name: s.name, kind: s.kind, path: s.path, line: s.line, end_line: s.end_line }))
+    }, null, 2) + '\n')
+    return
+  }
+  if (!at || at.symbols.length === 0) {
+    log(`No symbol at ${position}.`)
+    return
+  }
+  printTable(
+    ['NAME', 'KIND', 'LOCATION', 'ID'],
+    at.symbols.map(s => [s.name, s.kind, `${s.path}:${s.line}`, s.id])
+  )
+}
+
+/**
+ * Tests, benchmarks, fuzz targets and examples exercising the symbols with a name
+ */
+function printTests(index: SymbolIndex, name: string, json: boolean) {
+  const rows = index.findSymbols(name).flatMap(sym => index.testsFor(sym.id).map(l => ({
+    symbol: sym.name,
+    kind: l.test.kind,
+    test: l.test.name,
+    path: l.test.path,
+    line: l.test.line,
+    end_line: l.test.end_line,
+    via: l.via
+  })))
+
+  if (json) {
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (rows.length === 0) {
+    log(`No tests found for ${name}.`)
+    return
+  }
+  printTable(
+    ['SYMBOL', 'KIND', 'TEST', 'LOCATION', 'VIA'],
+    rows.map(r => [r.symbol, r.kind, r.test, `${r.path}:${r.line}`, r.via])
+  )
+}
+
+function printThrowPaths(index: SymbolIndex, name: string, json: boolean) {
+  const rows = index.findSymbols(name).flatMap(fn => {
+    const found = index.canThrow(fn.id)
+    if (!found) return []
+    return [{
+      symbol: fn.name,
+      path: fn.path,
+      line: fn.line,
+      thrower: found.thrower.name,
+      throws: found.types,
+      escaping: index.escapingErrors(fn.id),
+      calls: found.calls.map(e => ({ callee: index.getSymbol(e.callee)?.name || e.callee, path: e.path, line: e.line, dynamic: e.dynamic }))
+    }]
+  })
+
+  if (json) {
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (rows.length === 0) {
+    log(`${name} cannot throw.`)
+    return
+  }
+  printTable(
+    ['SYMBOL', 'LOCATION', 'ERRORS', 'VIA'],
+    rows.map(r => [
+      r.symbol,
+      `${r.path}:${r.line}`,
+      r.escaping.join(','),
+      r.calls.length === 0 ? 'throws itself' : r.calls.map(c => `${c.callee} (${c.path}:${c.line}${c.dynamic ? ', dynamic' : ''})`).join(' -> ')
+    ])
+  )
+}
+
+/**
+ * Regex search over indexed sources, pruned by the trigram index:
+ * indexer grep <regex> [--ignore-case] [--limit=N] [--context=N] [--deps] [--rev=<rev>] [--json]
+ */
+export async function handleGrep(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const pattern = positional[0]
+  if (!pattern) {
+    fail('Usage: indexer grep <regex> [--ignore-case] [--limit=N] [--context=N] [--json]')
+  }
+  const context = contextFlag(flags.context)
+  try {
+    new RegExp(pattern)
+  } catch (e: any) {
+    fail(`Invalid regex: ${e.message}`)
+  }
+
+  const root = await findProjectRoot(startCwd)
+  const { index, readSource } = await openIndex(startCwd, root, flags)
+  const matches = await index.text.search(
+    pattern,
+    readSource,
+    {
+      ignoreCase: !!flags['ignore-case'],
+      limit: typeof flags.limit === 'string' ? parseInt(flags.limit, 10) || undefined : undefined
+    }
+  )
+  const snippets = context === null ? null : await new SnippetCache(readSource).snippets(index, matches, context)
+
+  if (flags.json || flags.format === 'json') {
+    const rows = snippets ? matches.map((m, i) => (snippets[i] ? { ...m, context: snippets[i] } : m)) : matches
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (snippets) {
+    printSnippets(matches.map((m, i) => ({ title: `${m.path}:${m.line}:${m.column}`, line: m.line, snippet: snippets[i] })))
+    return
+  }
+  for (const m of matches) {
+    console.log(`${m.path}:${m.line}:${m.column}: ${m.text.trim()}`)
+  }
+}
+
+/**
+ * Symbols with no references outside their declaration:
+ * indexer deadcode [--exported[=false]] [--kind=a,b] [--allow=glob,...]
+ *                  [--allowlist=<file>] [--deps] [--rev=<rev>] [--json]
+ * Allowlist globs are read from .indexer/deadcode-allow unless --allowlist
+ * names another file.
+ */
+export async function handleDeadCode(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const root = await findProjectRoot(startCwd)
+  const allow = [
+    ...await loadAllowlist(root, typeof flags.allowlist === 'string' ? path.resolve(startCwd, flags.allowlist) : undefined),
+    ...(listFlag(flags.allow) || [])
+  ]
+  const { index } = await openIndex(startCwd, root, flags)
+  const dead = findDeadCode(index, {
+    allow,
+    kinds: listFlag(flags.kind),
+    exported: flags.exported === undefined ? undefined : flags.exported !== 'false'
+  })
+
+  if (flags.json || flags.format === 'json') {
+    const rows = dead.map(s => ({
+      name: s.name,
+      kind: s.kind,
+      path: s.path,
+      line: s.line,
+      end_line: s.end_line,
+      exported: !!s.exported
+    }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+
+  if (dead.length === 0) {
+    log('No unreferenced symbols.')
+    return
+  }
+  printTable(
+    ['KIND', 'NAME', 'LOCATION', 'EXPORTED'],
+    dead.map(s => [s.kind, s.name, `${s.path}:${s.line}`, s.exported ? 'yes' : 'no'])
+  )
+  log(`${dead.length} unreferenced symbol${dead.length === 1 ? '' : 's'}`)
+}
+
+/**
+ * Functions with structurally identical or near-identical bodies:
+ * indexer dupes [--min-size=N] [--similarity=0.8] [--exact] [--kind=a,b]
+ *               [--package=./lib/...] [--deps] [--rev=<rev>] [--json]
+ * Sizes count AST nodes of the body.
+ */
+export async function handleDupes(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const minSize = typeof flags['min-size'] === 'string' ? Number(flags['min-size']) : DEFAULT_MIN_SIZE
+  if (!(minSize > 0)) {
+    fail(`Invalid --min-size "${flags['min-size']}": expected a positive number of AST nodes`)
+  }
+  const similarity = typeof flags.similarity === 'string' ? Number(flags.similarity) : DEFAULT_SIMILARITY
+  if (!(similarity > 0 && similarity <= 1)) {
+    fail(`Invalid --similarity "${flags.similarity}": expected a number in (0, 1]`)
+  }
+  const root = await findProjectRoot(startCwd)
+  const { index } = await openIndex(startCwd, root, flags)
+  const groups = findDuplicates(index, {
+    minSize,
+    similarity,
+    exact: !!flags.exact,
+    kinds: listFlag(flags.kind),
+    packages: listFlag(flags.package)
+  })
+
+  if (flags.json || flags.format === 'json') {
+    const rows = groups.map(g => ({
+      exact: g.exact,
+      similarity: g.similarity,
+      size: g.size,
+      symbols: g.symbols.map(s => ({
+        name: s.name,
+        kind: s.kind,
+        path: s.path,
+        line: s.line,
+        end_line: s.end_line,
+        size: s.body_size
+      }))
+    }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+
+  if (groups.length === 0) {
+    log('No duplicated function bodies.')
+    return
+  }
+  printTable(
+    ['GROUP', 'MATCH', 'SIZE', 'NAME', 'LOCATION'],
+    groups.flatMap((g, i) => g.symbols.map(s => [
+      String(i + 1),
+      g.exact ? 'exact' : `${Math.round(g.similarity * 100)}%`,
+      String(s.body_size),
+      s.name,
+      `${s.path}:${s.line}-${s.end_line}`
+    ]))
+  )
+  const copies = groups.reduce((n, g) => n + g.symbols.length, 0)
+  log(`${groups.length} duplicate group${groups.length === 1 ? '' : 's'}, ${copies} functions`)
+}
+
+/**
+ * Write the exported API surface as a manifest:
+ * indexer api [--package=./lib/...] [--rev=<rev>] [--output=api.json]
+ * The manifest goes to stdout unless --output is given.
+ */
+export async function handleApi(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const packages = listFlag(flags.package)
+  const root = await findProjectRoot(startCwd)
+  const { index } = await openIndex(startCwd, root, flags, packages)
+  const data = JSON.stringify(extractApiSurface(index, { packages }), null, 2) + '\n'
+  const output = typeof flags.output === 'string' ? flags.output : '-'
+  if (output === '-') {
+    process.stdout.write(data)
+    return
+  }
+  const outPath = path.resolve(startCwd, output)
+  await fs.writeFile(outPath, data)
+  log(`Wrote API manifest to ${outPath}`)
+}
+
+// Manifest of a revision, a manifest file (*.json) or, without a spec, the working tree
+async function apiManifestOf(root: string, startCwd: string, spec: string | undefined, packages?: string[]): Promise<ApiManifest> {
+  if (spec?.endsWith('.json')) {
+    const content = await fs.readFile(path.resolve(startCwd, spec), 'utf8').catch(() => fail(`Cannot read API manifest ${spec}`))
+    try {
+      return parseApiManifest(content)
+    } catch (e: any) {
+      fail(`${spec}: ${e.message}`)
+    }
+  }
+  const { index } = spec
+    ? await openRevisionIndex(root, spec).catch((e: Error) => fail(e.message))
+    : await openIndex(startCwd, root, {}, packages)
+  return extractApiSurface(index, { packages })
+}
+
+/**
+ * Exported API changes between two revisions or manifests:
+ * indexer apidiff <old> [<new>] [--package=./lib/...] [--json]
+ * Each side is a git revision or a manifest written by "indexer api"; <new>
+ * defaults to the working tree. Exits with status 1 when a change is breaking.
+ */
+export async function handleApiDiff(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  if (positional.length === 0) {
+    fail('Usage: indexer apidiff <old-rev|old.json> [<new-rev|new.json>] [--package=./lib/...] [--json]')
+  }
+  const packages = listFlag(flags.package)
+  const root = await findProjectRoot(startCwd)
+  const before = await apiManifestOf(root, startCwd, positional[0], packages)
+  const after = await apiManifestOf(root, startCwd, positional[1], packages)
+  const changes = diffApiSurface(before, after)
+  const breaking = changes.filter(c => c.breaking).length
+  if (breaking > 0) process.exitCode = 1
+
+  if (flags.json || flags.format === 'json') {
+    process.stdout.write(JSON.stringify(changes, null, 2) + '\n')
+    return
+  }
+  if (changes.length === 0) {
+    log('No API changes.')
+    return
+  }
+  printTable(
+    ['CHANGE', 'BREAKING', 'KIND', 'NAME', 'FILE', 'DETAIL'],
+    changes.map(c => {
+      const entry = (c.after || c.before)!
+      return [c.change, c.breaking ? 'yes' : 'no', entry.kind, entry.name, entry.path, c.reasons.join('; ')]
+    })
+  )
+  log(`${changes.length} API change${changes.length === 1 ? '' : 's'}, ${breaking} breaking`)
+}
+
+/**
+ * Recorded generations of the symbol index, and the symbols that moved
+ * between two of them:
+ * indexer history [--json]
+ * indexer history diff <from> [<to>] [--moves] [--package=./lib/...] [--json]
+ * Each side is a generation number, an ISO date or a time ago (7d, 12h);
+ * <to> defaults to the current index. --moves lists only moved and renamed
+ * symbols.
+ */
+export async function handleHistory(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const root = await findProjectRoot(startCwd)
+  if (positional[0] === 'diff') {
+    if (!positional[1]) fail('Usage: indexer history diff <from> [<to>] [--moves] [--package=./lib/...] [--json]')
+    const packages = listFlag(flags.package)
+    const { index: before } = await openGeneration(root, positional[1])
+    const { index: after } = positional[2]
+      ? await openGeneration(root, positional[2])
+      : await openIndex(startCwd, root, {})
+    let changes = diffGenerations(before, after, { packages })
+    if (flags.moves) changes = changes.filter(c => c.change === 'moved' || c.change === 'renamed')
+
+    if (flags.json || flags.format === 'json') {
+      process.stdout.write(JSON.stringify(changes, null, 2) + '\n')
+      return
+    }
+    if (changes.length === 0) {
+      log('No symbol changes.')
+      return
+    }
+    const place = (p?: { name: string, path: string, line: number }) => (p ? `${p.name} ${p.path}:${p.line}` : '')
+    printTable(['CHANGE', 'KIND', 'BEFORE', 'AFTER'], changes.map(c => [c.change, c.kind, place(c.before), place(c.after)]))
+    const moved = changes.filter(c => c.change === 'moved').length
+    const renamed = changes.filter(c => c.change === 'renamed').length
+    log(`${changes.length} symbol change${changes.length === 1 ? '' : 's'}, ${moved} moved, ${renamed} renamed`)
+    return
+  }
+  if (positional[0] !== undefined) fail(`Unknown history command "${positional[0]}". Use: indexer history [diff <from> [<to>]]`)
+
+  const generations = await listGenerations(root)
+  if (flags.json || flags.format === 'json') {
+    process.stdout.write(JSON.stringify(generations, null, 2) + '\n')
+    return
+  }
+  if (generations.length === 0) {
+    const { keep } = await loadHistoryPolicy(root).catch((e: Error) => fail(e.message))
+    log(keep > 0 ? 'No index generations recorded yet.' : 'No index generations recorded. Set history: <count> in .indexer/to-index to keep some.')
+    return
+  }
+  printTable(
+    ['GENERATION', 'RECORDED', 'FILES'],
+    [...generations].reverse().map(g => [String(g.generation), g.recorded_at, String(g.files)])
+  )
+}
+
+/**
+ * TODO / FIXME / HACK / BUG / XXX comments:
+ * indexer todos [--marker=TODO,FIXME] [--package=./lib/...] [--owner=@team] [--blame] [--author=<email>] [--rev=<rev>] [--json]
+ * --blame adds the author of each comment line from git blame; --author
+ * filters on it.
+ */
+export async function handleTodos(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const packages = listFlag(flags.package)
+  const root = await findProjectRoot(startCwd)
+  const { index } = await openIndex(startCwd, root, flags, packages)
+  let todos = findTodos(index, { markers: listFlag(flags.marker), packages, owners: listFlag(flags.owner) })
+
+  const wantedAuthors = listFlag(flags.author).map(a => a.toLowerCase())
+  const authors = new Map<string, string[]>()
+  if (flags.blame || wantedAuthors.length > 0) {
+    await mapConcurrent([...new Set(todos.map(t => t.path))], 8, async (relPath) => {
+      authors.set(relPath, await blameAuthors(root, relPath))
+    })
+  }
+  const authorOf = (todo: TodoEntry) => authors.get(todo.path)?.[todo.line - 1]
+  if (wantedAuthors.length > 0) {
+    todos = todos.filter(t => wantedAuthors.includes((authorOf(t) || '').toLowerCase()))
+  }
+
+  if (flags.json || flags.format === 'json') {
+    const rows = todos.map(t => ({
+      marker: t.marker,
+      text: t.text,
+      path: t.path,
+      line: t.line,
+      column: t.column,
+      ...(t.assignee ? { assignee: t.assignee } : {}),
+      ...(t.symbol ? { symbol: t.symbol.name, symbol_id: t.symbol.id } : {}),
+      ...(t.owners.length > 0 ? { owners: t.owners } : {}),
+      ...(authorOf(t) ? { author: authorOf(t) } : {})
+    }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (todos.length === 0) {
+    log('No TODO comments.')
+    return
+  }
+  const blamed = authors.size > 0
+  printTable(
+    ['MARKER', 'LOCATION', 'SYMBOL', 'OWNERS', ...(blamed ? ['AUTHOR'] : []), 'TEXT'],
+    todos.map(t => [
+      t.assignee ? `${t.marker}(${t.assignee})` : t.marker,
+      `${t.path}:${t.line}`,
+      t.symbol?.name || '',
+      t.owners.join(','),
+      ...(blamed ? [authorOf(t) || ''] : []),
+      t.text
+    ])
+  )
+  log(`${todos.length} comment${todos.length === 1 ? '' : 's'}`)
+}
+
+/**
+ * Syntax errors and analyzer findings of the indexed files:
+ * indexer diagnostics [--package=./lib/...] [--lang=<lang>] [--analyzer=syntax,eslint] [--rule=<rule>,...] [--rev=<rev>] [--json]
+ * Files with errors are still indexed from what parsed around them. Exits
+ * with status 1 when there are any.
+ */
+export async function handleDiagnostics(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const packages = listFlag(flags.package)
+  const root = await findProjectRoot(startCwd)
+  const { index } = await openIndex(startCwd, root, flags, packages)
+  const diagnostics = findParseDiagnostics(index, {
+    packages,
+    lang: typeof flags.lang === 'string' ? flags.lang : undefined,
+    analyzers: listFlag(flags.analyzer),
+    rules: listFlag(flags.rule)
+  })
+  if (diagnostics.length > 0) process.exitCode = 1
+
+  if (flags.json || flags.format === 'json') {
+    process.stdout.write(JSON.stringify(diagnostics, null, 2) + '\n')
+    return
+  }
+  const syntaxOnly = diagnostics.every(d => !d.analyzer)
+  if (diagnostics.length === 0) {
+    log(index.analyzers.length > 0 ? 'No diagnostics.' : 'No syntax errors.')
+    return
+  }
+  const files = new Set(diagnostics.map(d => d.path)).size
+  const summary = `in ${files} file${files === 1 ? '' : 's'}`
+  if (syntaxOnly) {
+    printTable(['LOCATION', 'MESSAGE'], diagnostics.map(d => [`${d.path}:${d.line}:${d.column}`, d.message]))
+    log(`${diagnostics.length} syntax error${diagnostics.length === 1 ? '' : 's'} ${summary}`)
+    return
+  }
+  printTable(['LOCATION', 'SEVERITY', 'ANALYZER', 'RULE', 'MESSAGE'], diagnostics.map(d => [
+    `${d.path}:${d.line}:${d.column}`,
+    d.severity || 'error',
+    diagnosticSource(d),
+    d.rule || '',
+    d.message
+  ]))
+  log(`${diagnostics.length} diagnostic${diagnostics.length === 1 ? '' : 's'} ${summary}`)
+}
+
+/**
+ * String, template and regexp literals containing a text:
+ * indexer strings [text] [--regex] [--ignore-case] [--kind=string,template,regexp] [--package=./lib/...] [--min-length=N] [--strings=N] [--json]
+ * The index keeps literals of at least --strings characters (8 by default);
+ * --min-length narrows the results further without re-parsing.
+ */
+export async function handleStrings(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const packages = listFlag(flags.package)
+  const root = await findProjectRoot(startCwd)
+  const { index } = await openIndex(startCwd, root, { ...flags, strings: flags.strings || true }, packages)
+  let matches: StringMatch[] = []
+  try {
+    matches = findStrings(index, {
+      text: positional.join(' '),
+      regex: !!flags.regex,
+      ignoreCase: !!flags['ignore-case'],
+      kinds: listFlag(flags.kind),
+      packages,
+      minLength: Number(flags['min-length']) || 0
+    })
+  } catch (e: any) {
+    fail(`Invalid --regex pattern: ${e.message}`)
+  }
+
+  if (flags.json || flags.format === 'json') {
+    const rows = matches.map(m => ({
+      value: m.value,
+      kind: m.kind,
+      path: m.path,
+      line: m.line,
+      column: m.column,
+      count: m.count || 1,
+      ...(m.symbol ? { symbol: m.symbol.name, symbol_id: m.symbol.id } : {})
+    }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (matches.length === 0) {
+    log('No matching literals.')
+    return
+  }
+  printTable(
+    ['LOCATION', 'SYMBOL', 'KIND', 'COUNT', 'VALUE'],
+    matches.map(m => [
+      `${m.path}:${m.line}:${m.column}`,
+      m.symbol?.name || '',
+      m.kind,
+      String(m.count || 1),
+      JSON.stringify(m.value.length > 80 ? `${m.value.slice(0, 77)}...` : m.value)
+    ])
+  )
+  log(`${matches.length} literal${matches.length === 1 ? '' : 's'}`)
+}
+
+/**
+ * Control-flow landmarks of functions (labels, goto, labeled break / continue, catch / finally):
+ * indexer landmarks [<fn>] [--kind=label,goto,break,continue,catch,finally] [--label=<name>] [--package=./lib/...] [--rev=<rev>] [--json]
+ */
+export async function handleLandmarks(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const packages = listFlag(flags.package)
+  const kinds = listFlag(flags.kind) || []
+  const unknown = kinds.find(k => !LANDMARK_KINDS.includes(k as LandmarkKind))
+  if (unknown) fail(`Unknown --kind "${unknown}". Use --kind=${LANDMARK_KINDS.join(',')}`)
+  const root = await findProjectRoot(startCwd)
+  const { index } = await openIndex(startCwd, root, flags, packages)
+  const sites = findLandmarks(index, {
+    kinds: kinds as LandmarkKind[],
+    packages,
+    name: positional[0],
+    label: typeof flags.label === 'string' ? flags.label : undefined
+  })
+
+  if (flags.json || flags.format === 'json') {
+    const rows = sites.map(s => ({
+      kind: s.kind,
+      path: s.path,
+      line: s.line,
+      column: s.column,
+      ...(s.label !== undefined ? { label: s.label } : {}),
+      ...(s.target !== undefined ? { target: s.target } : {}),
+      symbol: s.symbol.name,
+      symbol_id: s.symbol.id
+    }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (sites.length === 0) {
+    log('No landmarks.')
+    return
+  }
+  printTable(
+    ['LOCATION', 'KIND', 'LABEL', 'TARGET', 'FUNCTION'],
+    sites.map(s => [
+      `${s.path}:${s.line}:${s.column}`,
+      s.kind,
+      s.label ?? '',
+      s.target !== undefined ? `${s.path}:${s.target}` : '',
+      s.symbol.name
+    ])
+  )
+  const functions = new Set(sites.map(s => s.symbol.id)).size
+  log(`${sites.length} landmark${sites.length === 1 ? '' : 's'} in ${functions} function${functions === 1 ? '' : 's'}`)
+}
+
+/**
+ * Uses of deprecated symbols across the workspace:
+ * indexer deprecations [--unused] [--deps] [--rev=<rev>] [--json]
+ * With --unused, deprecated symbols nothing uses any more are listed too.
+ */
+export async function handleDeprecations(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const root = await findProjectRoot(startCwd)
+  const { index } = await openIndex(startCwd, root, flags)
+  const report = findDeprecatedUses(index)
+
+  if (flags.json || flags.format === 'json') {
+    const rows = report.filter(d => flags.unused || d.uses.length > 0).map(d => ({
+      name: d.symbol.name,
+      kind: d.symbol.kind,
+      path: d.symbol.path,
+      line: d.symbol.line,
+      notice: d.notice,
+      uses: d.uses
+    }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+
+  const rows: string[][] = []
+  for (const d of report) {
+    const notice = d.notice.replace(/\s+/g, ' ')
+    for (const use of d.uses) {
+      rows.push([`${use.path}:${use.line}${use.column ? `:${use.column}` : ''}`, d.symbol.name, `${d.symbol.path}:${d.symbol.line}`, notice])
+    }
+    if (flags.unused && d.uses.length === 0) rows.push(['(unused)', d.symbol.name, `${d.symbol.path}:${d.symbol.line}`, notice])
+  }
+  if (rows.length === 0) {
+    log(report.length === 0 ? 'No deprecated symbols.' : 'No uses of deprecated symbols.')
+    return
+  }
+  printTable(['USE', 'DEPRECATED', 'DECLARED', 'NOTICE'], rows)
+  const used = report.filter(d => d.uses.length > 0)
+  const uses = used.reduce((n, d) => n + d.uses.length, 0)
+  log(`${uses} use${uses === 1 ? '' : 's'} of ${used.length} deprecated symbol${used.length === 1 ? '' : 's'} (${report.length - used.length} unused)`)
+}
+
+/**
+ * Calls into security-sensitive APIs, by package, with the callers that
+ * reach them:
+ * indexer audit [--package=./lib/...] [--category=exec,sql_concat] [--depth=N] [--deps] [--rev=<rev>] [--json]
+ * --depth is how many callers are followed up from each call (4 by default).
+ */
+export async function handleAudit(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const categories = listFlag(flags.category)
+  const unknown = categories?.find(c => !SENSITIVE_CATEGORIES.includes(c as SensitiveCategory))
+  if (unknown) fail(`Unknown --category "${unknown}". Use --category=${SENSITIVE_CATEGORIES.join(',')}`)
+  const depth = flags.depth === undefined ? undefined : Number(flags.depth)
+  if (depth !== undefined && (!Number.isInteger(depth) || depth < 0)) fail('Usage: --depth=N, the number of callers to follow up from each call')
+
+  const root = await findProjectRoot(startCwd)
+  // Callers can be in any package, so every package is loaded
+  const { index, readSource } = await openIndex(startCwd, root, flags)
+  const report = await auditSecurity(index, readSource, {
+    packages: listFlag(flags.package),
+    categories: categories as SensitiveCategory[] | undefined,
+    depth
+  })
+  const nameOf = (id: string) => index.getSymbol(id)?.name || id
+
+  if (flags.json || flags.format === 'json') {
+    const rows = report.map(pkg => ({
+      package: pkg.package,
+      calls: pkg.calls,
+      categories: pkg.categories,
+      findings: pkg.findings.map(f => ({
+        category: f.category,
+        api: f.api,
+        path: f.path,
+        line: f.line,
+        column: f.column,
+        ...(f.symbol ? { symbol: nameOf(f.symbol), symbol_id: f.symbol } : {}),
+        callers: f.callers.map(chain => chain.map(nameOf))
+      }))
+    }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (report.length === 0) {
+    log('No calls into sensitive APIs.')
+    return
+  }
+  for (const pkg of report) {
+    const counts = SENSITIVE_CATEGORIES.filter(c => pkg.categories[c]).map(c => `${c} ${pkg.categories[c]}`).join(', ')
+    console.log(`${pkg.package}: ${pkg.calls} call${pkg.calls === 1 ? '' : 's'} (${counts})`)
+    printTable(
+      ['CATEGORY', 'API', 'LOCATION', 'IN', 'CALLED FROM'],
+      pkg.findings.map(f => [
+        f.category,
+        f.api,
+        `${f.path}:${f.line}`,
+        f.symbol ? nameOf(f.symbol) : '',
+        f.callers.map(chain => chain.map(nameOf).join(' -> ')).join('; ')
+      ])
+    )
+    console.log('')
+  }
+  const calls = report.reduce((n, pkg) => n + pkg.calls, 0)
+  log(`${calls} sensitive call${calls === 1 ? '' : 's'} in ${report.length} package${report.length === 1 ? '' : 's'}`)
+}
+
+const IMPACT_FORMATS = ['text', 'json', 'go-test-args', 'test-files']
+
+/**
+ * What a change to a symbol could break, for test selection:
+ * indexer impacted --symbol=<name> [--depth=N] [--imports=false] [--rev=<rev>] [--format=text|json|go-test-args|test-files]
+ * The name is a symbol ID, a short or qualified name, or one qualified by its
+ * package directory (store.Save). go-test-args prints the impacted packages
+ * for `go test`, test-files the test files to run, one per line.
+ */
+export async function handleImpacted(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const name = typeof flags.symbol === 'string' ? flags.symbol : positional[0]
+  const format = typeof flags.format === 'string' ? flags.format : flags.json ? 'json' : 'text'
+  if (!name) fail('Usage: indexer impacted --symbol=<name> [--depth=N] [--format=text|json|go-test-args|test-files]')
+  if (!IMPACT_FORMATS.includes(format)) fail(`Unknown --format "${format}". Use --format=${IMPACT_FORMATS.join('|')}`)
+  const depth = flags.depth === undefined ? undefined : Number(flags.depth)
+  if (depth !== undefined && (!Number.isInteger(depth) || depth < 0)) fail('Usage: --depth=N, the number of reference hops to follow')
+
+  const root = await findProjectRoot(startCwd)
+  // Dependents can be in any package, so every package is loaded
+  const { index, readSource } = await openIndex(startCwd, root, flags)
+  const targets = resolveImpactTargets(index, name)
+  if (targets.length === 0) fail(`No symbol named "${name}"`)
+  const importGraph = flags.imports === 'false'
+    ? null
+    : await computeImportGraph(index, readSource, { packageGraph: await resolvePackageGraph(root) })
+  const report = computeImpact(index, targets.map(s => s.id), { depth, importGraph })
+
+  if (format === 'go-test-args') {
+    process.stdout.write(goTestArgs(report) + '\n')
+    return
+  }
+  if (format === 'test-files') {
+    if (report.testFiles.length > 0) process.stdout.write(report.testFiles.join('\n') + '\n')
+    return
+  }
+  if (format === 'json') {
+    const json = {
+      symbols: report.symbols.map(s => ({ id: s.id, name: s.name, path: s.path, line: s.line })),
+      dependents: report.dependents.map(d => ({ id: d.symbol.id, name: d.symbol.name, path: d.symbol.path, line: d.symbol.line, depth: d.depth, via: d.via })),
+      files: report.files,
+      packages: report.packages,
+      tests: report.tests.map(t => ({ kind: t.kind, name: t.name, path: t.path, line: t.line })),
+      test_files: report.testFiles
+    }
+    process.stdout.write(JSON.stringify(json, null, 2) + '\n')
+    return
+  }
+
+  printTable(['PACKAGE', 'REASON'], report.packages.map(p => [p.package, p.reason]))
+  if (report.tests.length > 0) {
+    console.log('')
+    printTable(['TEST', 'KIND', 'LOCATION'], report.tests.map(t => [t.name, t.kind, `${t.path}:${t.line}`]))
+  }
+  const count = (n: number, noun: string) => `${n} ${noun}${n === 1 ? '' : 's'}`
+  log(`${count(report.dependents.length, 'dependent')} of ${report.symbols.map(s => s.name).join(', ')} in ${count(report.packages.length, 'package')}; ${count(report.tests.length, 'linked test')} in ${count(report.testFiles.length, 'test file')}`)
+}
+
+const STATS_FORMATS = ['text', 'json', 'csv']
+
+/**
+ * Per-package metrics for dashboards:
+ * indexer stats [--package=./lib/...] [--rev=<rev>] [--format=text|json|csv]
+ */
+export async function handleStats(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const format = typeof flags.format === 'string' ? flags.format : flags.json ? 'json' : 'text'
+  if (!STATS_FORMATS.includes(format)) fail(`Unknown --format "${format}". Use --format=${STATS_FORMATS.join('|')}`)
+
+  const root = await findProjectRoot(startCwd)
+  // Fan-in and fan-out reach into other packages, so every package is loaded
+  const { index } = await openIndex(startCwd, root, flags)
+  const stats = computePackageStats(index, { packages: listFlag(flags.package) })
+
+  if (format === 'csv') {
+    process.stdout.write(packageStatsCsv(stats))
+    return
+  }
+  if (format === 'json') {
+    process.stdout.write(JSON.stringify(stats, null, 2) + '\n')
+    return
+  }
+  if (stats.length === 0) {
+    log('No packages indexed.')
+    return
+  }
+  const percent = (ratio: number) => `${Math.round(ratio * 100)}%`
+  printTable(
+    ['PACKAGE', 'FILES', 'SYMBOLS', 'EXPORTED', 'FUNCS', 'AVG LINES', 'FAN-IN', 'FAN-OUT', 'TESTED EXPORTS'],
+    stats.map(s => [
+      s.package,
+      String(s.files),
+      String(s.symbols),
+      `${s.exported} (${percent(s.exported_ratio)})`,
+      String(s.functions),
+      String(s.avg_function_lines),
+      String(s.fan_in),
+      String(s.fan_out),
+      `${s.tested_exports} (${percent(s.export_coverage)})`
+    ])
+  )
+}
+
+/**
+ * Package import graph:
+ * indexer imports <package> [--imported-by] [--external] [--deps] [--rev=<rev>] [--json]
+ * indexer imports --cycles [--json]
+ * Packages are source directories relative to the project root ("lib/core").
+ */
+export async function handleImports(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const pkg = positional[0]?.replace(/^\.\/(?=.)/, '').replace(/\/+$/, '')
+  if (!pkg && !flags.cycles) {
+    fail('Usage: indexer imports <package> [--imported-by] [--external] [--json] | indexer imports --cycles [--json]')
+  }
+
+  const root = await findProjectRoot(startCwd)
+  const { index, readSource } = await openIndex(startCwd, root, flags)
+  const graph = await computeImportGraph(index, readSource, { packageGraph: await resolvePackageGraph(root) })
+  const json = !!(flags.json || flags.format === 'json')
+
+  if (flags.cycles) {
+    const cycles = findImportCycles(graph)
+    if (json) {
+      process.stdout.write(JSON.stringify(cycles, null, 2) + '\n')
+      return
+    }
+    if (cycles.length === 0) {
+      log('No import cycles between packages.')
+      return
+    }
+    for (const group of cycles) {
+      console.log(group.join(' <-> '))
+    }
+    log(`${cycles.length} import cycle${cycles.length === 1 ? '' : 's'}`)
+    return
+  }
+
+  if (!pkg || !graph.packages.includes(pkg)) {
+    fail(`No indexed package "${pkg}"`)
+  }
+  const reverse = !!flags['imported-by']
+  const edges = (reverse ? importersOf(graph, pkg) : importsOf(graph, pkg)).filter(e => flags.external || !e.external)
+  if (json) {
+    const rows = edges.map(e => ({ package: reverse ? e.from : e.to, external: e.external, imports: e.imports }))
+    process.stdout.write(JSON.stringify(rows, null, 2) + '\n')
+    return
+  }
+  if (edges.length === 0) {
+    log(reverse ? `Nothing imports ${pkg}.` : `${pkg} imports no other packages.`)
+    return
+  }
+  printTable(
+    ['PACKAGE', 'EXTERNAL', 'IMPORTS', 'FIRST'],
+    edges.map(e => [reverse ? e.from : e.to, e.external ? 'yes' : 'no', String(e.imports.length), `${e.imports[0].path}:${e.imports[0].line}`])
+  )
+}
+
+function warnDamaged(damaged: ShardDamage[]) {
+  const corrupt = damaged.filter(d => d.problem === 'corrupt')
+  if (corrupt.length > 0) warn(`Re-indexed ${corrupt.length} damaged symbol index shard(s): ${corrupt.map(d => d.path).join(', ')}`)
+}
+
+// An index built on a case-sensitive file system can hold paths a case-insensitive checkout cannot tell apart
+function warnCaseCollisions(index: SymbolIndex) {
+  if (!index.caseInsensitive) return
+  const collisions = pathCaseCollisions(index.listFiles())
+  if (collisions.length === 0) return
+  warn(`${collisions.length} group(s) of indexed paths differ only in case and resolve only as spelled: ${collisions.map(g => g.join(' / ')).join(', ')}`)
+}
+
+/**
+ * Check the stored symbol index shard by shard:
+ * indexer verify [--repair] [--json]
+ * Exits with status 1 when a shard is corrupt and --repair was not given.
+ */
+export async function handleVerify(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const root = await findProjectRoot(startCwd)
+  const store = getSymbolStore(root)
+  const shards = await store.load()
+  if (!shards) {
+    fail(`No symbol index in the ${symbolStoreKind()} store for this project`)
+  }
+  const { damaged } = verifyShards(shards)
+  const corrupt = damaged.filter(d => d.problem === 'corrupt')
+  if (flags.repair && damaged.length > 0) {
+    await openSymbolIndex(root, store)
+  }
+
+  if (flags.json || flags.format === 'json') {
+    process.stdout.write(JSON.stringify({ shards: shards.length, damaged, repaired: !!flags.repair && damaged.length > 0 }, null, 2) + '\n')
+  } else if (damaged.length === 0) {
+    log(`All ${shards.length} shards verified.`)
+  } else {
+    printTable(['FILE', 'PROBLEM'], damaged.map(d => [d.path, d.problem]))
+    log(flags.repair
+      ? `Re-indexed ${damaged.length} of ${shards.length} shards.`
+      : `${damaged.length} of ${shards.length} shards failed verification; run "indexer verify --repair" to re-index them.`)
+  }
+  if (corrupt.length > 0 && !flags.repair) process.exitCode = 1
+}
+
+/**
+ * Upgrade the stored symbol index, or an index file, written by an older
+ * indexer to the current format in place:
+ * indexer migrate [--index=<file.idx>] [--deps] [--locals] [--strings[=N]] [--json]
+ * Files whose shards cannot be upgraded are re-parsed; an index file, which
+ * has no sources, leaves them out.
+ */
+export async function handleMigrate(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const indexFile = typeof flags.index === 'string' ? path.resolve(startCwd, flags.index) : null
+  const result = await (async () => {
+    if (indexFile) return migrateIndexFile(indexFile)
+    const root = await findProjectRoot(startCwd)
+    const options = { deps: !!flags.deps, locals: !!flags.locals, strings: stringsFlag(flags.strings), ...limitFlags(flags) }
+    return migrateIndex(root, getSymbolStore(root), options)
+  })().catch((e: Error) => fail(e.message))
+  if (!result) {
+    fail(`No symbol index in the ${symbolStoreKind()} store for this project`)
+  }
+
+  if (flags.json || flags.format === 'json') {
+    process.stdout.write(JSON.stringify(result, null, 2) + '\n')
+  } else if (result.from === result.to) {
+    log(`The index is already in format ${result.to}.`)
+  } else {
+    const reparsed = result.update ? result.update.added.length + result.update.modified.length : 0
+    log(`Migrated the index from format ${result.from || 'unrecorded'} to ${result.to}: kept ${result.upgraded} shards, ` +
+      (indexFile ? `left out ${result.dropped.length} that could not be upgraded.` : `re-indexed ${reparsed} files.`))
+  }
+}
+
+function registryLocation(flags: Record<string, string | boolean>, usage: string): string {
+  const location = typeof flags.registry === 'string' ? flags.registry : process.env.INDEXER_REGISTRY
+  if (!location) fail(`${usage}\nSet --registry or INDEXER_REGISTRY (https://..., s3://bucket/prefix or gs://bucket/prefix).`)
+  return location
+}
+
+/**
+ * Build the index of a revision and upload it to the registry:
+ * indexer push [--rev=HEAD] [--registry=<url>] [--redact=strings,comments,contents]
+ */
+export async function handlePush(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const location = registryLocation(flags, 'Usage: indexer push [--rev=HEAD] [--registry=<url>] [--redact=strings,comments,contents]')
+  const rev = typeof flags.rev === 'string' ? flags.rev : 'HEAD'
+  const root = await findProjectRoot(startCwd)
+  const redact = await redactFlag(root, flags)
+  const pushed = await pushRevisionIndex(root, rev, location, undefined, redact).catch((e: Error) => fail(e.message))
+  log(`Pushed index of ${pushed.commit.slice(0, 12)} (${pushed.bytes} bytes) to ${pushed.url}`)
+}
+
+/**
+ * Download the prebuilt index of a revision from the registry:
+ * indexer pull [--rev=HEAD] [--registry=<url>]
+ * Exits with status 1 when the registry has no index for the commit.
+ */
+export async function handlePull(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const location = registryLocation(flags, 'Usage: indexer pull [--rev=HEAD] [--registry=<url>]')
+  const rev = typeof flags.rev === 'string' ? flags.rev : 'HEAD'
+  const root = await findProjectRoot(startCwd)
+  const pulled = await pullRevisionIndex(root, rev, location).catch((e: Error) => fail(e.message))
+  if (!pulled) {
+    warn(`The registry has no index of ${rev} for this module; build it with "indexer push --rev=${rev}" in CI.`)
+    process.exitCode = 1
+    return
+  }
+  log(`Pulled index of ${pulled.commit.slice(0, 12)} (${pulled.bytes} bytes); use it with --rev=${rev}`)
+}
+
+/**
+ * Merge repository indexes into one, for searching across repositories:
+ * indexer merge [<name>=]<a.idx> [<name>=]<b.idx> ... -o <combined.idx>
+ * Each index's files move under a directory named after it (its file name
+ * by default); imports of another repository's modules resolve into it.
+ * Query the result with --index=<combined.idx>.
+ */
+export async function handleMerge(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const at = positional.indexOf('-o')
+  const output = typeof flags.output === 'string' ? flags.output : at === -1 ? undefined : positional.splice(at, 2)[1]
+  if (!output || positional.length < 2) {
+    fail('Usage: indexer merge [<name>=]<a.idx> [<name>=]<b.idx> ... -o <combined.idx>')
+  }
+
+  const shards = await (async () => {
+    const inputs = await Promise.all(positional.map(async arg => {
+      const { name, file } = parseMergeInput(arg)
+      const { input, damaged } = await readMergeInput(name, path.resolve(startCwd, file))
+      if (damaged.length > 0) warn(`Left out ${damaged.length} damaged shard(s) of ${file}: ${damaged.map(d => d.path).join(', ')}`)
+      return input
+    }))
+    return mergeShards(inputs)
+  })().catch((e: Error) => fail(e.message))
+  const outPath = path.resolve(startCwd, output)
+  await writeIndexFile(outPath, shards, true)
+  const crossing = shards.reduce((n, shard) => n + shard.references.filter(r => r.repository).length, 0)
+  log(`Merged ${positional.length} indexes (${shards.length} files) into ${outPath}; ${crossing} references resolve into other repositories`)
+}
+
+/**
+ * Copy the stored symbol index between backends:
+ * indexer convert-index --to=sqlite|json|pack|sharded [--from=sqlite|json|pack|sharded]
+ * The source defaults to the store selected by SYMBOL_STORE.
+ */
+export async function handleConvertIndex(startCwd: string, args: string[]) {
+  const { flags } = parseFlags(args)
+  const kinds = SYMBOL_STORE_KINDS.join('|')
+  const from = (typeof flags.from === 'string' ? flags.from : symbolStoreKind()) as SymbolStoreKind
+  const to = flags.to as SymbolStoreKind
+  if (!SYMBOL_STORE_KINDS.includes(from) || !SYMBOL_STORE_KINDS.includes(to)) {
+    fail(`Usage: indexer convert-index --to=${kinds} [--from=${kinds}]`)
+  }
+  if (from === to) {
+    fail(`Source and target store are both "${from}"`)
+  }
+
+  const root = await findProjectRoot(startCwd)
+  const source = createSymbolStore(from, root)
+  const shards = await source.load()
+  if (!shards) {
+    fail(`No symbol index in the ${from} store for this project`)
+  }
+  // The copy keeps the shard format, so an old index still needs indexer migrate
+  await createSymbolStore(to, root).save(shards, await storedFormat(source) ?? undefined)
+  log(`Converted ${shards.length} files from ${from} to ${to}`)
+}
+
+/**
+ * Serve the symbol index over LSP: indexer lsp [--stdio] [--port=N] [--deps] [--locals]
+ * Stdio mode keeps stdout free for protocol messages.
+ */
+export async function handleLsp(startCwd: string, args: string[], portArg: string | null = null) {
+  const { flags } = parseFlags(args)
+  const root = await findProjectRoot(startCwd)
+  const { index, damaged } = await openSymbolIndex(root, undefined, { deps: !!flags.deps, locals: !!flags.locals, ...limitFlags(flags) })
+  warnDamaged(damaged)
+
+  const port = portArg ? parseInt(portArg, 10) : NaN
+  if (!flags.stdio && Number.isFinite(port)) {
+    await serveTcp(index, root, port)
+    log(`LSP server listening on 127.0.0.1:${port}`)
+    return
+  }
+
+  await serveStdio(index, root)
+  process.exit(0)
+}
+
+/**
+ * Serve the symbol index over gRPC, plus the HTTP/JSON API when asked:
+ * indexer serve [--port=50051] [--http-port=N] [--host=127.0.0.1] [--deps] [--rev=<rev>]
+ * With --watch, --webhook=<url>,... (or INDEXER_WEBHOOKS) POSTs a summary of
+ * every reindex, signed with INDEXER_WEBHOOK_SECRET when it is set.
+ */
+export async function handleServe(startCwd: string, args: string[], portArg: string | null = null) {
+  const { flags } = parseFlags(args)
+  const portFlag = (name: string) => (typeof flags[name] === 'string' ? parseInt(flags[name] as string, 10) : null)
+  const port = portArg ? parseInt(portArg, 10) : DEFAULT_GRPC_PORT
+  const httpPort = portFlag('http-port')
+  const metricsPort = portFlag('metrics-port')
+  if (!Number.isFinite(port) || [httpPort, metricsPort].some(p => p !== null && !Number.isFinite(p))) {
+    fail('Usage: indexer serve [--port=50051] [--http-port=N] [--metrics-port=N] [--pprof] [--workspace=<id>=<path>,...] [--watch [--webhook=<url>,...]] [--host=127.0.0.1]')
+  }
+  if (flags.watch && typeof flags.rev === 'string') fail('--watch serves the working tree and cannot be combined with --rev')
+  if (flags.watch && typeof flags.archive === 'string') fail('--watch serves the working tree and cannot be combined with --archive')
+  const webhooks = listFlag(flags.webhook) || listFlag(process.env.INDEXER_WEBHOOKS) || []
+  if (webhooks.length > 0 && !flags.watch) fail('--webhook reports reindexing and needs --watch')
+  const host = typeof flags.host === 'string' ? flags.host : '127.0.0.1'
+  let specs: WorkspaceSpec[] | null = null
+  try {
+    const values = listFlag(flags.workspace)
+    if (values) specs = parseWorkspaceSpecs(values)
+  } catch (e: any) {
+    fail(e.message)
+  }
+
+  // One workspace per --workspace root, or the project around the working directory
+  const roots: Array<{ id: string, root: string }> = []
+  for (const spec of specs || [{ id: '', path: startCwd }]) {
+    const root = await findProjectRoot(path.resolve(startCwd, spec.path))
+    roots.push({ id: spec.id || path.basename(root), root })
+  }
+  try {
+    checkWorkspaceRoots(roots)
+  } catch (e: any) {
+    fail(e.message)
+  }
+  const workspaces = new WorkspaceSet()
+  for (const { id, root } of roots) {
+    const { index, readSource } = await openIndex(startCwd, root, flags)
+    workspaces.add({ id, root, index, snippets: new SnippetCache(readSource) })
+  }
+  const metrics = new ServerMetrics(workspaces)
+  const ops = { metrics, pprof: !!flags.pprof }
+  const served = workspaces.list().map(w => `${workspaces.size > 1 ? `${w.id}: ` : ''}${w.index.listFiles().length} files`).join(', ')
+  await serveGrpc(workspaces, port, host, metrics)
+  log(`gRPC server listening on ${host}:${port} (${served})`)
+  if (httpPort !== null) {
+    await serveHttp(workspaces, httpPort, host, ops)
+    log(`HTTP API listening on http://${host}:${httpPort} (metrics at /metrics${ops.pprof ? ', profiles at /debug/pprof' : ''})`)
+  }
+  if (metricsPort !== null) {
+    await serveMetrics(metricsPort, host, ops)
+    log(`Metrics listening on http://${host}:${metricsPort}/metrics`)
+  }
+  if (flags.watch) {
+    for (const { id, root, index } of workspaces.list()) {
+      const notifier = webhooks.length > 0
+        ? new WebhookNotifier(index, { urls: webhooks, secret: process.env.INDEXER_WEBHOOK_SECRET })
+        : null
+      const label = workspaces.size > 1 ? ` in ${id}` : ''
+      const watcher = watchSymbolIndex(root, index, (event) => {
+        const { added, modified, removed } = event.update
+        log(`Reindexed ${added.length + modified.length + removed.length} files${label} in ${event.durationMs}ms`)
+        if (notifier) void notifier.notify(event)
+      }, limitFlags(flags))
+      metrics.watch(watcher, workspaces.size > 1 ? id : undefined)
+      checkpointOnShutdown(watcher, label)
+    }
+    if (webhooks.length > 0) log(`Posting index updates to ${webhooks.length} webhook${webhooks.length === 1 ? '' : 's'}${process.env.INDEXER_WEBHOOK_SECRET ? ' (signed)' : ''}`)
+  }
+}
+
+// Read-only commands the query daemon answers from its resident index
+const DAEMON_COMMANDS: Record<string, DaemonCommand> = {
+  query: handleQuery,
+  grep: handleGrep,
+  deadcode: handleDeadCode,
+  dupes: handleDupes,
+  api: handleApi,
+  apidiff: handleApiDiff,
+  todos: handleTodos,
+  diagnostics: handleDiagnostics,
+  strings: handleStrings,
+  imports: handleImports,
+  deprecations: handleDeprecations,
+  audit: handleAudit,
+  export: handleExport
+}
+const DAEMON_START_TIMEOUT_MS = 60_000
+// Flags naming files, sent to the daemon as absolute paths
+const PATH_FLAGS = ['archive', 'cache-from', 'output', 'allowlist'] as const
+
+/**
+ * Run a command in the project's query daemon when one is running and
+ * answers it (and --daemon=false is not given). Resolves the exit code, or
+ * null when the command should run in this process.
+ */
+export async function forwardToQueryDaemon(startCwd: string, command: string, args: string[]): Promise<number | null> {
+  const { flags } = parseFlags(args)
+  // The daemon holds the working tree, not index files
+  if (!Object.hasOwn(DAEMON_COMMANDS, command) || flags.daemon === 'false' || typeof flags.index === 'string') return null
+  const root = await findProjectRoot(startCwd)
+  return runInQueryDaemon(getQueryDaemonSocketPath(root), { command, args: resolvePathFlags(args, startCwd, PATH_FLAGS), cwd: startCwd })
+}
+
+/**
+ * Keep the project's symbol index in memory between CLI queries:
+ * indexer daemon start [--deps] [--locals] [--strings[=N]]
+ * indexer daemon stop|status [--json]
+ * indexer daemon run [...] runs it in the foreground. While a daemon runs,
+ * query, grep, deadcode, dupes, api, apidiff, todos, diagnostics, strings,
+ * imports, deprecations, audit and export are answered by it unless --daemon=false is
+ * given; commands asking for other passes than the daemon's load their own.
+ */
+export async function handleDaemon(startCwd: string, args: string[]) {
+  const { flags, positional } = parseFlags(args)
+  const action = positional[0]
+  const root = await findProjectRoot(startCwd)
+  const socketPath = getQueryDaemonSocketPath(root)
+
+  switch (action) {
+    case 'run': {
+      const options = { deps: !!flags.deps, locals: !!flags.locals, strings: stringsFlag(flags.strings) }
+      const limits = limitFlags(flags)
+      const { index, damaged } = await openSymbolIndex(root, undefined, { ...options, ...limits })
+      warnDamaged(damaged)
+      const watcher = watchSymbolIndex(root, index, (event) => {
+        const { added, modified, removed } = event.update
+        log(`Reindexed ${added.length + modified.length + removed.length} files in ${event.durationMs}ms`)
+      }, limits)
+      const daemon = new QueryDaemon({
+        socketPath,
+        resident: { root, index, ...options },
+        commands: DAEMON_COMMANDS,
+        onStop: async () => {
+          if (await watcher.shutdown()) log('Checkpointed the symbol index')
+          log('Query daemon stopped')
+          process.exit(0)
+        }
+      })
+      await daemon.listen().catch((e: Error) => fail(e.message))
+      checkpointOnShutdown(watcher)
+      log(`Query daemon listening on ${socketPath} (${index.listFiles().length} files)`)
+      return
+    }
+    case 'start': {
+      const running = await queryDaemonStatus(socketPath)
+      if (running) {
+        log(`Query daemon already running (pid ${running.pid})`)
+        return
+      }
+      const logPath = getQueryDaemonLogPath(root)
+      await fs.mkdir(path.dirname(logPath), { recursive: true })
+      const out = fsSync.openSync(logPath, 'a')
+      const child = spawn(process.execPath, [...process.execArgv, process.argv[1], 'daemon', 'run', ...args.filter(a => a !== action)], {
+        cwd: root,
+        detached: true,
+        stdio: ['ignore', out, out]
+      })
+      child.unref()
+      fsSync.closeSync(out)
+
+      const deadline = Date.now() + DAEMON_START_TIMEOUT_MS
+      let status: QueryDaemonStatus | null = null
+      while (!status && child.exitCode === null && Date.now() < deadline) {
+        await new Promise(resolve => setTimeout(resolve, 200))
+        status = await queryDaemonStatus(socketPath)
+      }
+      if (!status) fail(`Query daemon did not start; see ${logPath}`)
+      log(`Query daemon started (pid ${status.pid}, ${status.files} files)`)
+      return
+    }
+    case 'stop':
+      log(await stopQueryDaemon(socketPath) ? 'Query daemon stopped' : 'No query daemon is running')
+      return
+    case 'status': {
+      const status = await queryDaemonStatus(socketPath)
+      if (flags.json || flags.format === 'json') {
+        process.stdout.write(JSON.stringify(status, null, 2) + '\n')
+        return
+      }
+      if (!status) {
+        log('No query daemon is running')
+        return
+      }
+      const passes = [status.deps && 'deps', status.locals && 'locals', status.strings > 0 && `strings>=${status.strings}`].filter(Boolean)
+      log(`Query daemon running (pid ${status.pid}, up ${Math.round(status.uptime_ms / 1000)}s)`)
+      log(`  ${status.files} files, ${status.symbols} symbols${passes.length > 0 ? ` (${passes.join(', ')})` : ''}`)
+      log(`  ${status.requests} commands served on ${status.socket}`)
+      return
+    }
+    default:
+      fail('Usage: indexer daemon start|stop|status|run [--deps] [--locals] [--strings[=N]]')
+  }
 }
 
 export async function handleLogs() {
@@ -310,6 +2389,12 @@ export async function handleUninstall(startCwd: string) {
     warn(`Failed to delete snapshot: ${e.message}`)
   }
 
+  try {
+    await getSymbolStore(root).clear()
+  } catch (e: any) {
+    warn(`Failed to delete symbol index: ${e.message}`)
+  }
+
   await fs.rm(paths.dotDir, { recursive: true, force: true })
   await removeGitignoreEntry(root, '.indexer/')
   await removeRootMcpIndexer(paths.rootMcpPath)
@@ -0,0 +1,30 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { parseCommandLine, parseFlags, resolvePathFlags } from './cli-flags.js'
+
+test('cli-flags: flags split into values, switches and positional arguments', () => {
+  assert.deepEqual(parseFlags(['User', '--kind=class', '--json']), { flags: { kind: 'class', json: true }, positional: ['User'] })
+})
+
+test('cli-flags: the command line keeps --watch for the command while taking the global options out', () => {
+  const line = parseCommandLine(['serve', '--port', '50052', '--watch', '--webhook=http://ci/hook', '--project=/repo'])
+  assert.equal(line.command, 'serve')
+  assert.equal(line.port, '50052')
+  assert.equal(line.projectPath, '/repo')
+  assert.equal(line.watch, true)
+  assert.deepEqual(line.args, ['--watch', '--webhook=http://ci/hook'])
+  assert.equal(parseFlags(line.args).flags.watch, true)
+
+  assert.deepEqual(parseCommandLine(['--mcp-http', '--port=7000']), { command: null, projectPath: null, mcpHttp: true, port: '7000', watch: false, args: [] })
+})
+
+test('cli-flags: path flags are made absolute against the working directory', () => {
+  const args = ['--archive=../snap.tar.gz', '--output=-', '--kind=class', 'src/user.ts', '--cache-from=/ci/prev.idx']
+  assert.deepEqual(resolvePathFlags(args, '/repo/packages/api', ['archive', 'cache-from', 'output']), [
+    '--archive=/repo/packages/snap.tar.gz',
+    '--output=-',
+    '--kind=class',
+    'src/user.ts',
+    '--cache-from=/ci/prev.idx'
+  ])
+})
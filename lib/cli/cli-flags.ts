@@ -0,0 +1,79 @@
+import path from 'path'
+
+export interface ParsedFlags {
+  flags: Record<string, string | boolean>
+  positional: string[]
+}
+
+/**
+ * Split command arguments into --key=value / --key flags and positional args
+ */
+export function parseFlags(args: string[]): ParsedFlags {
+  const flags: Record<string, string | boolean> = {}
+  const positional: string[] = []
+  for (const arg of args) {
+    if (!arg.startsWith('--')) {
+      positional.push(arg)
+      continue
+    }
+    const eq = arg.indexOf('=')
+    if (eq === -1) {
+      flags[arg.slice(2)] = true
+    } else {
+      flags[arg.slice(2, eq)] = arg.slice(eq + 1)
+    }
+  }
+  return { flags, positional }
+}
+
+/**
+ * Arguments with the named --key=<path> flags made absolute against cwd, so
+ * a process with another working directory reads the same files; - (stdout)
+ * is left alone
+ */
+export function resolvePathFlags(args: string[], cwd: string, names: readonly string[]): string[] {
+  return args.map(arg => {
+    const eq = arg.indexOf('=')
+    if (!arg.startsWith('--') || eq === -1 || !names.includes(arg.slice(2, eq))) return arg
+    const value = arg.slice(eq + 1)
+    return value === '' || value === '-' ? arg : `${arg.slice(0, eq)}=${path.resolve(cwd, value)}`
+  })
+}
+
+export interface CommandLine {
+  command: string | null
+  projectPath: string | null // --project=<path>
+  mcpHttp: boolean // --mcp-http
+  port: string | null // --port N or --port=N
+  watch: boolean // --watch, which also stays in args for the commands that read it
+  args: string[] // arguments after the command
+}
+
+/**
+ * Split the indexer's arguments into the command, the options every command
+ * takes and the command's own arguments
+ */
+export function parseCommandLine(argv: string[]): CommandLine {
+  const line: CommandLine = { command: null, projectPath: null, mcpHttp: false, port: null, watch: false, args: [] }
+  for (let i = 0; i < argv.length; i++) {
+    const arg = argv[i]
+    if (arg.startsWith('--project=')) {
+      line.projectPath = arg.split('=')[1]
+    } else if (arg === '--mcp-http') {
+      line.mcpHttp = true
+    } else if (arg === '--watch') {
+      line.watch = true
+      line.args.push(arg)
+    } else if (arg === '--port' && i + 1 < argv.length) {
+      line.port = argv[i + 1]
+      i++ // Skip the next argument as it's the port value
+    } else if (arg.startsWith('--port=')) {
+      line.port = arg.split('=')[1]
+    } else if (!line.command) {
+      line.command = arg
+    } else {
+      line.args.push(arg)
+    }
+  }
+  return line
+}
@@ -4,6 +4,7 @@ import {stdin as input, stdout as output} from "node:process"
 import fs, {writeSync} from "fs"
 import path from "path"
 import {fileURLToPath} from "url"
+import {CommandExit, commandContext} from "./command-context.js"
 
 const __filename = fileURLToPath(import.meta.url)
 const __dirname = path.dirname(__filename)
@@ -44,12 +45,42 @@ export function warn(msg: string): void {
 }
 
 export function fail(msg: string, code: number = 1): never {
+  // Inside the query daemon only the command ends, not the daemon
+  if (commandContext.getStore()) {
+    console.error(`[indexer] ${msg}`)
+    throw new CommandExit(code)
+  }
   restoreTerminal()
   console.error(`[indexer] ${msg}`)
   process.exitCode = code
   process.exit(code)
 }
 
+export function printTable(headers: string[], rows: string[][]): void {
+  const widths = headers.map((h, i) => Math.max(h.length, ...rows.map(r => (r[i] || "").length)))
+  const format = (cells: string[]) => cells.map((c, i) => (c || "").padEnd(widths[i])).join("  ").trimEnd()
+  console.log(format(headers))
+  for (const row of rows) {
+    console.log(format(row))
+  }
+}
+
+// Results each followed by the source around them, as grep -C prints it:
+// ":" after the result's line number, "-" after the others, "--" between results
+export function printSnippets(results: {title: string, line: number, snippet: {start_line: number, lines: string[]} | null}[]): void {
+  results.forEach((result, i) => {
+    if (i > 0) console.log("--")
+    console.log(result.title)
+    const snippet = result.snippet
+    if (!snippet) return
+    const width = String(snippet.start_line + snippet.lines.length - 1).length
+    snippet.lines.forEach((text, j) => {
+      const line = snippet.start_line + j
+      console.log(`${String(line).padStart(width)}${line === result.line ? ":" : "-"} ${text}`)
+    })
+  })
+}
+
 export async function confirmAction(question: string): Promise<boolean> {
   const rl = createInterface({input, output})
   const answer = await rl.question(question + " (y/N): ")
@@ -186,6 +217,124 @@ export function printHelp(): void {
     `  indexer init         # install indexer
  ` +
     `  indexer clean        # drop & reindex current project (alias: clear)
+ ` +
+    `  indexer index --watch # reindex, then keep the index hot while editing
+ ` +
+    `  indexer build [--rev=HEAD~3] [--base=<rev>] [--cache-from=old.idx] [--rebuild] # index the tree at a git revision, stored by commit
+ ` +
+    `  indexer export --format=lsif|scip|proto|ctags|etags|dot|imports-dot|imports-json [--output=<file>|-] [--generated=false] # export symbol index / call graph / import graph
+ ` +
+    `  indexer export --format=docs [--package=./lib/...] [--output=docs.json] # package documentation for a docs site generator
+ ` +
+    `  indexer export --format=jsonl [--output=<file>] [--references=false] # stream symbol and reference records while indexing, one JSON object per line
+ ` +
+    `  indexer query [--kind=interface] [--exported] [--package=./lib/...] [--name=User*] [--generated=false] [--deprecated] [--fuzzy=usrdw] [--callers=fn] [--sort=refs|packages] [--json] # query symbols
+ ` +
+    `  indexer query --members=User [--json] # methods and fields of a type, including inherited ones
+ ` +
+    `  indexer query --values=Status [--json] # values of an enum or literal union type, with the constants declared as it
+ ` +
+    `  indexer query --tag=json:user_id [--json] # fields by serialization / validation tag (decorators, C# attributes)
+ ` +
+    `  indexer query --fact=sqlcheck.raw_query=true [--json] # symbols by a fact a custom analyzer recorded (analyzer: in .indexer/to-index)
+ ` +
+    `  indexer query --sig='func(context.Context, string) (User, error)' [--sig-mode=unordered,assignable] # functions by parameter and result types
+ ` +
+    `  indexer query --exported --owner=@org/platform-team [--blame] [--json] # symbols owned by a team (CODEOWNERS), optionally with git blame authors
+ ` +
+    `  indexer query --tests-for=User.save [--json] # tests, benchmarks and examples that exercise a symbol
+ ` +
+    `  indexer query --throw-path=User.save [--json] # how a function can throw: the errors that escape it and the call chain to a throw
+ ` +
+    `  indexer query --landmark=goto,'label>2' [--json] # functions by control-flow landmarks they contain, optionally how many
+ ` +
+    `  indexer query --supertypes=User|--subtypes=Store [--transitive] [--scope=module|workspace|all] [--json] # type hierarchy of a class or interface
+ ` +
+    `  indexer query --at=src/main.ts:12:7 [--json] # symbol under a cursor position
+ ` +
+    `  indexer query --where='kind = "method" AND refs.count = 0' [--scope=module|workspace|all] [--json] # filter symbols with a query expression
+ ` +
+    `  indexer grep <regex> [--ignore-case] [--limit=N] [--json] # trigram-accelerated search in indexed sources
+ ` +
+    `  indexer deadcode [--exported[=false]] [--kind=a,b] [--allow=glob,...] [--json] # symbols nothing references
+ ` +
+    `  indexer dupes [--min-size=50] [--similarity=0.8] [--exact] [--package=./lib/...] [--json] # duplicated and near-duplicate function bodies
+ ` +
+    `  indexer api [--package=./lib/...] [--output=api.json] # manifest of the exported API
+ ` +
+    `  indexer apidiff <old-rev|old.json> [<new-rev|new.json>] [--json] # added, removed and changed exported symbols, breaking ones flagged
+ ` +
+    `  indexer todos [--marker=TODO,FIXME] [--package=./lib/...] [--owner=@team] [--blame] [--json] # TODO / FIXME / HACK / BUG comments with their symbol
+ ` +
+    `  indexer diagnostics [--package=./lib/...] [--lang=<lang>] [--analyzer=syntax,eslint] [--rule=<rule>] [--json] # syntax errors and analyzer findings (lint results)
+ ` +
+    `  indexer strings [text] [--regex] [--kind=string,template,regexp] [--min-length=N] [--json] # string, template and regexp literals with their symbol
+ ` +
+    `  indexer landmarks [<fn>] [--kind=label,goto,break,continue,catch,finally] [--label=<name>] [--json] # labels, jumps and handlers in function bodies, with where each jump goes
+ ` +
+    `  indexer history [--json] # recorded generations of the symbol index (history: in .indexer/to-index)
+ ` +
+    `  indexer history diff <from> [<to>] [--moves] [--json] # symbols added, removed, moved and renamed between two generations (or a generation and now)
+ ` +
+    `  indexer deprecations [--unused] [--json] # every use of a deprecated symbol
+ ` +
+    `  indexer audit [--package=./lib/...] [--category=exec,dynamic_code,weak_crypto,insecure_transport,sql_concat] [--depth=N] [--json] # calls into security-sensitive APIs per package, with their callers
+ ` +
+    `  indexer impacted --symbol=<name> [--depth=N] [--imports=false] [--format=text|json|go-test-args|test-files] # packages and tests a change to a symbol could break
+ ` +
+    `  indexer stats [--package=./lib/...] [--format=text|json|csv] # per-package metrics: symbols by kind, exported share, function length, fan-in/fan-out, exports covered by tests
+ ` +
+    `  indexer imports <package> [--imported-by] [--external] [--json] # packages a source directory imports, or its importers
+ ` +
+    `  indexer imports --cycles [--json] # import cycles between internal packages
+ ` +
+    `  indexer convert-index --to=sqlite|json|pack|sharded [--from=...] # copy the stored symbol index between formats
+ ` +
+    `  indexer export --format=idx [--output=<name>.idx] [--cache-from=old.idx] # write the index as an index file, for indexer merge, --index=<file> and --cache-from
+ ` +
+    `  indexer merge [<name>=]a.idx [<name>=]b.idx ... -o combined.idx # union repository indexes into one, queried with --index=combined.idx
+ ` +
+    `  indexer verify [--repair] [--json] # check stored symbol index shards against their checksums
+ ` +
+    `  indexer migrate [--index=<file.idx>] [--json] # upgrade an index written by an older indexer to the current format in place
+ ` +
+    `  indexer push [--rev=HEAD] [--registry=<url>] # build the index of a commit and upload it to a registry
+ ` +
+    `  indexer pull [--rev=HEAD] [--registry=<url>] # download a prebuilt commit index from a registry
+ ` +
+    `  indexer lsp [--port=N] # serve the symbol index over LSP (stdio, or TCP with --port)
+ ` +
+    `  indexer serve [--port=50051] [--http-port=N] [--metrics-port=N] [--pprof] [--workspace=<id>=<path>,...] [--watch [--webhook=<url>]] [--host=127.0.0.1] # serve the symbol index over gRPC (+ HTTP/JSON, Prometheus metrics)
+ ` +
+    `  indexer daemon start|stop|status [--deps] [--locals] [--strings[=N]] # keep the symbol index in memory for faster queries (--daemon=false bypasses it)
+ ` +
+    `  --deps               # (index/query/grep/deadcode/dupes/todos/strings/deprecations/imports/export/lsp/serve) also index node_modules dependencies
+ ` +
+    `  --locals             # (same commands as --deps) also index parameters and local variables with their scopes
+ ` +
+    `  --strings[=N]        # (same commands as --deps) also index string literals of at least N characters (default 8)
+ ` +
+    `  --concurrency=N      # (same commands as --deps, plus daemon) parse at most N files at once (INDEXER_CONCURRENCY; CPU count by default)
+ ` +
+    `  --io-rate=<rate>     # (same commands as --concurrency) read at most this many bytes per second, such as 20MB (INDEXER_IO_RATE; unlimited by default)
+ ` +
+    `  --rev=<rev>          # (query/grep/deadcode/dupes/api/todos/deprecations/audit/impacted/stats/imports/export/serve) use the index of a git revision instead of the working tree
+ ` +
+    `  --archive=<file>     # (same commands as --rev) index a zip, tar or .tar.gz archive (an npm pack, a PyPI sdist) in memory instead of the working tree
+ ` +
+    `  --generation=<n>     # (same commands as --rev) query a recorded generation of the index instead of the working tree (see indexer history)
+ ` +
+    `  --as-of=<time>       # (same commands as --rev) query the newest generation recorded at or before an ISO date or a time ago, such as 7d
+ ` +
+    `  --precision=fast|full # (same commands as --rev) full binds JS/TS references with the TypeScript type checker instead of by name
+ ` +
+    `  --tsconfig=<file>    # (with --precision=full) TypeScript project file to check (INDEXER_TSCONFIG; tsconfig.json by default)
+ ` +
+    `  --dispatch=name|cha|rta|vta # (same commands as --rev) how calls through receivers resolve in the call graph: every method of the name, class hierarchy, instantiated types, or values assigned to the receiver
+ ` +
+    `  --context=N          # (query/grep) print N lines of source on either side of each result (up to 50; "context" in JSON); HTTP takes ?context=N
+ ` +
+    `  --redact=<classes>   # (export/push) strip strings, comments and/or contents (or all) from the index written; redact: in .indexer/to-index sets the default
  ` +
     `  indexer status       # show status
  ` +
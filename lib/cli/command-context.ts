@@ -0,0 +1,36 @@
+/**
+ * Command Context Module
+ * State of a CLI command running inside the query daemon: where its output
+ * goes and the resident index that answers it. The context follows the
+ * command through its async calls, so concurrent work in the daemon (the
+ * file watcher, other clients) keeps writing to the daemon's own streams.
+ * Outside the daemon there is no context and commands behave as usual.
+ */
+
+import { AsyncLocalStorage } from 'async_hooks'
+import type { SymbolIndex } from '../core/symbol-index.js'
+
+export interface ResidentIndex {
+  root: string
+  index: SymbolIndex // the working tree, kept current by the daemon's watcher
+  deps: boolean
+  locals: boolean
+  strings: number
+}
+
+export interface CommandContext {
+  write: (stream: 'stdout' | 'stderr', data: string) => void
+  resident: ResidentIndex
+}
+
+export const commandContext = new AsyncLocalStorage<CommandContext>()
+
+/**
+ * Thrown by fail() inside the daemon, where exiting would take the daemon
+ * down with the command
+ */
+export class CommandExit extends Error {
+  constructor(readonly code: number) {
+    super(`Command exited with code ${code}`)
+  }
+}
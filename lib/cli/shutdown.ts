@@ -0,0 +1,43 @@
+/**
+ * Shutdown Module
+ * Work a long-running command (watch, serve, the query daemon) finishes on
+ * SIGTERM or SIGINT before the process exits, such as applying pending
+ * index updates and checkpointing the index. Hooks run one after another;
+ * a second signal, or hooks outliving the timeout, exits at once.
+ */
+
+import { warn } from './cli-ui.js'
+
+const SHUTDOWN_TIMEOUT_MS = Number(process.env.INDEXER_SHUTDOWN_TIMEOUT_MS) || 30_000
+
+const hooks: (() => Promise<void>)[] = []
+let shuttingDown = false
+
+/**
+ * Run a hook when the process is asked to stop
+ */
+export function onShutdown(hook: () => Promise<void>): void {
+  hooks.push(hook)
+}
+
+/**
+ * Run the shutdown hooks, then exit with the code; at once if already
+ * shutting down or no hook is registered
+ */
+export async function shutdown(code: number): Promise<never> {
+  if (shuttingDown || hooks.length === 0) process.exit(code)
+  shuttingDown = true
+  const timer = setTimeout(() => {
+    warn(`Shutdown took over ${SHUTDOWN_TIMEOUT_MS}ms; exiting without finishing it`)
+    process.exit(code)
+  }, SHUTDOWN_TIMEOUT_MS)
+  timer.unref()
+  for (const hook of hooks.splice(0)) {
+    try {
+      await hook()
+    } catch (e: any) {
+      warn(`Shutdown step failed: ${e.message}`)
+    }
+  }
+  process.exit(code)
+}
@@ -0,0 +1,150 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { analyzePackages, analyzerKey, loadAnalyzers, type Analyzer } from './analyzers.js'
+import { querySymbols } from './symbol-query.js'
+import { selectSymbols } from './query-language.js'
+import { findParseDiagnostics } from './parse-diagnostics.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const SOURCES: Record<string, string> = {
+  'src/db/users.ts': 'export function findUser(id) {\n  return db.query(`SELECT * FROM users WHERE id = ${id}`)\n}\n',
+  'src/db/teams.ts': 'export function findTeam(id) {\n  return db.query("SELECT * FROM teams WHERE id = ?", [id])\n}\n',
+  'src/app.ts': 'export function main() {}\n'
+}
+
+const FILES: FixtureFiles = {
+  'src/db/users.ts': [{ name: 'findUser', kind: 'function', line: 1, end_line: 3, column: 17, exported: true }],
+  'src/db/teams.ts': [{ name: 'findTeam', kind: 'function', line: 1, end_line: 3, column: 17, exported: true }],
+  'src/app.ts': [{ name: 'main', kind: 'function', line: 1, end_line: 1, column: 17, exported: true }]
+}
+
+const sqlcheck: Analyzer = {
+  name: 'sqlcheck',
+  analyze: (pkg) => pkg.files.flatMap(file => file.symbols.map(sym => ({
+    symbol: sym.id,
+    name: 'raw_query',
+    value: /query\(`/.test(file.content)
+  })))
+}
+
+const readSource = async (relPath: string) => SOURCES[relPath] ?? null
+
+test('analyzers: facts are stored on the symbols of analyzed packages', async () => {
+  const index = createIndex(FILES)
+  const packages: string[] = []
+  index.analyzers = [sqlcheck, {
+    name: 'size',
+    languages: ['python'],
+    analyze: (pkg) => {
+      packages.push(pkg.dir)
+      return []
+    }
+  }]
+  const before = index.getFile('src/db/users.ts')!
+  const snapshot = index.snapshot()
+
+  const replaced = await index.write(() => analyzePackages(index, ['src/db/users.ts'], readSource))
+  assert.deepEqual(replaced.sort(), ['src/db/teams.ts', 'src/db/users.ts'])
+  assert.deepEqual(index.getSymbol('src/db/users.ts#findUser')!.facts, { sqlcheck: { raw_query: true } })
+  assert.deepEqual(index.getSymbol('src/db/teams.ts#findTeam')!.facts, { sqlcheck: { raw_query: false } })
+  assert.equal(index.getSymbol('src/app.ts#main')!.facts, undefined)
+  // Files outside an analyzer's languages are not passed to it
+  assert.deepEqual(packages, [])
+  // Shards are replaced, not changed under earlier snapshots
+  assert.equal(before.symbols[0].facts, undefined)
+  assert.equal(snapshot.getSymbol('src/db/users.ts#findUser')!.facts, undefined)
+
+  assert.deepEqual(querySymbols(index, { facts: ['sqlcheck.raw_query=true'] }).map(s => s.name), ['findUser'])
+  assert.deepEqual(querySymbols(index, { facts: ['sqlcheck'] }).map(s => s.name), ['findTeam', 'findUser'])
+  assert.deepEqual(selectSymbols(index, 'facts.sqlcheck.raw_query = true').map(s => s.name), ['findUser'])
+
+  // Facts are recomputed, not merged, and failures leave the package without them
+  index.analyzers = [{ name: 'sqlcheck', analyze: () => { throw new Error('boom') } }]
+  const errors: string[] = []
+  const error = console.error
+  console.error = (message: string) => errors.push(message)
+  try {
+    await index.write(() => analyzePackages(index, ['src/db/teams.ts'], readSource))
+  } finally {
+    console.error = error
+  }
+  assert.deepEqual(errors, ['Analyzer sqlcheck failed on src/db: boom'])
+  assert.equal(index.getSymbol('src/db/users.ts#findUser')!.facts, undefined)
+})
+
+test('analyzers: diagnostics are stored beside syntax errors', async () => {
+  const index = createIndex(FILES)
+  const users = index.getFile('src/db/users.ts')!
+  index.addShard({ ...users, diagnostics: [{ message: 'Unexpected token', line: 3, column: 2 }] })
+  index.analyzers = [{
+    name: 'sqlcheck',
+    analyze: (pkg) => ({
+      diagnostics: pkg.files.filter(f => /query\(`/.test(f.content)).flatMap(f => [
+        { path: f.path, line: 2, column: 3, message: 'query built from a template string', rule: 'raw-query', severity: 'warning' as const },
+        { path: 'src/app.ts', line: 1, message: 'outside the package' }
+      ])
+    })
+  }]
+
+  await index.write(() => analyzePackages(index, ['src/db/users.ts'], readSource))
+  assert.deepEqual(index.getFile('src/db/users.ts')!.diagnostics, [
+    { message: 'Unexpected token', line: 3, column: 2 },
+    { message: 'query built from a template string', line: 2, column: 3, analyzer: 'sqlcheck', rule: 'raw-query', severity: 'warning' }
+  ])
+  assert.equal(index.getFile('src/db/teams.ts')!.diagnostics, undefined)
+  assert.equal(index.getFile('src/app.ts')!.diagnostics, undefined)
+
+  assert.deepEqual(findParseDiagnostics(index, { analyzers: ['syntax'] }).map(d => d.message), ['Unexpected token'])
+  assert.deepEqual(findParseDiagnostics(index, { analyzers: ['sqlcheck'] }).map(d => d.line), [2])
+  assert.deepEqual(findParseDiagnostics(index, { rules: ['raw-query'], packages: ['./src/db/...'] }).map(d => d.path), ['src/db/users.ts'])
+  assert.deepEqual(findParseDiagnostics(index, { rules: ['other'] }), [])
+
+  // Findings are replaced on the next run; syntax errors stay until the file is parsed again
+  index.analyzers = [{ name: 'sqlcheck', analyze: () => [] }]
+  await index.write(() => analyzePackages(index, ['src/db/users.ts'], readSource))
+  assert.deepEqual(index.getFile('src/db/users.ts')!.diagnostics, [{ message: 'Unexpected token', line: 3, column: 2 }])
+})
+
+test('analyzers: modules and commands from analyzer: entries', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'analyzers-test-'))
+  try {
+    await fs.writeFile(path.join(root, 'owners.mjs'), [
+      'export default {',
+      '  name: "owners",',
+      '  version: "2",',
+      '  analyze: (pkg) => pkg.files.flatMap(f => f.symbols.map(s => ({ symbol: s.id, name: "dir", value: pkg.dir })))',
+      '}'
+    ].join('\n'))
+    const script = [
+      'let input = ""',
+      'process.stdin.on("data", d => input += d).on("end", () => {',
+      '  const { protocol, package: pkg } = JSON.parse(input)',
+      '  const facts = pkg.files.flatMap(f => f.symbols.map(s => ({ symbol: s.id, name: "lines", value: f.content.split("\\n").length - 1 })))',
+      '  const diagnostics = pkg.files.map(f => ({ path: f.path, line: 1, message: "checked", rule: "lines", severity: "info" }))',
+      '  process.stdout.write(JSON.stringify({ protocol, facts, diagnostics }))',
+      '})'
+    ].join('\n')
+    await fs.writeFile(path.join(root, 'lines.cjs'), script)
+
+    const analyzers = await loadAnalyzers(root, ['./owners.mjs', `lines = "${process.execPath}" lines.cjs`])
+    assert.deepEqual(analyzers.map(a => a.name), ['owners', 'lines'])
+    assert.equal(analyzerKey(analyzers), `owners@2,lines@"${process.execPath}" lines.cjs`)
+
+    const index = createIndex(FILES)
+    index.analyzers = analyzers
+    await index.write(() => analyzePackages(index, ['src/app.ts'], readSource))
+    assert.deepEqual(index.getSymbol('src/app.ts#main')!.facts, { owners: { dir: 'src' }, lines: { lines: 1 } })
+    assert.equal(index.getSymbol('src/db/users.ts#findUser')!.facts, undefined)
+    assert.deepEqual(index.getFile('src/app.ts')!.diagnostics, [
+      { message: 'checked', line: 1, column: 1, analyzer: 'lines', rule: 'lines', severity: 'info' }
+    ])
+
+    await assert.rejects(loadAnalyzers(root, ['./missing.mjs']), /Cannot load analyzer \.\/missing\.mjs/)
+    await assert.rejects(loadAnalyzers(root, ['./owners.mjs', './owners.mjs']), /Two analyzers are named owners/)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,270 @@
+/**
+ * Analyzers Module
+ * Custom analysis passes the index runs over each package (directory) as it
+ * is indexed, in the manner of Go's analysis framework. An analyzer receives
+ * a package's parsed files (their symbols, references and source) and
+ * returns facts about its symbols, which are stored with them as
+ * facts.<analyzer>.<fact> and queried like any other field (--fact,
+ * --where='facts.sqlcheck.raw_query = true'), and diagnostics, which are
+ * stored with the file next to its syntax errors (indexer diagnostics
+ * --analyzer=sqlcheck). Analyzers are JavaScript modules exporting an
+ * Analyzer, commands that read a package as JSON on stdin and write
+ * {"facts": [...], "diagnostics": [...]} to stdout, or the built-in ESLint
+ * adapter (see eslint-analyzer) and security pass (see security-audit),
+ * listed in .indexer/to-index:
+ *
+ *   analyzer: ./tools/sqlcheck.js
+ *   analyzer: complexity = python3 tools/complexity.py
+ *   analyzer: eslint
+ *   analyzer: security
+ *
+ * A package is analyzed again whenever one of its files changes, and every
+ * file when the list of analyzers does (it is part of the content hash).
+ */
+
+import path from 'path'
+import { exec } from 'child_process'
+import { pathToFileURL } from 'url'
+import { eslintAnalyzer } from './eslint-analyzer.js'
+import { securityAnalyzer } from './security-audit.js'
+import { packageOf } from './import-graph.js'
+import { LIMITS_ANALYZER } from './file-limits.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { DiagnosticSeverity, FileShard, IndexedSymbol, ParseDiagnostic, SymbolReference } from '../types/index.js'
+
+// Version of the JSON exchanged with command analyzers
+export const ANALYZER_PROTOCOL_VERSION = 1
+
+export type FactValue = string | number | boolean | null | FactValue[] | { [key: string]: FactValue }
+
+export interface AnalyzerFile {
+  path: string
+  lang: string
+  content: string
+  symbols: IndexedSymbol[]
+  references: SymbolReference[]
+}
+
+export interface AnalyzerPackage {
+  dir: string // directory relative to the project root, '.' for the root
+  module?: string // package the directory belongs to (package.json, build package)
+  files: AnalyzerFile[]
+}
+
+export interface AnalyzerFact {
+  symbol: string // symbol ID; facts about symbols outside the package are dropped
+  name: string
+  value: FactValue
+}
+
+export interface AnalyzerDiagnostic {
+  path: string // a file of the package; diagnostics for other files are dropped
+  line: number
+  column?: number // 1-based, 1 by default
+  message: string
+  rule?: string
+  severity?: DiagnosticSeverity // error by default
+}
+
+// Facts alone, or facts and diagnostics
+export type AnalyzerResult = AnalyzerFact[] | { facts?: AnalyzerFact[], diagnostics?: AnalyzerDiagnostic[] }
+
+export interface Analyzer {
+  name: string // fact namespace: facts.<name>.<fact>
+  version?: string // part of the content hash, so a new version re-analyzes everything
+  languages?: string[] // languages of the files it is given (all by default)
+  analyze(pkg: AnalyzerPackage): AnalyzerResult | Promise<AnalyzerResult>
+}
+
+const SEVERITIES = new Set<string>(['error', 'warning', 'info'])
+
+const COMMAND_ENTRY = /^([\w-]+)\s*=\s*(.+)$/
+
+/**
+ * Analyzer that runs a command once per package, sending
+ * {"protocol": 1, "package": {...}} on stdin and reading
+ * {"facts": [...], "diagnostics": [...]} from stdout
+ */
+export function commandAnalyzer(name: string, command: string, cwd: string): Analyzer {
+  return {
+    name,
+    version: command,
+    analyze: (pkg) => new Promise((resolve, reject) => {
+      const child = exec(command, { cwd, maxBuffer: 256 * 1024 * 1024 }, (err, stdout, stderr) => {
+        if (err) {
+          reject(new Error(stderr.trim() || err.message))
+          return
+        }
+        let reply: any
+        try {
+          reply = JSON.parse(stdout)
+        } catch {
+          reject(new Error('output is not JSON'))
+          return
+        }
+        resolve({
+          facts: Array.isArray(reply?.facts) ? reply.facts : [],
+          diagnostics: Array.isArray(reply?.diagnostics) ? reply.diagnostics : []
+        })
+      })
+      child.stdin?.on('error', () => {}) // the command may exit without reading its input
+      child.stdin?.end(JSON.stringify({ protocol: ANALYZER_PROTOCOL_VERSION, package: pkg }))
+    })
+  }
+}
+
+function isAnalyzer(value: any): value is Analyzer {
+  return !!value && typeof value.name === 'string' && typeof value.analyze === 'function'
+}
+
+/**
+ * Load the analyzers named by analyzer: entries: eslint, security, a module
+ * (path relative to the project root, or a package name) or name = command
+ */
+export async function loadAnalyzers(projectRoot: string, entries: string[]): Promise<Analyzer[]> {
+  const analyzers: Analyzer[] = []
+  for (const entry of entries) {
+    const command = entry.match(COMMAND_ENTRY)
+    if (command) {
+      analyzers.push(commandAnalyzer(command[1], command[2], projectRoot))
+      continue
+    }
+    if (entry === 'eslint') {
+      analyzers.push(await eslintAnalyzer(projectRoot))
+      continue
+    }
+    if (entry === 'security') {
+      analyzers.push(securityAnalyzer())
+      continue
+    }
+    const specifier = entry.startsWith('.') || path.isAbsolute(entry)
+      ? pathToFileURL(path.resolve(projectRoot, entry)).href
+      : entry
+    let loaded: any
+    try {
+      loaded = await import(specifier)
+    } catch (e) {
+      throw new Error(`Cannot load analyzer ${entry}: ${(e as Error).message}`)
+    }
+    const exported = [loaded.analyzer, loaded.default, ...(Array.isArray(loaded.analyzers) ? loaded.analyzers : [])].filter(isAnalyzer)
+    if (exported.length === 0) throw new Error(`Analyzer ${entry} exports no analyzer (an object with name and analyze)`)
+    analyzers.push(...new Set(exported))
+  }
+  const names = new Set<string>()
+  for (const analyzer of analyzers) {
+    if (names.has(analyzer.name)) throw new Error(`Two analyzers are named ${analyzer.name}`)
+    names.add(analyzer.name)
+  }
+  return analyzers
+}
+
+/**
+ * Identity of a set of analyzers, '' for none; folded into content hashes
+ */
+export function analyzerKey(analyzers: Analyzer[]): string {
+  return analyzers.map(a => `${a.name}@${a.version || ''}`).join(',')
+}
+
+/**
+ * Package input for an analyzer: the package's files in its languages
+ */
+async function packageInput(
+  index: SymbolIndex,
+  dir: string,
+  shards: FileShard[],
+  analyzer: Analyzer,
+  readSource: (relPath: string) => Promise<string | null>
+): Promise<AnalyzerPackage | null> {
+  const files: AnalyzerFile[] = []
+  for (const shard of shards) {
+    if (analyzer.languages && !analyzer.languages.includes(shard.lang)) continue
+    const content = await readSource(shard.path)
+    if (content === null) continue
+    files.push({ path: shard.path, lang: shard.lang, content, symbols: shard.symbols, references: shard.references })
+  }
+  if (files.length === 0) return null
+  const module = index.moduleOf ? index.moduleOf(files[0].path) : null
+  return { dir, ...(module ? { module: module.name } : {}), files }
+}
+
+/**
+ * Run the index's analyzers over the packages of some files and store their
+ * facts, replacing the packages' shards. An analyzer that fails on a package
+ * is reported and leaves that package without its facts.
+ * @param index - Index to update, inside a write() batch
+ * @param relPaths - Changed files; their whole packages are analyzed
+ * @param readSource - Content of an indexed file
+ * @returns Paths of the shards replaced
+ */
+export async function analyzePackages(
+  index: SymbolIndex,
+  relPaths: string[],
+  readSource: (relPath: string) => Promise<string | null>
+): Promise<string[]> {
+  if (index.analyzers.length === 0 || relPaths.length === 0) return []
+  const sources = new Map<string, Promise<string | null>>()
+  const read = (relPath: string) => {
+    if (!sources.has(relPath)) sources.set(relPath, readSource(relPath))
+    return sources.get(relPath)!
+  }
+  const dirs = new Set(relPaths.map(packageOf))
+  const byDir = new Map<string, FileShard[]>()
+  for (const shard of index.listShards()) {
+    const dir = packageOf(shard.path)
+    if (!dirs.has(dir)) continue
+    const shards = byDir.get(dir)
+    if (shards) shards.push(shard)
+    else byDir.set(dir, [shard])
+  }
+
+  const replaced: string[] = []
+  for (const [dir, shards] of byDir) {
+    const ids = new Set(shards.flatMap(s => s.symbols.map(sym => sym.id)))
+    const facts = new Map<string, Record<string, Record<string, FactValue>>>()
+    const diagnostics = new Map<string, ParseDiagnostic[]>(shards.map(s => [s.path, []]))
+    for (const analyzer of index.analyzers) {
+      const input = await packageInput(index, dir, shards, analyzer, read)
+      if (!input) continue
+      let result: AnalyzerResult
+      try {
+        result = await analyzer.analyze(input)
+      } catch (e) {
+        console.error(`Analyzer ${analyzer.name} failed on ${dir}: ${(e as Error).message}`)
+        continue
+      }
+      const found = Array.isArray(result) ? result : result?.facts
+      for (const diagnostic of (Array.isArray(result) ? [] : result?.diagnostics) || []) {
+        const ofFile = diagnostic && diagnostics.get(diagnostic.path)
+        if (!ofFile || typeof diagnostic.message !== 'string') continue
+        ofFile.push({
+          message: diagnostic.message,
+          line: diagnostic.line || 1,
+          column: diagnostic.column || 1,
+          analyzer: analyzer.name,
+          ...(diagnostic.rule ? { rule: diagnostic.rule } : {}),
+          ...(diagnostic.severity && SEVERITIES.has(diagnostic.severity) ? { severity: diagnostic.severity } : {})
+        })
+      }
+      for (const fact of found || []) {
+        if (!fact || !ids.has(fact.symbol) || typeof fact.name !== 'string') continue
+        const ofSymbol = facts.get(fact.symbol) || {}
+        ;(ofSymbol[analyzer.name] ||= {})[fact.name] = fact.value ?? null
+        facts.set(fact.symbol, ofSymbol)
+      }
+    }
+    for (const shard of shards) {
+      // Shards are replaced, never changed in place: snapshots may hold them
+      const symbols = shard.symbols.map(sym => {
+        const { facts: _stale, ...rest } = sym
+        const found = facts.get(sym.id)
+        return (found ? { ...rest, facts: found } : rest) as IndexedSymbol
+      })
+      // Syntax errors and limit notes stay; analyzer diagnostics are the ones just reported
+      const kept = [...(shard.diagnostics || []).filter(d => !d.analyzer || d.analyzer === LIMITS_ANALYZER), ...diagnostics.get(shard.path)!]
+      const { diagnostics: _previous, ...rest } = shard
+      index.addShard({ ...rest, symbols, ...(kept.length > 0 ? { diagnostics: kept } : {}) })
+      replaced.push(shard.path)
+    }
+  }
+  return replaced
+}
@@ -0,0 +1,95 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { compareApiEntries, diffApiSurface, extractApiSurface, parseApiManifest, type ApiEntry } from './api-surface.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const V1: FixtureFiles = {
+  'src/user.ts': [
+    { name: 'Store', kind: 'interface', line: 1, end_line: 4, exported: true, members: ['get', 'set'], signature: 'interface Store' },
+    { name: 'User', kind: 'class', line: 5, end_line: 20, exported: true, extends: ['Entity'], signature: 'class User extends Entity' },
+    { name: 'User.save', kind: 'method', line: 6, end_line: 8, exported: true, signature: 'save(force?: boolean): Promise<void>' },
+    { name: 'User.secret', kind: 'method', line: 9, end_line: 10, exported: false, signature: 'private secret(): string' },
+    { name: 'findUser', kind: 'function', line: 21, end_line: 23, exported: true, signature: 'function findUser(name: string): User' },
+    { name: 'helper', kind: 'function', line: 24, end_line: 25, exported: false, signature: 'function helper()' }
+  ],
+  'src/format.ts': [
+    { name: 'format', kind: 'function', line: 1, end_line: 2, exported: true, signature: 'function format(value: string, width = 10): string' },
+    { name: 'legacy', kind: 'function', line: 3, end_line: 4, exported: true, signature: 'function legacy(): void' }
+  ]
+}
+
+const V2: FixtureFiles = {
+  'src/user.ts': [
+    { name: 'Store', kind: 'interface', line: 1, end_line: 5, exported: true, members: ['delete', 'get', 'set'], signature: 'interface Store' },
+    { name: 'User', kind: 'class', line: 6, end_line: 22, exported: true, implements: ['Serializable'], signature: 'class User implements Serializable' },
+    { name: 'User.save', kind: 'method', line: 7, end_line: 9, exported: true, signature: 'save(force?: boolean, retries = 3): Promise<void>' },
+    { name: 'findUser', kind: 'function', line: 23, end_line: 25, exported: true, signature: 'function findUser(name: string, ctx: Context): User' }
+  ],
+  'src/format.ts': [
+    { name: 'format', kind: 'function', line: 1, end_line: 2, exported: true, signature: 'function format(text: string,  width = 10): string' },
+    { name: 'pad', kind: 'function', line: 3, end_line: 4, exported: true, signature: 'function pad(value: string): string' }
+  ]
+}
+
+test('api-surface: the manifest lists exported symbols by key without line numbers', () => {
+  const manifest = extractApiSurface(createIndex(V1))
+  assert.deepEqual(manifest.symbols.map(e => e.key), [
+    'src/format.ts:format',
+    'src/format.ts:legacy',
+    'src/user.ts:Store',
+    'src/user.ts:User',
+    'src/user.ts:User.save',
+    'src/user.ts:findUser'
+  ])
+  assert.deepEqual(manifest.symbols.find(e => e.name === 'Store'), {
+    key: 'src/user.ts:Store', name: 'Store', kind: 'interface', path: 'src/user.ts', lang: 'typescript',
+    signature: 'interface Store', members: ['get', 'set']
+  })
+  assert.deepEqual(parseApiManifest(JSON.stringify(manifest)), manifest)
+  assert.throws(() => parseApiManifest('{"symbols": []}'), /Not an API manifest/)
+  assert.deepEqual(extractApiSurface(createIndex(V1), { packages: ['./lib/...'] }).symbols, [])
+})
+
+test('api-surface: overloads are keyed by their parameter types', () => {
+  const index = new SymbolIndex()
+  index.addFile('Game/Api.cs', 'csharp', [
+    { name: 'Api.Send', kind: 'method', line: 1, end_line: 2, exported: true, signature: 'public void Send(string text)' },
+    { name: 'Api.Send', kind: 'method', line: 3, end_line: 4, exported: true, signature: 'public void Send(int code, string text)' }
+  ])
+  assert.deepEqual(extractApiSurface(index).symbols.map(e => e.key), [
+    'Game/Api.cs:Api.Send(number, string)',
+    'Game/Api.cs:Api.Send(string)'
+  ])
+})
+
+test('api-surface: diff flags breaking changes', () => {
+  const changes = diffApiSurface(extractApiSurface(createIndex(V1)), extractApiSurface(createIndex(V2)))
+  assert.deepEqual(changes.map(c => [c.key, c.change, c.breaking, c.reasons]), [
+    ['src/format.ts:format', 'changed', false, ['signature changed compatibly']],
+    ['src/format.ts:legacy', 'removed', true, ['removed']],
+    ['src/format.ts:pad', 'added', false, ['added']],
+    ['src/user.ts:Store', 'changed', true, ['interface member delete added']],
+    ['src/user.ts:User', 'changed', true, ['no longer extends Entity', 'declaration changed', 'now implements Serializable']],
+    ['src/user.ts:User.save', 'changed', false, ['signature changed compatibly']],
+    ['src/user.ts:findUser', 'changed', true, ['new required parameter 2']]
+  ])
+})
+
+test('api-surface: callable signature rules', () => {
+  const fn = (signature: string, kind = 'function'): ApiEntry => ({ key: 'a.ts:f', name: 'f', kind, path: 'a.ts', lang: 'typescript', signature })
+  const breaking = (a: string, b: string) => compareApiEntries(fn(a), fn(b)).breaking
+
+  assert.deepEqual(breaking('function f(a: string, b?: number)', 'function f(a: string)'), ['parameter 2 removed'])
+  assert.deepEqual(breaking('function f(a: string)', 'function f(a: number)'), ['parameter 1 type changed from string to number'])
+  assert.deepEqual(breaking('function f(a?: string)', 'function f(a: string)'), ['parameter 1 is now required'])
+  assert.deepEqual(breaking('function f(a: string)', 'function f(a?: string)'), [])
+  assert.deepEqual(breaking('function f(...a: string[])', 'function f(a: string)'), ['parameter 1 is no longer variadic'])
+  assert.deepEqual(breaking('function f(): string', 'function f(): number'), ['result type changed from string to number'])
+  assert.deepEqual(breaking('function f(): string', 'async function f(): Promise<string>'), [])
+  assert.deepEqual(compareApiEntries(fn('function useF()'), fn('function useF()', 'hook')), {
+    breaking: [],
+    compatible: ['kind changed from function to hook']
+  })
+  assert.deepEqual(compareApiEntries(fn('function f()'), fn('function f()', 'class')).breaking, ['kind changed from function to class'])
+})
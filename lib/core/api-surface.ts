@@ -0,0 +1,206 @@
+/**
+ * API Surface Module
+ * The exported API of a project as a stable manifest (no line numbers, sorted
+ * by key, so it diffs cleanly when checked in), and the difference between
+ * two manifests with breaking changes flagged, like Go's apidiff.
+ *
+ * A symbol's key is its file and qualified name ("src/user.ts:User.save");
+ * overloads sharing a key get their parameter types appended. Breaking
+ * changes: a removed symbol, a changed kind, a callable whose parameters or
+ * result changed (other than appending optional parameters or making one
+ * optional), a member added to or removed from an interface (implementers
+ * break either way), a type that no longer extends or implements one of its
+ * supertypes, and changed type parameters. Anything else that changed in a
+ * declaration is reported as compatible.
+ */
+
+import { CALLABLE_KINDS } from './call-graph.js'
+import { matchesPackage } from './symbol-query.js'
+import { symbolSignature, type ParsedSignature } from './signature-search.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol, TypeParameter } from '../types/index.js'
+
+export const API_MANIFEST_FORMAT = 1
+
+export interface ApiEntry {
+  key: string
+  name: string
+  kind: string
+  path: string
+  lang: string
+  signature?: string // declaration header, whitespace collapsed
+  type_params?: TypeParameter[]
+  extends?: string[]
+  implements?: string[]
+  members?: string[] // of an interface
+}
+
+export interface ApiManifest {
+  format: number
+  symbols: ApiEntry[]
+}
+
+export interface ApiChange {
+  change: 'added' | 'removed' | 'changed'
+  key: string
+  before?: ApiEntry
+  after?: ApiEntry
+  breaking: boolean
+  reasons: string[] // what changed, breaking reasons first
+}
+
+export interface ApiSurfaceOptions {
+  /** Restrict to these package patterns (see matchesPackage) */
+  packages?: string[]
+}
+
+function entryOf(sym: IndexedSymbol): ApiEntry {
+  const entry: ApiEntry = { key: `${sym.path}:${sym.name}`, name: sym.name, kind: sym.kind, path: sym.path, lang: sym.lang }
+  if (sym.signature) entry.signature = String(sym.signature).replace(/\s+/g, ' ').trim()
+  if (sym.type_params) entry.type_params = sym.type_params
+  if (sym.extends?.length) entry.extends = [...sym.extends].sort()
+  if (sym.implements?.length) entry.implements = [...sym.implements].sort()
+  if (sym.members?.length) entry.members = [...sym.members].sort()
+  return entry
+}
+
+/**
+ * Manifest of the exported symbols of an index
+ */
+export function extractApiSurface(index: SymbolIndex, options: ApiSurfaceOptions = {}): ApiManifest {
+  const packages = options.packages && options.packages.length > 0 ? options.packages : null
+  const byKey = new Map<string, ApiEntry[]>()
+  for (const sym of index.allSymbols()) {
+    if (!sym.exported || sym.generated || sym.external) continue
+    if (packages && !packages.some(p => matchesPackage(sym.path, p))) continue
+    const entry = entryOf(sym)
+    const group = byKey.get(entry.key)
+    if (group) group.push(entry)
+    else byKey.set(entry.key, [entry])
+  }
+  const symbols: ApiEntry[] = []
+  for (const group of byKey.values()) {
+    if (group.length === 1) {
+      symbols.push(group[0])
+      continue
+    }
+    // Overloads: the parameter types tell them apart
+    for (const entry of group) {
+      const params = parsedSignature(entry)?.params.map(p => p.type).join(', ') || ''
+      symbols.push({ ...entry, key: `${entry.key}(${params})` })
+    }
+  }
+  symbols.sort((a, b) => (a.key < b.key ? -1 : a.key > b.key ? 1 : 0))
+  return { format: API_MANIFEST_FORMAT, symbols }
+}
+
+/**
+ * Parse a manifest written by extractApiSurface
+ * @throws Error if the content is not a manifest of a known format
+ */
+export function parseApiManifest(content: string): ApiManifest {
+  const manifest = JSON.parse(content)
+  if (!manifest || !Array.isArray(manifest.symbols) || manifest.format !== API_MANIFEST_FORMAT) {
+    throw new Error(`Not an API manifest of format ${API_MANIFEST_FORMAT}`)
+  }
+  return manifest
+}
+
+function parsedSignature(entry: ApiEntry): ParsedSignature | undefined {
+  return symbolSignature({ id: entry.key, name: entry.name, kind: entry.kind, path: entry.path, lang: entry.lang, line: 0, end_line: 0, signature: entry.signature })
+}
+
+// Incompatibilities between two parsed signatures of a callable
+function signatureBreaks(before: ParsedSignature, after: ParsedSignature): string[] {
+  const reasons: string[] = []
+  before.params.forEach((param, i) => {
+    const next = after.params[i]
+    if (!next) {
+      reasons.push(`parameter ${i + 1} removed`)
+    } else if (next.type !== param.type) {
+      reasons.push(`parameter ${i + 1} type changed from ${param.type} to ${next.type}`)
+    } else if (param.rest && !next.rest) {
+      reasons.push(`parameter ${i + 1} is no longer variadic`)
+    } else if (param.optional && !next.optional && !next.rest) {
+      reasons.push(`parameter ${i + 1} is now required`)
+    }
+  })
+  after.params.slice(before.params.length).forEach((param, i) => {
+    if (!param.optional && !param.rest) reasons.push(`new required parameter ${before.params.length + i + 1}`)
+  })
+  if (after.returns !== before.returns) reasons.push(`result type changed from ${before.returns} to ${after.returns}`)
+  return reasons
+}
+
+function listDiff(before: string[] = [], after: string[] = []): { added: string[], removed: string[] } {
+  return {
+    added: after.filter(x => !before.includes(x)),
+    removed: before.filter(x => !after.includes(x))
+  }
+}
+
+const typeParamText = (params: TypeParameter[] = []) => JSON.stringify(params.map(p => [p.name, p.constraint, p.default]))
+
+/**
+ * Breaking and compatible differences of one symbol between two manifests
+ */
+export function compareApiEntries(before: ApiEntry, after: ApiEntry): { breaking: string[], compatible: string[] } {
+  const breaking: string[] = []
+  const compatible: string[] = []
+  const callable = CALLABLE_KINDS.has(before.kind) && CALLABLE_KINDS.has(after.kind)
+  if (before.kind !== after.kind) {
+    const reason = `kind changed from ${before.kind} to ${after.kind}`
+    // A function turned hook or component is still called the same way
+    if (callable) compatible.push(reason)
+    else breaking.push(reason)
+  }
+
+  if (before.signature !== after.signature) {
+    const [old, next] = [parsedSignature(before), parsedSignature(after)]
+    if (callable && old && next) {
+      const reasons = signatureBreaks(old, next)
+      if (reasons.length > 0) breaking.push(...reasons)
+      else compatible.push('signature changed compatibly')
+    } else if (callable) {
+      breaking.push('signature changed')
+    } else {
+      compatible.push('declaration changed')
+    }
+  }
+
+  if (typeParamText(before.type_params) !== typeParamText(after.type_params)) {
+    breaking.push('type parameters changed')
+  }
+  for (const relation of ['extends', 'implements'] as const) {
+    const { added, removed } = listDiff(before[relation], after[relation])
+    for (const name of removed) breaking.push(`no longer ${relation} ${name}`)
+    for (const name of added) compatible.push(`now ${relation} ${name}`)
+  }
+  const members = listDiff(before.members, after.members)
+  for (const name of members.removed) breaking.push(`interface member ${name} removed`)
+  for (const name of members.added) breaking.push(`interface member ${name} added`)
+  return { breaking, compatible }
+}
+
+/**
+ * Added, removed and changed exported symbols between two manifests, by key
+ */
+export function diffApiSurface(before: ApiManifest, after: ApiManifest): ApiChange[] {
+  const old = new Map(before.symbols.map(e => [e.key, e]))
+  const next = new Map(after.symbols.map(e => [e.key, e]))
+  const changes: ApiChange[] = []
+  for (const [key, entry] of old) {
+    const updated = next.get(key)
+    if (!updated) {
+      changes.push({ change: 'removed', key, before: entry, breaking: true, reasons: ['removed'] })
+      continue
+    }
+    const { breaking, compatible } = compareApiEntries(entry, updated)
+    if (breaking.length === 0 && compatible.length === 0) continue
+    changes.push({ change: 'changed', key, before: entry, after: updated, breaking: breaking.length > 0, reasons: [...breaking, ...compatible] })
+  }
+  for (const [key, entry] of next) {
+    if (!old.has(key)) changes.push({ change: 'added', key, after: entry, breaking: false, reasons: ['added'] })
+  }
+  return changes.sort((a, b) => (a.key < b.key ? -1 : a.key > b.key ? 1 : 0))
+}
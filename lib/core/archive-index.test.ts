@@ -0,0 +1,57 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import zlib from 'zlib'
+import { tmpdir } from 'os'
+import { archivePrefix, openArchiveIndex } from './archive-index.js'
+import { tar, tarEntry, zip } from '../test-utils.js'
+
+test('archive-index: top-level directories and Go module zips', () => {
+  assert.deepEqual(archivePrefix(['github.com/!burnt!sushi/toml@v1.3.2/decode.go', 'github.com/!burnt!sushi/toml@v1.3.2/go.mod']), {
+    prefix: 'github.com/!burnt!sushi/toml@v1.3.2',
+    module: { name: 'github.com/BurntSushi/toml', version: 'v1.3.2' }
+  })
+  assert.deepEqual(archivePrefix(['package/package.json', 'package/lib/index.js']), { prefix: 'package' })
+  assert.deepEqual(archivePrefix(['src/@types/x.d.ts', 'src/main.ts']), { prefix: 'src' })
+  assert.deepEqual(archivePrefix(['README.md', 'src/main.ts']), { prefix: '' })
+})
+
+test('archive-index: indexes an npm pack zip and a PyPI sdist in memory', async () => {
+  const dir = await fs.mkdtemp(path.join(tmpdir(), 'archive-index-test-'))
+  process.env.INDEXER_MAX_FILE_SIZE = '1k'
+  try {
+    await fs.writeFile(path.join(dir, 'left-pad.zip'), zip({
+      'package/package.json': JSON.stringify({ name: 'left-pad', version: '1.3.0' }),
+      'package/index.js': 'export function leftPad(str, len) {\n  return str.padStart(len)\n}\n',
+      'package/node_modules/dep/index.js': 'export function hidden() {}\n',
+      'package/lib/table.js': `export const table = [${'0, '.repeat(1000)}]\n`
+    }))
+    const pack = await openArchiveIndex(path.join(dir, 'left-pad.zip'))
+    assert.equal(pack.prefix, 'package')
+    assert.deepEqual(pack.module, { name: 'left-pad', version: '1.3.0' })
+    assert.deepEqual(pack.index.listFiles(), ['index.js', 'lib/table.js'])
+    const [leftPad] = pack.index.findSymbols('leftPad')
+    assert.deepEqual([leftPad.path, leftPad.module, leftPad.module_version], ['index.js', 'left-pad', '1.3.0'])
+    assert.match(await pack.readSource('index.js') || '', /padStart/)
+    // Over the size limit: only its start was inflated, and it is not indexed
+    assert.deepEqual(pack.index.getFile('lib/table.js')!.diagnostics?.map(d => d.rule), ['max-file-size'])
+    assert.equal(pack.index.findSymbols('table').length, 0)
+
+    await fs.writeFile(path.join(dir, 'requests-2.31.0.tar.gz'), zlib.gzipSync(tar(
+      tarEntry('requests-2.31.0/requests/api.py', 'def get(url):\n    return url\n'),
+      tarEntry('requests-2.31.0/docs/theme.js', 'export function toggleTheme() {}\n'),
+      tarEntry('requests-2.31.0/.gitignore', 'build/\n'),
+      tarEntry('requests-2.31.0/build/lib/requests/api.py', 'def stale():\n    pass\n')
+    )))
+    const sdist = await openArchiveIndex(path.join(dir, 'requests-2.31.0.tar.gz'))
+    assert.equal(sdist.prefix, 'requests-2.31.0')
+    assert.equal(sdist.module, undefined)
+    assert.deepEqual(sdist.index.listFiles(), ['.gitignore', 'docs/theme.js', 'requests/api.py'])
+    assert.equal(sdist.index.findSymbols('toggleTheme')[0].path, 'docs/theme.js')
+    assert.equal(sdist.index.findSymbols('get')[0].path, 'requests/api.py')
+  } finally {
+    delete process.env.INDEXER_MAX_FILE_SIZE
+    await fs.rm(dir, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,162 @@
+/**
+ * Archive Index Module
+ * Symbol indexes of a zip or tar archive (a release tarball, an npm pack,
+ * a PyPI sdist) built from the archive in memory, so dependencies can be
+ * indexed in ephemeral CI containers without extracting them. A top-level
+ * directory every entry shares is stripped from paths. An npm pack's
+ * package.json names the module and version every symbol records, and so
+ * does the <module>@<version>/ directory of a Go module zip (whose .go
+ * files only a registered Go backend indexes). The default excludes apply,
+ * and the archive's own .gitignore and .indexerignore; files that are not
+ * UTF-8 text are skipped. Entries are inflated up to the file size limit
+ * (see file-limits), and the archive up to INDEXER_MAX_ARCHIVE_SIZE.
+ */
+
+import ignore from 'ignore'
+import { SymbolIndex, indexFileContents } from './symbol-index.js'
+import { DEFAULT_EXCLUDES } from './file-filters.js'
+import { memoryFileSource, type FileSource } from './file-source.js'
+import { loadFileLimits, sourceText, type FileLimits, type SourceContent } from './file-limits.js'
+import { parseByteRate, type IndexContext } from './index-limits.js'
+import type { ModuleInfo } from './package-graph.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import { DEFAULT_ARCHIVE_LIMITS, readArchive, type ArchiveEntry, type ArchiveLimits } from '../utils/archive.js'
+
+export interface ArchiveFileSource extends FileSource {
+  /** Directory stripped from the entry paths ('' for none), without the trailing slash */
+  prefix: string
+  /** Module of a Go module zip or an npm pack */
+  module?: ModuleInfo
+  /**
+   * Content to index: the start of an entry over the size limit, which
+   * readFile leaves out since it holds no more (see readSourceContent)
+   */
+  readContent(relPath: string): Promise<SourceContent | null>
+}
+
+export interface ArchiveIndex {
+  index: SymbolIndex
+  /** Directory stripped from the entry paths ('' for none), without the trailing slash */
+  prefix: string
+  /** Module of a Go module zip or an npm pack */
+  module?: ModuleInfo
+  /** Contents of an indexed file, from the archive */
+  readSource: (relPath: string) => Promise<string | null>
+}
+
+// Go module paths escape capitals as !<lowercase> (golang.org/x/mod/module)
+function unescapeModulePath(escaped: string): string {
+  return escaped.replace(/!([a-z])/g, (_m, c: string) => c.toUpperCase())
+}
+
+/**
+ * Directory every entry lives under, and the Go module it names if any
+ */
+export function archivePrefix(paths: string[]): { prefix: string, module?: ModuleInfo } {
+  if (paths.length === 0) return { prefix: '' }
+  const go = /^([^@]*[^@/])@([^/]+)\//.exec(paths[0])
+  if (go && paths.every(p => p.startsWith(go[0]))) {
+    return { prefix: go[0].slice(0, -1), module: { name: unescapeModulePath(go[1]), version: unescapeModulePath(go[2]) } }
+  }
+  const top = paths[0].split('/')[0]
+  if (paths.every(p => p.startsWith(`${top}/`))) return { prefix: top }
+  return { prefix: '' }
+}
+
+function packageModule(entries: Map<string, string>): ModuleInfo | undefined {
+  const text = entries.get('package.json')
+  if (text === undefined) return undefined
+  try {
+    const pkg = JSON.parse(text)
+    if (typeof pkg.name === 'string' && typeof pkg.version === 'string') return { name: pkg.name, version: pkg.version }
+  } catch {}
+  return undefined
+}
+
+// UTF-8 text of an entry, or null for binary data
+function entryText(entry: ArchiveEntry): string | null {
+  if (entry.data.includes(0)) return null
+  return entry.data.toString('utf8')
+}
+
+/**
+ * Limits an archive is read with: entries up to the file size limit, and
+ * INDEXER_MAX_ARCHIVE_SIZE bytes inflated in all (default 1 GiB, 0 for no limit)
+ */
+export function archiveLimits(limits: FileLimits): ArchiveLimits {
+  const total = process.env.INDEXER_MAX_ARCHIVE_SIZE
+  let maxTotalBytes = DEFAULT_ARCHIVE_LIMITS.maxTotalBytes
+  if (total) {
+    try {
+      maxTotalBytes = parseByteRate(total)
+    } catch {
+      throw new Error(`Invalid INDEXER_MAX_ARCHIVE_SIZE "${total}". Use bytes, such as 2GB, 0 for no limit`)
+    }
+  }
+  return { maxEntryBytes: limits.maxFileBytes, maxTotalBytes }
+}
+
+/**
+ * The indexable files of an archive as a file source, read into memory once
+ * @param archivePath - Zip, tar or .tar.gz file
+ * @param limits - File limits, the environment's when left out (see loadFileLimits)
+ */
+export async function archiveFileSource(archivePath: string, limits?: FileLimits): Promise<ArchiveFileSource> {
+  const entries = await readArchive(archivePath, archiveLimits(limits ?? await loadFileLimits(null)))
+  const { prefix, module: goModule } = archivePrefix(entries.map(e => e.path))
+  const texts = new Map<string, string>()
+  const oversized = new Map<string, number>() // bytes of entries only the start of which was read
+  for (const entry of entries) {
+    const text = entryText(entry)
+    if (text === null) continue
+    const relPath = prefix ? entry.path.slice(prefix.length + 1) : entry.path
+    texts.set(relPath, text)
+    if (entry.size > entry.data.length) oversized.set(relPath, entry.size)
+  }
+
+  const ig = ignore().add(DEFAULT_EXCLUDES.map(p => p.replace('**/', '')))
+  for (const file of ['.gitignore', '.indexerignore']) {
+    const text = texts.get(file)
+    if (text !== undefined) ig.add(text.split(/\r?\n/))
+  }
+  const files = new Map([...texts].filter(([p]) => !ig.ignores(p) && !p.endsWith('.lock')))
+  const module = goModule || packageModule(texts)
+  const memory = memoryFileSource(files)
+  return {
+    ...memory,
+    readFile: async relPath => (oversized.has(relPath) ? null : memory.readFile(relPath)),
+    readContent: async (relPath) => {
+      const text = files.get(relPath)
+      const bytes = oversized.get(relPath)
+      return text === undefined ? null : bytes === undefined ? text : { head: text, bytes }
+    },
+    prefix,
+    ...(module ? { module } : {})
+  }
+}
+
+/**
+ * Index the files of an archive
+ * @param archivePath - Zip, tar or .tar.gz file
+ * @param ctx - Cancellation and parse concurrency
+ */
+export async function openArchiveIndex(archivePath: string, ctx: IndexContext = {}): Promise<ArchiveIndex> {
+  const limits = await loadFileLimits(null)
+  const source = await archiveFileSource(archivePath, limits)
+  const relPaths = await source.listFiles()
+  const contents = await Promise.all(relPaths.map(p => source.readContent(p)))
+  ctx.signal?.throwIfAborted()
+
+  await initTreeSitter()
+  const index = new SymbolIndex()
+  index.limits = limits
+  const { prefix, module } = source
+  if (module) index.moduleOf = () => module
+  await indexFileContents(index, relPaths, contents, undefined, ctx)
+  return {
+    index,
+    prefix,
+    ...(module ? { module } : {}),
+    readSource: async relPath => (index.getFile(relPath) ? source.readContent(relPath).then(c => (c === null ? null : sourceText(c))) : null)
+  }
+}
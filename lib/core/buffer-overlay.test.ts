@@ -0,0 +1,54 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { BufferOverlay } from './buffer-overlay.js'
+import { indexContent } from './symbol-index.js'
+import { indexSources } from '../test-utils.js'
+
+const SOURCES = {
+  'src/user.ts': 'export class User {\n  save() {}\n}\n',
+  'src/admin.ts': 'export function promote() {}\n'
+}
+
+test('buffer-overlay: dirty buffers replace indexed files in queries only', async () => {
+  const base = await indexSources(SOURCES)
+  const overlay = new BufferOverlay(base)
+  overlay.set('src/user.ts', 'export class User {\n  save() {}\n  load() {}\n}\n')
+  overlay.set('src/draft.ts', 'export const draft = 1\n')
+  overlay.delete('src/admin.ts')
+
+  const view = await overlay.index()
+  assert.deepEqual(view.listFiles(), ['src/draft.ts', 'src/user.ts'])
+  assert.equal(view.findSymbols('User.load').length, 1)
+  assert.deepEqual(view.text.candidates('load'), ['src/user.ts'])
+  assert.throws(() => view.removeFile('src/user.ts'), /read-only/)
+
+  // The base index never sees buffer contents
+  assert.deepEqual(base.listFiles(), ['src/admin.ts', 'src/user.ts'])
+  assert.equal(base.findSymbols('User.load').length, 0)
+  assert.deepEqual(base.text.candidates('load'), [])
+  assert.equal(await overlay.index(), view)
+})
+
+test('buffer-overlay: closing a buffer restores the file, base changes show through', async () => {
+  const base = await indexSources(SOURCES)
+  const overlay = new BufferOverlay(base)
+  overlay.set('src/user.ts', 'export class Account {}\n')
+  overlay.set('src/draft.ts', 'export const draft = 1\n')
+  const before = await overlay.index()
+
+  overlay.close('src/user.ts')
+  const after = await overlay.index()
+  assert.equal(after.findSymbols('Account').length, 0)
+  assert.equal(after.findSymbols('User.save').length, 1)
+  assert.deepEqual(after.text.candidates('User'), ['src/user.ts'])
+  // Views already handed out keep their contents
+  assert.equal(before.findSymbols('Account').length, 1)
+
+  await base.write(() => indexContent(base, 'src/admin.ts', 'export function demote() {}\n'))
+  const rebased = await overlay.index()
+  assert.equal(rebased.findSymbols('demote').length, 1)
+  assert.equal(rebased.findSymbols('draft').length, 1)
+
+  overlay.close('src/draft.ts')
+  assert.deepEqual((await overlay.index()).listFiles(), base.listFiles())
+})
@@ -0,0 +1,118 @@
+/**
+ * Buffer Overlay Module
+ * Unsaved editor buffers over a symbol index. Queries run against a view of
+ * the index in which every dirty buffer replaces the file on disk: buffers
+ * are re-parsed when they change, everything else is shared with the base
+ * index, and the base itself never sees buffer contents. Closing a buffer
+ * brings back the indexed file.
+ */
+
+import { indexContent, type SymbolIndex } from './symbol-index.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+
+export class BufferOverlay {
+  private readonly buffers = new Map<string, string | null>() // null: deleted in the editor
+  private readonly pending = new Set<string>() // changed since the fork was last brought up to date
+  private fork: SymbolIndex | null = null
+  private forkedAt = -1 // base generation the fork was made from
+  private view: SymbolIndex | null = null
+
+  constructor(private readonly base: SymbolIndex) {}
+
+  /**
+   * Set the content of a dirty buffer
+   */
+  set(relPath: string, content: string): void {
+    if (this.buffers.get(relPath) === content) return
+    this.buffers.set(relPath, content)
+    this.pending.add(relPath)
+  }
+
+  /**
+   * Hide a file, as if it had been deleted but not yet saved
+   */
+  delete(relPath: string): void {
+    if (this.buffers.has(relPath) && this.buffers.get(relPath) === null) return
+    this.buffers.set(relPath, null)
+    this.pending.add(relPath)
+  }
+
+  /**
+   * Drop a buffer so the indexed file shows through again
+   */
+  close(relPath: string): void {
+    if (!this.buffers.delete(relPath)) return
+    this.pending.add(relPath)
+  }
+
+  has(relPath: string): boolean {
+    return this.buffers.has(relPath)
+  }
+
+  /** Paths with a dirty buffer or deletion */
+  dirtyFiles(): string[] {
+    return Array.from(this.buffers.keys()).sort()
+  }
+
+  /**
+   * Buffer content, or undefined when the file is not overlaid
+   * (null when it is deleted in the editor)
+   */
+  content(relPath: string): string | null | undefined {
+    return this.buffers.get(relPath)
+  }
+
+  /**
+   * Reader preferring buffer contents over another reader
+   */
+  reader(fallback: (relPath: string) => Promise<string | null>): (relPath: string) => Promise<string | null> {
+    return async (relPath) => (this.buffers.has(relPath) ? this.buffers.get(relPath)! : fallback(relPath))
+  }
+
+  /**
+   * Read-only view of the base index with the buffers merged in. Only
+   * buffers changed since the last call are re-parsed, unless the base
+   * index changed in between, which re-applies all of them.
+   */
+  async index(): Promise<SymbolIndex> {
+    if (this.buffers.size === 0) {
+      this.fork = null
+      this.view = null
+      this.pending.clear()
+      return this.base.snapshot()
+    }
+    if (this.view && this.pending.size === 0 && this.forkedAt === this.base.generation) return this.view
+
+    await initTreeSitter()
+    let changed: string[]
+    if (!this.fork || this.forkedAt !== this.base.generation) {
+      const source = this.base.snapshot()
+      this.forkedAt = source.generation
+      this.fork = source.fork()
+      changed = Array.from(this.buffers.keys())
+    } else {
+      changed = Array.from(this.pending)
+    }
+    this.pending.clear()
+
+    const fork = this.fork
+    for (const relPath of changed.sort()) {
+      const content = this.buffers.get(relPath)
+      if (typeof content === 'string') {
+        await indexContent(fork, relPath, content)
+        continue
+      }
+      if (content === null) {
+        fork.removeFile(relPath)
+        continue
+      }
+      // Closed: back to the base's shard and text
+      const shard = this.base.getFile(relPath)
+      if (shard) fork.addShard(shard)
+      else fork.removeFile(relPath)
+      fork.text.revert(relPath)
+    }
+    this.view = fork.snapshot()
+    return this.view
+  }
+}
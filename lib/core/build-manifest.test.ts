@@ -0,0 +1,63 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { buildModuleOf, parseBuildManifest } from './build-manifest.js'
+import { listProjectFiles, resetConfigCache, shouldIndexFile } from './file-filters.js'
+
+const LABEL_KIND_OUTPUT = `source file //:main.go
+source file //src/users:user.ts
+source file //src/users:BUILD.bazel
+generated file //src/users:api.pb.ts
+ts_project rule //src/users:users
+source file @npm//:package.json
+`
+
+test('build-manifest: labels map to files and build packages', () => {
+  const manifest = parseBuildManifest(LABEL_KIND_OUTPUT + '# hand-written\n//src/billing:invoice.ts\n./tools/gen.py\n', 'bazel-out/k8-fastbuild/bin')
+  assert.deepEqual([...manifest.packages], [
+    ['main.go', '//'],
+    ['src/users/user.ts', '//src/users'],
+    ['src/users/BUILD.bazel', '//src/users'],
+    ['bazel-out/k8-fastbuild/bin/src/users/api.pb.ts', '//src/users'],
+    ['src/billing/invoice.ts', '//src/billing'],
+    ['tools/gen.py', '//tools']
+  ])
+  assert.deepEqual([...manifest.generated], ['bazel-out/k8-fastbuild/bin/src/users/api.pb.ts'])
+  const moduleOf = buildModuleOf(manifest)
+  assert.deepEqual(moduleOf('src/users/user.ts'), { name: '//src/users', version: '' })
+  assert.equal(moduleOf('src/unbuilt.ts'), null)
+})
+
+test('build-manifest: a build: entry replaces walking the tree', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'build-manifest-test-'))
+  try {
+    const files: Record<string, string> = {
+      '.indexer/to-index': 'build: build-files.txt\next: .ts\nexclude: *.test.ts\n',
+      '.gitignore': 'bazel-*\n',
+      'build-files.txt': LABEL_KIND_OUTPUT + 'source file //src/users:user.test.ts\n',
+      'src/users/user.ts': '',
+      'src/users/user.test.ts': '',
+      'src/unbuilt.ts': '',
+      'bazel-out/k8-fastbuild/bin/src/users/api.pb.ts': ''
+    }
+    for (const [relPath, content] of Object.entries(files)) {
+      await fs.mkdir(path.dirname(path.join(root, relPath)), { recursive: true })
+      await fs.writeFile(path.join(root, relPath), content)
+    }
+    // As bazel leaves it: bazel-bin points into the output base, which bazel-out also reaches
+    await fs.symlink(path.join(root, 'bazel-out/k8-fastbuild/bin'), path.join(root, 'bazel-bin'))
+    resetConfigCache(root)
+
+    assert.deepEqual((await listProjectFiles(root)).sort(), ['bazel-out/k8-fastbuild/bin/src/users/api.pb.ts', 'src/users/user.ts'])
+    assert.equal(await shouldIndexFile('src/unbuilt.ts', root), false)
+    assert.equal(await shouldIndexFile('bazel-out/k8-fastbuild/bin/src/users/api.pb.ts', root), true)
+    // In the manifest, but filtered out by the exclude: entry
+    assert.equal(await shouldIndexFile('src/users/user.test.ts', root), false)
+    assert.equal(await shouldIndexFile('src/users/user.ts', root), true)
+  } finally {
+    resetConfigCache(root)
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
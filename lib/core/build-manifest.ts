@@ -0,0 +1,142 @@
+/**
+ * Build Manifest Module
+ * Takes the files to index, and the packages they belong to, from the build
+ * system instead of walking the tree, so the index matches what the build
+ * compiles: sources no target uses are left out and generated sources under
+ * bazel-out (or plz-out/gen) are included. Enabled by a build: entry in
+ * .indexer/to-index:
+ *
+ *   build: bazel            # bazel query 'kind("source file|generated file", //...)'
+ *   build: files.txt        # a manifest, relative to the project root
+ *
+ * A manifest has one entry per line: a label (//pkg:file), optionally after
+ * the kind bazel query --output=label_kind prints ("generated file //pkg:x.ts"),
+ * or a project-relative path, whose package is its directory. Saved bazel
+ * query output works as is; for Please or other build systems, list the
+ * files (or labels) the build uses.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { execFile } from 'child_process'
+import type { ModuleInfo } from './package-graph.js'
+
+const BAZEL_QUERY = 'kind("source file|generated file", //...)'
+// Where generated files live when the build system cannot be asked
+const GENERATED_ROOTS = ['bazel-bin', 'plz-out/gen']
+
+export interface BuildManifest {
+  packages: Map<string, string> // file -> package label (//src/users)
+  generated: Set<string> // files that are build outputs
+}
+
+const _manifestCache = new Map<string, Promise<BuildManifest>>()
+
+export function resetBuildManifestCache(projectRoot?: string): void {
+  if (projectRoot) _manifestCache.delete(projectRoot)
+  else _manifestCache.clear()
+}
+
+/**
+ * Split a label into its package dir and target name; null for labels of
+ * other repositories and for targets without a file name
+ */
+function parseLabel(label: string): { dir: string, name: string } | null {
+  const main = label.replace(/^@@?(?=\/\/)/, '')
+  if (!main.startsWith('//')) return null
+  const colon = main.indexOf(':')
+  if (colon === -1) return null
+  const dir = main.slice(2, colon).replace(/\/+$/, '')
+  const name = main.slice(colon + 1)
+  return name ? { dir, name } : null
+}
+
+/**
+ * Parse manifest text (or bazel query output). Generated files are placed
+ * under generatedRoot, the directory the build writes them to.
+ */
+export function parseBuildManifest(text: string, generatedRoot: string): BuildManifest {
+  const manifest: BuildManifest = { packages: new Map(), generated: new Set() }
+  for (const raw of text.split(/\r?\n/)) {
+    const line = raw.trim()
+    if (!line || line.startsWith('#')) continue
+    const labelAt = line.search(/(^|\s)(@[^\s/]*)?\/\//)
+    if (labelAt === -1) {
+      const file = line.replace(/\\/g, '/').replace(/^\.\//, '')
+      const dir = path.posix.dirname(file)
+      manifest.packages.set(file, `//${dir === '.' ? '' : dir}`)
+      continue
+    }
+    const kind = line.slice(0, labelAt).trim()
+    // label_kind output also lists rules ("ts_project rule //src:lib")
+    if (kind && kind !== 'source file' && kind !== 'generated file') continue
+    const label = parseLabel(line.slice(labelAt).trim())
+    if (!label) continue
+    const source = label.dir ? `${label.dir}/${label.name}` : label.name
+    const file = kind === 'generated file' ? `${generatedRoot}/${source}` : source
+    manifest.packages.set(file, `//${label.dir}`)
+    if (kind === 'generated file') manifest.generated.add(file)
+  }
+  return manifest
+}
+
+/**
+ * The output directory bazel-bin points to, as a path inside the project
+ * (bazel-out/<config>/bin), or the first generated root that exists
+ */
+async function generatedRoot(projectRoot: string): Promise<string> {
+  try {
+    const target = (await fs.readlink(path.join(projectRoot, 'bazel-bin'))).replace(/\\/g, '/')
+    const at = target.lastIndexOf('/bazel-out/')
+    return at === -1 ? 'bazel-bin' : target.slice(at + 1)
+  } catch {}
+  for (const dir of GENERATED_ROOTS) {
+    try {
+      await fs.access(path.join(projectRoot, dir))
+      return dir
+    } catch {}
+  }
+  return GENERATED_ROOTS[0]
+}
+
+function bazelQuery(projectRoot: string): Promise<string> {
+  return new Promise((resolve, reject) => {
+    const args = ['query', BAZEL_QUERY, '--output=label_kind', '--keep_going', '--noshow_progress']
+    execFile('bazel', args, { cwd: projectRoot, maxBuffer: 256 * 1024 * 1024 }, (err, stdout, stderr) => {
+      // --keep_going exits with 3 when some packages failed to load; the rest is still usable
+      if (err && (err as any).code !== 3) reject(new Error(`bazel query failed: ${stderr.trim() || err.message}`))
+      else resolve(stdout)
+    })
+  })
+}
+
+/**
+ * The manifest a build: entry names, read once per project until
+ * resetBuildManifestCache
+ * @param projectRoot - Project root path
+ * @param build - "bazel", or the path of a manifest file
+ */
+export function loadBuildManifest(projectRoot: string, build: string): Promise<BuildManifest> {
+  let manifest = _manifestCache.get(projectRoot)
+  if (!manifest) {
+    manifest = (async () => {
+      const text = build === 'bazel'
+        ? await bazelQuery(projectRoot)
+        : await fs.readFile(path.resolve(projectRoot, build), 'utf8')
+      return parseBuildManifest(text, await generatedRoot(projectRoot))
+    })()
+    manifest.catch(() => _manifestCache.delete(projectRoot))
+    _manifestCache.set(projectRoot, manifest)
+  }
+  return manifest
+}
+
+/**
+ * Module resolver for the symbol index: a file's module is its build package
+ */
+export function buildModuleOf(manifest: BuildManifest): (filePath: string) => ModuleInfo | null {
+  return (filePath) => {
+    const label = manifest.packages.get(filePath)
+    return label === undefined ? null : { name: label, version: '' }
+  }
+}
@@ -0,0 +1,123 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import type { DispatchAlgorithm } from './call-graph.js'
+import { querySymbols } from './symbol-query.js'
+import { exportCallGraphDot } from '../exporters/dot.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/user.ts': `
+export class User {
+  constructor() {
+    this.reset()
+  }
+  reset() {}
+  save() {}
+}
+
+export class Team {
+  save() {}
+}
+
+export function helper() {}
+
+export function main(target) {
+  const user = new User()
+  helper()
+  target.save()
+}
+`
+}
+
+function names(symbols: { name: string }[]): string[] {
+  return symbols.map(s => s.name).sort()
+}
+
+function idOf(index: SymbolIndex, name: string): string {
+  return index.findSymbols(name).find(s => s.name === name)!.id
+}
+
+test('call-graph: direct, constructor and this calls resolve statically', () => {
+  const index = createIndex(FILES)
+  const callees = index.callees(idOf(index, 'main'))
+  assert.deepEqual(names(callees), ['Team.save', 'User.constructor', 'User.save', 'helper'])
+  assert.deepEqual(names(index.callees(idOf(index, 'User.constructor'))), ['User.reset'])
+  assert.deepEqual(names(index.callers(idOf(index, 'helper'))), ['main'])
+})
+
+test('call-graph: calls through other receivers fan out and are dynamic', () => {
+  const index = createIndex(FILES)
+  const edges = index.callGraph().callees.get(idOf(index, 'main'))!
+  const dynamic = edges.filter(e => e.dynamic).map(e => index.getSymbol(e.callee)!.name).sort()
+  assert.deepEqual(dynamic, ['Team.save', 'User.save'])
+})
+
+test('call-graph: query filters by callers and callees', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(querySymbols(index, { callersOf: 'reset' }).map(s => s.name), ['User.constructor'])
+  assert.deepEqual(querySymbols(index, { calleesOf: 'main', kinds: ['function'] }).map(s => s.name), ['helper'])
+})
+
+test('call-graph: DOT export clusters by file and dashes dynamic edges', () => {
+  const index = createIndex(FILES)
+  const dot = exportCallGraphDot(index)
+  assert.match(dot, /^digraph calls \{/)
+  assert.match(dot, /label="src\/user\.ts"/)
+  assert.match(dot, /"src\/user\.ts#main" -> "src\/user\.ts#helper";/)
+  assert.match(dot, /-> "src\/user\.ts#Team\.save" \[style=dashed\]/)
+
+  const focused = exportCallGraphDot(index, { focus: [idOf(index, 'User.reset')], depth: 1 })
+  assert.match(focused, /User\.constructor/)
+  assert.doesNotMatch(focused, /helper/)
+})
+
+// Notifier with Email and Sms declaring it and Pager matching it structurally;
+// only Email and Sms are instantiated
+function createDispatchIndex(): SymbolIndex {
+  const index = new SymbolIndex()
+  index.addFile('src/notify.ts', 'typescript', [
+    { name: 'Notifier', kind: 'interface', line: 1, end_line: 3, exported: true, members: ['notify'] },
+    { name: 'Notifier.notify', kind: 'method', line: 2, end_line: 2 },
+    { name: 'Email', kind: 'class', line: 4, end_line: 6, exported: true, implements: ['Notifier'] },
+    { name: 'Email.notify', kind: 'method', line: 5, end_line: 5 },
+    { name: 'Sms', kind: 'class', line: 7, end_line: 9, exported: true, implements: ['Notifier'] },
+    { name: 'Sms.notify', kind: 'method', line: 8, end_line: 8 },
+    { name: 'Pager', kind: 'class', line: 10, end_line: 12, exported: true },
+    { name: 'Pager.notify', kind: 'method', line: 11, end_line: 11 },
+    { name: 'send', kind: 'function', line: 13, end_line: 15, exported: true },
+    { name: 'notify', kind: 'reference', line: 14, end_line: 14, column: 5, call: true, receiver: 'n' },
+    { name: 'main', kind: 'function', line: 16, end_line: 19, exported: true },
+    { name: 'Email', kind: 'reference', line: 17, end_line: 17, column: 3, call: true },
+    { name: 'Sms', kind: 'reference', line: 18, end_line: 18, column: 3, call: true },
+    { name: 'direct', kind: 'function', line: 20, end_line: 23, exported: true },
+    { name: 'm', kind: 'local', line: 21, end_line: 21, column: 9, scope_line: 20, scope_column: 20, scope_end_line: 23, scope_end_column: 2 },
+    { name: 'Email', kind: 'reference', line: 21, end_line: 21, column: 13, call: true },
+    { name: 'notify', kind: 'reference', line: 22, end_line: 22, column: 5, call: true, receiver: 'm' }
+  ])
+  return index
+}
+
+function calleesWith(index: SymbolIndex, dispatch: DispatchAlgorithm, caller: string): string[] {
+  index.dispatch = dispatch
+  return names(index.callees(idOf(index, caller)))
+}
+
+test('call-graph: dispatch algorithms narrow calls through receivers', () => {
+  const index = createDispatchIndex()
+  const all = ['Email.notify', 'Notifier.notify', 'Pager.notify', 'Sms.notify']
+  assert.deepEqual(calleesWith(index, 'name', 'send'), all)
+  assert.deepEqual(calleesWith(index, 'cha', 'send'), all)
+  assert.deepEqual(calleesWith(index, 'rta', 'send'), ['Email.notify', 'Sms.notify'])
+  assert.deepEqual(calleesWith(index, 'rta', 'direct'), ['Email.notify', 'Sms.notify'])
+  assert.deepEqual(calleesWith(index, 'vta', 'direct'), ['Email.notify'])
+  assert.deepEqual(calleesWith(index, 'vta', 'send'), ['Email.notify', 'Sms.notify'])
+
+  // A receiver typed Notifier by the type checker reaches its implementations
+  const bound = createDispatchIndex()
+  const notify = idOf(bound, 'Notifier.notify')
+  bound.bindings = { targetOf: ref => ref.receiver === 'n' ? notify : undefined, boundTo: () => [] }
+  assert.deepEqual(calleesWith(bound, 'name', 'send'), all)
+  assert.deepEqual(calleesWith(bound, 'cha', 'send'), ['Email.notify', 'Pager.notify', 'Sms.notify'])
+  assert.deepEqual(calleesWith(bound, 'rta', 'send'), ['Email.notify', 'Sms.notify'])
+})
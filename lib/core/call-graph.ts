@@ -0,0 +1,279 @@
+/**
+ * Call Graph Module
+ * Analysis pass that records caller -> callee edges between functions and
+ * methods. Direct calls, `this`/`self` calls and constructors resolve
+ * statically; calls through other receivers fan out to every method with
+ * that name and are marked dynamic (best-effort interface dispatch). With
+ * --precision=full, a call the type checker bound goes to that definition
+ * alone, unless it is an interface's, which still fans out.
+ *
+ * --dispatch picks a sharper algorithm for those fan-outs:
+ *   name - every method with the called name (the default)
+ *   cha  - class hierarchy analysis: the method of the receiver's static type
+ *          and its overrides in every subtype. The static type is known for
+ *          this / self and for calls the type checker bound; other receivers
+ *          keep the name rule.
+ *   rta  - rapid type analysis: cha over the types the index instantiates
+ *          (new Foo(), Foo(), Foo{}), which also drops the methods of types
+ *          never instantiated from the name rule
+ *   vta  - variable type analysis: for a local receiver (--locals), the types
+ *          of the values its function assigns to it, when every assignment is
+ *          a constructor call; else rta
+ * A narrowing that would leave no target keeps the wider set.
+ */
+
+import { makeSymbolId } from './symbol-index.js'
+import type { SymbolIndex } from './symbol-index.js'
+import { INTERFACE_KINDS, TYPE_KINDS, heritageOf, resolveTypeName } from './implementations.js'
+import type { IndexedSymbol, SymbolReference } from '../types/index.js'
+
+export const CALLABLE_KINDS = new Set([
+  'function', 'method', 'hook', 'function_component', 'unity_lifecycle', 'accessor'
+])
+
+const SELF_RECEIVERS = new Set(['this', 'self'])
+
+export type DispatchAlgorithm = 'name' | 'cha' | 'rta' | 'vta'
+
+export const DISPATCH_ALGORITHMS: DispatchAlgorithm[] = ['name', 'cha', 'rta', 'vta']
+
+export interface CallEdge {
+  caller: string // symbol ID
+  callee: string // symbol ID
+  path: string
+  line: number
+  column?: number
+  dynamic: boolean
+  caught?: boolean // the call is in a try block that catches what it throws
+}
+
+export interface CallGraph {
+  edges: CallEdge[]
+  callees: Map<string, CallEdge[]> // caller ID -> outgoing edges
+  callers: Map<string, CallEdge[]> // callee ID -> incoming edges
+}
+
+function isMember(sym: IndexedSymbol): boolean {
+  return sym.name.includes('.')
+}
+
+/**
+ * Innermost callable whose line range contains a line
+ */
+function enclosingCallable(callables: IndexedSymbol[], line: number): IndexedSymbol | null {
+  let best: IndexedSymbol | null = null
+  for (const fn of callables) {
+    if (fn.line > line || fn.end_line < line) continue
+    if (!best || fn.line > best.line || (fn.line === best.line && fn.end_line < best.end_line)) best = fn
+  }
+  return best
+}
+
+function ownerType(index: SymbolIndex, member: IndexedSymbol): IndexedSymbol | null {
+  const dot = member.name.lastIndexOf('.')
+  if (dot === -1) return null
+  const ownerName = member.name.slice(0, dot)
+  return index.fileSymbols(member.path).find(s => s.name === ownerName && (TYPE_KINDS.has(s.kind) || INTERFACE_KINDS.has(s.kind))) || null
+}
+
+/**
+ * Method declared on a type or inherited from its base types
+ */
+function findMethod(index: SymbolIndex, type: IndexedSymbol, name: string, visiting = new Set<string>()): IndexedSymbol | null {
+  if (visiting.has(type.id)) return null
+  visiting.add(type.id)
+  const own = index.getSymbol(makeSymbolId(type.path, `${type.name}.${name}`))
+  if (own && CALLABLE_KINDS.has(own.kind)) return own
+  for (const baseName of heritageOf(type)) {
+    for (const base of resolveTypeName(index, baseName, type.path)) {
+      if (!TYPE_KINDS.has(base.kind)) continue
+      const inherited = findMethod(index, base, name, visiting)
+      if (inherited) return inherited
+    }
+  }
+  return null
+}
+
+interface Dispatch {
+  algorithm: DispatchAlgorithm
+  instantiated: Map<string, boolean> // type ID -> whether the index creates values of it
+}
+
+// A use of a type's name that creates a value: a call, or a plain read (Go's Foo{})
+function isInstantiated(index: SymbolIndex, type: IndexedSymbol, dispatch: Dispatch): boolean {
+  let live = dispatch.instantiated.get(type.id)
+  if (live === undefined) {
+    live = false
+    for (const ref of index.iterReferences(type.id)) {
+      if (!ref.role || ref.role === 'read' || ref.role === 'call') {
+        live = true
+        break
+      }
+    }
+    dispatch.instantiated.set(type.id, live)
+  }
+  return live
+}
+
+/**
+ * Concrete types a receiver of a static type can hold: the type and its
+ * subtypes, only the instantiated ones past cha
+ */
+function concreteTypes(index: SymbolIndex, type: IndexedSymbol, dispatch: Dispatch): IndexedSymbol[] {
+  const types = [type, ...index.subtypes(type.id, true).map(h => h.symbol)].filter(t => TYPE_KINDS.has(t.kind))
+  if (dispatch.algorithm === 'cha') return types
+  const live = types.filter(t => isInstantiated(index, t, dispatch))
+  return live.length > 0 ? live : types
+}
+
+// Distinct methods a call of `name` runs on values of some types
+function methodsOf(index: SymbolIndex, types: IndexedSymbol[], name: string): IndexedSymbol[] {
+  const methods = new Map<string, IndexedSymbol>()
+  for (const type of types) {
+    const method = findMethod(index, type, name)
+    if (method) methods.set(method.id, method)
+  }
+  return [...methods.values()]
+}
+
+/**
+ * Types of the values a function assigns to a local receiver, or null when
+ * some assignment is not a constructor call (or the receiver is no local)
+ */
+function assignedTypes(index: SymbolIndex, ref: SymbolReference, caller: IndexedSymbol): IndexedSymbol[] | null {
+  if (!ref.receiver) return null
+  const local = index.localAt(ref.path, ref.receiver, ref.line, ref.column)
+  if (!local || local.kind !== 'local') return null
+  const shard = index.getFile(ref.path)
+  if (!shard) return null
+  const lines = new Set([local.line])
+  for (const write of shard.references) {
+    if (write.role !== 'write' || write.name !== ref.receiver || write.line < caller.line || write.line > caller.end_line) continue
+    if (index.localAt(ref.path, write.name, write.line, write.column) === local) lines.add(write.line)
+  }
+  const types = new Map<string, IndexedSymbol>()
+  for (const line of lines) {
+    const created = shard.references
+      .filter(r => r.call && r.line === line && r !== ref)
+      .flatMap(r => resolveTypeName(index, r.name, ref.path))
+      .filter(t => TYPE_KINDS.has(t.kind))
+    if (created.length === 0) return null
+    for (const type of created) types.set(type.id, type)
+  }
+  return [...types.values()]
+}
+
+/**
+ * Resolve the targets of one call site
+ */
+export function resolveCall(
+  index: SymbolIndex,
+  ref: SymbolReference,
+  caller: IndexedSymbol,
+  algorithm: DispatchAlgorithm = 'name'
+): { targets: IndexedSymbol[], dynamic: boolean } {
+  return resolveDispatch(index, ref, caller, { algorithm, instantiated: new Map() })
+}
+
+function resolveDispatch(index: SymbolIndex, ref: SymbolReference, caller: IndexedSymbol, dispatch: Dispatch): { targets: IndexedSymbol[], dynamic: boolean } {
+  const narrow = dispatch.algorithm !== 'name'
+  const overrides = (method: IndexedSymbol): { targets: IndexedSymbol[], dynamic: boolean } => {
+    const owner = ownerType(index, method)
+    const targets = owner ? methodsOf(index, concreteTypes(index, owner, dispatch), ref.name) : []
+    return targets.length > 0 ? { targets, dynamic: targets.length > 1 } : { targets: [method], dynamic: false }
+  }
+
+  if (dispatch.algorithm === 'vta' && ref.receiver && !SELF_RECEIVERS.has(ref.receiver)) {
+    const types = assignedTypes(index, ref, caller)
+    const targets = types ? methodsOf(index, types, ref.name) : []
+    if (targets.length > 0) return { targets, dynamic: targets.length > 1 }
+  }
+
+  const target = index.checkedTarget(ref)
+  if (target !== undefined) {
+    const bound = target ? index.getSymbol(target) : undefined
+    if (!bound) return { targets: [], dynamic: false }
+    if (TYPE_KINDS.has(bound.kind)) {
+      const ctor = findMethod(index, bound, 'constructor')
+      return { targets: ctor ? [ctor] : [], dynamic: false }
+    }
+    if (narrow && CALLABLE_KINDS.has(bound.kind) && isMember(bound)) return overrides(bound)
+    if (ownerType(index, bound)?.kind !== 'interface') {
+      return { targets: CALLABLE_KINDS.has(bound.kind) ? [bound] : [], dynamic: false }
+    }
+  }
+
+  const candidates = index.findSymbols(ref.name)
+  const callables = candidates.filter(s => CALLABLE_KINDS.has(s.kind))
+
+  // `new Foo()` / `Foo()` on a type calls its constructor
+  if (callables.length === 0) {
+    const ctors = candidates
+      .filter(s => TYPE_KINDS.has(s.kind))
+      .map(t => findMethod(index, t, 'constructor'))
+      .filter((s): s is IndexedSymbol => !!s)
+    return { targets: ctors, dynamic: false }
+  }
+
+  if (ref.receiver && SELF_RECEIVERS.has(ref.receiver)) {
+    const owner = ownerType(index, caller)
+    const method = owner ? findMethod(index, owner, ref.name) : null
+    if (method) return narrow ? overrides(method) : { targets: [method], dynamic: false }
+  }
+
+  if (!ref.receiver) {
+    const functions = callables.filter(s => !isMember(s))
+    const local = functions.filter(s => s.path === ref.path)
+    if (local.length > 0) return { targets: local, dynamic: false }
+    if (functions.length > 0) return { targets: functions, dynamic: functions.length > 1 }
+  }
+
+  if (dispatch.algorithm === 'rta' || dispatch.algorithm === 'vta') {
+    const live = callables.filter(s => {
+      const owner = isMember(s) ? ownerType(index, s) : null
+      return !owner || concreteTypes(index, owner, dispatch).some(t => isInstantiated(index, t, dispatch) && findMethod(index, t, ref.name)?.id === s.id)
+    })
+    if (live.length > 0) return { targets: live, dynamic: live.length > 1 }
+  }
+
+  return { targets: callables, dynamic: callables.length > 1 }
+}
+
+/**
+ * Compute the call graph for the whole index
+ */
+export function computeCallGraph(index: SymbolIndex, algorithm: DispatchAlgorithm = 'name'): CallGraph {
+  const graph: CallGraph = { edges: [], callees: new Map(), callers: new Map() }
+  const dispatch: Dispatch = { algorithm, instantiated: new Map() }
+
+  for (const shard of index.listShards()) {
+    const callables = shard.symbols.filter(s => CALLABLE_KINDS.has(s.kind))
+    if (callables.length === 0) continue
+
+    for (const ref of shard.references) {
+      if (!ref.call) continue
+      const caller = enclosingCallable(callables, ref.line)
+      if (!caller) continue
+
+      const { targets, dynamic } = resolveDispatch(index, ref, caller, dispatch)
+      for (const target of targets) {
+        const edge: CallEdge = {
+          caller: caller.id,
+          callee: target.id,
+          path: ref.path,
+          line: ref.line,
+          column: ref.column,
+          dynamic,
+          ...(ref.caught ? { caught: true } : {})
+        }
+        graph.edges.push(edge)
+        if (!graph.callees.has(caller.id)) graph.callees.set(caller.id, [])
+        if (!graph.callers.has(target.id)) graph.callers.set(target.id, [])
+        graph.callees.get(caller.id)!.push(edge)
+        graph.callers.get(target.id)!.push(edge)
+      }
+    }
+  }
+
+  return graph
+}
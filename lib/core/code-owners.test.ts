@@ -0,0 +1,90 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import os from 'os'
+import path from 'path'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { SymbolIndex } from './symbol-index.js'
+import { querySymbols } from './symbol-query.js'
+import { selectSymbols } from './query-language.js'
+import { loadCodeOwners, ownedBy, ownerPattern, ownersResolver, parseCodeOwners } from './code-owners.js'
+
+const CODEOWNERS = `# Default owners
+*       @org/everyone
+*.ts    @org/frontend   # TypeScript
+/lib/   @org/platform-team  dev@example.com
+docs/*  @docs
+apps/   @org/apps
+/lib/generated/
+`
+
+test('code-owners: parses rules with their owners and line numbers', () => {
+  const rules = parseCodeOwners(CODEOWNERS)
+  assert.deepEqual(rules.map(r => [r.pattern, r.owners, r.line]), [
+    ['*', ['@org/everyone'], 2],
+    ['*.ts', ['@org/frontend'], 3],
+    ['/lib/', ['@org/platform-team', 'dev@example.com'], 4],
+    ['docs/*', ['@docs'], 5],
+    ['apps/', ['@org/apps'], 6],
+    ['/lib/generated/', [], 7]
+  ])
+})
+
+test('code-owners: patterns follow CODEOWNERS matching rules', () => {
+  assert.ok(ownerPattern('*.js')('src/deep/a.js'))
+  assert.ok(ownerPattern('/build/logs/')('build/logs/deep/x.log'))
+  assert.ok(!ownerPattern('/build/logs/')('src/build/logs/x.log'))
+  assert.ok(ownerPattern('apps/')('src/apps/main.ts'))
+  assert.ok(ownerPattern('docs/*')('docs/intro.md'))
+  assert.ok(!ownerPattern('docs/*')('docs/guide/intro.md'))
+  assert.ok(ownerPattern('lib/core')('lib/core/a.ts'))
+  assert.ok(ownerPattern('**/logs')('deep/logs/today.log'))
+})
+
+test('code-owners: the last matching rule wins and owner-less rules unassign', () => {
+  const owners = ownersResolver(parseCodeOwners(CODEOWNERS))
+  assert.deepEqual(owners('README.md'), ['@org/everyone'])
+  assert.deepEqual(owners('src/app.ts'), ['@org/frontend'])
+  assert.deepEqual(owners('lib/core/index.ts'), ['@org/platform-team', 'dev@example.com'])
+  assert.deepEqual(owners('lib/generated/api.ts'), [])
+  assert.deepEqual(owners('docs/intro.md'), ['@docs'])
+  assert.deepEqual(owners('docs/guide/intro.md'), ['@org/everyone'])
+  assert.deepEqual(owners('src/apps/main.ts'), ['@org/apps'])
+})
+
+test('code-owners: owners compare case-insensitively with an optional @', () => {
+  assert.ok(ownedBy(['@Org/Platform-Team'], ['@org/platform-team']))
+  assert.ok(ownedBy(['@org/platform-team'], ['org/platform-team']))
+  assert.ok(ownedBy(['Dev@Example.com'], ['dev@example.com']))
+  assert.ok(!ownedBy(['@org/apps'], ['@org/platform-team']))
+})
+
+test('code-owners: loadCodeOwners prefers .github/ over the root and docs/', async () => {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'codeowners-'))
+  try {
+    assert.equal(await loadCodeOwners(root), null)
+    await fs.writeFile(path.join(root, 'CODEOWNERS'), '* @root\n')
+    assert.equal((await loadCodeOwners(root))!.path, 'CODEOWNERS')
+    await fs.mkdir(path.join(root, '.github'))
+    await fs.writeFile(path.join(root, '.github', 'CODEOWNERS'), '* @github\n')
+    const owners = await loadCodeOwners(root)
+    assert.equal(owners!.path, '.github/CODEOWNERS')
+    assert.deepEqual(owners!.rules[0].owners, ['@github'])
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('code-owners: queries filter symbols by owner', () => {
+  const index = new SymbolIndex()
+  index.ownersOf = ownersResolver(parseCodeOwners(CODEOWNERS))
+  index.addFile('lib/api.ts', 'typescript', extractJSSymbols('export function serve() {}\nfunction helper() {}'))
+  index.addFile('src/app.ts', 'typescript', extractJSSymbols('export function main() {}'))
+
+  const names = (symbols: { name: string }[]) => symbols.map(s => s.name)
+  assert.deepEqual(names(querySymbols(index, { exported: true, owners: ['@org/platform-team'] })), ['serve'])
+  assert.deepEqual(names(querySymbols(index, { owners: ['@org/frontend', 'dev@example.com'] })), ['serve', 'helper', 'main'])
+  assert.deepEqual(names(selectSymbols(index, 'exported AND owners.contains("@org/platform-team")')), ['serve'])
+  assert.deepEqual(index.owners('src/app.ts'), ['@org/frontend'])
+  assert.deepEqual(index.fork().owners('src/app.ts'), ['@org/frontend'])
+})
@@ -0,0 +1,97 @@
+/**
+ * Code Owners Module
+ * Reads the project's CODEOWNERS file (.github/, root or docs/, the first
+ * one found, as GitHub does) and answers who owns a file. Patterns follow
+ * the CODEOWNERS flavour of gitignore syntax and the last matching line
+ * wins; a line with a pattern and no owners leaves its files unowned.
+ *
+ * Ownership is resolved when the index is opened rather than stored in the
+ * shards, so editing CODEOWNERS never requires a re-index.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { minimatch } from 'minimatch'
+
+export const CODEOWNERS_PATHS = ['.github/CODEOWNERS', 'CODEOWNERS', 'docs/CODEOWNERS']
+
+export interface OwnerRule {
+  pattern: string // as written
+  owners: string[] // @user, @org/team or an email address
+  line: number
+}
+
+export interface CodeOwners {
+  path: string // relative to the project root
+  rules: OwnerRule[]
+}
+
+/**
+ * Parse CODEOWNERS content; comments and blank lines are skipped
+ */
+export function parseCodeOwners(content: string): OwnerRule[] {
+  const rules: OwnerRule[] = []
+  content.split(/\r?\n/).forEach((raw, i) => {
+    // "\#" and "\ " escape a literal hash or space in a pattern
+    const text = raw.replace(/(^|[^\\])#.*$/, '$1').trim()
+    if (!text) return
+    const [pattern, ...owners] = text.split(/(?<!\\)\s+/)
+    rules.push({ pattern: pattern.replace(/\\([# ])/g, '$1'), owners, line: i + 1 })
+  })
+  return rules
+}
+
+/**
+ * Compile a CODEOWNERS pattern to a file path predicate. A pattern without
+ * a slash (other than a trailing one) matches at any depth, a leading slash
+ * anchors it at the root, a trailing slash or a plain name matches
+ * everything below a directory, and "docs/*" stops at direct children.
+ */
+export function ownerPattern(pattern: string): (relPath: string) => boolean {
+  let p = pattern
+  const directory = p.endsWith('/')
+  p = p.replace(/\/+$/, '')
+  const anchored = p.startsWith('/') || p.includes('/')
+  p = p.replace(/^\/+/, '')
+  if (!anchored) p = `**/${p}`
+  const globs = directory ? [`${p}/**`] : /[*?[]/.test(p.slice(p.lastIndexOf('/') + 1)) ? [p] : [p, `${p}/**`]
+  return (relPath) => globs.some(glob => minimatch(relPath, glob, { dot: true }))
+}
+
+/**
+ * Resolver from a file path to its owners, by the last matching rule
+ */
+export function ownersResolver(rules: OwnerRule[]): (relPath: string) => string[] {
+  const compiled = rules.map(rule => ({ owners: rule.owners, matches: ownerPattern(rule.pattern) })).reverse()
+  const cache = new Map<string, string[]>()
+  return (relPath) => {
+    const normalized = relPath.replace(/\\/g, '/')
+    let owners = cache.get(normalized)
+    if (!owners) {
+      owners = compiled.find(rule => rule.matches(normalized))?.owners || []
+      cache.set(normalized, owners)
+    }
+    return owners
+  }
+}
+
+/**
+ * The project's CODEOWNERS file, or null if it has none
+ */
+export async function loadCodeOwners(projectRoot: string): Promise<CodeOwners | null> {
+  for (const relPath of CODEOWNERS_PATHS) {
+    const content = await fs.readFile(path.join(projectRoot, relPath), 'utf8').catch(() => null)
+    if (content !== null) return { path: relPath, rules: parseCodeOwners(content) }
+  }
+  return null
+}
+
+/**
+ * Whether an owner list includes one of the wanted owners; handles and
+ * emails compare case-insensitively and the leading "@" is optional
+ */
+export function ownedBy(owners: string[], wanted: string[]): boolean {
+  const normalize = (owner: string) => owner.replace(/^@/, '').toLowerCase()
+  const set = new Set(owners.map(normalize))
+  return wanted.some(owner => set.has(normalize(owner)))
+}
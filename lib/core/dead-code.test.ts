@@ -0,0 +1,56 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { findDeadCode, parseAllowlist } from './dead-code.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/service.ts': `
+export class Service {
+  constructor() {
+    this.status()
+  }
+  status() {
+    return this.helper()
+  }
+  helper() {
+    return 1
+  }
+  run() {
+    return 'ok'
+  }
+}
+
+function countdown(n) {
+  return n > 0 ? countdown(n - 1) : 0
+}
+
+export function legacy() {}
+`,
+  'src/main.ts': `
+import { Service } from './service'
+export function main() {
+  return new Service()
+}
+`
+}
+
+function names(symbols: { name: string }[]): string[] {
+  return symbols.map(s => s.name).sort()
+}
+
+test('dead-code: reports symbols unreferenced outside their declaration', () => {
+  const index = createIndex(FILES)
+  // countdown only calls itself; main and constructors are entry points
+  assert.deepEqual(names(findDeadCode(index)), ['Service.run', 'countdown', 'legacy'])
+  assert.deepEqual(names(findDeadCode(index, { exported: true })), ['Service.run', 'legacy'])
+  assert.deepEqual(names(findDeadCode(index, { exported: false })), ['countdown'])
+  assert.deepEqual(names(findDeadCode(index, { kinds: ['function'] })), ['countdown', 'legacy'])
+})
+
+test('dead-code: allowlist globs match names and paths', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(names(findDeadCode(index, { allow: ['Service.*', 'count*'] })), ['legacy'])
+  assert.deepEqual(names(findDeadCode(index, { allow: ['run'] })), ['countdown', 'legacy'])
+  assert.deepEqual(findDeadCode(index, { allow: ['src/**'] }), [])
+  assert.deepEqual(parseAllowlist('# generated\nsrc/gen/**\n\n  legacy  \n'), ['src/gen/**', 'legacy'])
+})
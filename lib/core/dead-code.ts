@@ -0,0 +1,91 @@
+/**
+ * Dead Code Module
+ * Analysis pass that reports symbols with no references outside their own
+ * declaration. References resolve by name, so the report errs on the side of
+ * keeping code: any use of a same-named symbol counts. Entry points the
+ * runtime calls, test functions and allowlisted names are never reported.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { minimatch } from 'minimatch'
+import { shortName } from './symbol-index.js'
+import { testKindOf } from './test-map.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+/** Kinds that are not declarations of their own */
+const SKIPPED_KINDS = new Set(['import', 'export', 'reference', 'default_export', 'unity_lifecycle', 'unknown'])
+
+/** Names invoked by the runtime or language rather than by project code */
+const ENTRY_POINT_NAMES = new Set(['main', 'Main', 'constructor', '__init__', '__main__'])
+
+export interface DeadCodeOptions {
+  /** Name or path globs to leave out of the report */
+  allow?: string[]
+  /** Only exported (true) or only unexported (false) symbols */
+  exported?: boolean
+  /** Restrict to these kinds */
+  kinds?: string[]
+}
+
+/**
+ * Whether an allowlist pattern covers a symbol. Patterns with a slash match
+ * the file path, others the qualified or short name ("User.*", "test*").
+ */
+export function isAllowed(sym: IndexedSymbol, patterns: string[]): boolean {
+  return patterns.some(pattern => pattern.includes('/')
+    ? minimatch(sym.path, pattern, { dot: true })
+    : minimatch(sym.name, pattern) || minimatch(shortName(sym.name), pattern)
+  )
+}
+
+/**
+ * Whether anything refers to a symbol other than from inside its own body
+ * (recursion, a class naming itself); stops at the first such reference
+ */
+function hasOutsideReference(index: SymbolIndex, sym: IndexedSymbol): boolean {
+  for (const ref of index.iterReferences(sym.id)) {
+    if (ref.path !== sym.path || ref.line < sym.line || ref.line > sym.end_line) return true
+  }
+  return false
+}
+
+/**
+ * Symbols nothing in the index refers to, sorted by file and line
+ */
+export function findDeadCode(index: SymbolIndex, options: DeadCodeOptions = {}): IndexedSymbol[] {
+  const allow = options.allow || []
+  const kinds = options.kinds && options.kinds.length > 0 ? new Set(options.kinds) : null
+  return index.allSymbols()
+    .filter(sym => !SKIPPED_KINDS.has(sym.kind) && !ENTRY_POINT_NAMES.has(shortName(sym.name)))
+    // Test runners call tests, benchmarks and examples
+    .filter(sym => !testKindOf(sym))
+    .filter(sym => !sym.external)
+    .filter(sym => !kinds || kinds.has(sym.kind))
+    .filter(sym => options.exported === undefined || !!sym.exported === options.exported)
+    .filter(sym => !isAllowed(sym, allow))
+    .filter(sym => !hasOutsideReference(index, sym))
+    .sort((a, b) => a.path.localeCompare(b.path) || a.line - b.line)
+}
+
+/**
+ * Parse allowlist text: one glob per line, lines starting with # are comments
+ */
+export function parseAllowlist(text: string): string[] {
+  return text.split('\n')
+    .map(line => line.trim())
+    .filter(line => line && !line.startsWith('#'))
+}
+
+/**
+ * Load the project allowlist from .indexer/deadcode-allow (empty if missing)
+ */
+export async function loadAllowlist(projectRoot: string, file = path.join(projectRoot, '.indexer', 'deadcode-allow')): Promise<string[]> {
+  try {
+    return parseAllowlist(await fs.readFile(file, 'utf8'))
+  } catch (e: any) {
+    if (e.code === 'ENOENT') return []
+    throw e
+  }
+}
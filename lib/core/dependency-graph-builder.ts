@@ -1,8 +1,7 @@
 import path from 'path'
 import fs from 'fs/promises'
 import crypto from 'crypto'
-import { extractImports as extractJSImports } from '../utils/ast-js.js'
-import { extractPythonImports, extractCSharpImports } from '../utils/tree-sitter.js'
+import { getLanguageBackend, type ImportInfo } from '../utils/language-backends.js'
 import { resolveImportPath } from '../utils/path-resolver.js'
 import {
   saveNodes,
@@ -69,20 +68,12 @@ async function processFile(
   const lang = detectLanguage(filePath)
 
   // Skip unsupported languages
-  if (lang !== 'javascript' && lang !== 'typescript' && lang !== 'python' && lang !== 'csharp') {
+  const backend = getLanguageBackend(lang)
+  if (!backend) {
     return null
   }
 
-  // Extract imports based on language
-  let imports: any[] = []
-
-  if (lang === 'javascript' || lang === 'typescript') {
-    imports = extractJSImports(content)
-  } else if (lang === 'python') {
-    imports = await extractPythonImports(content)
-  } else if (lang === 'csharp') {
-    imports = await extractCSharpImports(content)
-  }
+  const imports: ImportInfo[] = await backend.extractImports(content)
 
   // Create node
   const node: Omit<DependencyNode, 'collection_id'> = {
@@ -0,0 +1,75 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import { SymbolIndex, indexFileContents, makeSymbolId } from './symbol-index.js'
+import { querySymbols } from './symbol-query.js'
+import { findDeprecatedUses } from './deprecations.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const API_SRC = `/**
+ * Save a user.
+ * @deprecated Use saveAll instead
+ */
+export function save(u) {
+  return save(u.next)
+}
+
+/**
+ * Load a user.
+ *
+ * Deprecated: loading is implicit now.
+ */
+export function load() {}
+
+/**
+ * Drop it.
+ * @deprecated
+ */
+export function drop() {}
+
+export function saveAll() {}
+`
+
+const APP_SRC = `import { save, load } from './api'
+
+export function run(u) {
+  save(u)
+  load()
+  saveAll()
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/api.ts': API_SRC,
+  'src/app.ts': APP_SRC
+}
+
+test('deprecations: every use of a deprecated symbol', () => {
+  const report = findDeprecatedUses(createIndex(FILES))
+  assert.deepEqual(report.map(d => [d.symbol.name, d.notice, d.uses.map(u => `${u.path}:${u.line}`)]), [
+    ['save', 'Use saveAll instead', ['src/app.ts:1', 'src/app.ts:4']],
+    ['load', 'loading is implicit now.', ['src/app.ts:1', 'src/app.ts:5']],
+    ['drop', '', []]
+  ])
+})
+
+test('deprecations: query filter', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(querySymbols(index, { deprecated: true }).map(s => s.name), ['save', 'load', 'drop'])
+  assert.deepEqual(querySymbols(index, { deprecated: false, packages: ['./src'] }).map(s => s.name), ['saveAll', 'run'])
+})
+
+test('deprecations: C# [Obsolete] and Python @deprecated', async () => {
+  await initTreeSitter()
+  const index = new SymbolIndex()
+  await indexFileContents(index, ['Game/Player.cs', 'svc/api.py', 'svc/main.py'], [
+    'public class Player {\n    [Obsolete("Use Move")]\n    public void Walk() {}\n    public void Move() { Walk(); }\n}\n',
+    'from typing_extensions import deprecated\n\n@deprecated("use fetch")\ndef get(url):\n    pass\n',
+    'from api import get\n\nget("/")\n'
+  ])
+  const report = findDeprecatedUses(index)
+  assert.deepEqual(report.map(d => [d.symbol.id, d.notice, d.uses.map(u => `${u.path}:${u.line}`)]), [
+    [makeSymbolId('Game/Player.cs', 'Player.Walk'), 'Use Move', ['Game/Player.cs:4']],
+    [makeSymbolId('svc/api.py', 'get'), '', ['svc/main.py:1', 'svc/main.py:3']]
+  ])
+})
@@ -0,0 +1,47 @@
+/**
+ * Deprecations Module
+ * Analysis pass that lists deprecated symbols and every use of them, for
+ * planning migrations off old APIs. A symbol is deprecated by its doc comment
+ * (JSDoc @deprecated, a godoc-style "Deprecated:" paragraph, Sphinx
+ * ".. deprecated::"), by C# [Obsolete] or by a Python @deprecated decorator
+ * (PEP 702). Uses resolve by name like every other reference query.
+ */
+
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol, Location } from '../types/index.js'
+
+const DEPRECATED_DECORATORS = new Set(['deprecated', 'typing_extensions.deprecated', 'warnings.deprecated'])
+
+export interface DeprecatedSymbol {
+  symbol: IndexedSymbol
+  notice: string // deprecation text ('' when none was given)
+  uses: Location[] // references outside the symbol's own declaration, by location
+}
+
+/**
+ * Deprecation notice of a symbol, or undefined if it is not deprecated
+ */
+export function deprecationNotice(sym: IndexedSymbol): string | undefined {
+  if (sym.doc_info?.deprecated !== undefined) return sym.doc_info.deprecated
+  if ((sym.decorators || []).some((d: string) => DEPRECATED_DECORATORS.has(d))) return ''
+  return undefined
+}
+
+/**
+ * Every deprecated symbol with its uses, sorted by location. Uses inside the
+ * deprecated symbol's own body are not counted.
+ */
+export function findDeprecatedUses(index: SymbolIndex): DeprecatedSymbol[] {
+  const result: DeprecatedSymbol[] = []
+  for (const sym of index.allSymbols()) {
+    const notice = deprecationNotice(sym)
+    if (notice === undefined) continue
+    const uses = index.references(sym.id).filter(ref =>
+      ref.path !== sym.path || ref.line < sym.line || ref.line > sym.end_line
+    )
+    result.push({ symbol: sym, notice, uses })
+  }
+  return result.sort((a, b) =>
+    a.symbol.path.localeCompare(b.symbol.path) || a.symbol.line - b.symbol.line
+  )
+}
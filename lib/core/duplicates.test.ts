@@ -0,0 +1,55 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { findDuplicates } from './duplicates.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const body = (items: string, total: string, extra = '') => `{
+  let ${total} = 0
+  for (const item of ${items}) {
+    if (item.price > 100 && item.active) {
+      ${total} += item.price * item.quantity
+    } else if (item.discount) {
+      ${total} += (item.price - item.discount) * item.quantity
+    }
+  }
+  const tax = ${total} * 0.2
+  console.log('total', ${total}, tax)${extra}
+  return ${total} + tax
+}`
+
+const FILES: FixtureFiles = {
+  'src/orders.ts': `
+export function orderTotal(items: Item[]): number ${body('items', 'sum')}
+export function small(a: number) { return a + 1 }
+`,
+  'src/invoices.ts': `
+export class Invoice {
+  total(lines: Line[]): number ${body('lines', 'amount')}
+}
+export const cartTotal = (cart: Item[]) => ${body('cart', 'acc', `
+  if (tax > 1000) {
+    console.warn('large order', acc)
+  }`)}
+`
+}
+
+const names = (groups: ReturnType<typeof findDuplicates>) => groups.map(g => [g.exact, g.symbols.map(s => s.name)])
+
+test('duplicates: groups structurally identical bodies with renamed names', () => {
+  const groups = findDuplicates(createIndex(FILES), { minSize: 20, exact: true })
+  assert.deepEqual(names(groups), [[true, ['Invoice.total', 'orderTotal']]])
+  assert.equal(groups[0].similarity, 1)
+})
+
+test('duplicates: near-duplicates join the group of their closest copies', () => {
+  const groups = findDuplicates(createIndex(FILES), { minSize: 20, similarity: 0.6 })
+  assert.deepEqual(names(groups), [[false, ['Invoice.total', 'cartTotal', 'orderTotal']]])
+  assert.ok(groups[0].similarity >= 0.6 && groups[0].similarity < 1)
+})
+
+test('duplicates: minimum size and kinds filter the candidates', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(findDuplicates(index, { minSize: 10000 }), [])
+  assert.deepEqual(names(findDuplicates(index, { minSize: 20, kinds: ['function', 'method'] })), [[true, ['Invoice.total', 'orderTotal']]])
+  assert.deepEqual(names(findDuplicates(index, { minSize: 20, packages: ['./lib/...'] })), [])
+})
@@ -0,0 +1,135 @@
+/**
+ * Duplicates Module
+ * Analysis pass that groups functions and methods with structurally
+ * identical or near-identical bodies, from the fingerprints computed while
+ * indexing. Identical means the same sequence of AST node types (names and
+ * literal values may differ); near-identical pairs are found by locality
+ * sensitive hashing over the MinHash signatures and kept when the estimated
+ * similarity reaches the threshold.
+ */
+
+import { matchesPackage } from './symbol-query.js'
+import { minhashSimilarity } from '../utils/fingerprint.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export const DEFAULT_MIN_SIZE = 50 // AST nodes
+export const DEFAULT_SIMILARITY = 0.8
+const BAND_ROWS = 4 // MinHash values per LSH band
+
+export interface DuplicateOptions {
+  /** Smallest body to report, in AST nodes */
+  minSize?: number
+  /** Lowest estimated similarity (0-1) of near-duplicates */
+  similarity?: number
+  /** Only structurally identical bodies */
+  exact?: boolean
+  /** Restrict to these kinds */
+  kinds?: string[]
+  /** Restrict to these package patterns (see matchesPackage) */
+  packages?: string[]
+}
+
+export interface DuplicateGroup {
+  exact: boolean // every body has the same structure
+  similarity: number // lowest estimated similarity between linked bodies; 1 when exact
+  size: number // AST nodes of the largest body
+  symbols: IndexedSymbol[] // by path and line
+}
+
+function byLocation(a: IndexedSymbol, b: IndexedSymbol): number {
+  if (a.path !== b.path) return a.path < b.path ? -1 : 1
+  return a.line - b.line
+}
+
+// Whether `inner` is declared inside the body of `outer`
+function nestedIn(inner: IndexedSymbol, outer: IndexedSymbol): boolean {
+  return inner !== outer && inner.path === outer.path && inner.line >= outer.line && inner.end_line <= outer.end_line
+}
+
+/**
+ * Groups of duplicated function bodies, largest first
+ */
+export function findDuplicates(index: SymbolIndex, options: DuplicateOptions = {}): DuplicateGroup[] {
+  const minSize = options.minSize ?? DEFAULT_MIN_SIZE
+  const threshold = options.similarity ?? DEFAULT_SIMILARITY
+  const kinds = options.kinds && options.kinds.length > 0 ? new Set(options.kinds) : null
+  const packages = options.packages && options.packages.length > 0 ? options.packages : null
+
+  const candidates = index.allSymbols().filter(sym =>
+    typeof sym.body_hash === 'string' && sym.body_size >= minSize &&
+    (!kinds || kinds.has(sym.kind)) &&
+    (!packages || packages.some(p => matchesPackage(sym.path, p)))
+  )
+
+  // Identical bodies share a hash; one representative per hash goes on to LSH
+  const byHash = new Map<string, IndexedSymbol[]>()
+  for (const sym of candidates) {
+    const group = byHash.get(sym.body_hash)
+    if (group) group.push(sym)
+    else byHash.set(sym.body_hash, [sym])
+  }
+  const reps = [...byHash.values()].map(group => group[0])
+
+  // Union-find over representatives linked by near-duplicate pairs
+  const parent = reps.map((_, i) => i)
+  const find = (i: number): number => (parent[i] === i ? i : (parent[i] = find(parent[i])))
+  const weakest = new Map<number, number>() // root -> lowest similarity of a link in its set
+  if (!options.exact) {
+    const buckets = new Map<string, number[]>()
+    reps.forEach((sym, i) => {
+      const minhash: number[] = sym.body_minhash || []
+      for (let band = 0; band * BAND_ROWS < minhash.length; band++) {
+        const key = `${band}:${minhash.slice(band * BAND_ROWS, (band + 1) * BAND_ROWS).join(',')}`
+        const bucket = buckets.get(key)
+        if (bucket) bucket.push(i)
+        else buckets.set(key, [i])
+      }
+    })
+    const seen = new Set<string>()
+    for (const bucket of buckets.values()) {
+      for (let x = 0; x < bucket.length; x++) {
+        for (let y = x + 1; y < bucket.length; y++) {
+          const [a, b] = [bucket[x], bucket[y]]
+          const pair = `${a},${b}`
+          if (seen.has(pair)) continue
+          seen.add(pair)
+          const [sa, sb] = [reps[a], reps[b]]
+          // A body inside another one shares its shingles without being a copy
+          if (nestedIn(sa, sb) || nestedIn(sb, sa)) continue
+          if (Math.min(sa.body_size, sb.body_size) / Math.max(sa.body_size, sb.body_size) < threshold) continue
+          const similarity = minhashSimilarity(sa.body_minhash, sb.body_minhash)
+          if (similarity < threshold) continue
+          const [ra, rb] = [find(a), find(b)]
+          const low = Math.min(similarity, weakest.get(ra) ?? 1, weakest.get(rb) ?? 1)
+          if (ra !== rb) parent[ra] = rb
+          weakest.set(rb, low)
+        }
+      }
+    }
+  }
+
+  const components = new Map<number, number[]>()
+  reps.forEach((_, i) => {
+    const root = find(i)
+    const members = components.get(root)
+    if (members) members.push(i)
+    else components.set(root, [i])
+  })
+
+  const groups: DuplicateGroup[] = []
+  for (const [root, members] of components) {
+    const symbols = members.flatMap(i => byHash.get(reps[i].body_hash)!)
+      // Drop functions nested in another member: the outer copy covers them
+      .filter((sym, _, all) => !all.some(other => nestedIn(sym, other)))
+    if (symbols.length < 2) continue
+    const exact = members.length === 1
+    groups.push({
+      exact,
+      similarity: exact ? 1 : weakest.get(root) ?? threshold,
+      size: Math.max(...symbols.map(s => s.body_size)),
+      symbols: symbols.sort(byLocation)
+    })
+  }
+  return groups.sort((a, b) => b.size - a.size || byLocation(a.symbols[0], b.symbols[0]))
+}
@@ -0,0 +1,57 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { selectSymbols } from './query-language.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'lib/types.ts': `
+export enum Perm {
+  None,
+  Read = 1 << 0,
+  Write = 1 << 1,
+  All = Read | Write,
+  Next
+}
+export enum Color { Red = 'red', Green = 'gr' + 'een', Blue = Color.Red }
+export type Status = 'active' | 'inactive' | 'banned'
+export const Banned: Status = 'banned'
+`,
+  'lib/extra.ts': `
+export const Pending = 'pending' satisfies Status
+export const limit: number = 10
+`
+}
+
+const values = (index: SymbolIndex, typeName: string) =>
+  index.enumValues(index.findSymbols(typeName)[0].id).map(v => [v.name, v.value, v.symbol?.path])
+
+test('enum-sets: numbers members like iota and evaluates initializers', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(values(index, 'Perm'), [
+    ['None', 0, 'lib/types.ts'],
+    ['Read', 1, 'lib/types.ts'],
+    ['Write', 2, 'lib/types.ts'],
+    ['All', 3, 'lib/types.ts'],
+    ['Next', 4, 'lib/types.ts']
+  ])
+  assert.deepEqual(values(index, 'Color'), [
+    ['Red', 'red', 'lib/types.ts'],
+    ['Green', 'green', 'lib/types.ts'],
+    ['Blue', 'red', 'lib/types.ts']
+  ])
+  const read = index.findSymbols('Perm.Read')[0]
+  assert.deepEqual([read.kind, read.exported, read.const_value], ['constant', true, 1])
+})
+
+test('enum-sets: union literals and constants typed as them', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(values(index, 'Status'), [
+    ['active', 'active', undefined],
+    ['inactive', 'inactive', undefined],
+    ['Banned', 'banned', 'lib/types.ts'],
+    ['Pending', 'pending', 'lib/extra.ts']
+  ])
+  assert.deepEqual(index.enumValues(index.findSymbols('limit')[0].id), [])
+  assert.deepEqual(selectSymbols(index, 'values.count >= 4').map(s => s.name), ['Perm', 'Status'])
+})
@@ -0,0 +1,79 @@
+/**
+ * Enum Sets Module
+ * Analysis pass that gathers the values of enumerated types: the members of
+ * an enum (numbered by position when they have no initializer, as Go's iota
+ * numbers a const block), the literals of a union type alias
+ * (type Status = 'active' | 'inactive'), and the constants declared with
+ * one of those types (const Active: Status = 'active'), wherever they live.
+ */
+
+import path from 'path'
+import { shortName } from './symbol-index.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export type EnumLiteral = string | number | boolean
+
+export interface EnumValue {
+  name: string // member or constant name ("Active"), or the literal itself for union members
+  value?: EnumLiteral // undefined when it is not a constant expression
+  symbol?: IndexedSymbol // declaring constant; absent for union literals no constant names
+}
+
+// Kinds whose values can be enumerated
+export const ENUM_KINDS = new Set(['enum', 'type'])
+
+/**
+ * The type a typed constant belongs to: same file first, then same
+ * directory, then a single match anywhere
+ */
+function resolveValueType(index: SymbolIndex, sym: IndexedSymbol): IndexedSymbol | undefined {
+  const candidates = index.findSymbols(sym.value_type).filter(s => ENUM_KINDS.has(s.kind))
+  const local = candidates.filter(s => s.path === sym.path)
+  if (local.length > 0) return local[0]
+  const dir = path.posix.dirname(sym.path)
+  const sibling = candidates.filter(s => path.posix.dirname(s.path) === dir)
+  if (sibling.length > 0) return sibling[0]
+  return candidates.length === 1 ? candidates[0] : undefined
+}
+
+function memberValue(sym: IndexedSymbol, name = shortName(sym.name)): EnumValue {
+  return { name, ...(sym.const_value !== undefined ? { value: sym.const_value } : {}), symbol: sym }
+}
+
+/**
+ * Values of every enumerable type, keyed by type id, in declaration order
+ * (members, then union literals, then typed constants by location)
+ */
+export function computeEnumSets(index: SymbolIndex): Map<string, EnumValue[]> {
+  const sets = new Map<string, EnumValue[]>()
+
+  for (const shard of index.listShards()) {
+    for (const sym of shard.symbols) {
+      if (sym.kind === 'enum') {
+        const prefix = `${sym.name}.`
+        const members = shard.symbols.filter(s => s.kind === 'constant' && s.name.startsWith(prefix) && !s.name.slice(prefix.length).includes('.'))
+        sets.set(sym.id, members.map(m => memberValue(m)))
+      } else if (sym.kind === 'type' && Array.isArray(sym.literal_values)) {
+        sets.set(sym.id, sym.literal_values.map((value: EnumLiteral) => ({ name: String(value), value })))
+      }
+    }
+  }
+
+  for (const shard of index.listShards()) {
+    for (const sym of shard.symbols) {
+      if (typeof sym.value_type !== 'string') continue
+      const type = resolveValueType(index, sym)
+      if (!type) continue
+      const values = sets.get(type.id) || []
+      sets.set(type.id, values)
+      // A constant naming one of the union's literals takes its place
+      const literal = sym.const_value === undefined
+        ? -1
+        : values.findIndex(v => !v.symbol && v.value === sym.const_value)
+      if (literal !== -1) values[literal] = memberValue(sym, sym.name)
+      else values.push(memberValue(sym, sym.name))
+    }
+  }
+  return sets
+}
@@ -0,0 +1,92 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { SymbolIndex } from './symbol-index.js'
+import { querySymbols } from './symbol-query.js'
+import { parseQuery, selectSymbols } from './query-language.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const SERVICE_SRC = `export function parse(text) {
+  if (!text) throw new ValidationError('empty')
+  return JSON.parse(text)
+}
+
+export function load(text) {
+  try {
+    return parse(text)
+  } catch (err) {
+    throw new LoadError('cannot load', { cause: err })
+  }
+}
+
+export function safeLoad(text) {
+  try {
+    return parse(text)
+  } catch {
+    return null
+  }
+}
+
+export function handler(text) {
+  return format(load(text))
+}
+
+export function format(value) {
+  const show = () => { throw new Error('nested') }
+  return String(value)
+}
+`
+
+// What the extractor records for SERVICE_SRC
+const FILES: FixtureFiles = {
+  'src/service.ts': [
+    { name: 'parse', kind: 'function', line: 1, end_line: 4, throws: ['ValidationError'] },
+    { name: 'load', kind: 'function', line: 6, end_line: 12, throws: ['LoadError'], wraps: ['LoadError'] },
+    { name: 'safeLoad', kind: 'function', line: 14, end_line: 20 },
+    { name: 'handler', kind: 'function', line: 22, end_line: 24 },
+    { name: 'format', kind: 'function', line: 26, end_line: 29 },
+    { name: 'ValidationError', kind: 'reference', line: 2, column: 27, call: true },
+    { name: 'parse', kind: 'reference', line: 8, column: 12, call: true, caught: true },
+    { name: 'LoadError', kind: 'reference', line: 10, column: 15, call: true },
+    { name: 'parse', kind: 'reference', line: 16, column: 12, call: true, caught: true },
+    { name: 'format', kind: 'reference', line: 23, column: 10, call: true },
+    { name: 'load', kind: 'reference', line: 23, column: 17, call: true }
+  ]
+}
+
+function idOf(index: SymbolIndex, name: string): string {
+  return index.findSymbols(name).find(s => s.name === name)!.id
+}
+
+test('error-paths: throws escape through uncaught calls only', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(querySymbols(index, { throws: true }).map(s => s.name), ['parse', 'load', 'handler'])
+  assert.deepEqual(querySymbols(index, { throws: false }).map(s => s.name), ['safeLoad', 'format'])
+
+  const path = index.canThrow(idOf(index, 'handler'))!
+  assert.equal(path.thrower.name, 'load')
+  assert.deepEqual(path.types, ['LoadError'])
+  assert.deepEqual(path.calls.map(e => [index.getSymbol(e.callee)!.name, e.line]), [['load', 23]])
+  assert.deepEqual(index.canThrow(idOf(index, 'parse'))!.calls, [])
+  assert.equal(index.canThrow(idOf(index, 'safeLoad')), null)
+})
+
+test('error-paths: escaping errors and wrapping', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.escapingErrors(idOf(index, 'handler')), ['LoadError'])
+  assert.deepEqual(index.errorWrapsInto('LoadError').map(w => [w.symbol.name, w.types]), [['load', ['LoadError']]])
+  assert.deepEqual(index.errorWrapsInto('Validation*'), [])
+  assert.deepEqual(querySymbols(index, { wraps: '*Error' }).map(s => s.name), ['load'])
+  assert.deepEqual(selectSymbols(index, parseQuery('canThrow AND NOT wraps("LoadError")')).map(s => s.name), ['parse', 'handler'])
+})
+
+test('error-paths: the extractor records uncaught throws, wraps and guarded calls', () => {
+  const symbols = extractJSSymbols(SERVICE_SRC)
+  const fn = (name: string) => symbols.find(s => s.name === name && s.kind === 'function')!
+  assert.deepEqual([fn('parse').throws, fn('load').throws, fn('load').wraps], [['ValidationError'], ['LoadError'], ['LoadError']])
+  assert.equal(fn('safeLoad').throws, undefined)
+  // The arrow function's throw is its own
+  assert.equal(fn('format').throws, undefined)
+  const calls = symbols.filter(s => s.kind === 'reference' && s.name === 'parse' && s.call)
+  assert.deepEqual(calls.map(c => [c.line, c.caught]), [[8, true], [16, true]])
+})
@@ -0,0 +1,106 @@
+/**
+ * Error Paths Module
+ * Analysis pass for reliability reviews: which functions can throw, either
+ * directly (a throw / raise their body does not catch) or through the call
+ * graph (a call, outside any try that catches, to a function that can
+ * throw), and which functions wrap errors into a given type by constructing
+ * it with a cause. A function that can throw gets the shortest call chain to
+ * a direct thrower as the witness. Like the call graph, this is best-effort:
+ * dynamic calls count, and a catch is assumed to handle every error.
+ */
+
+import { namePattern } from './symbol-query.js'
+import type { CallEdge } from './call-graph.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export interface ThrowPath {
+  thrower: IndexedSymbol // function whose own body throws
+  types: string[] // what the thrower throws ("*" when the type is unknown)
+  calls: CallEdge[] // from the queried function down to the thrower; empty when it throws itself
+}
+
+export interface ErrorWrap {
+  symbol: IndexedSymbol
+  types: string[] // error types it constructs with a cause
+}
+
+/** Next call toward a direct thrower, by function ID (null for the throwers themselves) */
+export type ThrowTable = Map<string, CallEdge | null>
+
+function throwsDirectly(sym: IndexedSymbol): boolean {
+  return Array.isArray(sym.throws) && sym.throws.length > 0
+}
+
+/**
+ * Breadth-first from the direct throwers back along uncaught calls, so each
+ * function keeps its shortest route
+ */
+export function computeThrowTable(index: SymbolIndex): ThrowTable {
+  const table: ThrowTable = new Map()
+  const queue: string[] = []
+  for (const sym of index.allSymbols()) {
+    if (!throwsDirectly(sym)) continue
+    table.set(sym.id, null)
+    queue.push(sym.id)
+  }
+  const { callers } = index.callGraph()
+  for (let i = 0; i < queue.length; i++) {
+    for (const edge of callers.get(queue[i]) || []) {
+      if (edge.caught || table.has(edge.caller)) continue
+      table.set(edge.caller, edge)
+      queue.push(edge.caller)
+    }
+  }
+  return table
+}
+
+/**
+ * How a function can throw, or null when nothing it does escapes
+ */
+export function throwPath(index: SymbolIndex, table: ThrowTable, fnId: string): ThrowPath | null {
+  if (!table.has(fnId)) return null
+  const calls: CallEdge[] = []
+  let id = fnId
+  for (let edge = table.get(id); edge; edge = table.get(id)) {
+    calls.push(edge)
+    id = edge.callee
+  }
+  const thrower = index.getSymbol(id)
+  return thrower ? { thrower, types: thrower.throws, calls } : null
+}
+
+/**
+ * Error types that can escape a function: its own uncaught throws and
+ * those of every function it reaches through uncaught calls
+ */
+export function escapingErrors(index: SymbolIndex, fnId: string): string[] {
+  const { callees } = index.callGraph()
+  const types = new Set<string>()
+  const seen = new Set([fnId])
+  const stack = [fnId]
+  while (stack.length > 0) {
+    const id = stack.pop()!
+    for (const type of index.getSymbol(id)?.throws || []) types.add(type)
+    for (const edge of callees.get(id) || []) {
+      if (edge.caught || seen.has(edge.callee)) continue
+      seen.add(edge.callee)
+      stack.push(edge.callee)
+    }
+  }
+  return [...types].sort()
+}
+
+/**
+ * Functions that wrap errors into a type (a name pattern, see namePattern),
+ * by location
+ */
+export function errorWrapsInto(index: SymbolIndex, errType: string): ErrorWrap[] {
+  const matches = namePattern(errType)
+  const result: ErrorWrap[] = []
+  for (const sym of index.allSymbols()) {
+    const types = ((sym.wraps || []) as string[]).filter(matches)
+    if (types.length > 0) result.push({ symbol: sym, types })
+  }
+  return result.sort((a, b) => (a.symbol.path < b.symbol.path ? -1 : a.symbol.path > b.symbol.path ? 1 : a.symbol.line - b.symbol.line))
+}
@@ -0,0 +1,66 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { SymbolIndex } from './symbol-index.js'
+import { analyzePackages } from './analyzers.js'
+import { eslintAnalyzer } from './eslint-analyzer.js'
+
+const SOURCES: Record<string, string> = {
+  'src/billing/invoice.ts': 'export class Invoice {\n  total() {\n    console.log(this)\n    return legacyTotal(this)\n  }\n}\n',
+  'src/billing/generated.ts': 'export const x = 1\n'
+}
+
+// Stands in for the eslint package: flags console calls and legacyTotal
+const fakeESLint = {
+  ESLint: class {
+    static version = '9.0.0'
+    async isPathIgnored(filePath: string) {
+      return filePath.endsWith('generated.ts')
+    }
+    async lintText(code: string) {
+      const messages = code.split('\n').flatMap((text, i) => [
+        ...(text.includes('console.') ? [{ ruleId: 'no-console', severity: 1, message: 'Unexpected console statement.', line: i + 1, column: text.indexOf('console') + 1 }] : []),
+        ...(text.includes('legacyTotal') ? [{ ruleId: '@typescript-eslint/no-deprecated', severity: 2, message: 'legacyTotal is deprecated.', line: i + 1, column: 12 }] : [])
+      ])
+      return [{ messages: [...messages, { ruleId: null, severity: 2, message: 'Parsing error', line: 1, column: 1 }] }]
+    }
+  }
+}
+
+test('eslint analyzer: problems become diagnostics and facts of the enclosing symbol', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'eslint-analyzer-test-'))
+  try {
+    await fs.writeFile(path.join(root, 'eslint.config.js'), 'export default []\n')
+    const analyzer = await eslintAnalyzer(root, fakeESLint)
+    assert.equal(analyzer.name, 'eslint')
+    assert.match(analyzer.version!, /^9\.0\.0:[0-9a-f]{12}$/)
+
+    // The configuration is part of the version
+    await fs.writeFile(path.join(root, 'eslint.config.js'), 'export default [{ rules: {} }]\n')
+    assert.notEqual((await eslintAnalyzer(root, fakeESLint)).version, analyzer.version)
+
+    const index = new SymbolIndex()
+    index.addFile('src/billing/invoice.ts', 'typescript', [
+      { name: 'Invoice', kind: 'class', line: 1, end_line: 6, column: 14, exported: true },
+      { name: 'Invoice.total', kind: 'method', line: 2, end_line: 5, column: 3 }
+    ])
+    index.addFile('src/billing/generated.ts', 'typescript', [{ name: 'x', kind: 'const', line: 1, end_line: 1, column: 14, exported: true }])
+    index.analyzers = [analyzer]
+    await index.write(() => analyzePackages(index, ['src/billing/invoice.ts'], async (p) => SOURCES[p] ?? null))
+
+    assert.deepEqual(index.getFile('src/billing/invoice.ts')!.diagnostics, [
+      { message: 'Unexpected console statement.', line: 3, column: 5, analyzer: 'eslint', rule: 'no-console', severity: 'warning' },
+      { message: 'legacyTotal is deprecated.', line: 4, column: 12, analyzer: 'eslint', rule: '@typescript-eslint/no-deprecated', severity: 'error' }
+    ])
+    assert.deepEqual(index.getSymbol('src/billing/invoice.ts#Invoice.total')!.facts, {
+      eslint: { problems: 2, rules: ['@typescript-eslint/no-deprecated', 'no-console'] }
+    })
+    assert.equal(index.getSymbol('src/billing/invoice.ts#Invoice')!.facts, undefined)
+    // Ignored files are not linted
+    assert.equal(index.getFile('src/billing/generated.ts')!.diagnostics, undefined)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,134 @@
+/**
+ * ESLint Analyzer Module
+ * Runs the project's ESLint, with its own configuration and plugins, as an
+ * analyzer (analyzer: eslint), so lint results are stored in the index and
+ * queried beside symbols: `indexer diagnostics --analyzer=eslint
+ * --rule=@typescript-eslint/no-deprecated --package=./src/billing/...`.
+ * Every problem becomes a diagnostic of its file with the rule and
+ * severity, and the innermost declaration around it records the count and
+ * rules as facts (facts.eslint.problems, facts.eslint.rules). Problems
+ * without a rule are parse errors, which the index records already. The
+ * ESLint version and configuration files are the analyzer's version, so
+ * changing either re-lints the project.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import crypto from 'crypto'
+import { createRequire } from 'module'
+import { isLocalSymbol } from './local-scopes.js'
+import type { Analyzer, AnalyzerDiagnostic, AnalyzerFact } from './analyzers.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+const LINTED_LANGUAGES = ['javascript', 'typescript']
+
+const CONFIG_FILES = [
+  'eslint.config.js', 'eslint.config.mjs', 'eslint.config.cjs', 'eslint.config.ts', 'eslint.config.mts', 'eslint.config.cts',
+  '.eslintrc', '.eslintrc.js', '.eslintrc.cjs', '.eslintrc.json', '.eslintrc.yaml', '.eslintrc.yml', 'package.json'
+]
+
+// ESLint message severities
+const SEVERITY: Record<number, AnalyzerDiagnostic['severity']> = { 1: 'warning', 2: 'error' }
+
+interface LintMessage {
+  ruleId: string | null
+  severity: number
+  message: string
+  line?: number
+  column?: number
+}
+
+interface ESLintLike {
+  lintText(code: string, options: { filePath: string }): Promise<{ messages: LintMessage[] }[]>
+  isPathIgnored(filePath: string): Promise<boolean>
+}
+
+/**
+ * The project's ESLint class (its own copy, else the indexer's), or the
+ * module given
+ */
+async function loadESLint(projectRoot: string, module?: any): Promise<{ ESLint: new (options: { cwd: string }) => ESLintLike, version: string }> {
+  if (!module) {
+    try {
+      module = createRequire(path.join(projectRoot, 'package.json'))('eslint')
+    } catch {
+      try {
+        module = await import('eslint')
+      } catch {
+        throw new Error('analyzer: eslint needs the eslint package; install it in the project (npm install -D eslint)')
+      }
+    }
+  }
+  // loadESLint picks flat or legacy configuration the way the eslint CLI does
+  const ESLint = module.loadESLint ? await module.loadESLint({ cwd: projectRoot }) : module.ESLint
+  return { ESLint, version: ESLint.version || module.ESLint?.version || '' }
+}
+
+async function configHash(projectRoot: string): Promise<string> {
+  const hash = crypto.createHash('sha1')
+  for (const name of CONFIG_FILES) {
+    try {
+      hash.update(`${name}\0${await fs.readFile(path.join(projectRoot, name), 'utf8')}\0`)
+    } catch {
+      // Not there
+    }
+  }
+  return hash.digest('hex').slice(0, 12)
+}
+
+// Innermost declaration whose lines cover a line
+function enclosing(symbols: IndexedSymbol[], line: number): IndexedSymbol | undefined {
+  let best: IndexedSymbol | undefined
+  for (const sym of symbols) {
+    if (isLocalSymbol(sym) || sym.line > line || line > (sym.end_line ?? sym.line)) continue
+    if (!best || sym.line > best.line || (sym.line === best.line && sym.end_line < best.end_line)) best = sym
+  }
+  return best
+}
+
+/**
+ * ESLint as an analyzer
+ * @param module - ESLint module to use instead of the project's
+ */
+export async function eslintAnalyzer(projectRoot: string, module?: any): Promise<Analyzer> {
+  const { ESLint, version } = await loadESLint(projectRoot, module)
+  let eslint: ESLintLike | null = null
+  return {
+    name: 'eslint',
+    version: `${version}:${await configHash(projectRoot)}`,
+    languages: LINTED_LANGUAGES,
+    analyze: async (pkg) => {
+      eslint ||= new ESLint({ cwd: projectRoot })
+      const diagnostics: AnalyzerDiagnostic[] = []
+      const facts: AnalyzerFact[] = []
+      for (const file of pkg.files) {
+        const filePath = path.join(projectRoot, file.path)
+        if (await eslint.isPathIgnored(filePath)) continue
+        const [result] = await eslint.lintText(file.content, { filePath })
+        const problems = new Map<string, { count: number, rules: Set<string> }>()
+        for (const message of result?.messages || []) {
+          if (!message.ruleId) continue
+          const line = message.line || 1
+          diagnostics.push({
+            path: file.path,
+            line,
+            column: message.column || 1,
+            message: message.message,
+            rule: message.ruleId,
+            severity: SEVERITY[message.severity] || 'warning'
+          })
+          const owner = enclosing(file.symbols, line)
+          if (!owner) continue
+          const entry = problems.get(owner.id) || { count: 0, rules: new Set<string>() }
+          entry.count++
+          entry.rules.add(message.ruleId)
+          problems.set(owner.id, entry)
+        }
+        for (const [symbol, { count, rules }] of problems) {
+          facts.push({ symbol, name: 'problems', value: count }, { symbol, name: 'rules', value: [...rules].sort() })
+        }
+      }
+      return { facts, diagnostics }
+    }
+  }
+}
@@ -0,0 +1,55 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { listProjectFiles, resetConfigCache, shouldIndexFile } from './file-filters.js'
+
+async function createProject(files: Record<string, string>): Promise<string> {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'file-filters-test-'))
+  for (const [relPath, content] of Object.entries(files)) {
+    await fs.mkdir(path.dirname(path.join(root, relPath)), { recursive: true })
+    await fs.writeFile(path.join(root, relPath), content)
+  }
+  resetConfigCache(root)
+  return root
+}
+
+const SOURCES = {
+  'src/app.ts': '',
+  'src/api.pb.ts': '',
+  'src/gen/client.ts': '',
+  'vendor/lib.ts': '',
+  'third_party/vendor/x.ts': '',
+  'out/bundle.ts': ''
+}
+
+test('file-filters: .indexerignore uses gitignore semantics after .gitignore', async () => {
+  const root = await createProject({
+    ...SOURCES,
+    '.gitignore': 'out/*\n',
+    '.indexerignore': '# vendored code\nvendor/\n*.pb.ts\n!out/bundle.ts\n'
+  })
+  try {
+    assert.deepEqual((await listProjectFiles(root)).sort(), ['.gitignore', '.indexerignore', 'out/bundle.ts', 'src/app.ts', 'src/gen/client.ts'])
+    assert.equal(await shouldIndexFile('src/api.pb.ts', root), false)
+    assert.equal(await shouldIndexFile('third_party/vendor/x.ts', root), false)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('file-filters: exclude: entries in .indexer/to-index', async () => {
+  const root = await createProject({
+    ...SOURCES,
+    '.indexer/to-index': 'ext: .ts\nexclude: src/gen\nexclude: vendor\nexclude: *.pb.ts\n'
+  })
+  try {
+    assert.deepEqual((await listProjectFiles(root)).sort(), ['out/bundle.ts', 'src/app.ts'])
+    assert.equal(await shouldIndexFile('src/gen/client.ts', root), false)
+    assert.equal(await shouldIndexFile('third_party/vendor/x.ts', root), false)
+    assert.equal(await shouldIndexFile('src/app.ts', root), true)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
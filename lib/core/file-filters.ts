@@ -1,6 +1,12 @@
 /**
  * File Filters Module
  * Handles file filtering, ignore patterns, and to-index configuration.
+ * Ignore rules come from .gitignore and .indexerignore (gitignore syntax, read
+ * after .gitignore so "!" lines can re-include files); .indexer/to-index
+ * limits scope with dir:/ext: entries and drops matching files with exclude:.
+ * A build: entry there takes the file list from the build system instead of
+ * the file tree (see build-manifest); vendor: skip leaves out vendor/
+ * directories (see vendor-trees).
  */
 
 import fs from 'fs/promises'
@@ -9,6 +15,8 @@ import fg from 'fast-glob'
 import ignore from 'ignore'
 import type { Ignore } from 'ignore'
 import { minimatch } from 'minimatch'
+import { loadBuildManifest, resetBuildManifestCache, type BuildManifest } from './build-manifest.js'
+import { isVendored } from './vendor-trees.js'
 
 /**
  * Default file exclusion patterns
@@ -41,10 +49,11 @@ export function resetConfigCache(projectRoot?: string): void {
     _toIndexCache.clear()
     _ignoreCache.clear()
   }
+  resetBuildManifestCache(projectRoot)
 }
 
 /**
- * Load ignore patterns from .gitignore
+ * Load ignore patterns from .gitignore and .indexerignore
  * @param {string} projectRoot - Project root path
  * @returns {Promise<Ignore>} Ignore instance
  */
@@ -53,22 +62,58 @@ export async function loadIgnorePatterns(projectRoot?: string): Promise<Ignore>
   if (_ignoreCache.has(root)) return _ignoreCache.get(root)
   const ig = ignore()
   ig.add(DEFAULT_EXCLUDES.map((p) => p.replace('**/', '')))
-  try {
-    const gi = await fs.readFile(path.join(root, '.gitignore'), 'utf8')
-    ig.add(gi.split('\n'))
-  } catch {}
+  for (const file of ['.gitignore', '.indexerignore']) {
+    try {
+      const text = await fs.readFile(path.join(root, file), 'utf8')
+      ig.add(text.split(/\r?\n/))
+    } catch {}
+  }
   _ignoreCache.set(root, ig)
   return ig
 }
 
+type ToIndexConfig = {enabled: boolean, dirs: string[], exts: string[], excludes: string[], build?: string, vendor?: string, analyzers?: string[], redact?: string[], maxFileSize?: string, maxSymbols?: string, largeFiles?: string, history?: string, historyInterval?: string, rank?: string[]}
+
+/**
+ * Normalize a dir:/exclude: entry to a project-relative path or glob
+ * @param {string} value - Entry value
+ * @returns {string} Normalized entry
+ */
+function normalizeEntry(value: string): string {
+  let entry = value.replace(/\\/g, '/')
+  if (entry.startsWith('./')) entry = entry.slice(2)
+  return entry.replace(/^\/+/, '').replace(/\/+$/, '')
+}
+
+/**
+ * Globs for an exclude: entry. As in .gitignore, an entry without a slash
+ * matches at any depth, and an entry naming a directory covers its contents.
+ * @param {string} entry - Normalized entry
+ * @returns {string[]} Globs
+ */
+function excludeGlobs(entry: string): string[] {
+  const glob = entry.includes('/') ? entry : `**/${entry}`
+  return [glob, `${glob}/**`]
+}
+
 /**
  * Parse to-index configuration text
  * @param {string} text - Configuration text
- * @returns {{dirs: string[], exts: string[]}} Parsed configuration
+ * @returns {{dirs: string[], exts: string[], excludes: string[], build?: string, vendor?: string, analyzers?: string[], redact?: string[]}} Parsed configuration
  */
-function parseToIndexConfig(text: string): { dirs: string[], exts: string[] } {
+function parseToIndexConfig(text: string): Omit<ToIndexConfig, 'enabled'> {
   const dirs: string[] = []
   const exts: string[] = []
+  const excludes: string[] = []
+  const analyzers: string[] = []
+  const redact: string[] = []
+  const rank: string[] = []
+  let build: string | undefined
+  let vendor: string | undefined
+  // Per-file limits, see file-limits
+  const limits: {maxFileSize?: string, maxSymbols?: string, largeFiles?: string} = {}
+  // Generations to keep, see index-history
+  const history: {history?: string, historyInterval?: string} = {}
   const lines = text.split(/\r?\n/)
   for (const raw of lines) {
     const line = raw.trim()
@@ -79,7 +124,8 @@ function parseToIndexConfig(text: string): { dirs: string[], exts: string[] } {
     if (colonIdx !== -1) {
       const head = line.slice(0, colonIdx).trim().toLowerCase()
       const tail = line.slice(colonIdx + 1).trim()
-      if (head === 'dir' || head === 'ext') { kind = head; value = tail }
+      if (head === 'dir' || head === 'ext' || head === 'exclude' || head === 'build' || head === 'vendor' || head === 'analyzer' || head === 'redact' || head === 'rank' ||
+          head === 'max-file-size' || head === 'max-symbols' || head === 'large-files' || head === 'history' || head === 'history-interval') { kind = head; value = tail }
     }
     if (!kind) {
       if (line.startsWith('./') || line.startsWith('/')) { kind = 'dir'; value = line }
@@ -87,17 +133,38 @@ function parseToIndexConfig(text: string): { dirs: string[], exts: string[] } {
       else { kind = 'dir'; value = line }
     }
     if (kind === 'dir') {
-      let dir = value.replace(/\\/g, '/')
-      if (dir.startsWith('./')) dir = dir.slice(2)
-      dir = dir.replace(/^\/+/, '').replace(/\/+$/, '')
+      const dir = normalizeEntry(value)
       if (dir) dirs.push(dir)
+    } else if (kind === 'exclude') {
+      const entry = normalizeEntry(value)
+      if (entry) excludes.push(...excludeGlobs(entry))
+    } else if (kind === 'build') {
+      if (value) build = value
+    } else if (kind === 'vendor') {
+      if (value) vendor = value.toLowerCase()
+    } else if (kind === 'analyzer') {
+      if (value) analyzers.push(value)
+    } else if (kind === 'redact') {
+      if (value) redact.push(value)
+    } else if (kind === 'rank') {
+      if (value) rank.push(value)
+    } else if (kind === 'max-file-size') {
+      if (value) limits.maxFileSize = value
+    } else if (kind === 'max-symbols') {
+      if (value) limits.maxSymbols = value
+    } else if (kind === 'large-files') {
+      if (value) limits.largeFiles = value
+    } else if (kind === 'history') {
+      if (value) history.history = value
+    } else if (kind === 'history-interval') {
+      if (value) history.historyInterval = value
     } else if (kind === 'ext') {
       let ext = value.toLowerCase()
       if (!ext.startsWith('.')) ext = `.${ext}`
       exts.push(ext)
     }
   }
-  return {dirs, exts}
+  return {dirs, exts, excludes, ...(build ? {build} : {}), ...(vendor ? {vendor} : {}), ...(analyzers.length > 0 ? {analyzers} : {}), ...(redact.length > 0 ? {redact} : {}), ...(rank.length > 0 ? {rank} : {}), ...limits, ...history}
 }
 
 /**
@@ -105,13 +172,13 @@ function parseToIndexConfig(text: string): { dirs: string[], exts: string[] } {
  * @param {string} projectRoot - Project root path
  * @returns {Promise<object|null>} Configuration object or null
  */
-export async function loadToIndexConfig(projectRoot?: string): Promise<{enabled: boolean, dirs: string[], exts: string[]} | null> {
+export async function loadToIndexConfig(projectRoot?: string): Promise<ToIndexConfig | null> {
   const root = projectRoot || process.env.WORKSPACE_DIR || process.cwd()
   if (_toIndexCache.has(root)) return _toIndexCache.get(root)
   try {
     const text = await fs.readFile(path.join(root, '.indexer', 'to-index'), 'utf8')
     const config = parseToIndexConfig(text)
-    const res = {enabled: true, ...config} as {enabled: boolean, dirs: string[], exts: string[]}
+    const res: ToIndexConfig = {enabled: true, ...config}
     _toIndexCache.set(root, res)
     return res
   } catch (e: any) {
@@ -123,6 +190,17 @@ export async function loadToIndexConfig(projectRoot?: string): Promise<{enabled:
   }
 }
 
+/**
+ * Check if a path matches an exclude: entry of the to-index configuration
+ * @param {string} relPath - Relative file path (forward slashes)
+ * @param {object|null} toIndex - Loaded configuration
+ * @returns {boolean} True if the file is excluded
+ */
+function isExcluded(relPath: string, toIndex: ToIndexConfig | null): boolean {
+  if (toIndex?.vendor === 'skip' && isVendored(relPath)) return true
+  return !!toIndex?.excludes?.some(pattern => minimatch(relPath, pattern, {dot: true}))
+}
+
 /**
  * Check if a file should be indexed
  * @param {string} relPath - Relative file path
@@ -134,8 +212,13 @@ export async function shouldIndexFile(relPath: string, projectRoot?: string): Pr
   const ig = await loadIgnorePatterns(root)
   const toIndex = await loadToIndexConfig(root)
   const normalized = relPath.replace(/\\/g, '/')
+  if (toIndex?.build) {
+    const manifest = await loadBuildManifest(root, toIndex.build)
+    return manifest.packages.has(normalized) && isIndexedBuildFile(normalized, manifest, toIndex, ig)
+  }
   if (ig.ignores(normalized)) return false
   if (toIndex && toIndex.dirs.length === 0 && toIndex.exts.length === 0) return false
+  if (isExcluded(normalized, toIndex)) return false
   if (toIndex?.dirs?.length) {
     const dirGlobs = toIndex.dirs.map((dir) => {
       const hasGlob = /[*?\[]/.test(dir)
@@ -150,6 +233,33 @@ export async function shouldIndexFile(relPath: string, projectRoot?: string): Pr
   return true
 }
 
+/**
+ * Files of the build manifest that pass the to-index filters. dir: entries
+ * do not apply, and ignore rules only to sources: build outputs are usually
+ * ignored by git.
+ * @param {string} root - Project root path
+ * @param {object} toIndex - Loaded configuration with a build: entry
+ * @param {Ignore} ig - Ignore rules
+ * @returns {Promise<string[]>} Relative file paths from the manifest
+ */
+async function listBuildFiles(root: string, toIndex: ToIndexConfig, ig: Ignore): Promise<string[]> {
+  const manifest = await loadBuildManifest(root, toIndex.build!)
+  return Array.from(manifest.packages.keys()).filter(p => isIndexedBuildFile(p, manifest, toIndex, ig))
+}
+
+/**
+ * Check if a file of the build manifest passes the to-index filters
+ * @param {string} relPath - Relative file path from the manifest
+ * @returns {boolean} True if the file is indexed
+ */
+function isIndexedBuildFile(relPath: string, manifest: BuildManifest, toIndex: ToIndexConfig, ig: Ignore): boolean {
+  if (!manifest.generated.has(relPath) && ig.ignores(relPath)) return false
+  if (isExcluded(relPath, toIndex)) return false
+  const ext = path.extname(relPath).toLowerCase()
+  if (toIndex.exts.length && !toIndex.exts.includes(ext)) return false
+  return !['.lock'].includes(ext)
+}
+
 /**
  * List all project files that should be indexed
  * @param {string} projectRoot - Project root path
@@ -159,6 +269,7 @@ export async function listProjectFiles(projectRoot?: string): Promise<string[]>
   const root = projectRoot || process.env.WORKSPACE_DIR || process.cwd()
   const ig = await loadIgnorePatterns(root)
   const toIndex = await loadToIndexConfig(root)
+  if (toIndex?.build) return listBuildFiles(root, toIndex, ig)
   if (toIndex && toIndex.dirs.length === 0 && toIndex.exts.length === 0) return []
   let globs = ['**/*']
   if (toIndex?.dirs?.length) {
@@ -177,6 +288,7 @@ export async function listProjectFiles(projectRoot?: string): Promise<string[]>
   const files: string[] = []
   for (const p of entries) {
     if (ig.ignores(p)) continue
+    if (isExcluded(p, toIndex)) continue
     const ext = path.extname(p).toLowerCase()
     if (toIndex?.exts?.length && !toIndex.exts.includes(ext)) continue
     if (['.lock'].includes(ext)) continue
@@ -6,11 +6,7 @@
 import fs from 'fs/promises'
 import path from 'path'
 import crypto from 'crypto'
-import {extractJSSymbols} from '../utils/ast-js.js'
-import {
-  extractCSharpSymbols,
-  extractPythonSymbols
-} from '../utils/tree-sitter.js'
+import {getLanguageBackend} from '../utils/language-backends.js'
 import {
   upsertPoints,
   deletePointsByPath,
@@ -211,10 +207,8 @@ async function indexFile(
   if (prevHash) await deletePointsByPath(coll, file, conf)
 
   const lang = detectLanguage(file)
-  let symbols: Partial<SymbolInfo>[] = []
-  if (lang === 'javascript' || lang === 'typescript') symbols = extractJSSymbols(content)
-  if (lang === 'python') symbols = await extractPythonSymbols(content)
-  if (lang === 'csharp') symbols = await extractCSharpSymbols(content)
+  const backend = getLanguageBackend(lang)
+  const symbols: Partial<SymbolInfo>[] = backend ? (await backend.extractSymbols(content)).filter(s => s.kind !== 'diagnostic') : []
 
   const chunks = chunkByLines(content, cfg.MAX_CHUNK_LINES, cfg.OVERLAP_LINES)
   const points: QdrantPoint[] = []
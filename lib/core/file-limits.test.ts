@@ -0,0 +1,101 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { isBinaryContent, limitContent, limitSymbols, limitsKey, readSourceContent, DEFAULT_FILE_LIMITS, type FileLimits } from './file-limits.js'
+import { SymbolIndex, indexFileContents, indexSourceFile } from './symbol-index.js'
+import type { SymbolInfo } from '../types/index.js'
+
+const SOURCE = 'package main\n\nfunc main() {}\n\nfunc helper() {}\n'
+
+const EXTRACTED: Partial<SymbolInfo>[] = [
+  { name: 'main', kind: 'function', line: 3, end_line: 3 },
+  { name: 'helper', kind: 'function', line: 5, end_line: 5 },
+  { name: 'helper', kind: 'reference', line: 3, end_line: 3, column: 15 },
+  { name: 'Unexpected token', kind: 'diagnostic', line: 4, column: 1 }
+]
+
+function limits(overrides: Partial<FileLimits>): FileLimits {
+  return { ...DEFAULT_FILE_LIMITS, ...overrides }
+}
+
+test('file-limits: binary content and oversized files by policy', () => {
+  assert.ok(isBinaryContent('\x7fELF\0\0\x01'))
+  assert.ok(isBinaryContent('\ufffd\ufffd\x01\x02abc'))
+  assert.ok(!isBinaryContent(SOURCE))
+  assert.deepEqual(limitContent('PK\x03\x04\0\0', DEFAULT_FILE_LIMITS).diagnostics.map(d => [d.rule, d.analyzer]), [['binary', 'limits']])
+
+  const small = limitContent(SOURCE, limits({ maxFileBytes: 20 }))
+  assert.equal(small.parse, null)
+  assert.match(small.diagnostics[0].message, /^File is 47 bytes, over the limit of 20; not indexed$/)
+
+  const truncated = limitContent(SOURCE, limits({ maxFileBytes: 20, policy: 'truncate' }))
+  assert.equal(truncated.parse, 'package main\n\n')
+  assert.equal(truncated.diagnostics[0].severity, 'warning')
+  assert.match(truncated.diagnostics[0].message, /only its first 2 lines/)
+
+  // Partial parses the same start, and keeps only its declarations
+  const partial = limitContent(SOURCE, limits({ maxFileBytes: 20, policy: 'partial' }))
+  assert.equal(partial.parse, 'package main\n\n')
+  assert.ok(partial.declarationsOnly)
+  assert.match(partial.diagnostics[0].message, /only the declarations in its first 2 lines/)
+  assert.equal(limitContent(SOURCE, limits({ maxFileBytes: 0 })).parse, SOURCE)
+})
+
+test('file-limits: symbol limit by policy, parser diagnostics kept', () => {
+  const names = (e: Partial<SymbolInfo>[]) => e.map(s => `${s.kind}:${s.name}`)
+  assert.deepEqual(limitSymbols(EXTRACTED, DEFAULT_FILE_LIMITS).diagnostics, [])
+
+  const skipped = limitSymbols(EXTRACTED, limits({ maxSymbols: 2 }))
+  assert.deepEqual(names(skipped.extracted), ['diagnostic:Unexpected token'])
+  assert.equal(skipped.diagnostics[0].rule, 'max-symbols')
+
+  const truncated = limitSymbols(EXTRACTED, limits({ maxSymbols: 2, policy: 'truncate' }))
+  assert.deepEqual(names(truncated.extracted), ['function:main', 'reference:helper', 'diagnostic:Unexpected token'])
+  assert.match(truncated.diagnostics[0].message, /up to line 3/)
+
+  const partial = limitSymbols(EXTRACTED, limits({ maxSymbols: 2, policy: 'partial' }))
+  assert.deepEqual(names(partial.extracted), ['function:main', 'function:helper', 'diagnostic:Unexpected token'])
+  assert.deepEqual(names(limitSymbols(EXTRACTED, DEFAULT_FILE_LIMITS, true).extracted), ['function:main', 'function:helper', 'diagnostic:Unexpected token'])
+})
+
+test('file-limits: left-out files stay indexed with a diagnostic, and limits salt the hash', async () => {
+  const index = new SymbolIndex()
+  index.limits = limits({ maxFileBytes: 20 })
+  const update = await indexFileContents(index, ['blob.go', 'big.go'], ['\0\x01\x02garbage', SOURCE])
+  assert.deepEqual(update.added, ['blob.go', 'big.go'])
+  assert.deepEqual(index.getFile('blob.go')?.diagnostics?.map(d => d.rule), ['binary'])
+  assert.deepEqual(index.getFile('big.go')?.symbols, [])
+  assert.deepEqual(index.getFile('big.go')?.diagnostics?.map(d => d.rule), ['max-file-size'])
+
+  assert.equal(limitsKey(DEFAULT_FILE_LIMITS), '')
+  assert.equal(limitsKey(index.limits), `limits=20,${DEFAULT_FILE_LIMITS.maxSymbols},skip:`)
+  index.limits = limits({ maxFileBytes: 10 })
+  assert.deepEqual((await indexFileContents(index, ['blob.go'], ['\0\x01\x02garbage'])).modified, ['blob.go'])
+})
+
+test('file-limits: files over the size limit are not read whole', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'file-limits-test-'))
+  try {
+    await fs.writeFile(path.join(root, 'big.go'), SOURCE)
+    const file = path.join(root, 'big.go')
+    assert.equal(await readSourceContent(file, DEFAULT_FILE_LIMITS), SOURCE)
+    assert.deepEqual(await readSourceContent(file, limits({ maxFileBytes: 20 })), { head: '', bytes: 47 })
+    assert.deepEqual(await readSourceContent(file, limits({ maxFileBytes: 20, policy: 'truncate' })), { head: 'package main\n\nfunc m', bytes: 47 })
+
+    // What was read is limited as the whole file would be
+    const head = await readSourceContent(file, limits({ maxFileBytes: 20, policy: 'truncate' }))
+    assert.deepEqual(limitContent(head, limits({ maxFileBytes: 20, policy: 'truncate' })), limitContent(SOURCE, limits({ maxFileBytes: 20, policy: 'truncate' })))
+
+    const index = new SymbolIndex()
+    index.limits = limits({ maxFileBytes: 20 })
+    const first = await indexSourceFile(index, root, 'big.go')
+    assert.match(first!.diagnostics![0].message, /^File is 47 bytes/)
+    // Growing past the start still changes the hash
+    await fs.appendFile(file, 'func more() {}\n')
+    assert.notEqual((await indexSourceFile(index, root, 'big.go'))!.hash, first!.hash)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
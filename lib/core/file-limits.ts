@@ -0,0 +1,227 @@
+/**
+ * File Limits Module
+ * Bounds on what one file may put into the index, so a pathological input
+ * (a 50 MB generated table, a minified bundle, a binary blob named .go)
+ * cannot blow up memory. A file over the size limit, or whose parse yields
+ * more symbols and references than the symbol limit, is handled by policy:
+ * skip indexes nothing from it, truncate keeps the start of it, and partial
+ * keeps its declarations (those at the start of a file over the size limit)
+ * and drops its references and string literals. A file over the size limit
+ * is never read whole (see readSourceContent), so a multi-GB one costs no
+ * more memory than the limit. Binary content is never parsed, whatever the
+ * policy. Either way the file stays in the index with a diagnostic saying
+ * what was left out (analyzer "limits"), so `indexer diagnostics
+ * --analyzer=limits` lists them.
+ */
+
+import fs from 'fs/promises'
+import { loadToIndexConfig } from './file-filters.js'
+import { parseByteRate } from './index-limits.js'
+import type { ParseDiagnostic, SymbolInfo } from '../types/index.js'
+
+export type LargeFilePolicy = 'skip' | 'truncate' | 'partial'
+
+export const LARGE_FILE_POLICIES: LargeFilePolicy[] = ['skip', 'truncate', 'partial']
+export const LIMITS_ANALYZER = 'limits'
+
+export interface FileLimits {
+  /** Bytes of content a file may have, 0 for no limit */
+  maxFileBytes: number
+  /** Symbols and references one file may yield, 0 for no limit */
+  maxSymbols: number
+  policy: LargeFilePolicy
+}
+
+export const DEFAULT_FILE_LIMITS: FileLimits = { maxFileBytes: 4 * 1024 * 1024, maxSymbols: 100_000, policy: 'skip' }
+
+/**
+ * What was read of a file over the size limit: its first maxFileBytes bytes
+ * (none under the skip policy) and the size of the whole file
+ */
+export interface PartialContent {
+  head: string
+  bytes: number
+}
+
+/** Content of a file to index: all of it, or the part read of a file over the size limit */
+export type SourceContent = string | PartialContent
+
+// Share of control characters and undecodable bytes that marks content as binary
+const BINARY_SAMPLE = 8000
+const BINARY_RATIO = 0.1
+
+export function parseLargeFilePolicy(value: string): LargeFilePolicy {
+  const policy = value.trim().toLowerCase()
+  if (!LARGE_FILE_POLICIES.includes(policy as LargeFilePolicy)) {
+    throw new Error(`Unknown large-file policy "${value}". Use ${LARGE_FILE_POLICIES.join(', ')}`)
+  }
+  return policy as LargeFilePolicy
+}
+
+function parseCount(value: string, name: string): number {
+  const count = Number(value.trim().replace(/_/g, ''))
+  if (!Number.isInteger(count) || count < 0) throw new Error(`Invalid ${name} "${value}". Use a whole number, 0 for no limit`)
+  return count
+}
+
+function parseSize(value: string): number {
+  try {
+    return parseByteRate(value)
+  } catch {
+    throw new Error(`Invalid max-file-size "${value}". Use bytes, such as 4MB or 512k, 0 for no limit`)
+  }
+}
+
+/**
+ * Limits of a project: INDEXER_MAX_FILE_SIZE, INDEXER_MAX_SYMBOLS and
+ * INDEXER_LARGE_FILES, else the max-file-size:, max-symbols: and
+ * large-files: entries of .indexer/to-index, else the defaults
+ * @param projectRoot - Project root, or null for the environment alone
+ */
+export async function loadFileLimits(projectRoot: string | null): Promise<FileLimits> {
+  const toIndex = projectRoot === null ? null : await loadToIndexConfig(projectRoot)
+  const size = process.env.INDEXER_MAX_FILE_SIZE || toIndex?.maxFileSize
+  const symbols = process.env.INDEXER_MAX_SYMBOLS || toIndex?.maxSymbols
+  const policy = process.env.INDEXER_LARGE_FILES || toIndex?.largeFiles
+  return {
+    maxFileBytes: size ? parseSize(size) : DEFAULT_FILE_LIMITS.maxFileBytes,
+    maxSymbols: symbols ? parseCount(symbols, 'max-symbols') : DEFAULT_FILE_LIMITS.maxSymbols,
+    policy: policy ? parseLargeFilePolicy(policy) : DEFAULT_FILE_LIMITS.policy
+  }
+}
+
+/**
+ * Salt for content hashes (see hashPasses), so changing the limits
+ * re-indexes files; empty for the defaults
+ */
+export function limitsKey(limits: FileLimits): string {
+  const { maxFileBytes, maxSymbols, policy } = DEFAULT_FILE_LIMITS
+  if (limits.maxFileBytes === maxFileBytes && limits.maxSymbols === maxSymbols && limits.policy === policy) return ''
+  return `limits=${limits.maxFileBytes},${limits.maxSymbols},${limits.policy}:`
+}
+
+/**
+ * Read a file to index. A file over the size limit is not read whole: only
+ * what its policy can keep of it is, nothing for skip
+ */
+export async function readSourceContent(file: string, limits: FileLimits): Promise<SourceContent> {
+  if (limits.maxFileBytes) {
+    const { size } = await fs.stat(file)
+    if (size > limits.maxFileBytes) {
+      if (limits.policy === 'skip') return { head: '', bytes: size }
+      const handle = await fs.open(file, 'r')
+      try {
+        const buffer = Buffer.alloc(limits.maxFileBytes)
+        const { bytesRead } = await handle.read(buffer, 0, limits.maxFileBytes, 0)
+        return { head: buffer.subarray(0, bytesRead).toString('utf8'), bytes: size }
+      } finally {
+        await handle.close()
+      }
+    }
+  }
+  return fs.readFile(file, 'utf8')
+}
+
+/**
+ * The text of content to index: all of it, or the part that was read
+ */
+export function sourceText(content: SourceContent): string {
+  return typeof content === 'string' ? content : content.head
+}
+
+/**
+ * Whether decoded content is binary: a NUL character, or many control
+ * characters and replacement characters (bytes that were not UTF-8) near
+ * the start
+ */
+export function isBinaryContent(content: string): boolean {
+  const sample = content.slice(0, BINARY_SAMPLE)
+  if (sample.includes('\0')) return true
+  let odd = 0
+  for (let i = 0; i < sample.length; i++) {
+    const code = sample.charCodeAt(i)
+    if (code === 0xfffd || (code < 0x20 && code !== 0x09 && code !== 0x0a && code !== 0x0d && code !== 0x0c)) odd++
+  }
+  return sample.length > 0 && odd / sample.length > BINARY_RATIO
+}
+
+function limitDiagnostic(rule: string, message: string, line = 1): ParseDiagnostic {
+  return { message, line, column: 1, analyzer: LIMITS_ANALYZER, rule, severity: 'warning' }
+}
+
+export interface LimitedContent {
+  /** Text to parse, or null to index nothing from the file */
+  parse: string | null
+  /** Text for full-text search */
+  text: string
+  /** Keep declarations only (the partial policy) */
+  declarationsOnly: boolean
+  diagnostics: ParseDiagnostic[]
+}
+
+/**
+ * What of a file's content to parse, before parsing
+ */
+export function limitContent(content: SourceContent, limits: FileLimits): LimitedContent {
+  const text = sourceText(content)
+  const bytes = typeof content === 'string' ? Buffer.byteLength(content) : content.bytes
+  if (isBinaryContent(text)) {
+    return { parse: null, text: '', declarationsOnly: false, diagnostics: [limitDiagnostic('binary', 'Binary content; not indexed')] }
+  }
+  if (!limits.maxFileBytes || bytes <= limits.maxFileBytes) return { parse: text, text, declarationsOnly: false, diagnostics: [] }
+
+  const over = `File is ${bytes} bytes, over the limit of ${limits.maxFileBytes}`
+  if (limits.policy === 'skip') {
+    return { parse: null, text: '', declarationsOnly: false, diagnostics: [limitDiagnostic('max-file-size', `${over}; not indexed`)] }
+  }
+
+  // Both truncate and partial parse only the start: cut at the last line end
+  // within the limit, counting bytes rather than characters
+  let head = Buffer.from(text).subarray(0, limits.maxFileBytes).toString('utf8')
+  head = head.replace(/\ufffd+$/, '') // a character the cut split
+  const lineEnd = head.lastIndexOf('\n')
+  if (lineEnd !== -1) head = head.slice(0, lineEnd + 1)
+  const lines = head.split('\n').length - (head.endsWith('\n') ? 1 : 0)
+  const declarationsOnly = limits.policy === 'partial'
+  const kept = declarationsOnly ? `only the declarations in its first ${lines} lines are indexed` : `only its first ${lines} lines are indexed`
+  return {
+    parse: head,
+    text: head,
+    declarationsOnly,
+    diagnostics: [limitDiagnostic('max-file-size', `${over}; ${kept}`, Math.max(1, lines))]
+  }
+}
+
+/**
+ * Parsed entries of a file within the symbol limit. Diagnostics from the
+ * parser are always kept and not counted.
+ */
+export function limitSymbols(
+  extracted: Partial<SymbolInfo>[],
+  limits: FileLimits,
+  declarationsOnly = false
+): { extracted: Partial<SymbolInfo>[], diagnostics: ParseDiagnostic[] } {
+  const notes = extracted.filter(s => s.kind === 'diagnostic')
+  let entries = extracted.filter(s => s.kind !== 'diagnostic')
+  if (declarationsOnly) entries = entries.filter(s => s.kind !== 'reference' && s.kind !== 'string')
+  if (!limits.maxSymbols || entries.length <= limits.maxSymbols) return { extracted: [...entries, ...notes], diagnostics: [] }
+
+  const over = `File has ${entries.length} symbols and references, over the limit of ${limits.maxSymbols}`
+  if (limits.policy === 'skip') {
+    return { extracted: notes, diagnostics: [limitDiagnostic('max-symbols', `${over}; not indexed`)] }
+  }
+  if (limits.policy === 'partial') {
+    const declarations = entries.filter(s => s.kind !== 'reference' && s.kind !== 'string')
+    if (declarations.length <= limits.maxSymbols) {
+      return { extracted: [...declarations, ...notes], diagnostics: [limitDiagnostic('max-symbols', `${over}; only its declarations are indexed`)] }
+    }
+    entries = declarations
+  }
+  // The first entries in source order
+  const kept = [...entries].sort((a, b) => (a.line || 0) - (b.line || 0) || (a.column || 0) - (b.column || 0)).slice(0, limits.maxSymbols)
+  const lastLine = kept[kept.length - 1]?.line || 1
+  return {
+    extracted: [...kept, ...notes],
+    diagnostics: [limitDiagnostic('max-symbols', `${over}; only those up to line ${lastLine} are indexed`, lastLine)]
+  }
+}
@@ -0,0 +1,126 @@
+/**
+ * File Source Module
+ * Where the indexer reads files from. The working tree and git revisions
+ * are sources, and so is anything an embedding tool can list and read: a
+ * build sandbox, a virtual file system, editor buffers. An Overlay layers
+ * in-memory edits and deletions over another source.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { listProjectFiles, shouldIndexFile } from './file-filters.js'
+import { mapConcurrent } from './parse-pool.js'
+import { readConcurrency } from './index-limits.js'
+import { listRevisionFiles, readRevisionFiles } from '../utils/git.js'
+
+export interface FileSource {
+  /** Project-relative paths of the files to index, with '/' separators */
+  listFiles(): Promise<string[]>
+  /** File content, or null if the file does not exist */
+  readFile(relPath: string): Promise<string | null>
+  /** Many files at once, in input order; sources with cheaper batch reads implement it */
+  readFiles?(relPaths: string[]): Promise<(string | null)[]>
+}
+
+/**
+ * Read files from a source, batched when the source supports it
+ */
+export function readSourceFiles(source: FileSource, relPaths: string[]): Promise<(string | null)[]> {
+  if (source.readFiles) return source.readFiles(relPaths)
+  return mapConcurrent(relPaths, readConcurrency(), relPath => source.readFile(relPath))
+}
+
+/**
+ * Project files on disk, filtered by .indexer/to-index and the ignore files
+ */
+export function projectFileSource(projectRoot: string): FileSource {
+  return {
+    listFiles: () => listProjectFiles(projectRoot),
+    readFile: async (relPath) => {
+      try {
+        return await fs.readFile(path.join(projectRoot, relPath), 'utf8')
+      } catch {
+        return null
+      }
+    }
+  }
+}
+
+/**
+ * Files of a commit, read from git objects, filtered like project files
+ */
+export function revisionFileSource(projectRoot: string, commit: string): FileSource {
+  return {
+    listFiles: async () => {
+      const result: string[] = []
+      for (const relPath of await listRevisionFiles(projectRoot, commit)) {
+        if (await shouldIndexFile(relPath, projectRoot)) result.push(relPath)
+      }
+      return result
+    },
+    readFile: async (relPath) => (await readRevisionFiles(projectRoot, commit, [relPath]))[0],
+    readFiles: (relPaths) => readRevisionFiles(projectRoot, commit, relPaths)
+  }
+}
+
+/**
+ * Files held in memory, keyed by relative path
+ */
+export function memoryFileSource(files: Record<string, string> | Map<string, string>): FileSource {
+  const map = files instanceof Map ? files : new Map(Object.entries(files))
+  return {
+    listFiles: async () => Array.from(map.keys()).sort(),
+    readFile: async (relPath) => map.get(relPath) ?? null
+  }
+}
+
+/**
+ * In-memory edits over another source: written files shadow or add to the
+ * base, deleted files disappear from it
+ */
+export class Overlay implements FileSource {
+  private readonly entries = new Map<string, string | null>() // null marks a deletion
+
+  constructor(private readonly base: FileSource) {}
+
+  write(relPath: string, content: string): this {
+    this.entries.set(relPath, content)
+    return this
+  }
+
+  delete(relPath: string): this {
+    this.entries.set(relPath, null)
+    return this
+  }
+
+  /** Drop the overlay's entry so the base file shows through again */
+  reset(relPath: string): this {
+    this.entries.delete(relPath)
+    return this
+  }
+
+  /** Paths the overlay writes or deletes */
+  changedFiles(): string[] {
+    return Array.from(this.entries.keys()).sort()
+  }
+
+  async listFiles(): Promise<string[]> {
+    const files = new Set(await this.base.listFiles())
+    for (const [relPath, content] of this.entries) {
+      if (content === null) files.delete(relPath)
+      else files.add(relPath)
+    }
+    return Array.from(files).sort()
+  }
+
+  async readFile(relPath: string): Promise<string | null> {
+    return this.entries.has(relPath) ? this.entries.get(relPath)! : this.base.readFile(relPath)
+  }
+
+  async readFiles(relPaths: string[]): Promise<(string | null)[]> {
+    const fromBase = relPaths.filter(p => !this.entries.has(p))
+    const baseContents = await readSourceFiles(this.base, fromBase)
+    const byPath = new Map(fromBase.map((p, i) => [p, baseContents[i]]))
+    return relPaths.map(p => (this.entries.has(p) ? this.entries.get(p)! : byPath.get(p)!))
+  }
+}
@@ -0,0 +1,45 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { fuzzyScore, fuzzySearch } from './fuzzy-search.js'
+import { querySymbols } from './symbol-query.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/user.ts': `
+export class User {
+  doWork() {}
+  draw() {}
+}
+export function userdrawsomething() {}
+export function unusedStuff() {}
+`
+}
+
+test('fuzzy-search: fuzzyScore matches subsequences case-insensitively', () => {
+  assert.deepEqual(fuzzyScore('usrdw', 'User.doWork')?.positions, [0, 1, 3, 5, 7])
+  assert.equal(fuzzyScore('xyz', 'User.doWork'), null)
+  assert.equal(fuzzyScore('', 'User')?.score, 0)
+})
+
+test('fuzzy-search: boundary and camelCase matches rank higher', () => {
+  const boundary = fuzzyScore('ud', 'User.doWork')!.score
+  const inner = fuzzyScore('ud', 'unusedStuff')!.score
+  assert.ok(boundary > inner)
+  assert.ok(fuzzyScore('usrdw', 'User.doWork')!.score > fuzzyScore('usrdw', 'userdrawsomething')!.score)
+})
+
+test('fuzzy-search: fuzzySearch ranks, pages and filters', () => {
+  const index = createIndex(FILES)
+  const names = fuzzySearch(index, 'usrdw').map(m => m.symbol.name)
+  assert.equal(names[0], 'User.doWork')
+  assert.ok(names.includes('userdrawsomething'))
+
+  const all = fuzzySearch(index, 'u')
+  assert.deepEqual(fuzzySearch(index, 'u', { offset: 1, limit: 2 }).map(m => m.symbol.id), all.slice(1, 3).map(m => m.symbol.id))
+  assert.deepEqual(fuzzySearch(index, 'usrdw', { filter: s => s.kind === 'function' }).map(m => m.symbol.name), ['userdrawsomething'])
+})
+
+test('fuzzy-search: query --fuzzy combines with structured filters', () => {
+  const results = querySymbols(createIndex(FILES), { fuzzy: 'dw', kinds: ['method'] })
+  assert.deepEqual(results.map(s => s.name), ['User.doWork', 'User.draw'])
+})
@@ -0,0 +1,165 @@
+/**
+ * Fuzzy Search Module
+ * Ranked subsequence matching over the symbol table for editor pickers
+ * ("usrdw" -> User.doWork). Matches at word and camelCase boundaries and
+ * consecutive runs score higher; gaps cost a little, and symbols of generated
+ * files rank lower. The index's search ranking, if any, adds to the score
+ * (see search-ranking).
+ */
+
+import { rankingBonus, type SearchRanking } from './search-ranking.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+const SCORE_MATCH = 16
+const BONUS_START = 12
+const BONUS_BOUNDARY = 10
+const BONUS_CONSECUTIVE = 8
+const BONUS_CASE = 1
+const PENALTY_GAP = 1
+// Generated code ranks below hand-written matches of similar quality
+const PENALTY_GENERATED = 24
+
+const SEPARATORS = new Set(['.', '_', '-', '/', '$', '#', ' ', ':'])
+
+export interface FuzzyMatch {
+  symbol: IndexedSymbol
+  score: number
+  positions: number[] // matched character offsets in symbol.name
+}
+
+export interface FuzzySearchOptions {
+  limit?: number
+  offset?: number
+  filter?: (sym: IndexedSymbol) => boolean
+  /** Ranking on top of the match score; the index's by default, null for none */
+  ranking?: SearchRanking | null
+}
+
+function isUpper(c: string): boolean {
+  return c !== c.toLowerCase() && c === c.toUpperCase()
+}
+
+function isDigit(c: string): boolean {
+  return c >= '0' && c <= '9'
+}
+
+/**
+ * Bonus for a match at position i: start of text, after a separator, or a
+ * camelCase / digit transition
+ */
+function boundaryBonus(text: string, i: number): number {
+  if (i === 0) return BONUS_START
+  const prev = text[i - 1]
+  const cur = text[i]
+  if (SEPARATORS.has(prev)) return BONUS_BOUNDARY
+  if (isUpper(cur) && !isUpper(prev)) return BONUS_BOUNDARY
+  if (isDigit(cur) && !isDigit(prev)) return BONUS_BOUNDARY
+  return 0
+}
+
+/**
+ * Score a pattern against a text, or null if the pattern is not a
+ * case-insensitive subsequence of it. Finds the best-scoring alignment.
+ */
+export function fuzzyScore(pattern: string, text: string): { score: number, positions: number[] } | null {
+  const p = pattern.replace(/\s+/g, '')
+  if (p.length === 0) return { score: 0, positions: [] }
+  if (p.length > text.length) return null
+
+  const pLower = p.toLowerCase()
+  const tLower = text.toLowerCase()
+
+  // Cheap subsequence check before the full alignment
+  let k = 0
+  for (let j = 0; j < tLower.length && k < pLower.length; j++) {
+    if (tLower[j] === pLower[k]) k++
+  }
+  if (k < pLower.length) return null
+
+  const m = p.length
+  const n = text.length
+  const NONE = -Infinity
+  // score[i][j]: best score with pattern[i] matched at text[j]
+  const score: number[][] = []
+  const from: number[][] = []
+
+  for (let i = 0; i < m; i++) {
+    const row = new Array<number>(n).fill(NONE)
+    const back = new Array<number>(n).fill(-1)
+    const prevRow = i > 0 ? score[i - 1] : null
+    // Best previous match at least two characters back, with gap penalty applied
+    let gapBest = NONE
+    let gapFrom = -1
+
+    for (let j = 0; j < n; j++) {
+      if (prevRow && j >= 2) {
+        const decayed = gapBest - PENALTY_GAP
+        const candidate = prevRow[j - 2] - PENALTY_GAP
+        if (candidate >= decayed) {
+          gapBest = candidate
+          gapFrom = j - 2
+        } else {
+          gapBest = decayed
+        }
+      }
+      if (tLower[j] !== pLower[i]) continue
+
+      const charScore = SCORE_MATCH + boundaryBonus(text, j) + (text[j] === p[i] ? BONUS_CASE : 0)
+      if (!prevRow) {
+        row[j] = charScore
+        continue
+      }
+      const consecutive = j >= 1 ? prevRow[j - 1] + BONUS_CONSECUTIVE : NONE
+      if (consecutive >= gapBest && consecutive > NONE) {
+        row[j] = charScore + consecutive
+        back[j] = j - 1
+      } else if (gapBest > NONE) {
+        row[j] = charScore + gapBest
+        back[j] = gapFrom
+      }
+    }
+    score.push(row)
+    from.push(back)
+  }
+
+  let bestEnd = -1
+  for (let j = 0; j < n; j++) {
+    if (score[m - 1][j] > NONE && (bestEnd === -1 || score[m - 1][j] > score[m - 1][bestEnd])) bestEnd = j
+  }
+  if (bestEnd === -1) return null
+
+  const positions = new Array<number>(m)
+  for (let i = m - 1, j = bestEnd; i >= 0; i--) {
+    positions[i] = j
+    j = from[i][j]
+  }
+  return { score: score[m - 1][bestEnd], positions }
+}
+
+/**
+ * Rank symbols by fuzzy match against their qualified names
+ */
+export function fuzzySearch(index: SymbolIndex, pattern: string, options: FuzzySearchOptions = {}): FuzzyMatch[] {
+  const ranking = options.ranking === undefined ? index.ranking : options.ranking
+  const now = Date.now()
+  const matches: FuzzyMatch[] = []
+  for (const sym of index.allSymbols()) {
+    if (options.filter && !options.filter(sym)) continue
+    const result = fuzzyScore(pattern, sym.name)
+    if (!result) continue
+    let score = sym.generated ? result.score - PENALTY_GENERATED : result.score
+    if (ranking) score += rankingBonus(index, sym, result.score, ranking, now)
+    matches.push({ symbol: sym, score, positions: result.positions })
+  }
+
+  matches.sort((a, b) => {
+    if (a.score !== b.score) return b.score - a.score
+    if (a.symbol.name.length !== b.symbol.name.length) return a.symbol.name.length - b.symbol.name.length
+    if (a.symbol.name !== b.symbol.name) return a.symbol.name < b.symbol.name ? -1 : 1
+    return a.symbol.path < b.symbol.path ? -1 : a.symbol.path > b.symbol.path ? 1 : 0
+  })
+
+  const offset = Math.max(options.offset || 0, 0)
+  return options.limit ? matches.slice(offset, offset + options.limit) : matches.slice(offset)
+}
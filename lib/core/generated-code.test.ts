@@ -0,0 +1,55 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { querySymbols } from './symbol-query.js'
+import { fuzzySearch } from './fuzzy-search.js'
+import { excludeGenerated, isGeneratedSource } from './generated-code.js'
+import { indexSources } from '../test-utils.js'
+
+const GENERATED_SRC = `// Code generated by protoc-gen-ts. DO NOT EDIT.
+// source: user.proto
+
+export class UserRequest {}
+export function encodeUser() {}
+`
+
+const HANDWRITTEN_SRC = `import { encodeUser } from './user_pb'
+
+export class UserService {}
+export function encode() {
+  return encodeUser()
+}
+`
+
+const SOURCES = { 'src/user_pb.ts': GENERATED_SRC, 'src/user.ts': HANDWRITTEN_SRC }
+
+test('generated-code: recognizes standard headers', () => {
+  assert.ok(isGeneratedSource(GENERATED_SRC))
+  assert.ok(isGeneratedSource('#!/usr/bin/env node\n/**\n * @generated\n */\nexport {}\n'))
+  assert.ok(isGeneratedSource('# Generated by the protocol buffer compiler.  DO NOT EDIT!\nimport sys\n'))
+  assert.ok(isGeneratedSource('// <auto-generated>\n//   This code was generated by a tool.\n// </auto-generated>\nnamespace Game {}\n'))
+  assert.ok(!isGeneratedSource(HANDWRITTEN_SRC))
+  assert.ok(!isGeneratedSource('export const a = 1\n// Code generated by x. DO NOT EDIT.\n'))
+})
+
+test('generated-code: symbols of generated files are tagged and filterable', async () => {
+  const index = await indexSources(SOURCES)
+  assert.deepEqual(index.fileSymbols('src/user_pb.ts').map(s => [s.name, s.generated]), [
+    ['UserRequest', true],
+    ['encodeUser', true]
+  ])
+  assert.ok(index.fileSymbols('src/user.ts').every(s => !s.generated))
+  assert.deepEqual(querySymbols(index, { kinds: ['class'], generated: false }).map(s => s.name), ['UserService'])
+  assert.deepEqual(querySymbols(index, { kinds: ['class'], generated: true }).map(s => s.name), ['UserRequest'])
+})
+
+test('generated-code: fuzzy search ranks generated symbols lower', async () => {
+  const index = await indexSources(SOURCES)
+  // UserRequest would sort first on an equal score
+  assert.deepEqual(fuzzySearch(index, 'user').map(m => m.symbol.name), ['UserService', 'UserRequest', 'encodeUser'])
+})
+
+test('generated-code: exports can leave generated files out', async () => {
+  const filtered = excludeGenerated(await indexSources(SOURCES))
+  assert.deepEqual(filtered.listFiles(), ['src/user.ts'])
+  assert.equal(filtered.findSymbols('encodeUser').length, 0)
+})
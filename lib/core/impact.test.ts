@@ -0,0 +1,69 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { computeImpact, goTestArgs, resolveImpactTargets } from './impact.js'
+import { makeSymbolId } from './symbol-index.js'
+import type { ImportGraph, PackageEdge } from './import-graph.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+// lib/store: save; lib/api: handle calls save; cmd: main calls handle;
+// tests/test_api.py: test_handle calls handle; lib/other imports lib/store
+const FILES: FixtureFiles = {
+  'lib/store/store.py': [
+    { name: 'save', kind: 'function', line: 1, end_line: 2, exported: true },
+    { name: 'load', kind: 'function', line: 4, end_line: 5, exported: true }
+  ],
+  'lib/api/api.py': [
+    { name: 'handle', kind: 'function', line: 1, end_line: 3, exported: true },
+    { name: 'save', kind: 'reference', line: 2, end_line: 2, column: 5, call: true }
+  ],
+  'cmd/main.py': [
+    { name: 'main', kind: 'function', line: 1, end_line: 2, exported: true },
+    { name: 'handle', kind: 'reference', line: 2, end_line: 2, column: 5, call: true }
+  ],
+  'tests/test_api.py': [
+    { name: 'test_handle', kind: 'function', line: 1, end_line: 2, exported: true },
+    { name: 'handle', kind: 'reference', line: 2, end_line: 2, column: 5, call: true }
+  ],
+  'lib/other/other.py': [
+    { name: 'unrelated', kind: 'function', line: 1, end_line: 2, exported: true }
+  ],
+  'lib/other/test_other.py': [
+    { name: 'test_unrelated', kind: 'function', line: 1, end_line: 2, exported: true }
+  ]
+}
+
+function importGraph(): ImportGraph {
+  const edge: PackageEdge = { from: 'lib/other', to: 'lib/store', external: false, imports: [] }
+  return { packages: [], external: [], edges: [edge], imports: new Map(), importedBy: new Map([['lib/store', [edge]]]) }
+}
+
+test('impact: dependents, packages and tests follow references transitively', () => {
+  const index = createIndex(FILES)
+  const report = computeImpact(index, [makeSymbolId('lib/store/store.py', 'save')])
+
+  assert.deepEqual(report.dependents.map(d => [d.symbol.name, d.depth]), [['handle', 1], ['main', 2], ['test_handle', 2]])
+  assert.deepEqual(report.packages, [
+    { package: 'cmd', reason: 'reference' },
+    { package: 'lib/api', reason: 'reference' },
+    { package: 'lib/store', reason: 'changed' },
+    { package: 'tests', reason: 'reference' }
+  ])
+  assert.deepEqual(report.tests.map(t => t.name), ['test_handle'])
+  assert.deepEqual(report.testFiles, ['tests/test_api.py'])
+  assert.equal(goTestArgs(report), './cmd ./lib/api ./lib/store ./tests')
+
+  const shallow = computeImpact(index, [makeSymbolId('lib/store/store.py', 'save')], { depth: 1 })
+  assert.deepEqual(shallow.dependents.map(d => d.symbol.name), ['handle'])
+})
+
+test('impact: importers of the changed package and package-qualified names', () => {
+  const index = createIndex(FILES)
+  const [save] = resolveImpactTargets(index, 'store.save')
+  assert.equal(save.id, makeSymbolId('lib/store/store.py', 'save'))
+  assert.deepEqual(resolveImpactTargets(index, 'api.save'), [])
+
+  const report = computeImpact(index, [makeSymbolId('lib/store/store.py', 'load')], { importGraph: importGraph() })
+  assert.deepEqual(report.dependents, [])
+  assert.deepEqual(report.packages, [{ package: 'lib/other', reason: 'import' }, { package: 'lib/store', reason: 'changed' }])
+  assert.deepEqual(report.testFiles, ['lib/other/test_other.py'])
+})
@@ -0,0 +1,151 @@
+/**
+ * Impact Module
+ * What could break if a symbol changes (indexer impacted): its dependents,
+ * followed transitively through the reference graph (the declarations whose
+ * bodies use it, uses through type aliases included, and the subtypes of a
+ * type), the packages holding them and, through the import graph, every
+ * package that imports the symbol's own package directly or indirectly. The
+ * tests to run are those linked to the symbol or to a dependent (see
+ * test-map) plus the test files of the impacted packages. The result is
+ * meant for test selection in CI, so it errs on the side of more: every
+ * match of an ambiguous name counts, and a use outside any declaration
+ * still impacts its file.
+ */
+
+import path from 'path'
+import { isLocalSymbol } from './local-scopes.js'
+import { importersOf, packageOf, type ImportGraph } from './import-graph.js'
+import { isTestFile, type TestCase } from './test-map.js'
+import { DEFAULT_SCOPES } from './search-scope.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+// How a package is impacted: it holds a changed symbol, a use of one, or imports one of those
+export type ImpactReason = 'changed' | 'reference' | 'import'
+
+export interface ImpactedSymbol {
+  symbol: IndexedSymbol
+  depth: number // reference hops from a changed symbol
+  via: string // ID of the symbol it depends on
+}
+
+export interface ImpactedPackage {
+  package: string
+  reason: ImpactReason
+}
+
+export interface ImpactReport {
+  symbols: IndexedSymbol[] // the changed symbols
+  dependents: ImpactedSymbol[] // by depth, then ID
+  files: string[] // files holding a changed symbol or a use of one, sorted
+  packages: ImpactedPackage[] // sorted
+  tests: TestCase[] // linked tests, by path and line
+  testFiles: string[] // sorted
+}
+
+export interface ImpactOptions {
+  /** Reference hops to follow (unlimited by default) */
+  depth?: number
+  /** Import graph, to add the packages importing a changed symbol's package */
+  importGraph?: ImportGraph | null
+}
+
+/**
+ * Symbols a name given on the command line means: a symbol ID, a short or
+ * qualified name, or a name qualified by its package directory (store.Save
+ * for Save in lib/store)
+ */
+export function resolveImpactTargets(index: SymbolIndex, name: string): IndexedSymbol[] {
+  const byId = index.getSymbol(index.resolveSymbolId(name))
+  if (byId) return [byId]
+  const found = index.findSymbols(name)
+  if (found.length > 0 || !name.includes('.')) return found
+  const pkg = name.slice(0, name.indexOf('.'))
+  const rest = name.slice(pkg.length + 1)
+  return index.findSymbols(rest).filter(s => {
+    const dir = packageOf(s.path)
+    return dir === pkg || path.posix.basename(dir) === pkg
+  })
+}
+
+// Innermost non-local declaration around a line, or undefined at the top level
+function enclosing(symbols: IndexedSymbol[], line: number): IndexedSymbol | undefined {
+  let best: IndexedSymbol | undefined
+  for (const sym of symbols) {
+    if (isLocalSymbol(sym) || sym.kind === 'package' || sym.line > line || line > (sym.end_line ?? sym.line)) continue
+    if (!best || sym.line > best.line || (sym.line === best.line && sym.end_line < best.end_line)) best = sym
+  }
+  return best
+}
+
+/**
+ * Everything a change to some symbols could affect
+ */
+export function computeImpact(index: SymbolIndex, symbolIds: string[], options: ImpactOptions = {}): ImpactReport {
+  const maxDepth = options.depth ?? Infinity
+  const symbols = symbolIds.map(id => index.getSymbol(id)).filter((s): s is IndexedSymbol => !!s)
+  const seen = new Set(symbols.map(s => s.id))
+  const dependents: ImpactedSymbol[] = []
+  const files = new Set(symbols.map(s => s.path))
+
+  const queue = symbols.map(symbol => ({ symbol, depth: 0 }))
+  for (let i = 0; i < queue.length; i++) {
+    const { symbol, depth } = queue[i]
+    const next: IndexedSymbol[] = index.subtypes(symbol.id, true).map(h => h.symbol)
+    for (const ref of index.references(symbol.id, undefined, DEFAULT_SCOPES.references, 'all')) {
+      files.add(ref.path)
+      const around = enclosing(index.fileSymbols(ref.path), ref.line)
+      if (around) next.push(around)
+    }
+    if (depth >= maxDepth) continue
+    for (const dependent of next) {
+      if (seen.has(dependent.id)) continue
+      seen.add(dependent.id)
+      files.add(dependent.path)
+      dependents.push({ symbol: dependent, depth: depth + 1, via: symbol.id })
+      queue.push({ symbol: dependent, depth: depth + 1 })
+    }
+  }
+  dependents.sort((a, b) => a.depth - b.depth || a.symbol.id.localeCompare(b.symbol.id))
+
+  const reasons = new Map<string, ImpactReason>()
+  for (const sym of symbols) reasons.set(packageOf(sym.path), 'changed')
+  for (const file of files) if (!reasons.has(packageOf(file))) reasons.set(packageOf(file), 'reference')
+  if (options.importGraph) {
+    const pending = symbols.map(s => packageOf(s.path))
+    while (pending.length > 0) {
+      for (const edge of importersOf(options.importGraph, pending.pop()!)) {
+        if (reasons.has(edge.from)) continue
+        reasons.set(edge.from, 'import')
+        pending.push(edge.from)
+      }
+    }
+  }
+  const packages = Array.from(reasons, ([pkg, reason]) => ({ package: pkg, reason }))
+    .sort((a, b) => a.package.localeCompare(b.package))
+
+  const tests = new Map<string, TestCase>()
+  for (const id of [...symbols.map(s => s.id), ...dependents.map(d => d.symbol.id)]) {
+    for (const link of index.testsFor(id)) tests.set(link.test.id, link.test)
+  }
+  const testFiles = new Set(Array.from(tests.values(), t => t.path))
+  for (const file of index.listFiles()) {
+    if (isTestFile(file) && reasons.has(packageOf(file))) testFiles.add(file)
+  }
+
+  return {
+    symbols,
+    dependents,
+    files: Array.from(files).sort(),
+    packages,
+    tests: Array.from(tests.values()).sort((a, b) => a.path.localeCompare(b.path) || a.line - b.line),
+    testFiles: Array.from(testFiles).sort()
+  }
+}
+
+/**
+ * Impacted packages as `go test` arguments (./lib/store ./cmd/api)
+ */
+export function goTestArgs(report: ImpactReport): string {
+  return report.packages.map(p => p.package === '.' ? '.' : `./${p.package}`).join(' ')
+}
@@ -0,0 +1,147 @@
+/**
+ * Implementations Module
+ * Analysis pass that links concrete types to the interfaces they satisfy,
+ * either by declaration (implements / base lists) or structurally (the type
+ * provides every member the interface declares).
+ */
+
+import { shortName } from './symbol-index.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export const TYPE_KINDS = new Set(['class', 'struct', 'scriptable_object'])
+export const INTERFACE_KINDS = new Set(['interface'])
+
+export interface ImplementationTable {
+  implementations: Map<string, Set<string>> // interface ID -> type IDs
+  interfaces: Map<string, Set<string>> // type ID -> interface IDs
+}
+
+/**
+ * Names listed in a symbol's heritage clauses
+ */
+export function heritageOf(sym: IndexedSymbol): string[] {
+  return [
+    ...(sym.extends || []),
+    ...(sym.implements || []),
+    ...(sym.bases || [])
+  ]
+}
+
+/**
+ * Resolve a heritage name to symbols, preferring the referencing file
+ */
+export function resolveTypeName(index: SymbolIndex, name: string, fromPath: string): IndexedSymbol[] {
+  const candidates = index.findSymbols(name.replace(/<.*$/s, ''))
+    .filter(s => TYPE_KINDS.has(s.kind) || INTERFACE_KINDS.has(s.kind))
+  const local = candidates.filter(s => s.path === fromPath)
+  return local.length > 0 ? local : candidates
+}
+
+/**
+ * Member short names declared directly on a type
+ */
+export function ownMemberNames(index: SymbolIndex, type: IndexedSymbol): Set<string> {
+  const prefix = `${type.name}.`
+  const names = new Set<string>()
+  for (const s of index.fileSymbols(type.path)) {
+    if (s.name.startsWith(prefix)) names.add(shortName(s.name))
+  }
+  return names
+}
+
+function addLink(table: ImplementationTable, ifaceId: string, typeId: string): void {
+  if (!table.implementations.has(ifaceId)) table.implementations.set(ifaceId, new Set())
+  if (!table.interfaces.has(typeId)) table.interfaces.set(typeId, new Set())
+  table.implementations.get(ifaceId)!.add(typeId)
+  table.interfaces.get(typeId)!.add(ifaceId)
+}
+
+/**
+ * Compute the interface <-> implementation table for the whole index
+ * @param include - Types and interfaces to consider (all by default), such as those of one module
+ */
+export function computeImplementations(index: SymbolIndex, include?: (sym: IndexedSymbol) => boolean): ImplementationTable {
+  const table: ImplementationTable = { implementations: new Map(), interfaces: new Map() }
+  const all = include ? index.allSymbols().filter(include) : index.allSymbols()
+  const types = all.filter(s => TYPE_KINDS.has(s.kind))
+  const ifaces = all.filter(s => INTERFACE_KINDS.has(s.kind))
+
+  // Interface closure: an interface plus everything it extends
+  const ifaceClosure = new Map<string, Set<string>>()
+  function closureOf(iface: IndexedSymbol, visiting = new Set<string>()): Set<string> {
+    if (ifaceClosure.has(iface.id)) return ifaceClosure.get(iface.id)!
+    const result = new Set<string>([iface.id])
+    if (visiting.has(iface.id)) return result
+    visiting.add(iface.id)
+    for (const name of heritageOf(iface)) {
+      for (const parent of resolveTypeName(index, name, iface.path)) {
+        if (INTERFACE_KINDS.has(parent.kind)) {
+          for (const id of closureOf(parent, visiting)) result.add(id)
+        }
+      }
+    }
+    ifaceClosure.set(iface.id, result)
+    return result
+  }
+
+  // Members provided by a type, including inherited ones
+  const memberCache = new Map<string, Set<string>>()
+  function membersOf(type: IndexedSymbol, visiting = new Set<string>()): Set<string> {
+    if (memberCache.has(type.id)) return memberCache.get(type.id)!
+    const result = ownMemberNames(index, type)
+    if (visiting.has(type.id)) return result
+    visiting.add(type.id)
+    for (const name of heritageOf(type)) {
+      for (const parent of resolveTypeName(index, name, type.path)) {
+        if (TYPE_KINDS.has(parent.kind)) {
+          for (const m of membersOf(parent, visiting)) result.add(m)
+        }
+      }
+    }
+    memberCache.set(type.id, result)
+    return result
+  }
+
+  // Declared interfaces, including those inherited from base types
+  const declaredCache = new Map<string, Set<string>>()
+  function declaredOf(type: IndexedSymbol, visiting = new Set<string>()): Set<string> {
+    if (declaredCache.has(type.id)) return declaredCache.get(type.id)!
+    const result = new Set<string>()
+    if (visiting.has(type.id)) return result
+    visiting.add(type.id)
+    for (const name of heritageOf(type)) {
+      for (const parent of resolveTypeName(index, name, type.path)) {
+        if (INTERFACE_KINDS.has(parent.kind)) {
+          for (const id of closureOf(parent)) result.add(id)
+        } else {
+          for (const id of declaredOf(parent, visiting)) result.add(id)
+        }
+      }
+    }
+    declaredCache.set(type.id, result)
+    return result
+  }
+
+  for (const type of types) {
+    for (const ifaceId of declaredOf(type)) {
+      const iface = include ? index.getSymbol(ifaceId) : null
+      if (!include || (iface && include(iface))) addLink(table, ifaceId, type.id)
+    }
+
+    const members = membersOf(type)
+    for (const iface of ifaces) {
+      const closure = closureOf(iface)
+      const required = new Set<string>()
+      for (const id of closure) {
+        for (const m of index.getSymbol(id)?.members || []) required.add(m)
+      }
+      if (required.size === 0) continue
+      if ([...required].every(m => members.has(m))) {
+        for (const id of closure) addLink(table, id, type.id)
+      }
+    }
+  }
+
+  return table
+}
@@ -0,0 +1,111 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import { SymbolIndex, indexFileContents } from './symbol-index.js'
+import { computeImportGraph, findImportCycles, importersOf, importsOf, packageOf } from './import-graph.js'
+import { exportImportGraphDot, exportImportGraphJson } from '../exporters/import-graph.js'
+import { createIndex } from '../test-utils.js'
+
+const SOURCES: Record<string, string> = {
+  'index.ts': `import { serve } from './lib/api/server.js'\nserve()\n`,
+  'lib/api/server.ts': `import express from 'express'\nimport { User } from '../models/user.js'\nimport { log } from '../util'\nexport function serve() {}\n`,
+  'lib/api/routes.ts': `import { serve } from './server.js'\nimport { Team } from '../models/team'\n`,
+  'lib/models/user.ts': `import { find } from '../db/query'\nexport class User {}\n`,
+  'lib/models/team.ts': `export class Team {}\n`,
+  'lib/db/query.ts': `import type { User } from '../models/user'\nimport pg from '@databases/pg/client'\nexport function find() {}\n`,
+  'lib/util/index.ts': `export function log() {}\n`
+}
+
+const readSource = (p: string) => SOURCES[p]
+
+test('import-graph: packages are source directories', () => {
+  assert.equal(packageOf('lib/core/rename.ts'), 'lib/core')
+  assert.equal(packageOf('index.ts'), '.')
+})
+
+test('import-graph: Imports and ImportedBy between packages', async () => {
+  const graph = await computeImportGraph(createIndex(SOURCES), readSource)
+  assert.deepEqual(graph.packages, ['.', 'lib/api', 'lib/db', 'lib/models', 'lib/util'])
+  assert.deepEqual(graph.external, ['@databases/pg', 'express'])
+
+  assert.deepEqual(importsOf(graph, 'lib/api').map(e => [e.to, e.external, e.imports.length]), [
+    ['lib/models', false, 2],
+    ['lib/util', false, 1],
+    ['express', true, 1]
+  ])
+  assert.deepEqual(importsOf(graph, 'lib/api')[0].imports, [
+    { path: 'lib/api/routes.ts', line: 2, source: '../models/team' },
+    { path: 'lib/api/server.ts', line: 2, source: '../models/user.js' }
+  ])
+  assert.deepEqual(importersOf(graph, 'lib/models').map(e => e.from), ['lib/api', 'lib/db'])
+  assert.deepEqual(importersOf(graph, 'lib/api').map(e => e.from), ['.'])
+  assert.deepEqual(importsOf(graph, 'lib/util'), [])
+})
+
+test('import-graph: cycles between internal packages', async () => {
+  const graph = await computeImportGraph(createIndex(SOURCES), readSource)
+  assert.deepEqual(findImportCycles(graph), [['lib/db', 'lib/models']])
+})
+
+test('import-graph: Python modules resolve relative and sibling imports', async () => {
+  await initTreeSitter()
+  const index = new SymbolIndex()
+  const files: Record<string, string> = {
+    'app/main.py': 'from app.core import engine\nimport requests\n',
+    'app/core/engine.py': 'from ..main import run\nfrom . import util\n',
+    'app/core/util.py': 'import os\n'
+  }
+  await indexFileContents(index, Object.keys(files), Object.values(files))
+  const graph = await computeImportGraph(index, p => files[p])
+  assert.deepEqual(importsOf(graph, 'app').map(e => e.to), ['app/core', 'requests'])
+  assert.deepEqual(importsOf(graph, 'app/core').map(e => e.to), ['app', 'os'])
+  assert.deepEqual(findImportCycles(graph), [['app', 'app/core']])
+})
+
+test('import-graph: workspace member imports resolve to their sources', async () => {
+  const files: Record<string, string> = {
+    'packages/api/src/server.ts': `import { slug } from '@acme/util'\nimport { fmt } from '@acme/util/format'\nimport express from 'express'\n`,
+    'packages/util/src/index.ts': `export function slug() {}\n`,
+    'packages/util/src/format/index.ts': `export function fmt() {}\n`
+  }
+  const index = new SymbolIndex()
+  for (const [filePath, code] of Object.entries(files)) {
+    index.addFile(filePath, 'typescript', extractJSSymbols(code))
+  }
+  const member = (name: string, dir: string, entry?: string) =>
+    ({ name, version: '1.0.0', dir, dependencies: {}, resolved: [], ...(entry ? { entry } : {}) })
+  const packageGraph = {
+    root: member('monorepo', ''),
+    workspaces: [member('@acme/api', 'packages/api'), member('@acme/util', 'packages/util', 'dist/index.js')],
+    packages: []
+  }
+
+  const graph = await computeImportGraph(index, p => files[p], { packageGraph })
+  assert.deepEqual(importsOf(graph, 'packages/api/src').map(e => [e.to, e.external]), [
+    ['packages/util/src', false],
+    ['packages/util/src/format', false],
+    ['express', true]
+  ])
+  const unresolved = await computeImportGraph(index, p => files[p])
+  assert.deepEqual(unresolved.external, ['@acme/util', 'express'])
+})
+
+test('import-graph: DOT and JSON exports', async () => {
+  const graph = await computeImportGraph(createIndex(SOURCES), readSource)
+  const dot = exportImportGraphDot(graph)
+  assert.match(dot, /^digraph imports \{/)
+  assert.match(dot, /"lib\/db" -> "lib\/models" \[label="1", color=red\];/)
+  assert.match(dot, /"lib\/api" -> "lib\/util" \[label="1"\];/)
+  assert.doesNotMatch(dot, /express/)
+  assert.match(exportImportGraphDot(graph, { external: true }), /"lib\/api" -> "ext:express" \[label="1", color=grey\];/)
+
+  const json = JSON.parse(exportImportGraphJson(graph))
+  assert.deepEqual(json.packages.find((p: any) => p.name === 'lib/models'), {
+    name: 'lib/models',
+    imports: ['lib/db'],
+    imported_by: ['lib/api', 'lib/db']
+  })
+  assert.equal(json.external, undefined)
+  assert.deepEqual(json.cycles, [['lib/db', 'lib/models']])
+})
@@ -0,0 +1,259 @@
+/**
+ * Import Graph Module
+ * Package-level import graph over the whole index. A package is a source
+ * directory ("lib/core", "." for the root); an edge collects every import
+ * statement from files in one package that resolves to another. Relative
+ * imports resolve against the indexed files, so the graph is the same for the
+ * working tree and for a stored revision; imports that resolve nowhere in
+ * the index are edges to an external package named after the import. With a
+ * package graph, imports of workspace members resolve to their sources.
+ */
+
+import path from 'path'
+import { getLanguageBackend } from '../utils/language-backends.js'
+import { workspaceMemberOf, type PackageGraph } from './package-graph.js'
+import type { SymbolIndex } from './symbol-index.js'
+
+const JS_EXTENSIONS = ['.ts', '.tsx', '.js', '.jsx', '.d.ts', '.mjs', '.cjs']
+
+export interface ImportSite {
+  path: string // importing file
+  line: number
+  source: string // import specifier as written
+}
+
+export interface PackageEdge {
+  from: string
+  to: string
+  external: boolean
+  imports: ImportSite[]
+}
+
+export interface ImportGraph {
+  packages: string[] // internal packages, sorted
+  external: string[] // external packages, sorted
+  edges: PackageEdge[]
+  imports: Map<string, PackageEdge[]> // package -> edges to what it imports
+  importedBy: Map<string, PackageEdge[]> // package -> edges from its importers
+}
+
+/**
+ * Package (source directory) a file belongs to
+ */
+export function packageOf(filePath: string): string {
+  return path.posix.dirname(filePath.replace(/\\/g, '/'))
+}
+
+/**
+ * External package an unresolved specifier names ("@scope/pkg/sub" -> "@scope/pkg",
+ * "numpy.linalg" -> "numpy", "System.Text" -> "System")
+ */
+function externalName(source: string, lang: string): string {
+  if (lang === 'javascript' || lang === 'typescript') {
+    const parts = source.replace(/^node:/, '').split('/')
+    return source.startsWith('@') ? parts.slice(0, 2).join('/') : parts[0]
+  }
+  return source.split('.')[0]
+}
+
+function firstIndexed(files: Set<string>, candidates: string[]): string | null {
+  return candidates.find(c => files.has(c)) || null
+}
+
+/**
+ * Indexed file a JS module path refers to, trying extensions and index files
+ */
+function resolveJsFile(files: Set<string>, base: string): string | null {
+  const stems = [base, base.replace(/\.[cm]?jsx?$/, '')]
+  return firstIndexed(files, stems.flatMap(stem => [
+    stem,
+    ...JS_EXTENSIONS.map(ext => stem + ext),
+    ...JS_EXTENSIONS.map(ext => `${stem}/index${ext}`)
+  ]))
+}
+
+/**
+ * Source file a workspace member import refers to: the subpath (under the
+ * member or its src/), else the member's entry point or index
+ */
+function resolveWorkspaceImport(files: Set<string>, packageGraph: PackageGraph | null | undefined, source: string): string | null {
+  const found = packageGraph && workspaceMemberOf(packageGraph, source)
+  if (!found) return null
+  const { member, subpath } = found
+  const entry = member.entry?.replace(/\.d\.ts$/, '')
+  const bases = subpath
+    ? [`${member.dir}/${subpath}`, `${member.dir}/src/${subpath}`]
+    : [
+        // Built entry points ("dist/index.js") map back to src/
+        ...(entry ? [path.posix.join(member.dir, entry), path.posix.join(member.dir, entry.replace(/^(dist|lib|build|out)\//, 'src/'))] : []),
+        `${member.dir}/index`,
+        `${member.dir}/src/index`
+      ]
+  for (const base of bases) {
+    const target = resolveJsFile(files, base)
+    if (target) return target
+  }
+  return null
+}
+
+/**
+ * Internal package an import refers to, or null when it points outside the index
+ */
+function resolvePackage(
+  files: Set<string>,
+  packages: Set<string>,
+  fromPath: string,
+  source: string,
+  lang: string,
+  packageGraph?: PackageGraph | null
+): string | null {
+  const dir = packageOf(fromPath)
+  if (lang === 'javascript' || lang === 'typescript') {
+    if (!source.startsWith('./') && !source.startsWith('../')) {
+      const target = resolveWorkspaceImport(files, packageGraph, source)
+      return target && packageOf(target)
+    }
+    const target = resolveJsFile(files, path.posix.normalize(path.posix.join(dir, source)))
+    return target && packageOf(target)
+  }
+  if (lang === 'python') {
+    const dots = source.match(/^\.*/)![0].length
+    const modulePath = source.slice(dots).replace(/\./g, '/')
+    // Relative imports climb from the importing package; absolute ones try the root, then siblings
+    const bases = dots > 0
+      ? [path.posix.join(dir, ...Array(dots - 1).fill('..'))]
+      : ['.', dir]
+    for (const b of bases) {
+      const stem = path.posix.normalize(path.posix.join(b, modulePath))
+      if (files.has(`${stem}.py`)) return packageOf(`${stem}.py`)
+      // A package directory, with or without __init__.py
+      if (packages.has(stem) && (dots > 0 || modulePath)) return stem
+    }
+    return null
+  }
+  if (lang === 'csharp') {
+    const target = firstIndexed(files, [`${source.replace(/\./g, '/')}.cs`])
+    return target && packageOf(target)
+  }
+  return null
+}
+
+export interface ImportGraphOptions {
+  /** Package graph of the project, for resolving imports of workspace members */
+  packageGraph?: PackageGraph | null
+}
+
+/**
+ * Build the import graph from the sources of every indexed file
+ */
+export async function computeImportGraph(
+  index: SymbolIndex,
+  readSource: (relPath: string) => Promise<string | null> | string | null | undefined,
+  options: ImportGraphOptions = {}
+): Promise<ImportGraph> {
+  const files = new Set(index.listFiles())
+  const packages = new Set(Array.from(files, packageOf))
+  const external = new Set<string>()
+  const byPair = new Map<string, PackageEdge>()
+
+  for (const filePath of files) {
+    const from = packageOf(filePath)
+    const lang = index.getFile(filePath)?.lang || ''
+    const backend = getLanguageBackend(lang)
+    if (!backend) continue
+    const code = await readSource(filePath)
+    if (code == null) continue
+
+    for (const imp of await backend.extractImports(code)) {
+      const target = resolvePackage(files, packages, filePath, imp.source, lang, options.packageGraph)
+      // Unresolved relative imports point at missing files, not at a package
+      if (!target && /^\./.test(imp.source)) continue
+      const to = target ?? externalName(imp.source, lang)
+      if (to === from) continue
+      if (!target) external.add(to)
+
+      const key = `${from}\u0000${to}\u0000${!target}`
+      let edge = byPair.get(key)
+      if (!edge) {
+        edge = { from, to, external: !target, imports: [] }
+        byPair.set(key, edge)
+      }
+      edge.imports.push({ path: filePath, line: imp.line, source: imp.source })
+    }
+  }
+
+  const edges = Array.from(byPair.values()).sort((a, b) =>
+    a.from.localeCompare(b.from) || Number(a.external) - Number(b.external) || a.to.localeCompare(b.to)
+  )
+  const graph: ImportGraph = {
+    packages: Array.from(packages).sort(),
+    external: Array.from(external).sort(),
+    edges,
+    imports: new Map(),
+    importedBy: new Map()
+  }
+  for (const edge of edges) {
+    edge.imports.sort((a, b) => a.path.localeCompare(b.path) || a.line - b.line)
+    graph.imports.set(edge.from, [...(graph.imports.get(edge.from) || []), edge])
+    if (!edge.external) graph.importedBy.set(edge.to, [...(graph.importedBy.get(edge.to) || []), edge])
+  }
+  return graph
+}
+
+/**
+ * Packages a package imports, internal ones first
+ */
+export function importsOf(graph: ImportGraph, pkg: string): PackageEdge[] {
+  return graph.imports.get(pkg) || []
+}
+
+/**
+ * Internal packages that import a package
+ */
+export function importersOf(graph: ImportGraph, pkg: string): PackageEdge[] {
+  return graph.importedBy.get(pkg) || []
+}
+
+/**
+ * Import cycles among internal packages: every strongly connected group of
+ * two or more packages, each sorted, in package order
+ */
+export function findImportCycles(graph: ImportGraph): string[][] {
+  // Tarjan's algorithm
+  const order = new Map<string, number>()
+  const low = new Map<string, number>()
+  const stack: string[] = []
+  const onStack = new Set<string>()
+  const cycles: string[][] = []
+  let counter = 0
+
+  function visit(pkg: string) {
+    order.set(pkg, counter)
+    low.set(pkg, counter++)
+    stack.push(pkg)
+    onStack.add(pkg)
+    for (const edge of importsOf(graph, pkg)) {
+      if (edge.external) continue
+      if (!order.has(edge.to)) {
+        visit(edge.to)
+        low.set(pkg, Math.min(low.get(pkg)!, low.get(edge.to)!))
+      } else if (onStack.has(edge.to)) {
+        low.set(pkg, Math.min(low.get(pkg)!, order.get(edge.to)!))
+      }
+    }
+    if (low.get(pkg) !== order.get(pkg)) return
+    const group: string[] = []
+    let member: string
+    do {
+      member = stack.pop()!
+      onStack.delete(member)
+      group.push(member)
+    } while (member !== pkg)
+    if (group.length > 1) cycles.push(group.sort())
+  }
+
+  for (const pkg of graph.packages) {
+    if (!order.has(pkg)) visit(pkg)
+  }
+  return cycles.sort((a, b) => a[0].localeCompare(b[0]))
+}
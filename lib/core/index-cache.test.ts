@@ -0,0 +1,63 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { SymbolIndex, syncSymbolIndex } from './symbol-index.js'
+import { readIndexCache, reusableShards } from './index-cache.js'
+import { writeIndexFile } from './index-merge.js'
+import type { FileShard } from '../types/index.js'
+
+const FILES: Record<string, string> = {
+  'src/billing/invoice.ts': 'export class Invoice {}\n',
+  'src/billing/tax.ts': 'export function tax() { return 0 }\n',
+  'src/users/user.ts': 'export class User {}\n',
+  'src/app.ts': 'export function main() {}\n'
+}
+
+async function writeFiles(root: string, files: Record<string, string>) {
+  for (const [relPath, content] of Object.entries(files)) {
+    await fs.mkdir(path.join(root, path.dirname(relPath)), { recursive: true })
+    await fs.writeFile(path.join(root, relPath), content)
+  }
+}
+
+test('index-cache: only packages with the same files and hashes are reused', () => {
+  const shard = (p: string, hash: string): FileShard => ({ path: p, lang: 'typescript', hash, symbols: [], references: [] })
+  const cache = [shard('a/x.ts', '1'), shard('a/y.ts', '2'), shard('b/x.ts', '3'), shard('c/x.ts', '4'), shard('c/y.ts', '5')]
+  const reused = reusableShards(cache, ['a/x.ts', 'a/y.ts', 'b/x.ts', 'b/new.ts', 'c/x.ts', 'd/x.ts'], ['1', '2', '3', '6', '4', '7'])
+  // b gained a file, c lost one, d is new
+  assert.deepEqual(reused.map(s => s.path), ['a/x.ts', 'a/y.ts'])
+  assert.deepEqual(reusableShards(cache, ['a/x.ts', 'a/y.ts'], ['1', 'changed']), [])
+})
+
+test('index-cache: a build seeded from an index file parses only changed packages', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'index-cache-test-'))
+  try {
+    await writeFiles(root, FILES)
+    const relPaths = Object.keys(FILES).sort()
+    const previous = new SymbolIndex()
+    await syncSymbolIndex(previous, root, relPaths)
+    const cacheFile = path.join(root, 'previous.idx')
+    await writeIndexFile(cacheFile, previous.listShards())
+
+    await writeFiles(root, { 'src/billing/tax.ts': 'export function tax() { return 1 }\n' })
+    const cache = await readIndexCache(cacheFile)
+    const index = new SymbolIndex()
+    const update = await syncSymbolIndex(index, root, relPaths, cache)
+    assert.deepEqual(update.cached!.sort(), ['src/app.ts', 'src/users/user.ts'])
+    // Seeded files are new to the index, so they are stored like parsed ones
+    assert.deepEqual(update.added.sort(), relPaths)
+    assert.deepEqual(update.unchanged, [])
+    assert.equal(index.getFile('src/users/user.ts')!.hash, previous.getFile('src/users/user.ts')!.hash)
+    assert.notEqual(index.getFile('src/billing/tax.ts')!.hash, previous.getFile('src/billing/tax.ts')!.hash)
+
+    // An index that is already current takes nothing from the cache
+    assert.deepEqual((await syncSymbolIndex(index, root, relPaths, cache)).cached, undefined)
+
+    await fs.writeFile(path.join(root, 'bogus.idx'), 'not an index')
+    await assert.rejects(readIndexCache(path.join(root, 'bogus.idx')), /is not an index file/)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
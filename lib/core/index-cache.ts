@@ -0,0 +1,69 @@
+/**
+ * Index Cache Module
+ * Warm starts from a previous build's index file (--cache-from=old.idx), so
+ * a CI machine without a stored index only parses what changed. A package
+ * (directory) is taken from the cache when it holds the same files with the
+ * same content hashes. A content hash covers what a shard depends on
+ * besides its file, the extraction passes and the analyzers, so a cache
+ * written with other passes is simply not used; neither is one in another
+ * shard format (see index-format). Names resolve across packages when the
+ * index is queried, not when it is built, so an unchanged package's shards
+ * stay valid whatever changed around it. A package with a changed, added or
+ * removed file is indexed again in full, which re-runs its analyzers too.
+ */
+
+import { packageOf } from './import-graph.js'
+import { verifyShards } from './index-integrity.js'
+import { SHARD_FORMAT_VERSION } from './index-format.js'
+import { SymbolPackReader } from '../utils/symbol-pack.js'
+import type { FileShard } from '../types/index.js'
+
+/**
+ * Shards of an index file written by indexer export --format=idx; shards
+ * that fail their checksum are left out, so their packages are indexed
+ * again, and a file in another shard format gives none
+ */
+export async function readIndexCache(filePath: string): Promise<FileShard[]> {
+  const reader = await SymbolPackReader.open(filePath)
+  if (!reader) throw new Error(`${filePath} is not an index file; write one with "indexer export --format=idx"`)
+  try {
+    if (reader.merged) throw new Error(`${filePath} is a merged index; cache from this repository's own index file`)
+    if (reader.shardFormat !== SHARD_FORMAT_VERSION) return []
+    return verifyShards(await reader.readShards()).intact
+  } finally {
+    await reader.close()
+  }
+}
+
+/**
+ * Cached shards of the packages whose files are all unchanged
+ * @param cache - Shards of the previous index
+ * @param relPaths - Files of the tree being indexed
+ * @param hashes - Their content hashes, in input order (null for missing files)
+ */
+export function reusableShards(cache: FileShard[], relPaths: string[], hashes: (string | null)[]): FileShard[] {
+  const cached = new Map<string, Map<string, FileShard>>()
+  for (const shard of cache) {
+    const dir = packageOf(shard.path)
+    const ofDir = cached.get(dir)
+    if (ofDir) ofDir.set(shard.path, shard)
+    else cached.set(dir, new Map([[shard.path, shard]]))
+  }
+  const current = new Map<string, { path: string, hash: string }[]>()
+  relPaths.forEach((relPath, i) => {
+    const hash = hashes[i]
+    if (hash === null) return
+    const dir = packageOf(relPath)
+    const ofDir = current.get(dir)
+    if (ofDir) ofDir.push({ path: relPath, hash })
+    else current.set(dir, [{ path: relPath, hash }])
+  })
+
+  const shards: FileShard[] = []
+  for (const [dir, files] of current) {
+    const ofDir = cached.get(dir)
+    if (!ofDir || ofDir.size !== files.length || !files.every(f => ofDir.get(f.path)?.hash === f.hash)) continue
+    shards.push(...files.map(f => ofDir.get(f.path)!))
+  }
+  return shards
+}
@@ -0,0 +1,54 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { CHECKPOINT_FORMAT, changedSinceCheckpoint, checkpointMatches, type IndexCheckpoint } from './index-checkpoint.js'
+import { SHARD_FORMAT_VERSION } from './index-format.js'
+
+const BEFORE_SHUTDOWN = Date.parse('2024-03-01T12:00:00.000Z')
+const AFTER_SHUTDOWN = Date.parse('2024-03-01T12:05:00.000Z')
+
+function checkpoint(files: IndexCheckpoint['files']): IndexCheckpoint {
+  return {
+    format: CHECKPOINT_FORMAT,
+    shard_format: SHARD_FORMAT_VERSION,
+    passes: 'v1:',
+    written_at: '2024-03-01T12:01:00.000Z',
+    watermark: Date.parse('2024-03-01T12:00:58.000Z'),
+    files
+  }
+}
+
+test('checkpointMatches needs the same files and passes', () => {
+  const cp = checkpoint({ 'a.ts': [1, 2], 'b.ts': [3, 4] })
+  assert.equal(checkpointMatches(cp, ['b.ts', 'a.ts'], 'v1:'), true)
+  assert.equal(checkpointMatches(cp, ['a.ts'], 'v1:'), false)
+  assert.equal(checkpointMatches(cp, ['a.ts', 'c.ts'], 'v1:'), false)
+  assert.equal(checkpointMatches(cp, ['a.ts', 'b.ts'], 'v1:locals'), false)
+})
+
+test('changedSinceCheckpoint re-reads new, changed and late files', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'checkpoint-test-'))
+  try {
+    const write = async (name: string, content: string, mtime: number) => {
+      await fs.writeFile(path.join(root, name), content)
+      await fs.utimes(path.join(root, name), mtime / 1000, mtime / 1000)
+      const stat = await fs.stat(path.join(root, name))
+      return [stat.mtimeMs, stat.size] as [number, number]
+    }
+    const same = await write('same.ts', 'export const a = 1\n', BEFORE_SHUTDOWN)
+    const edited = await write('edited.ts', 'export const b = 1\n', BEFORE_SHUTDOWN)
+    await write('edited.ts', 'export const b = 22\n', AFTER_SHUTDOWN)
+    // Written just before the shutdown; the watcher may not have seen it
+    const late = await write('late.ts', 'export const c = 1\n', Date.parse('2024-03-01T12:00:59.000Z'))
+    await write('new.ts', 'export const d = 1\n', AFTER_SHUTDOWN)
+
+    const cp = checkpoint({ 'same.ts': same, 'edited.ts': edited, 'late.ts': late, 'gone.ts': [BEFORE_SHUTDOWN, 10] })
+    const { changed, unchanged } = await changedSinceCheckpoint(root, cp, ['same.ts', 'edited.ts', 'late.ts', 'new.ts'])
+    assert.deepEqual(unchanged, ['same.ts'])
+    assert.deepEqual(changed, ['edited.ts', 'late.ts', 'new.ts'])
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,130 @@
+/**
+ * Index Checkpoint Module
+ * What a watching process (watch, serve --watch, the query daemon) knew
+ * about the tree when it shut down cleanly, so the next start re-reads only
+ * the files that changed since instead of every file. A checkpoint records
+ * the modification time and size of every indexed file after the store was
+ * brought up to date, the passes the index was built with and a watermark:
+ * a file modified at or after it may have changed after the last update was
+ * applied, so it is re-read whatever its recorded state. A checkpoint is
+ * used once; it is deleted when read, and ignored when the stored files,
+ * shard format or passes differ from what it recorded.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { SHARD_FORMAT_VERSION } from './index-format.js'
+import { mapConcurrent } from './parse-pool.js'
+import { readConcurrency } from './index-limits.js'
+import { getGlobalConfigDir, getProjectCollectionName } from '../utils/config-global.js'
+
+export const CHECKPOINT_FORMAT = 1
+
+// File system events reach the watcher a little after the write; changes
+// this close to the shutdown are re-read on the next start
+const WATERMARK_SLACK_MS = 2000
+
+type FileState = [mtimeMs: number, size: number]
+
+export interface IndexCheckpoint {
+  format: number
+  shard_format: number
+  passes: string // content hash salt of the index (extraction passes, analyzers, limits)
+  written_at: string // ISO time
+  watermark: number // ms since the epoch; files modified at or after it are re-read
+  files: Record<string, FileState>
+}
+
+export function getCheckpointPath(projectRoot: string): string {
+  return path.join(getGlobalConfigDir(), 'checkpoints', `${getProjectCollectionName(projectRoot)}.json`)
+}
+
+async function fileState(projectRoot: string, relPath: string): Promise<FileState | null> {
+  try {
+    const stat = await fs.stat(path.join(projectRoot, relPath))
+    return [stat.mtimeMs, stat.size]
+  } catch {
+    return null
+  }
+}
+
+/**
+ * Record the state of the indexed files; call once the store holds the index
+ * @param relPaths - Files of the index
+ * @param passes - Content hash salt of the index
+ */
+export async function writeCheckpoint(projectRoot: string, relPaths: string[], passes: string, now = Date.now()): Promise<IndexCheckpoint> {
+  const states = await mapConcurrent(relPaths, readConcurrency(), relPath => fileState(projectRoot, relPath))
+  const files: Record<string, FileState> = {}
+  relPaths.forEach((relPath, i) => {
+    const state = states[i]
+    if (state) files[relPath] = state
+  })
+  const checkpoint: IndexCheckpoint = {
+    format: CHECKPOINT_FORMAT,
+    shard_format: SHARD_FORMAT_VERSION,
+    passes,
+    written_at: new Date(now).toISOString(),
+    watermark: now - WATERMARK_SLACK_MS,
+    files
+  }
+  const file = getCheckpointPath(projectRoot)
+  await fs.mkdir(path.dirname(file), { recursive: true })
+  await fs.writeFile(`${file}.tmp`, JSON.stringify(checkpoint))
+  await fs.rename(`${file}.tmp`, file)
+  return checkpoint
+}
+
+/**
+ * Read and delete a project's checkpoint; null if there is none of this
+ * format and shard format
+ */
+export async function takeCheckpoint(projectRoot: string): Promise<IndexCheckpoint | null> {
+  const file = getCheckpointPath(projectRoot)
+  let checkpoint: IndexCheckpoint
+  try {
+    checkpoint = JSON.parse(await fs.readFile(file, 'utf8'))
+  } catch {
+    return null
+  } finally {
+    await fs.rm(file, { force: true })
+  }
+  if (checkpoint?.format !== CHECKPOINT_FORMAT || checkpoint.shard_format !== SHARD_FORMAT_VERSION) return null
+  if (!checkpoint.files || typeof checkpoint.files !== 'object') return null
+  return checkpoint
+}
+
+/**
+ * Whether a checkpoint describes an index: the same files, built with the
+ * same passes
+ */
+export function checkpointMatches(checkpoint: IndexCheckpoint, indexedFiles: string[], passes: string): boolean {
+  if (checkpoint.passes !== passes) return false
+  const recorded = Object.keys(checkpoint.files)
+  if (recorded.length !== indexedFiles.length) return false
+  return indexedFiles.every(f => Object.hasOwn(checkpoint.files, f))
+}
+
+/**
+ * Files of the tree that may differ from what the checkpoint recorded:
+ * new, of another modification time or size, or modified at or after the
+ * watermark. Files the checkpoint recorded that are not in the tree are
+ * left to the caller.
+ */
+export async function changedSinceCheckpoint(
+  projectRoot: string,
+  checkpoint: IndexCheckpoint,
+  relPaths: string[]
+): Promise<{ changed: string[], unchanged: string[] }> {
+  const states = await mapConcurrent(relPaths, readConcurrency(), relPath => fileState(projectRoot, relPath))
+  const changed: string[] = []
+  const unchanged: string[] = []
+  relPaths.forEach((relPath, i) => {
+    const state = states[i]
+    const recorded = Object.hasOwn(checkpoint.files, relPath) ? checkpoint.files[relPath] : null
+    const same = state && recorded && state[0] === recorded[0] && state[1] === recorded[1] && state[0] < checkpoint.watermark
+    if (same) unchanged.push(relPath)
+    else changed.push(relPath)
+  })
+  return { changed, unchanged }
+}
@@ -0,0 +1,47 @@
+/**
+ * Index Format Module
+ * Every stored index records the format of its shards: the stores (sqlite,
+ * json, pack, sharded) next to the shards, index files (.idx) in their
+ * header. An index in another format is never read as if it were current;
+ * opening one fails with an IndexFormatError instead. One written by a newer
+ * indexer cannot be read at all, one written by an older indexer is upgraded
+ * in place by indexer migrate (see index-migrate). Indexes stored before
+ * formats were recorded report format 0.
+ */
+
+import type { SymbolStore } from '../utils/symbol-store.js'
+
+// Bump when stored shards change, and register a migration in index-migrate
+export const SHARD_FORMAT_VERSION = 24
+
+export class IndexFormatError extends Error {
+  /**
+   * @param location - What holds the index, for the message
+   * @param migrate - Command that upgrades it
+   */
+  constructor(readonly format: number, location: string, migrate = 'indexer migrate') {
+    super(format > SHARD_FORMAT_VERSION
+      ? `${location} is in index format ${format}, written by a newer indexer; this one reads format ${SHARD_FORMAT_VERSION}. Upgrade the indexer or rebuild the index.`
+      : `${location} is in index format ${format || 'unrecorded'}, written by an older indexer; this one reads format ${SHARD_FORMAT_VERSION}. Run "${migrate}" to upgrade it.`)
+    this.name = 'IndexFormatError'
+  }
+}
+
+/**
+ * Shard format of a store's index, the current one for stores that do not
+ * record it
+ * @returns null if nothing has been stored yet
+ */
+export async function storedFormat(store: SymbolStore): Promise<number | null> {
+  return store.loadFormat ? store.loadFormat() : SHARD_FORMAT_VERSION
+}
+
+/**
+ * Fail when a stored index is not in the current format
+ * @param format - Its format, null if nothing is stored
+ * @param location - What holds the index, for the message
+ * @param migrate - Command that upgrades it
+ */
+export function checkIndexFormat(format: number | null, location: string, migrate?: string): void {
+  if (format !== null && format !== SHARD_FORMAT_VERSION) throw new IndexFormatError(format, location, migrate)
+}
@@ -0,0 +1,66 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { diffGenerations, parseDuration, resolveGeneration, type Generation } from './index-history.js'
+
+function generation(n: number, recordedAt: string): Generation {
+  return { generation: n, recorded_at: recordedAt, files: 1, format: 24, file: `${n}.idxpack` }
+}
+
+const GENERATIONS = [
+  generation(3, '2024-05-01T10:00:00.000Z'),
+  generation(4, '2024-05-06T10:00:00.000Z'),
+  generation(5, '2024-05-08T09:00:00.000Z')
+]
+
+test('resolveGeneration takes a number, a date or a time ago', () => {
+  const now = new Date('2024-05-08T12:00:00.000Z')
+  assert.equal(resolveGeneration(GENERATIONS, '4', now).generation, 4)
+  assert.equal(resolveGeneration(GENERATIONS, '2024-05-07', now).generation, 4)
+  assert.equal(resolveGeneration(GENERATIONS, '1h', now).generation, 5)
+  assert.equal(resolveGeneration(GENERATIONS, '7d', now).generation, 3)
+  assert.throws(() => resolveGeneration(GENERATIONS, '2w', now), /No index generation as old as/)
+  assert.throws(() => resolveGeneration(GENERATIONS, '9', now), /recorded: 3, 4, 5/)
+  assert.throws(() => resolveGeneration(GENERATIONS, 'last week', now), /Invalid generation/)
+  assert.throws(() => resolveGeneration([], '1', now), /No index generations are recorded/)
+})
+
+test('parseDuration reads a number and a unit', () => {
+  assert.equal(parseDuration('30m'), 30 * 60 * 1000)
+  assert.equal(parseDuration('2w'), 14 * 24 * 60 * 60 * 1000)
+  assert.throws(() => parseDuration('soon'), /Invalid duration/)
+})
+
+test('diffGenerations finds moved and renamed symbols', () => {
+  const before = new SymbolIndex()
+  before.addFile('src/config.ts', 'typescript', [
+    { name: 'parseConfig', kind: 'function', line: 3, end_line: 9 },
+    { name: 'loadConfig', kind: 'function', line: 11, end_line: 20, body_hash: 'a1b2' },
+    { name: 'Settings', kind: 'interface', line: 22, end_line: 25 },
+    { name: 'legacy', kind: 'function', line: 27, end_line: 30 }
+  ])
+  const after = new SymbolIndex()
+  after.addFile('src/config.ts', 'typescript', [
+    { name: 'readConfig', kind: 'function', line: 1, end_line: 10, body_hash: 'a1b2' },
+    { name: 'Options', kind: 'interface', line: 22, end_line: 25 }
+  ])
+  after.addFile('lib/parse.ts', 'typescript', [
+    { name: 'parseConfig', kind: 'function', line: 1, end_line: 7 },
+    { name: 'validate', kind: 'function', line: 9, end_line: 12 }
+  ])
+
+  const changes = diffGenerations(before, after)
+  assert.deepEqual(changes.map(c => [c.change, c.before?.name, c.after?.name]), [
+    ['moved', 'parseConfig', 'parseConfig'],
+    ['added', undefined, 'validate'],
+    ['renamed', 'loadConfig', 'readConfig'],
+    ['renamed', 'Settings', 'Options'],
+    ['removed', 'legacy', undefined]
+  ])
+  assert.deepEqual(changes[0].before, { name: 'parseConfig', path: 'src/config.ts', line: 3 })
+  assert.deepEqual(changes[0].after, { name: 'parseConfig', path: 'lib/parse.ts', line: 1 })
+
+  const scoped = diffGenerations(before, after, { packages: ['./lib'] })
+  assert.deepEqual(scoped.map(c => c.change), ['moved', 'added'])
+  assert.deepEqual(diffGenerations(after, after), [])
+})
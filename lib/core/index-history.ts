@@ -0,0 +1,278 @@
+/**
+ * Index History Module
+ * Past generations of a project's symbol index, kept as symbol packs so a
+ * query can run against the index as it was ("where was this symbol
+ * defined last week"), and the symbols that moved or were renamed between
+ * two generations. A generation is recorded when a sync changes the index,
+ * at most once per interval, and only the newest are kept; with no history:
+ * entry in .indexer/to-index (or INDEXER_HISTORY) nothing is recorded.
+ *
+ * Between generations, a symbol of the same name and kind in another file
+ * moved; one of the same kind with another name renamed when its body is
+ * structurally the same (body_hash) or it is declared at the same place.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { loadToIndexConfig } from './file-filters.js'
+import { stampChecksum } from './index-integrity.js'
+import { SHARD_FORMAT_VERSION } from './index-format.js'
+import { isLocalSymbol } from './local-scopes.js'
+import { matchesPackage } from './symbol-query.js'
+import type { SymbolIndex } from './symbol-index.js'
+import { writeSymbolPack } from '../utils/symbol-pack.js'
+import { getGlobalConfigDir, getProjectCollectionName } from '../utils/config-global.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+export const HISTORY_FORMAT = 1
+
+export interface HistoryPolicy {
+  /** Generations to keep, 0 to record none */
+  keep: number
+  /** Least time between two recorded generations */
+  intervalMs: number
+}
+
+export const DEFAULT_HISTORY_POLICY: HistoryPolicy = { keep: 0, intervalMs: 60 * 60 * 1000 }
+
+export interface Generation {
+  generation: number
+  recorded_at: string // ISO time
+  files: number
+  format: number // shard format of the pack
+  file: string // pack, relative to the history directory
+}
+
+interface HistoryManifest {
+  format: number
+  next: number
+  generations: Generation[] // oldest first
+}
+
+const DURATION_UNITS: Record<string, number> = {
+  s: 1000, m: 60 * 1000, h: 60 * 60 * 1000, d: 24 * 60 * 60 * 1000, w: 7 * 24 * 60 * 60 * 1000
+}
+
+/**
+ * Milliseconds of a duration such as 30m, 12h, 7d or 2w
+ * @throws Error if the value is not one
+ */
+export function parseDuration(value: string): number {
+  const match = /^\s*(\d+(?:\.\d+)?)\s*([smhdw])\s*$/i.exec(value)
+  if (!match) throw new Error(`Invalid duration "${value}". Use a number and a unit (s, m, h, d, w), such as 12h or 7d`)
+  return Number(match[1]) * DURATION_UNITS[match[2].toLowerCase()]
+}
+
+function parseKeep(value: string): number {
+  const keep = Number(value.trim())
+  if (!Number.isInteger(keep) || keep < 0) throw new Error(`Invalid history "${value}". Use the number of generations to keep, 0 for none`)
+  return keep
+}
+
+/**
+ * History policy of a project: INDEXER_HISTORY and INDEXER_HISTORY_INTERVAL,
+ * else the history: and history-interval: entries of .indexer/to-index,
+ * else the defaults
+ */
+export async function loadHistoryPolicy(projectRoot: string): Promise<HistoryPolicy> {
+  const toIndex = await loadToIndexConfig(projectRoot)
+  const keep = process.env.INDEXER_HISTORY || toIndex?.history
+  const interval = process.env.INDEXER_HISTORY_INTERVAL || toIndex?.historyInterval
+  return {
+    keep: keep ? parseKeep(keep) : DEFAULT_HISTORY_POLICY.keep,
+    intervalMs: interval ? parseDuration(interval) : DEFAULT_HISTORY_POLICY.intervalMs
+  }
+}
+
+/**
+ * Directory holding a project's generations
+ */
+export function getHistoryDir(projectRoot: string): string {
+  return path.join(getGlobalConfigDir(), 'symbol-history', getProjectCollectionName(projectRoot))
+}
+
+async function readManifest(dir: string): Promise<HistoryManifest> {
+  try {
+    const manifest = JSON.parse(await fs.readFile(path.join(dir, 'history.json'), 'utf8'))
+    if (manifest.format === HISTORY_FORMAT && Array.isArray(manifest.generations)) return manifest
+  } catch (e: any) {
+    if (e.code !== 'ENOENT' && !(e instanceof SyntaxError)) throw e
+  }
+  return { format: HISTORY_FORMAT, next: 1, generations: [] }
+}
+
+async function writeManifest(dir: string, manifest: HistoryManifest): Promise<void> {
+  const file = path.join(dir, 'history.json')
+  await fs.writeFile(`${file}.tmp`, JSON.stringify(manifest, null, 2))
+  await fs.rename(`${file}.tmp`, file)
+}
+
+/**
+ * Recorded generations of a project, oldest first
+ */
+export async function listGenerations(projectRoot: string): Promise<Generation[]> {
+  return (await readManifest(getHistoryDir(projectRoot))).generations
+}
+
+/**
+ * Pack file of a generation
+ */
+export function generationFile(projectRoot: string, generation: Generation): string {
+  return path.join(getHistoryDir(projectRoot), generation.file)
+}
+
+/**
+ * Record the shards of an index as a new generation, unless the policy keeps
+ * none or the last one is more recent than its interval, and drop the
+ * generations beyond the policy's count
+ * @param now - Time of the generation, for tests
+ * @returns The generation recorded, or null
+ */
+export async function recordGeneration(
+  projectRoot: string,
+  shards: FileShard[],
+  policy?: HistoryPolicy,
+  now = new Date()
+): Promise<Generation | null> {
+  const { keep, intervalMs } = policy || await loadHistoryPolicy(projectRoot)
+  if (keep === 0) return null
+  const dir = getHistoryDir(projectRoot)
+  const manifest = await readManifest(dir)
+  const last = manifest.generations[manifest.generations.length - 1]
+  if (last && now.getTime() - Date.parse(last.recorded_at) < intervalMs) return null
+
+  const generation: Generation = {
+    generation: manifest.next,
+    recorded_at: now.toISOString(),
+    files: shards.length,
+    format: SHARD_FORMAT_VERSION,
+    file: `${manifest.next}.idxpack`
+  }
+  await writeSymbolPack(path.join(dir, generation.file), shards.map(stampChecksum), { format: SHARD_FORMAT_VERSION })
+  const generations = [...manifest.generations, generation]
+  const dropped = generations.splice(0, Math.max(0, generations.length - keep))
+  await writeManifest(dir, { format: HISTORY_FORMAT, next: manifest.next + 1, generations })
+  for (const old of dropped) await fs.rm(path.join(dir, old.file), { force: true })
+  return generation
+}
+
+/**
+ * The generation a spec names: a generation number ("12"), or the newest
+ * recorded at or before a time, given as an ISO date or a duration ago
+ * ("7d", "12h")
+ * @throws Error if the spec is malformed or no generation matches
+ */
+export function resolveGeneration(generations: Generation[], spec: string, now = new Date()): Generation {
+  if (generations.length === 0) throw new Error('No index generations are recorded; set history: in .indexer/to-index to keep some')
+  const value = spec.trim()
+  if (/^\d+$/.test(value)) {
+    const found = generations.find(g => g.generation === Number(value))
+    if (!found) throw new Error(`No index generation ${value}; recorded: ${generations.map(g => g.generation).join(', ')}`)
+    return found
+  }
+  let time: number
+  if (/^\d+(?:\.\d+)?\s*[smhdw]$/i.test(value)) {
+    time = now.getTime() - parseDuration(value)
+  } else {
+    time = Date.parse(value)
+    if (Number.isNaN(time)) throw new Error(`Invalid generation "${spec}". Use a generation number, an ISO date or a duration ago such as 7d`)
+  }
+  const found = generations.filter(g => Date.parse(g.recorded_at) <= time).pop()
+  if (!found) throw new Error(`No index generation as old as ${new Date(time).toISOString()}; the oldest is from ${generations[0].recorded_at}`)
+  return found
+}
+
+export interface SymbolPlace {
+  name: string
+  path: string
+  line: number
+}
+
+export interface SymbolChange {
+  change: 'added' | 'removed' | 'moved' | 'renamed'
+  kind: string
+  before?: SymbolPlace
+  after?: SymbolPlace
+}
+
+export interface GenerationDiffOptions {
+  /** Restrict to these package patterns (see matchesPackage), on either side */
+  packages?: string[]
+}
+
+// Declarations worth following across generations
+function trackedSymbols(index: SymbolIndex): IndexedSymbol[] {
+  return index.allSymbols().filter(sym => !sym.external && !isLocalSymbol(sym) && sym.kind !== 'import')
+}
+
+function placeOf(sym: IndexedSymbol): SymbolPlace {
+  return { name: sym.name, path: sym.path, line: sym.line }
+}
+
+function groupBy(symbols: IndexedSymbol[], key: (sym: IndexedSymbol) => string): Map<string, IndexedSymbol[]> {
+  const groups = new Map<string, IndexedSymbol[]>()
+  for (const sym of symbols) {
+    const k = key(sym)
+    const group = groups.get(k)
+    if (group) group.push(sym)
+    else groups.set(k, [sym])
+  }
+  return groups
+}
+
+// Takes the first symbol the predicate accepts out of a list
+function take(symbols: IndexedSymbol[], accept: (sym: IndexedSymbol) => boolean): IndexedSymbol | undefined {
+  const i = symbols.findIndex(accept)
+  return i === -1 ? undefined : symbols.splice(i, 1)[0]
+}
+
+/**
+ * Symbols added, removed, moved and renamed from one generation to another,
+ * sorted by the path they ended up in (or were removed from)
+ */
+export function diffGenerations(before: SymbolIndex, after: SymbolIndex, options: GenerationDiffOptions = {}): SymbolChange[] {
+  const packages = options.packages && options.packages.length > 0 ? options.packages : null
+  const inScope = (sym: IndexedSymbol) => !packages || packages.some(p => matchesPackage(sym.path, p))
+  const nameKey = (sym: IndexedSymbol) => `${sym.kind}\0${sym.name}`
+  const oldByName = groupBy(trackedSymbols(before), nameKey)
+  const newByName = groupBy(trackedSymbols(after), nameKey)
+
+  const changes: SymbolChange[] = []
+  const gone: IndexedSymbol[] = []
+  const fresh: IndexedSymbol[] = []
+  for (const [key, olds] of oldByName) {
+    const news = [...(newByName.get(key) || [])]
+    newByName.delete(key)
+    const left = olds.filter(sym => !take(news, n => n.path === sym.path))
+    // Same name and kind elsewhere: moved
+    for (const sym of left) {
+      const moved = news.shift()
+      if (moved) {
+        if (inScope(sym) || inScope(moved)) changes.push({ change: 'moved', kind: sym.kind, before: placeOf(sym), after: placeOf(moved) })
+      } else gone.push(sym)
+    }
+    fresh.push(...news)
+  }
+  for (const news of newByName.values()) fresh.push(...news)
+
+  // Another name for the same body, or at the same place
+  const freshByKind = groupBy(fresh, sym => sym.kind)
+  for (const sym of gone) {
+    const candidates = freshByKind.get(sym.kind) || []
+    const renamed = (sym.body_hash ? take(candidates, n => n.body_hash === sym.body_hash) : undefined) ||
+      take(candidates, n => n.path === sym.path && n.line === sym.line)
+    if (renamed) {
+      if (inScope(sym) || inScope(renamed)) changes.push({ change: 'renamed', kind: sym.kind, before: placeOf(sym), after: placeOf(renamed) })
+    } else if (inScope(sym)) changes.push({ change: 'removed', kind: sym.kind, before: placeOf(sym) })
+  }
+  for (const candidates of freshByKind.values()) {
+    for (const sym of candidates) {
+      if (inScope(sym)) changes.push({ change: 'added', kind: sym.kind, after: placeOf(sym) })
+    }
+  }
+  const placeKey = (c: SymbolChange) => (c.after || c.before)!
+  return changes.sort((a, b) => {
+    const pa = placeKey(a), pb = placeKey(b)
+    return pa.path < pb.path ? -1 : pa.path > pb.path ? 1 : pa.line - pb.line
+  })
+}
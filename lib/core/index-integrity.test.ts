@@ -0,0 +1,86 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import os from 'os'
+import path from 'path'
+import { shardChecksum, shardProblem, stampChecksum, verifyShards } from './index-integrity.js'
+import { openSymbolIndex } from './symbol-index.js'
+import type { SymbolStore } from '../utils/symbol-store.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+function sym(filePath: string, name: string, line: number): IndexedSymbol {
+  return { id: `${filePath}#${name}`, name, kind: 'function', path: filePath, line, end_line: line } as IndexedSymbol
+}
+
+function shard(): FileShard {
+  return {
+    path: 'src/a.ts',
+    lang: 'typescript',
+    hash: 'h1',
+    symbols: [sym('src/a.ts', 'b', 1), sym('src/a.ts', 'a', 1)],
+    references: [{ name: 'b', path: 'src/a.ts', line: 3 }]
+  }
+}
+
+function memoryStore(initial: FileShard[] | null) {
+  const state = { shards: initial, updated: [] as string[], removed: [] as string[] }
+  const store: SymbolStore = {
+    load: async () => state.shards && state.shards.map(s => structuredClone(s)),
+    save: async (next) => { state.shards = next },
+    update: async (changed, removed) => {
+      state.updated.push(...changed.map(s => s.path))
+      state.removed.push(...removed)
+    },
+    findSymbols: async () => [],
+    clear: async () => { state.shards = null }
+  }
+  return { store, state }
+}
+
+test('index-integrity: checksums ignore symbol order but catch edits', () => {
+  const a = shard()
+  const b = shard()
+  b.symbols.reverse()
+  assert.equal(shardChecksum(a), shardChecksum(b))
+
+  stampChecksum(a)
+  assert.equal(shardProblem(a), null)
+  a.references[0].line = 4
+  assert.equal(shardProblem(a), 'corrupt')
+  assert.equal(shardProblem(shard()), 'unverified')
+
+  const { intact, damaged } = verifyShards([stampChecksum(shard()), a])
+  assert.equal(intact.length, 1)
+  assert.deepEqual(damaged, [{ path: 'src/a.ts', problem: 'corrupt' }])
+})
+
+test('index-integrity: openSymbolIndex re-indexes only damaged shards', async () => {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'index-integrity-'))
+  try {
+    await fs.writeFile(path.join(root, 'a.ts'), 'export function alpha() {}\n')
+    await fs.writeFile(path.join(root, 'b.ts'), 'export function beta() {}\n')
+    await fs.writeFile(path.join(root, 'c.ts'), 'export function gamma() {}\n')
+
+    const first = memoryStore(null)
+    await openSymbolIndex(root, first.store)
+    const stored = first.state.shards!
+    assert.ok(stored.every(s => s.checksum))
+
+    // Corrupt b.ts, and c.ts, which is then deleted from the tree
+    stored.find(s => s.path === 'b.ts')!.symbols[0].name = 'garbage'
+    stored.find(s => s.path === 'c.ts')!.references.push({ name: 'x', path: 'c.ts', line: 9 })
+    await fs.rm(path.join(root, 'c.ts'))
+
+    const second = memoryStore(stored)
+    const { index, update, damaged } = await openSymbolIndex(root, second.store)
+    assert.deepEqual(damaged.map(d => [d.path, d.problem]), [['b.ts', 'corrupt'], ['c.ts', 'corrupt']])
+    assert.deepEqual(update.unchanged, ['a.ts'])
+    assert.deepEqual(update.added, ['b.ts'])
+    assert.equal(index.findSymbols('beta').length, 1)
+    assert.equal(index.findSymbols('garbage').length, 0)
+    assert.deepEqual(second.state.updated, ['b.ts'])
+    assert.deepEqual(second.state.removed, ['c.ts'])
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,74 @@
+/**
+ * Index Integrity Module
+ * Per-shard checksums for the stored symbol index. Shards are stamped with a
+ * SHA-1 of their contents when they are persisted; on open, shards whose
+ * checksum does not match are dropped and only their files are re-indexed,
+ * so a damaged store costs a partial rebuild instead of a failed load.
+ */
+
+import crypto from 'crypto'
+import type { FileShard } from '../types/index.js'
+
+export type ShardProblem =
+  | 'corrupt' // checksum does not match the contents
+  | 'unverified' // stored without a checksum, e.g. by an older version
+
+export interface ShardDamage {
+  path: string
+  problem: ShardProblem
+}
+
+export interface ShardVerification {
+  intact: FileShard[]
+  damaged: ShardDamage[]
+}
+
+/**
+ * Checksum of a shard's contents. Symbols are hashed in id order, since
+ * stores do not all keep declaration order for symbols on the same line.
+ */
+export function shardChecksum(shard: FileShard): string {
+  const symbols = [...(shard.symbols || [])].sort((a, b) => (a.id < b.id ? -1 : a.id > b.id ? 1 : 0))
+  return crypto.createHash('sha1')
+    .update(JSON.stringify([
+      shard.path,
+      shard.lang,
+      shard.hash ?? null,
+      symbols,
+      shard.references || [],
+      ...(shard.todos ? [shard.todos] : []),
+      ...(shard.strings ? [{ strings: shard.strings }] : []),
+      ...(shard.diagnostics ? [{ diagnostics: shard.diagnostics }] : []),
+      ...(shard.wide_chars ? [{ wide_chars: shard.wide_chars }] : [])
+    ]))
+    .digest('hex')
+}
+
+/**
+ * Stamp a shard with the checksum of its current contents
+ */
+export function stampChecksum(shard: FileShard): FileShard {
+  shard.checksum = shardChecksum(shard)
+  return shard
+}
+
+/**
+ * Problem with a stored shard, or null if its checksum matches
+ */
+export function shardProblem(shard: FileShard): ShardProblem | null {
+  if (!shard.checksum) return 'unverified'
+  return shard.checksum === shardChecksum(shard) ? null : 'corrupt'
+}
+
+/**
+ * Split stored shards into intact ones and damaged files
+ */
+export function verifyShards(shards: FileShard[]): ShardVerification {
+  const result: ShardVerification = { intact: [], damaged: [] }
+  for (const shard of shards) {
+    const problem = shardProblem(shard)
+    if (problem) result.damaged.push({ path: shard.path, problem })
+    else result.intact.push(shard)
+  }
+  return result
+}
@@ -0,0 +1,57 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { SymbolIndex, syncSymbolIndex } from './symbol-index.js'
+import { RateLimiter, parseByteRate } from './index-limits.js'
+
+test('index-limits: byte rates take k, MB and G suffixes', () => {
+  assert.equal(parseByteRate('1048576'), 1048576)
+  assert.equal(parseByteRate('512k'), 512 * 1024)
+  assert.equal(parseByteRate('20MB'), 20 * 1024 * 1024)
+  assert.equal(parseByteRate('1.5g/s'), 1.5 * 1024 ** 3)
+  assert.throws(() => parseByteRate('fast'), /Invalid byte rate "fast"/)
+})
+
+test('index-limits: reads past the rate wait, and an abort stops the wait', async () => {
+  const limiter = new RateLimiter(1000)
+  let start = Date.now()
+  await limiter.take(1000)
+  assert.ok(Date.now() - start < 100)
+  start = Date.now()
+  await limiter.take(300)
+  assert.ok(Date.now() - start >= 250)
+
+  const controller = new AbortController()
+  setTimeout(() => controller.abort(new Error('stopped')), 20)
+  await assert.rejects(limiter.take(5000, controller.signal), /stopped/)
+})
+
+test('index-limits: a cancelled sync leaves the index as it was', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'index-limits-test-'))
+  try {
+    await fs.writeFile(path.join(root, 'a.ts'), 'export class Invoice {}\n')
+    await fs.writeFile(path.join(root, 'b.ts'), 'export function tax() { return 0 }\n')
+    const index = new SymbolIndex()
+    await syncSymbolIndex(index, root, ['a.ts', 'b.ts'])
+    const before = index.listShards()
+
+    await fs.writeFile(path.join(root, 'a.ts'), 'export class Receipt {}\n')
+    const controller = new AbortController()
+    setTimeout(() => controller.abort(new Error('cancelled')), 50)
+    // A few bytes a second, so the run is still reading when it is cancelled
+    await assert.rejects(syncSymbolIndex(index, root, ['a.ts'], undefined, { signal: controller.signal, ioRate: 4 }), /cancelled/)
+    assert.deepEqual(index.listShards(), before)
+
+    // Already cancelled: nothing is read
+    await assert.rejects(syncSymbolIndex(index, root, ['a.ts'], undefined, { signal: AbortSignal.abort(new Error('gone')) }), /gone/)
+    assert.deepEqual(index.listFiles(), ['a.ts', 'b.ts'])
+
+    const update = await syncSymbolIndex(index, root, ['a.ts'], undefined, { concurrency: 1, readConcurrency: 1 })
+    assert.deepEqual(update.modified, ['a.ts'])
+    assert.deepEqual(update.removed, ['b.ts'])
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
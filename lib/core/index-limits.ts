@@ -0,0 +1,101 @@
+/**
+ * Index Limits Module
+ * Cancellation and resource limits for indexing runs. An IndexContext
+ * carries an AbortSignal that stops a build, sync or reindex between files,
+ * how many files are parsed and read at once, and a cap on the bytes read
+ * from disk per second, so a background reindex (a watcher, the query
+ * daemon) leaves the machine usable while a developer works. A cancelled run
+ * rejects with the signal's reason and leaves the index as it was: nothing
+ * is changed until every file has been read and parsed, and once changes
+ * are applied the run finishes. Queries answer from the index in memory and
+ * do not wait on anything; opening an index, which syncs it with the tree,
+ * is what takes long, so that is what takes a context.
+ */
+
+import { setTimeout as sleep } from 'timers/promises'
+
+// Files read from disk at once while indexing, unless configured
+const DEFAULT_READ_CONCURRENCY = 32
+
+export interface IndexLimits {
+  /** Files parsed at once (defaults to indexConcurrency) */
+  concurrency?: number
+  /** Files read at once (defaults to readConcurrency) */
+  readConcurrency?: number
+  /** Bytes read from disk per second, 0 for no limit (defaults to ioRateLimit) */
+  ioRate?: number
+}
+
+export interface IndexContext extends IndexLimits {
+  /** Cancels the run; it rejects with the signal's reason */
+  signal?: AbortSignal
+}
+
+const RATE_UNITS: Record<string, number> = { '': 1, b: 1, k: 1024, kb: 1024, m: 1024 ** 2, mb: 1024 ** 2, g: 1024 ** 3, gb: 1024 ** 3 }
+
+/**
+ * Parse a byte rate such as 20MB, 512k or 1048576 (bytes per second; an
+ * optional "/s" is allowed)
+ */
+export function parseByteRate(value: string): number {
+  const match = /^(\d+(?:\.\d+)?)\s*([a-z]*)(?:\/s)?$/i.exec(value.trim())
+  const unit = match ? RATE_UNITS[match[2].toLowerCase()] : undefined
+  if (!match || unit === undefined) throw new Error(`Invalid byte rate "${value}". Use bytes per second, such as 20MB or 512k`)
+  return Math.round(Number(match[1]) * unit)
+}
+
+/**
+ * Number of files to read at once: INDEXER_READ_CONCURRENCY, else 32
+ */
+export function readConcurrency(): number {
+  const configured = Number(process.env.INDEXER_READ_CONCURRENCY)
+  return Number.isInteger(configured) && configured > 0 ? configured : DEFAULT_READ_CONCURRENCY
+}
+
+/**
+ * Bytes to read per second: INDEXER_IO_RATE (such as 20MB), else 0 for no
+ * limit
+ */
+export function ioRateLimit(): number {
+  const configured = process.env.INDEXER_IO_RATE
+  return configured ? parseByteRate(configured) : 0
+}
+
+/**
+ * Token bucket holding up to a second's worth of bytes. A caller that takes
+ * more than is left waits until the bucket has refilled what it overdrew,
+ * so concurrent readers share the rate between them.
+ */
+export class RateLimiter {
+  private available: number
+  private last = Date.now()
+
+  constructor(private readonly bytesPerSecond: number) {
+    this.available = bytesPerSecond
+  }
+
+  /**
+   * Account for bytes read, waiting while the rate is exceeded
+   */
+  async take(bytes: number, signal?: AbortSignal): Promise<void> {
+    const now = Date.now()
+    this.available = Math.min(this.bytesPerSecond, this.available + (now - this.last) / 1000 * this.bytesPerSecond)
+    this.last = now
+    this.available -= bytes
+    if (this.available >= 0) return
+    try {
+      await sleep(-this.available / this.bytesPerSecond * 1000, undefined, { signal })
+    } catch (e) {
+      signal?.throwIfAborted()
+      throw e
+    }
+  }
+}
+
+/**
+ * Limiter for a run's reads, or null when it may read as fast as it likes
+ */
+export function ioLimiter(ctx: IndexContext = {}): RateLimiter | null {
+  const rate = ctx.ioRate ?? ioRateLimit()
+  return rate > 0 ? new RateLimiter(rate) : null
+}
@@ -0,0 +1,73 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { SymbolIndex } from './symbol-index.js'
+import { mergeShards, openIndexFile, parseMergeInput, readMergeInput, writeIndexFile } from './index-merge.js'
+
+// billing exports Invoice as @org/billing; shop imports it and has an unrelated Invoice of its own
+function repositories() {
+  const billing = new SymbolIndex()
+  billing.moduleOf = () => ({ name: '@org/billing', version: '1.0.0' })
+  billing.addFile('src/models/invoice.ts', 'typescript', [{ name: 'Invoice', kind: 'class', line: 1, end_line: 9, column: 14, exported: true }])
+  billing.addFile('src/pay.ts', 'typescript', [
+    { name: 'pay', kind: 'function', line: 1, end_line: 3, exported: true },
+    { name: 'Invoice', kind: 'reference', line: 2, column: 10 }
+  ])
+
+  const shop = new SymbolIndex()
+  shop.addFile('legacy/invoice.ts', 'typescript', [{ name: 'Invoice', kind: 'class', line: 1, end_line: 4, column: 14, exported: true }])
+  shop.addFile('src/checkout.ts', 'typescript', [
+    { name: 'Invoice', kind: 'reference', line: 1, column: 10, source: '@org/billing/models' },
+    { name: 'checkout', kind: 'function', line: 3, end_line: 5, exported: true },
+    { name: 'Invoice', kind: 'reference', line: 4, column: 7 }
+  ])
+  shop.addFile('legacy/print.ts', 'typescript', [
+    { name: 'print', kind: 'function', line: 1, end_line: 3 },
+    { name: 'Invoice', kind: 'reference', line: 2, column: 3 }
+  ])
+  return { billing, shop }
+}
+
+test('index-merge: repositories are namespaced and imports resolve across them', async () => {
+  const { billing, shop } = repositories()
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'index-merge-test-'))
+  try {
+    await writeIndexFile(path.join(root, 'billing.idx'), billing.listShards())
+    await writeIndexFile(path.join(root, 'shop.idx'), shop.listShards())
+    const inputs = await Promise.all(['billing.idx', 'web=shop.idx'].map(async arg => {
+      const { name, file } = parseMergeInput(arg)
+      return (await readMergeInput(name, path.join(root, file))).input
+    }))
+    await writeIndexFile(path.join(root, 'combined.idx'), mergeShards(inputs), true)
+    const { index, damaged } = await openIndexFile(path.join(root, 'combined.idx'))
+
+    assert.deepEqual(damaged, [])
+    assert.deepEqual(index.listFiles(), [
+      'billing/src/models/invoice.ts',
+      'billing/src/pay.ts',
+      'web/legacy/invoice.ts',
+      'web/legacy/print.ts',
+      'web/src/checkout.ts'
+    ])
+    assert.equal(index.getSymbol('billing/src/models/invoice.ts#Invoice')!.module, '@org/billing')
+    assert.deepEqual(index.references('billing/src/models/invoice.ts#Invoice').map(r => `${r.path}:${r.line}`),
+      ['billing/src/pay.ts:2', 'web/src/checkout.ts:1', 'web/src/checkout.ts:4'])
+    assert.deepEqual(index.references('web/legacy/invoice.ts#Invoice').map(r => r.path), ['web/legacy/print.ts'])
+    assert.deepEqual(index.symbolAt('web/src/checkout.ts', 4, 7)!.symbols.map(s => s.id), ['billing/src/models/invoice.ts#Invoice'])
+
+    // A merged index is not merged again
+    await assert.rejects(readMergeInput('all', path.join(root, 'combined.idx')), /already merged/)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('index-merge: repository names', () => {
+  assert.deepEqual(parseMergeInput('../repos/billing.idx'), { name: 'billing', file: '../repos/billing.idx' })
+  assert.deepEqual(parseMergeInput('@org-web=out/web.idx'), { name: '@org-web', file: 'out/web.idx' })
+  assert.throws(() => parseMergeInput('org/web=web.idx'), /Invalid repository name/)
+  const { billing } = repositories()
+  assert.throws(() => mergeShards([{ name: 'a', shards: billing.listShards() }, { name: 'a', shards: [] }]), /Two indexes are named "a"/)
+})
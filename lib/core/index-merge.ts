@@ -0,0 +1,157 @@
+/**
+ * Index Merge Module
+ * Unions the indexes of several repositories into one index file, for code
+ * search across an organization. Each repository's files move under a
+ * top-level directory named after it (billing/src/invoice.ts), and so do
+ * their symbol IDs. References resolve within their own repository, except
+ * where a file imports a name from another one: a JS/TS named import whose
+ * specifier is a repository's name or one of its modules (@org/billing,
+ * @org/billing/models) binds the name, throughout the importing file, to
+ * that repository's definitions. Merged packs are flagged, so an index
+ * opened from one (--index=<file>) follows the same rule.
+ */
+
+import path from 'path'
+import { SymbolIndex } from './symbol-index.js'
+import { stampChecksum, verifyShards, type ShardDamage } from './index-integrity.js'
+import { SHARD_FORMAT_VERSION, checkIndexFormat } from './index-format.js'
+import { SymbolPackReader, writeSymbolPack } from '../utils/symbol-pack.js'
+import type { FileShard } from '../types/index.js'
+
+export interface MergeInput {
+  name: string // repository name, the top-level directory of its files
+  shards: FileShard[]
+}
+
+// One path segment, so the repository of a merged path is its first segment
+const REPOSITORY_NAME = /^(?!\.\.?$)[\w@.+-]+$/
+
+/**
+ * Split a merge argument, [<name>=]<file>; the name defaults to the file's
+ * base name without its extension
+ */
+export function parseMergeInput(arg: string): { name: string, file: string } {
+  const eq = arg.indexOf('=')
+  const file = eq === -1 ? arg : arg.slice(eq + 1)
+  const name = eq === -1 ? path.basename(file).replace(/\.[^.]*$/, '') : arg.slice(0, eq)
+  if (!REPOSITORY_NAME.test(name)) throw new Error(`Invalid repository name "${name}" for ${file}`)
+  return { name, file }
+}
+
+/**
+ * Repository a path of a merged index belongs to
+ */
+export function repositoryOf(filePath: string): string {
+  const slash = filePath.indexOf('/')
+  return slash === -1 ? filePath : filePath.slice(0, slash)
+}
+
+/**
+ * Import specifiers that name a repository: its name and the modules of
+ * its own symbols (dependencies indexed with --deps are other repositories')
+ */
+export function importNamesOf(input: MergeInput): string[] {
+  const names = new Set([input.name])
+  for (const shard of input.shards) {
+    if (/(^|\/)node_modules\//.test(shard.path)) continue
+    for (const sym of shard.symbols) {
+      if (sym.module && !sym.external) names.add(sym.module)
+    }
+  }
+  return [...names]
+}
+
+/**
+ * Shards of the merged index. Imported names are resolved to the repository
+ * with the longest matching import name, the earlier input on a tie.
+ */
+export function mergeShards(inputs: MergeInput[]): FileShard[] {
+  const seen = new Set<string>()
+  for (const input of inputs) {
+    if (seen.has(input.name)) throw new Error(`Two indexes are named "${input.name}"; name them with <name>=<file>`)
+    seen.add(input.name)
+  }
+  const importNames = inputs
+    .flatMap(input => importNamesOf(input).map(name => ({ name, repository: input.name })))
+    .sort((a, b) => b.name.length - a.name.length)
+  const resolveImport = (source: string, from: string): string | undefined => {
+    const found = importNames.find(n => source === n.name || source.startsWith(`${n.name}/`))
+    return found && found.repository !== from ? found.repository : undefined
+  }
+
+  return inputs.flatMap(input => input.shards.map(shard => {
+    const imported = new Map<string, string>()
+    for (const ref of shard.references) {
+      const repository = ref.source ? resolveImport(ref.source, input.name) : undefined
+      if (repository) imported.set(ref.name, repository)
+    }
+    const filePath = `${input.name}/${shard.path}`
+    return stampChecksum({
+      ...shard,
+      path: filePath,
+      symbols: shard.symbols.map(sym => ({ ...sym, id: `${input.name}/${sym.id}`, path: filePath })),
+      references: shard.references.map(ref => {
+        const repository = imported.get(ref.name)
+        return { ...ref, path: filePath, ...(repository ? { repository } : {}) }
+      })
+    })
+  }))
+}
+
+/**
+ * Open an index file of the current shard format
+ */
+async function openPack(filePath: string): Promise<SymbolPackReader> {
+  const reader = await SymbolPackReader.open(filePath)
+  if (!reader) throw new Error(`${filePath} is not an index file; write one with "indexer export --format=idx"`)
+  try {
+    checkIndexFormat(reader.shardFormat, filePath, `indexer migrate --index=${filePath}`)
+  } catch (e) {
+    await reader.close()
+    throw e
+  }
+  return reader
+}
+
+/**
+ * Read a repository's index file for merging
+ */
+export async function readMergeInput(name: string, filePath: string): Promise<{ input: MergeInput, damaged: ShardDamage[] }> {
+  const reader = await openPack(filePath)
+  try {
+    if (reader.merged) throw new Error(`${filePath} is already merged; merge the indexes it was made from`)
+    const { intact, damaged } = verifyShards(await reader.readShards())
+    return { input: { name, shards: intact }, damaged }
+  } finally {
+    await reader.close()
+  }
+}
+
+/**
+ * Write an index to a file, merged or a single repository's
+ */
+export async function writeIndexFile(filePath: string, shards: FileShard[], merged = false): Promise<void> {
+  await writeSymbolPack(filePath, shards.map(stampChecksum), { merged, format: SHARD_FORMAT_VERSION })
+}
+
+/**
+ * Open an index file as a symbol index; shards that fail their checksum are
+ * left out and listed in `damaged`
+ */
+export async function openIndexFile(filePath: string): Promise<{ index: SymbolIndex, damaged: ShardDamage[] }> {
+  const reader = await openPack(filePath)
+  try {
+    const { intact, damaged } = verifyShards(await reader.readShards())
+    const index = new SymbolIndex()
+    if (reader.merged) index.repositoryOf = repositoryOf
+    // addShard clears the external flag of files externalOf does not name
+    const external = new Set(intact.filter(s => s.symbols.some(sym => sym.external)).map(s => s.path))
+    if (external.size > 0) index.externalOf = (p) => external.has(p)
+    for (const shard of intact) {
+      index.addShard(shard)
+    }
+    return { index, damaged }
+  } finally {
+    await reader.close()
+  }
+}
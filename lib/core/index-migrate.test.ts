@@ -0,0 +1,131 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import crypto from 'crypto'
+import fs from 'fs/promises'
+import os from 'os'
+import path from 'path'
+import { SHARD_FORMAT_VERSION, IndexFormatError } from './index-format.js'
+import { migrateIndexFile, migrateShards } from './index-migrate.js'
+import { openIndexFile, writeIndexFile } from './index-merge.js'
+import { stampChecksum } from './index-integrity.js'
+import { contentHash, openSymbolIndex } from './symbol-index.js'
+import { limitsKey, DEFAULT_FILE_LIMITS } from './file-limits.js'
+import type { SymbolStore } from '../utils/symbol-store.js'
+import type { FileShard } from '../types/index.js'
+
+const SOURCES: Record<string, string> = {
+  'src/a.ts': 'export function alpha() {}\n',
+  'src/b.ts': 'export function beta() {}\nexport function gamma() {}\n'
+}
+
+// Shard as format 21 stored it, its hash salted with the format
+function legacyShard(filePath: string, text: string): FileShard {
+  return stampChecksum({
+    path: filePath,
+    lang: 'typescript',
+    hash: crypto.createHash('sha1').update(`21:${text}`).digest('hex'),
+    symbols: [],
+    references: []
+  })
+}
+
+test('index-migrate: unchanged files keep their shards with current hashes', async () => {
+  const shards = [
+    legacyShard('src/a.ts', SOURCES['src/a.ts']),
+    // Edited since it was indexed
+    legacyShard('src/b.ts', 'export function beta() {}\n'),
+    { ...legacyShard('src/c.ts', ''), symbols: [{ id: 'src/c.ts#c', name: 'c', kind: 'function', path: 'src/c.ts', line: 1 }] } as FileShard
+  ]
+  const { shards: migrated, dropped } = await migrateShards(shards, 0, { read: async p => SOURCES[p] ?? null, passes: '' })
+
+  assert.deepEqual(dropped, ['src/c.ts'])
+  assert.deepEqual(migrated.map(s => s.path), ['src/a.ts', 'src/b.ts'])
+  assert.equal(migrated[0].hash, contentHash(SOURCES['src/a.ts'], ''))
+  // Its old hash matches no content now, so opening the index re-parses it
+  assert.equal(migrated[1].hash, shards[1].hash)
+})
+
+test('index-migrate: new hashes are salted with the file limits', async () => {
+  const limits = { ...DEFAULT_FILE_LIMITS, maxFileBytes: 30 }
+  const shards = [legacyShard('src/a.ts', SOURCES['src/a.ts']), legacyShard('src/b.ts', SOURCES['src/b.ts'])]
+  const { shards: migrated } = await migrateShards(shards, 0, { read: async p => SOURCES[p] ?? null, passes: '', limits })
+  assert.equal(migrated[0].hash, contentHash(SOURCES['src/a.ts'], limitsKey(limits)))
+  // Over the limit now, so it is re-parsed to apply it
+  assert.equal(migrated[1].hash, shards[1].hash)
+})
+
+test('index-migrate: stores and index files in another format are not read', async () => {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'index-migrate-'))
+  try {
+    const store: SymbolStore = {
+      load: async () => [],
+      loadFormat: async () => 0,
+      save: async () => {},
+      update: async () => {},
+      findSymbols: async () => [],
+      clear: async () => {}
+    }
+    await assert.rejects(openSymbolIndex(root, store), /format unrecorded, written by an older indexer.*indexer migrate/)
+    await assert.rejects(openSymbolIndex(root, { ...store, loadFormat: async () => SHARD_FORMAT_VERSION + 1 }), IndexFormatError)
+
+    const file = path.join(root, 'old.idx')
+    await writeIndexFile(file, [legacyShard('src/a.ts', SOURCES['src/a.ts'])])
+    const data = await fs.readFile(file)
+    data.writeUInt32LE(21, 56)
+    await fs.writeFile(file, data)
+    await assert.rejects(openIndexFile(file), /format 21.*indexer migrate --index=/)
+
+    const result = await migrateIndexFile(file)
+    assert.deepEqual(result, { from: 21, to: SHARD_FORMAT_VERSION, upgraded: 1, dropped: [] })
+    const { index } = await openIndexFile(file)
+    assert.deepEqual(index.listFiles(), ['src/a.ts'])
+    assert.deepEqual(await migrateIndexFile(file), { from: SHARD_FORMAT_VERSION, to: SHARD_FORMAT_VERSION, upgraded: 0, dropped: [] })
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('index-migrate: index files keep the shards they cannot re-parse', async () => {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'index-migrate-'))
+  try {
+    const file = path.join(root, 'old.idx')
+    const python: FileShard = stampChecksum({
+      path: 'app/models.py',
+      lang: 'python',
+      hash: 'h1',
+      symbols: [{ id: 'app/models.py#User', name: 'User', kind: 'class', path: 'app/models.py', line: 1 }],
+      references: []
+    })
+    const alias = stampChecksum({ ...python, path: 'src/types.ts', lang: 'typescript', symbols: [{ id: 'src/types.ts#Id', name: 'Id', kind: 'type', path: 'src/types.ts', line: 1 }] })
+    await writeIndexFile(file, [python, alias])
+    const data = await fs.readFile(file)
+    data.writeUInt32LE(22, 56)
+    await fs.writeFile(file, data)
+
+    assert.deepEqual(await migrateIndexFile(file), { from: 22, to: SHARD_FORMAT_VERSION, upgraded: 2, dropped: [] })
+    const { index } = await openIndexFile(file)
+    assert.deepEqual(index.listFiles(), ['app/models.py', 'src/types.ts'])
+    assert.equal(index.findSymbols('User').length, 1)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('index-migrate: files that may hold a landmark are re-parsed for them', async () => {
+  const sources: Record<string, string> = {
+    'src/block.ts': 'export function f() {\n  checks: {\n    if (!ok) break checks\n  }\n}\n',
+    'src/cleanup.ts': 'export function g() {\n  try { run() } finally { close() }\n}\n',
+    'src/retry.py': 'def h():\n    try:\n        run()\n    except OSError:\n        pass\n',
+    'src/plain.ts': 'export function k() {\n  return 1\n}\n'
+  }
+  const shards = Object.keys(sources).map(p => stampChecksum({
+    path: p,
+    lang: p.endsWith('.py') ? 'python' : 'typescript',
+    hash: contentHash(sources[p], ''),
+    symbols: [],
+    references: []
+  }))
+  const { shards: kept, dropped } = await migrateShards(shards, 23, { read: async p => sources[p] ?? null, passes: '' })
+  assert.deepEqual(kept.map(s => s.path), ['src/plain.ts'])
+  assert.deepEqual(dropped, ['src/block.ts', 'src/cleanup.ts', 'src/retry.py'])
+})
@@ -0,0 +1,174 @@
+/**
+ * Index Migrate Module
+ * Upgrades an index written by an older indexer to the current shard format
+ * in place (indexer migrate), one format at a time. Each format has a
+ * migration to the next that upgrades a shard, reading its file when it
+ * needs to; a shard it cannot upgrade, every shard of a format without a
+ * migration and damaged shards are dropped, and the stored index re-parses
+ * their files afterwards. Index files (.idx) carry no sources, so their
+ * dropped shards are left out. Indexes stored before formats were recorded
+ * are taken to be at format 21, the last of those; an older one fails the
+ * checks of its migrations and is re-parsed.
+ */
+
+import path from 'path'
+import crypto from 'crypto'
+import { SHARD_FORMAT_VERSION, IndexFormatError, storedFormat } from './index-format.js'
+import { contentHash, hashPasses, openSymbolIndex, type IndexUpdate, type OpenSymbolIndexOptions } from './symbol-index.js'
+import { stampChecksum, verifyShards } from './index-integrity.js'
+import { writeIndexFile } from './index-merge.js'
+import { analyzerKey, loadAnalyzers } from './analyzers.js'
+import { DEFAULT_FILE_LIMITS, limitContent, limitsKey, loadFileLimits, readSourceContent, sourceText, type FileLimits } from './file-limits.js'
+import { loadToIndexConfig } from './file-filters.js'
+import { mapConcurrent } from './parse-pool.js'
+import { readConcurrency } from './index-limits.js'
+import { SymbolPackReader } from '../utils/symbol-pack.js'
+import { getSymbolStore, type SymbolStore } from '../utils/symbol-store.js'
+import type { FileShard } from '../types/index.js'
+
+// Format of indexes stored before formats were recorded
+const UNRECORDED_FORMAT = 21
+
+// Source that may hold a landmark: goto, a labeled jump, a label on a loop,
+// switch or block, or a catch / except / finally clause
+const LANDMARK_SOURCE = /\bgoto\b|\b(break|continue)\s+[A-Za-z_$]|^\s*[A-Za-z_$][\w$]*\s*:\s*(\{|(for|while|do|switch)\b)|\b(catch|except|finally)\b/m
+
+export interface MigrationContext {
+  /** Content of an indexed file (its start when over the size limit), null when it is gone or the index has no sources */
+  read(relPath: string): Promise<string | null>
+  /** Passes content hashes are salted with (see hashPasses), the limits aside */
+  passes: string
+  /** File limits the index is built with, the defaults when left out */
+  limits?: FileLimits
+}
+
+export interface IndexMigration {
+  from: number // upgrades shards of this format to the next
+  description: string
+  /** The upgraded shard, or null to re-parse its file */
+  upgrade(shard: FileShard, ctx: MigrationContext): Promise<FileShard | null>
+}
+
+export const MIGRATIONS: IndexMigration[] = [
+  {
+    from: 21,
+    description: 'content hashes no longer include the shard format',
+    // A changed file keeps its old hash, which matches nothing now, so it is re-parsed;
+    // so is a file the limits, which format 21 did not have, would index differently
+    async upgrade(shard, ctx) {
+      const text = await ctx.read(shard.path)
+      const legacy = text === null ? null : crypto.createHash('sha1').update(`21:${ctx.passes}${text}`).digest('hex')
+      if (text === null || shard.hash !== legacy) return shard
+      const limits = ctx.limits ?? DEFAULT_FILE_LIMITS
+      const entries = shard.symbols.length + shard.references.length
+      const limited = limitContent(text, limits).diagnostics.length > 0 || (limits.maxSymbols > 0 && entries > limits.maxSymbols)
+      return limited ? shard : { ...shard, hash: contentHash(text, ctx.passes + limitsKey(limits)) }
+    }
+  },
+  {
+    from: 22,
+    description: 'type aliases record the type they name (alias_of)',
+    // Only files that can declare an alias are re-parsed, and only when they can be
+    // read: a shard without its source keeps what it has rather than drop out
+    async upgrade(shard, ctx) {
+      const aliases = shard.lang === 'csharp' || shard.lang === 'python' || shard.symbols.some(s => s.kind === 'type')
+      return aliases && await ctx.read(shard.path) !== null ? null : shard
+    }
+  },
+  {
+    from: 23,
+    description: 'functions record their labels, goto, labeled break / continue and catch / finally handlers (landmarks)',
+    // Files with a goto, a labeled jump, a labeled loop or block, or a handler are
+    // re-parsed; a shard whose file cannot be read keeps what it has
+    async upgrade(shard, ctx) {
+      const text = await ctx.read(shard.path)
+      return text !== null && LANDMARK_SOURCE.test(text) ? null : shard
+    }
+  }
+]
+
+export interface ShardMigration {
+  shards: FileShard[] // in the current format
+  dropped: string[] // files whose shards could not be upgraded, sorted
+}
+
+/**
+ * Upgrade shards of a format to the current one
+ * @param from - Their format (0: unrecorded)
+ */
+export async function migrateShards(shards: FileShard[], from: number, ctx: MigrationContext): Promise<ShardMigration> {
+  const { intact, damaged } = verifyShards(shards)
+  const dropped = damaged.map(d => d.path)
+  let current = intact
+  for (let format = from || UNRECORDED_FORMAT; format < SHARD_FORMAT_VERSION; format++) {
+    const migration = MIGRATIONS.find(m => m.from === format)
+    if (!migration) {
+      dropped.push(...current.map(s => s.path))
+      current = []
+      break
+    }
+    const upgraded = await mapConcurrent(current, readConcurrency(), shard => migration.upgrade(shard, ctx))
+    dropped.push(...current.filter((_, i) => !upgraded[i]).map(s => s.path))
+    current = upgraded.filter((s): s is FileShard => !!s)
+  }
+  return { shards: current, dropped: dropped.sort() }
+}
+
+export interface MigrationResult {
+  from: number // format the index was in (0: unrecorded)
+  to: number
+  upgraded: number // shards kept
+  dropped: string[] // files re-parsed, or left out of an index file
+  update?: IndexUpdate // re-indexing of the stored index after the upgrade
+}
+
+/**
+ * Upgrade the stored index of a project in place, then bring it up to date
+ * with the working tree
+ * @param options - Passes the index is built with, and limits for re-indexing;
+ * the file limits are the project's (see loadFileLimits)
+ * @returns null if nothing is stored
+ */
+export async function migrateIndex(
+  projectRoot: string,
+  store: SymbolStore = getSymbolStore(projectRoot),
+  options: OpenSymbolIndexOptions = {}
+): Promise<MigrationResult | null> {
+  const format = await storedFormat(store)
+  if (format === null) return null
+  if (format > SHARD_FORMAT_VERSION) throw new IndexFormatError(format, 'The stored symbol index')
+  if (format === SHARD_FORMAT_VERSION) return { from: format, to: format, upgraded: 0, dropped: [] }
+
+  const analyzers = await loadAnalyzers(projectRoot, (await loadToIndexConfig(projectRoot))?.analyzers || [])
+  const limits = await loadFileLimits(projectRoot)
+  const ctx: MigrationContext = {
+    read: relPath => readSourceContent(path.join(projectRoot, relPath), limits).then(sourceText, () => null),
+    passes: hashPasses({ locals: !!options.locals, ...(options.strings ? { strings: options.strings } : {}) }, analyzerKey(analyzers)),
+    limits
+  }
+  const migrated = await migrateShards(await store.load() || [], format, ctx)
+  await store.save(migrated.shards.map(stampChecksum), SHARD_FORMAT_VERSION)
+  const { update } = await openSymbolIndex(projectRoot, store, options)
+  return { from: format, to: SHARD_FORMAT_VERSION, upgraded: migrated.shards.length, dropped: migrated.dropped, update }
+}
+
+/**
+ * Upgrade an index file (indexer export --format=idx, indexer merge) in place
+ */
+export async function migrateIndexFile(filePath: string): Promise<MigrationResult> {
+  const reader = await SymbolPackReader.open(filePath)
+  if (!reader) throw new Error(`${filePath} is not an index file; write one with "indexer export --format=idx"`)
+  const format = reader.shardFormat
+  const merged = reader.merged
+  let shards: FileShard[]
+  try {
+    if (format > SHARD_FORMAT_VERSION) throw new IndexFormatError(format, filePath)
+    if (format === SHARD_FORMAT_VERSION) return { from: format, to: format, upgraded: 0, dropped: [] }
+    shards = await reader.readShards()
+  } finally {
+    await reader.close()
+  }
+  const migrated = await migrateShards(shards, format, { read: async () => null, passes: '' })
+  await writeIndexFile(filePath, migrated.shards, merged)
+  return { from: format, to: SHARD_FORMAT_VERSION, upgraded: migrated.shards.length, dropped: migrated.dropped }
+}
@@ -0,0 +1,58 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { tmpdir } from 'os'
+import { SymbolIndex } from './symbol-index.js'
+import { isCaseInsensitiveFs, pathCaseCollisions, relativeIndexPath, toIndexPath } from './index-paths.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src\\models\\User.ts': [
+    { name: 'User', kind: 'class', line: 1, end_line: 3, column: 14, exported: true }
+  ],
+  // Both can be checked out on Linux only
+  'src/Readme.ts': [],
+  'src/README.ts': []
+}
+
+test('index-paths: paths are stored and looked up with forward slashes', () => {
+  assert.equal(toIndexPath('src\\models\\user.ts'), 'src/models/user.ts')
+  assert.equal(toIndexPath('./src//models/./../user.ts'), 'src/user.ts')
+  assert.equal(toIndexPath('src/models/'), 'src/models')
+  assert.equal(toIndexPath('.'), '')
+  assert.equal(relativeIndexPath('/repo', '/repo/src/user.ts'), 'src/user.ts')
+  assert.equal(relativeIndexPath('/repo', '/other/user.ts'), null)
+
+  const index = createIndex(FILES, { caseInsensitive: false })
+  assert.deepEqual(index.listFiles(), ['src/README.ts', 'src/Readme.ts', 'src/models/User.ts'])
+  assert.equal(index.getSymbol('src/models/User.ts#User')?.path, 'src/models/User.ts')
+  assert.equal(index.resolvePath('.\\src\\models\\User.ts'), 'src/models/User.ts')
+  // Case matters on a case-sensitive file system
+  assert.equal(index.resolvePath('src/models/user.ts'), null)
+})
+
+test('index-paths: a case-insensitive checkout resolves paths and ids in any case', () => {
+  const index = createIndex(FILES, { caseInsensitive: true })
+  assert.equal(index.resolvePath('SRC\\models\\user.ts'), 'src/models/User.ts')
+  assert.equal(index.resolveSymbolId('src/models/user.ts#User'), 'src/models/User.ts#User')
+  assert.equal(index.resolveSymbolId('src/missing.ts#User'), 'src/missing.ts#User')
+  // Paths that differ only in case match only as spelled
+  assert.equal(index.resolvePath('src/Readme.ts'), 'src/Readme.ts')
+  assert.equal(index.resolvePath('src/readme.ts'), null)
+  assert.deepEqual(pathCaseCollisions(index.listFiles()), [['src/README.ts', 'src/Readme.ts']])
+  // Snapshots keep the setting
+  assert.equal(index.snapshot().resolvePath('src/MODELS/User.ts'), 'src/models/User.ts')
+})
+
+test('index-paths: INDEXER_CASE_INSENSITIVE overrides the file system probe', async () => {
+  const previous = process.env.INDEXER_CASE_INSENSITIVE
+  try {
+    process.env.INDEXER_CASE_INSENSITIVE = 'true'
+    assert.equal(await isCaseInsensitiveFs(tmpdir()), true)
+    assert.equal(new SymbolIndex().caseInsensitive, true)
+    process.env.INDEXER_CASE_INSENSITIVE = 'false'
+    assert.equal(await isCaseInsensitiveFs(tmpdir()), false)
+  } finally {
+    if (previous === undefined) delete process.env.INDEXER_CASE_INSENSITIVE
+    else process.env.INDEXER_CASE_INSENSITIVE = previous
+  }
+})
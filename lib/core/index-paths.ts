@@ -0,0 +1,103 @@
+/**
+ * Index Paths Module
+ * Paths as the index stores them: relative to the project root, with '/'
+ * separators and without './' or '..' segments, whatever platform the index
+ * was built on. Paths that come in with a query (a CLI argument, an LSP
+ * document URI, an HTTP or gRPC request) are brought into that form before
+ * they are looked up, so a Windows checkout can query an index built on
+ * Linux and the other way round. On a case-insensitive file system (the
+ * default on macOS and Windows) a path that differs from an indexed one only
+ * in case resolves to it; when several indexed paths differ only in case,
+ * which an index built on Linux can hold, only the exact spelling matches
+ * and pathCaseCollisions() reports them.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+
+/**
+ * A project-relative path in index form: a/b/c.ts for a\b\c.ts, ./a/./b/../c.ts
+ * or a//c.ts
+ */
+export function toIndexPath(filePath: string): string {
+  const normalized = path.posix.normalize(filePath.replace(/\\/g, '/'))
+  return normalized === '.' ? '' : normalized.replace(/^\.\//, '').replace(/\/+$/, '')
+}
+
+/**
+ * Index path of an absolute path under the project root
+ * @returns null for a path outside the root
+ */
+export function relativeIndexPath(projectRoot: string, absPath: string): string | null {
+  const rel = path.relative(projectRoot, absPath)
+  if (rel === '..' || rel.startsWith(`..${path.sep}`) || path.isAbsolute(rel)) return null
+  return toIndexPath(rel)
+}
+
+/**
+ * Key two paths share when they name the same file on a case-insensitive
+ * file system
+ */
+export function foldPathCase(filePath: string): string {
+  return filePath.toLowerCase()
+}
+
+/**
+ * Whether paths are matched regardless of case when the file system is not
+ * probed: INDEXER_CASE_INSENSITIVE=true|false, else true on macOS and Windows
+ */
+export function defaultCaseInsensitive(): boolean {
+  const configured = process.env.INDEXER_CASE_INSENSITIVE
+  if (configured) return configured !== 'false' && configured !== '0'
+  return process.platform === 'win32' || process.platform === 'darwin'
+}
+
+/**
+ * Whether the file system a directory is on ignores case, found by looking
+ * the directory up with its case swapped. INDEXER_CASE_INSENSITIVE overrides
+ * the probe; a path without letters falls back to defaultCaseInsensitive().
+ */
+export async function isCaseInsensitiveFs(dir: string): Promise<boolean> {
+  if (process.env.INDEXER_CASE_INSENSITIVE) return defaultCaseInsensitive()
+  const resolved = path.resolve(dir)
+  const swapped = resolved.replace(/\p{L}/gu, c => (c === c.toLowerCase() ? c.toUpperCase() : c.toLowerCase()))
+  if (swapped === resolved) return defaultCaseInsensitive()
+  try {
+    const [original, other] = await Promise.all([fs.stat(resolved), fs.stat(swapped)])
+    return original.dev === other.dev && original.ino === other.ino
+  } catch {
+    return false
+  }
+}
+
+/**
+ * Indexed paths by their case-folded key, leaving out keys several paths
+ * share
+ */
+export function caseFoldedPaths(paths: Iterable<string>): Map<string, string> {
+  const folded = new Map<string, string | null>()
+  for (const filePath of paths) {
+    const key = foldPathCase(filePath)
+    folded.set(key, folded.has(key) ? null : filePath)
+  }
+  const unique = new Map<string, string>()
+  for (const [key, filePath] of folded) {
+    if (filePath !== null) unique.set(key, filePath)
+  }
+  return unique
+}
+
+/**
+ * Groups of paths that differ only in case; a case-insensitive checkout can
+ * hold only one file of each group
+ */
+export function pathCaseCollisions(paths: Iterable<string>): string[][] {
+  const groups = new Map<string, string[]>()
+  for (const filePath of paths) {
+    const key = foldPathCase(filePath)
+    const group = groups.get(key)
+    if (group) group.push(filePath)
+    else groups.set(key, [filePath])
+  }
+  return [...groups.values()].filter(group => group.length > 1).map(group => group.sort())
+}
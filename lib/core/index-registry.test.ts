@@ -0,0 +1,155 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import crypto from 'crypto'
+import fs from 'fs/promises'
+import http from 'http'
+import os from 'os'
+import path from 'path'
+import { execFileSync } from 'child_process'
+import type { AddressInfo } from 'net'
+import { artifactUrl, pullRevisionIndex, pushRevisionIndex, registryBaseUrl } from './index-registry.js'
+import { openRevisionIndex } from './revision-index.js'
+import { SHARD_FORMAT_VERSION } from './symbol-index.js'
+import { getSymbolPackPath } from '../utils/symbol-pack.js'
+import { resolveRevision } from '../utils/git.js'
+
+function git(cwd: string, ...args: string[]) {
+  execFileSync('git', ['-c', 'user.name=test', '-c', 'user.email=test@example.com', ...args], { cwd, stdio: 'ignore' })
+}
+
+// Sets environment variables for the length of a test, restoring them after
+async function withEnv<T>(vars: Record<string, string>, run: () => Promise<T> | T): Promise<T> {
+  const previous = Object.fromEntries(Object.keys(vars).map(name => [name, process.env[name]]))
+  Object.assign(process.env, vars)
+  try {
+    return await run()
+  } finally {
+    for (const [name, value] of Object.entries(previous)) {
+      if (value === undefined) delete process.env[name]
+      else process.env[name] = value
+    }
+  }
+}
+
+// In-memory object store answering PUT and GET
+async function startRegistry() {
+  const objects = new Map<string, Buffer>()
+  const requests: { method: string, url: string, headers: http.IncomingHttpHeaders, body: Buffer }[] = []
+  const server = http.createServer((req, res) => {
+    const chunks: Buffer[] = []
+    req.on('data', chunk => chunks.push(chunk))
+    req.on('end', () => {
+      requests.push({ method: req.method!, url: req.url!, headers: req.headers, body: Buffer.concat(chunks) })
+      if (req.method === 'PUT') {
+        objects.set(req.url!, Buffer.concat(chunks))
+        res.writeHead(200).end()
+      } else if (objects.has(req.url!)) {
+        res.writeHead(200).end(objects.get(req.url!))
+      } else {
+        res.writeHead(404).end()
+      }
+    })
+  })
+  await new Promise<void>(resolve => server.listen(0, '127.0.0.1', resolve))
+  const origin = `http://127.0.0.1:${(server.address() as AddressInfo).port}`
+  return { objects, requests, origin, url: `${origin}/indexes`, close: () => new Promise(resolve => server.close(resolve)) }
+}
+
+test('index-registry: maps registry locations to artifact URLs', async () => {
+  await withEnv({ AWS_REGION: 'eu-west-1', INDEXER_S3_ENDPOINT: '' }, () => {
+    assert.equal(registryBaseUrl('s3://ci-indexes/team/'), 'https://ci-indexes.s3.eu-west-1.amazonaws.com/team')
+  })
+  await withEnv({ INDEXER_S3_ENDPOINT: 'http://minio:9000/' }, () => {
+    assert.equal(registryBaseUrl('s3://ci-indexes/team'), 'http://minio:9000/ci-indexes/team')
+  })
+  assert.equal(registryBaseUrl('gs://ci-indexes'), 'https://storage.googleapis.com/ci-indexes')
+  assert.equal(registryBaseUrl('https://cache.example.com/idx'), 'https://cache.example.com/idx')
+  assert.throws(() => registryBaseUrl('ftp://example.com'), /Unsupported registry/)
+  assert.equal(
+    artifactUrl('https://cache.example.com', '@acme/api', 'abc123'),
+    `https://cache.example.com/%40acme%2Fapi/abc123.${SHARD_FORMAT_VERSION}.idxpack`
+  )
+})
+
+test('index-registry: a pulled index is used by a clone without rebuilding', async () => {
+  const tmp = await fs.mkdtemp(path.join(os.tmpdir(), 'index-registry-'))
+  const origin = path.join(tmp, 'origin')
+  const clone = path.join(tmp, 'clone')
+  const registry = await startRegistry()
+  const packs: string[] = []
+  try {
+    await fs.mkdir(origin)
+    git(origin, 'init', '-q')
+    await fs.writeFile(path.join(origin, 'package.json'), '{"name":"@acme/api"}\n')
+    await fs.writeFile(path.join(origin, 'user.ts'), 'export class User {}\n')
+    git(origin, 'add', '-A')
+    git(origin, 'commit', '-q', '-m', 'first')
+    git(tmp, 'clone', '-q', origin, clone)
+
+    const pulledEarly = await pullRevisionIndex(clone, 'HEAD', registry.url)
+    assert.equal(pulledEarly, null)
+
+    const pushed = await pushRevisionIndex(origin, 'HEAD', registry.url)
+    packs.push(getSymbolPackPath(origin, `${pushed.commit}.${SHARD_FORMAT_VERSION}`))
+    assert.equal(pushed.url, `${registry.url}/%40acme%2Fapi/${pushed.commit}.${SHARD_FORMAT_VERSION}.idxpack`)
+    assert.equal(registry.objects.size, 1)
+
+    const pulled = (await pullRevisionIndex(clone, 'HEAD', registry.url))!
+    packs.push(getSymbolPackPath(clone, `${pulled.commit}.${SHARD_FORMAT_VERSION}`))
+    assert.equal(pulled.commit, pushed.commit)
+    assert.equal(pulled.bytes, pushed.bytes)
+
+    const { index, cached } = await openRevisionIndex(clone, 'HEAD')
+    assert.equal(cached, true)
+    assert.equal(index.findSymbols('User').length, 1)
+
+    // Anything that is not a pack is rejected and leaves no file behind
+    git(clone, 'commit', '-q', '--allow-empty', '-m', 'second')
+    const second = await resolveRevision(clone, 'HEAD')
+    registry.objects.set(`/indexes/%40acme%2Fapi/${second}.${SHARD_FORMAT_VERSION}.idxpack`, Buffer.from('<html>'))
+    await assert.rejects(pullRevisionIndex(clone, 'HEAD', registry.url), /not a symbol pack/)
+    const secondPack = getSymbolPackPath(clone, `${second}.${SHARD_FORMAT_VERSION}`)
+    await assert.rejects(fs.access(secondPack))
+    await assert.rejects(fs.access(`${secondPack}.download`))
+  } finally {
+    await registry.close()
+    for (const pack of packs) await fs.rm(pack, { force: true })
+    await fs.rm(tmp, { recursive: true, force: true })
+  }
+})
+
+test('index-registry: requests to s3:// registries are signed with the AWS credentials', async () => {
+  const tmp = await fs.mkdtemp(path.join(os.tmpdir(), 'index-registry-'))
+  const origin = path.join(tmp, 'origin')
+  const clone = path.join(tmp, 'clone')
+  const registry = await startRegistry()
+  const packs: string[] = []
+  const env = { INDEXER_S3_ENDPOINT: registry.origin, AWS_ACCESS_KEY_ID: 'AKIDEXAMPLE', AWS_SECRET_ACCESS_KEY: 'secret', AWS_REGION: 'eu-west-1' }
+  try {
+    await fs.mkdir(origin)
+    git(origin, 'init', '-q')
+    await fs.writeFile(path.join(origin, 'user.ts'), 'export class User {}\n')
+    git(origin, 'add', '-A')
+    git(origin, 'commit', '-q', '-m', 'first')
+    git(tmp, 'clone', '-q', origin, clone)
+
+    const { pushed, pulled } = await withEnv(env, async () => {
+      const pushed = await pushRevisionIndex(origin, 'HEAD', 's3://indexes/team', 'api')
+      const pulled = (await pullRevisionIndex(clone, 'HEAD', 's3://indexes/team', 'api'))!
+      return { pushed, pulled }
+    })
+    packs.push(getSymbolPackPath(origin, `${pushed.commit}.${SHARD_FORMAT_VERSION}`), getSymbolPackPath(clone, `${pulled.commit}.${SHARD_FORMAT_VERSION}`))
+    assert.equal(pushed.url, `${registry.origin}/indexes/team/api/${pushed.commit}.${SHARD_FORMAT_VERSION}.idxpack`)
+    assert.equal(pulled.bytes, pushed.bytes)
+
+    assert.deepEqual(registry.requests.map(r => r.method), ['PUT', 'GET'])
+    for (const request of registry.requests) {
+      assert.match(String(request.headers.authorization), /^AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE\/\d{8}\/eu-west-1\/s3\/aws4_request, SignedHeaders=[^,]*host;[^,]*x-amz-date, Signature=[0-9a-f]{64}$/)
+      assert.equal(request.headers['x-amz-content-sha256'], crypto.createHash('sha256').update(request.body).digest('hex'))
+    }
+  } finally {
+    await registry.close()
+    for (const pack of packs) await fs.rm(pack, { force: true })
+    await fs.rm(tmp, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,142 @@
+/**
+ * Index Registry Module
+ * Shares revision indexes through an object store so CI builds them once and
+ * developers download them. The protocol is plain HTTP on one key per
+ * artifact, <registry>/<module>/<commit>.<shard format>.idxpack: PUT uploads
+ * a symbol pack, GET downloads it, 404 means nobody has pushed it yet.
+ * gs://bucket/prefix maps to the Cloud Storage HTTPS endpoint, which takes
+ * an OAuth access token; INDEXER_REGISTRY_TOKEN, if set, is sent as a bearer
+ * token. s3://bucket/prefix maps to the bucket's endpoint in AWS_REGION (or
+ * INDEXER_S3_ENDPOINT, path-style, for S3-compatible stores), and requests
+ * to it are signed with the AWS credentials in the environment (see
+ * aws-sigv4); without credentials they go unsigned, for public buckets.
+ * A redaction policy (see redaction) strips text from what is uploaded; the
+ * local pack keeps it.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { SHARD_FORMAT_VERSION } from './symbol-index.js'
+import { openRevisionIndex } from './revision-index.js'
+import { resolveRevision } from '../utils/git.js'
+import { SymbolPackReader, getSymbolPackPath, readSymbolPack, writeSymbolPack } from '../utils/symbol-pack.js'
+import { redactShard, type RedactionClass } from './redaction.js'
+import { awsCredentialsFromEnv, awsRegionFromEnv, signRequest } from '../utils/aws-sigv4.js'
+
+export interface RegistryTransfer {
+  commit: string
+  url: string
+  bytes: number
+}
+
+/**
+ * HTTP(S) base URL of a registry location
+ */
+export function registryBaseUrl(location: string): string {
+  const trimmed = location.replace(/\/+$/, '')
+  const s3 = trimmed.match(/^s3:\/\/([^/]+)(\/.*)?$/)
+  if (s3) {
+    const endpoint = process.env.INDEXER_S3_ENDPOINT?.replace(/\/+$/, '')
+    if (endpoint) return `${endpoint}/${s3[1]}${s3[2] || ''}`
+    return `https://${s3[1]}.s3.${awsRegionFromEnv()}.amazonaws.com${s3[2] || ''}`
+  }
+  const gs = trimmed.match(/^gs:\/\/([^/]+)(\/.*)?$/)
+  if (gs) return `https://storage.googleapis.com/${gs[1]}${gs[2] || ''}`
+  if (!/^https?:\/\//.test(trimmed)) throw new Error(`Unsupported registry location: ${location}`)
+  return trimmed
+}
+
+/**
+ * URL of a module's index artifact for a commit
+ */
+export function artifactUrl(location: string, moduleName: string, commit: string): string {
+  return `${registryBaseUrl(location)}/${encodeURIComponent(moduleName)}/${commit}.${SHARD_FORMAT_VERSION}.idxpack`
+}
+
+/**
+ * Name an index is published under: the package.json name, else the
+ * project directory's name
+ */
+export async function registryModuleName(projectRoot: string): Promise<string> {
+  try {
+    const pkg = JSON.parse(await fs.readFile(path.join(projectRoot, 'package.json'), 'utf8'))
+    if (typeof pkg.name === 'string' && pkg.name) return pkg.name
+  } catch {}
+  return path.basename(path.resolve(projectRoot))
+}
+
+// Request to a registry, signed for S3 and with the bearer token otherwise
+function registryFetch(location: string, url: string, method: 'GET' | 'PUT', body?: Buffer): Promise<Response> {
+  let headers: Record<string, string> = body ? { 'Content-Type': 'application/octet-stream' } : {}
+  const token = process.env.INDEXER_REGISTRY_TOKEN
+  if (/^s3:\/\//.test(location)) {
+    const credentials = awsCredentialsFromEnv()
+    if (credentials) headers = signRequest({ method, url, headers, body }, { credentials, region: awsRegionFromEnv(), service: 's3' })
+  } else if (token) {
+    headers.Authorization = `Bearer ${token}`
+  }
+  return fetch(url, { method, headers, body })
+}
+
+// Pack content with a policy applied, written next to the pack and read back
+async function redactedPack(packPath: string, redact: RedactionClass[]): Promise<Buffer> {
+  const shards = await readSymbolPack(packPath)
+  if (!shards) throw new Error(`${packPath} is not a symbol pack`)
+  const tmpPath = `${packPath}.redacted`
+  try {
+    await writeSymbolPack(tmpPath, shards.map(shard => redactShard(shard, redact)), { format: SHARD_FORMAT_VERSION })
+    return await fs.readFile(tmpPath)
+  } finally {
+    await fs.rm(tmpPath, { force: true })
+  }
+}
+
+/**
+ * Build (or reuse) the index of a revision and upload it
+ * @param redact - Text to strip from the upload (see redaction)
+ */
+export async function pushRevisionIndex(
+  projectRoot: string,
+  rev: string,
+  location: string,
+  moduleName?: string,
+  redact: RedactionClass[] = []
+): Promise<RegistryTransfer> {
+  const { commit } = await openRevisionIndex(projectRoot, rev)
+  const packPath = getSymbolPackPath(projectRoot, `${commit}.${SHARD_FORMAT_VERSION}`)
+  const data = redact.length > 0 ? await redactedPack(packPath, redact) : await fs.readFile(packPath)
+  const url = artifactUrl(location, moduleName || await registryModuleName(projectRoot), commit)
+
+  const res = await registryFetch(location, url, 'PUT', data)
+  if (!res.ok) throw new Error(`Upload to ${url} failed: HTTP ${res.status}`)
+  return { commit, url, bytes: data.length }
+}
+
+/**
+ * Download the prebuilt index of a revision into the local pack store,
+ * where --rev=<rev> picks it up
+ * @returns The transfer, or null if the registry has no index for the commit
+ */
+export async function pullRevisionIndex(projectRoot: string, rev: string, location: string, moduleName?: string): Promise<RegistryTransfer | null> {
+  const commit = await resolveRevision(projectRoot, rev)
+  const url = artifactUrl(location, moduleName || await registryModuleName(projectRoot), commit)
+
+  const res = await registryFetch(location, url, 'GET')
+  if (res.status === 404) return null
+  if (!res.ok) throw new Error(`Download from ${url} failed: HTTP ${res.status}`)
+  const data = Buffer.from(await res.arrayBuffer())
+
+  // Only a readable pack replaces what is stored locally
+  const packPath = getSymbolPackPath(projectRoot, `${commit}.${SHARD_FORMAT_VERSION}`)
+  await fs.mkdir(path.dirname(packPath), { recursive: true })
+  const tmpPath = `${packPath}.download`
+  await fs.writeFile(tmpPath, data)
+  const reader = await SymbolPackReader.open(tmpPath).catch(() => null)
+  if (!reader) {
+    await fs.rm(tmpPath, { force: true })
+    throw new Error(`Download from ${url} is not a symbol pack`)
+  }
+  await reader.close()
+  await fs.rename(tmpPath, packPath)
+  return { commit, url, bytes: data.length }
+}
@@ -0,0 +1,69 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { Overlay, memoryFileSource } from './file-source.js'
+import { streamIndex } from './index-stream.js'
+import { SymbolIndex } from './symbol-index.js'
+
+const FILES = {
+  'src/user.ts': 'export class User {\n  save() {}\n}\n',
+  'src/admin.ts': 'import { User } from "./user"\nexport function promote(u: User) { u.save() }\n',
+  'src/util.ts': 'export const VERSION = 1\n'
+}
+
+test('index-stream: streams symbols and references per batch, in input order', async () => {
+  const files: string[] = []
+  const symbols: string[] = []
+  let references = 0
+  const { index, update } = await streamIndex(memoryFileSource(FILES), {
+    onFile: shard => { files.push(shard.path) },
+    onSymbol: sym => { symbols.push(sym.name) },
+    onReference: () => { references++ }
+  }, { batchSize: 2 })
+
+  assert.deepEqual(files, ['src/admin.ts', 'src/user.ts', 'src/util.ts'])
+  assert.deepEqual(update.added, files)
+  assert.ok(symbols.includes('User.save') && symbols.includes('promote'))
+  assert.ok(references > 0)
+  assert.equal(index.findSymbols('VERSION').length, 1)
+})
+
+test('index-stream: retain=false drops each batch once it was handed out', async () => {
+  const index = new SymbolIndex()
+  const files: string[] = []
+  const { update } = await streamIndex(memoryFileSource(FILES), {
+    onFile: shard => {
+      files.push(shard.path)
+      assert.ok(index.getFile(shard.path))
+    }
+  }, { index, batchSize: 1, retain: false })
+
+  assert.deepEqual(files, ['src/admin.ts', 'src/user.ts', 'src/util.ts'])
+  assert.deepEqual(update.added, files)
+  assert.deepEqual(index.listFiles(), [])
+})
+
+test('index-stream: an overlay re-indexes only what it changes', async () => {
+  const base = memoryFileSource(FILES)
+  const { index } = await streamIndex(base)
+
+  const overlay = new Overlay(base)
+    .write('src/user.ts', 'export class User {\n  save() {}\n  load() {}\n}\n')
+    .write('src/new.ts', 'export function fresh() {}\n')
+    .delete('src/util.ts')
+  const seen: string[] = []
+  const removed: string[] = []
+  const { update } = await streamIndex(overlay, { onFile: s => { seen.push(s.path) }, onRemove: p => { removed.push(p) } }, { index })
+
+  assert.deepEqual(seen, ['src/new.ts', 'src/user.ts'])
+  assert.deepEqual(removed, ['src/util.ts'])
+  assert.deepEqual(update.unchanged, ['src/admin.ts'])
+  assert.equal(index.findSymbols('User.load').length, 1)
+  assert.equal(index.findSymbols('VERSION').length, 0)
+
+  // Just the files an editor touched
+  overlay.reset('src/util.ts').write('src/new.ts', 'export function renamed() {}\n')
+  const partial = await streamIndex(overlay, {}, { index, files: ['src/new.ts', 'src/util.ts'] })
+  assert.deepEqual(partial.update.modified, ['src/new.ts'])
+  assert.deepEqual(partial.update.added, ['src/util.ts'])
+  assert.deepEqual(index.findSymbols('fresh'), [])
+})
@@ -0,0 +1,106 @@
+/**
+ * Index Stream Module
+ * Library entry point for embedding the indexer in other tools. Files come
+ * from any FileSource and are indexed in batches; after each batch is
+ * committed, the symbols and references of every file it added or changed
+ * are handed to callbacks in input order, so a build system can consume
+ * results while the rest of the tree is still being parsed. With
+ * `retain: false` each batch is dropped once its callbacks ran, so memory
+ * stays flat however big the tree is.
+ */
+
+import { SymbolIndex, indexFileContents, type IndexUpdate } from './symbol-index.js'
+import { readSourceFiles, type FileSource } from './file-source.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import type { FileShard, IndexedSymbol, SymbolReference } from '../types/index.js'
+
+const DEFAULT_BATCH_SIZE = 256
+
+export interface IndexStreamHandlers {
+  /** A file was added or re-indexed, with everything extracted from it */
+  onFile?(shard: FileShard): void | Promise<void>
+  onSymbol?(sym: IndexedSymbol): void | Promise<void>
+  onReference?(ref: SymbolReference): void | Promise<void>
+  /** A file left the index (deleted from the source) */
+  onRemove?(relPath: string): void | Promise<void>
+}
+
+export interface IndexStreamOptions {
+  index?: SymbolIndex // index to update; a new one by default
+  files?: string[] // index only these paths (missing ones are removed) instead of syncing the whole source
+  batchSize?: number // files read and parsed per batch
+  signal?: AbortSignal // stop after the current batch
+  retain?: boolean // keep indexed files in the index (default true); false discards each batch after its callbacks
+}
+
+export interface IndexStreamResult {
+  index: SymbolIndex
+  update: IndexUpdate
+}
+
+async function emit(handlers: IndexStreamHandlers, shard: FileShard): Promise<void> {
+  if (handlers.onFile) await handlers.onFile(shard)
+  if (handlers.onSymbol) {
+    for (const sym of shard.symbols) await handlers.onSymbol(sym)
+  }
+  if (handlers.onReference) {
+    for (const ref of shard.references) await handlers.onReference(ref)
+  }
+}
+
+/**
+ * Index the files of a source, streaming results to callbacks. Without
+ * `files`, the index is synced to the source: files it no longer lists are
+ * removed, unchanged contents are skipped. Each batch is one index.write(),
+ * so concurrent readers see whole batches.
+ * @param source - Where to read files from
+ * @param handlers - Callbacks for indexed and removed files
+ * @param options - Index to update, subset of files, batch size, abort signal
+ */
+export async function streamIndex(
+  source: FileSource,
+  handlers: IndexStreamHandlers = {},
+  options: IndexStreamOptions = {}
+): Promise<IndexStreamResult> {
+  await initTreeSitter()
+  const index = options.index || new SymbolIndex()
+  const batchSize = Math.max(1, options.batchSize || DEFAULT_BATCH_SIZE)
+  const update: IndexUpdate = { added: [], modified: [], removed: [], unchanged: [] }
+  const relPaths = options.files || await source.listFiles()
+
+  if (!options.files) {
+    const current = new Set(relPaths)
+    const stale = index.listFiles().filter(relPath => !current.has(relPath))
+    await index.write(async () => {
+      for (const relPath of stale) index.removeFile(relPath)
+    })
+    for (const relPath of stale) {
+      update.removed.push(relPath)
+      if (handlers.onRemove) await handlers.onRemove(relPath)
+    }
+  }
+
+  for (let start = 0; start < relPaths.length; start += batchSize) {
+    if (options.signal?.aborted) break
+    const batch = relPaths.slice(start, start + batchSize)
+    const contents = await readSourceFiles(source, batch)
+    const batchUpdate = await index.write(() => indexFileContents(index, batch, contents))
+
+    const changed = new Set([...batchUpdate.added, ...batchUpdate.modified])
+    const removed = new Set(batchUpdate.removed)
+    for (const relPath of batch) {
+      if (changed.has(relPath)) await emit(handlers, index.getFile(relPath)!)
+      else if (removed.has(relPath) && handlers.onRemove) await handlers.onRemove(relPath)
+    }
+    if (options.retain === false) {
+      await index.write(async () => {
+        for (const relPath of batch) index.removeFile(relPath)
+      })
+    }
+    update.added.push(...batchUpdate.added)
+    update.modified.push(...batchUpdate.modified)
+    update.removed.push(...batchUpdate.removed)
+    update.unchanged.push(...batchUpdate.unchanged)
+  }
+  return { index, update }
+}
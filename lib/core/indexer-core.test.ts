@@ -143,6 +143,8 @@ test('TypeScript parsing captures functions, classes, and methods', async () =>
       'Runner',
       'UserId',
       'Status',
+      'Status.Idle',
+      'Status.Active',
       'Core',
       'Core.Sub',
       'Version',
@@ -160,6 +162,7 @@ test('TypeScript parsing captures functions, classes, and methods', async () =>
       'interface',
       'type',
       'enum',
+      'constant',
       'namespace',
       'const',
       'function',
@@ -201,6 +204,8 @@ test('C# parsing captures Unity structures, fields, and lifecycle methods', asyn
       'Point',
       'IRunnable',
       'State',
+      'State.Idle',
+      'State.Active',
       'Run',
       'X',
       'Score',
@@ -215,6 +220,7 @@ test('C# parsing captures Unity structures, fields, and lifecycle methods', asyn
       'struct',
       'interface',
       'enum',
+      'constant',
       'property',
       'method',
       'unity_lifecycle',
@@ -0,0 +1,111 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { findLandmarks } from './landmark-sites.js'
+import { querySymbols } from './symbol-query.js'
+import { selectSymbols } from './query-language.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const GRID_SRC = `export function find(grid, value) {
+  outer: for (const row of grid) {
+    for (const cell of row) {
+      if (cell === value) break outer
+      if (!cell) continue outer
+    }
+  }
+  const inner = () => { done: for (;;) break done }
+}
+
+export function sum(values) {
+  let total = 0
+  for (const v of values) total += v
+  return total
+}
+`
+
+// What the extractors record for GRID_SRC and a C# state machine
+const FILES: FixtureFiles = {
+  'src/grid.ts': [
+    {
+      name: 'find',
+      kind: 'function',
+      line: 1,
+      end_line: 9,
+      landmarks: [
+        { kind: 'label', line: 2, column: 3, label: 'outer', end_line: 7 },
+        { kind: 'break', line: 4, column: 27, label: 'outer', target: 2 },
+        { kind: 'continue', line: 5, column: 20, label: 'outer', target: 2 }
+      ]
+    },
+    { name: 'sum', kind: 'function', line: 11, end_line: 15 }
+  ],
+  'src/Machine.cs': [
+    {
+      name: 'Machine.Run',
+      kind: 'method',
+      line: 3,
+      end_line: 14,
+      landmarks: [
+        { kind: 'goto', line: 5, column: 7, label: 'retry', target: 9 },
+        { kind: 'goto', line: 7, column: 7, label: 'fail', target: 11 },
+        { kind: 'label', line: 9, column: 5, label: 'retry', end_line: 9 },
+        { kind: 'label', line: 11, column: 5, label: 'fail', end_line: 12 }
+      ]
+    }
+  ]
+}
+
+test('landmark-sites: the extractor records labels and labeled jumps with their targets', () => {
+  const symbols = extractJSSymbols(GRID_SRC)
+  const fn = (name: string) => symbols.find(s => s.name === name && s.kind === 'function')!
+  assert.deepEqual(fn('find').landmarks, createIndex(FILES).findSymbols('find')[0].landmarks)
+  assert.equal(fn('sum').landmarks, undefined)
+})
+
+test('landmark-sites: the extractor records catch and finally handlers as landmarks', () => {
+  const symbols = extractJSSymbols(`export function load(path) {
+  try {
+    return read(path)
+  } catch (e) {
+    const parse = () => { try { return 1 } finally { log() } }
+    return null
+  } finally {
+    close(path)
+  }
+}
+`)
+  const load = symbols.find(s => s.name === 'load' && s.kind === 'function')!
+  assert.deepEqual(load.landmarks, [
+    { kind: 'catch', line: 4, column: 5, end_line: 7 },
+    { kind: 'finally', line: 7, column: 13, end_line: 9 }
+  ])
+})
+
+test('landmark-sites: lists landmarks by kind, label and function', () => {
+  const index = createIndex(FILES)
+  const sites = findLandmarks(index)
+  assert.deepEqual(sites.map(s => [s.path, s.line, s.kind, s.symbol.name]), [
+    ['src/Machine.cs', 5, 'goto', 'Machine.Run'],
+    ['src/Machine.cs', 7, 'goto', 'Machine.Run'],
+    ['src/Machine.cs', 9, 'label', 'Machine.Run'],
+    ['src/Machine.cs', 11, 'label', 'Machine.Run'],
+    ['src/grid.ts', 2, 'label', 'find'],
+    ['src/grid.ts', 4, 'break', 'find'],
+    ['src/grid.ts', 5, 'continue', 'find']
+  ])
+  assert.deepEqual(findLandmarks(index, { kinds: ['goto'], label: 'fail' }).map(s => [s.line, s.target]), [[7, 11]])
+  assert.deepEqual(findLandmarks(index, { name: 'find', kinds: ['break', 'continue'] }).map(s => s.line), [4, 5])
+})
+
+test('landmark-sites: functions filtered by landmark counts', () => {
+  const index = createIndex(FILES)
+  const names = (landmarks: string[]) => querySymbols(index, { landmarks }).map(s => s.name)
+  assert.deepEqual(names(['goto']), ['Machine.Run'])
+  assert.deepEqual(names(['label>1']), ['Machine.Run'])
+  assert.deepEqual(names(['label=1', 'continue']), ['find'])
+  assert.deepEqual(names(['goto=0']), ['find', 'sum'])
+  assert.throws(() => names(['defer>2']), /Invalid landmark filter/)
+  assert.deepEqual(names(['finally=0']), ['Machine.Run', 'find', 'sum'])
+  assert.deepEqual(selectSymbols(index, 'landmarks.goto.count >= 2').map(s => s.name), ['Machine.Run'])
+  assert.deepEqual(selectSymbols(index, 'landmarks.break AND NOT landmarks.goto').map(s => s.name), ['find'])
+})
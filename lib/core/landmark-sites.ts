@@ -0,0 +1,47 @@
+/**
+ * Landmark Sites Module
+ * Lists the control-flow landmarks stored on function symbols (labels,
+ * goto, labeled break / continue; see landmarks) across the index, each
+ * with the function it is in and, for a jump, the line it goes to, so
+ * `indexer landmarks` can navigate them without re-reading sources.
+ */
+
+import { matchesPackage, namePattern } from './symbol-query.js'
+import { symbolLandmarks, type Landmark, type LandmarkKind } from '../utils/landmarks.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export interface LandmarkQuery {
+  kinds?: LandmarkKind[]
+  packages?: string[]
+  name?: string // functions matching a name pattern (see namePattern)
+  label?: string // landmarks naming this label
+}
+
+export interface LandmarkSite extends Landmark {
+  path: string
+  symbol: IndexedSymbol // function the landmark is in
+}
+
+/**
+ * Landmarks matching a query, sorted by path and line
+ */
+export function findLandmarks(index: SymbolIndex, query: LandmarkQuery = {}): LandmarkSite[] {
+  const kinds = query.kinds && query.kinds.length > 0 ? new Set(query.kinds) : null
+  const packages = query.packages && query.packages.length > 0 ? query.packages : null
+  const matchName = query.name ? namePattern(query.name) : null
+
+  const sites: LandmarkSite[] = []
+  for (const sym of index.allSymbols()) {
+    const landmarks = symbolLandmarks(sym)
+    if (landmarks.length === 0) continue
+    if (packages && !packages.some(p => matchesPackage(sym.path, p))) continue
+    if (matchName && !matchName(sym.name)) continue
+    for (const landmark of landmarks) {
+      if (kinds && !kinds.has(landmark.kind)) continue
+      if (query.label !== undefined && landmark.label !== query.label) continue
+      sites.push({ ...landmark, path: sym.path, symbol: sym })
+    }
+  }
+  return sites.sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : a.line - b.line || a.column - b.column))
+}
@@ -0,0 +1,95 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { SymbolIndex, makeSymbolId } from './symbol-index.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const CART_SRC = `export function total(items) {
+  let sum = 0
+  for (const item of items) {
+    const price = item.price
+    sum += price
+  }
+  return sum
+}
+
+export function price(item) {
+  return item.cost
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/cart.ts': extractJSSymbols(CART_SRC, { locals: true })
+}
+
+function sites(index: SymbolIndex, id: string): string[] {
+  return index.references(id).map(r => `${r.line}:${r.column}`)
+}
+
+test('local-scopes: locals are only extracted on request, keyed by their function', () => {
+  assert.equal(extractJSSymbols(CART_SRC).some(s => s.kind === 'local' || s.kind === 'parameter'), false)
+
+  const locals = createIndex(FILES).fileSymbols('src/cart.ts').filter(s => s.kind === 'local' || s.kind === 'parameter')
+  assert.deepEqual(locals.map(s => [s.id, s.kind, s.line, s.column]), [
+    ['src/cart.ts#total/items', 'parameter', 1, 23],
+    ['src/cart.ts#total/sum', 'local', 2, 7],
+    ['src/cart.ts#total/item', 'local', 3, 14],
+    ['src/cart.ts#total/price', 'local', 4, 11],
+    ['src/cart.ts#price/item', 'parameter', 10, 23]
+  ])
+  const price = locals.find(s => s.id === 'src/cart.ts#total/price')!
+  assert.deepEqual([price.scope, price.scope_line, price.scope_column, price.scope_end_line], ['total', 3, 29, 6])
+})
+
+test('local-scopes: references of a local stay inside its scope', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(sites(index, 'src/cart.ts#total/items'), ['3:22'])
+  assert.deepEqual(sites(index, 'src/cart.ts#total/item'), ['4:19'])
+  assert.deepEqual(sites(index, 'src/cart.ts#price/item'), ['11:10'])
+  assert.deepEqual(sites(index, 'src/cart.ts#total/sum'), ['5:5', '7:10'])
+  // The local price shadows the function; item.price is a property, not the local
+  assert.deepEqual(sites(index, 'src/cart.ts#total/price'), ['5:12'])
+  assert.deepEqual(sites(index, makeSymbolId('src/cart.ts', 'price')), ['4:24'])
+})
+
+test('local-scopes: symbolAt resolves to the innermost local', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.symbolAt('src/cart.ts', 5, 12)!.symbols.map(s => s.id), ['src/cart.ts#total/price'])
+  assert.deepEqual(index.symbolAt('src/cart.ts', 11, 10)!.symbols.map(s => s.id), ['src/cart.ts#price/item'])
+  assert.deepEqual(index.symbolAt('src/cart.ts', 4, 24)!.symbols.map(s => s.id), [makeSymbolId('src/cart.ts', 'price')])
+})
+
+const SEARCH_SRC = `export function first<T>(rows: T[][]): T | null {
+  outer: for (const row of rows) {
+    for (const cell of row) {
+      if (cell === outer) return cell
+      if (!cell) continue outer
+    }
+  }
+  return null
+}
+
+export const outer = 0
+
+export class Box<T> {
+  get(): T | null { return null }
+}
+`
+
+test('local-scopes: type parameters and labels are scoped names', () => {
+  assert.equal(extractJSSymbols(SEARCH_SRC).some(s => s.kind === 'type_parameter' || s.kind === 'label'), false)
+
+  const index = new SymbolIndex()
+  index.addFile('src/search.ts', 'typescript', extractJSSymbols(SEARCH_SRC, { locals: true }))
+  const scoped = index.fileSymbols('src/search.ts').filter(s => s.kind === 'type_parameter' || s.kind === 'label')
+  assert.deepEqual(scoped.map(s => [s.id, s.kind, s.line, s.column, s.scope_line, s.scope_end_line]), [
+    ['src/search.ts#first/T', 'type_parameter', 1, 23, 1, 9],
+    ['src/search.ts#first/outer', 'label', 2, 3, 2, 7],
+    ['src/search.ts#Box/T', 'type_parameter', 13, 18, 13, 15]
+  ])
+  assert.deepEqual(sites(index, 'src/search.ts#first/T'), ['1:32', '1:40'])
+  assert.deepEqual(sites(index, 'src/search.ts#Box/T'), ['14:10'])
+  // continue outer names the label; the comparison reads the constant
+  assert.deepEqual(sites(index, 'src/search.ts#first/outer'), ['5:27'])
+  assert.deepEqual(sites(index, makeSymbolId('src/search.ts', 'outer')), ['4:20'])
+})
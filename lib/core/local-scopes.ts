@@ -0,0 +1,74 @@
+/**
+ * Local Scopes Module
+ * Resolves identifiers to the parameters, local variables, type parameters
+ * and labels indexed with --locals. Each local carries the extent of the
+ * scope it is visible in; a reference binds to the innermost same-named
+ * local whose scope contains it, so occurrences of a local never leak into
+ * other functions or onto a same-named top-level symbol, and shadowing is
+ * respected. Labels are names of their own: only break and continue refer
+ * to them.
+ */
+
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export const LOCAL_KINDS = new Set(['local', 'parameter', 'type_parameter', 'label'])
+
+/** Indexed locals by file and name */
+export type LocalTable = Map<string, IndexedSymbol[]>
+
+function tableKey(filePath: string, name: string, label = false): string {
+  return `${filePath}\n${label ? ':' : ''}${name}`
+}
+
+export function isLocalSymbol(sym: IndexedSymbol): boolean {
+  return LOCAL_KINDS.has(sym.kind)
+}
+
+export function computeLocals(index: SymbolIndex): LocalTable {
+  const table: LocalTable = new Map()
+  for (const shard of index.listShards()) {
+    for (const sym of shard.symbols) {
+      if (!isLocalSymbol(sym)) continue
+      const key = tableKey(sym.path, sym.name, sym.kind === 'label')
+      const list = table.get(key)
+      if (list) list.push(sym)
+      else table.set(key, [sym])
+    }
+  }
+  return table
+}
+
+function comparePositions(line: number, column: number, otherLine: number, otherColumn: number): number {
+  return line - otherLine || column - otherColumn
+}
+
+/**
+ * Whether a position (1-based line and column) is inside a local's scope
+ */
+export function scopeContains(local: IndexedSymbol, line: number, column: number): boolean {
+  return comparePositions(local.scope_line, local.scope_column, line, column) <= 0 &&
+    comparePositions(line, column, local.scope_end_line, local.scope_end_column) < 0
+}
+
+/**
+ * Whether one local's scope lies inside (or is) another's
+ */
+export function scopeWithin(inner: IndexedSymbol, outer: IndexedSymbol): boolean {
+  return scopeContains(outer, inner.scope_line, inner.scope_column)
+}
+
+/**
+ * Local an identifier at a position binds to, or null when it names
+ * something declared outside every enclosing function
+ * @param label - The identifier is a break / continue target
+ */
+export function resolveLocal(table: LocalTable, filePath: string, name: string, line: number, column = 1, label = false): IndexedSymbol | null {
+  let best: IndexedSymbol | null = null
+  for (const local of table.get(tableKey(filePath, name, label)) || []) {
+    if (!scopeContains(local, line, column)) continue
+    // Nested scopes start later than the ones around them
+    if (!best || comparePositions(local.scope_line, local.scope_column, best.scope_line, best.scope_column) > 0) best = local
+  }
+  return best
+}
@@ -0,0 +1,53 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { makeSymbolId } from './symbol-index.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const SRC = `
+class Entity {
+  id = 0
+  #secret = 1
+  save() {}
+  describe() {}
+}
+
+class Person extends Entity {
+  name = ''
+  describe() {}
+}
+
+export class User extends Person {
+  email = ''
+  get label() { return this.name }
+  login() {}
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/model.ts': SRC
+}
+
+const USER = makeSymbolId('src/model.ts', 'User')
+
+test('member-sets: method set includes promoted methods, nearest wins', () => {
+  const methods = createIndex(FILES).methodSet(USER)
+  assert.deepEqual(methods.map(m => [m.symbol.name, m.via.join('>')]), [
+    ['User.login', ''],
+    ['Person.describe', 'Person'],
+    ['Entity.save', 'Person>Entity']
+  ])
+  assert.deepEqual(methods.map(m => m.promoted), [false, true, true])
+})
+
+test('member-sets: fields with and without inherited members', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.fields(USER, false).map(m => m.name), ['email', 'label'])
+  assert.deepEqual(index.fields(USER).map(m => m.name), ['email', 'label', 'name', 'id'])
+  assert.deepEqual(index.fields(makeSymbolId('src/model.ts', 'Entity')).map(m => m.name), ['id', '#secret'])
+})
+
+test('member-sets: unknown or non-type symbols have no members', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.methodSet('missing'), [])
+  assert.deepEqual(index.fields(makeSymbolId('src/model.ts', 'User.login')), [])
+})
@@ -0,0 +1,81 @@
+/**
+ * Member Sets Module
+ * Fields and methods of a type, including members promoted from its base
+ * types. A member declared closer to the type shadows a same-named member
+ * further up the hierarchy.
+ */
+
+import { shortName } from './symbol-index.js'
+import type { SymbolIndex } from './symbol-index.js'
+import { TYPE_KINDS, heritageOf, resolveTypeName } from './implementations.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export const METHOD_KINDS = new Set(['method', 'unity_lifecycle'])
+export const FIELD_KINDS = new Set(['property', 'field', 'private_field', 'serialized_field', 'accessor'])
+
+export interface MemberEntry {
+  name: string // member short name
+  symbol: IndexedSymbol
+  promoted: boolean
+  via: string[] // base types the member was promoted through, nearest first
+}
+
+/**
+ * Members declared directly on a type
+ */
+function ownMembers(index: SymbolIndex, type: IndexedSymbol, kinds: Set<string>): IndexedSymbol[] {
+  const prefix = `${type.name}.`
+  return index.fileSymbols(type.path).filter(s =>
+    kinds.has(s.kind) && s.name.startsWith(prefix) && !s.name.slice(prefix.length).includes('.')
+  )
+}
+
+/**
+ * Walk a type and its base types breadth-first, nearest declarations first
+ */
+function collectMembers(index: SymbolIndex, type: IndexedSymbol, kinds: Set<string>, includeInherited: boolean): MemberEntry[] {
+  const result: MemberEntry[] = []
+  const seenNames = new Set<string>()
+  const seenTypes = new Set<string>([type.id])
+  let level: { type: IndexedSymbol, via: string[] }[] = [{ type, via: [] }]
+
+  while (level.length > 0) {
+    const next: { type: IndexedSymbol, via: string[] }[] = []
+    for (const { type: current, via } of level) {
+      const promoted = via.length > 0
+      for (const sym of ownMembers(index, current, kinds)) {
+        const name = shortName(sym.name)
+        // #private members are not visible to subclasses
+        if (promoted && name.startsWith('#')) continue
+        if (seenNames.has(name)) continue
+        seenNames.add(name)
+        result.push({ name, symbol: sym, promoted, via })
+      }
+      if (!includeInherited) continue
+      for (const baseName of heritageOf(current)) {
+        for (const base of resolveTypeName(index, baseName, current.path)) {
+          if (!TYPE_KINDS.has(base.kind) || seenTypes.has(base.id)) continue
+          seenTypes.add(base.id)
+          next.push({ type: base, via: [...via, base.name] })
+        }
+      }
+    }
+    level = next
+  }
+
+  return result
+}
+
+/**
+ * Full method set of a type: own methods plus those promoted from base types
+ */
+export function methodSet(index: SymbolIndex, type: IndexedSymbol): MemberEntry[] {
+  return collectMembers(index, type, METHOD_KINDS, true)
+}
+
+/**
+ * Fields and properties of a type, optionally including inherited ones
+ */
+export function fieldsOf(index: SymbolIndex, type: IndexedSymbol, includeInherited: boolean): MemberEntry[] {
+  return collectMembers(index, type, FIELD_KINDS, includeInherited)
+}
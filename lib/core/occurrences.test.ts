@@ -0,0 +1,49 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { encodeSemanticTokens, fileHighlights, semanticTokens } from './occurrences.js'
+import { makeSymbolId } from './symbol-index.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/app.ts': [
+    { name: 'User', kind: 'class', line: 1, end_line: 3, column: 14, exported: true },
+    { name: 'LIMIT', kind: 'const', line: 5, end_line: 5, column: 7 },
+    { name: 'old', kind: 'function', line: 6, end_line: 6, column: 10, doc_info: { deprecated: 'use main' } },
+    { name: 'main', kind: 'function', line: 8, end_line: 13, column: 17, exported: true },
+    { name: 'User', kind: 'reference', line: 9, end_line: 9, column: 15, call: true },
+    { name: 'LIMIT', kind: 'reference', line: 10, end_line: 10, column: 3, role: 'write' },
+    { name: 'LIMIT', kind: 'reference', line: 11, end_line: 11, column: 7 },
+    { name: 'old', kind: 'reference', line: 12, end_line: 12, column: 3, call: true },
+    { name: 'mystery', kind: 'reference', line: 12, end_line: 12, column: 9 }
+  ]
+}
+
+test('occurrences: highlights of a symbol mark writes apart from reads', () => {
+  const index = createIndex(FILES)
+  const limit = index.getSymbol(makeSymbolId('src/app.ts', 'LIMIT'))!
+  assert.deepEqual(fileHighlights(index, 'src/app.ts', [limit]).map(h => [h.line, h.column, h.end_column, h.kind]), [
+    [5, 7, 12, 'write'],
+    [10, 3, 8, 'write'],
+    [11, 7, 12, 'read']
+  ])
+  assert.deepEqual(fileHighlights(index, 'src/other.ts', [limit]), [])
+})
+
+test('occurrences: semantic tokens come from symbol kinds and reference roles', () => {
+  const index = createIndex(FILES)
+  const tokens = semanticTokens(index, 'src/app.ts')
+  assert.deepEqual(tokens.map(t => [t.line, t.column, t.length, t.type, t.modifiers]), [
+    [1, 14, 4, 'class', ['declaration']],
+    [5, 7, 5, 'variable', ['declaration', 'readonly']],
+    [6, 10, 3, 'function', ['declaration', 'deprecated']],
+    [8, 17, 4, 'function', ['declaration']],
+    [9, 15, 4, 'class', []],
+    [10, 3, 5, 'variable', ['readonly', 'modification']],
+    [11, 7, 5, 'variable', ['readonly']],
+    [12, 3, 3, 'function', ['deprecated']]
+  ])
+
+  // Relative lines and starts, length, legend index of the type, modifier bits
+  assert.deepEqual(encodeSemanticTokens(tokens.slice(0, 2)), [0, 13, 4, 2, 1, 4, 6, 5, 8, 3])
+  assert.deepEqual(encodeSemanticTokens(tokens.slice(5, 7)).slice(5), [1, 6, 5, 8, 2])
+})
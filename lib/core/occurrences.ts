@@ -0,0 +1,156 @@
+/**
+ * Occurrences Module
+ * Where names occur in one file, for editors: the highlights of a symbol
+ * (its definitions and uses in the file, each a read or a write) and the
+ * semantic tokens of every name the index resolves, typed by the stored
+ * symbol kind and modified by the reference role, so coloring matches the
+ * index without the editor parsing the file. Names the index cannot resolve
+ * get no token and keep the editor's own grammar coloring. Tokens follow the
+ * LSP legend (textDocument/semanticTokens); encodeSemanticTokens packs them
+ * the way the protocol sends them.
+ */
+
+import { shortName } from './symbol-index.js'
+import { deprecationNotice } from './deprecations.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol, SymbolReference } from '../types/index.js'
+
+export type HighlightKind = 'read' | 'write'
+
+export interface Highlight {
+  line: number
+  column: number // 1-based
+  end_column: number // exclusive
+  kind: HighlightKind // definitions and assignments are writes
+  symbol: string // ID of the symbol highlighted
+}
+
+// Standard LSP token types and modifiers, in legend order
+export const TOKEN_TYPES = [
+  'namespace', 'type', 'class', 'enum', 'interface', 'struct', 'typeParameter',
+  'parameter', 'variable', 'property', 'function', 'method'
+] as const
+export const TOKEN_MODIFIERS = ['declaration', 'readonly', 'deprecated', 'modification', 'defaultLibrary'] as const
+
+export type TokenType = typeof TOKEN_TYPES[number]
+export type TokenModifier = typeof TOKEN_MODIFIERS[number]
+
+export interface SemanticToken {
+  line: number
+  column: number // 1-based
+  length: number
+  type: TokenType
+  modifiers: TokenModifier[]
+  symbol: string // ID of the symbol the name resolves to
+}
+
+const KIND_TOKENS: Record<string, TokenType> = {
+  namespace: 'namespace',
+  package: 'namespace',
+  type: 'type',
+  class: 'class',
+  scriptable_object: 'class',
+  enum: 'enum',
+  interface: 'interface',
+  struct: 'struct',
+  type_parameter: 'typeParameter',
+  parameter: 'parameter',
+  local: 'variable',
+  variable: 'variable',
+  const: 'variable',
+  constant: 'variable',
+  property: 'property',
+  field: 'property',
+  private_field: 'property',
+  serialized_field: 'property',
+  function: 'function',
+  hook: 'function',
+  function_component: 'function',
+  method: 'method',
+  unity_lifecycle: 'method',
+  accessor: 'method'
+}
+
+const READONLY_KINDS = new Set(['const', 'constant'])
+
+function isWrite(ref: SymbolReference): boolean {
+  return ref.role === 'write' || ref.role === 'address'
+}
+
+/**
+ * Definitions and uses of some symbols inside one file, by position
+ */
+export function fileHighlights(index: SymbolIndex, filePath: string, symbols: IndexedSymbol[]): Highlight[] {
+  const highlights: Highlight[] = []
+  for (const sym of symbols) {
+    const name = shortName(sym.name)
+    if (sym.path === filePath) {
+      const column = sym.column || 1
+      highlights.push({ line: sym.line, column, end_column: column + name.length, kind: 'write', symbol: sym.id })
+    }
+    for (const ref of index.iterReferences(sym.id)) {
+      if (ref.path !== filePath) continue
+      const column = ref.column || 1
+      highlights.push({ line: ref.line, column, end_column: column + name.length, kind: isWrite(ref) ? 'write' : 'read', symbol: sym.id })
+    }
+  }
+  return highlights.sort((a, b) => a.line - b.line || a.column - b.column)
+}
+
+/**
+ * Semantic tokens of a file: every definition and reference with a column
+ * that resolves to a symbol of a kind with a token type, by position
+ */
+export function semanticTokens(index: SymbolIndex, filePath: string): SemanticToken[] {
+  const shard = index.getFile(filePath)
+  if (!shard) return []
+  const seen = new Set<string>()
+  const positions: { line: number, column: number, ref?: SymbolReference }[] = []
+  const add = (line: number, column: number | undefined, ref?: SymbolReference) => {
+    if (column === undefined || seen.has(`${line}:${column}`)) return
+    seen.add(`${line}:${column}`)
+    positions.push({ line, column, ref })
+  }
+  for (const sym of shard.symbols) add(sym.line, sym.column)
+  for (const ref of shard.references) add(ref.line, ref.column, ref)
+
+  const tokens: SemanticToken[] = []
+  index.symbolsAt(filePath, positions).forEach((at, i) => {
+    const sym = at?.symbols[0]
+    const type = sym && KIND_TOKENS[sym.kind]
+    if (!at || !sym || !type) return
+    const ref = positions[i].ref
+    const modifiers: TokenModifier[] = []
+    if (at.declaration) modifiers.push('declaration')
+    if (READONLY_KINDS.has(sym.kind)) modifiers.push('readonly')
+    if (deprecationNotice(sym) !== undefined) modifiers.push('deprecated')
+    if (!at.declaration && ref && isWrite(ref)) modifiers.push('modification')
+    if (sym.external) modifiers.push('defaultLibrary')
+    tokens.push({ line: at.line, column: at.column, length: at.end_column - at.column, type, modifiers, symbol: sym.id })
+  })
+  return tokens.sort((a, b) => a.line - b.line || a.column - b.column)
+}
+
+/**
+ * Tokens in the protocol's packed form: five integers per token (line and
+ * start relative to the previous token, length, type and modifier bits).
+ * `character` maps a 1-based column to the client's 0-based character.
+ */
+export function encodeSemanticTokens(
+  tokens: SemanticToken[],
+  character: (line: number, column: number) => number = (_line, column) => column - 1
+): number[] {
+  const data: number[] = []
+  let line = 0
+  let start = 0
+  for (const token of tokens) {
+    const tokenLine = token.line - 1
+    const tokenStart = character(token.line, token.column)
+    const length = character(token.line, token.column + token.length) - tokenStart
+    const modifiers = token.modifiers.reduce((bits, m) => bits | (1 << TOKEN_MODIFIERS.indexOf(m)), 0)
+    data.push(tokenLine - line, tokenLine === line ? tokenStart - start : tokenStart, length, TOKEN_TYPES.indexOf(token.type), modifiers)
+    line = tokenLine
+    start = tokenStart
+  }
+  return data
+}
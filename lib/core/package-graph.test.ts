@@ -0,0 +1,123 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import os from 'os'
+import path from 'path'
+import { listDependencyFiles, moduleOf, resolvePackageGraph, workspaceMemberOf } from './package-graph.js'
+import { openSymbolIndex } from './symbol-index.js'
+import type { SymbolStore } from '../utils/symbol-store.js'
+import type { FileShard } from '../types/index.js'
+
+async function writeFile(root: string, relPath: string, content: string) {
+  await fs.mkdir(path.dirname(path.join(root, relPath)), { recursive: true })
+  await fs.writeFile(path.join(root, relPath), content)
+}
+
+async function createProject(): Promise<string> {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'package-graph-'))
+  await writeFile(root, 'package.json', JSON.stringify({
+    name: 'app', version: '1.0.0', dependencies: { lib: '^1.0.0' }, devDependencies: { typed: '*' }
+  }))
+  await writeFile(root, 'src/main.ts', 'export function main() {}\n')
+  await writeFile(root, 'node_modules/lib/package.json', JSON.stringify({
+    name: 'lib', version: '1.2.0', dependencies: { util: '^2.0.0' }
+  }))
+  await writeFile(root, 'node_modules/lib/index.js', 'export function libFn() {}\n')
+  await writeFile(root, 'node_modules/lib/node_modules/util/package.json', JSON.stringify({ name: 'util', version: '2.1.0' }))
+  await writeFile(root, 'node_modules/lib/node_modules/util/index.js', 'export function utilFn() {}\n')
+  await writeFile(root, 'node_modules/typed/package.json', JSON.stringify({ name: 'typed', version: '0.3.0' }))
+  await writeFile(root, 'node_modules/typed/index.js', 'export function typedImpl() {}\n')
+  await writeFile(root, 'node_modules/typed/index.d.ts', 'export declare function typedFn(): void\n')
+  return root
+}
+
+function memoryStore(): SymbolStore {
+  let shards: FileShard[] | null = null
+  return {
+    load: async () => shards,
+    save: async (next) => { shards = next },
+    update: async () => {},
+    findSymbols: async () => [],
+    clear: async () => { shards = null }
+  }
+}
+
+test('package-graph: resolves nested node_modules the way Node does', async () => {
+  const root = await createProject()
+  try {
+    const graph = (await resolvePackageGraph(root))!
+    assert.equal(graph.root.name, 'app')
+    assert.deepEqual(graph.root.resolved, ['node_modules/lib', 'node_modules/typed'])
+    assert.deepEqual(graph.packages.map(p => `${p.name}@${p.version}`), ['lib@1.2.0', 'util@2.1.0', 'typed@0.3.0'])
+    assert.deepEqual(graph.packages[0].resolved, ['node_modules/lib/node_modules/util'])
+
+    assert.deepEqual(moduleOf(graph, 'node_modules/lib/node_modules/util/index.js'), { name: 'util', version: '2.1.0' })
+    assert.deepEqual(moduleOf(graph, 'src/main.ts'), { name: 'app', version: '1.0.0' })
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('package-graph: dependency files prefer declarations', async () => {
+  const root = await createProject()
+  try {
+    const files = await listDependencyFiles(root, (await resolvePackageGraph(root))!)
+    assert.deepEqual(files, [
+      'node_modules/lib/index.js',
+      'node_modules/lib/node_modules/util/index.js',
+      'node_modules/typed/index.d.ts'
+    ])
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('package-graph: openSymbolIndex stamps module info and indexes deps on request', async () => {
+  const root = await createProject()
+  try {
+    const { index } = await openSymbolIndex(root, memoryStore())
+    assert.equal(index.findSymbols('libFn').length, 0)
+    assert.equal(index.findSymbols('main')[0].module, 'app')
+
+    const withDeps = (await openSymbolIndex(root, memoryStore(), { deps: true })).index
+    const [utilFn] = withDeps.findSymbols('utilFn')
+    assert.equal(utilFn.module, 'util')
+    assert.equal(utilFn.module_version, '2.1.0')
+    assert.equal(withDeps.findSymbols('typedImpl').length, 0)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('package-graph: workspace members resolve to their sources', async () => {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'package-graph-ws-'))
+  try {
+    await writeFile(root, 'package.json', JSON.stringify({ name: 'monorepo', private: true, workspaces: ['packages/*'] }))
+    await writeFile(root, 'pnpm-workspace.yaml', 'packages:\n  - "tools/cli"\n  - \'!packages/legacy\'\n')
+    await writeFile(root, 'packages/api/package.json', JSON.stringify({
+      name: '@acme/api', version: '2.0.0', dependencies: { '@acme/util': 'workspace:*', lib: '^1.0.0' }
+    }))
+    await writeFile(root, 'packages/util/package.json', JSON.stringify({ name: '@acme/util', version: '1.1.0', main: 'dist/index.js' }))
+    await writeFile(root, 'packages/legacy/package.json', JSON.stringify({ name: '@acme/legacy' }))
+    await writeFile(root, 'tools/cli/package.json', JSON.stringify({ name: 'acme-cli', dependencies: { '@acme/api': '*' } }))
+    await writeFile(root, 'node_modules/lib/package.json', JSON.stringify({ name: 'lib', version: '1.2.0' }))
+
+    const graph = (await resolvePackageGraph(root))!
+    assert.deepEqual(graph.workspaces.map(m => [m.name, m.dir]), [
+      ['@acme/api', 'packages/api'],
+      ['@acme/util', 'packages/util'],
+      ['acme-cli', 'tools/cli']
+    ])
+    assert.equal(graph.workspaces[1].entry, 'dist/index.js')
+    assert.deepEqual(graph.workspaces[0].resolved, ['packages/util', 'node_modules/lib'])
+    assert.deepEqual(graph.workspaces[2].resolved, ['packages/api'])
+    assert.deepEqual(graph.packages.map(p => p.name), ['lib'])
+
+    assert.deepEqual(moduleOf(graph, 'packages/api/src/server.ts'), { name: '@acme/api', version: '2.0.0' })
+    assert.deepEqual(moduleOf(graph, 'scripts/build.ts'), { name: 'monorepo', version: '0.0.0' })
+    assert.deepEqual(workspaceMemberOf(graph, '@acme/util/format')?.subpath, 'format')
+    assert.equal(workspaceMemberOf(graph, '@acme/utility'), null)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,206 @@
+/**
+ * Package Graph Module
+ * Reads package.json, resolves installed dependencies through node_modules
+ * the way Node does, and lists dependency sources so their symbols can be
+ * indexed alongside the project's. Workspaces (package.json "workspaces",
+ * pnpm-workspace.yaml) are first-party packages: a dependency on a member
+ * resolves to its source directory, not to the node_modules link.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import fg from 'fast-glob'
+
+// Per-package cap so one huge dependency cannot swamp the index
+const MAX_DEPENDENCY_FILES = Number(process.env.MAX_DEPENDENCY_FILES) || 2000
+
+const DEPENDENCY_IGNORES = ['**/node_modules/**', '**/test/**', '**/tests/**', '**/__tests__/**', '**/*.min.js']
+
+export interface ModuleInfo {
+  name: string
+  version: string
+}
+
+export interface PackageNode extends ModuleInfo {
+  dir: string // relative to the project root, '' for the root package
+  dependencies: Record<string, string> // declared name -> version range
+  resolved: string[] // dirs of the installed dependencies
+  entry?: string // workspace members: entry file from "source", "types" or "main", relative to dir
+}
+
+export interface PackageGraph {
+  root: PackageNode
+  workspaces: PackageNode[] // workspace members, sorted by dir
+  packages: PackageNode[] // installed dependencies, sorted by dir
+}
+
+async function readManifest(absDir: string): Promise<any | null> {
+  try {
+    return JSON.parse(await fs.readFile(path.join(absDir, 'package.json'), 'utf8'))
+  } catch {
+    return null
+  }
+}
+
+/**
+ * Find the installed directory of a dependency, walking up node_modules
+ * folders from the requiring package but never above the project root
+ */
+async function findPackageDir(projectRoot: string, fromDir: string, name: string): Promise<string | null> {
+  let dir = path.join(projectRoot, fromDir)
+  while (true) {
+    const candidate = path.join(dir, 'node_modules', name)
+    try {
+      await fs.access(path.join(candidate, 'package.json'))
+      return path.relative(projectRoot, candidate).split(path.sep).join('/')
+    } catch {}
+    if (path.relative(projectRoot, dir) === '') return null
+    dir = path.dirname(dir)
+  }
+}
+
+/**
+ * Workspace member patterns: package.json "workspaces" (array, or yarn's
+ * { packages }) and the "packages:" list of pnpm-workspace.yaml
+ */
+async function workspacePatterns(projectRoot: string, manifest: any): Promise<string[]> {
+  const declared = Array.isArray(manifest.workspaces) ? manifest.workspaces : manifest.workspaces?.packages
+  const patterns: string[] = Array.isArray(declared) ? declared.filter((p: unknown) => typeof p === 'string') : []
+  try {
+    const yaml = await fs.readFile(path.join(projectRoot, 'pnpm-workspace.yaml'), 'utf8')
+    let inPackages = false
+    for (const line of yaml.split(/\r?\n/)) {
+      if (/^\S/.test(line)) inPackages = /^packages\s*:/.test(line)
+      const item = inPackages && line.match(/^\s+-\s*['"]?([^'"#]+?)['"]?\s*(#.*)?$/)
+      if (item) patterns.push(item[1])
+    }
+  } catch {}
+  return patterns
+}
+
+/**
+ * Workspace members of a project, sorted by dir
+ */
+async function resolveWorkspaces(projectRoot: string, manifest: any): Promise<PackageNode[]> {
+  const patterns = await workspacePatterns(projectRoot, manifest)
+  const include = patterns.filter(p => !p.startsWith('!')).map(p => p.replace(/^\.\//, '').replace(/\/+$/, ''))
+  const exclude = patterns.filter(p => p.startsWith('!')).map(p => p.slice(1).replace(/^\.\//, '').replace(/\/+$/, ''))
+  if (include.length === 0) return []
+
+  const dirs = await fg(include, {
+    cwd: projectRoot,
+    onlyDirectories: true,
+    followSymbolicLinks: false,
+    ignore: ['**/node_modules/**', ...exclude]
+  })
+  const members: PackageNode[] = []
+  for (const dir of dirs.sort()) {
+    const member = await readManifest(path.join(projectRoot, dir))
+    if (!member) continue
+    members.push({
+      name: member.name || path.basename(dir),
+      version: member.version || '0.0.0',
+      dir,
+      dependencies: { ...member.devDependencies, ...member.optionalDependencies, ...member.dependencies },
+      resolved: [],
+      ...(typeof (member.source || member.types || member.main) === 'string'
+        ? { entry: path.posix.normalize(member.source || member.types || member.main) }
+        : {})
+    })
+  }
+  return members
+}
+
+/**
+ * Resolve the installed dependency graph of a project
+ * @returns The graph, or null if the project has no package.json
+ */
+export async function resolvePackageGraph(projectRoot: string): Promise<PackageGraph | null> {
+  const manifest = await readManifest(projectRoot)
+  if (!manifest) return null
+
+  const root: PackageNode = {
+    name: manifest.name || path.basename(projectRoot),
+    version: manifest.version || '0.0.0',
+    dir: '',
+    // Dev dependencies are only installed for the root package and workspace members
+    dependencies: { ...manifest.devDependencies, ...manifest.optionalDependencies, ...manifest.dependencies },
+    resolved: []
+  }
+  const workspaces = await resolveWorkspaces(projectRoot, manifest)
+  const memberByName = new Map(workspaces.map(m => [m.name, m]))
+
+  const byDir = new Map<string, PackageNode>()
+  const queue: PackageNode[] = [root, ...workspaces]
+  while (queue.length > 0) {
+    const node = queue.shift()!
+    for (const name of Object.keys(node.dependencies).sort()) {
+      // "workspace:*" and plain ranges alike resolve to the member's sources
+      const member = memberByName.get(name)
+      if (member) {
+        if (member !== node) node.resolved.push(member.dir)
+        continue
+      }
+      const dir = await findPackageDir(projectRoot, node.dir, name)
+      if (!dir) continue
+      node.resolved.push(dir)
+      if (byDir.has(dir)) continue
+
+      const dep = await readManifest(path.join(projectRoot, dir)) || {}
+      const child: PackageNode = {
+        name: dep.name || name,
+        version: dep.version || '0.0.0',
+        dir,
+        dependencies: { ...dep.peerDependencies, ...dep.optionalDependencies, ...dep.dependencies },
+        resolved: []
+      }
+      byDir.set(dir, child)
+      queue.push(child)
+    }
+  }
+
+  const packages = Array.from(byDir.values()).sort((a, b) => (a.dir < b.dir ? -1 : a.dir > b.dir ? 1 : 0))
+  return { root, workspaces, packages }
+}
+
+/**
+ * Workspace member a bare import specifier names ("@acme/api/client" ->
+ * @acme/api), with the subpath after the package name
+ */
+export function workspaceMemberOf(graph: PackageGraph, specifier: string): { member: PackageNode, subpath: string } | null {
+  for (const member of graph.workspaces) {
+    if (specifier === member.name) return { member, subpath: '' }
+    if (specifier.startsWith(`${member.name}/`)) return { member, subpath: specifier.slice(member.name.length + 1) }
+  }
+  return null
+}
+
+/**
+ * Package that owns a project-relative path (deepest workspace member or
+ * node_modules match)
+ */
+export function moduleOf(graph: PackageGraph, filePath: string): ModuleInfo {
+  let owner: PackageNode = graph.root
+  for (const pkg of [...graph.workspaces, ...graph.packages]) {
+    if (filePath.startsWith(`${pkg.dir}/`) && pkg.dir.length > owner.dir.length) owner = pkg
+  }
+  return { name: owner.name, version: owner.version }
+}
+
+/**
+ * Source files of installed dependencies, relative to the project root.
+ * Declaration files are preferred; packages without any fall back to JS.
+ */
+export async function listDependencyFiles(projectRoot: string, graph: PackageGraph): Promise<string[]> {
+  const files: string[] = []
+  for (const pkg of graph.packages) {
+    const cwd = path.join(projectRoot, pkg.dir)
+    const options = { cwd, onlyFiles: true, followSymbolicLinks: false, ignore: DEPENDENCY_IGNORES }
+    let entries = await fg(['**/*.d.ts', '**/*.d.mts', '**/*.d.cts'], options)
+    if (entries.length === 0) entries = await fg(['**/*.{js,mjs,cjs,jsx,ts,tsx}'], options)
+    for (const entry of entries.sort().slice(0, MAX_DEPENDENCY_FILES)) {
+      files.push(`${pkg.dir}/${entry}`)
+    }
+  }
+  return files
+}
@@ -0,0 +1,44 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { computePackageStats, packageStatsCsv } from './package-stats.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+// lib/store: Save (tested), Load, Row; lib/api: Handle calls Save; lib/api/test_api.py tests Save
+const FILES: FixtureFiles = {
+  'lib/store/store.py': [
+    { name: 'Save', kind: 'function', line: 1, end_line: 4, exported: true },
+    { name: 'Load', kind: 'function', line: 6, end_line: 7, exported: true },
+    { name: 'Row', kind: 'class', line: 9, end_line: 11, exported: true },
+    { name: 'helper', kind: 'function', line: 13, end_line: 15 }
+  ],
+  'lib/api/api.py': [
+    { name: 'Handle', kind: 'function', line: 1, end_line: 3, exported: true },
+    { name: 'Save', kind: 'reference', line: 2, end_line: 2, column: 5, call: true }
+  ],
+  'lib/api/test_api.py': [
+    { name: 'test_handle', kind: 'function', line: 1, end_line: 3, exported: true },
+    { name: 'Save', kind: 'reference', line: 2, end_line: 2, column: 5, call: true }
+  ]
+}
+
+test('package-stats: kinds, exports, function length, fan-in/out and tested exports', () => {
+  const [api, store] = computePackageStats(createIndex(FILES))
+
+  assert.deepEqual(api, {
+    package: 'lib/api', files: 1, test_files: 1, symbols: 1, kinds: { function: 1 }, exported: 1, exported_ratio: 1,
+    functions: 1, avg_function_lines: 3, fan_in: 0, fan_out: 1, tested_exports: 0, export_coverage: 0
+  })
+  assert.deepEqual(store, {
+    package: 'lib/store', files: 1, test_files: 0, symbols: 4, kinds: { class: 1, function: 3 }, exported: 3, exported_ratio: 0.75,
+    functions: 3, avg_function_lines: 3, fan_in: 1, fan_out: 0, tested_exports: 1, export_coverage: 0.333
+  })
+})
+
+test('package-stats: package filter and CSV', () => {
+  const stats = computePackageStats(createIndex(FILES), { packages: ['./lib/store'] })
+  assert.deepEqual(stats.map(s => [s.package, s.fan_in]), [['lib/store', 1]])
+
+  const [header, row] = packageStatsCsv(stats).trimEnd().split('\n')
+  assert.equal(header, 'package,files,test_files,symbols,exported,exported_ratio,functions,avg_function_lines,fan_in,fan_out,tested_exports,export_coverage,kind_class,kind_function')
+  assert.equal(row, 'lib/store,1,0,4,3,0.75,3,3,1,0,1,0.333,1,3')
+})
@@ -0,0 +1,143 @@
+/**
+ * Package Stats Module
+ * Per-package metrics for dashboards (indexer stats, GET /stats): symbols
+ * by kind, the share that is exported, the average length of functions and
+ * methods, fan-in and fan-out (the other packages that use this one and
+ * that this one uses, through references) and how many exports a test file
+ * references. Packages are source directories; test files count only as
+ * test_files and as the references that cover exports, so a package's
+ * tests do not widen its fan-in. References resolve as in references().
+ */
+
+import { isLocalSymbol } from './local-scopes.js'
+import { packageOf } from './import-graph.js'
+import { matchesPackageDir } from './symbol-query.js'
+import { CALLABLE_KINDS } from './call-graph.js'
+import { isTestFile } from './test-map.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export interface PackageStats {
+  package: string
+  files: number // source files, tests excluded
+  test_files: number
+  symbols: number
+  kinds: Record<string, number> // symbols by kind
+  exported: number
+  exported_ratio: number // exported / symbols
+  functions: number // functions and methods
+  avg_function_lines: number
+  fan_in: number // other packages referencing a symbol of this one
+  fan_out: number // other packages this one references a symbol of
+  tested_exports: number // exported symbols a test file references
+  export_coverage: number // tested_exports / exported
+}
+
+export interface PackageStatsOptions {
+  /** Package patterns (./lib/...) to report; every package by default */
+  packages?: string[]
+}
+
+function ratio(part: number, whole: number, digits = 3): number {
+  if (whole === 0) return 0
+  const scale = 10 ** digits
+  return Math.round(part / whole * scale) / scale
+}
+
+function counted(sym: IndexedSymbol): boolean {
+  return !isLocalSymbol(sym) && sym.kind !== 'package'
+}
+
+/**
+ * Metrics of every package with indexed files, sorted by package
+ */
+export function computePackageStats(index: SymbolIndex, options: PackageStatsOptions = {}): PackageStats[] {
+  const wanted = (pkg: string) => !options.packages?.length || options.packages.some(p => matchesPackageDir(pkg, p))
+  const stats = new Map<string, PackageStats>()
+  const lines = new Map<string, number>()
+  const fanIn = new Map<string, Set<string>>()
+  const fanOut = new Map<string, Set<string>>()
+  const link = (edges: Map<string, Set<string>>, from: string, to: string) => {
+    const set = edges.get(from)
+    if (set) set.add(to)
+    else edges.set(from, new Set([to]))
+  }
+  const statsOf = (pkg: string) => {
+    let entry = stats.get(pkg)
+    if (!entry) {
+      entry = {
+        package: pkg, files: 0, test_files: 0, symbols: 0, kinds: {}, exported: 0, exported_ratio: 0,
+        functions: 0, avg_function_lines: 0, fan_in: 0, fan_out: 0, tested_exports: 0, export_coverage: 0
+      }
+      stats.set(pkg, entry)
+    }
+    return entry
+  }
+
+  for (const shard of index.listShards()) {
+    const pkg = packageOf(shard.path)
+    if (isTestFile(shard.path)) {
+      if (wanted(pkg)) statsOf(pkg).test_files++
+      continue
+    }
+    // Fan-out needs the references of every package, reported or not
+    const entry = wanted(pkg) ? statsOf(pkg) : null
+    if (entry) entry.files++
+    for (const sym of shard.symbols) {
+      if (!counted(sym)) continue
+      let tested = false
+      for (const ref of index.iterReferences(sym.id)) {
+        if (isTestFile(ref.path)) {
+          tested = true
+          continue
+        }
+        const from = packageOf(ref.path)
+        if (from === pkg) continue
+        link(fanIn, pkg, from)
+        link(fanOut, from, pkg)
+      }
+      if (!entry) continue
+      entry.symbols++
+      entry.kinds[sym.kind] = (entry.kinds[sym.kind] || 0) + 1
+      if (sym.exported) {
+        entry.exported++
+        if (tested) entry.tested_exports++
+      }
+      if (CALLABLE_KINDS.has(sym.kind)) {
+        entry.functions++
+        lines.set(pkg, (lines.get(pkg) || 0) + sym.end_line - sym.line + 1)
+      }
+    }
+  }
+
+  for (const entry of stats.values()) {
+    entry.exported_ratio = ratio(entry.exported, entry.symbols)
+    entry.avg_function_lines = ratio(lines.get(entry.package) || 0, entry.functions, 1)
+    entry.fan_in = fanIn.get(entry.package)?.size || 0
+    entry.fan_out = fanOut.get(entry.package)?.size || 0
+    entry.export_coverage = ratio(entry.tested_exports, entry.exported)
+  }
+  return [...stats.values()].sort((a, b) => a.package.localeCompare(b.package))
+}
+
+function csvField(value: string | number): string {
+  const text = String(value)
+  return /[",\n\r]/.test(text) ? `"${text.replace(/"/g, '""')}"` : text
+}
+
+/**
+ * Package stats as CSV: one row per package, a kind_<kind> column for each
+ * kind any package has
+ */
+export function packageStatsCsv(stats: PackageStats[]): string {
+  const kinds = [...new Set(stats.flatMap(s => Object.keys(s.kinds)))].sort()
+  const columns: (keyof PackageStats)[] = [
+    'package', 'files', 'test_files', 'symbols', 'exported', 'exported_ratio', 'functions',
+    'avg_function_lines', 'fan_in', 'fan_out', 'tested_exports', 'export_coverage'
+  ]
+  const rows = [[...columns, ...kinds.map(k => `kind_${k}`)]]
+  for (const s of stats) {
+    rows.push([...columns.map(c => s[c] as string | number), ...kinds.map(k => s.kinds[k] || 0)].map(String))
+  }
+  return rows.map(row => row.map(csvField).join(',')).join('\n') + '\n'
+}
@@ -0,0 +1,73 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex, indexContent, indexFileContents } from './symbol-index.js'
+import { findParseDiagnostics } from './parse-diagnostics.js'
+import { registerLanguageBackend } from '../utils/language-backends.js'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { shardRecords } from '../exporters/jsonl.js'
+import { indexDiagnostics } from '../exporters/records.js'
+import { buildLsif } from '../exporters/lsif.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/api/user.ts': [
+    { name: 'User', kind: 'class', line: 1, end_line: 5, exported: true },
+    { name: "'}' expected", kind: 'diagnostic', line: 7, column: 3 },
+    { name: 'Unexpected token', kind: 'diagnostic', line: 4, column: 10 }
+  ],
+  'src/app.ts': [{ name: 'main', kind: 'function', line: 1, end_line: 2 }],
+  'tools/gen.py': [{ name: 'Syntax error: missing ")"', kind: 'diagnostic', line: 2, column: 8 }]
+}
+
+test('parse-diagnostics: stored with the shard next to what parsed', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.getFile('src/api/user.ts')!.diagnostics, [
+    { message: "'}' expected", line: 7, column: 3 },
+    { message: 'Unexpected token', line: 4, column: 10 }
+  ])
+  assert.deepEqual(index.fileSymbols('src/api/user.ts').map(s => s.name), ['User'])
+  assert.equal(index.getFile('src/app.ts')!.diagnostics, undefined)
+
+  assert.deepEqual(findParseDiagnostics(index).map(d => `${d.path}:${d.line}`), ['src/api/user.ts:4', 'src/api/user.ts:7', 'tools/gen.py:2'])
+  assert.deepEqual(findParseDiagnostics(index, { packages: ['./tools/...'] }).map(d => d.message), ['Syntax error: missing ")"'])
+  assert.deepEqual(findParseDiagnostics(index, { lang: 'python' }).map(d => d.path), ['tools/gen.py'])
+})
+
+test('parse-diagnostics: included in exports', () => {
+  const index = createIndex(FILES)
+  const syntax = indexDiagnostics(index).filter(d => d.code === 'syntax')
+  assert.deepEqual(syntax.map(d => [d.path, d.line, d.column, d.severity]), [
+    ['src/api/user.ts', 4, 10, 1],
+    ['src/api/user.ts', 7, 3, 1],
+    ['tools/gen.py', 2, 8, 1]
+  ])
+  const lines = shardRecords(index.getFile('tools/gen.py')!).map(line => JSON.parse(line))
+  assert.deepEqual(lines.at(-1), { type: 'diagnostic', path: 'tools/gen.py', message: 'Syntax error: missing ")"', line: 2, column: 8 })
+  const results = buildLsif(index, { projectRoot: '/repo' }).filter(e => e.label === 'diagnosticResult')
+  assert.deepEqual(results.map(r => r.result.map((d: any) => d.range.start)), [[{ line: 6, character: 2 }, { line: 3, character: 9 }], [{ line: 1, character: 7 }]])
+})
+
+test('parse-diagnostics: a parser that throws leaves a diagnostic instead of stopping the run', async () => {
+  registerLanguageBackend({
+    name: 'broken-lua',
+    languages: ['lua'],
+    extractSymbols: async () => { throw new Error('grammar not loaded') },
+    extractImports: async () => [],
+    isCodeAtPosition: async () => true
+  })
+  const index = new SymbolIndex()
+  await indexFileContents(index, ['init.lua', 'src/ok.ts'], ['setup()\n', 'export const ok = 1\n'])
+  assert.deepEqual(index.listFiles(), ['init.lua', 'src/ok.ts'])
+  assert.deepEqual(index.getFile('init.lua')!.diagnostics, [{ message: 'Parser failed: grammar not loaded', line: 1, column: 1 }])
+  const shard = await indexContent(index, 'plugin.lua', 'x\n')
+  assert.equal(shard.diagnostics!.length, 1)
+})
+
+test('parse-diagnostics: babel keeps the declarations around a recoverable error', () => {
+  const extracted = extractJSSymbols('export function ok() {}\nlet a = 1\nlet a = 2\n')
+  assert.ok(extracted.some(s => s.kind === 'function' && s.name === 'ok'))
+  const diagnostics = extracted.filter(s => s.kind === 'diagnostic')
+  assert.equal(diagnostics.length, 1)
+  assert.match(diagnostics[0].name!, /already been declared/)
+  assert.equal(diagnostics[0].line, 3)
+})
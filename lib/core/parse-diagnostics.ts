@@ -0,0 +1,55 @@
+/**
+ * Parse Diagnostics Module
+ * Syntax errors found while files are indexed. Extractors report them as
+ * entries of kind "diagnostic" and keep whatever parsed around them (babel
+ * with error recovery, tree-sitter's ERROR and MISSING nodes), and a parser
+ * that fails outright leaves one diagnostic on an empty file, so a broken
+ * file neither stops the run nor hides the rest of its package. They are
+ * stored with the file's shard until it parses cleanly. Analyzers (see
+ * analyzers) add theirs, lint findings with a rule and severity, to the same
+ * list; a syntax error has no analyzer.
+ */
+
+import { matchesPackage } from './symbol-query.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { ParseDiagnostic } from '../types/index.js'
+
+export interface DiagnosticEntry extends ParseDiagnostic {
+  path: string
+  lang: string
+}
+
+export interface DiagnosticQuery {
+  packages?: string[] // package patterns (./lib/...)
+  lang?: string
+  analyzers?: string[] // reporting analyzers, "syntax" for syntax errors
+  rules?: string[] // analyzer rules (no-unused-vars)
+}
+
+/**
+ * Where a diagnostic comes from: its analyzer, or "syntax"
+ */
+export function diagnosticSource(diagnostic: ParseDiagnostic): string {
+  return diagnostic.analyzer || 'syntax'
+}
+
+/**
+ * Diagnostics of the indexed files, by path and line
+ */
+export function findParseDiagnostics(index: SymbolIndex, query: DiagnosticQuery = {}): DiagnosticEntry[] {
+  const packages = query.packages && query.packages.length > 0 ? query.packages : null
+  const analyzers = query.analyzers && query.analyzers.length > 0 ? new Set(query.analyzers) : null
+  const rules = query.rules && query.rules.length > 0 ? new Set(query.rules) : null
+  const entries: DiagnosticEntry[] = []
+  for (const shard of index.listShards()) {
+    if (!shard.diagnostics || shard.diagnostics.length === 0) continue
+    if (packages && !packages.some(p => matchesPackage(shard.path, p))) continue
+    if (query.lang && shard.lang !== query.lang) continue
+    for (const diagnostic of shard.diagnostics) {
+      if (analyzers && !analyzers.has(diagnosticSource(diagnostic))) continue
+      if (rules && !(diagnostic.rule && rules.has(diagnostic.rule))) continue
+      entries.push({ ...diagnostic, path: shard.path, lang: shard.lang })
+    }
+  }
+  return entries.sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : a.line - b.line || a.column - b.column))
+}
@@ -0,0 +1,32 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { mapConcurrent, parseFiles } from './parse-pool.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+
+test('parse-pool: mapConcurrent keeps input order and bounds concurrency', async () => {
+  let inFlight = 0
+  let peak = 0
+  const items = Array.from({ length: 20 }, (_, i) => i)
+  const results = await mapConcurrent(items, 3, async (n) => {
+    inFlight++
+    peak = Math.max(peak, inFlight)
+    await new Promise(resolve => setTimeout(resolve, (20 - n) % 4))
+    inFlight--
+    return n * 2
+  })
+  assert.deepEqual(results, items.map(n => n * 2))
+  assert.equal(peak, 3)
+})
+
+test('parse-pool: worker results match in-process parsing, in input order', async () => {
+  await initTreeSitter()
+  const jobs = Array.from({ length: 96 }, (_, i) => i % 2 === 0
+    ? { relPath: `src/m${i}.ts`, content: `export class C${i} { run() {} }\nexport function f${i}() { return new C${i}() }\n` }
+    : { relPath: `py/m${i}.py`, content: `class P${i}:\n    def go(self):\n        pass\n` }
+  )
+  const sequential = await parseFiles(jobs, 1)
+  const parallel = await parseFiles(jobs, 4)
+  assert.equal(parallel.length, jobs.length)
+  assert.deepEqual(parallel, sequential)
+  assert.ok(parallel[95].some(s => s.name === 'P95'))
+})
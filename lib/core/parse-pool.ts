@@ -0,0 +1,181 @@
+/**
+ * Parse Pool Module
+ * Parses files on a pool of worker threads sized to the machine's available
+ * parallelism. Results come back in input order, so the index built from
+ * them is the same whatever order the workers finish in.
+ */
+
+import os from 'os'
+import { Worker } from 'worker_threads'
+import { extractSymbols } from '../tools/common/utils.js'
+import { nowNs } from '../utils/tracing.js'
+import type { ExtractOptions, SymbolInfo } from '../types/index.js'
+
+// Below this many files, worker startup costs more than it saves
+const MIN_PARALLEL_FILES = Number(process.env.INDEXER_MIN_PARALLEL_FILES) || 64
+// Each worker should get at least this many files
+const FILES_PER_WORKER = 16
+
+const WORKER_URL = new URL('./parse-worker.js', import.meta.url)
+
+export interface ParseJob {
+  relPath: string
+  content: string
+  options?: ExtractOptions
+}
+
+/** Told when a file started and finished parsing, in Unix epoch nanoseconds */
+export type ParseTimer = (job: number, startNs: bigint, endNs: bigint) => void
+
+export type ParseResult = { symbols: Partial<SymbolInfo>[], error?: undefined } | { symbols?: undefined, error: string }
+
+/**
+ * Number of files to parse at once: INDEXER_CONCURRENCY, else the number of
+ * CPUs available to the process
+ */
+export function indexConcurrency(): number {
+  const configured = Number(process.env.INDEXER_CONCURRENCY)
+  if (Number.isInteger(configured) && configured > 0) return configured
+  return typeof os.availableParallelism === 'function' ? os.availableParallelism() : os.cpus().length
+}
+
+/**
+ * Map over items with at most `limit` calls in flight, keeping input order.
+ * Once the signal aborts no further calls start, and the map rejects with
+ * its reason after the ones in flight settle.
+ */
+export async function mapConcurrent<T, R>(
+  items: T[],
+  limit: number,
+  fn: (item: T, i: number) => Promise<R>,
+  signal?: AbortSignal
+): Promise<R[]> {
+  const results = new Array<R>(items.length)
+  let next = 0
+  const run = async () => {
+    while (next < items.length && !signal?.aborted) {
+      const i = next++
+      results[i] = await fn(items[i], i)
+    }
+  }
+  await Promise.all(Array.from({ length: Math.max(1, Math.min(limit, items.length)) }, run))
+  signal?.throwIfAborted()
+  return results
+}
+
+/**
+ * Extract a file's symbols in-process. An extractor that throws leaves the
+ * file with a diagnostic instead of failing the whole run.
+ */
+export async function parseFile(job: ParseJob): Promise<Partial<SymbolInfo>[]> {
+  try {
+    return await extractSymbols(job.relPath, job.content, job.options)
+  } catch (err) {
+    return [{ name: `Parser failed: ${err instanceof Error ? err.message : String(err)}`, kind: 'diagnostic', line: 1, column: 1 }]
+  }
+}
+
+/**
+ * Parse files in-process, one after another
+ */
+async function parseSequential(jobs: ParseJob[], onParsed?: ParseTimer, signal?: AbortSignal): Promise<Partial<SymbolInfo>[][]> {
+  const results: Partial<SymbolInfo>[][] = []
+  for (const job of jobs) {
+    signal?.throwIfAborted()
+    const start = onParsed ? nowNs() : 0n
+    results.push(await parseFile(job))
+    onParsed?.(results.length - 1, start, nowNs())
+  }
+  return results
+}
+
+/**
+ * Parse files on worker threads. Jobs are handed out one at a time so a slow
+ * file does not hold up a whole batch. If workers cannot be started or die,
+ * their outstanding jobs are parsed in-process instead. An abort terminates
+ * the workers without waiting for the files they are on.
+ */
+async function parseOnWorkers(
+  jobs: ParseJob[],
+  workerCount: number,
+  onParsed?: ParseTimer,
+  signal?: AbortSignal
+): Promise<Partial<SymbolInfo>[][]> {
+  const results = new Array<Partial<SymbolInfo>[] | undefined>(jobs.length)
+  const started = onParsed ? new Array<bigint>(jobs.length) : null
+  const fallback: number[] = []
+  let next = 0
+
+  const runWorker = () => new Promise<void>((resolve) => {
+    let worker: Worker
+    try {
+      worker = new Worker(WORKER_URL)
+    } catch {
+      resolve()
+      return
+    }
+    let current = -1
+    const stop = () => { void worker.terminate() }
+    signal?.addEventListener('abort', stop, { once: true })
+    const dispatch = () => {
+      if (next >= jobs.length || signal?.aborted) {
+        current = -1
+        void worker.terminate()
+        return
+      }
+      current = next++
+      if (started) started[current] = nowNs()
+      worker.postMessage({ id: current, ...jobs[current] })
+    }
+    worker.on('message', (result: ParseResult & { id: number }) => {
+      if (result.error !== undefined) fallback.push(result.id)
+      else {
+        results[result.id] = result.symbols
+        onParsed?.(result.id, started![result.id], nowNs())
+      }
+      dispatch()
+    })
+    // A crash is followed by 'exit'; its in-flight job is retried in-process
+    worker.on('error', () => {})
+    worker.on('exit', () => {
+      signal?.removeEventListener('abort', stop)
+      if (current >= 0) fallback.push(current)
+      current = -1
+      resolve()
+    })
+    dispatch()
+  })
+
+  await Promise.all(Array.from({ length: workerCount }, runWorker))
+  signal?.throwIfAborted()
+
+  // Jobs that never reached a live worker, plus failed ones
+  for (let i = next; i < jobs.length; i++) fallback.push(i)
+  for (const i of fallback.sort((a, b) => a - b)) {
+    signal?.throwIfAborted()
+    const start = onParsed ? nowNs() : 0n
+    results[i] = await parseFile(jobs[i])
+    onParsed?.(i, start, nowNs())
+  }
+  return results as Partial<SymbolInfo>[][]
+}
+
+/**
+ * Extract symbols from many files, in parallel when it pays off
+ * @param jobs - Files to parse
+ * @param concurrency - Maximum number of workers (defaults to indexConcurrency)
+ * @param onParsed - Told how long each file took, for tracing
+ * @param signal - Stops parsing; the parse rejects with its reason
+ * @returns Extracted symbols per job, in input order
+ */
+export async function parseFiles(
+  jobs: ParseJob[],
+  concurrency = indexConcurrency(),
+  onParsed?: ParseTimer,
+  signal?: AbortSignal
+): Promise<Partial<SymbolInfo>[][]> {
+  signal?.throwIfAborted()
+  const workerCount = Math.min(concurrency, Math.ceil(jobs.length / FILES_PER_WORKER))
+  if (workerCount <= 1 || jobs.length < MIN_PARALLEL_FILES) return parseSequential(jobs, onParsed, signal)
+  return parseOnWorkers(jobs, workerCount, onParsed, signal)
+}
@@ -0,0 +1,23 @@
+/**
+ * Parse Worker Module
+ * Worker-thread entry point for the parse pool: runs the language extractors
+ * on file contents posted by the main thread.
+ */
+
+import { parentPort } from 'worker_threads'
+import { extractSymbols } from '../tools/common/utils.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import type { ParseJob, ParseResult } from './parse-pool.js'
+
+const ready = initTreeSitter()
+
+parentPort?.on('message', async (job: ParseJob & { id: number }) => {
+  let result: ParseResult & { id: number }
+  try {
+    await ready
+    result = { id: job.id, symbols: await extractSymbols(job.relPath, job.content, job.options) }
+  } catch (err) {
+    result = { id: job.id, error: err instanceof Error ? err.message : String(err) }
+  }
+  parentPort!.postMessage(result)
+})
@@ -0,0 +1,53 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { PositionConverter, parsePositionEncoding, wideCharRuns } from './positions.js'
+
+// "é" is 2 UTF-8 bytes, "界" 3, and "😀" 4 bytes and two UTF-16 code units
+const TEXT = 'const café = 1\nconst 世界 = "😀😀"; let x\nplain()\n'
+
+test('positions: columns convert between UTF-8, UTF-16 and UTF-32', () => {
+  const converter = PositionConverter.fromText(TEXT)
+  // "=" after café
+  assert.equal(converter.convert(1, 12, 'utf-16', 'utf-8'), 13)
+  assert.equal(converter.convert(1, 13, 'utf-8', 'utf-16'), 12)
+  assert.equal(converter.convert(1, 12, 'utf-16', 'utf-32'), 12)
+  // "x" after two emoji
+  assert.equal(converter.convert(2, 24, 'utf-16', 'utf-8'), 32)
+  assert.equal(converter.convert(2, 24, 'utf-16', 'utf-32'), 22)
+  assert.equal(converter.convert(2, 22, 'utf-32', 'utf-16'), 24)
+  assert.equal(converter.convert(2, 32, 'utf-8', 'utf-32'), 22)
+  // Inside a character: the start of that character
+  assert.equal(converter.convert(2, 8, 'utf-8', 'utf-16'), 7)
+  assert.equal(converter.convert(2, 14, 'utf-16', 'utf-8'), 17)
+  // ASCII lines are the same in every encoding
+  assert.equal(converter.isWide(3), false)
+  assert.equal(converter.convert(3, 6, 'utf-16', 'utf-8'), 6)
+  assert.equal(parsePositionEncoding('UTF-8'), 'utf-8')
+  assert.throws(() => parsePositionEncoding('latin1'), /Unknown position encoding/)
+})
+
+test('positions: byte offsets need the text', () => {
+  const converter = PositionConverter.fromText(TEXT)
+  const offset = converter.byteOffset(2, 24)
+  assert.equal(Buffer.from(TEXT).subarray(offset, offset + 1).toString(), 'x')
+  assert.deepEqual(converter.positionAt(offset), { line: 2, column: 24 })
+  assert.deepEqual(converter.positionAt(0), { line: 1, column: 1 })
+  assert.throws(() => new PositionConverter(wideCharRuns(TEXT)).byteOffset(1, 1), /fromText/)
+})
+
+test('positions: shards keep the runs, so an index converts without the file', () => {
+  assert.deepEqual(wideCharRuns('plain()\n'), [])
+  assert.deepEqual(wideCharRuns(TEXT), [
+    { line: 1, column: 10, count: 1, bytes: 2 },
+    { line: 2, column: 7, count: 2, bytes: 3 },
+    { line: 2, column: 13, count: 2, bytes: 4 }
+  ])
+  const index = new SymbolIndex()
+  const shard = index.addFile('src/a.ts', 'typescript', [], undefined, wideCharRuns(TEXT))
+  assert.equal(shard.wide_chars?.length, 3)
+  assert.equal(index.addFile('src/b.ts', 'typescript', []).wide_chars, undefined)
+  assert.equal(index.positions('src/a.ts')?.convert(2, 24, 'utf-16', 'utf-8'), 32)
+  assert.equal(index.snapshot().positions('src/a.ts')?.convert(1, 12, 'utf-16', 'utf-8'), 13)
+  assert.equal(index.positions('src/missing.ts'), null)
+})
@@ -0,0 +1,176 @@
+/**
+ * Positions Module
+ * Columns in the index count UTF-16 code units from the start of the line,
+ * 1-based, as JavaScript strings, Babel, tree-sitter's web binding and LSP
+ * (by default) do. Other tools count UTF-8 bytes (compilers, ctags, Go) or
+ * code points ("runes", LSP's utf-32). Shards keep the runs of non-ASCII
+ * characters of their file (wide_chars); that is all a column conversion
+ * needs, so a PositionConverter built from a shard converts positions
+ * without reading the file. On ASCII lines every encoding has the same
+ * columns. Byte offsets from the start of the file also need each line's
+ * length, so only a converter built from the text has them.
+ */
+
+import type { FileShard, WideCharRun } from '../types/index.js'
+
+export type PositionEncoding = 'utf-8' | 'utf-16' | 'utf-32'
+
+export const POSITION_ENCODINGS: PositionEncoding[] = ['utf-8', 'utf-16', 'utf-32']
+
+/**
+ * Parse an encoding name (utf-8, utf-16, utf-32)
+ */
+export function parsePositionEncoding(value: string): PositionEncoding {
+  const name = value.toLowerCase()
+  if (!POSITION_ENCODINGS.includes(name as PositionEncoding)) {
+    throw new Error(`Unknown position encoding "${value}". Use ${POSITION_ENCODINGS.join(', ')}`)
+  }
+  return name as PositionEncoding
+}
+
+// UTF-8 bytes of the character starting at a UTF-16 index; a lone surrogate is written as U+FFFD
+function utf8Width(text: string, i: number): number {
+  const code = text.charCodeAt(i)
+  if (code < 0x80) return 1
+  if (code < 0x800) return 2
+  if (code >= 0xd800 && code <= 0xdbff) {
+    const next = text.charCodeAt(i + 1)
+    if (next >= 0xdc00 && next <= 0xdfff) return 4
+  }
+  return 3
+}
+
+// Columns a character of a run takes in an encoding
+function width(run: WideCharRun, encoding: PositionEncoding): number {
+  if (encoding === 'utf-8') return run.bytes
+  if (encoding === 'utf-16') return run.bytes === 4 ? 2 : 1
+  return 1
+}
+
+/**
+ * Runs of non-ASCII characters of a file, by line; empty for an ASCII file
+ */
+export function wideCharRuns(text: string): WideCharRun[] {
+  if (!/[^\x00-\x7f]/.test(text)) return []
+  const runs: WideCharRun[] = []
+  let line = 1
+  let lineStart = 0
+  for (let i = 0; i < text.length; i++) {
+    const code = text.charCodeAt(i)
+    if (code === 10) {
+      line++
+      lineStart = i + 1
+      continue
+    }
+    if (code < 0x80) continue
+    const bytes = utf8Width(text, i)
+    const column = i - lineStart + 1
+    const last = runs[runs.length - 1]
+    if (last && last.line === line && last.bytes === bytes && last.column + last.count * width(last, 'utf-16') === column) {
+      last.count++
+    } else {
+      runs.push({ line, column, count: 1, bytes })
+    }
+    if (bytes === 4) i++
+  }
+  return runs
+}
+
+/**
+ * Converts the positions of one file between UTF-8, UTF-16 and UTF-32
+ * columns, and (when built from its text) to and from byte offsets
+ */
+export class PositionConverter {
+  private readonly byLine = new Map<number, WideCharRun[]>()
+
+  /**
+   * @param runs - The file's non-ASCII runs (FileShard.wide_chars)
+   * @param lineBytes - UTF-8 length of every line, newline included, for byte offsets
+   */
+  constructor(runs: WideCharRun[] = [], private readonly lineBytes: number[] | null = null) {
+    for (const run of runs) {
+      const ofLine = this.byLine.get(run.line)
+      if (ofLine) ofLine.push(run)
+      else this.byLine.set(run.line, [run])
+    }
+  }
+
+  static fromText(text: string): PositionConverter {
+    const lines = text.split('\n')
+    const runs = wideCharRuns(text)
+    const lineBytes = lines.map((l, i) => Buffer.byteLength(l, 'utf8') + (i < lines.length - 1 ? 1 : 0))
+    return new PositionConverter(runs, lineBytes)
+  }
+
+  static fromShard(shard: FileShard): PositionConverter {
+    return new PositionConverter(shard.wide_chars)
+  }
+
+  /**
+   * Whether a line has characters whose columns differ between encodings
+   */
+  isWide(line: number): boolean {
+    return this.byLine.has(line)
+  }
+
+  /**
+   * Column of a position in another encoding. A column inside a character
+   * (a UTF-8 continuation byte, the second half of a surrogate pair) maps to
+   * the start of that character.
+   * @param line - 1-based line
+   * @param column - 1-based column in `from`
+   */
+  convert(line: number, column: number, from: PositionEncoding, to: PositionEncoding): number {
+    const runs = this.byLine.get(line)
+    if (!runs || from === to) return column
+    const target = column - 1
+    let fromPos = 0
+    let toPos = 0
+    let units = 0
+    for (const run of runs) {
+      // ASCII between the previous run and this one
+      const ascii = run.column - 1 - units
+      if (target < fromPos + ascii) return toPos + (target - fromPos) + 1
+      fromPos += ascii
+      toPos += ascii
+      units += ascii
+      const fromWidth = width(run, from)
+      if (target < fromPos + run.count * fromWidth) {
+        return toPos + Math.floor((target - fromPos) / fromWidth) * width(run, to) + 1
+      }
+      fromPos += run.count * fromWidth
+      toPos += run.count * width(run, to)
+      units += run.count * width(run, 'utf-16')
+    }
+    return toPos + (target - fromPos) + 1
+  }
+
+  /**
+   * Byte offset from the start of the file of a position with a UTF-16 column
+   */
+  byteOffset(line: number, column: number): number {
+    const lineBytes = this.requireLines()
+    let offset = 0
+    for (let i = 0; i < line - 1 && i < lineBytes.length; i++) offset += lineBytes[i]
+    return offset + this.convert(line, column, 'utf-16', 'utf-8') - 1
+  }
+
+  /**
+   * Position (1-based line, UTF-16 column) of a byte offset from the start of the file
+   */
+  positionAt(byteOffset: number): { line: number, column: number } {
+    const lineBytes = this.requireLines()
+    let line = 1
+    let offset = byteOffset
+    while (line < lineBytes.length && offset >= lineBytes[line - 1]) {
+      offset -= lineBytes[line - 1]
+      line++
+    }
+    return { line, column: this.convert(line, offset + 1, 'utf-8', 'utf-16') }
+  }
+
+  private requireLines(): number[] {
+    if (!this.lineBytes) throw new Error('Byte offsets need the file text; build the converter with PositionConverter.fromText')
+    return this.lineBytes
+  }
+}
@@ -154,6 +154,10 @@ export function renderToIndex({type, dirs, exts}: ProjectIndexConfig): string {
     `# Project type: ${type}`,
     `# Use "dir:" entries to limit scope. If none are set, indexing starts at project root.`,
     `# File extensions are required; without them the watcher will ignore all files.`,
+    `# Use "exclude:" entries to skip generated or vendored files (exclude: vendor, exclude: *.pb.ts).`,
+    `# Use "build: bazel" (or "build: <manifest>") to index the files the build uses instead of walking the tree.`,
+    `# Use "vendor: dependency" to index vendor/ directories as third-party code, or "vendor: skip" to leave them out.`,
+    `# Ignore rules in .gitignore and .indexerignore apply as well.`,
     ``
   ].join('\n')
 
@@ -0,0 +1,63 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { QuerySyntaxError, parseQuery, selectSymbols } from './query-language.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'lib/notify.ts': `
+export interface Notifier {
+  send(msg: string): void
+}
+export class EmailNotifier implements Notifier {
+  send(msg: string) { this.format(msg) }
+  format(msg: string) { return msg }
+  retry() {}
+}
+export class Logger {
+  write(msg: string) {}
+}
+`,
+  'main.ts': `
+export function main() {}
+`
+}
+
+const names = (index: SymbolIndex, expr: string) => selectSymbols(index, expr).map(s => s.name)
+
+test('query-language: parses precedence, keywords and literals', () => {
+  assert.deepEqual(parseQuery('a = 1 OR b AND NOT c.contains("x", true)'), {
+    type: 'or',
+    left: { type: 'compare', path: ['a'], op: '=', value: 1 },
+    right: {
+      type: 'and',
+      left: { type: 'field', path: ['b'] },
+      right: { type: 'not', operand: { type: 'call', path: ['c'], method: 'contains', args: ['x', true] } }
+    }
+  })
+  assert.deepEqual(parseQuery('a == 1 || b && !c.contains("x", true)'), parseQuery('a = 1 OR b AND NOT c.contains("x", true)'))
+  assert.throws(() => parseQuery('kind = '), QuerySyntaxError)
+  assert.throws(() => parseQuery('name.frobnicate("x")'), /Unknown method "frobnicate"/)
+  assert.throws(() => parseQuery('(kind = "class"'), /Expected "\)"/)
+  assert.throws(() => parseQuery('kind = "class" kind'), /Unexpected "kind" at position 16/)
+})
+
+test('query-language: follows receivers and relations', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(names(index, 'kind = "method" AND receiver.implements("Notifier")'), [
+    'EmailNotifier.send', 'EmailNotifier.format', 'EmailNotifier.retry'
+  ])
+  assert.deepEqual(names(index, 'kind = "method" AND receiver.implements("Notifier") AND refs.count = 0'), [
+    'EmailNotifier.send', 'EmailNotifier.retry'
+  ])
+  assert.deepEqual(names(index, 'calledBy("EmailNotifier.send")'), ['EmailNotifier.format'])
+  assert.deepEqual(names(index, 'calls("format") AND callees.count = 1'), ['EmailNotifier.send'])
+})
+
+test('query-language: string methods, negation and structured filters', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(names(index, '(kind = "class" OR kind = "interface") AND NOT name.matches("*Notifier")'), ['Logger'])
+  assert.deepEqual(names(index, 'path.startsWith("lib/") AND name.matches("send")'), ['Notifier.send', 'EmailNotifier.send'])
+  assert.deepEqual(names(index, 'deprecated OR missing.field = "x"'), [])
+  assert.deepEqual(selectSymbols(index, 'package = "."', { kinds: ['function'] }).map(s => s.name), ['main'])
+})
@@ -0,0 +1,356 @@
+/**
+ * Query Language Module
+ * A small expression language over the symbol index, for filters the
+ * structured query flags cannot combine:
+ *
+ *   kind = "method" AND receiver.implements("Notifier") AND refs.count = 0
+ *   (kind = "class" OR kind = "interface") AND NOT name.matches("*Test*")
+ *   tag("json", "user_id") OR tags.db.name = "user_id"
+ *
+ * Terms are comparisons (= != < <= > >=) of a field path with a string,
+ * number, boolean or null literal, method calls on a field path, or a bare
+ * field path tested for truthiness; AND binds tighter than OR, NOT tightest.
+ * Field paths start at the symbol: its stored fields (name, kind, path,
+ * line, exported, signature, ...) plus receiver (alias parent), refs,
+ * callers, callees, implementations, interfaces, supertypes, subtypes,
+ * tests, values (of an enum), deprecated, package, owners (from
+ * CODEOWNERS), canThrow and escapingErrors (see error-paths), usage
+ * (usage.refs, usage.files, usage.packages; see usage-stats) and aliasOf,
+ * aliases and aliasRefs (the type an alias names, the aliases naming a type
+ * and the uses through them; see type-aliases), which can be followed
+ * further (receiver.name, callers.count, owners.contains("@platform-team")). wraps("AppError") matches functions
+ * that construct that error type with a cause. Field tags and analyzer
+ * facts are stored fields: tags.json.name, tags.validate.options,
+ * facts.sqlcheck.raw_query (see analyzers). landmarks groups a function's
+ * control-flow landmarks by kind: landmarks.goto.count > 0 (see landmarks).
+ * implementations stays within the workspace unless the caller passes
+ * another scope (see search-scope).
+ */
+
+import { deprecationNotice } from './deprecations.js'
+import { hasFieldTag, namePattern, querySymbols, type SymbolQuery } from './symbol-query.js'
+import { LANDMARK_KINDS, symbolLandmarks } from '../utils/landmarks.js'
+import { DEFAULT_SCOPES, type SearchScope } from './search-scope.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export type QueryLiteral = string | number | boolean | null
+
+export type CompareOp = '=' | '!=' | '<' | '<=' | '>' | '>='
+
+export type QueryNode =
+  | { type: 'and' | 'or', left: QueryNode, right: QueryNode }
+  | { type: 'not', operand: QueryNode }
+  | { type: 'compare', path: string[], op: CompareOp, value: QueryLiteral }
+  | { type: 'call', path: string[], method: string, args: QueryLiteral[] }
+  | { type: 'field', path: string[] }
+
+export class QuerySyntaxError extends Error {
+  constructor(message: string, readonly position: number) {
+    super(`${message} at position ${position + 1}`)
+  }
+}
+
+// Methods a term may call, by the kind of value they apply to
+const SYMBOL_METHODS = ['implements', 'extends', 'calls', 'calledBy', 'wraps', 'tag']
+const STRING_METHODS = ['matches', 'startsWith', 'endsWith', 'contains']
+const METHODS = new Set([...SYMBOL_METHODS, ...STRING_METHODS])
+
+type Token =
+  | { type: 'ident', value: string, pos: number }
+  | { type: 'string' | 'number', value: string, pos: number }
+  | { type: 'punct', value: string, pos: number }
+  | { type: 'end', value: '', pos: number }
+
+const KEYWORDS: Record<string, string> = { and: '&&', or: '||', not: '!' }
+
+function tokenize(source: string): Token[] {
+  const tokens: Token[] = []
+  let i = 0
+  while (i < source.length) {
+    const ch = source[i]
+    if (/\s/.test(ch)) {
+      i++
+    } else if (ch === '"' || ch === "'") {
+      let value = ''
+      let j = i + 1
+      for (; j < source.length && source[j] !== ch; j++) {
+        if (source[j] === '\\' && j + 1 < source.length) j++
+        value += source[j]
+      }
+      if (j >= source.length) throw new QuerySyntaxError('Unterminated string', i)
+      tokens.push({ type: 'string', value, pos: i })
+      i = j + 1
+    } else if (/[0-9]/.test(ch) || (ch === '-' && /[0-9]/.test(source[i + 1] || ''))) {
+      const m = source.slice(i).match(/^-?[0-9]+(\.[0-9]+)?/)!
+      tokens.push({ type: 'number', value: m[0], pos: i })
+      i += m[0].length
+    } else if (/[A-Za-z_$]/.test(ch)) {
+      const m = source.slice(i).match(/^[A-Za-z_$][A-Za-z0-9_$]*/)!
+      const keyword = KEYWORDS[m[0].toLowerCase()]
+      tokens.push(keyword ? { type: 'punct', value: keyword, pos: i } : { type: 'ident', value: m[0], pos: i })
+      i += m[0].length
+    } else {
+      const m = source.slice(i).match(/^(==|!=|<=|>=|&&|\|\||[=<>!().,])/)
+      if (!m) throw new QuerySyntaxError(`Unexpected character "${ch}"`, i)
+      tokens.push({ type: 'punct', value: m[0] === '==' ? '=' : m[0], pos: i })
+      i += m[0].length
+    }
+  }
+  tokens.push({ type: 'end', value: '', pos: source.length })
+  return tokens
+}
+
+/**
+ * Parse a query expression
+ * @throws QuerySyntaxError on malformed input or an unknown method
+ */
+export function parseQuery(source: string): QueryNode {
+  const tokens = tokenize(source)
+  let at = 0
+  const peek = () => tokens[at]
+  const isPunct = (value: string) => peek().type === 'punct' && peek().value === value
+  const expect = (value: string) => {
+    if (!isPunct(value)) throw new QuerySyntaxError(`Expected "${value}"`, peek().pos)
+    at++
+  }
+
+  function literal(): QueryLiteral {
+    const token = peek()
+    at++
+    if (token.type === 'string') return token.value
+    if (token.type === 'number') return Number(token.value)
+    if (token.type === 'ident' && ['true', 'false', 'null'].includes(token.value)) {
+      return token.value === 'null' ? null : token.value === 'true'
+    }
+    throw new QuerySyntaxError('Expected a string, number, true, false or null', token.pos)
+  }
+
+  function term(): QueryNode {
+    if (isPunct('(')) {
+      at++
+      const inner = or()
+      expect(')')
+      return inner
+    }
+    const start = peek()
+    if (start.type !== 'ident') throw new QuerySyntaxError('Expected a field name', start.pos)
+    const path = [start.value]
+    at++
+    while (isPunct('.')) {
+      at++
+      const seg = peek()
+      if (seg.type !== 'ident') throw new QuerySyntaxError('Expected a field name after "."', seg.pos)
+      path.push(seg.value)
+      at++
+    }
+
+    if (isPunct('(')) {
+      const method = path.pop()!
+      if (!METHODS.has(method)) throw new QuerySyntaxError(`Unknown method "${method}"`, start.pos)
+      at++
+      const args: QueryLiteral[] = []
+      while (!isPunct(')')) {
+        args.push(literal())
+        if (!isPunct(')')) expect(',')
+      }
+      at++
+      return { type: 'call', path, method, args }
+    }
+    const op = peek()
+    if (op.type === 'punct' && ['=', '!=', '<', '<=', '>', '>='].includes(op.value)) {
+      at++
+      return { type: 'compare', path, op: op.value as CompareOp, value: literal() }
+    }
+    return { type: 'field', path }
+  }
+
+  function not(): QueryNode {
+    if (isPunct('!')) {
+      at++
+      return { type: 'not', operand: not() }
+    }
+    return term()
+  }
+
+  function and(): QueryNode {
+    let left = not()
+    while (isPunct('&&')) {
+      at++
+      left = { type: 'and', left, right: not() }
+    }
+    return left
+  }
+
+  function or(): QueryNode {
+    let left = and()
+    while (isPunct('||')) {
+      at++
+      left = { type: 'or', left, right: and() }
+    }
+    return left
+  }
+
+  const node = or()
+  if (peek().type !== 'end') throw new QuerySyntaxError(`Unexpected "${peek().value}"`, peek().pos)
+  return node
+}
+
+function isSymbol(value: any): value is IndexedSymbol {
+  return !!value && typeof value === 'object' && typeof value.id === 'string' && typeof value.kind === 'string'
+}
+
+/**
+ * Type or namespace a member is declared in, or undefined at top level
+ */
+function ownerOf(index: SymbolIndex, sym: IndexedSymbol): IndexedSymbol | undefined {
+  const dot = sym.name.lastIndexOf('.')
+  if (dot === -1) return undefined
+  const ownerName = sym.name.slice(0, dot)
+  return index.fileSymbols(sym.path).find(s => s.name === ownerName)
+}
+
+/**
+ * Relations a field path can follow from a symbol
+ */
+function symbolField(index: SymbolIndex, sym: IndexedSymbol, field: string, scope: SearchScope): any {
+  switch (field) {
+    case 'receiver':
+    case 'parent':
+      return ownerOf(index, sym)
+    case 'refs':
+      return index.references(sym.id)
+    case 'aliasRefs':
+      return index.references(sym.id, undefined, undefined, 'alias')
+    case 'aliasOf':
+      return index.aliasTarget(sym.id) || undefined
+    case 'aliases':
+      return index.aliasesOf(sym.id)
+    case 'callers':
+      return index.callers(sym.id)
+    case 'callees':
+      return index.callees(sym.id)
+    case 'implementations':
+      return index.implementations(sym.id, scope)
+    case 'interfaces':
+      return index.interfaces(sym.id)
+    case 'supertypes':
+      return index.supertypes(sym.id, true).map(h => h.symbol)
+    case 'subtypes':
+      return index.subtypes(sym.id, true).map(h => h.symbol)
+    case 'tests':
+      return index.testsFor(sym.id)
+    case 'values':
+      return index.enumValues(sym.id).map(v => v.symbol || v.value)
+    case 'deprecated':
+      return deprecationNotice(sym) !== undefined
+    case 'package':
+      return sym.path.includes('/') ? sym.path.slice(0, sym.path.lastIndexOf('/')) : '.'
+    case 'owners':
+      return index.owners(sym.path)
+    case 'canThrow':
+      return index.canThrow(sym.id) !== null
+    case 'escapingErrors':
+      return index.escapingErrors(sym.id)
+    case 'usage':
+      return index.usage(sym.id)
+    case 'landmarks':
+      return Object.fromEntries(LANDMARK_KINDS.map(kind => [kind, symbolLandmarks(sym, kind)]))
+    default:
+      return sym[field]
+  }
+}
+
+function resolvePath(index: SymbolIndex, sym: IndexedSymbol, path: string[], scope: SearchScope): any {
+  let value: any = sym
+  for (const field of path) {
+    if (value === undefined || value === null) return undefined
+    if (Array.isArray(value)) value = field === 'count' ? value.length : undefined
+    else if (isSymbol(value)) value = symbolField(index, value, field, scope)
+    else if (typeof value === 'object') value = value[field]
+    else value = undefined
+  }
+  return value
+}
+
+function compare(actual: any, op: CompareOp, expected: QueryLiteral): boolean {
+  if (op === '=') return actual === expected || (actual === undefined && (expected === null || expected === false))
+  if (op === '!=') return !compare(actual, '=', expected)
+  if (typeof actual !== typeof expected || (typeof actual !== 'number' && typeof actual !== 'string')) return false
+  switch (op) {
+    case '<': return actual < (expected as any)
+    case '<=': return actual <= (expected as any)
+    case '>': return actual > (expected as any)
+    default: return actual >= (expected as any)
+  }
+}
+
+function callMethod(index: SymbolIndex, target: any, method: string, args: QueryLiteral[]): boolean {
+  const arg = String(args[0] ?? '')
+  if (isSymbol(target)) {
+    const matches = namePattern(arg)
+    switch (method) {
+      case 'implements': return index.interfaces(target.id).some(s => matches(s.name))
+      case 'extends': return index.supertypes(target.id, true).some(h => matches(h.symbol.name))
+      case 'calls': return index.callees(target.id).some(s => matches(s.name))
+      case 'calledBy': return index.callers(target.id).some(s => matches(s.name))
+      case 'wraps': return (target.wraps || []).some(matches)
+      case 'tag': return hasFieldTag(target, arg, args[1] === undefined ? undefined : String(args[1]))
+      default: return false
+    }
+  }
+  if (typeof target === 'string') {
+    switch (method) {
+      case 'matches': return namePattern(arg)(target)
+      case 'startsWith': return target.startsWith(arg)
+      case 'endsWith': return target.endsWith(arg)
+      case 'contains': return target.includes(arg)
+      default: return false
+    }
+  }
+  if (Array.isArray(target) && method === 'contains') {
+    return target.some(v => v === args[0] || (isSymbol(v) && namePattern(arg)(v.name)))
+  }
+  return false
+}
+
+/**
+ * Evaluate a parsed query against one symbol; scope bounds implementations
+ */
+export function evaluateQuery(
+  node: QueryNode,
+  index: SymbolIndex,
+  sym: IndexedSymbol,
+  scope: SearchScope = DEFAULT_SCOPES.implementations
+): boolean {
+  switch (node.type) {
+    case 'and': return evaluateQuery(node.left, index, sym, scope) && evaluateQuery(node.right, index, sym, scope)
+    case 'or': return evaluateQuery(node.left, index, sym, scope) || evaluateQuery(node.right, index, sym, scope)
+    case 'not': return !evaluateQuery(node.operand, index, sym, scope)
+    case 'compare': return compare(resolvePath(index, sym, node.path, scope), node.op, node.value)
+    case 'call': return callMethod(index, resolvePath(index, sym, node.path, scope), node.method, node.args)
+    case 'field': {
+      const value = resolvePath(index, sym, node.path, scope)
+      return Array.isArray(value) ? value.length > 0 : !!value
+    }
+  }
+}
+
+/**
+ * Symbols matching a query expression, combined with any structured filters
+ * and sorted as querySymbols sorts them
+ * @throws QuerySyntaxError if the expression does not parse
+ */
+export function selectSymbols(
+  index: SymbolIndex,
+  expression: string | QueryNode,
+  query: SymbolQuery = {},
+  scope: SearchScope = DEFAULT_SCOPES.implementations
+): IndexedSymbol[] {
+  const node = typeof expression === 'string' ? parseQuery(expression) : expression
+  const base = query.filter
+  return querySymbols(index, {
+    ...query,
+    filter: sym => (!base || base(sym)) && evaluateQuery(node, index, sym, scope)
+  })
+}
@@ -0,0 +1,73 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { parseRedaction, redactIndex, redactShard } from './redaction.js'
+import { shardProblem } from './index-integrity.js'
+import { SymbolIndex } from './symbol-index.js'
+import type { FileShard } from '../types/index.js'
+
+const SHARD: FileShard = {
+  path: 'src/billing.ts',
+  lang: 'typescript',
+  hash: 'abc',
+  symbols: [
+    {
+      id: 'src/billing.ts#charge',
+      name: 'charge',
+      kind: 'function',
+      path: 'src/billing.ts',
+      lang: 'typescript',
+      line: 3,
+      end_line: 5,
+      signature: "function charge(plan = 'acme-gold', note = \"it's\")",
+      doc: 'Charges the secret partner rate',
+      doc_info: { summary: 'Charges the secret partner rate', deprecated: 'use bill()' }
+    },
+    { id: 'src/billing.ts#KEY', name: 'KEY', kind: 'constant', path: 'src/billing.ts', lang: 'typescript', line: 1, end_line: 1, const_value: 'sk-live' }
+  ],
+  references: [
+    { name: 'test', path: 'src/billing.ts', line: 7, column: 1, call: true, test_title: 'charges acme' },
+    { name: 'get', path: 'src/billing.ts', line: 8, column: 1, call: true, receiver: 'vault("prod")' }
+  ],
+  todos: [{ marker: 'TODO', text: 'ask legal about acme', line: 2, column: 4 }],
+  strings: [{ value: 'acme-gold', kind: 'string', line: 3, column: 24 }],
+  diagnostics: [{ message: 'Syntax error: unexpected "acme secret"', line: 9, column: 1 }]
+}
+
+test('redaction: each class strips its text and keeps the structure', () => {
+  const strings = redactShard(SHARD, ['strings'])
+  assert.equal(strings.strings, undefined)
+  assert.equal(strings.symbols[0].signature, "function charge(plan = '', note = \"\")")
+  assert.equal(strings.symbols[1].const_value, '')
+  assert.deepEqual(strings.references.map(r => [r.test_title, r.receiver]), [['', undefined], [undefined, 'vault("")']])
+  assert.deepEqual(strings.todos, SHARD.todos)
+
+  const comments = redactShard(SHARD, ['comments'])
+  assert.equal(comments.symbols[0].doc, undefined)
+  assert.deepEqual(comments.symbols[0].doc_info, { deprecated: '' })
+  assert.equal(comments.todos, undefined)
+  assert.equal(comments.symbols[0].signature, SHARD.symbols[0].signature)
+
+  const contents = redactShard(SHARD, ['contents'])
+  assert.equal(contents.diagnostics![0].message, 'Syntax error: unexpected input')
+
+  for (const redacted of [strings, comments, contents]) {
+    assert.equal(shardProblem(redacted), null)
+    assert.deepEqual(redacted.symbols.map(s => [s.id, s.line]), SHARD.symbols.map(s => [s.id, s.line]))
+  }
+  // The original is left alone
+  assert.equal(SHARD.symbols[0].doc, 'Charges the secret partner rate')
+})
+
+test('redaction: policies and redacted index copies', () => {
+  assert.deepEqual(parseRedaction('comments, strings'), ['strings', 'comments'])
+  assert.deepEqual(parseRedaction('all'), ['strings', 'comments', 'contents'])
+  assert.deepEqual(parseRedaction('none'), [])
+  assert.throws(() => parseRedaction('secrets'), /Unknown redaction "secrets"/)
+
+  const index = new SymbolIndex()
+  index.addShard(structuredClone(SHARD))
+  assert.equal(redactIndex(index, []), index)
+  const redacted = redactIndex(index, ['comments'])
+  assert.equal(redacted.getSymbol('src/billing.ts#charge')?.doc, undefined)
+  assert.equal(index.getSymbol('src/billing.ts#charge')?.doc, 'Charges the secret partner rate')
+})
@@ -0,0 +1,119 @@
+/**
+ * Redaction Module
+ * Strips proprietary text from indexes that leave the build environment
+ * (indexer push, indexer export) while keeping their structure: symbols,
+ * references, positions, kinds and signatures stay as they are. A policy
+ * names what to strip, from redact: entries in .indexer/to-index or
+ * --redact on the command line:
+ *   strings   - string literals: the --strings table, literal union values,
+ *               constant values, test titles and quoted text in signatures
+ *               and receivers
+ *   comments  - doc comments (a deprecation stays, without its message) and
+ *               TODO comments
+ *   contents  - file contents: source text quoted by syntax errors, and the
+ *               source lines exporters (ctags, etags, docs) would embed
+ * The index the project queries locally is never redacted.
+ */
+
+import { SymbolIndex } from './symbol-index.js'
+import { stampChecksum } from './index-integrity.js'
+import { loadToIndexConfig } from './file-filters.js'
+import type { FileShard, IndexedSymbol, SymbolReference } from '../types/index.js'
+
+export type RedactionClass = 'strings' | 'comments' | 'contents'
+
+export const REDACTION_CLASSES: RedactionClass[] = ['strings', 'comments', 'contents']
+
+// Quoted literals, emptied so the shape of a signature survives ("'a'" -> "''")
+const QUOTED = /(['"`])(?:\\.|(?!\1)[^\\])*\1/g
+
+/**
+ * Parse a redaction policy: a comma-separated list of classes, all or none
+ */
+export function parseRedaction(value: string): RedactionClass[] {
+  const classes = new Set<RedactionClass>()
+  for (const entry of value.split(',').map(v => v.trim().toLowerCase()).filter(Boolean)) {
+    if (entry === 'none') continue
+    if (entry === 'all') REDACTION_CLASSES.forEach(c => classes.add(c))
+    else if (REDACTION_CLASSES.includes(entry as RedactionClass)) classes.add(entry as RedactionClass)
+    else throw new Error(`Unknown redaction "${entry}". Use ${[...REDACTION_CLASSES, 'all', 'none'].join(', ')}`)
+  }
+  return REDACTION_CLASSES.filter(c => classes.has(c))
+}
+
+/**
+ * Policy for an index leaving a project: --redact when given, else the
+ * redact: entries of .indexer/to-index
+ */
+export async function redactionPolicy(projectRoot: string, flag?: string): Promise<RedactionClass[]> {
+  if (flag !== undefined) return parseRedaction(flag)
+  return parseRedaction(((await loadToIndexConfig(projectRoot))?.redact || []).join(','))
+}
+
+function emptyQuotes(text: string): string {
+  return text.replace(QUOTED, '$1$1')
+}
+
+function redactSymbol(sym: IndexedSymbol, classes: RedactionClass[]): IndexedSymbol {
+  const redacted: IndexedSymbol = { ...sym }
+  if (classes.includes('strings')) {
+    delete redacted.literal_values
+    if (typeof redacted.const_value === 'string') redacted.const_value = ''
+    if (redacted.signature) redacted.signature = emptyQuotes(redacted.signature)
+    if (redacted.type_params) {
+      redacted.type_params = redacted.type_params.map(p => ({ ...p, ...(p.default ? { default: emptyQuotes(p.default) } : {}) }))
+    }
+  }
+  if (classes.includes('comments')) {
+    delete redacted.doc
+    delete redacted.doc_info
+    if (sym.doc_info?.deprecated !== undefined) redacted.doc_info = { deprecated: '' }
+  }
+  return redacted
+}
+
+function redactReference(ref: SymbolReference, classes: RedactionClass[]): SymbolReference {
+  if (!classes.includes('strings') || (ref.test_title === undefined && !ref.receiver)) return ref
+  return {
+    ...ref,
+    ...(ref.test_title !== undefined ? { test_title: '' } : {}),
+    ...(ref.receiver ? { receiver: emptyQuotes(ref.receiver) } : {})
+  }
+}
+
+/**
+ * Copy of a shard with the text a policy names stripped, checksum restamped
+ */
+export function redactShard(shard: FileShard, classes: RedactionClass[]): FileShard {
+  if (classes.length === 0) return shard
+  const { strings, todos, diagnostics, ...rest } = shard
+  const redacted: FileShard = {
+    ...rest,
+    symbols: shard.symbols.map(sym => redactSymbol(sym, classes)),
+    references: shard.references.map(ref => redactReference(ref, classes))
+  }
+  if (strings && !classes.includes('strings')) redacted.strings = strings
+  if (todos && !classes.includes('comments')) redacted.todos = todos
+  if (diagnostics) {
+    redacted.diagnostics = classes.includes('contents')
+      ? diagnostics.map(d => d.analyzer ? d : { ...d, message: d.message.replace(/^(Syntax error: unexpected) .*$/s, '$1 input') })
+      : diagnostics
+  }
+  return stampChecksum(redacted)
+}
+
+/**
+ * Copy of an index with every shard redacted. Type-checker bindings
+ * (--precision=full) stay behind: they belong to the original references.
+ */
+export function redactIndex(index: SymbolIndex, classes: RedactionClass[]): SymbolIndex {
+  if (classes.length === 0) return index
+  const redacted = new SymbolIndex()
+  redacted.moduleOf = index.moduleOf
+  redacted.externalOf = index.externalOf
+  redacted.repositoryOf = index.repositoryOf
+  redacted.ownersOf = index.ownersOf
+  redacted.dispatch = index.dispatch
+  for (const shard of index.listShards()) redacted.addShard(redactShard(shard, classes))
+  return redacted
+}
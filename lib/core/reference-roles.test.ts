@@ -0,0 +1,62 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { parseRoles, referenceRole } from './reference-roles.js'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { referenceRecord } from '../exporters/records.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/state.ts': [
+    { name: 'count', kind: 'variable', line: 1, end_line: 1, column: 12, exported: true },
+    { name: 'bump', kind: 'function', line: 2, end_line: 2, column: 17, exported: true }
+  ],
+  'src/app.ts': [
+    { name: 'count', kind: 'reference', line: 1, column: 10, role: 'import', source: './state' },
+    { name: 'count', kind: 'reference', line: 3, column: 3, role: 'write' },
+    { name: 'count', kind: 'reference', line: 4, column: 15 },
+    { name: 'bump', kind: 'reference', line: 5, column: 3, call: true }
+  ]
+}
+
+test('reference-roles: references carry their role and filter by it', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.references('src/state.ts#count').map(r => `${r.line}:${r.role}`), ['1:import', '3:write', '4:read'])
+  assert.deepEqual(index.references('src/state.ts#count', ['write']).map(r => r.line), [3])
+  assert.deepEqual(index.references('src/state.ts#bump').map(r => r.role), ['call'])
+
+  // Implied roles are not stored
+  const stored = index.getFile('src/app.ts')!.references
+  assert.deepEqual(stored.map(r => r.role), ['import', 'write', undefined, undefined])
+  assert.deepEqual(stored.map(referenceRole), ['import', 'write', 'read', 'call'])
+
+  assert.deepEqual(stored.map(ref => referenceRecord(ref).role), ['import', 'write', 'read', 'call'])
+})
+
+test('reference-roles: role lists', () => {
+  assert.deepEqual(parseRoles('write, call'), ['write', 'call'])
+  assert.throws(() => parseRoles('write,assign'), /Unknown reference role "assign"/)
+})
+
+test('reference-roles: babel classifies JS and TS references', () => {
+  const code = [
+    "import { total, Shape, Base } from './lib'",
+    'class Circle extends Base implements Shape {}',
+    'let area: Shape = total',
+    'total = 1',
+    'total += area',
+    'total++',
+    'const box = { total: 0 }',
+    'box.total = 2',
+    ';[total] = [3]',
+    'render(total)'
+  ].join('\n')
+  const refs = extractJSSymbols(code).filter(s => s.kind === 'reference')
+  const roles = refs.map(r => `${r.line}:${r.name}:${r.role || (r.call ? 'call' : 'read')}`)
+  for (const expected of [
+    '1:total:import', '2:Base:implement', '2:Shape:implement', '3:Shape:type', '3:total:read',
+    '4:total:write', '5:total:write', '5:area:read', '6:total:write', '8:box:read', '8:total:write',
+    '9:total:write', '10:render:call', '10:total:read'
+  ]) {
+    assert.ok(roles.includes(expected), `${expected} in ${roles.join(', ')}`)
+  }
+})
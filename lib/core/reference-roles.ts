@@ -0,0 +1,31 @@
+/**
+ * Reference Roles Module
+ * What a reference does with the name it uses: reads it, assigns it
+ * (write: x = 1, x += 1, x++, [x] = pair, for (x of xs)), calls it, imports
+ * it, names it as a type, extends or implements it, or passes it by
+ * reference (address: C# ref / out arguments and &x). Extractors store the
+ * role only when it is not implied, so a plain read carries none and a call
+ * has `call` set; referenceRole() fills them in. Reference lists filter by
+ * role, for "writes to this variable" (GET /refs/{id}?role=write).
+ */
+
+import type { ReferenceRole, SymbolReference } from '../types/index.js'
+
+export const REFERENCE_ROLES: ReferenceRole[] = ['read', 'write', 'call', 'import', 'type', 'implement', 'address']
+
+/**
+ * Role of a reference, implied ones included
+ */
+export function referenceRole(ref: SymbolReference): ReferenceRole {
+  return ref.role || (ref.call ? 'call' : 'read')
+}
+
+/**
+ * Parse a comma-separated role list (write,call)
+ */
+export function parseRoles(value: string): ReferenceRole[] {
+  const roles = value.split(',').map(r => r.trim()).filter(Boolean)
+  const unknown = roles.find(r => !REFERENCE_ROLES.includes(r as ReferenceRole))
+  if (unknown) throw new Error(`Unknown reference role "${unknown}". Use ${REFERENCE_ROLES.join(', ')}`)
+  return roles as ReferenceRole[]
+}
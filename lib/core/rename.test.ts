@@ -0,0 +1,110 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { SymbolIndex, makeSymbolId } from './symbol-index.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER_SRC = `export class User {
+  save() {}
+}
+
+export function helper() {}
+
+function greet() {}
+
+export function main(u) {
+  helper()
+  u.save()
+  greet()
+  report()
+}
+`
+
+const APP_SRC = `import { helper } from './user'
+const greet = 1
+export function run() {
+  return helper() + greet
+}
+`
+
+const TEAM_SRC = `export class Team {
+  save() {}
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': USER_SRC,
+  'src/app.ts': APP_SRC,
+  'src/team.ts': TEAM_SRC
+}
+
+function sites(edits: { path: string, line: number, column: number }[]): string[] {
+  return edits.map(e => `${e.path}:${e.line}:${e.column}`)
+}
+
+test('rename: edits the declaration, imports and every use', () => {
+  const plan = createIndex(FILES).renameTargets(makeSymbolId('src/user.ts', 'helper'), 'assist')
+  assert.deepEqual(sites(plan.edits), ['src/user.ts:5:17', 'src/app.ts:1:10', 'src/app.ts:4:10', 'src/user.ts:10:3'])
+  assert.ok(plan.edits.every(e => e.end_column === e.column + 'helper'.length && e.new_text === 'assist'))
+  assert.deepEqual(plan.conflicts, [])
+})
+
+test('rename: a local namesake keeps its own uses', () => {
+  const plan = createIndex(FILES).renameTargets(makeSymbolId('src/user.ts', 'greet'), 'welcome')
+  assert.deepEqual(sites(plan.edits), ['src/user.ts:7:10', 'src/user.ts:12:3'])
+  assert.deepEqual(plan.conflicts, [])
+})
+
+test('rename: reports collisions and shadowing', () => {
+  const index = createIndex(FILES)
+  const collide = index.renameTargets(makeSymbolId('src/user.ts', 'helper'), 'greet')
+  assert.deepEqual(collide.conflicts.map(c => [c.kind, c.path, c.line]), [
+    ['collision', 'src/user.ts', 7],
+    ['collision', 'src/app.ts', 2]
+  ])
+
+  const shadow = index.renameTargets(makeSymbolId('src/user.ts', 'greet'), 'report')
+  assert.deepEqual(shadow.conflicts.map(c => [c.kind, c.path, c.line]), [['shadowing', 'src/user.ts', 13]])
+})
+
+test('rename: member uses that may belong to a namesake are ambiguous', () => {
+  const plan = createIndex(FILES).renameTargets(makeSymbolId('src/user.ts', 'User.save'), 'store')
+  assert.deepEqual(sites(plan.edits), ['src/user.ts:2:3', 'src/user.ts:11:5'])
+  assert.deepEqual(plan.conflicts.map(c => [c.kind, c.line, c.symbol]), [['ambiguous', 11, makeSymbolId('src/team.ts', 'Team.save')]])
+})
+
+test('rename: invalid names and unknown symbols', () => {
+  const index = createIndex(FILES)
+  const plan = index.renameTargets(makeSymbolId('src/user.ts', 'helper'), '1st')
+  assert.deepEqual(plan.edits, [])
+  assert.equal(plan.conflicts[0].kind, 'invalid_name')
+  assert.throws(() => index.renameTargets('src/user.ts#Nope', 'x'), /Unknown symbol/)
+})
+
+test('rename: locals check the scopes around and inside theirs', () => {
+  const index = new SymbolIndex()
+  index.addFile('src/cart.ts', 'typescript', extractJSSymbols(`export function total(items) {
+  let sum = 0
+  for (const item of items) {
+    const price = item.price
+    sum += price
+  }
+  return sum
+}
+`, { locals: true }))
+
+  const rename = index.renameTargets('src/cart.ts#total/items', 'list')
+  assert.deepEqual(sites(rename.edits), ['src/cart.ts:1:23', 'src/cart.ts:3:22'])
+  assert.deepEqual(rename.conflicts, [])
+
+  // sum += price would read the inner price
+  const inner = index.renameTargets('src/cart.ts#total/sum', 'price')
+  assert.deepEqual(inner.conflicts.map(c => [c.kind, c.line, c.column, c.symbol]), [['shadowing', 5, 5, 'src/cart.ts#total/price']])
+
+  // sum += price would assign the renamed item
+  const outer = index.renameTargets('src/cart.ts#total/item', 'sum')
+  assert.deepEqual(outer.conflicts.map(c => [c.kind, c.line, c.column]), [['shadowing', 5, 5]])
+
+  const collide = index.renameTargets('src/cart.ts#total/sum', 'items')
+  assert.deepEqual(collide.conflicts.map(c => [c.kind, c.line, c.symbol]), [['collision', 1, 'src/cart.ts#total/items']])
+})
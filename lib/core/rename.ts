@@ -0,0 +1,148 @@
+/**
+ * Rename Module
+ * Computes the edit set for renaming a symbol: its declaration plus every
+ * reference, and the conflicts the rename would cause (a name already taken
+ * in the same scope, references that would start binding to the renamed
+ * symbol, references that may belong to a same-named symbol). Locals
+ * indexed with --locals are checked against the scopes around and inside
+ * theirs instead. Nothing is written; tooling applies the edits.
+ */
+
+import { shortName } from './symbol-index.js'
+import { isLocalSymbol, scopeContains, scopeWithin } from './local-scopes.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol, Location } from '../types/index.js'
+
+const IDENTIFIER = /^[\p{L}_$][\p{L}\p{N}_$]*$/u
+
+export interface RenameEdit {
+  path: string
+  line: number
+  column: number // 1-based, start of the old name
+  end_column: number // exclusive
+  new_text: string
+}
+
+export type RenameConflictKind = 'invalid_name' | 'collision' | 'shadowing' | 'ambiguous' | 'unknown_position'
+
+export interface RenameConflict {
+  kind: RenameConflictKind
+  message: string
+  path: string
+  line: number
+  column?: number
+  symbol?: string // ID of the other symbol involved
+}
+
+export interface RenamePlan {
+  edits: RenameEdit[]
+  conflicts: RenameConflict[]
+}
+
+function isMember(sym: IndexedSymbol): boolean {
+  return sym.name.includes('.')
+}
+
+/**
+ * Qualified name the symbol would have after the rename
+ */
+function renamedName(sym: IndexedSymbol, newName: string): string {
+  const idx = sym.name.lastIndexOf('.')
+  return idx === -1 ? newName : `${sym.name.slice(0, idx + 1)}${newName}`
+}
+
+/**
+ * Edits and conflicts for renaming a symbol
+ * @param index - Symbol index
+ * @param symbolId - Symbol to rename
+ * @param newName - New short name (the last segment of a qualified name)
+ */
+export function renameTargets(index: SymbolIndex, symbolId: string, newName: string): RenamePlan {
+  const sym = index.getSymbol(symbolId)
+  if (!sym) throw new Error(`Unknown symbol: ${symbolId}`)
+
+  const oldName = shortName(sym.name)
+  const plan: RenamePlan = { edits: [], conflicts: [] }
+  if (!IDENTIFIER.test(newName)) {
+    plan.conflicts.push({ kind: 'invalid_name', message: `"${newName}" is not a valid identifier`, path: sym.path, line: sym.line })
+    return plan
+  }
+  if (newName === oldName) return plan
+
+  const sites: Location[] = [{ path: sym.path, line: sym.line, column: sym.column }, ...index.references(symbolId)]
+  for (const site of sites) {
+    if (!site.column) {
+      plan.conflicts.push({ kind: 'unknown_position', message: `Column of ${oldName} unknown; edit by hand`, path: site.path, line: site.line })
+      continue
+    }
+    plan.edits.push({ path: site.path, line: site.line, column: site.column, end_column: site.column + oldName.length, new_text: newName })
+  }
+  if (isLocalSymbol(sym)) {
+    localConflicts(index, sym, newName, sites, plan)
+    return plan
+  }
+
+  // Name already declared in the same scope (type members or file top level)
+  const taken = index.fileSymbols(sym.path).find(s => s.name === renamedName(sym, newName) && !isLocalSymbol(s))
+  if (taken) {
+    plan.conflicts.push({ kind: 'collision', message: `${taken.name} is already declared`, path: taken.path, line: taken.line, column: taken.column, symbol: taken.id })
+  }
+
+  if (!isMember(sym)) {
+    // Files using the symbol that declare the new name themselves
+    const usingFiles = new Set(sites.map(s => s.path).filter(p => p !== sym.path))
+    for (const filePath of usingFiles) {
+      const local = index.fileSymbols(filePath).find(s => s.name === newName && !isLocalSymbol(s))
+      if (local) {
+        plan.conflicts.push({ kind: 'collision', message: `${newName} is already declared in ${filePath}`, path: local.path, line: local.line, column: local.column, symbol: local.id })
+      }
+    }
+    // Uses of the new name in the declaring file that would bind to the renamed symbol
+    if (!taken) {
+      for (const ref of index.getFile(sym.path)?.references || []) {
+        if (ref.name !== newName || index.localOf(ref)) continue
+        plan.conflicts.push({ kind: 'shadowing', message: `${newName} here would refer to the renamed ${oldName}`, path: ref.path, line: ref.line, column: ref.column })
+      }
+    }
+  }
+
+  // References resolve by name, so a same-named symbol elsewhere may own some of them
+  // (any same-named member; a top-level name only outside the declaring file)
+  const other = index.findSymbols(oldName).find(s => s !== sym && isMember(s) === isMember(sym) && !isLocalSymbol(s))
+  if (other) {
+    for (const ref of sites.slice(1)) {
+      if (!isMember(sym) && ref.path === sym.path) continue
+      plan.conflicts.push({ kind: 'ambiguous', message: `May refer to ${other.name} in ${other.path}`, path: ref.path, line: ref.line, column: ref.column, symbol: other.id })
+    }
+  }
+
+  return plan
+}
+
+/**
+ * Conflicts of renaming a parameter or local: the new name declared in the
+ * same scope, uses of an outer newName that the renamed local would capture,
+ * and uses of the local that a nested newName would capture
+ */
+function localConflicts(index: SymbolIndex, sym: IndexedSymbol, newName: string, sites: Location[], plan: RenamePlan): void {
+  const oldName = shortName(sym.name)
+  const sameScope = (other: IndexedSymbol) => other.scope_line === sym.scope_line && other.scope_column === sym.scope_column
+  // A label only meets other labels
+  const label = sym.kind === 'label'
+
+  const taken = index.localAt(sym.path, newName, sym.line, sym.column, label)
+  if (taken && sameScope(taken)) {
+    plan.conflicts.push({ kind: 'collision', message: `${newName} is already declared in ${sym.scope}`, path: taken.path, line: taken.line, column: taken.column, symbol: taken.id })
+  }
+  for (const ref of index.getFile(sym.path)?.references || []) {
+    if (ref.name !== newName || ref.property || !!ref.label !== label || !scopeContains(sym, ref.line, ref.column || 1)) continue
+    const bound = index.localOf(ref)
+    if (bound && scopeWithin(bound, sym)) continue
+    plan.conflicts.push({ kind: 'shadowing', message: `${newName} here would refer to the renamed ${oldName}`, path: ref.path, line: ref.line, column: ref.column })
+  }
+  for (const site of sites.slice(1)) {
+    const inner = index.localAt(site.path, newName, site.line, site.column, label)
+    if (!inner || sameScope(inner) || !scopeWithin(inner, sym)) continue
+    plan.conflicts.push({ kind: 'shadowing', message: `${oldName} here would refer to the ${newName} of ${inner.scope}`, path: site.path, line: site.line, column: site.column, symbol: inner.id })
+  }
+}
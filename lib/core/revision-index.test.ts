@@ -0,0 +1,108 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import os from 'os'
+import path from 'path'
+import { execFileSync } from 'child_process'
+import { buildRevisionIndex } from './revision-index.js'
+import { updateFromDiff } from './symbol-index.js'
+import { diffRevisions, lastCommitTimes, listRevisionFiles, readRevisionFiles, resolveRevision } from '../utils/git.js'
+
+function git(cwd: string, ...args: string[]) {
+  execFileSync('git', ['-c', 'user.name=test', '-c', 'user.email=test@example.com', ...args], { cwd, stdio: 'ignore' })
+}
+
+async function createRepo(): Promise<string> {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'revision-index-'))
+  git(root, 'init', '-q')
+  await fs.mkdir(path.join(root, 'src'))
+  await fs.writeFile(path.join(root, 'src/user.ts'), 'export class User {\n  login() {}\n}\n')
+  git(root, 'add', '-A')
+  git(root, 'commit', '-q', '-m', 'first')
+  await fs.writeFile(path.join(root, 'src/user.ts'), 'export class Account {\n  signIn() {}\n}\n')
+  await fs.writeFile(path.join(root, 'src/admin.ts'), 'export function promote() {}\n')
+  git(root, 'add', '-A')
+  git(root, 'commit', '-q', '-m', 'second')
+  // Uncommitted edits must not leak into revision indexes
+  await fs.writeFile(path.join(root, 'src/user.ts'), 'export class Draft {}\n')
+  return root
+}
+
+test('revision-index: git helpers list and read trees at a commit', async () => {
+  const root = await createRepo()
+  try {
+    const first = await resolveRevision(root, 'HEAD~1')
+    assert.match(first, /^[0-9a-f]{40}$/)
+    assert.deepEqual(await listRevisionFiles(root, first), ['src/user.ts'])
+    assert.deepEqual(await readRevisionFiles(root, first, ['src/user.ts', 'src/admin.ts']), [
+      'export class User {\n  login() {}\n}\n',
+      null
+    ])
+    await assert.rejects(resolveRevision(root, 'HEAD~9'), /Unknown revision/)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('revision-index: last commit times are cached per HEAD', async () => {
+  const root = await createRepo()
+  try {
+    const times = await lastCommitTimes(root)
+    assert.deepEqual([...times.keys()].sort(), ['src/admin.ts', 'src/user.ts'])
+    assert.ok(times.get('src/user.ts')! > 0)
+    assert.equal(await lastCommitTimes(root), times)
+
+    await fs.writeFile(path.join(root, 'src/new.ts'), 'export {}\n')
+    git(root, 'add', '-A')
+    git(root, 'commit', '-q', '-m', 'third')
+    const after = await lastCommitTimes(root)
+    assert.notEqual(after, times)
+    assert.ok(after.has('src/new.ts'))
+    assert.equal((await lastCommitTimes(os.tmpdir())).size, 0)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('revision-index: indexes the committed tree, not the working copy', async () => {
+  const root = await createRepo()
+  try {
+    const old = await buildRevisionIndex(root, await resolveRevision(root, 'HEAD~1'))
+    assert.deepEqual(old.allSymbols().map(s => s.name).sort(), ['User', 'User.login'])
+
+    const head = await buildRevisionIndex(root, await resolveRevision(root, 'HEAD'))
+    assert.deepEqual(head.listFiles(), ['src/admin.ts', 'src/user.ts'])
+    assert.equal(head.findSymbols('Draft').length, 0)
+    assert.equal(head.findSymbols('signIn')[0].path, 'src/user.ts')
+    assert.deepEqual((await head.text.search('promote', async () => 'export function promote() {}')).map(m => m.path), ['src/admin.ts'])
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('revision-index: splicing a diff gives the same index as a full build', async () => {
+  const root = await createRepo()
+  try {
+    git(root, 'mv', 'src/admin.ts', 'src/roles.ts')
+    git(root, 'commit', '-q', '-m', 'rename')
+    const from = await resolveRevision(root, 'HEAD~2')
+    const to = await resolveRevision(root, 'HEAD')
+
+    const changes = await diffRevisions(root, from, to)
+    assert.deepEqual(changes, [
+      { status: 'added', path: 'src/roles.ts' },
+      { status: 'modified', path: 'src/user.ts' }
+    ])
+    assert.deepEqual(await diffRevisions(root, 'HEAD~1', 'HEAD'), [
+      { status: 'renamed', path: 'src/roles.ts', oldPath: 'src/admin.ts' }
+    ])
+
+    const index = await buildRevisionIndex(root, from)
+    const update = await updateFromDiff(index, changes, relPaths => readRevisionFiles(root, to, relPaths))
+    assert.deepEqual(update.added, ['src/roles.ts'])
+    assert.deepEqual(update.modified, ['src/user.ts'])
+    assert.deepEqual(index.listShards(), (await buildRevisionIndex(root, to)).listShards())
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,132 @@
+/**
+ * Revision Index Module
+ * Symbol indexes of the tree at a git commit, read straight from the object
+ * database so the working copy is never touched. Indexes are keyed by
+ * commit SHA (and shard format version) and kept as symbol packs; commits
+ * are immutable, so a stored pack never needs re-syncing.
+ */
+
+import { SHARD_FORMAT_VERSION, SymbolIndex, indexFileContents, seedFromCache, updateFromDiff, type IndexUpdate } from './symbol-index.js'
+import { shouldIndexFile } from './file-filters.js'
+import { loadFileLimits } from './file-limits.js'
+import type { IndexContext } from './index-limits.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import { diffRevisions, listRevisionFiles, readRevisionFile, readRevisionFiles, resolveRevision } from '../utils/git.js'
+import type { FileChange, FileShard } from '../types/index.js'
+import { getSymbolPackPath, readSymbolPack, writeSymbolPack } from '../utils/symbol-pack.js'
+
+export interface RevisionIndex {
+  index: SymbolIndex
+  commit: string
+  /** True when the index came from a stored pack instead of being built */
+  cached: boolean
+  /** Set when the index was derived from a base revision's index */
+  base?: { commit: string, update: IndexUpdate }
+  /** Files taken from the cache index instead of parsed, when one was given */
+  fromCache?: string[]
+}
+
+export interface OpenRevisionIndexOptions extends IndexContext {
+  /** Re-index even if a pack for the commit exists */
+  rebuild?: boolean
+  /** Derive the index from this revision's index plus the diff between them */
+  base?: string
+  /** Shards of a previous build's index to take unchanged packages from */
+  cache?: FileShard[]
+}
+
+/**
+ * Index the tree of a commit
+ * @param projectRoot - Project root (inside the git work tree)
+ * @param commit - Full commit SHA
+ * @param cache - Shards of a previous index to take unchanged packages from
+ * @param ctx - Cancellation and parse concurrency
+ */
+export async function buildRevisionIndex(
+  projectRoot: string,
+  commit: string,
+  cache?: FileShard[],
+  ctx: IndexContext = {}
+): Promise<SymbolIndex> {
+  await initTreeSitter()
+  const relPaths: string[] = []
+  for (const relPath of await listRevisionFiles(projectRoot, commit)) {
+    if (await shouldIndexFile(relPath, projectRoot)) relPaths.push(relPath)
+  }
+  const index = new SymbolIndex()
+  index.limits = await loadFileLimits(projectRoot)
+  const contents = await readRevisionFiles(projectRoot, commit, relPaths)
+  ctx.signal?.throwIfAborted()
+  if (cache) seedFromCache(index, cache, relPaths, contents)
+  await indexFileContents(index, relPaths, contents, undefined, ctx)
+  return index
+}
+
+/**
+ * Open the index of a revision, building and storing it on first use. With
+ * a base revision, only the files changed since the base are re-parsed;
+ * with a cache, only the packages that differ from it.
+ * @param projectRoot - Project root (inside the git work tree)
+ * @param rev - Any git revision (HEAD~3, v1.2.0, a branch or SHA)
+ * @param options - Rebuild even if stored, derive from a base revision or warm-start from a cache, cancellation and limits
+ */
+export async function openRevisionIndex(
+  projectRoot: string,
+  rev: string,
+  options: OpenRevisionIndexOptions = {}
+): Promise<RevisionIndex> {
+  const commit = await resolveRevision(projectRoot, rev)
+  const packPath = getSymbolPackPath(projectRoot, `${commit}.${SHARD_FORMAT_VERSION}`)
+
+  const stored = options.rebuild ? null : await readSymbolPack(packPath)
+  if (stored) {
+    const index = new SymbolIndex()
+    for (const shard of stored) index.addShard(shard)
+    // Packs hold symbols only; text search needs the file contents again
+    const relPaths = index.listFiles()
+    const contents = await readRevisionFiles(projectRoot, commit, relPaths)
+    relPaths.forEach((relPath, i) => {
+      const content = contents[i]
+      if (content !== null) index.text.add(relPath, content)
+    })
+    return { index, commit, cached: true }
+  }
+
+  if (options.base) {
+    const base = await openRevisionIndex(projectRoot, options.base, { signal: options.signal, concurrency: options.concurrency })
+    const changes = await indexableChanges(projectRoot, await diffRevisions(projectRoot, base.commit, commit))
+    const update = await updateFromDiff(base.index, changes, relPaths => readRevisionFiles(projectRoot, commit, relPaths), options)
+    await writeSymbolPack(packPath, base.index.listShards(), { format: SHARD_FORMAT_VERSION })
+    return { index: base.index, commit, cached: false, base: { commit: base.commit, update } }
+  }
+
+  const index = await buildRevisionIndex(projectRoot, commit, options.cache, options)
+  await writeSymbolPack(packPath, index.listShards(), { format: SHARD_FORMAT_VERSION })
+  if (!options.cache) return { index, commit, cached: false }
+  // Seeded shards are the cache's own objects
+  const fromCache = options.cache.filter(shard => index.getFile(shard.path) === shard).map(shard => shard.path)
+  return { index, commit, cached: false, fromCache }
+}
+
+/**
+ * Drop changes to files the project filters exclude; a rename out of the
+ * indexed set becomes a deletion
+ */
+async function indexableChanges(projectRoot: string, changes: FileChange[]): Promise<FileChange[]> {
+  const result: FileChange[] = []
+  for (const change of changes) {
+    if (change.status === 'deleted' || await shouldIndexFile(change.path, projectRoot)) {
+      result.push(change)
+    } else if (change.status === 'renamed' && change.oldPath) {
+      result.push({ status: 'deleted', path: change.oldPath })
+    }
+  }
+  return result
+}
+
+/**
+ * Reader for file contents at a commit, for search and exporters
+ */
+export function revisionReader(projectRoot: string, commit: string): (relPath: string) => Promise<string | null> {
+  return (relPath) => readRevisionFile(projectRoot, commit, relPath)
+}
@@ -0,0 +1,68 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { fuzzySearch } from './fuzzy-search.js'
+import { parseRankingEntries, rankingSignals, type SearchRanking } from './search-ranking.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const DAY = 24 * 60 * 60 * 1000
+const NOW = Date.parse('2024-06-01T00:00:00.000Z')
+
+// Two equally good matches for "parse": a deep helper and an exported API
+const FILES: FixtureFiles = {
+  'src/internal/util/parse.ts': [
+    { name: 'parse', kind: 'variable', line: 1, end_line: 1 }
+  ],
+  'src/parse.ts': [
+    { name: 'parse', kind: 'function', line: 1, end_line: 5, exported: true }
+  ]
+}
+
+function ranking(weights: Partial<SearchRanking['weights']>, extra: Partial<SearchRanking> = {}): SearchRanking {
+  return { weights: { kind: 0, exported: 0, usage: 0, depth: 0, recency: 0, ...weights }, hooks: [], ...extra }
+}
+
+test('parseRankingEntries reads weights and leaves modules', () => {
+  assert.deepEqual(parseRankingEntries(['usage=2, depth=1', 'recency=0.5', './tools/rank.js']), {
+    weights: { usage: 2, depth: 1, recency: 0.5 },
+    modules: ['./tools/rank.js']
+  })
+  assert.throws(() => parseRankingEntries(['popularity=2']), /Unknown ranking signal "popularity"/)
+})
+
+test('rankingSignals are between 0 and 1', () => {
+  const index = createIndex(FILES)
+  const helper = index.allSymbols().find(s => s.path === 'src/internal/util/parse.ts')!
+  const api = index.allSymbols().find(s => s.path === 'src/parse.ts')!
+  const commitTimes = new Map([['src/internal/util/parse.ts', NOW - 30 * DAY]])
+  const helperSignals = rankingSignals(index, helper, 80, ranking({ recency: 1 }, { commitTimes }), NOW)
+  assert.deepEqual(helperSignals, { match: 80, kind: 0.4, exported: 0, usage: 0, depth: 0.25, recency: 0.5 })
+  // Uncommitted files count as new
+  assert.equal(rankingSignals(index, api, 80, ranking({ recency: 1 }, { commitTimes }), NOW).recency, 1)
+})
+
+test('weights and hooks reorder equally good matches', () => {
+  const index = createIndex(FILES)
+  const order = (r: SearchRanking | null) => fuzzySearch(index, 'parse', { ranking: r }).map(m => m.symbol.path)
+  // Without a ranking, ties fall back to the path
+  assert.deepEqual(order(null), ['src/internal/util/parse.ts', 'src/parse.ts'])
+  assert.deepEqual(order(ranking({ exported: 1 })), ['src/parse.ts', 'src/internal/util/parse.ts'])
+  assert.deepEqual(order(ranking({ depth: -1 })), ['src/internal/util/parse.ts', 'src/parse.ts'])
+
+  const internalFirst = { name: 'internal', score: (sym: any) => (sym.path.includes('/internal/') ? 100 : 0) }
+  assert.deepEqual(order(ranking({ exported: 1 }, { hooks: [internalFirst] })), ['src/internal/util/parse.ts', 'src/parse.ts'])
+
+  index.ranking = ranking({ kind: 1 })
+  assert.deepEqual(fuzzySearch(index, 'parse').map(m => m.symbol.kind), ['function', 'variable'])
+})
+
+test('a throwing hook scores 0 and is reported once', (t) => {
+  const index = createIndex(FILES)
+  const errors: string[] = []
+  t.mock.method(console, 'error', (msg: string) => errors.push(msg))
+  const broken = { name: 'broken', score: (sym: any) => { if (sym.exported) throw new Error('no owner'); return 100 } }
+  const order = fuzzySearch(index, 'parse', { ranking: ranking({ exported: 1 }, { hooks: [broken] }) }).map(m => m.symbol.path)
+  assert.deepEqual(order, ['src/internal/util/parse.ts', 'src/parse.ts'])
+  fuzzySearch(index, 'parse', { ranking: ranking({}, { hooks: [broken] }) })
+  assert.equal(errors.length, 1)
+  assert.match(errors[0], /Ranking hook broken failed on src\/parse\.ts#parse; it scores 0: no owner/)
+})
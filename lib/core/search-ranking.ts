@@ -0,0 +1,177 @@
+/**
+ * Search Ranking Module
+ * Tunable ordering of symbol search results (workspace symbols, --fuzzy)
+ * on top of the match score: weighted signals, each between 0 and 1, and
+ * ranking hooks that add a score of their own. Both come from rank: entries
+ * in .indexer/to-index, weights as signal=weight and hooks as JavaScript
+ * modules exporting a RankingHook:
+ *
+ *   rank: exported=1, usage=2
+ *   rank: recency=0.5
+ *   rank: ./tools/rank.js
+ *
+ * Signals: kind (types over callables over values), exported, usage
+ * (references, log scale), depth (shallow paths first) and recency (last
+ * commit to the file; uncommitted files count as new). A signal of weight w
+ * is worth up to w matched characters of the pattern, so small weights
+ * reorder matches of similar quality rather than promote poor matches.
+ * Without rank: entries results keep the match order. A hook that throws
+ * scores 0 for that result and is reported once.
+ */
+
+import path from 'path'
+import { pathToFileURL } from 'url'
+import { lastCommitTimes } from '../utils/git.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export const RANKING_SIGNALS = ['kind', 'exported', 'usage', 'depth', 'recency'] as const
+export type RankingSignal = typeof RANKING_SIGNALS[number]
+
+export type RankingWeights = Record<RankingSignal, number>
+
+export interface RankingSignals extends Record<RankingSignal, number> {
+  match: number // score of the pattern against the name (see fuzzyScore)
+}
+
+export interface RankingHook {
+  name: string
+  /** Added to the result's score; may be negative */
+  score(sym: IndexedSymbol, signals: RankingSignals): number
+}
+
+export interface SearchRanking {
+  weights: RankingWeights
+  hooks: RankingHook[]
+  /** Last commit time of each file (recency), in milliseconds */
+  commitTimes?: Map<string, number>
+}
+
+// Score of one matched character (SCORE_MATCH in fuzzy-search), the unit of a weight
+const SIGNAL_SCALE = 16
+// References at which the usage signal saturates
+const USAGE_SATURATION = 1024
+// Age at which the recency signal is halved
+const RECENCY_HALF_LIFE_MS = 30 * 24 * 60 * 60 * 1000
+
+const KIND_SIGNAL: Record<string, number> = {
+  class: 1, interface: 1, struct: 1, enum: 1, type: 1, namespace: 1, scriptable_object: 1,
+  function: 0.8, method: 0.8, function_component: 0.8, hook: 0.8, accessor: 0.8, unity_lifecycle: 0.8,
+  constant: 0.6, const: 0.6, default_export: 0.6,
+  variable: 0.4, property: 0.4, field: 0.4, private_field: 0.4, serialized_field: 0.4
+}
+const KIND_SIGNAL_OTHER = 0.2
+
+const NO_WEIGHTS: RankingWeights = { kind: 0, exported: 0, usage: 0, depth: 0, recency: 0 }
+
+// Hooks that have thrown, reported once each
+const failedHooks = new WeakSet<RankingHook>()
+
+const WEIGHT_ENTRY = /^([a-z]+)\s*=\s*(-?\d+(?:\.\d+)?)$/
+
+function isRankingHook(value: any): value is RankingHook {
+  return !!value && typeof value.name === 'string' && typeof value.score === 'function'
+}
+
+/**
+ * Weights from signal=weight lists ("usage=2, depth=1"); entries that are
+ * not weights are left for loadSearchRanking as hook modules
+ * @throws Error on an unknown signal
+ */
+export function parseRankingEntries(entries: string[]): { weights: Partial<RankingWeights>, modules: string[] } {
+  const weights: Partial<RankingWeights> = {}
+  const modules: string[] = []
+  for (const entry of entries) {
+    const parts = entry.split(',').map(p => p.trim()).filter(Boolean)
+    if (!parts.every(p => WEIGHT_ENTRY.test(p))) {
+      modules.push(entry.trim())
+      continue
+    }
+    for (const part of parts) {
+      const [, signal, weight] = WEIGHT_ENTRY.exec(part)!
+      if (!RANKING_SIGNALS.includes(signal as RankingSignal)) {
+        throw new Error(`Unknown ranking signal "${signal}". Use ${RANKING_SIGNALS.join(', ')}`)
+      }
+      weights[signal as RankingSignal] = Number(weight)
+    }
+  }
+  return { weights, modules }
+}
+
+/**
+ * Ranking of a project from its rank: entries, with INDEXER_RANK (weights
+ * only) overriding theirs; null when nothing is configured
+ */
+export async function loadSearchRanking(projectRoot: string, entries: string[]): Promise<SearchRanking | null> {
+  const configured = parseRankingEntries(entries)
+  const override = parseRankingEntries(process.env.INDEXER_RANK ? [process.env.INDEXER_RANK] : [])
+  if (override.modules.length > 0) throw new Error(`Invalid INDEXER_RANK "${process.env.INDEXER_RANK}". Use signal=weight pairs, such as usage=2,depth=1`)
+  const weights: RankingWeights = { ...NO_WEIGHTS, ...configured.weights, ...override.weights }
+  const hooks: RankingHook[] = []
+  for (const entry of configured.modules) {
+    const specifier = entry.startsWith('.') || path.isAbsolute(entry)
+      ? pathToFileURL(path.resolve(projectRoot, entry)).href
+      : entry
+    let loaded: any
+    try {
+      loaded = await import(specifier)
+    } catch (e) {
+      throw new Error(`Cannot load ranking hook ${entry}: ${(e as Error).message}`)
+    }
+    const exported = [loaded.rankingHook, loaded.default, ...(Array.isArray(loaded.rankingHooks) ? loaded.rankingHooks : [])].filter(isRankingHook)
+    if (exported.length === 0) throw new Error(`Ranking hook ${entry} exports no hook (an object with name and score)`)
+    hooks.push(...new Set(exported))
+  }
+  if (hooks.length === 0 && RANKING_SIGNALS.every(s => weights[s] === 0)) return null
+  const commitTimes = weights.recency !== 0 || hooks.length > 0 ? await lastCommitTimes(projectRoot) : undefined
+  return { weights, hooks, ...(commitTimes ? { commitTimes } : {}) }
+}
+
+/**
+ * Signals of a search result, each between 0 and 1
+ * @param match - Score of the pattern against the symbol's name
+ * @param now - Time recency is measured from
+ */
+export function rankingSignals(index: SymbolIndex, sym: IndexedSymbol, match: number, ranking: SearchRanking, now = Date.now()): RankingSignals {
+  const depth = sym.path.split('/').length - 1
+  const committed = ranking.commitTimes?.get(sym.path)
+  const age = committed === undefined ? 0 : Math.max(0, now - committed)
+  return {
+    match,
+    kind: Object.hasOwn(KIND_SIGNAL, sym.kind) ? KIND_SIGNAL[sym.kind] : KIND_SIGNAL_OTHER,
+    exported: sym.exported ? 1 : 0,
+    usage: ranking.weights.usage !== 0 || ranking.hooks.length > 0
+      ? Math.min(1, Math.log2(1 + index.usage(sym.id).refs) / Math.log2(1 + USAGE_SATURATION))
+      : 0,
+    depth: 1 / (1 + depth),
+    recency: Math.pow(0.5, age / RECENCY_HALF_LIFE_MS)
+  }
+}
+
+/**
+ * What a ranking adds to a result's match score: its weighted signals and
+ * the scores of its hooks
+ */
+export function rankingBonus(index: SymbolIndex, sym: IndexedSymbol, match: number, ranking: SearchRanking, now = Date.now()): number {
+  const signals = rankingSignals(index, sym, match, ranking, now)
+  let bonus = 0
+  for (const signal of RANKING_SIGNALS) bonus += ranking.weights[signal] * signals[signal] * SIGNAL_SCALE
+  for (const hook of ranking.hooks) {
+    const score = hookScore(hook, sym, signals)
+    if (Number.isFinite(score)) bonus += score
+  }
+  return bonus
+}
+
+// A hook that throws scores 0, and is reported the first time only
+function hookScore(hook: RankingHook, sym: IndexedSymbol, signals: RankingSignals): number {
+  try {
+    return Number(hook.score(sym, signals))
+  } catch (e) {
+    if (!failedHooks.has(hook)) {
+      failedHooks.add(hook)
+      console.error(`Ranking hook ${hook.name} failed on ${sym.id}; it scores 0: ${(e as Error).message}`)
+    }
+    return 0
+  }
+}
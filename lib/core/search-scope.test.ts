@@ -0,0 +1,86 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { parseScope, scopeFilter } from './search-scope.js'
+import { selectSymbols } from './query-language.js'
+import { createIndex, type FixtureFiles, type FixtureOptions } from '../test-utils.js'
+
+const MODULES: Record<string, string> = {
+  'packages/billing/': '@org/billing',
+  'packages/users/': '@org/users',
+  'node_modules/cache-lib/': 'cache-lib'
+}
+
+const OPTIONS: FixtureOptions = {
+  moduleOf: filePath => {
+    const dir = Object.keys(MODULES).find(d => filePath.startsWith(d))
+    return { name: dir ? MODULES[dir] : 'app', version: '1.0.0' }
+  }
+}
+
+const FILES: FixtureFiles = {
+  'packages/billing/src/store.ts': [
+    { name: 'Store', kind: 'interface', line: 1, end_line: 3, column: 18, exported: true },
+    { name: 'MemoryStore', kind: 'class', line: 5, end_line: 7, column: 14, exported: true, implements: ['Store'] },
+    { name: 'Store', kind: 'reference', line: 5, column: 37 },
+    { name: 'EventStore', kind: 'class', line: 9, end_line: 9, column: 14, exported: true, extends: ['Emitter'] },
+    { name: 'Emitter', kind: 'reference', line: 9, column: 33 }
+  ],
+  'packages/users/src/db.ts': [
+    { name: 'DbStore', kind: 'class', line: 3, end_line: 5, column: 14, exported: true, implements: ['Store'] },
+    { name: 'Store', kind: 'reference', line: 3, column: 33 }
+  ],
+  'node_modules/cache-lib/index.d.ts': [
+    { name: 'Emitter', kind: 'class', line: 1, end_line: 1, column: 22, exported: true },
+    { name: 'LruStore', kind: 'class', line: 3, end_line: 3, column: 22, exported: true, implements: ['Store'] },
+    { name: 'Store', kind: 'reference', line: 3, column: 42 }
+  ]
+}
+
+const STORE = 'packages/billing/src/store.ts#Store'
+
+test('search-scope: references are found in the module, the workspace or everywhere', () => {
+  const index = createIndex(FILES, OPTIONS)
+  const paths = (scope: 'module' | 'workspace' | 'all') => index.references(STORE, undefined, scope).map(r => r.path)
+  assert.deepEqual(paths('all'), ['node_modules/cache-lib/index.d.ts', 'packages/billing/src/store.ts', 'packages/users/src/db.ts'])
+  assert.deepEqual(paths('workspace'), ['packages/billing/src/store.ts', 'packages/users/src/db.ts'])
+  assert.deepEqual(paths('module'), ['packages/billing/src/store.ts'])
+  // Without a scope nothing is left out
+  assert.equal(index.references(STORE).length, 3)
+})
+
+test('search-scope: implementations and type hierarchy stay within the scope', () => {
+  const index = createIndex(FILES, OPTIONS)
+  const names = (items: { symbol: { name: string } }[]) => items.map(i => i.symbol.name).sort()
+  assert.deepEqual(names(index.subtypes(STORE, false, 'all')), ['DbStore', 'LruStore', 'MemoryStore'])
+  assert.deepEqual(names(index.subtypes(STORE, false, 'workspace')), ['DbStore', 'MemoryStore'])
+  assert.deepEqual(names(index.subtypes(STORE, false, 'module')), ['MemoryStore'])
+  assert.deepEqual(index.implementations(STORE, 'module').map(s => s.name), ['MemoryStore'])
+  assert.deepEqual(index.implementations(STORE, 'workspace').map(s => s.name).sort(), ['DbStore', 'MemoryStore'])
+
+  // A base class from a dependency is a supertype only when dependencies are searched
+  const eventStore = 'packages/billing/src/store.ts#EventStore'
+  assert.deepEqual(names(index.supertypes(eventStore, false, 'all')), ['Emitter'])
+  assert.deepEqual(index.supertypes(eventStore, false, 'workspace'), [])
+})
+
+test('search-scope: query implementations leave out dependencies unless asked', () => {
+  const index = createIndex(FILES, OPTIONS)
+  const names = (expression: string, scope?: 'module' | 'workspace' | 'all') =>
+    selectSymbols(index, expression, {}, scope).map(s => s.name)
+  assert.deepEqual(names('kind = "interface" AND implementations.contains("LruStore")'), [])
+  assert.deepEqual(names('kind = "interface" AND implementations.count = 2'), ['Store'])
+  assert.deepEqual(names('kind = "interface" AND implementations.contains("LruStore")', 'all'), ['Store'])
+  assert.deepEqual(names('kind = "interface" AND implementations.count = 1', 'module'), ['Store'])
+})
+
+test('search-scope: vendored modules are outside the workspace', () => {
+  const index = new SymbolIndex()
+  index.externalOf = (filePath) => filePath.startsWith('vendor/')
+  const workspace = scopeFilter(index, 'workspace', 'src/app.ts')!
+  assert.equal(workspace.includes('src/user.ts'), true)
+  assert.equal(workspace.includes('vendor/lodash/map.js'), false)
+  assert.equal(workspace.includes('web/node_modules/react/index.d.ts'), false)
+  assert.equal(scopeFilter(index, 'all', 'src/app.ts'), null)
+  assert.throws(() => parseScope('repo'), /Unknown scope "repo"/)
+})
@@ -0,0 +1,80 @@
+/**
+ * Search Scope Module
+ * How far reference, implementation and hierarchy searches look from the
+ * symbol they start at: its own module (the package.json package, workspace
+ * member, build package or vendored module it is declared in), the
+ * workspace (first-party code: everything but installed dependencies under
+ * node_modules and vendored modules), or everything the index holds. Uses
+ * of a name inside dependencies and types deriving from it there are rarely
+ * what a search is after, so references and subtypes default to the
+ * workspace; supertypes default to everything, since a type's bases often
+ * live in its dependencies.
+ */
+
+import type { SymbolIndex } from './symbol-index.js'
+
+export type SearchScope = 'module' | 'workspace' | 'all'
+
+export const SEARCH_SCOPES: SearchScope[] = ['module', 'workspace', 'all']
+
+export const DEFAULT_SCOPES = {
+  references: 'workspace',
+  implementations: 'workspace',
+  subtypes: 'workspace',
+  supertypes: 'all'
+} satisfies Record<string, SearchScope>
+
+export interface ScopeFilter {
+  key: string // identifies the files in scope, for caching analyses per scope
+  includes(filePath: string): boolean
+}
+
+/**
+ * Parse a scope name (module, workspace, all)
+ */
+export function parseScope(value: string): SearchScope {
+  if (!SEARCH_SCOPES.includes(value as SearchScope)) {
+    throw new Error(`Unknown scope "${value}". Use ${SEARCH_SCOPES.join(', ')}`)
+  }
+  return value as SearchScope
+}
+
+/**
+ * Whether a file is an installed dependency's (under node_modules)
+ */
+export function isDependencyPath(filePath: string): boolean {
+  return /(^|\/)node_modules\//.test(filePath)
+}
+
+/**
+ * Module a file belongs to: its package, else its repository in a merged
+ * index, else '' (the project is a single module)
+ */
+export function scopeModuleOf(index: SymbolIndex, filePath: string): string {
+  if (index.moduleOf) return index.moduleOf(filePath)?.name ?? ''
+  return index.repositoryOf ? index.repositoryOf(filePath) : ''
+}
+
+/**
+ * Files a search from a file looks at
+ * @param fromPath - File of the symbol the search starts at
+ * @returns null when the search covers the whole index
+ */
+export function scopeFilter(index: SymbolIndex, scope: SearchScope, fromPath: string): ScopeFilter | null {
+  switch (scope) {
+    case 'all':
+      return null
+    case 'workspace':
+      return {
+        key: 'workspace',
+        includes: (filePath) => !isDependencyPath(filePath) && !index.externalOf?.(filePath)
+      }
+    case 'module': {
+      const module = scopeModuleOf(index, fromPath)
+      return {
+        key: `module:${module}`,
+        includes: (filePath) => scopeModuleOf(index, filePath) === module
+      }
+    }
+  }
+}
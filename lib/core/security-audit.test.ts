@@ -0,0 +1,136 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { auditSecurity, findSensitiveCalls, securityAnalyzer } from './security-audit.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const SHELL_SRC = `import cp from 'node:child_process'
+import { createHash } from 'crypto'
+export function shell(cmd) {
+  cp.exec(cmd)
+  /x/.exec(cmd)
+  return createHash('md5').update(cmd).digest('hex')
+}
+export function find(db, id) {
+  return db.query(\`SELECT * FROM users WHERE id = \${id}\`)
+}
+const agent = { rejectUnauthorized: false }
+`
+
+const DEPLOY_SRC = `import subprocess as sp
+from hashlib import md5
+import yaml
+
+def deploy(target):
+    sp.run(["ls", target])
+    yaml.load(open(target), Loader=yaml.SafeLoader)
+    return md5(target.encode())
+
+def lookup(cursor, name):
+    cursor.execute("SELECT * FROM users WHERE name = '%s'" % name)
+`
+
+const HANDLER_SRC = `import { shell } from '../shell'
+export function handle(req) {
+  return shell(req.cmd)
+}
+`
+
+const MAIN_SRC = `import { handle } from './api/handler'
+export function main() {
+  handle({ cmd: 'ls' })
+}
+`
+
+const SOURCES: Record<string, string> = {
+  'src/shell.ts': SHELL_SRC,
+  'src/deploy.py': DEPLOY_SRC,
+  'src/api/handler.ts': HANDLER_SRC,
+  'src/main.ts': MAIN_SRC
+}
+
+const FILES: FixtureFiles = {
+  'src/shell.ts': [
+    { name: 'shell', kind: 'function', line: 3, end_line: 7, column: 17, exported: true },
+    { name: 'find', kind: 'function', line: 8, end_line: 10, column: 17, exported: true },
+    { name: 'agent', kind: 'variable', line: 11, end_line: 11, column: 7 },
+    { name: 'exec', kind: 'reference', line: 4, column: 6, call: true, receiver: 'cp' },
+    { name: 'exec', kind: 'reference', line: 5, column: 7, call: true },
+    { name: 'createHash', kind: 'reference', line: 6, column: 10, call: true },
+    { name: 'query', kind: 'reference', line: 9, column: 13, call: true, receiver: 'db' }
+  ],
+  'src/deploy.py': [
+    { name: 'deploy', kind: 'function', line: 5, end_line: 8, column: 5 },
+    { name: 'lookup', kind: 'function', line: 10, end_line: 11, column: 5 },
+    { name: 'run', kind: 'reference', line: 6, column: 8, call: true, receiver: 'sp' },
+    { name: 'load', kind: 'reference', line: 7, column: 10, call: true, receiver: 'yaml' },
+    { name: 'md5', kind: 'reference', line: 8, column: 12, call: true },
+    { name: 'execute', kind: 'reference', line: 11, column: 12, call: true, receiver: 'cursor' }
+  ],
+  'src/api/handler.ts': [
+    { name: 'handle', kind: 'function', line: 2, end_line: 4, column: 17, exported: true },
+    { name: 'shell', kind: 'reference', line: 3, column: 10, call: true }
+  ],
+  'src/main.ts': [
+    { name: 'main', kind: 'function', line: 2, end_line: 4, column: 17, exported: true },
+    { name: 'handle', kind: 'reference', line: 3, column: 3, call: true }
+  ]
+}
+
+test('security-audit: sensitive calls are recognized through the file imports', () => {
+  const index = createIndex(FILES)
+  const calls = (relPath: string) => findSensitiveCalls({ ...index.getFile(relPath)!, content: SOURCES[relPath] })
+    .map(c => `${c.category} ${c.api} ${c.line}`)
+  // RegExp#exec is not child_process.exec
+  assert.deepEqual(calls('src/shell.ts'), [
+    'exec child_process.exec 4',
+    'weak_crypto crypto.createHash 6',
+    'sql_concat db.query 9',
+    'insecure_transport rejectUnauthorized: false 11'
+  ])
+  // yaml.load with a safe loader is fine
+  assert.deepEqual(calls('src/deploy.py'), [
+    'exec subprocess.run 6',
+    'weak_crypto hashlib.md5 8',
+    'sql_concat cursor.execute 11'
+  ])
+  assert.deepEqual(calls('src/main.ts'), [])
+})
+
+test('security-audit: the analyzer stores warnings and the APIs each declaration calls', async () => {
+  const index = createIndex(FILES)
+  const shard = index.getFile('src/shell.ts')!
+  const result = await securityAnalyzer().analyze({
+    dir: 'src',
+    files: [{ path: shard.path, lang: shard.lang, content: SHELL_SRC, symbols: shard.symbols, references: shard.references }]
+  })
+  assert.ok(!Array.isArray(result))
+  assert.deepEqual(result.facts, [
+    { symbol: 'src/shell.ts#shell', name: 'exec', value: ['child_process.exec'] },
+    { symbol: 'src/shell.ts#shell', name: 'weak_crypto', value: ['crypto.createHash'] },
+    { symbol: 'src/shell.ts#find', name: 'sql_concat', value: ['db.query'] },
+    { symbol: 'src/shell.ts#agent', name: 'insecure_transport', value: ['rejectUnauthorized: false'] }
+  ])
+  assert.deepEqual(result.diagnostics?.[0], {
+    path: 'src/shell.ts',
+    line: 4,
+    column: 6,
+    message: 'child_process.exec runs a command',
+    rule: 'exec',
+    severity: 'warning'
+  })
+})
+
+test('security-audit: calls are grouped by package with the callers that reach them', async () => {
+  const index = createIndex(FILES)
+  const report = await auditSecurity(index, async relPath => SOURCES[relPath] ?? null, { categories: ['exec'] })
+  assert.deepEqual(report.map(p => [p.package, p.calls, p.categories]), [['src', 2, { exec: 2 }]])
+  const shellOut = report[0].findings.find(f => f.api === 'child_process.exec')!
+  assert.equal(shellOut.symbol, 'src/shell.ts#shell')
+  assert.deepEqual(shellOut.callers, [['src/main.ts#main', 'src/api/handler.ts#handle']])
+
+  const shallow = await auditSecurity(index, async relPath => SOURCES[relPath] ?? null, { categories: ['exec'], depth: 1 })
+  assert.deepEqual(shallow[0].findings.find(f => f.api === 'child_process.exec')!.callers, [['src/api/handler.ts#handle']])
+
+  const scoped = await auditSecurity(index, async relPath => SOURCES[relPath] ?? null, { packages: ['./src/api/...'] })
+  assert.deepEqual(scoped, [])
+})
@@ -0,0 +1,368 @@
+/**
+ * Security Audit Module
+ * An inventory of calls into APIs that deserve a security review: running
+ * commands (child_process, subprocess, os.system, Process.Start), evaluating
+ * code or deserializing objects (eval, vm, pickle, yaml.load, Assembly.Load),
+ * weak hashes and ciphers (MD5, SHA-1, DES, RC4), plain HTTP and turned-off
+ * certificate checks, and SQL built by concatenating or interpolating
+ * strings. A call is recognized from the index's references and the file's
+ * imports: `cp.exec()` counts when cp is child_process and `exec()` when it
+ * was imported from it, so RegExp#exec does not. The pass is lexical; it
+ * points reviewers at code rather than proving it unsafe.
+ *
+ * As an analyzer (analyzer: security) it records every call as a warning of
+ * its file (indexer diagnostics --analyzer=security --rule=exec) and, on the
+ * innermost declaration around it, the APIs it calls per category
+ * (facts.security.exec = ["child_process.exec"], --fact=security.exec).
+ * indexer audit groups the calls by package, each with the chains of
+ * callers that reach it through the call graph.
+ */
+
+import { isLocalSymbol } from './local-scopes.js'
+import { packageOf } from './import-graph.js'
+import { matchesPackage } from './symbol-query.js'
+import { CALLABLE_KINDS } from './call-graph.js'
+import { mapConcurrent } from './parse-pool.js'
+import { readConcurrency } from './index-limits.js'
+import type { Analyzer, AnalyzerDiagnostic, AnalyzerFact } from './analyzers.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol, SymbolReference } from '../types/index.js'
+
+export type SensitiveCategory = 'exec' | 'dynamic_code' | 'weak_crypto' | 'insecure_transport' | 'sql_concat'
+
+export const SENSITIVE_CATEGORIES: SensitiveCategory[] = ['exec', 'dynamic_code', 'weak_crypto', 'insecure_transport', 'sql_concat']
+
+// What a call in each category does, for diagnostics
+const CATEGORY_MESSAGES: Record<SensitiveCategory, string> = {
+  exec: 'runs a command',
+  dynamic_code: 'evaluates code or deserializes objects',
+  weak_crypto: 'uses a weak hash or cipher',
+  insecure_transport: 'talks without TLS or without checking certificates',
+  sql_concat: 'runs SQL built from concatenated or interpolated strings'
+}
+
+// Part of the analyzer's version: changing the rules re-analyzes every file
+const RULES_VERSION = '1'
+
+// Caller chains are followed this far up, and this many are kept per call, unless asked
+const DEFAULT_CHAIN_DEPTH = 4
+const DEFAULT_CHAINS = 3
+// Partial chains explored per call at most, so a function called from everywhere stays cheap
+const MAX_PARTIAL_CHAINS = 10_000
+
+type LanguageGroup = 'js' | 'python' | 'csharp'
+
+const LANGUAGE_GROUPS: Record<string, LanguageGroup> = { javascript: 'js', typescript: 'js', python: 'python', csharp: 'csharp' }
+
+export interface SensitiveCall {
+  category: SensitiveCategory
+  api: string // child_process.exec, hashlib.md5, Process.Start, rejectUnauthorized: false
+  path: string
+  line: number
+  column: number
+}
+
+interface CallRule {
+  category: SensitiveCategory
+  lang: LanguageGroup
+  names: string[]
+  // Whose function it is: a module's (called on its namespace or imported by
+  // name), a class's (called on it; '*' for any receiver), else a global's
+  module?: string
+  receiver?: string
+  args?: (call: string) => boolean // the call, from its name to the closing parenthesis
+}
+
+interface LineRule {
+  category: SensitiveCategory
+  lang: LanguageGroup
+  api: string
+  pattern: RegExp
+}
+
+// A string literal argument matching a pattern
+function literalArg(pattern: RegExp): (call: string) => boolean {
+  return call => [...call.matchAll(/(["'`])((?:\\.|(?!\1).)*)\1/g)].some(m => pattern.test(m[2]))
+}
+
+const SQL_STATEMENT = /\b(select\b[\s\S]*\bfrom|insert\s+into|update\b[\s\S]*\bset|delete\s+from)\b/i
+// ${x}, f"...", $"...", "..." + x, x + "...", "..." % x and "...".format(x)
+const STRING_BUILDING = /\$\{|\b[fF]["']|\$@?"|["'`]\s*\+|\+\s*["'`]|["']\s*%\s*[\w(]|["']\.format\(/
+
+function builtSql(call: string): boolean {
+  return SQL_STATEMENT.test(call) && STRING_BUILDING.test(call)
+}
+
+const CALL_RULES: CallRule[] = [
+  { category: 'exec', lang: 'js', module: 'child_process', names: ['exec', 'execSync', 'execFile', 'execFileSync', 'spawn', 'spawnSync', 'fork'] },
+  { category: 'dynamic_code', lang: 'js', names: ['eval'] },
+  { category: 'dynamic_code', lang: 'js', module: 'vm', names: ['runInThisContext', 'runInNewContext', 'runInContext', 'compileFunction'] },
+  { category: 'weak_crypto', lang: 'js', module: 'crypto', names: ['createHash', 'createHmac'], args: literalArg(/^(md4|md5|sha-?1|ripemd-?160)$/i) },
+  { category: 'weak_crypto', lang: 'js', module: 'crypto', names: ['createCipher', 'createDecipher', 'createCipheriv', 'createDecipheriv'], args: literalArg(/^(des|rc2|rc4|bf|blowfish)\b|-ecb$|^des-/i) },
+  { category: 'insecure_transport', lang: 'js', module: 'http', names: ['createServer', 'request', 'get'] },
+  { category: 'sql_concat', lang: 'js', receiver: '*', names: ['query', 'execute', 'raw', '$queryRawUnsafe', '$executeRawUnsafe'], args: builtSql },
+
+  { category: 'exec', lang: 'python', module: 'subprocess', names: ['run', 'call', 'check_call', 'check_output', 'Popen', 'getoutput', 'getstatusoutput'] },
+  { category: 'exec', lang: 'python', module: 'os', names: ['system', 'popen', 'execl', 'execlp', 'execv', 'execve', 'execvp', 'spawnl', 'spawnv'] },
+  { category: 'dynamic_code', lang: 'python', names: ['eval', 'exec'] },
+  { category: 'dynamic_code', lang: 'python', module: 'pickle', names: ['load', 'loads'] },
+  { category: 'dynamic_code', lang: 'python', module: 'marshal', names: ['load', 'loads'] },
+  { category: 'dynamic_code', lang: 'python', module: 'yaml', names: ['unsafe_load', 'full_load'] },
+  { category: 'dynamic_code', lang: 'python', module: 'yaml', names: ['load'], args: call => !/Loader\s*=\s*(yaml\.)?(Safe|Base)Loader/.test(call) },
+  { category: 'weak_crypto', lang: 'python', module: 'hashlib', names: ['md5', 'sha1'] },
+  { category: 'weak_crypto', lang: 'python', module: 'hashlib', names: ['new'], args: literalArg(/^(md4|md5|sha1)$/i) },
+  { category: 'insecure_transport', lang: 'python', module: 'ssl', names: ['_create_unverified_context'] },
+  { category: 'insecure_transport', lang: 'python', module: 'requests', names: ['get', 'post', 'put', 'patch', 'delete', 'head', 'request'], args: call => /\bverify\s*=\s*False\b/.test(call) },
+  { category: 'insecure_transport', lang: 'python', module: 'urllib.request', names: ['urlopen'], args: literalArg(/^http:\/\//) },
+  { category: 'sql_concat', lang: 'python', receiver: '*', names: ['execute', 'executemany', 'executescript', 'raw'], args: builtSql },
+
+  { category: 'exec', lang: 'csharp', receiver: 'Process', names: ['Start'] },
+  { category: 'dynamic_code', lang: 'csharp', receiver: 'Assembly', names: ['Load', 'LoadFrom', 'LoadFile', 'UnsafeLoadFrom'] },
+  { category: 'weak_crypto', lang: 'csharp', receiver: 'MD5', names: ['Create', 'HashData'] },
+  { category: 'weak_crypto', lang: 'csharp', receiver: 'SHA1', names: ['Create', 'HashData'] },
+  { category: 'weak_crypto', lang: 'csharp', receiver: 'DES', names: ['Create'] },
+  { category: 'weak_crypto', lang: 'csharp', receiver: 'RC2', names: ['Create'] },
+  { category: 'sql_concat', lang: 'csharp', receiver: '*', names: ['ExecuteSqlRaw', 'ExecuteSqlRawAsync', 'FromSqlRaw', 'ExecuteSqlCommand'], args: builtSql }
+]
+
+const LINE_RULES: LineRule[] = [
+  { category: 'insecure_transport', lang: 'js', api: 'rejectUnauthorized: false', pattern: /\brejectUnauthorized\s*:\s*false\b/ },
+  { category: 'insecure_transport', lang: 'js', api: 'NODE_TLS_REJECT_UNAUTHORIZED=0', pattern: /\bNODE_TLS_REJECT_UNAUTHORIZED\b.*=\s*["'`]?0\b/ },
+  { category: 'insecure_transport', lang: 'python', api: 'ssl.CERT_NONE', pattern: /\bssl\.CERT_NONE\b/ },
+  { category: 'insecure_transport', lang: 'csharp', api: 'certificate validation callback returning true', pattern: /\bServerCertificate(Custom)?ValidationCallback\b[^;]*=>\s*true\b/ },
+  { category: 'insecure_transport', lang: 'csharp', api: 'DangerousAcceptAnyServerCertificateValidator', pattern: /\bDangerousAcceptAnyServerCertificateValidator\b/ }
+]
+
+// Comment lines are not searched by line rules
+const COMMENT_LINE: Record<LanguageGroup, RegExp> = { js: /^\s*(\/\/|\/\*|\*)/, python: /^\s*#/, csharp: /^\s*(\/\/|\/\*|\*)/ }
+
+interface ModuleBindings {
+  namespaces: Map<string, string> // local name -> module
+  names: Map<string, { module: string, name: string }> // local name -> the module export it is
+}
+
+// `a as b` (import) or `a: b` (destructuring) -> [a, b]
+function bindingNames(list: string, separator: RegExp): [string, string][] {
+  return list.split(',').map(s => s.replace(/^\s*type\s+/, '').trim()).filter(Boolean).map(s => {
+    const [name, local] = s.split(separator).map(p => p.trim())
+    return [name, local || name]
+  })
+}
+
+function jsBindings(content: string): ModuleBindings {
+  const bindings: ModuleBindings = { namespaces: new Map(), names: new Map() }
+  const bind = (module: string, defaultName?: string, namespace?: string, named?: string, separator = /\s+as\s+/) => {
+    module = module.replace(/^node:/, '')
+    // A CommonJS module's default export is the module itself
+    for (const local of [defaultName, namespace]) if (local) bindings.namespaces.set(local, module)
+    for (const [name, local] of bindingNames(named || '', separator)) bindings.names.set(local, { module, name })
+  }
+  for (const m of content.matchAll(/\bimport\s+(?:type\s+)?(?:([\w$]+)\s*,?\s*)?(?:\*\s*as\s+([\w$]+)|\{([^}]*)\})?\s*from\s*["']([^"']+)["']/g)) {
+    bind(m[4], m[1], m[2], m[3])
+  }
+  for (const m of content.matchAll(/\b(?:const|let|var)\s+(?:([\w$]+)|\{([^}]*)\})\s*=\s*require\(\s*["']([^"']+)["']\s*\)/g)) {
+    bind(m[3], m[1], undefined, m[2], /\s*:\s*/)
+  }
+  return bindings
+}
+
+function pythonBindings(content: string): ModuleBindings {
+  const bindings: ModuleBindings = { namespaces: new Map(), names: new Map() }
+  for (const m of content.matchAll(/^[ \t]*import[ \t]+([\w., \t]+)$/gm)) {
+    for (const [module, local] of bindingNames(m[1], /\s+as\s+/)) bindings.namespaces.set(local, module)
+  }
+  for (const m of content.matchAll(/^[ \t]*from[ \t]+([\w.]+)[ \t]+import[ \t]+(\([^)]*\)|[^\n#]+)/gm)) {
+    for (const [name, local] of bindingNames(m[2].replace(/[()]/g, ''), /\s+as\s+/)) bindings.names.set(local, { module: m[1], name })
+  }
+  return bindings
+}
+
+// Module a receiver names: a namespace import, or a submodule imported by name (from urllib import request)
+function receiverModule(bindings: ModuleBindings, receiver: string): string | undefined {
+  const named = bindings.names.get(receiver)
+  return bindings.namespaces.get(receiver) ?? (named ? `${named.module}.${named.name}` : undefined)
+}
+
+// The call a reference makes, from its name to the parenthesis closing its arguments
+function callText(lines: string[], line: number, column: number): string {
+  let text = ''
+  let depth = 0
+  for (let i = line - 1; i < lines.length && i < line + 9; i++) {
+    const part = i === line - 1 ? lines[i].slice(column - 1) : lines[i]
+    for (let j = 0; j < part.length; j++) {
+      if (part[j] === '(') depth++
+      else if (part[j] === ')' && --depth === 0) return text + part.slice(0, j + 1)
+    }
+    text += `${part}\n`
+  }
+  return text
+}
+
+// The API a call reference is, if a rule of its language matches it
+function matchCall(rule: CallRule, ref: SymbolReference, bindings: ModuleBindings): string | null {
+  if (rule.module) {
+    if (ref.receiver) {
+      return receiverModule(bindings, ref.receiver) === rule.module && rule.names.includes(ref.name) ? `${rule.module}.${ref.name}` : null
+    }
+    const imported = bindings.names.get(ref.name)
+    return imported?.module === rule.module && rule.names.includes(imported.name) ? `${rule.module}.${imported.name}` : null
+  }
+  if (!rule.names.includes(ref.name)) return null
+  if (rule.receiver === '*') return ref.receiver ? `${ref.receiver}.${ref.name}` : ref.name
+  if (rule.receiver) {
+    return ref.receiver === rule.receiver || ref.receiver?.endsWith(`.${rule.receiver}`) ? `${rule.receiver}.${ref.name}` : null
+  }
+  // A global, unless something of the same name was imported
+  return !ref.receiver && !bindings.names.has(ref.name) ? ref.name : null
+}
+
+/**
+ * Calls of a file into sensitive APIs, in source order
+ */
+export function findSensitiveCalls(file: { path: string, lang: string, content: string, references: SymbolReference[] }): SensitiveCall[] {
+  const lang = LANGUAGE_GROUPS[file.lang]
+  if (!lang) return []
+  const bindings = lang === 'js' ? jsBindings(file.content) : lang === 'python' ? pythonBindings(file.content) : { namespaces: new Map(), names: new Map() }
+  const lines = file.content.split(/\r?\n/)
+  const calls: SensitiveCall[] = []
+  const rules = CALL_RULES.filter(r => r.lang === lang)
+  for (const ref of file.references) {
+    if (!ref.call) continue
+    for (const rule of rules) {
+      const api = matchCall(rule, ref, bindings)
+      if (!api) continue
+      if (rule.args && !rule.args(callText(lines, ref.line, ref.column || 1))) continue
+      calls.push({ category: rule.category, api, path: file.path, line: ref.line, column: ref.column || 1 })
+      break
+    }
+  }
+  const lineRules = LINE_RULES.filter(r => r.lang === lang)
+  lines.forEach((text, i) => {
+    if (COMMENT_LINE[lang].test(text)) return
+    for (const rule of lineRules) {
+      const match = rule.pattern.exec(text)
+      if (match) calls.push({ category: rule.category, api: rule.api, path: file.path, line: i + 1, column: match.index + 1 })
+    }
+  })
+  return calls.sort((a, b) => a.line - b.line || a.column - b.column)
+}
+
+// Innermost declaration around a line that passes a filter
+function enclosing(symbols: IndexedSymbol[], line: number, accept: (sym: IndexedSymbol) => boolean): IndexedSymbol | undefined {
+  let best: IndexedSymbol | undefined
+  for (const sym of symbols) {
+    if (!accept(sym) || sym.line > line || line > (sym.end_line ?? sym.line)) continue
+    if (!best || sym.line > best.line || (sym.line === best.line && sym.end_line < best.end_line)) best = sym
+  }
+  return best
+}
+
+/**
+ * The security pass as an analyzer (analyzer: security)
+ */
+export function securityAnalyzer(): Analyzer {
+  return {
+    name: 'security',
+    version: RULES_VERSION,
+    languages: Object.keys(LANGUAGE_GROUPS),
+    analyze: (pkg) => {
+      const diagnostics: AnalyzerDiagnostic[] = []
+      const facts: AnalyzerFact[] = []
+      for (const file of pkg.files) {
+        const apis = new Map<string, Map<SensitiveCategory, Set<string>>>()
+        for (const call of findSensitiveCalls(file)) {
+          diagnostics.push({
+            path: call.path,
+            line: call.line,
+            column: call.column,
+            message: `${call.api} ${CATEGORY_MESSAGES[call.category]}`,
+            rule: call.category,
+            severity: 'warning'
+          })
+          const owner = enclosing(file.symbols, call.line, sym => !isLocalSymbol(sym))
+          if (!owner) continue
+          const ofOwner = apis.get(owner.id) || new Map<SensitiveCategory, Set<string>>()
+          ofOwner.set(call.category, (ofOwner.get(call.category) || new Set()).add(call.api))
+          apis.set(owner.id, ofOwner)
+        }
+        for (const [symbol, categories] of apis) {
+          for (const [category, names] of categories) facts.push({ symbol, name: category, value: [...names].sort() })
+        }
+      }
+      return { facts, diagnostics }
+    }
+  }
+}
+
+export interface AuditFinding extends SensitiveCall {
+  symbol: string | null // ID of the function or method making the call, null at the top level of a file
+  callers: string[][] // chains of caller IDs reaching that function, outermost first
+}
+
+export interface PackageAudit {
+  package: string // directory, '.' for the root
+  calls: number
+  categories: Partial<Record<SensitiveCategory, number>>
+  findings: AuditFinding[]
+}
+
+export interface AuditOptions {
+  packages?: string[] // package patterns (./lib/...) the calls are in
+  categories?: SensitiveCategory[]
+  depth?: number // callers followed up from each call (4 by default)
+  chains?: number // caller chains kept per call (3 by default)
+}
+
+/**
+ * Chains of callers that reach a function, shortest first: each ends at a
+ * function nothing calls, or after `depth` callers
+ */
+export function callerChains(index: SymbolIndex, fnId: string, depth = DEFAULT_CHAIN_DEPTH, limit = DEFAULT_CHAINS): string[][] {
+  const graph = index.callGraph()
+  const chains: string[][] = []
+  const queue: string[][] = [[fnId]]
+  while (queue.length > 0 && chains.length < limit) {
+    const chain = queue.shift()!
+    const callers = [...new Set((graph.callers.get(chain[0]) || []).map(e => e.caller))].filter(id => !chain.includes(id))
+    if (callers.length === 0 || chain.length > depth) {
+      if (chain.length > 1) chains.push(chain.slice(0, -1))
+      continue
+    }
+    for (const caller of callers) {
+      if (queue.length < MAX_PARTIAL_CHAINS) queue.push([caller, ...chain])
+    }
+  }
+  return chains
+}
+
+/**
+ * Calls into sensitive APIs across the index, by package
+ * @param readSource - Content of an indexed file; files it cannot read are skipped
+ */
+export async function auditSecurity(
+  index: SymbolIndex,
+  readSource: (relPath: string) => Promise<string | null>,
+  options: AuditOptions = {}
+): Promise<PackageAudit[]> {
+  const shards = index.listShards().filter(shard => LANGUAGE_GROUPS[shard.lang] &&
+    (!options.packages?.length || options.packages.some(p => matchesPackage(shard.path, p))))
+  const found = await mapConcurrent(shards, readConcurrency(), async (shard) => {
+    const content = await readSource(shard.path)
+    return content === null ? [] : findSensitiveCalls({ ...shard, content })
+  })
+
+  const packages = new Map<string, PackageAudit>()
+  shards.forEach((shard, i) => {
+    for (const call of found[i]) {
+      if (options.categories?.length && !options.categories.includes(call.category)) continue
+      const fn = enclosing(shard.symbols, call.line, sym => CALLABLE_KINDS.has(sym.kind) && !isLocalSymbol(sym))
+      const dir = packageOf(call.path)
+      const audit = packages.get(dir) || { package: dir, calls: 0, categories: {}, findings: [] }
+      audit.calls++
+      audit.categories[call.category] = (audit.categories[call.category] || 0) + 1
+      audit.findings.push({ ...call, symbol: fn?.id ?? null, callers: fn ? callerChains(index, fn.id, options.depth, options.chains) : [] })
+      packages.set(dir, audit)
+    }
+  })
+  return [...packages.values()].sort((a, b) => a.package.localeCompare(b.package))
+}
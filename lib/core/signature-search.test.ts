@@ -0,0 +1,94 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { querySymbols } from './symbol-query.js'
+import { SignatureMatcher, normalizeType, parseSignatureQuery } from './signature-search.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/users.ts': `
+export class Entity {}
+export class User extends Entity {}
+export async function findUser(ctx: Context, name: string): Promise<User | undefined> { return undefined }
+export function findByAge(age: number, ctx: Context): User { return new User() }
+export function first<T>(items: T[]): T { return items[0] }
+export function join(sep: string, ...parts: string[]): string { return parts.join(sep) }
+export function save(entity: Entity, force = false): void {}
+export const rename = (user: User, name: string): User => user
+export class Repo {
+  constructor(private readonly ctx: Context) {}
+  async load(id: string): Promise<User> { return new User() }
+}
+`,
+  'app/users.py': [
+    { name: 'find_user', kind: 'function', line: 1, end_line: 2, signature: 'def find_user(ctx: Context, name: str) -> Optional[User]' },
+    { name: 'count', kind: 'function', line: 3, end_line: 4, signature: 'def count(self, *names: str) -> int' }
+  ],
+  'Game/Users.cs': [
+    { name: 'UserStore.FindAsync', kind: 'method', line: 5, end_line: 9, signature: '[HttpGet("{name}")] public async Task<User> FindAsync(Context ctx, string name, int limit = 10)' }
+  ]
+}
+
+const search = (index: SymbolIndex, sig: string, options = {}) => {
+  const matcher = new SignatureMatcher(index, sig, options)
+  return querySymbols(index, { filter: sym => matcher.matches(sym) }).map(s => s.name)
+}
+
+test('signature-search: normalizes types across languages', () => {
+  assert.equal(normalizeType('context.Context'), 'Context')
+  assert.equal(normalizeType('Promise<User | undefined>'), 'User')
+  assert.equal(normalizeType('[]*User'), 'User[]')
+  assert.equal(normalizeType('List<string>'), 'string[]')
+  assert.equal(normalizeType('list[str]'), 'string[]')
+  assert.equal(normalizeType('Optional[int]'), 'number')
+  assert.equal(normalizeType('Map<string,  number>'), 'Map<string,number>')
+  assert.equal(normalizeType('interface{}'), 'any')
+})
+
+test('signature-search: parses Go, TypeScript and Hoogle style queries', () => {
+  assert.deepEqual(parseSignatureQuery('func(context.Context, string) (User, error)'), {
+    params: [{ type: 'Context' }, { type: 'string' }],
+    returns: 'User'
+  })
+  assert.deepEqual(parseSignatureQuery('func Find(ctx context.Context, names ...string) error'), {
+    params: [{ type: 'Context' }, { type: 'string', rest: true }],
+    returns: 'void'
+  })
+  assert.deepEqual(parseSignatureQuery('(id: string) => Promise<User>'), { params: [{ type: 'string' }], returns: 'User' })
+  assert.deepEqual(parseSignatureQuery('string -> number -> User'), {
+    params: [{ type: 'string' }, { type: 'number' }],
+    returns: 'User'
+  })
+  assert.throws(() => parseSignatureQuery('func(string'), /Unbalanced/)
+})
+
+test('signature-search: matches in order across languages, skipping optional parameters', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(search(index, 'func(context.Context, string) (User, error)'), [
+    'UserStore.FindAsync', 'find_user', 'findUser'
+  ])
+  assert.deepEqual(search(index, '(string) => User'), ['Repo.load'])
+  assert.deepEqual(search(index, '(Entity) => void'), ['save'])
+  assert.deepEqual(search(index, '(User, string) => User'), ['rename'])
+  assert.deepEqual(search(index, 'Context -> Repo'), ['Repo.constructor'])
+})
+
+test('signature-search: unifies type parameters and fills rest parameters', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(search(index, '([]User) User'), ['first'])
+  assert.deepEqual(search(index, '([]User) string'), [])
+  assert.deepEqual(search(index, '(string, string, string) => string'), ['join'])
+  assert.deepEqual(search(index, '(string) => string'), ['join'])
+  assert.deepEqual(search(index, 'func(...string) int'), ['count'])
+  assert.deepEqual(search(index, '(_, string) => _'), ['UserStore.FindAsync', 'find_user', 'count', 'findUser', 'join', 'rename'])
+})
+
+test('signature-search: unordered and assignable modes', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(search(index, 'func(number, Context) User'), ['findByAge'])
+  assert.deepEqual(search(index, 'func(Context, number) User'), [])
+  assert.deepEqual(search(index, 'func(Context, number) User', { unordered: true }), ['findByAge'])
+  assert.deepEqual(search(index, '(User) => void'), [])
+  assert.deepEqual(search(index, '(User) => void', { assignable: true }), ['save'])
+  assert.deepEqual(search(index, '(number, Context) => Entity', { assignable: true }), ['findByAge'])
+})
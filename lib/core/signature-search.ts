@@ -0,0 +1,370 @@
+/**
+ * Signature Search Module
+ * Hoogle-style "find by type": functions whose parameter and result types
+ * match a signature, written Go style (func(context.Context, string) (User, error)),
+ * TypeScript style ((string, number) => User) or Hoogle style (string -> User).
+ *
+ * Types are normalized before comparing, so one query reads the same across
+ * languages: qualifiers are dropped (context.Context -> Context), Promise<T>
+ * and Task<T> unwrap to T, []T / Array<T> / List<T> / list[T] are T[],
+ * int / double / float64 are number, str is string, interface{} / unknown /
+ * object are any, nullability (T | undefined, T?, Optional[T]) is dropped,
+ * and Go's trailing error result is ignored. The declared type parameters of
+ * a function unify with whatever the query puts in their place, and "_"
+ * matches any type.
+ *
+ * Matching is exact and in order by default; unordered ignores parameter
+ * order, and assignable also accepts a query argument a parameter's type
+ * accepts (a subclass, a union member, anything for any) and a result that
+ * can be assigned to the queried one.
+ */
+
+import { CALLABLE_KINDS } from './call-graph.js'
+import { TYPE_KINDS } from './implementations.js'
+import { shortName } from './symbol-index.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export interface SignatureParam {
+  type: string // normalized
+  optional?: boolean // has a default or is marked optional
+  rest?: boolean // ...rest / params / *args: takes any number of its element type
+}
+
+export interface ParsedSignature {
+  params: SignatureParam[]
+  returns: string // normalized; void when nothing is returned
+}
+
+export interface SignatureMatchOptions {
+  unordered?: boolean
+  assignable?: boolean
+}
+
+// Wildcard a query may use for any type
+const WILDCARD = '_'
+// Most parameters a query may list; unordered matching tries permutations
+const MAX_QUERY_PARAMS = 10
+
+const BRACKETS: Record<string, string> = { '(': ')', '[': ']', '{': '}', '<': '>' }
+const CLOSING = new Set(Object.values(BRACKETS))
+
+const TYPE_ALIASES: Record<string, string> = {
+  str: 'string', String: 'string', char: 'string', rune: 'string',
+  bool: 'boolean', Boolean: 'boolean',
+  Number: 'number', int: 'number', uint: 'number', long: 'number', ulong: 'number', short: 'number', ushort: 'number',
+  byte: 'number', sbyte: 'number', float: 'number', double: 'number', decimal: 'number', bigint: 'number',
+  int8: 'number', int16: 'number', int32: 'number', int64: 'number', uint8: 'number', uint16: 'number',
+  uint32: 'number', uint64: 'number', float32: 'number', float64: 'number', Int32: 'number', Int64: 'number', Double: 'number',
+  unknown: 'any', object: 'any', Object: 'any', Any: 'any', 'interface{}': 'any',
+  None: 'void', undefined: 'void', never: 'void', '()': 'void', Task: 'void', ValueTask: 'void'
+}
+const AWAITED = new Set(['Promise', 'PromiseLike', 'Task', 'ValueTask', 'Awaitable', 'Coroutine'])
+const ARRAYS = new Set([
+  'Array', 'ReadonlyArray', 'List', 'IList', 'IReadOnlyList', 'ICollection', 'IReadOnlyCollection', 'IEnumerable',
+  'Iterable', 'list', 'Sequence', 'Set', 'set', 'HashSet', 'ISet', 'frozenset'
+])
+const NULLISH = new Set(['undefined', 'null', 'None', 'nil'])
+const MODIFIERS = new Set([
+  'public', 'private', 'protected', 'internal', 'static', 'async', 'virtual', 'override', 'abstract', 'sealed',
+  'extern', 'unsafe', 'new', 'partial', 'readonly', 'declare', 'export', 'default', 'function', 'def', 'get', 'set'
+])
+const PARAM_MODIFIERS = /^(?:(?:public|private|protected|readonly|override|this|ref|out|in|scoped)\s+)+/
+
+/**
+ * Split at a separator outside brackets: "Map<a, b>, c" -> ["Map<a, b>", "c"].
+ * The ">" of "=>" and "->" is not a bracket.
+ */
+export function splitTopLevel(text: string, separator: string): string[] {
+  const parts: string[] = []
+  let depth = 0
+  let start = 0
+  for (let i = 0; i < text.length; i++) {
+    const ch = text[i]
+    if (depth === 0 && text.startsWith(separator, i) && !(separator === '=' && /[>=]/.test(text[i + 1] || ''))) {
+      parts.push(text.slice(start, i))
+      start = i + separator.length
+      i += separator.length - 1
+    } else if (ch === '>' && (text[i - 1] === '=' || text[i - 1] === '-')) {
+      continue
+    } else if (BRACKETS[ch]) {
+      depth++
+    } else if (CLOSING.has(ch)) {
+      depth = Math.max(depth - 1, 0)
+    }
+  }
+  parts.push(text.slice(start))
+  return parts.map(p => p.trim())
+}
+
+// Index of the bracket closing the one at `open`, or -1
+function closingIndex(text: string, open: number): number {
+  let depth = 0
+  for (let i = open; i < text.length; i++) {
+    const ch = text[i]
+    if (ch === '>' && (text[i - 1] === '=' || text[i - 1] === '-')) continue
+    if (BRACKETS[ch]) depth++
+    else if (CLOSING.has(ch) && --depth === 0) return i
+  }
+  return -1
+}
+
+// Decorators and attributes ahead of a declaration: @Get(':id'), [HttpGet("{id}")]
+const LEADING_DECORATORS = /^(?:@[\w.]+(?:\((?:[^()]|\([^()]*\))*\))?\s*|\[(?:[^[\]]|\[[^\]]*\])*\]\s*)+/
+
+// First "(" outside type parameters: "save<T extends X>(a: T)" -> 4
+function paramListStart(text: string): number {
+  let depth = 0
+  for (let i = 0; i < text.length; i++) {
+    const ch = text[i]
+    if (ch === '<') depth++
+    else if (ch === '>' && text[i - 1] !== '=' && text[i - 1] !== '-') depth--
+    else if (ch === '(' && depth <= 0) return i
+  }
+  return -1
+}
+
+/**
+ * One spelling per type across languages (see the module doc)
+ */
+export function normalizeType(raw: string): string {
+  let t = raw.trim().replace(/\s+/g, ' ').replace(/\s*([<>[\](),|&:?])\s*/g, '$1').replace(/=>/g, ' => ')
+  t = t.replace(/^(?:readonly |const |ref |out |in |params |\*+|&)+/, '').trim()
+  if (t === '') return 'any'
+  if (TYPE_ALIASES[t]) return TYPE_ALIASES[t]
+  if (t.startsWith('(') && closingIndex(t, 0) === t.length - 1) return normalizeType(t.slice(1, -1))
+
+  const union = splitTopLevel(t, '|').filter(Boolean)
+  if (union.length > 1) {
+    const members = [...new Set(union.filter(m => !NULLISH.has(m)).map(normalizeType))].sort()
+    return members.length === 0 ? 'void' : members.join('|')
+  }
+  if (t.endsWith('?') && !t.includes(' ')) return normalizeType(t.slice(0, -1))
+  if (t.endsWith('[]')) return `${normalizeType(t.slice(0, -2))}[]`
+  if (t.startsWith('[]')) return `${normalizeType(t.slice(2))}[]`
+  if (t.startsWith('...')) return `${normalizeType(t.slice(3))}[]`
+  if (t.startsWith('[') && t.endsWith(']')) return `[${splitTopLevel(t.slice(1, -1), ',').map(normalizeType).join(',')}]`
+
+  const generic = /^([A-Za-z_$][\w$.]*)([<[])([\s\S]*)[>\]]$/.exec(t)
+  if (generic) {
+    const name = generic[1].slice(generic[1].lastIndexOf('.') + 1)
+    const args = splitTopLevel(generic[3], ',').map(normalizeType)
+    if (AWAITED.has(name)) return args[args.length - 1] ?? 'void'
+    if (ARRAYS.has(name) && args.length === 1) return `${args[0]}[]`
+    if (name === 'Optional' && args.length === 1) return args[0]
+    if ((name === 'tuple' || name === 'Tuple') && args.length > 0) return `[${args.join(',')}]`
+    if (name === 'Union' && args.length > 0) return normalizeType(generic[3].split(',').join('|'))
+    return `${TYPE_ALIASES[name] || name}<${args.join(',')}>`
+  }
+  // Qualified names: context.Context, System.String, typing.Any
+  const bare = /^[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)+$/.test(t) ? t.slice(t.lastIndexOf('.') + 1) : t
+  return TYPE_ALIASES[bare] || bare
+}
+
+// Go results: (User, error) -> User; several results become a tuple
+function resultType(parts: string[]): string {
+  const types = parts.map(normalizeType).filter(t => t !== 'error' && t !== 'void')
+  if (types.length === 0) return 'void'
+  return types.length === 1 ? types[0] : `[${types.join(',')}]`
+}
+
+function queryParam(raw: string, goOrder: boolean): SignatureParam {
+  let text = raw.trim()
+  const colon = splitTopLevel(text, ':')
+  if (colon.length > 1) text = colon.slice(1).join(':')
+  else if (/^[A-Za-z_]\w* \S/.test(text) && !/^(?:chan|map|func|struct|readonly)\b/.test(text)) {
+    // Go puts the name first (ctx context.Context), C# last (string name)
+    const words = splitTopLevel(text, ' ')
+    text = goOrder ? words.slice(1).join(' ') : words[0]
+  }
+  const rest = text.startsWith('...')
+  return rest ? { type: normalizeType(text.slice(3)), rest } : { type: normalizeType(text) }
+}
+
+/**
+ * Parse a signature query
+ * @throws Error when the text is not a signature
+ */
+export function parseSignatureQuery(text: string): ParsedSignature {
+  let t = text.trim().replace(/\s+/g, ' ')
+  const goOrder = /^func\b/.test(t)
+  t = t.replace(/^(?:func|function|def|fn)\b\s*/, '').replace(/^[A-Za-z_$][\w$]*\s*(?=\()/, '')
+  if (t === '') throw new Error('Empty signature')
+
+  if (t.startsWith('(')) {
+    const close = closingIndex(t, 0)
+    if (close === -1) throw new Error(`Unbalanced parentheses in "${text}"`)
+    const inner = t.slice(1, close).trim()
+    const params = inner === '' ? [] : splitTopLevel(inner, ',').map(p => queryParam(p, goOrder))
+    let rest = t.slice(close + 1).trim().replace(/^(?:=>|->|:)\s*/, '')
+    if (rest.startsWith('(') && closingIndex(rest, 0) === rest.length - 1) rest = rest.slice(1, -1)
+    return { params, returns: rest === '' ? 'void' : resultType(splitTopLevel(rest, ',')) }
+  }
+
+  // Hoogle: string -> number -> User
+  const arrows = splitTopLevel(t, '->')
+  const returns = arrows.pop()!
+  return { params: arrows.map(p => queryParam(p, goOrder)), returns: resultType([returns]) }
+}
+
+function declaredParam(raw: string, lang: string): SignatureParam | null {
+  let text = raw.trim().replace(/^(?:@[\w.]+(?:\([^)]*\))?\s*|\[[^\]]*\]\s*)+/, '')
+  if (text === '' || text === '/' || text === '*') return null
+  if (lang === 'csharp') {
+    const isParams = /^params\s/.test(text)
+    text = text.replace(PARAM_MODIFIERS, '').replace(/^params\s+/, '')
+    const [decl, init] = splitTopLevel(text, '=')
+    const words = splitTopLevel(decl, ' ')
+    const type = normalizeType(words.length > 1 ? words.slice(0, -1).join(' ') : 'any')
+    if (isParams) return { type: type.replace(/\[\]$/, ''), rest: true }
+    return init !== undefined ? { type, optional: true } : { type }
+  }
+
+  text = text.replace(PARAM_MODIFIERS, '')
+  // name?: Type = default, ...rest: Type[], *args, **kwargs
+  const [decl, init] = splitTopLevel(text, '=')
+  const hasDefault = init !== undefined
+  const parts = splitTopLevel(decl, ':')
+  const name = parts[0]
+  if (lang !== 'python' && name === 'this') return null
+  const type = parts.length > 1 ? normalizeType(parts.slice(1).join(':')) : 'any'
+  if (name.startsWith('**')) return { type: 'any', optional: true }
+  if (name.startsWith('...') || name.startsWith('*')) return { type: type.replace(/\[\]$/, ''), rest: true }
+  return hasDefault || name.endsWith('?') ? { type, optional: true } : { type }
+}
+
+/**
+ * Parameter and result types of a function symbol from its declaration
+ * header, or undefined when it has none
+ */
+export function symbolSignature(sym: IndexedSymbol): ParsedSignature | undefined {
+  const header = (sym.signature as string | undefined)?.replace(LEADING_DECORATORS, '')
+  if (!header) return undefined
+  const open = paramListStart(header)
+  const close = open === -1 ? -1 : closingIndex(header, open)
+  if (close === -1) return undefined
+
+  const lang = sym.lang
+  const inner = header.slice(open + 1, close).trim()
+  let params = (inner === '' ? [] : splitTopLevel(inner, ','))
+    .map(p => declaredParam(p, lang))
+    .filter((p): p is SignatureParam => !!p)
+  if (lang === 'python' && /^(?:self|cls)\b/.test(inner)) params = params.slice(1)
+
+  const owner = sym.name.includes('.') ? sym.name.slice(0, sym.name.lastIndexOf('.')) : undefined
+  const after = header.slice(close + 1).trim()
+  let returns: string
+  if (lang === 'csharp') {
+    const words = splitTopLevel(header.slice(0, open).trim(), ' ').filter(w => !MODIFIERS.has(w))
+    // Constructors have no result type and construct their class
+    returns = words.length > 1 ? normalizeType(words[words.length - 2]) : normalizeType(shortName(owner || words[0] || ''))
+  } else if (shortName(sym.name) === 'constructor' && owner) {
+    returns = normalizeType(shortName(owner))
+  } else {
+    const annotation = after.replace(/\s*=>$/, '').replace(/^(?::|->)\s*/, '')
+    returns = annotation === '' ? 'any' : normalizeType(annotation)
+  }
+  return { params, returns }
+}
+
+function escapeRegExp(text: string): string {
+  return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&')
+}
+
+/**
+ * Match a query type against a declared type in which type parameters
+ * stand for any type, binding them consistently across the signature
+ */
+function unify(query: string, declared: string, vars: Set<string>, bindings: Map<string, string>): boolean {
+  if (query === WILDCARD || declared === query) return true
+  if (vars.size === 0) return false
+  const bound: string[] = []
+  const source = declared.split(/([A-Za-z_$][\w$]*)/).map((part, i) => {
+    if (i % 2 === 0 || !vars.has(part)) return escapeRegExp(part)
+    if (bindings.has(part)) return escapeRegExp(bindings.get(part)!)
+    if (bound.includes(part)) return `\\k<${part}>`
+    bound.push(part)
+    return `(?<${part}>.+?)`
+  }).join('')
+  const m = new RegExp(`^${source}$`).exec(query)
+  if (!m) return false
+  for (const v of bound) bindings.set(v, m.groups![v])
+  return true
+}
+
+export class SignatureMatcher {
+  private readonly query: ParsedSignature
+  private readonly subtypeCache = new Map<string, boolean>()
+
+  constructor(private readonly index: SymbolIndex, query: string | ParsedSignature, private readonly options: SignatureMatchOptions = {}) {
+    this.query = typeof query === 'string' ? parseSignatureQuery(query) : query
+    if (this.query.params.length > MAX_QUERY_PARAMS) {
+      throw new Error(`A signature query takes at most ${MAX_QUERY_PARAMS} parameters`)
+    }
+  }
+
+  /** Whether a symbol is a function whose signature matches the query */
+  matches(sym: IndexedSymbol): boolean {
+    if (!CALLABLE_KINDS.has(sym.kind) && !(sym.kind === 'const' && /=>$/.test(sym.signature || ''))) return false
+    const cache = this.index.memo('signatures', () => new Map<string, ParsedSignature | null>())
+    if (!cache.has(sym.id)) cache.set(sym.id, symbolSignature(sym) ?? null)
+    const declared = cache.get(sym.id)
+    if (!declared) return false
+
+    const vars = new Set<string>((sym.type_params || []).map(p => p.name))
+    const bindings = new Map<string, string>()
+    if (!this.matchParams(declared.params, 0, new Set(), 0, vars, bindings)) return false
+    // The declared result has to fit the queried one
+    return unify(this.query.returns, declared.returns, vars, bindings) ||
+      (!!this.options.assignable && this.assignable(declared.returns, this.query.returns))
+  }
+
+  // Parameters from `at` on against the query arguments not used yet
+  // (the next one, `next`, when matching in order)
+  private matchParams(
+    params: SignatureParam[], at: number, used: Set<number>, next: number, vars: Set<string>, bindings: Map<string, string>
+  ): boolean {
+    const args = this.query.params
+    if (at === params.length) return used.size === args.length
+    const param = params[at]
+    const candidates = this.options.unordered
+      ? args.map((_, i) => i).filter(i => !used.has(i))
+      : next < args.length ? [next] : []
+
+    for (const i of candidates) {
+      const attempt = new Map(bindings)
+      // The query argument has to fit the parameter (its element type for rest parameters)
+      const fits = unify(args[i].type, param.type, vars, attempt) ||
+        (!!this.options.assignable && this.assignable(args[i].type, param.type))
+      if (!fits) continue
+      // A rest parameter may take more arguments
+      const stay = param.rest && !args[i].rest ? at : at + 1
+      if (this.matchParams(params, stay, new Set(used).add(i), i + 1, vars, attempt)) {
+        attempt.forEach((v, k) => bindings.set(k, v))
+        return true
+      }
+    }
+    // Optional and rest parameters may be left out
+    return (!!param.optional || !!param.rest) && this.matchParams(params, at + 1, used, next, vars, bindings)
+  }
+
+  // Whether a value of type `from` can be used where `to` is expected
+  private assignable(from: string, to: string): boolean {
+    if (from === to || from === WILDCARD || to === WILDCARD || from === 'any' || to === 'any') return true
+    const sources = splitTopLevel(from, '|')
+    if (sources.length > 1) return sources.every(s => this.assignable(s, to))
+    const targets = splitTopLevel(to, '|')
+    if (targets.length > 1) return targets.some(t => this.assignable(from, t))
+    if (from.endsWith('[]') && to.endsWith('[]')) return this.assignable(from.slice(0, -2), to.slice(0, -2))
+    return this.isSubtype(from, to)
+  }
+
+  private isSubtype(from: string, to: string): boolean {
+    const key = `${from}\0${to}`
+    if (!this.subtypeCache.has(key)) {
+      const types = this.index.findSymbols(from).filter(s => TYPE_KINDS.has(s.kind))
+      this.subtypeCache.set(key, types.some(t => this.index.supertypes(t.id, true).some(h => shortName(h.symbol.name) === to)))
+    }
+    return this.subtypeCache.get(key)!
+  }
+}
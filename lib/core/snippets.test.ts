@@ -0,0 +1,59 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex } from './symbol-index.js'
+import { SnippetCache, parseContextLines, snippetOf } from './snippets.js'
+
+const USER_SRC = 'export class User {\n  save() {}\n  load() {}\n}\n'
+
+test('snippets: lines around a result stop at the ends of the file', () => {
+  const lines = USER_SRC.split('\n')
+  assert.deepEqual(snippetOf(lines, 2, 1), { start_line: 1, lines: ['export class User {', '  save() {}', '  load() {}'] })
+  assert.deepEqual(snippetOf(lines, 1, 0), { start_line: 1, lines: ['export class User {'] })
+  assert.equal(snippetOf(lines, 9, 1), null)
+  assert.equal(parseContextLines('3'), 3)
+  assert.throws(() => parseContextLines('-1'), /whole number/)
+  assert.throws(() => parseContextLines('51'), /from 0 to 50/)
+})
+
+test('snippets: files are read once and again after they are reindexed', async () => {
+  const sources: Record<string, string> = { 'src/user.ts': USER_SRC }
+  const reads: string[] = []
+  const cache = new SnippetCache(async (relPath) => {
+    reads.push(relPath)
+    return sources[relPath] ?? null
+  })
+  const index = new SymbolIndex()
+  index.addFile('src/user.ts', 'typescript', [], 'v1')
+  index.addFile('src/gone.ts', 'typescript', [], 'v1')
+
+  const found = await cache.snippets(index, [
+    { path: 'src/user.ts', line: 2 },
+    { path: 'src/user.ts', line: 3 },
+    { path: 'src/gone.ts', line: 1 }
+  ], 0)
+  assert.deepEqual(found, [{ start_line: 2, lines: ['  save() {}'] }, { start_line: 3, lines: ['  load() {}'] }, null])
+  assert.deepEqual((await cache.snippet(index, 'src/user.ts', 1, 0))?.lines, ['export class User {'])
+  assert.deepEqual(reads, ['src/user.ts', 'src/gone.ts'])
+
+  sources['src/user.ts'] = 'export class Account {}\n'
+  index.addFile('src/user.ts', 'typescript', [], 'v2')
+  assert.deepEqual((await cache.snippet(index, 'src/user.ts', 1, 0))?.lines, ['export class Account {}'])
+  assert.deepEqual(reads, ['src/user.ts', 'src/gone.ts', 'src/user.ts'])
+})
+
+test('snippets: the least recently used files are dropped', async () => {
+  const reads: string[] = []
+  const cache = new SnippetCache(async (relPath) => {
+    reads.push(relPath)
+    return 'line\n'
+  }, 2)
+  const index = new SymbolIndex()
+  for (const relPath of ['a.ts', 'b.ts', 'c.ts']) index.addFile(relPath, 'typescript', [])
+  await cache.snippet(index, 'a.ts', 1, 0)
+  await cache.snippet(index, 'b.ts', 1, 0)
+  await cache.snippet(index, 'a.ts', 1, 0)
+  await cache.snippet(index, 'c.ts', 1, 0)
+  await cache.snippet(index, 'a.ts', 1, 0)
+  await cache.snippet(index, 'b.ts', 1, 0)
+  assert.deepEqual(reads, ['a.ts', 'b.ts', 'c.ts', 'b.ts'])
+})
@@ -0,0 +1,96 @@
+/**
+ * Snippets Module
+ * Lines of source around a query's results, so a UI can preview them
+ * without opening every file itself. Sources are read through the reader
+ * the index was opened with (the working tree, a git revision) and kept,
+ * split into lines, in a small LRU cache. A cached file is read again once
+ * its shard's content hash changes, so an index kept up to date by a
+ * watcher never shows the lines of an older version. Index files carry no
+ * sources, so their results come without snippets.
+ */
+
+import { mapConcurrent } from './parse-pool.js'
+import { readConcurrency } from './index-limits.js'
+import type { SymbolIndex } from './symbol-index.js'
+
+// Lines asked for on either side of a result at most
+export const MAX_CONTEXT_LINES = 50
+// Files kept in memory by a cache, unless configured
+const DEFAULT_CACHED_FILES = 256
+
+export interface Snippet {
+  start_line: number // 1-based line of lines[0]
+  lines: string[]
+}
+
+export type SnippetReader = (relPath: string) => Promise<string | null>
+
+/**
+ * Parse a number of context lines (0 to MAX_CONTEXT_LINES)
+ */
+export function parseContextLines(value: string): number {
+  const lines = Number(value)
+  if (!/^\d+$/.test(value) || lines > MAX_CONTEXT_LINES) {
+    throw new Error(`context must be a whole number of lines from 0 to ${MAX_CONTEXT_LINES}`)
+  }
+  return lines
+}
+
+/**
+ * A line with `context` lines on either side
+ * @param lines - The file's lines
+ * @returns null for a line past the end of the file
+ */
+export function snippetOf(lines: string[], line: number, context: number): Snippet | null {
+  if (line < 1 || line > lines.length) return null
+  const start = Math.max(1, line - context)
+  const end = Math.min(lines.length, line + context)
+  return { start_line: start, lines: lines.slice(start - 1, end) }
+}
+
+/**
+ * Reads the sources of an index's files for snippets, caching the most
+ * recently used
+ */
+export class SnippetCache {
+  // In use order, oldest first; the lines are shared by concurrent readers of a file
+  private readonly files = new Map<string, { hash: string | undefined, lines: Promise<string[] | null> }>()
+
+  constructor(private readonly readSource: SnippetReader, private readonly maxFiles = DEFAULT_CACHED_FILES) {}
+
+  private lines(index: SymbolIndex, filePath: string): Promise<string[] | null> {
+    const hash = index.getFile(filePath)?.hash
+    const cached = this.files.get(filePath)
+    this.files.delete(filePath)
+    const lines = cached && cached.hash === hash
+      ? cached.lines
+      : this.readSource(filePath).then(content => (content === null ? null : content.split(/\r?\n/)), () => null)
+    this.files.set(filePath, { hash, lines })
+    while (this.files.size > this.maxFiles) this.files.delete(this.files.keys().next().value!)
+    return lines
+  }
+
+  /**
+   * Lines around one result
+   * @returns null when the file cannot be read or is shorter than the index says
+   */
+  async snippet(index: SymbolIndex, filePath: string, line: number, context: number): Promise<Snippet | null> {
+    const lines = await this.lines(index, filePath)
+    return lines ? snippetOf(lines, line, context) : null
+  }
+
+  /**
+   * Lines around each of many results, in input order, reading every file once
+   */
+  async snippets(index: SymbolIndex, results: { path: string, line: number }[], context: number): Promise<(Snippet | null)[]> {
+    const paths = [...new Set(results.map(r => r.path))]
+    const read = new Map<string, string[] | null>()
+    await mapConcurrent(paths, readConcurrency(), async (filePath) => {
+      read.set(filePath, await this.lines(index, filePath))
+    })
+    return results.map(r => {
+      const lines = read.get(r.path)
+      return lines ? snippetOf(lines, r.line, context) : null
+    })
+  }
+}
@@ -0,0 +1,69 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { findStrings } from './string-literals.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const REPO_SRC = `import { db } from './database-client'
+
+export class UserRepo {
+  find(id: string) {
+    if (!id) throw new Error('user id is required')
+    return db.query(\`SELECT * FROM users WHERE id = \${id}\`, { 'long-option-key': true })
+  }
+  save(user: User) {
+    if (!user.id) throw new Error('user id is required')
+    return /^[a-z0-9-]+$/i.test(user.id)
+  }
+}
+
+type Mode = 'readonly-mode' | 'short'
+`
+
+const FILES: FixtureFiles = {
+  'src/repo.ts': [
+    { name: 'UserRepo', kind: 'class', line: 3, end_line: 12 },
+    { name: 'UserRepo.find', kind: 'method', line: 4, end_line: 7 },
+    { name: 'UserRepo.save', kind: 'method', line: 8, end_line: 11 },
+    { name: 'user id is required', kind: 'string', literal: 'string', line: 5, column: 31, count: 2 },
+    { name: 'SELECT * FROM users WHERE id = ${}', kind: 'string', literal: 'template', line: 6, column: 21 },
+    { name: '/^[a-z0-9-]+$/i', kind: 'string', literal: 'regexp', line: 10, column: 12 }
+  ],
+  'lib/config.ts': [
+    { name: 'CONFIG_PATH', kind: 'constant', line: 1, end_line: 1 },
+    { name: 'Missing configuration file', kind: 'string', literal: 'string', line: 3, column: 9 }
+  ]
+}
+
+test('string-literals: literals are stored on the shard, not as symbols', () => {
+  const shard = createIndex(FILES).getFile('src/repo.ts')!
+  assert.deepEqual(shard.symbols.map(s => s.name), ['UserRepo', 'UserRepo.find', 'UserRepo.save'])
+  assert.deepEqual(shard.strings![0], { value: 'user id is required', kind: 'string', line: 5, column: 31, count: 2 })
+})
+
+test('string-literals: matches carry their enclosing symbol', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(findStrings(index, { text: 'user' }).map(m => [m.path, m.line, m.symbol?.name]), [
+    ['src/repo.ts', 5, 'UserRepo.find'],
+    ['src/repo.ts', 6, 'UserRepo.find']
+  ])
+  assert.deepEqual(findStrings(index, { text: 'missing', ignoreCase: true }).map(m => [m.value, m.symbol]), [['Missing configuration file', null]])
+  assert.deepEqual(findStrings(index, { text: '^select .* from', regex: true, ignoreCase: true }).map(m => m.kind), ['template'])
+})
+
+test('string-literals: filters by kind, package and length', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(findStrings(index, { kinds: ['regexp'] }).map(m => m.value), ['/^[a-z0-9-]+$/i'])
+  assert.deepEqual(findStrings(index, { packages: ['./lib/...'] }).map(m => m.path), ['lib/config.ts'])
+  assert.deepEqual(findStrings(index, { minLength: 20 }).map(m => m.value), ['Missing configuration file', 'SELECT * FROM users WHERE id = ${}'])
+})
+
+test('string-literals: the extractor keeps distinct data literals above the minimum length', () => {
+  const literals = extractJSSymbols(REPO_SRC, { strings: 8 }).filter(s => s.kind === 'string')
+  assert.deepEqual(literals.map(s => [s.literal, s.name, s.line, s.count]), [
+    ['string', 'user id is required', 5, 2],
+    ['template', 'SELECT * FROM users WHERE id = ${}', 6, undefined],
+    ['regexp', '/^[a-z0-9-]+$/i', 10, undefined]
+  ])
+  assert.equal(extractJSSymbols(REPO_SRC).some(s => s.kind === 'string'), false)
+})
@@ -0,0 +1,85 @@
+/**
+ * String Literals Module
+ * Searches the string, template and regexp literals indexed with --strings,
+ * to find where an error message, SQL fragment or pattern lives. Each file
+ * keeps one entry per distinct literal (its first occurrence and how often it
+ * repeats), and only literals of at least the minimum length, so the index
+ * stays small. Matches are attached at query time to the innermost symbol
+ * around them.
+ */
+
+import { isLocalSymbol } from './local-scopes.js'
+import { matchesPackage } from './symbol-query.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { FileShard, IndexedString, IndexedSymbol } from '../types/index.js'
+
+// Minimum literal length of a bare --strings
+export const DEFAULT_MIN_STRING_LENGTH = 8
+
+export const STRING_KINDS = ['string', 'template', 'regexp']
+
+export interface StringQuery {
+  /** Substring to look for; every literal when empty */
+  text?: string
+  /** Treat text as a regular expression */
+  regex?: boolean
+  ignoreCase?: boolean
+  /** Skip literals shorter than this */
+  minLength?: number
+  /** Restrict to these literal kinds */
+  kinds?: string[]
+  /** Restrict to these package patterns (see matchesPackage) */
+  packages?: string[]
+}
+
+export interface StringMatch extends IndexedString {
+  path: string
+  symbol: IndexedSymbol | null
+}
+
+/**
+ * Innermost declaration around a line of a file, or null at the top level
+ */
+function enclosingSymbol(shard: FileShard, line: number): IndexedSymbol | null {
+  let around: IndexedSymbol | null = null
+  for (const sym of shard.symbols) {
+    if (isLocalSymbol(sym) || sym.line > line || line > sym.end_line) continue
+    if (!around || sym.line > around.line || (sym.line === around.line && sym.end_line < around.end_line)) around = sym
+  }
+  return around
+}
+
+function literalMatcher(query: StringQuery): (value: string) => boolean {
+  if (!query.text) return () => true
+  if (query.regex) {
+    const pattern = new RegExp(query.text, query.ignoreCase ? 'i' : '')
+    return value => pattern.test(value)
+  }
+  if (query.ignoreCase) {
+    const text = query.text.toLowerCase()
+    return value => value.toLowerCase().includes(text)
+  }
+  return value => value.includes(query.text!)
+}
+
+/**
+ * Indexed literals matching a query, by file and line
+ */
+export function findStrings(index: SymbolIndex, query: StringQuery = {}): StringMatch[] {
+  const matches = literalMatcher(query)
+  const kinds = query.kinds && query.kinds.length > 0 ? new Set(query.kinds) : null
+  const packages = query.packages && query.packages.length > 0 ? query.packages : null
+
+  const results: StringMatch[] = []
+  for (const shard of index.listShards()) {
+    if (!shard.strings || shard.strings.length === 0) continue
+    if (packages && !packages.some(p => matchesPackage(shard.path, p))) continue
+    for (const literal of shard.strings) {
+      if (kinds && !kinds.has(literal.kind)) continue
+      if (query.minLength && literal.value.length < query.minLength) continue
+      if (!matches(literal.value)) continue
+      results.push({ ...literal, path: shard.path, symbol: enclosingSymbol(shard, literal.line) })
+    }
+  }
+  return results.sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : a.line - b.line || a.column - b.column))
+}
@@ -0,0 +1,297 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'node:fs/promises'
+import os from 'node:os'
+import path from 'node:path'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { SymbolIndex, makeSymbolId, syncSymbolIndex } from './symbol-index.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER_SRC = `
+export class User {
+  doWork() {
+    return 1
+  }
+}
+`
+
+const MAIN_SRC = `
+import { User } from './user'
+
+const u = new User()
+u.doWork()
+u.doWork()
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': USER_SRC,
+  'src/main.ts': MAIN_SRC
+}
+
+test('symbol-index: references returns usage locations across files', () => {
+  const index = createIndex(FILES)
+  const refs = index.references(makeSymbolId('src/user.ts', 'User.doWork'))
+
+  assert.equal(refs.length, 2)
+  assert.deepEqual(refs.map(r => [r.path, r.line]), [['src/main.ts', 5], ['src/main.ts', 6]])
+})
+
+test('symbol-index: references for a class include constructor usage', () => {
+  const index = createIndex(FILES)
+  const refs = index.references(makeSymbolId('src/user.ts', 'User'))
+
+  assert.ok(refs.some(r => r.path === 'src/main.ts' && r.line === 4))
+})
+
+test('symbol-index: unknown symbol has no references', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.references('src/missing.ts#Nope'), [])
+})
+
+test('symbol-index: removeFile drops its references', () => {
+  const index = createIndex(FILES)
+  index.removeFile('src/main.ts')
+
+  assert.deepEqual(index.references(makeSymbolId('src/user.ts', 'User.doWork')), [])
+  assert.equal(index.findSymbols('User.doWork').length, 1)
+})
+
+test('symbol-index: symbolAt resolves the identifier under a position', () => {
+  const index = createIndex(FILES)
+
+  const use = index.symbolAt('src/main.ts', 5, 4)!
+  assert.deepEqual([use.name, use.column, use.end_column, use.declaration], ['doWork', 3, 9, false])
+  assert.deepEqual(use.symbols.map(s => s.id), [makeSymbolId('src/user.ts', 'User.doWork')])
+  assert.equal(index.references(use.symbols[0].id).length, 2)
+
+  const decl = index.symbolAt('src/user.ts', 3, 3)!
+  assert.equal(decl.declaration, true)
+  assert.equal(decl.symbols[0].name, 'User.doWork')
+
+  assert.equal(index.symbolAt('src/main.ts', 5, 2), null)
+  assert.equal(index.symbolAt('src/missing.ts', 1, 1), null)
+})
+
+test('symbol-index: findSymbols matches short and qualified names', () => {
+  const index = createIndex(FILES)
+
+  assert.equal(index.findSymbols('doWork')[0].name, 'User.doWork')
+  assert.equal(index.findSymbols('User.doWork').length, 1)
+  assert.equal(index.findSymbols('Other.doWork').length, 0)
+})
+
+const NOTIFY_SRC = `
+export interface Notifier {
+  notify(msg: string): void
+}
+
+export interface Closer {
+  close(): void
+}
+
+export class Mailer implements Notifier {
+  notify(msg: string) {}
+}
+
+export class Pager {
+  notify(msg: string) {}
+  close() {}
+}
+
+export class LoudPager extends Pager {}
+`
+
+test('symbol-index: implementations finds declared and structural implementers', () => {
+  const index = new SymbolIndex()
+  index.addFile('src/notify.ts', 'typescript', extractJSSymbols(NOTIFY_SRC))
+
+  const impls = index.implementations(makeSymbolId('src/notify.ts', 'Notifier')).map(s => s.name)
+  assert.deepEqual(impls, ['Mailer', 'Pager', 'LoudPager'])
+})
+
+test('symbol-index: interfaces lists everything a type satisfies', () => {
+  const index = new SymbolIndex()
+  index.addFile('src/notify.ts', 'typescript', extractJSSymbols(NOTIFY_SRC))
+
+  assert.deepEqual(index.interfaces(makeSymbolId('src/notify.ts', 'Mailer')).map(s => s.name), ['Notifier'])
+  assert.deepEqual(index.interfaces(makeSymbolId('src/notify.ts', 'LoudPager')).map(s => s.name), ['Notifier', 'Closer'])
+})
+
+test('symbol-index: syncSymbolIndex re-parses only changed files', async () => {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'symbol-index-'))
+
+  try {
+    await fs.writeFile(path.join(root, 'a.ts'), 'export function alpha() {}\n')
+    await fs.writeFile(path.join(root, 'b.ts'), 'export function beta() {}\n')
+
+    const index = new SymbolIndex()
+    const first = await syncSymbolIndex(index, root, ['a.ts', 'b.ts'])
+    assert.deepEqual(first.added, ['a.ts', 'b.ts'])
+
+    await fs.writeFile(path.join(root, 'b.ts'), 'export function gamma() {}\n')
+    const second = await syncSymbolIndex(index, root, ['a.ts', 'b.ts'])
+    assert.deepEqual(second.unchanged, ['a.ts'])
+    assert.deepEqual(second.modified, ['b.ts'])
+    assert.equal(index.findSymbols('beta').length, 0)
+    assert.equal(index.findSymbols('gamma').length, 1)
+
+    const third = await syncSymbolIndex(index, root, ['b.ts'])
+    assert.deepEqual(third.removed, ['a.ts'])
+    assert.equal(index.findSymbols('alpha').length, 0)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('symbol-index: symbol IDs do not depend on line numbers', () => {
+  const overloads = (offset: number) => [
+    { name: 'parse', kind: 'function', line: 1 + offset, signature: '(text: string): number' },
+    { name: 'parse', kind: 'function', line: 2 + offset, signature: '(data: Buffer): number' },
+    { name: 'parse', kind: 'function', line: 5 + offset, signature: '(data: Buffer): number' }
+  ] as any[]
+  const ids = (offset: number) => {
+    const index = new SymbolIndex()
+    return index.addFile('src/parse.ts', 'typescript', overloads(offset)).symbols.map(s => s.id)
+  }
+
+  const [plain, overload, repeat] = ids(0)
+  assert.equal(plain, 'src/parse.ts#parse')
+  assert.match(overload, /^src\/parse\.ts#parse@[0-9a-f]{8}$/)
+  assert.equal(repeat, `${overload}~2`)
+  assert.deepEqual(ids(10), [plain, overload, repeat])
+})
+
+test('symbol-index: snapshots do not see later writes', () => {
+  const index = createIndex(FILES)
+  const snapshot = index.snapshot()
+
+  index.addFile('src/admin.ts', 'typescript', extractJSSymbols('export class Admin {\n  doWork() {}\n}\n'))
+  index.removeFile('src/main.ts')
+
+  assert.deepEqual(snapshot.listFiles(), ['src/main.ts', 'src/user.ts'])
+  assert.deepEqual(snapshot.findSymbols('doWork').map(s => s.path), ['src/user.ts'])
+  assert.equal(snapshot.references(makeSymbolId('src/user.ts', 'User.doWork')).length, 2)
+
+  assert.deepEqual(index.listFiles(), ['src/admin.ts', 'src/user.ts'])
+  assert.deepEqual(index.findSymbols('doWork').map(s => s.path), ['src/user.ts', 'src/admin.ts'])
+  assert.equal(index.references(makeSymbolId('src/user.ts', 'User.doWork')).length, 0)
+
+  assert.throws(() => snapshot.removeFile('src/user.ts'), /read-only/)
+  assert.equal(snapshot.snapshot(), snapshot)
+})
+
+test('symbol-index: snapshots keep their text postings and symbols', async () => {
+  const index = new SymbolIndex()
+  index.text.add('src/main.ts', 'u.doWork()\n')
+  const shard = index.addFile('src/user.ts', 'typescript', [{ name: 'User', kind: 'class', line: 1 }] as any)
+  const snapshot = index.snapshot()
+
+  index.removeFile('src/main.ts')
+  index.text.add('src/admin.ts', 'admin.doWork()\n')
+  assert.deepEqual(snapshot.text.candidates('doWork'), ['src/main.ts'])
+  assert.deepEqual(index.text.candidates('doWork'), ['src/admin.ts'])
+  const sources: Record<string, string> = { 'src/main.ts': 'u.doWork()\n' }
+  assert.deepEqual((await snapshot.text.search('doWork', async p => sources[p] ?? null)).map(m => m.path), ['src/main.ts'])
+
+  // Stamping the shard's symbols for another index leaves this one's alone
+  const vendored = new SymbolIndex()
+  vendored.externalOf = () => true
+  vendored.addShard(shard)
+  assert.equal(vendored.getSymbol('src/user.ts#User')?.external, true)
+  assert.equal(snapshot.getSymbol('src/user.ts#User')?.external, undefined)
+  assert.equal(index.getSymbol('src/user.ts#User')?.external, undefined)
+})
+
+test('symbol-index: write batches are isolated from snapshots and serialized', async () => {
+  const index = createIndex(FILES)
+  const order: string[] = []
+  const midBatch: SymbolIndex[] = []
+
+  const first = index.write(async () => {
+    order.push('first:start')
+    index.removeFile('src/main.ts')
+    await new Promise(resolve => setTimeout(resolve, 10))
+    midBatch.push(index.snapshot())
+    index.addFile('src/main.ts', 'typescript', extractJSSymbols(MAIN_SRC.replace(/doWork/g, 'rest')))
+    order.push('first:end')
+  })
+  const second = index.write(async () => {
+    order.push('second')
+  })
+  await Promise.all([first, second])
+
+  assert.deepEqual(order, ['first:start', 'first:end', 'second'])
+  assert.equal(midBatch[0].references(makeSymbolId('src/user.ts', 'User.doWork')).length, 2)
+  assert.equal(index.snapshot().references(makeSymbolId('src/user.ts', 'User.doWork')).length, 0)
+
+  await assert.rejects(index.write(async () => { throw new Error('boom') }), /boom/)
+  assert.equal(await index.write(async () => 'still running'), 'still running')
+})
+
+const GENERIC_SRC = `
+export class Box<K extends string, V = unknown> {
+  get<T>(key: K): T | undefined {
+    return undefined
+  }
+}
+export type Pair<A, B> = [A, B]
+export const wrap = <T,>(value: T) => new Box<string, T>()
+`
+
+const GENERIC_USE_SRC = `
+import { Box } from './box'
+
+let cache: Box<string, number>
+const b = new Box<'a', Date>()
+b.get<number>('a')
+`
+
+test('symbol-index: generic declarations carry their type parameters', () => {
+  const index = new SymbolIndex()
+  index.addFile('src/box.ts', 'typescript', extractJSSymbols(GENERIC_SRC))
+
+  assert.deepEqual(index.getSymbol(makeSymbolId('src/box.ts', 'Box'))!.type_params, [
+    { name: 'K', constraint: 'string' },
+    { name: 'V', default: 'unknown' }
+  ])
+  assert.deepEqual(index.getSymbol(makeSymbolId('src/box.ts', 'Box.get'))!.type_params, [{ name: 'T' }])
+  assert.deepEqual(index.getSymbol(makeSymbolId('src/box.ts', 'Pair'))!.type_params!.map(p => p.name), ['A', 'B'])
+  assert.deepEqual(index.getSymbol(makeSymbolId('src/box.ts', 'wrap'))!.type_params, [{ name: 'T' }])
+})
+
+test('symbol-index: instantiations resolve back to the generic definition', () => {
+  const index = new SymbolIndex()
+  index.addFile('src/box.ts', 'typescript', extractJSSymbols(GENERIC_SRC))
+  index.addFile('src/use.ts', 'typescript', extractJSSymbols(GENERIC_USE_SRC))
+
+  const uses = index.instantiations(makeSymbolId('src/box.ts', 'Box'))
+  assert.deepEqual(uses.map(r => `${r.path}:${r.line} ${r.type_args!.join(', ')}`), [
+    'src/box.ts:8 string, T',
+    'src/use.ts:4 string, number',
+    'src/use.ts:5 \'a\', Date'
+  ])
+  assert.deepEqual(index.instantiations(makeSymbolId('src/box.ts', 'Box.get')).map(r => r.type_args), [['number']])
+})
+
+test('symbol-index: iterators are lazy and match the slice methods', () => {
+  const index = new SymbolIndex()
+  index.addFile('src/log.ts', 'typescript', [{ name: 'log', kind: 'function', line: 1, end_line: 1, exported: true }])
+  for (const file of ['src/b.ts', 'src/a.ts', 'src/c.ts']) {
+    index.addFile(file, 'typescript', [
+      { name: 'log', kind: 'reference', line: 3, end_line: 3, column: 1, call: true },
+      { name: 'log', kind: 'reference', line: 1, end_line: 1, column: 1, call: true }
+    ])
+  }
+  const id = makeSymbolId('src/log.ts', 'log')
+
+  const refs = index.iterReferences(id)
+  assert.deepEqual(refs.next().value, { path: 'src/a.ts', line: 1, column: 1, end_line: 1, role: 'call' })
+  assert.deepEqual(refs.next().value?.line, 3)
+  assert.deepEqual(
+    index.references(id).map(r => `${r.path}:${r.line}`),
+    ['src/a.ts:1', 'src/a.ts:3', 'src/b.ts:1', 'src/b.ts:3', 'src/c.ts:1', 'src/c.ts:3']
+  )
+  assert.deepEqual(Array.from(index.iterReferences(id)), index.references(id))
+  assert.deepEqual(Array.from(index.iterFindSymbols('log'), s => s.id), [id])
+  assert.deepEqual(Array.from(index.iterSymbols()), index.allSymbols())
+})
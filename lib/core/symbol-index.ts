@@ -0,0 +1,1496 @@
+/**
+ * Symbol Index Module
+ * In-memory index of symbol definitions and references built from the
+ * language extractors. Answers cross-reference queries without Qdrant.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import crypto from 'crypto'
+import { detectLanguage } from '../tools/common/utils.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import { listProjectFiles, loadToIndexConfig, shouldIndexFile } from './file-filters.js'
+import { buildModuleOf, loadBuildManifest } from './build-manifest.js'
+import { computeImplementations } from './implementations.js'
+import { computeCallGraph, type CallGraph, type DispatchAlgorithm } from './call-graph.js'
+import { computeThrowTable, errorWrapsInto, escapingErrors, throwPath, type ErrorWrap, type ThrowPath } from './error-paths.js'
+import { TrigramIndex } from './trigram-index.js'
+import { computeUsageStats, usageOf, type SymbolUsage, type UsageTable } from './usage-stats.js'
+import { indexConcurrency, mapConcurrent, parseFile, parseFiles } from './parse-pool.js'
+import { ioLimiter, readConcurrency, type IndexContext } from './index-limits.js'
+import { fieldsOf, methodSet, type MemberEntry } from './member-sets.js'
+import { renameTargets, type RenamePlan } from './rename.js'
+import { computeTestMap, type TestLink } from './test-map.js'
+import { computeEnumSets, type EnumValue } from './enum-sets.js'
+import { computeTypeHierarchy, walkHierarchy, type HierarchyItem, type TypeHierarchy } from './type-hierarchy.js'
+import { computeAliases, type AliasUsage } from './type-aliases.js'
+import { isGeneratedSource } from './generated-code.js'
+import {
+  DEFAULT_FILE_LIMITS,
+  limitContent,
+  limitSymbols,
+  limitsKey,
+  loadFileLimits,
+  readSourceContent,
+  sourceText,
+  type FileLimits,
+  type LimitedContent,
+  type SourceContent
+} from './file-limits.js'
+import { scanTodoComments } from './todo-comments.js'
+import { matchesPackageDir } from './symbol-query.js'
+import { stampChecksum, verifyShards, type ShardDamage } from './index-integrity.js'
+import { SHARD_FORMAT_VERSION, checkIndexFormat, storedFormat } from './index-format.js'
+import { recordGeneration } from './index-history.js'
+import { changedSinceCheckpoint, checkpointMatches, takeCheckpoint, writeCheckpoint, type IndexCheckpoint } from './index-checkpoint.js'
+import { listDependencyFiles, moduleOf, resolvePackageGraph, type ModuleInfo } from './package-graph.js'
+import { loadCodeOwners, ownersResolver } from './code-owners.js'
+import { analyzePackages, analyzerKey, loadAnalyzers, type Analyzer } from './analyzers.js'
+import { reusableShards } from './index-cache.js'
+import { loadSearchRanking, type SearchRanking } from './search-ranking.js'
+import { referenceRole } from './reference-roles.js'
+import { scopeFilter, type ScopeFilter, type SearchScope } from './search-scope.js'
+import { PositionConverter, wideCharRuns } from './positions.js'
+import { caseFoldedPaths, defaultCaseInsensitive, foldPathCase, isCaseInsensitiveFs, toIndexPath } from './index-paths.js'
+import { LOCAL_KINDS, computeLocals, isLocalSymbol, resolveLocal } from './local-scopes.js'
+import { hasCopyAcrossVendor, isVendored, parseVendorMode, resolveVendorModules, sameSideOfVendor, vendorModuleOf } from './vendor-trees.js'
+import { getSymbolStore, type SymbolStore } from '../utils/symbol-store.js'
+import { nowNs, startSpan, tracingEnabled, withSpan } from '../utils/tracing.js'
+import type {
+  ExtractOptions,
+  FileChange,
+  FileShard,
+  IndexedSymbol,
+  Location,
+  ReferenceLocation,
+  ReferenceRole,
+  SymbolInfo,
+  SymbolKind,
+  SymbolReference,
+  IndexedString,
+  WideCharRun,
+  ParseDiagnostic,
+  TodoComment
+} from '../types/index.js'
+
+export { SHARD_FORMAT_VERSION }
+
+/**
+ * Prefix content hashes are salted with: the optional extraction passes and
+ * the analyzers
+ */
+export function hashPasses(options: ExtractOptions = {}, analyzers = ''): string {
+  return `${options.locals ? 'locals:' : ''}${options.strings ? `strings=${options.strings}:` : ''}${analyzers ? `analyzers=${analyzers}:` : ''}`
+}
+
+/**
+ * Content hash of a file, salted with the extraction passes (hashPasses), so
+ * switching --locals or --strings re-parses the file. The shard format is
+ * recorded by the store instead (see index-format).
+ */
+export function contentHash(text: string, passes: string): string {
+  return crypto.createHash('sha1').update(`${passes}${text}`).digest('hex')
+}
+
+// Hash of content to index; a file read in part is hashed with its size
+function sourceHash(content: SourceContent, passes: string): string {
+  return typeof content === 'string' ? contentHash(content, passes) : contentHash(content.head, `${passes}bytes=${content.bytes}:`)
+}
+
+/**
+ * Build a symbol ID from file path and qualified name (receiver included,
+ * "src/user.ts#User.save"). IDs depend only on the project-relative path and
+ * the declaration, never on its position, so they are the same on every
+ * rebuild and machine.
+ */
+export function makeSymbolId(filePath: string, name: string): string {
+  return `${filePath.replace(/\\/g, '/')}#${name}`
+}
+
+/**
+ * ID of a declaration whose plain ID is taken by an earlier one in the same
+ * file (overloads, redefinitions): the plain ID plus a hash of its kind and
+ * signature, and an ordinal if even those repeat
+ */
+function overloadId(baseId: string, kind: string | undefined, signature: string | undefined, taken: (id: string) => boolean): string {
+  const hash = crypto.createHash('sha1').update(`${kind || ''}:${signature || ''}`).digest('hex').slice(0, 8)
+  let id = `${baseId}@${hash}`
+  for (let n = 2; taken(id); n++) id = `${baseId}@${hash}~${n}`
+  return id
+}
+
+/**
+ * Last segment of a qualified name (e.g. "User.doWork" -> "doWork")
+ */
+export function shortName(name: string): string {
+  const idx = name.lastIndexOf('.')
+  return idx === -1 ? name : name.slice(idx + 1)
+}
+
+// A symbol with its file's module and external stamps, copied only when they change
+function stampSymbol(sym: IndexedSymbol, module: ModuleInfo | null, external: boolean): IndexedSymbol {
+  const moduleChanged = !!module && (sym.module !== module.name || sym.module_version !== module.version)
+  if (!moduleChanged && !!sym.external === external) return sym
+  const stamped = { ...sym }
+  if (module) {
+    stamped.module = module.name
+    stamped.module_version = module.version
+  }
+  if (external) stamped.external = true
+  else delete stamped.external
+  return stamped
+}
+
+function compareLocations(a: Location, b: Location): number {
+  if (a.path !== b.path) return a.path < b.path ? -1 : 1
+  if (a.line !== b.line) return a.line - b.line
+  return (a.column || 0) - (b.column || 0)
+}
+
+function* tagVia(refs: Iterable<SymbolReference>, via?: string): Generator<{ ref: SymbolReference, via?: string }> {
+  for (const ref of refs) yield { ref, via }
+}
+
+/**
+ * Merge sorted streams into one, pulling each only as far as needed; ties
+ * keep stream order
+ */
+function* mergeSorted<T>(streams: Iterable<T>[], compare: (a: T, b: T) => number): Generator<T> {
+  const heads = streams.map(stream => {
+    const it = stream[Symbol.iterator]()
+    return { it, next: it.next() }
+  }).filter(head => !head.next.done)
+  while (heads.length > 0) {
+    let min = 0
+    for (let i = 1; i < heads.length; i++) {
+      if (compare(heads[i].next.value, heads[min].next.value) < 0) min = i
+    }
+    yield heads[min].next.value
+    heads[min].next = heads[min].it.next()
+    if (heads[min].next.done) heads.splice(min, 1)
+  }
+}
+
+/**
+ * Definitions the type checker bound references to
+ */
+export interface ReferenceBindings {
+  /** Symbol ID of a reference's definition, '' when it is declared outside the index; undefined if unbound */
+  targetOf(ref: SymbolReference): string | undefined
+  /** References bound to a definition */
+  boundTo(symbolId: string): SymbolReference[]
+}
+
+export interface SymbolAt {
+  name: string // identifier under the position
+  line: number
+  column: number // 1-based start column of the identifier
+  end_column: number // exclusive
+  declaration: boolean // the position is a definition's name rather than a use
+  symbols: IndexedSymbol[] // definitions the identifier resolves to
+}
+
+/**
+ * Symbol index with copy-on-write snapshots.
+ *
+ * Concurrency guarantees:
+ * - snapshot() returns a read-only view that never changes, whatever is
+ *   written to the index afterwards. Taking one is O(1); the first write
+ *   after it copies the lookup tables once, and shards and symbols are shared.
+ * - write() runs an update as one batch. Batches queue behind each other, and
+ *   snapshots taken while one runs show the index as it was before the batch
+ *   started, so a reader sees either none or all of its changes.
+ * - Reads on the index itself see writes as they happen. Queries that await
+ *   between reads should run against a snapshot, and so should iterators
+ *   (iterReferences(), iterSymbols(), ...) that are not used up at once:
+ *   they read the index as they go.
+ * - Full-text postings (`text`) are copied on write like the tables.
+ * - Symbols are stamped with their module on copies, so a shard added to
+ *   several indexes (a fork, a filtered copy) is not changed under the others.
+ */
+export class SymbolIndex {
+  private files = new Map<string, FileShard>()
+  private symbols = new Map<string, IndexedSymbol>()
+  private symbolsByShortName = new Map<string, IndexedSymbol[]>()
+  private refsByName = new Map<string, SymbolReference[]>()
+  private version = 0
+  private analysisCache = new Map<string, { version: number, value: any }>()
+  // Tables are shared with a snapshot and must be copied before the next write
+  private shared = false
+  // Name lists created since the tables were last copied, safe to append to in place
+  private ownedLists = new WeakSet<object>()
+  private frozen = false
+  private committed: SymbolIndex | null = null
+  private writeChain: Promise<unknown> = Promise.resolve()
+  /** Trigram postings for full-text search, fed with file content as it is read */
+  text = new TrigramIndex()
+  /** Package a file belongs to; stamped on symbols as module / module_version */
+  moduleOf: ((filePath: string) => ModuleInfo | null) | null = null
+  /** Whether a file is third-party code kept in the project (vendor: dependency); stamped on symbols as external */
+  externalOf: ((filePath: string) => boolean) | null = null
+  /** Repository a file of a merged index belongs to; references cross repositories only through imports (see index-merge) */
+  repositoryOf: ((filePath: string) => string) | null = null
+  /** CODEOWNERS owners of a file, resolved at query time (see owners()) */
+  ownersOf: ((filePath: string) => string[]) | null = null
+  /** References bound by the type checker (--precision=full, see type-resolution) */
+  bindings: ReferenceBindings | null = null
+  /** How the call graph resolves calls through receivers (--dispatch, see call-graph) */
+  dispatch: DispatchAlgorithm = 'name'
+  /** Parse files with their parameters and local variables (--locals) */
+  locals = false
+  /** Minimum length of the string literals parsed files keep; 0 skips them (--strings) */
+  strings = 0
+  /** Custom analyzers run over the packages of indexed files (analyzer: entries, see analyzers) */
+  analyzers: Analyzer[] = []
+  /** Size and symbol limits of parsed files, with the policy for files over them (see file-limits) */
+  limits: FileLimits = DEFAULT_FILE_LIMITS
+  /** Ordering of symbol search results beyond the match (rank: entries, see search-ranking) */
+  ranking: SearchRanking | null = null
+  /** Resolve query paths that differ from an indexed path only in case (see index-paths) */
+  caseInsensitive = defaultCaseInsensitive()
+
+  /**
+   * Read-only view of the index as it is now, or (during a write() batch) as
+   * it was before the batch started
+   */
+  snapshot(): SymbolIndex {
+    if (this.frozen) return this
+    if (this.committed) return this.committed
+    const view = new SymbolIndex()
+    view.files = this.files
+    view.symbols = this.symbols
+    view.symbolsByShortName = this.symbolsByShortName
+    view.refsByName = this.refsByName
+    view.version = this.version
+    view.analysisCache = new Map(this.analysisCache)
+    view.text = this.text.snapshot()
+    view.moduleOf = this.moduleOf
+    view.externalOf = this.externalOf
+    view.repositoryOf = this.repositoryOf
+    view.ownersOf = this.ownersOf
+    view.bindings = this.bindings
+    view.dispatch = this.dispatch
+    view.locals = this.locals
+    view.strings = this.strings
+    view.analyzers = this.analyzers
+    view.limits = this.limits
+    view.ranking = this.ranking
+    view.caseInsensitive = this.caseInsensitive
+    view.frozen = true
+    this.shared = true
+    return view
+  }
+
+  /**
+   * Writable copy of the index as snapshot() would show it. Tables are
+   * shared until either side writes, and full-text postings are a layer over
+   * this index's, so changes to the fork never reach this index.
+   */
+  fork(): SymbolIndex {
+    const source = this.snapshot()
+    const copy = new SymbolIndex()
+    copy.files = source.files
+    copy.symbols = source.symbols
+    copy.symbolsByShortName = source.symbolsByShortName
+    copy.refsByName = source.refsByName
+    copy.version = source.version
+    copy.analysisCache = new Map(source.analysisCache)
+    copy.text = source.text.layer()
+    copy.moduleOf = source.moduleOf
+    copy.externalOf = source.externalOf
+    copy.repositoryOf = source.repositoryOf
+    copy.ownersOf = source.ownersOf
+    copy.bindings = source.bindings
+    copy.dispatch = source.dispatch
+    copy.locals = source.locals
+    copy.strings = source.strings
+    copy.analyzers = source.analyzers
+    copy.limits = source.limits
+    copy.ranking = source.ranking
+    copy.caseInsensitive = source.caseInsensitive
+    copy.shared = true
+    return copy
+  }
+
+  /**
+   * Counter bumped by every change; equal values mean nothing was written
+   * in between
+   */
+  get generation(): number {
+    return this.version
+  }
+
+  /**
+   * Run an update as one batch, after any batch already running. Snapshots
+   * taken until it settles do not see its changes. Do not call write() from
+   * inside a batch; it would wait for itself.
+   */
+  write<T>(update: () => Promise<T>): Promise<T> {
+    const run = async () => {
+      this.committed = this.snapshot()
+      try {
+        return await update()
+      } finally {
+        this.committed = null
+      }
+    }
+    const result = this.writeChain.then(run, run)
+    this.writeChain = result.catch(() => {})
+    return result
+  }
+
+  private beforeWrite() {
+    if (this.frozen) throw new Error('Index snapshots are read-only')
+    this.version++
+    if (!this.shared) return
+    this.files = new Map(this.files)
+    this.symbols = new Map(this.symbols)
+    this.symbolsByShortName = new Map(this.symbolsByShortName)
+    this.refsByName = new Map(this.refsByName)
+    this.ownedLists = new WeakSet()
+    this.shared = false
+  }
+
+  private appendTo<T>(map: Map<string, T[]>, key: string, item: T) {
+    let list = map.get(key)
+    if (!list || !this.ownedLists.has(list)) {
+      list = list ? [...list] : []
+      this.ownedLists.add(list)
+      map.set(key, list)
+    }
+    list.push(item)
+  }
+
+  private replaceList<T>(map: Map<string, T[]>, key: string, list: T[]) {
+    if (list.length === 0) {
+      map.delete(key)
+      return
+    }
+    this.ownedLists.add(list)
+    map.set(key, list)
+  }
+
+  /**
+   * Cache the result of a whole-index analysis until the index changes
+   */
+  memo<T>(key: string, compute: () => T): T {
+    const cached = this.analysisCache.get(key)
+    if (cached && cached.version === this.version) return cached.value as T
+    const value = compute()
+    this.analysisCache.set(key, { version: this.version, value })
+    return value
+  }
+
+  /**
+   * Optional passes files are parsed with
+   */
+  extractOptions(): ExtractOptions {
+    return { locals: this.locals, ...(this.strings ? { strings: this.strings } : {}) }
+  }
+
+  /**
+   * Add (or replace) a file using raw extractor output
+   * @param wideChars - Non-ASCII runs of its text (see positions)
+   */
+  addFile(filePath: string, lang: string, extracted: Partial<SymbolInfo>[], hash?: string, wideChars: WideCharRun[] = []): FileShard {
+    // Stored with '/' separators whichever platform the path comes from
+    filePath = toIndexPath(filePath)
+    const symbols: IndexedSymbol[] = []
+    const references: SymbolReference[] = []
+    const todos: TodoComment[] = []
+    const strings: IndexedString[] = []
+    const diagnostics: ParseDiagnostic[] = []
+    const taken = new Set<string>() // IDs of symbols so far
+
+    for (const s of extracted) {
+      if (!s.name || s.line === undefined) continue
+      if (s.kind === 'todo') {
+        todos.push({ marker: s.name, text: s.text || '', line: s.line, column: s.column, ...(s.assignee ? { assignee: s.assignee } : {}) })
+        continue
+      }
+      if (s.kind === 'diagnostic') {
+        diagnostics.push({ message: s.name, line: s.line, column: s.column || 1 })
+        continue
+      }
+      if (s.kind === 'string') {
+        strings.push({ value: s.name, kind: s.literal || 'string', line: s.line, column: s.column, ...(s.count ? { count: s.count } : {}) })
+        continue
+      }
+      if (s.kind === 'reference') {
+        references.push({
+          name: s.name,
+          path: filePath,
+          line: s.line,
+          column: s.column,
+          end_line: s.end_line,
+          ...(s.call ? { call: true } : {}),
+          ...(s.role ? { role: s.role } : {}),
+          ...(s.receiver ? { receiver: s.receiver } : {}),
+          ...(s.type_args ? { type_args: s.type_args } : {}),
+          ...(s.test_title !== undefined ? { test_title: s.test_title, test_end_line: s.test_end_line } : {}),
+          ...(s.property ? { property: true } : {}),
+          ...(s.label ? { label: true } : {}),
+          ...(s.caught ? { caught: true } : {}),
+          ...(s.source ? { source: s.source } : {})
+        })
+        continue
+      }
+      const { name, kind, line, end_line, ...rest } = s
+      // Locals are keyed by their function ("src/cart.ts#total/sum") so they never take a top-level ID
+      let id = makeSymbolId(filePath, LOCAL_KINDS.has(kind as string) ? `${s.scope}/${name}` : name)
+      if (taken.has(id)) id = overloadId(id, kind, s.signature, candidate => taken.has(candidate))
+      taken.add(id)
+      symbols.push({
+        ...rest,
+        id,
+        name,
+        kind: (kind || 'unknown') as SymbolKind,
+        path: filePath,
+        lang,
+        line,
+        end_line: end_line ?? line
+      })
+    }
+
+    return this.addShard({
+      path: filePath,
+      lang,
+      hash,
+      symbols,
+      references,
+      ...(todos.length > 0 ? { todos } : {}),
+      ...(strings.length > 0 ? { strings } : {}),
+      ...(diagnostics.length > 0 ? { diagnostics } : {}),
+      ...(wideChars.length > 0 ? { wide_chars: wideChars } : {})
+    })
+  }
+
+  /**
+   * Add (or replace) a prebuilt file shard
+   */
+  addShard(shard: FileShard): FileShard {
+    this.removeFile(shard.path)
+    this.beforeWrite()
+    const module = this.moduleOf ? this.moduleOf(shard.path) : null
+    const external = this.isExternal(shard.path)
+    const symbols = shard.symbols.map(sym => stampSymbol(sym, module, external))
+    if (symbols.some((sym, i) => sym !== shard.symbols[i])) shard = { ...shard, symbols }
+    this.files.set(shard.path, shard)
+    for (const sym of shard.symbols) {
+      this.symbols.set(sym.id, sym)
+      this.appendTo(this.symbolsByShortName, shortName(sym.name), sym)
+    }
+    for (const ref of shard.references) {
+      this.appendTo(this.refsByName, ref.name, ref)
+    }
+    return shard
+  }
+
+  /**
+   * Remove a file and everything it contributed
+   */
+  removeFile(filePath: string): boolean {
+    if (this.frozen) throw new Error('Index snapshots are read-only')
+    this.text.remove(filePath)
+    const shard = this.files.get(filePath)
+    if (!shard) return false
+    this.beforeWrite()
+    for (const sym of shard.symbols) {
+      this.symbols.delete(sym.id)
+      const key = shortName(sym.name)
+      this.replaceList(this.symbolsByShortName, key, (this.symbolsByShortName.get(key) || []).filter(s => s.path !== filePath))
+    }
+    for (const name of new Set(shard.references.map(r => r.name))) {
+      this.replaceList(this.refsByName, name, (this.refsByName.get(name) || []).filter(r => r.path !== filePath))
+    }
+    this.files.delete(filePath)
+    return true
+  }
+
+  getFile(filePath: string): FileShard | undefined {
+    return this.files.get(filePath)
+  }
+
+  listFiles(): string[] {
+    return Array.from(this.files.keys()).sort()
+  }
+
+  listShards(): FileShard[] {
+    return this.listFiles().map(f => this.files.get(f)!)
+  }
+
+  getSymbol(symbolId: string): IndexedSymbol | undefined {
+    return this.symbols.get(symbolId)
+  }
+
+  /**
+   * Column conversions for an indexed file (see positions)
+   */
+  positions(filePath: string): PositionConverter | null {
+    const shard = this.files.get(filePath)
+    return shard ? PositionConverter.fromShard(shard) : null
+  }
+
+  /**
+   * Indexed path a query names: separators and './' segments are normalized
+   * and, with caseInsensitive, a path differing only in case matches
+   * @returns null if no indexed file matches
+   */
+  resolvePath(filePath: string): string | null {
+    const normalized = toIndexPath(filePath)
+    if (this.files.has(normalized)) return normalized
+    if (!this.caseInsensitive) return null
+    return this.memo('caseFoldedPaths', () => caseFoldedPaths(this.files.keys())).get(foldPathCase(normalized)) ?? null
+  }
+
+  /**
+   * Symbol id a query names, with its path resolved like resolvePath();
+   * ids that match no file are returned unchanged
+   */
+  resolveSymbolId(symbolId: string): string {
+    if (this.symbols.has(symbolId)) return symbolId
+    const hash = symbolId.indexOf('#')
+    if (hash === -1) return symbolId
+    const filePath = this.resolvePath(symbolId.slice(0, hash))
+    return filePath === null ? symbolId : `${filePath}${symbolId.slice(hash)}`
+  }
+
+  allSymbols(): IndexedSymbol[] {
+    return Array.from(this.iterSymbols())
+  }
+
+  /**
+   * Every symbol, lazily, in index order
+   */
+  iterSymbols(): IterableIterator<IndexedSymbol> {
+    return this.symbols.values()
+  }
+
+  fileSymbols(filePath: string): IndexedSymbol[] {
+    return this.files.get(filePath)?.symbols || []
+  }
+
+  /**
+   * Find symbols by qualified name ("User.doWork") or short name ("doWork")
+   */
+  findSymbols(name: string): IndexedSymbol[] {
+    return Array.from(this.iterFindSymbols(name))
+  }
+
+  /**
+   * findSymbols(), lazily
+   */
+  *iterFindSymbols(name: string): Generator<IndexedSymbol> {
+    for (const s of this.symbolsByShortName.get(shortName(name)) || []) {
+      if (!name.includes('.') || s.name === name || s.name.endsWith(`.${name}`)) yield s
+    }
+  }
+
+  /**
+   * Identifier at a position (1-based line and column) and the definitions it
+   * resolves to, by the same rules as references(): the type checker's
+   * binding when there is one, else a definition declared in the file
+   * shadows those of other files. Null when the position holds no indexed
+   * definition or reference.
+   */
+  symbolAt(filePath: string, line: number, column: number): SymbolAt | null {
+    return this.symbolsAt(filePath, [{ line, column }])[0]
+  }
+
+  /**
+   * symbolAt() for many positions of one file, reading its shard once
+   */
+  symbolsAt(filePath: string, positions: { line: number, column: number }[]): (SymbolAt | null)[] {
+    const shard = this.files.get(filePath)
+    if (!shard) return positions.map(() => null)
+    const lines = new Set(positions.map(p => p.line))
+    const declared = new Map<number, IndexedSymbol[]>()
+    for (const sym of shard.symbols) {
+      if (!lines.has(sym.line)) continue
+      const onLine = declared.get(sym.line)
+      if (onLine) onLine.push(sym)
+      else declared.set(sym.line, [sym])
+    }
+    const used = new Map<number, SymbolReference[]>()
+    for (const ref of shard.references) {
+      if (!lines.has(ref.line)) continue
+      const onLine = used.get(ref.line)
+      if (onLine) onLine.push(ref)
+      else used.set(ref.line, [ref])
+    }
+    return positions.map(({ line, column }) => this.resolveAt(filePath, line, column, declared.get(line) || [], used.get(line) || []))
+  }
+
+  /**
+   * Resolve one position against the declarations and references on its line
+   */
+  private resolveAt(filePath: string, line: number, column: number, symbols: IndexedSymbol[], references: SymbolReference[]): SymbolAt | null {
+    const covers = (start: number | undefined, name: string) =>
+      start !== undefined && column >= start && column < start + name.length
+
+    for (const sym of symbols) {
+      const name = shortName(sym.name)
+      if (covers(sym.column, name)) {
+        return { name, line, column: sym.column, end_column: sym.column + name.length, declaration: true, symbols: [sym] }
+      }
+    }
+    const ref = references.find(r => covers(r.column, r.name))
+    if (!ref) return null
+    const location = { name: ref.name, line, column: ref.column!, end_column: ref.column! + ref.name.length, declaration: false }
+    const target = this.checkedTarget(ref)
+    if (target !== undefined) {
+      const sym = target ? this.symbols.get(target) : undefined
+      return { ...location, symbols: sym ? [sym] : [] }
+    }
+    const bound = this.localOf(ref)
+    const candidates = bound ? [bound] : this.findSymbols(ref.name).filter(s => !isLocalSymbol(s) && this.reaches(ref, s))
+    const local = candidates.filter(s => s.path === filePath)
+    return { ...location, symbols: sameSideOfVendor(local.length > 0 ? local : candidates, this.isExternal(filePath)).sort(compareLocations) }
+  }
+
+  /**
+   * Every location where a symbol is used (declaration site excluded), with
+   * what the use does; only those in `roles` when given (find writes), and
+   * only within `scope` of the symbol. Uses of a type through its aliases
+   * (see type-aliases) are left out unless `usage` asks for them; they carry
+   * the alias they go through in `via`.
+   */
+  references(symbolId: string, roles?: ReferenceRole[], scope: SearchScope = 'all', usage: AliasUsage = 'direct'): ReferenceLocation[] {
+    return Array.from(this.iterReferences(symbolId, roles, scope, usage))
+  }
+
+  /**
+   * references(), lazily and in the same order: each reference is resolved
+   * only when it is reached, so a caller that stops early (the first use, a
+   * page of them) never resolves the rest
+   */
+  *iterReferences(symbolId: string, roles?: ReferenceRole[], scope: SearchScope = 'all', usage: AliasUsage = 'direct'): Generator<ReferenceLocation> {
+    const within = this.scopeOf(symbolId, scope)
+    const streams: Iterable<{ ref: SymbolReference, via?: string }>[] = []
+    if (usage !== 'alias') streams.push(tagVia(this.referencesTo(symbolId)))
+    if (usage !== 'direct') {
+      for (const aliasId of this.aliasIds(symbolId)) streams.push(tagVia(this.referencesTo(aliasId), aliasId))
+    }
+    for (const { ref, via } of mergeSorted(streams, (a, b) => compareLocations(a.ref, b.ref))) {
+      if (within && !within.includes(ref.path)) continue
+      const role = referenceRole(ref)
+      if (roles && !roles.includes(role)) continue
+      yield { path: ref.path, line: ref.line, column: ref.column, end_line: ref.end_line, role, ...(via ? { via } : {}) }
+    }
+  }
+
+  /**
+   * Type an alias names at the end of its chain of aliases (type A = B,
+   * type B = C gives C for A); null for a symbol that is not a resolved alias
+   */
+  aliasTarget(symbolId: string): IndexedSymbol | null {
+    const target = this.memo('aliases', () => computeAliases(this)).underlying.get(symbolId)
+    return target ? this.symbols.get(target) || null : null
+  }
+
+  /**
+   * Aliases naming a type, directly or through other aliases
+   */
+  aliasesOf(symbolId: string): IndexedSymbol[] {
+    return this.resolveIds(this.aliasIds(symbolId))
+  }
+
+  private aliasIds(symbolId: string): string[] {
+    return this.memo('aliases', () => computeAliases(this)).aliases.get(symbolId) || []
+  }
+
+  /**
+   * Generic instantiations of a symbol: references that carry type arguments
+   * (Map<string, number> resolves back to Map<K, V>)
+   */
+  instantiations(symbolId: string): SymbolReference[] {
+    return Array.from(this.iterInstantiations(symbolId))
+  }
+
+  /**
+   * instantiations(), lazily
+   */
+  *iterInstantiations(symbolId: string): Generator<SymbolReference> {
+    for (const ref of this.referencesTo(symbolId)) {
+      if (ref.type_args && ref.type_args.length > 0) yield ref
+    }
+  }
+
+  /**
+   * Parameter, local variable or type parameter an identifier at a position
+   * binds to (the label, for a break / continue target), when the file was
+   * indexed with --locals
+   */
+  localAt(filePath: string, name: string, line: number, column?: number, label = false): IndexedSymbol | null {
+    return resolveLocal(this.memo('locals', () => computeLocals(this)), filePath, name, line, column, label)
+  }
+
+  /**
+   * Local a reference binds to; property names never do
+   */
+  localOf(ref: SymbolReference): IndexedSymbol | null {
+    return ref.property ? null : this.localAt(ref.path, ref.name, ref.line, ref.column, !!ref.label)
+  }
+
+  /**
+   * Symbol ID the type checker bound a reference to ('' when it is declared
+   * outside the index); undefined when the name rules apply
+   */
+  checkedTarget(ref: SymbolReference): string | undefined {
+    return this.bindings ? this.bindings.targetOf(ref) : undefined
+  }
+
+  private isExternal(filePath: string): boolean {
+    return !!this.externalOf && this.externalOf(filePath)
+  }
+
+  /**
+   * Whether a reference can resolve to a definition: in a merged index, only
+   * within its repository or into the one it imports the name from
+   */
+  private reaches(ref: SymbolReference, sym: IndexedSymbol): boolean {
+    if (!this.repositoryOf) return true
+    return (ref.repository ?? this.repositoryOf(ref.path)) === this.repositoryOf(sym.path)
+  }
+
+  // Resolved as they are pulled; the candidates are only sorted up front
+  private *referencesTo(symbolId: string): Generator<SymbolReference> {
+    const sym = this.symbols.get(symbolId)
+    if (!sym) return
+
+    const name = shortName(sym.name)
+    const namesakes = (this.symbolsByShortName.get(name) || []).filter(s => !isLocalSymbol(s))
+    const local = isLocalSymbol(sym)
+    // Of a definition indexed inside and outside vendor/, each copy has the uses on its side
+    const copied = hasCopyAcrossVendor(sym, namesakes)
+
+    // Bound references include imports of the definition under another name
+    const renamed = this.bindings ? this.bindings.boundTo(symbolId).filter(ref => ref.name !== name) : []
+    for (const ref of [...this.refsByName.get(name) || [], ...renamed].sort(compareLocations)) {
+      if (ref.path === sym.path && ref.line === sym.line && (!local || ref.column === sym.column)) continue
+      const target = this.checkedTarget(ref)
+      if (target !== undefined) {
+        if (target === sym.id) yield ref
+        continue
+      }
+      // Identifiers inside a local's scope are that local's, whatever else shares the name
+      const bound = this.localOf(ref)
+      if (local ? bound !== sym : bound) continue
+      // A same-named definition in the referencing file shadows other files
+      if (ref.path !== sym.path && namesakes.some(s => s !== sym && s.path === ref.path)) continue
+      if (copied && this.isExternal(ref.path) !== !!sym.external) continue
+      if (!this.reaches(ref, sym)) continue
+      yield ref
+    }
+  }
+
+  /**
+   * Concrete types that satisfy an interface, within `scope` of it
+   */
+  implementations(interfaceId: string, scope: SearchScope = 'all'): IndexedSymbol[] {
+    const within = this.scopeOf(interfaceId, scope)
+    const table = within
+      ? this.memo(`implementations:${within.key}`, () => computeImplementations(this, s => within.includes(s.path)))
+      : this.memo('implementations', () => computeImplementations(this))
+    return this.resolveIds(table.implementations.get(interfaceId))
+  }
+
+  /**
+   * Interfaces satisfied by a concrete type
+   */
+  interfaces(typeId: string): IndexedSymbol[] {
+    const table = this.memo('implementations', () => computeImplementations(this))
+    return this.resolveIds(table.interfaces.get(typeId))
+  }
+
+  /**
+   * Base types and interfaces of a type, or wider interfaces of an interface;
+   * nearest only unless `transitive`, and only within `scope` of the type
+   */
+  supertypes(typeId: string, transitive = false, scope: SearchScope = 'all'): HierarchyItem[] {
+    return walkHierarchy(this, this.typeHierarchy(typeId, scope), typeId, 'supertypes', transitive)
+  }
+
+  /**
+   * Derived types and implementations of a type, or narrower interfaces and
+   * implementations of an interface; nearest only unless `transitive`, and
+   * only within `scope` of the type
+   */
+  subtypes(typeId: string, transitive = false, scope: SearchScope = 'all'): HierarchyItem[] {
+    return walkHierarchy(this, this.typeHierarchy(typeId, scope), typeId, 'subtypes', transitive)
+  }
+
+  /**
+   * Hierarchy of the types within a scope of one, computed once per scope
+   */
+  private typeHierarchy(typeId: string, scope: SearchScope): TypeHierarchy {
+    const within = this.scopeOf(typeId, scope)
+    return within
+      ? this.memo(`type-hierarchy:${within.key}`, () => computeTypeHierarchy(this, s => within.includes(s.path)))
+      : this.memo('type-hierarchy', () => computeTypeHierarchy(this))
+  }
+
+  private scopeOf(symbolId: string, scope: SearchScope): ScopeFilter | null {
+    const sym = scope === 'all' ? undefined : this.symbols.get(symbolId)
+    return sym ? scopeFilter(this, scope, sym.path) : null
+  }
+
+  /**
+   * Methods of a type, including those promoted from base types
+   */
+  methodSet(typeId: string): MemberEntry[] {
+    const type = this.symbols.get(typeId)
+    return type ? methodSet(this, type) : []
+  }
+
+  /**
+   * Fields and properties of a type, optionally including inherited ones
+   */
+  fields(typeId: string, includeInherited = true): MemberEntry[] {
+    const type = this.symbols.get(typeId)
+    return type ? fieldsOf(this, type, includeInherited) : []
+  }
+
+  /**
+   * Usage counts of every referenced symbol, by ID
+   */
+  usageStats(): UsageTable {
+    return this.memo('usage-stats', () => computeUsageStats(this))
+  }
+
+  /**
+   * How often a symbol is referenced, and from how many files and packages
+   */
+  usage(symbolId: string): SymbolUsage {
+    return usageOf(this.usageStats(), symbolId)
+  }
+
+  /**
+   * Caller -> callee edges for the whole index, resolved with the index's
+   * dispatch algorithm
+   */
+  callGraph(): CallGraph {
+    return this.memo(`call-graph:${this.dispatch}`, () => computeCallGraph(this, this.dispatch))
+  }
+
+  /**
+   * Functions and methods that call a function
+   */
+  callers(fnId: string): IndexedSymbol[] {
+    const edges = this.callGraph().callers.get(fnId) || []
+    return this.resolveIds(new Set(edges.map(e => e.caller)))
+  }
+
+  /**
+   * Functions and methods a function calls
+   */
+  callees(fnId: string): IndexedSymbol[] {
+    const edges = this.callGraph().callees.get(fnId) || []
+    return this.resolveIds(new Set(edges.map(e => e.callee)))
+  }
+
+  /**
+   * How a function can throw (its own uncaught throw, or the shortest chain
+   * of uncaught calls to one), or null when it cannot
+   */
+  canThrow(fnId: string): ThrowPath | null {
+    return throwPath(this, this.memo(`throw-table:${this.dispatch}`, () => computeThrowTable(this)), fnId)
+  }
+
+  /**
+   * Error types that can escape a function, directly or through its calls
+   */
+  escapingErrors(fnId: string): string[] {
+    return escapingErrors(this, fnId)
+  }
+
+  /**
+   * Functions that construct an error type (name pattern) with a cause
+   */
+  errorWrapsInto(errType: string): ErrorWrap[] {
+    return errorWrapsInto(this, errType)
+  }
+
+  /**
+   * Tests, benchmarks, fuzz targets and examples that exercise a symbol, by
+   * reference or by naming convention
+   */
+  testsFor(symbolId: string): TestLink[] {
+    return this.memo('test-map', () => computeTestMap(this)).bySubject.get(symbolId) || []
+  }
+
+  /**
+   * Values of an enum or literal union type, including constants declared
+   * with the type in other files
+   */
+  enumValues(typeId: string): EnumValue[] {
+    return this.memo('enum-sets', () => computeEnumSets(this)).get(typeId) || []
+  }
+
+  /**
+   * CODEOWNERS owners of a file, empty when it has none or the project has
+   * no CODEOWNERS file
+   */
+  owners(filePath: string): string[] {
+    return this.ownersOf ? this.ownersOf(filePath) : []
+  }
+
+  /**
+   * Edits and conflicts for renaming a symbol (nothing is written)
+   */
+  renameTargets(symbolId: string, newName: string): RenamePlan {
+    return renameTargets(this, symbolId, newName)
+  }
+
+  private resolveIds(ids: Iterable<string> | undefined): IndexedSymbol[] {
+    const result: IndexedSymbol[] = []
+    for (const id of ids || []) {
+      const sym = this.symbols.get(id)
+      if (sym) result.push(sym)
+    }
+    return result.sort((a, b) => compareLocations(a, b))
+  }
+}
+
+export interface IndexUpdate {
+  added: string[]
+  modified: string[]
+  removed: string[]
+  unchanged: string[]
+  cached?: string[] // added or modified files taken from a --cache-from index instead of parsed
+}
+
+// Passes a content hash is salted with: extraction options, analyzers and file limits
+function indexPasses(index: SymbolIndex): string {
+  return hashPasses(index.extractOptions(), analyzerKey(index.analyzers)) + limitsKey(index.limits)
+}
+
+/**
+ * Add already-extracted file content to the index, tagging the definitions
+ * of generated files
+ */
+function addParsedFile(
+  index: SymbolIndex,
+  relPath: string,
+  content: SourceContent,
+  parsed: Partial<SymbolInfo>[],
+  limited = limitContent(content, index.limits)
+): FileShard {
+  const { extracted, diagnostics } = limitSymbols(parsed, index.limits, limited.declarationsOnly)
+  const text = limited.text
+  const entries = isGeneratedSource(text)
+    ? extracted.map(s => s.kind === 'reference' ? s : { ...s, generated: true })
+    : extracted
+  const lang = detectLanguage(relPath)
+  const hash = sourceHash(content, indexPasses(index))
+  const todos = limited.declarationsOnly ? [] : scanTodoComments(text, lang)
+  const shard = index.addFile(relPath, lang, [...entries, ...todos], hash, wideCharRuns(text))
+  // Notes on what the limits left out, next to the syntax errors
+  const notes = [...limited.diagnostics, ...diagnostics]
+  if (notes.length > 0) shard.diagnostics = [...(shard.diagnostics || []), ...notes]
+  index.text.add(relPath, text)
+  return shard
+}
+
+/**
+ * Parse file content and add it to the index
+ */
+export async function indexContent(index: SymbolIndex, relPath: string, content: SourceContent): Promise<FileShard> {
+  const limited = limitContent(content, index.limits)
+  const parsed = limited.parse === null ? [] : await parseFile({ relPath, content: limited.parse, options: index.extractOptions() })
+  return addParsedFile(index, relPath, content, parsed, limited)
+}
+
+async function readSourceFile(projectRoot: string, relPath: string, limits: FileLimits): Promise<SourceContent | null> {
+  try {
+    return await readSourceContent(path.join(projectRoot, relPath), limits)
+  } catch {
+    return null
+  }
+}
+
+/**
+ * Parse a project file and add it to the index
+ * @returns The new shard, or null if the file could not be read
+ */
+export async function indexSourceFile(index: SymbolIndex, projectRoot: string, relPath: string): Promise<FileShard | null> {
+  const content = await readSourceFile(projectRoot, relPath, index.limits)
+  return content === null ? null : indexContent(index, relPath, content)
+}
+
+/**
+ * Read project files concurrently, within the context's read concurrency
+ * and IO rate; files over the size limit are read only in part
+ * @param include - Files for which it resolves false are not read (null)
+ * @returns File contents in input order, null for unreadable files
+ */
+async function readSourceFiles(
+  projectRoot: string,
+  relPaths: string[],
+  limits: FileLimits,
+  ctx: IndexContext = {},
+  include?: (relPath: string) => Promise<boolean>
+): Promise<(SourceContent | null)[]> {
+  const limiter = ioLimiter(ctx)
+  return mapConcurrent(relPaths, ctx.readConcurrency ?? readConcurrency(), async relPath => {
+    if (include && !await include(relPath)) return null
+    const content = await readSourceFile(projectRoot, relPath, limits)
+    if (limiter && content !== null) await limiter.take(Buffer.byteLength(sourceText(content)), ctx.signal)
+    return content
+  }, ctx.signal)
+}
+
+function traceRead(projectRoot: string, relPaths: string[], limits: FileLimits, ctx: IndexContext = {}): Promise<(SourceContent | null)[]> {
+  return withSpan('indexer.read', { 'indexer.files': relPaths.length }, () => readSourceFiles(projectRoot, relPaths, limits, ctx))
+}
+
+/**
+ * One span per package under the active phase span, from the first of its
+ * files starting to the last finishing. indexer.busy_ms adds up the time
+ * spent on each file, which is what the package cost when files of several
+ * packages were processed in parallel.
+ */
+function tracePackages(phase: string, relPaths: string[], timings: [bigint, bigint][]): void {
+  const packages = new Map<string, { start: bigint, end: bigint, busy: bigint, files: number }>()
+  relPaths.forEach((relPath, i) => {
+    const [start, end] = timings[i]
+    const dir = path.posix.dirname(relPath)
+    const entry = packages.get(dir)
+    if (!entry) {
+      packages.set(dir, { start, end, busy: end - start, files: 1 })
+      return
+    }
+    if (start < entry.start) entry.start = start
+    if (end > entry.end) entry.end = end
+    entry.busy += end - start
+    entry.files++
+  })
+  for (const [dir, entry] of packages) {
+    startSpan(`indexer.${phase}.package`, {
+      'indexer.package': dir,
+      'indexer.files': entry.files,
+      'indexer.busy_ms': Number(entry.busy) / 1e6
+    }, entry.start)?.end(entry.end)
+  }
+}
+
+/**
+ * Indexing pipeline shared by build, sync, change application and revision
+ * indexing: unchanged contents are skipped, the rest are parsed on the parse
+ * pool and then added to the index in input order, so the result does not
+ * depend on which parse finishes first. Files are only removed and added
+ * once all of them are parsed, so a run cancelled through the context
+ * leaves the index as it was. Traced as indexer.parse and indexer.resolve
+ * spans, each with a child span per package.
+ * @param index - Index to update
+ * @param relPaths - Files to index
+ * @param contents - Content per file, null for files that no longer exist
+ * @param update - Update to record the changes in
+ * @param ctx - Cancellation and parse concurrency
+ */
+export async function indexFileContents(
+  index: SymbolIndex,
+  relPaths: string[],
+  contents: (SourceContent | null)[],
+  update: IndexUpdate = { added: [], modified: [], removed: [], unchanged: [] },
+  ctx: IndexContext = {}
+): Promise<IndexUpdate> {
+  const pending: { relPath: string, content: SourceContent, existing: boolean, options: ExtractOptions, limited: LimitedContent }[] = []
+  const gone: string[] = []
+  const options = index.extractOptions()
+  const passes = indexPasses(index)
+
+  relPaths.forEach((relPath, i) => {
+    const content = contents[i]
+    if (content === null) {
+      gone.push(relPath)
+      return
+    }
+    const existing = index.getFile(relPath)
+    if (existing && existing.hash === sourceHash(content, passes)) {
+      if (!index.text.has(relPath)) index.text.add(relPath, limitContent(content, index.limits).text)
+      update.unchanged.push(relPath)
+      return
+    }
+    pending.push({ relPath, content, existing: !!existing, options, limited: limitContent(content, index.limits) })
+  })
+  const remove = () => {
+    for (const relPath of gone) {
+      if (index.removeFile(relPath)) update.removed.push(relPath)
+    }
+  }
+  if (pending.length === 0) {
+    remove()
+    return update
+  }
+
+  const traced = tracingEnabled()
+  const pendingPaths = pending.map(file => file.relPath)
+  const timings: [bigint, bigint][] = []
+  const extracted = await withSpan('indexer.parse', { 'indexer.files': pending.length }, async () => {
+    const onParsed = traced ? (i: number, start: bigint, end: bigint) => { timings[i] = [start, end] } : undefined
+    // Files the limits leave out (binary, or skipped for size) are not parsed
+    const jobs = pending.filter(file => file.limited.parse !== null)
+    const parsed = await parseFiles(
+      jobs.map(file => ({ relPath: file.relPath, content: file.limited.parse!, options: file.options })),
+      ctx.concurrency ?? indexConcurrency(), onParsed, ctx.signal
+    )
+    if (traced) tracePackages('parse', jobs.map(file => file.relPath), timings)
+    const byFile = new Map(jobs.map((file, i) => [file, parsed[i]]))
+    return pending.map(file => byFile.get(file) || [])
+  })
+  remove()
+  await withSpan('indexer.resolve', { 'indexer.files': pending.length }, () => {
+    pending.forEach((file, i) => {
+      const start = traced ? nowNs() : 0n
+      addParsedFile(index, file.relPath, file.content, extracted[i], file.limited)
+      if (traced) timings[i] = [start, nowNs()]
+      if (file.existing) update.modified.push(file.relPath)
+      else update.added.push(file.relPath)
+    })
+    if (traced) tracePackages('resolve', pendingPaths, timings)
+  })
+  return update
+}
+
+/**
+ * Add the shards of a previous index (see index-cache) for the packages of
+ * the tree that have not changed, before indexFileContents() parses the rest
+ * @param relPaths - Files of the tree
+ * @param contents - Their contents, in input order (null for missing files)
+ * @returns Files seeded, each with whether it replaced a shard of the index
+ */
+export function seedFromCache(index: SymbolIndex, cache: FileShard[], relPaths: string[], contents: (SourceContent | null)[]): Map<string, boolean> {
+  const passes = indexPasses(index)
+  const hashes = contents.map(content => (content === null ? null : sourceHash(content, passes)))
+  const seeded = new Map<string, boolean>()
+  for (const shard of reusableShards(cache, relPaths, hashes)) {
+    const existing = index.getFile(shard.path)
+    if (existing?.hash === shard.hash) continue
+    index.addShard(shard)
+    seeded.set(shard.path, !!existing)
+  }
+  return seeded
+}
+
+/**
+ * Report seeded files that indexing kept as added or modified rather than
+ * unchanged, so they are persisted
+ */
+function withCached(update: IndexUpdate, seeded: Map<string, boolean>): IndexUpdate {
+  if (seeded.size === 0) return update
+  const kept = update.unchanged.filter(f => seeded.has(f))
+  return {
+    added: [...update.added, ...kept.filter(f => !seeded.get(f))],
+    modified: [...update.modified, ...kept.filter(f => seeded.get(f))],
+    removed: update.removed,
+    unchanged: update.unchanged.filter(f => !seeded.has(f)),
+    cached: kept
+  }
+}
+
+/**
+ * Run the index's analyzers over the packages an update touched. Files whose
+ * shards got new facts without changing are moved to `modified`, so they are
+ * persisted too.
+ * @param known - Contents already read, by path
+ */
+async function analyzeUpdate(
+  index: SymbolIndex,
+  projectRoot: string,
+  update: IndexUpdate,
+  known: Map<string, SourceContent | null> = new Map()
+): Promise<IndexUpdate> {
+  const changed = [...update.added, ...update.modified, ...update.removed]
+  const read = async (relPath: string) => {
+    const content = known.get(relPath) ?? await readSourceFile(projectRoot, relPath, index.limits)
+    return content === null ? null : sourceText(content)
+  }
+  const replaced = new Set(await withSpan('indexer.analyze', { 'indexer.files': changed.length }, () =>
+    analyzePackages(index, changed, read)
+  ))
+  const refreshed = update.unchanged.filter(f => replaced.has(f))
+  if (refreshed.length === 0) return update
+  return {
+    ...update,
+    modified: [...update.modified, ...refreshed],
+    unchanged: update.unchanged.filter(f => !replaced.has(f))
+  }
+}
+
+/**
+ * Build a symbol index for the project
+ * @param projectRoot - Project root path
+ * @param files - Relative paths to index (defaults to all project files)
+ * @param ctx - Cancellation, concurrency and IO rate limits
+ */
+export async function buildSymbolIndex(projectRoot: string, files?: string[], ctx: IndexContext = {}): Promise<SymbolIndex> {
+  await initTreeSitter()
+  return withSpan('indexer.build', { 'indexer.project': projectRoot }, async () => {
+    const index = new SymbolIndex()
+    const relPaths = files || await listProjectFiles(projectRoot)
+    await indexFileContents(index, relPaths, await traceRead(projectRoot, relPaths, index.limits, ctx), undefined, ctx)
+    return index
+  })
+}
+
+/**
+ * Bring an index up to date with the working tree, re-parsing only files
+ * whose content hash changed and patching the index in place as one write()
+ * batch. A cancelled sync keeps the shards it took from the cache, which
+ * match the files on disk, and changes nothing else.
+ * @param index - Index to update
+ * @param projectRoot - Project root path
+ * @param files - Current project files (defaults to all project files)
+ * @param cache - Shards of a previous index to take unchanged packages from
+ * @param ctx - Cancellation, concurrency and IO rate limits
+ */
+export async function syncSymbolIndex(
+  index: SymbolIndex,
+  projectRoot: string,
+  files?: string[],
+  cache?: FileShard[],
+  ctx: IndexContext = {}
+): Promise<IndexUpdate> {
+  await initTreeSitter()
+  return withSpan('indexer.sync', { 'indexer.project': projectRoot }, async (span) => {
+    const relPaths = files || await listProjectFiles(projectRoot)
+    const current = new Set(relPaths)
+    const contents = await traceRead(projectRoot, relPaths, index.limits, ctx)
+
+    const update = await index.write(async () => {
+      const update: IndexUpdate = { added: [], modified: [], removed: [], unchanged: [] }
+      // Files no longer in the tree are removed with the rest of the update
+      const stale = index.listFiles().filter(indexed => !current.has(indexed))
+      const seeded = cache ? seedFromCache(index, cache, relPaths, contents) : new Map<string, boolean>()
+      await indexFileContents(index, [...stale, ...relPaths], [...stale.map(() => null), ...contents], update, ctx)
+      const analyzed = await analyzeUpdate(index, projectRoot, update, new Map(relPaths.map((relPath, i) => [relPath, contents[i]])))
+      return withCached(analyzed, seeded)
+    })
+    span?.setAttributes(updateAttributes(update))
+    return update
+  })
+}
+
+/**
+ * Bring an index loaded from its store up to date with the tree after a
+ * clean shutdown: only the files changed since the checkpoint are read
+ * (see index-checkpoint), and files gone from the tree are removed
+ */
+async function resumeSymbolIndex(
+  index: SymbolIndex,
+  projectRoot: string,
+  checkpoint: IndexCheckpoint,
+  files?: string[],
+  ctx: IndexContext = {}
+): Promise<IndexUpdate> {
+  await initTreeSitter()
+  return withSpan('indexer.resume', { 'indexer.project': projectRoot }, async (span) => {
+    const relPaths = files || await listProjectFiles(projectRoot)
+    const current = new Set(relPaths)
+    const { changed, unchanged } = await changedSinceCheckpoint(projectRoot, checkpoint, relPaths)
+    const contents = await traceRead(projectRoot, changed, index.limits, ctx)
+
+    const update = await index.write(async () => {
+      const update: IndexUpdate = { added: [], modified: [], removed: [], unchanged: [] }
+      const stale = index.listFiles().filter(indexed => !current.has(indexed))
+      await indexFileContents(index, [...stale, ...changed], [...stale.map(() => null), ...contents], update, ctx)
+      const analyzed = await analyzeUpdate(index, projectRoot, update, new Map(changed.map((relPath, i) => [relPath, contents[i]])))
+      return { ...analyzed, unchanged: [...analyzed.unchanged, ...unchanged] }
+    })
+    span?.setAttributes(updateAttributes(update))
+    return update
+  })
+}
+
+/**
+ * Record a checkpoint of an index its store is up to date with, so the next
+ * openSymbolIndex re-reads only what changed after it
+ */
+export async function checkpointSymbolIndex(index: SymbolIndex, projectRoot: string): Promise<void> {
+  await writeCheckpoint(projectRoot, index.listFiles(), indexPasses(index))
+}
+
+function updateAttributes(update: IndexUpdate) {
+  return {
+    'indexer.files.added': update.added.length,
+    'indexer.files.modified': update.modified.length,
+    'indexer.files.removed': update.removed.length,
+    'indexer.files.unchanged': update.unchanged.length
+  }
+}
+
+/**
+ * Apply a set of changed paths to the index: files that still exist and pass
+ * the project filters are re-indexed if their content changed, the rest are
+ * removed
+ * @param index - Index to update
+ * @param projectRoot - Project root path
+ * @param relPaths - Changed paths relative to the project root
+ * @param ctx - Cancellation, concurrency and IO rate limits
+ */
+export async function applyFileChanges(
+  index: SymbolIndex,
+  projectRoot: string,
+  relPaths: string[],
+  ctx: IndexContext = {}
+): Promise<IndexUpdate> {
+  await initTreeSitter()
+  return withSpan('indexer.apply', { 'indexer.project': projectRoot }, async (span) => {
+    const unique = [...new Set(relPaths)]
+    const contents = await withSpan('indexer.read', { 'indexer.files': unique.length }, () =>
+      readSourceFiles(projectRoot, unique, index.limits, ctx, relPath => shouldIndexFile(relPath, projectRoot))
+    )
+    const update = await index.write(async () => {
+      const indexed = await indexFileContents(index, unique, contents, undefined, ctx)
+      return analyzeUpdate(index, projectRoot, indexed, new Map(unique.map((relPath, i) => [relPath, contents[i]])))
+    })
+    span?.setAttributes(updateAttributes(update))
+    return update
+  })
+}
+
+/**
+ * Patch an index with a list of file changes, such as the diff between the
+ * commit it was built from and a newer one. Only the changed files are
+ * re-parsed; everything else is kept as is.
+ * @param index - Index of the old tree, updated in place
+ * @param changes - Changed files (renames drop the old path)
+ * @param readFiles - Contents at the new tree, in input order (null if missing)
+ * @param ctx - Cancellation and parse concurrency
+ */
+export async function updateFromDiff(
+  index: SymbolIndex,
+  changes: FileChange[],
+  readFiles: (relPaths: string[]) => Promise<(string | null)[]>,
+  ctx: IndexContext = {}
+): Promise<IndexUpdate> {
+  await initTreeSitter()
+  const gone = changes.flatMap(c => (c.status === 'deleted' ? [c.path] : c.status === 'renamed' && c.oldPath ? [c.oldPath] : []))
+  const present = changes.filter(c => c.status !== 'deleted').map(c => c.path)
+  return withSpan('indexer.diff', { 'indexer.files': changes.length }, async (span) => {
+    const contents = await withSpan('indexer.read', { 'indexer.files': present.length }, () => readFiles(present))
+    ctx.signal?.throwIfAborted()
+    const update = await index.write(() => indexFileContents(index, [...gone, ...present], [...gone.map(() => null), ...contents], undefined, ctx))
+    span?.setAttributes(updateAttributes(update))
+    return update
+  })
+}
+
+export interface OpenSymbolIndexOptions extends IndexContext {
+  /** Also index installed dependencies (node_modules) */
+  deps?: boolean
+  /**
+   * Package patterns ("./services/api/...") to open on their own. With a
+   * store split per package only those packages are loaded and re-indexed;
+   * other stores load everything.
+   */
+  packages?: string[]
+  /** Also index parameters and local variables with their scopes */
+  locals?: boolean
+  /** Also index string literals at least this long */
+  strings?: number
+  /** Shards of a previous build's index to take unchanged packages from */
+  cache?: FileShard[]
+}
+
+/**
+ * Open the project's symbol index from its store, re-index whatever changed
+ * since the last run and persist the difference. Stored shards that fail
+ * their checksum are dropped and re-indexed like new files; they are listed
+ * in `damaged`. A store in another shard format fails with an
+ * IndexFormatError (see index-format). After a watching process shut down
+ * cleanly, only the files changed since its checkpoint are read.
+ * @param projectRoot - Project root path
+ * @param store - Backing store (defaults to getSymbolStore)
+ * @param options - Whether to include dependency sources, locals and strings, which packages to load, cancellation and limits
+ */
+export async function openSymbolIndex(
+  projectRoot: string,
+  store: SymbolStore = getSymbolStore(projectRoot),
+  options: OpenSymbolIndexOptions = {}
+): Promise<{ index: SymbolIndex, update: IndexUpdate, damaged: ShardDamage[] }> {
+  return withSpan('indexer.open', { 'indexer.project': projectRoot }, async () => {
+    const index = new SymbolIndex()
+    const graph = await resolvePackageGraph(projectRoot)
+    if (graph) index.moduleOf = (filePath) => moduleOf(graph, filePath)
+    const toIndex = await loadToIndexConfig(projectRoot)
+    if (parseVendorMode(toIndex?.vendor) === 'dependency') {
+      const ofVendor = vendorModuleOf(await resolveVendorModules(projectRoot))
+      const ofGraph = index.moduleOf
+      index.moduleOf = (filePath) => ofVendor(filePath) || (ofGraph ? ofGraph(filePath) : null)
+      index.externalOf = isVendored
+    }
+    // Build packages are the boundaries when the build system lists the files
+    const build = toIndex?.build
+    if (build) {
+      const ofBuild = buildModuleOf(await loadBuildManifest(projectRoot, build))
+      const ofGraph = index.moduleOf
+      index.moduleOf = (filePath) => ofBuild(filePath) || (ofGraph ? ofGraph(filePath) : null)
+    }
+    const codeOwners = await loadCodeOwners(projectRoot)
+    if (codeOwners) index.ownersOf = ownersResolver(codeOwners.rules)
+    index.caseInsensitive = await isCaseInsensitiveFs(projectRoot)
+    index.locals = !!options.locals
+    index.strings = options.strings || 0
+    index.analyzers = await loadAnalyzers(projectRoot, toIndex?.analyzers || [])
+    index.limits = await loadFileLimits(projectRoot)
+    index.ranking = await loadSearchRanking(projectRoot, toIndex?.rank || [])
+
+    const patterns = options.packages || []
+    const inScope = patterns.length > 0 && store.loadPackages
+      ? (dir: string) => patterns.some(p => matchesPackageDir(dir, p))
+      : null
+    checkIndexFormat(await storedFormat(store), 'The stored symbol index')
+    const stored = await withSpan('indexer.load', {}, async (span) => {
+      const shards = inScope ? await store.loadPackages!(inScope) : await store.load()
+      span?.setAttribute('indexer.files', shards?.length || 0)
+      return shards
+    })
+    const { intact, damaged } = verifyShards(stored || [])
+    for (const shard of intact) {
+      index.addShard(shard)
+    }
+
+    let files = options.deps && graph
+      ? [...await listProjectFiles(projectRoot), ...await listDependencyFiles(projectRoot, graph)]
+      : undefined
+    // A first run indexes everything, so the store is complete for the next one
+    if (inScope && stored) {
+      files = (files || await listProjectFiles(projectRoot)).filter(f => inScope(path.posix.dirname(f)))
+    }
+    // After a clean shutdown of a watching process, resume from its checkpoint
+    const checkpoint = inScope ? null : await takeCheckpoint(projectRoot)
+    const resume = checkpoint && stored && !options.cache && damaged.length === 0 &&
+      checkpointMatches(checkpoint, index.listFiles(), indexPasses(index)) ? checkpoint : null
+    const update = resume
+      ? await resumeSymbolIndex(index, projectRoot, resume, files, options)
+      : await syncSymbolIndex(index, projectRoot, files, options.cache, options)
+    if (!stored) {
+      await withSpan('indexer.store', { 'indexer.files': index.listFiles().length }, () =>
+        store.save(index.listShards().map(stampChecksum), SHARD_FORMAT_VERSION)
+      )
+    } else {
+      // Damaged shards of files that are gone were never loaded, so drop them explicitly
+      const stale = damaged.map(d => d.path).filter(p => !index.getFile(p))
+      await persistUpdate(index, store, { ...update, removed: [...update.removed, ...stale] })
+    }
+    // Only a complete index that changed is worth a generation
+    if (!inScope && (!stored || update.added.length + update.modified.length + update.removed.length > 0)) {
+      await recordGeneration(projectRoot, index.listShards())
+    }
+    return { index, update, damaged }
+  })
+}
+
+/**
+ * Write the files touched by an update to a store, with fresh checksums
+ */
+export async function persistUpdate(index: SymbolIndex, store: SymbolStore, update: IndexUpdate): Promise<void> {
+  const changed = [...update.added, ...update.modified]
+  if (changed.length === 0 && update.removed.length === 0) return
+  const shards = changed.map(f => index.getFile(f)).filter((s): s is FileShard => !!s)
+  await withSpan('indexer.store', { 'indexer.files': shards.length, 'indexer.files.removed': update.removed.length }, () =>
+    store.update(shards.map(stampChecksum), update.removed)
+  )
+}
@@ -0,0 +1,19 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SYMBOL_KINDS, isSymbolKind, symbolKindCode } from './symbol-kinds.js'
+
+test('symbol-kinds: every kind has its own number', () => {
+  const codes = Object.values(SYMBOL_KINDS)
+  assert.equal(new Set(codes).size, codes.length)
+  assert.ok(codes.every(code => code > 0))
+  // Numbers are stable: these are written into exported records
+  assert.deepEqual([symbolKindCode('function'), symbolKindCode('parameter'), symbolKindCode('package')], [1, 25, 28])
+})
+
+test('symbol-kinds: extractor records and unknown kinds have no number', () => {
+  for (const kind of ['reference', 'todo', 'unknown', 'widget', 'toString']) {
+    assert.equal(symbolKindCode(kind), 0)
+    assert.equal(isSymbolKind(kind), false)
+  }
+  assert.equal(isSymbolKind('label'), true)
+})
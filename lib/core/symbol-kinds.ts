@@ -0,0 +1,65 @@
+/**
+ * Symbol Kinds Module
+ * The kinds of symbol the index stores, each with a stable number
+ * (Symbol.Kind in index_records.proto). Kinds are only ever added, never
+ * renamed, renumbered or removed; SYMBOL_KINDS_VERSION counts the additions
+ * and exports carry it (Header.symbol_kinds_version in --format=proto), so a
+ * consumer can tell whether it may meet kinds it does not know. Version 2
+ * added label and type_parameter, indexed with --locals, and package, the
+ * file-scoped namespace a C# file declares.
+ */
+
+import type { SymbolKind } from '../types/index.js'
+
+export const SYMBOL_KINDS_VERSION = 2
+
+// Kinds extractors report that are not stored as symbols
+type RecordKind = 'reference' | 'todo' | 'string' | 'diagnostic' | 'unknown'
+
+export type StoredSymbolKind = Exclude<SymbolKind, RecordKind>
+
+export const SYMBOL_KINDS: Record<StoredSymbolKind, number> = {
+  function: 1,
+  method: 2,
+  class: 3,
+  interface: 4,
+  struct: 5,
+  enum: 6,
+  variable: 7,
+  constant: 8,
+  property: 9,
+  field: 10,
+  import: 11,
+  export: 12,
+  unity_lifecycle: 13,
+  serialized_field: 14,
+  scriptable_object: 15,
+  hook: 16,
+  function_component: 17,
+  accessor: 18,
+  private_field: 19,
+  type: 20,
+  namespace: 21,
+  const: 22,
+  default_export: 23,
+  local: 24,
+  parameter: 25,
+  // Version 2
+  label: 26,
+  type_parameter: 27,
+  package: 28
+}
+
+/**
+ * Stable number of a kind; 0 for one outside the set
+ */
+export function symbolKindCode(kind: string): number {
+  return Object.hasOwn(SYMBOL_KINDS, kind) ? SYMBOL_KINDS[kind as StoredSymbolKind] : 0
+}
+
+/**
+ * Whether a kind is one the index stores
+ */
+export function isSymbolKind(kind: string): kind is StoredSymbolKind {
+  return symbolKindCode(kind) !== 0
+}
@@ -0,0 +1,46 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { matchesPackage, namePattern, querySymbols } from './symbol-query.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'lib/store.ts': `
+export interface Store {}
+interface Hidden {}
+export class MemoryStore {}
+`,
+  'lib/db/sql.ts': `
+export interface SqlStore {}
+export function openDb() {}
+`,
+  'main.ts': `
+export interface Config {}
+`
+}
+
+test('symbol-query: matchesPackage handles direct and recursive patterns', () => {
+  assert.equal(matchesPackage('lib/store.ts', './lib'), true)
+  assert.equal(matchesPackage('lib/db/sql.ts', './lib'), false)
+  assert.equal(matchesPackage('lib/db/sql.ts', './lib/...'), true)
+  assert.equal(matchesPackage('library/x.ts', './lib/...'), false)
+  assert.equal(matchesPackage('main.ts', '.'), true)
+  assert.equal(matchesPackage('lib/db/sql.ts', './...'), true)
+})
+
+test('symbol-query: namePattern supports globs and short names', () => {
+  assert.equal(namePattern('doWork')('User.doWork'), true)
+  assert.equal(namePattern('User.*')('User.doWork'), true)
+  assert.equal(namePattern('*Store')('MemoryStore'), true)
+  assert.equal(namePattern('Store')('MemoryStore'), false)
+})
+
+test('symbol-query: filters by kind, export and package', () => {
+  const results = querySymbols(createIndex(FILES), { kinds: ['interface'], exported: true, packages: ['./lib/...'] })
+  assert.deepEqual(results.map(s => s.name), ['SqlStore', 'Store'])
+})
+
+test('symbol-query: unexported and limit', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(querySymbols(index, { kinds: ['interface'], exported: false }).map(s => s.name), ['Hidden'])
+  assert.equal(querySymbols(index, { limit: 2 }).length, 2)
+})
@@ -0,0 +1,188 @@
+/**
+ * Symbol Query Module
+ * Structured filters over the symbol index (kind, visibility, package path,
+ * name pattern, language, generated code, deprecation, callers/callees,
+ * error paths, control-flow landmarks, fuzzy name, field tags, analyzer facts, code owners) used by the query CLI. Query-language expressions plug in
+ * as a filter. Results can be ordered by usage instead of location.
+ */
+
+import type { SymbolIndex } from './symbol-index.js'
+import { fuzzySearch } from './fuzzy-search.js'
+import { deprecationNotice } from './deprecations.js'
+import { ownedBy } from './code-owners.js'
+import { CALLABLE_KINDS } from './call-graph.js'
+import { compareUsage, type UsageSort } from './usage-stats.js'
+import { matchesLandmarkFilter, parseLandmarkFilter } from '../utils/landmarks.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export interface SymbolQuery {
+  kinds?: string[]
+  exported?: boolean
+  packages?: string[]
+  name?: string
+  lang?: string
+  generated?: boolean // only (true) or no (false) symbols of generated files
+  external?: boolean // only (true) or no (false) symbols of vendored dependencies (vendor: dependency)
+  deprecated?: boolean // only (true) or no (false) deprecated symbols
+  callersOf?: string // only functions that call this one
+  calleesOf?: string // only functions this one calls
+  throws?: boolean // only functions that can (true) or cannot (false) throw, through their calls too
+  wraps?: string // only functions that construct this error type (name pattern) with a cause
+  landmarks?: string[] // control-flow landmarks, as "goto" or "label>2" (see parseLandmarkFilter)
+  fuzzy?: string // rank by fuzzy match instead of sorting by location
+  tags?: string[] // field tags, as "json" or "json:user_id" (see hasFieldTag)
+  facts?: string[] // analyzer facts, as "sqlcheck", "sqlcheck.raw_query" or "sqlcheck.raw_query=true" (see hasFact)
+  owners?: string[] // CODEOWNERS owners, any of them (see ownedBy)
+  filter?: (sym: IndexedSymbol) => boolean // extra predicate, e.g. a query-language expression
+  sort?: UsageSort // most used first, by references or by referencing packages (see usage-stats)
+  limit?: number
+  offset?: number
+}
+
+/**
+ * Match a file against a package pattern.
+ * "./lib" matches files directly in lib/, "./lib/..." matches lib/ recursively,
+ * "./..." matches everything.
+ */
+export function matchesPackage(filePath: string, pattern: string): boolean {
+  return matchesPackageDir(filePath.includes('/') ? filePath.slice(0, filePath.lastIndexOf('/')) : '', pattern)
+}
+
+/**
+ * Match a directory ('' or '.' for the root) against a package pattern
+ */
+export function matchesPackageDir(dir: string, pattern: string): boolean {
+  let p = pattern.replace(/\\/g, '/').replace(/^\.\//, '').replace(/^\.$/, '')
+  const recursive = p === '...' || p.endsWith('/...')
+  if (recursive) p = p.replace(/\/?\.\.\.$/, '')
+  p = p.replace(/\/+$/, '')
+
+  const d = dir === '.' ? '' : dir
+  if (recursive) return p === '' || d === p || d.startsWith(`${p}/`)
+  return d === p
+}
+
+/**
+ * Compile a name pattern: "*" and "?" are wildcards, otherwise the pattern
+ * matches the qualified name or its last segment exactly
+ */
+export function namePattern(pattern: string): (name: string) => boolean {
+  if (!/[*?]/.test(pattern)) {
+    return (name) => name === pattern || name.endsWith(`.${pattern}`)
+  }
+  const source = pattern.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.')
+  const re = new RegExp(`^${source}$`)
+  return (name) => re.test(name) || re.test(name.slice(name.lastIndexOf('.') + 1))
+}
+
+/**
+ * Whether a field has a tag, optionally one whose name or an option matches
+ * a pattern ("*" and "?" are wildcards): json, json:user_id, validate:email,
+ * db:primary
+ */
+export function hasFieldTag(sym: IndexedSymbol, key: string, pattern?: string): boolean {
+  const tag = sym.tags?.[key]
+  if (!tag) return false
+  if (pattern === undefined || pattern === '') return true
+  const source = pattern.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.')
+  const re = new RegExp(`^${source}$`)
+  return [tag.name ?? '', ...(tag.options || [])].some((value: string) => re.test(value))
+}
+
+function tagFilter(spec: string): (sym: IndexedSymbol) => boolean {
+  const colon = spec.indexOf(':')
+  return colon === -1
+    ? sym => hasFieldTag(sym, spec)
+    : sym => hasFieldTag(sym, spec.slice(0, colon), spec.slice(colon + 1))
+}
+
+/**
+ * Whether a symbol has facts from an analyzer ("sqlcheck"), a given fact
+ * ("sqlcheck.raw_query"), or a fact with a value (compared as text)
+ */
+export function hasFact(sym: IndexedSymbol, key: string, value?: string): boolean {
+  const dot = key.indexOf('.')
+  const facts = sym.facts?.[dot === -1 ? key : key.slice(0, dot)]
+  if (!facts) return false
+  if (dot === -1) return true
+  const name = key.slice(dot + 1)
+  if (!(name in facts)) return false
+  return value === undefined || String(facts[name]) === value
+}
+
+function factFilter(spec: string): (sym: IndexedSymbol) => boolean {
+  const eq = spec.indexOf('=')
+  return eq === -1
+    ? sym => hasFact(sym, spec)
+    : sym => hasFact(sym, spec.slice(0, eq), spec.slice(eq + 1))
+}
+
+// Symbol IDs allowed by the call-graph filters, or null when there are none
+function relatedIds(index: SymbolIndex, query: SymbolQuery): Set<string> | null {
+  let allowed: Set<string> | null = null
+  const restrict = (ids: string[]) => {
+    const next = new Set(ids)
+    allowed = allowed ? new Set([...allowed].filter(id => next.has(id))) : next
+  }
+  if (query.callersOf) {
+    restrict(index.findSymbols(query.callersOf).flatMap(fn => index.callers(fn.id).map(s => s.id)))
+  }
+  if (query.calleesOf) {
+    restrict(index.findSymbols(query.calleesOf).flatMap(fn => index.callees(fn.id).map(s => s.id)))
+  }
+  return allowed
+}
+
+/**
+ * Run a structured query over the index, sorted by path and line
+ * (or by match quality for fuzzy queries), or most used first with sort
+ */
+export function querySymbols(index: SymbolIndex, query: SymbolQuery): IndexedSymbol[] {
+  const kinds = query.kinds && query.kinds.length > 0 ? new Set(query.kinds) : null
+  const matchName = query.name ? namePattern(query.name) : null
+  const related = relatedIds(index, query)
+  const tags = (query.tags || []).map(tagFilter)
+  const facts = (query.facts || []).map(factFilter)
+  const wraps = query.wraps ? namePattern(query.wraps) : null
+  const landmarks = (query.landmarks || []).map(parseLandmarkFilter)
+
+  const matches = (sym: IndexedSymbol) => {
+    if (related && !related.has(sym.id)) return false
+    if (kinds && !kinds.has(sym.kind)) return false
+    if (query.exported !== undefined && !!sym.exported !== query.exported) return false
+    if (query.lang && sym.lang !== query.lang) return false
+    if (query.generated !== undefined && !!sym.generated !== query.generated) return false
+    if (query.external !== undefined && !!sym.external !== query.external) return false
+    if (query.deprecated !== undefined && (deprecationNotice(sym) !== undefined) !== query.deprecated) return false
+    if (query.throws !== undefined && (!CALLABLE_KINDS.has(sym.kind) || (index.canThrow(sym.id) !== null) !== query.throws)) return false
+    if (wraps && !(sym.wraps || []).some(wraps)) return false
+    if (landmarks.length > 0 && (!CALLABLE_KINDS.has(sym.kind) || !landmarks.every(l => matchesLandmarkFilter(sym, l)))) return false
+    if (query.packages && query.packages.length > 0 && !query.packages.some(p => matchesPackage(sym.path, p))) return false
+    if (matchName && !matchName(sym.name)) return false
+    if (!tags.every(t => t(sym))) return false
+    if (!facts.every(f => f(sym))) return false
+    if (query.owners && query.owners.length > 0 && !ownedBy(index.owners(sym.path), query.owners)) return false
+    if (query.filter && !query.filter(sym)) return false
+    return true
+  }
+
+  if (query.fuzzy && !query.sort) {
+    return fuzzySearch(index, query.fuzzy, { filter: matches, limit: query.limit, offset: query.offset })
+      .map(m => m.symbol)
+  }
+
+  let result: IndexedSymbol[]
+  if (query.fuzzy) {
+    result = fuzzySearch(index, query.fuzzy, { filter: matches }).map(m => m.symbol)
+  } else {
+    result = index.allSymbols().filter(matches)
+    result.sort((a, b) => {
+      if (a.path !== b.path) return a.path < b.path ? -1 : 1
+      return a.line - b.line
+    })
+  }
+  // The sort is stable, so equally used symbols keep their fuzzy or location order
+  if (query.sort) result.sort(compareUsage(index.usageStats(), query.sort))
+  const offset = Math.max(query.offset || 0, 0)
+  return query.limit ? result.slice(offset, offset + query.limit) : result.slice(offset)
+}
@@ -0,0 +1,119 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { initTreeSitter } from '../utils/tree-sitter.js'
+import { SymbolIndex, indexFileContents, makeSymbolId } from './symbol-index.js'
+import { isTestFile } from './test-map.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER_SRC = `export class User {
+  save() {}
+  load() {}
+}
+export function format(u) {}
+`
+
+const USER_TEST_SRC = `import { User, format } from './user'
+
+function makeUser() {
+  return new User()
+}
+
+test('User.save stores the user', () => {
+  makeUser().save()
+})
+
+test('format prints names', () => {})
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': USER_SRC,
+  'src/user.test.ts': USER_TEST_SRC
+}
+
+function tests(index: SymbolIndex, file: string, name: string): [string, number, string][] {
+  return index.testsFor(makeSymbolId(file, name)).map(l => [l.test.name, l.test.line, l.via])
+}
+
+test('test-map: recognizes test files by convention', () => {
+  assert.ok(isTestFile('lib/core/rename.test.ts'))
+  assert.ok(isTestFile('src/__tests__/user.tsx'))
+  assert.ok(isTestFile('tests/test_server.py'))
+  assert.ok(isTestFile('Game.Tests/PlayerTests.cs'))
+  assert.ok(!isTestFile('src/latest.ts'))
+})
+
+test('test-map: test bodies link through helpers to what they use', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(tests(index, 'src/user.ts', 'User.save'), [['User.save stores the user', 7, 'call']])
+  assert.deepEqual(tests(index, 'src/user.ts', 'User'), [['User.save stores the user', 7, 'call']])
+  assert.deepEqual(tests(index, 'src/user.ts', 'User.load'), [])
+  assert.equal(index.testsFor(makeSymbolId('src/user.test.ts', 'makeUser')).length, 0)
+})
+
+test('test-map: titles naming a symbol of the file under test', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(tests(index, 'src/user.ts', 'format'), [['format prints names', 11, 'name']])
+})
+
+test('test-map: Python test functions', async () => {
+  await initTreeSitter()
+  const index = new SymbolIndex()
+  await indexFileContents(index, ['svc/server.py', 'svc/test_server.py'], [
+    'def handle(x):\n    return x + 1\n\ndef stop():\n    pass\n',
+    'from server import handle\n\ndef test_handle():\n    assert handle(1) == 2\n\ndef test_stop():\n    pass\n'
+  ])
+  assert.deepEqual(tests(index, 'svc/server.py', 'handle'), [['test_handle', 3, 'call']])
+  assert.deepEqual(tests(index, 'svc/server.py', 'stop'), [['test_stop', 6, 'name']])
+})
+
+test('test-map: benchmarks, fuzz targets and attached examples', () => {
+  const index = createIndex(FILES)
+  index.addFile('src/user.bench.ts', 'typescript', extractJSSymbols(`import { format } from './user'
+
+bench('format', () => {
+  format(1)
+})
+
+test('format handles any input', () => {
+  fc.assert(fc.property(fc.anything(), x => format(x)))
+})
+`))
+  index.addFile('examples/user.ts', 'typescript', extractJSSymbols(`import { User } from '../src/user'
+
+export function ExampleUser_save() {
+  new User().save()
+}
+
+export function ExampleUser_save_twice() {}
+
+export function Example_format() {}
+`))
+
+  const kinds = (name: string) => index.testsFor(makeSymbolId('src/user.ts', name)).map(l => [l.test.kind, l.test.name, l.via])
+  assert.deepEqual(kinds('User.save'), [
+    ['example', 'ExampleUser_save', 'example'],
+    ['example', 'ExampleUser_save_twice', 'example'],
+    ['test', 'User.save stores the user', 'call']
+  ])
+  assert.deepEqual(kinds('format'), [
+    ['example', 'Example_format', 'example'],
+    ['benchmark', 'format', 'call'],
+    ['fuzz', 'format handles any input', 'call'],
+    ['test', 'format prints names', 'name']
+  ])
+  assert.deepEqual(kinds('User').map(k => k[1]), ['ExampleUser_save', 'User.save stores the user'])
+})
+
+test('test-map: Python decorators and benchmark functions', async () => {
+  await initTreeSitter()
+  const index = new SymbolIndex()
+  await indexFileContents(index, ['svc/server.py', 'svc/test_server.py'], [
+    'def handle(x):\n    return x + 1\n',
+    'from hypothesis import given\n\n@given(st.integers())\ndef check_handle(x):\n    handle(x)\n\ndef bench_handle(benchmark):\n    benchmark(handle, 1)\n'
+  ])
+  assert.deepEqual(index.testsFor(makeSymbolId('svc/server.py', 'handle')).map(l => [l.test.kind, l.test.name, l.via]), [
+    ['fuzz', 'check_handle', 'call'],
+    ['benchmark', 'bench_handle', 'call']
+  ])
+})
@@ -0,0 +1,253 @@
+/**
+ * Test Map Module
+ * Analysis pass that finds test cases, benchmarks, fuzz targets and examples
+ * and links them to the symbols they exercise. Cases are test() / it() /
+ * bench() calls and functions named or decorated by the usual conventions
+ * (test_parse, BenchmarkParse, FuzzParse, ExampleUser_save, [Fact],
+ * [Benchmark], @given). A case exercises what its body references, following
+ * helpers defined in test files, and, by naming convention, the symbols its
+ * name or title mentions in the file under test (user.test.ts -> user.ts,
+ * test_user.py -> user.py). Examples attach to the symbol they are named
+ * after, as in godoc: ExampleUser_save documents User.save.
+ */
+
+import path from 'path'
+import { CALLABLE_KINDS } from './call-graph.js'
+import { shortName } from './symbol-index.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol, SymbolReference } from '../types/index.js'
+
+const TEST_FILE_PATTERNS = [
+  /\.(test|spec|bench)\.[cm]?[jt]sx?$/,
+  /(^|\/)(__tests__|examples?)\//,
+  /(^|\/)(test|bench)_[^/]*\.py$/,
+  /_(test|bench)\.py$/,
+  /(Tests?|Benchmarks?)\.cs$/
+]
+
+const NAME_KINDS: [RegExp, TestKind][] = [
+  [/^[Tt]est(?=[A-Z_]|$)/, 'test'],
+  [/^[Bb]ench(mark)?(?=[A-Z_]|$)/, 'benchmark'],
+  [/^[Ff]uzz(?=[A-Z_]|$)/, 'fuzz'],
+  [/^[Ee]xample(?=[A-Z_]|$)/, 'example']
+]
+
+// C# attributes and Python decorators that mark a case wherever it is declared
+const DECORATOR_KINDS: Record<string, TestKind> = {
+  Fact: 'test',
+  Theory: 'test',
+  Test: 'test',
+  TestCase: 'test',
+  TestMethod: 'test',
+  DataTestMethod: 'test',
+  Benchmark: 'benchmark',
+  Property: 'fuzz',
+  given: 'fuzz',
+  'hypothesis.given': 'fuzz',
+  'pytest.mark.benchmark': 'benchmark'
+}
+
+const CALL_KINDS: Record<string, TestKind> = { test: 'test', it: 'test', bench: 'benchmark' }
+
+// Property-based testing inside a test() body makes it a fuzz target: fc.assert(fc.property(...))
+const FUZZ_CALLS = new Set(['property', 'asyncProperty'])
+
+export type TestKind = 'test' | 'benchmark' | 'fuzz' | 'example'
+
+export interface TestCase {
+  id: string // symbol ID of a test function, "<path>@<line>" for a test() call
+  kind: TestKind
+  name: string // function name or test title
+  path: string
+  line: number
+  end_line: number
+  subject?: string // ID of the symbol an example documents
+}
+
+export interface TestLink {
+  test: TestCase
+  via: 'call' | 'name' | 'example' // referenced from the body, matched by name, or an attached example
+}
+
+// Strongest evidence wins when a case links to a symbol more than once
+const VIA_RANK: Record<TestLink['via'], number> = { name: 0, call: 1, example: 2 }
+
+export interface TestMap {
+  tests: TestCase[]
+  bySubject: Map<string, TestLink[]> // subject symbol ID -> cases
+}
+
+/**
+ * Whether a path holds tests, benchmarks or examples by the usual per-language conventions
+ */
+export function isTestFile(filePath: string): boolean {
+  return TEST_FILE_PATTERNS.some(re => re.test(filePath))
+}
+
+/**
+ * Kind of case a function declares, or null for ordinary functions
+ */
+export function testKindOf(sym: IndexedSymbol): TestKind | null {
+  if (!CALLABLE_KINDS.has(sym.kind)) return null
+  for (const decorator of sym.decorators || []) {
+    if (DECORATOR_KINDS[decorator]) return DECORATOR_KINDS[decorator]
+  }
+  if (!isTestFile(sym.path)) return null
+  const name = shortName(sym.name)
+  for (const [pattern, kind] of NAME_KINDS) {
+    if (pattern.test(name)) return kind
+  }
+  // Undecorated public methods of C# test classes (attributes outside the parsed set)
+  if (sym.lang === 'csharp' && sym.kind === 'method' && sym.exported && !sym.decorators) return 'test'
+  return null
+}
+
+/**
+ * Base name a test file is named after ("user" for user.test.ts, test_user.py, UserTests.cs)
+ */
+function subjectStem(testPath: string): string {
+  return path.basename(testPath)
+    .replace(/\.(test|spec|bench)(?=\.)/, '')
+    .replace(/\.[^.]+$/, '')
+    .replace(/^(test|bench)_|_(test|bench)$|(Tests?|Benchmarks?)$/g, '')
+}
+
+/**
+ * Other non-test files a test file is named after, same directory first
+ */
+function subjectFiles(index: SymbolIndex, testPath: string): string[] {
+  const stem = subjectStem(testPath).toLowerCase()
+  const matches = index.listFiles().filter(f =>
+    f !== testPath && !isTestFile(f) && path.basename(f).replace(/\.[^.]+$/, '').toLowerCase() === stem
+  )
+  const dir = path.dirname(testPath).replace(/(^|\/)__tests__$/, '') || '.'
+  const local = matches.filter(f => path.dirname(f) === dir)
+  return local.length > 0 ? local : matches
+}
+
+function normalize(name: string): string {
+  return name.replace(/[._]/g, '').toLowerCase()
+}
+
+/**
+ * Function name without its test / benchmark / fuzz / example prefix
+ */
+function nameStem(name: string): string {
+  const short = shortName(name)
+  for (const [pattern] of NAME_KINDS) {
+    if (pattern.test(short)) return short.replace(pattern, '').replace(/^_/, '')
+  }
+  return short
+}
+
+/**
+ * Whether a test name or title points at a symbol
+ */
+function namesSubject(test: TestCase, fromFunction: boolean, sym: IndexedSymbol): boolean {
+  const name = shortName(sym.name)
+  if (!fromFunction) {
+    return (test.name.match(/[A-Za-z_$][\w$]*/g) || []).includes(name)
+  }
+  const stem = nameStem(test.name)
+  return normalize(stem) === normalize(name) || stem.startsWith(`${name}_`)
+}
+
+/**
+ * Symbol an example documents: ExampleUser_save -> User.save, ExampleParse -> Parse.
+ * A trailing lowercase _suffix only tells examples of one symbol apart.
+ */
+function exampleSubject(index: SymbolIndex, example: IndexedSymbol): IndexedSymbol | undefined {
+  let stem = nameStem(example.name)
+  while (stem) {
+    const key = normalize(stem)
+    const target = index.findSymbols(shortName(stem.replace(/_/g, '.')))
+      .find(s => !isTestFile(s.path) && normalize(s.name) === key)
+    if (target) return target
+    const cut = stem.search(/_[a-z][^_]*$/)
+    if (cut <= 0) break
+    stem = stem.slice(0, cut)
+  }
+  return undefined
+}
+
+function referencesIn(index: SymbolIndex, filePath: string, line: number, endLine: number): SymbolReference[] {
+  return (index.getFile(filePath)?.references || []).filter(r => r.line >= line && r.line <= endLine)
+}
+
+/**
+ * Compute cases and their subjects for the whole index
+ */
+export function computeTestMap(index: SymbolIndex): TestMap {
+  const map: TestMap = { tests: [], bySubject: new Map() }
+
+  function link(subject: IndexedSymbol, test: TestCase, via: TestLink['via']) {
+    const links = map.bySubject.get(subject.id) || []
+    const existing = links.find(l => l.test.id === test.id)
+    if (existing) {
+      if (VIA_RANK[via] > VIA_RANK[existing.via]) existing.via = via
+      return
+    }
+    links.push({ test, via })
+    map.bySubject.set(subject.id, links)
+  }
+
+  // Body references, walking into helpers declared in test files
+  function linkBody(test: TestCase, refs: SymbolReference[], visited: Set<string>) {
+    for (const ref of refs) {
+      if (ref.test_title !== undefined) continue
+      const candidates = index.findSymbols(ref.name)
+      const local = candidates.filter(s => s.path === ref.path)
+      for (const sym of local.length > 0 ? local : candidates) {
+        if (!isTestFile(sym.path)) {
+          link(sym, test, 'call')
+        } else if (CALLABLE_KINDS.has(sym.kind) && !testKindOf(sym) && !visited.has(sym.id)) {
+          visited.add(sym.id)
+          linkBody(test, referencesIn(index, sym.path, sym.line, sym.end_line), visited)
+        }
+      }
+    }
+  }
+
+  for (const filePath of index.listFiles()) {
+    const cases: { test: TestCase, fromFunction: boolean }[] = []
+    for (const sym of index.fileSymbols(filePath)) {
+      const kind = testKindOf(sym)
+      if (!kind) continue
+      const test: TestCase = { id: sym.id, kind, name: sym.name, path: sym.path, line: sym.line, end_line: sym.end_line }
+      if (kind === 'example') {
+        const subject = exampleSubject(index, sym)
+        if (subject) {
+          test.subject = subject.id
+          link(subject, test, 'example')
+        }
+      }
+      cases.push({ test, fromFunction: true })
+    }
+    if (isTestFile(filePath)) {
+      const refs = index.getFile(filePath)?.references || []
+      for (const ref of refs) {
+        if (ref.test_title === undefined) continue
+        const end = ref.test_end_line ?? ref.line
+        const fuzz = refs.some(r => r.line >= ref.line && r.line <= end && r.receiver === 'fc' && FUZZ_CALLS.has(r.name))
+        const kind = fuzz ? 'fuzz' : CALL_KINDS[ref.name] || 'test'
+        cases.push({ test: { id: `${filePath}@${ref.line}`, kind, name: ref.test_title, path: filePath, line: ref.line, end_line: end }, fromFunction: false })
+      }
+    }
+    if (cases.length === 0) continue
+
+    const subjects = subjectFiles(index, filePath).flatMap(f => index.fileSymbols(f))
+    for (const { test, fromFunction } of cases) {
+      map.tests.push(test)
+      linkBody(test, referencesIn(index, test.path, test.line, test.end_line), new Set())
+      if (test.subject) continue
+      for (const sym of subjects) {
+        if (namesSubject(test, fromFunction, sym)) link(sym, test, 'name')
+      }
+    }
+  }
+
+  for (const links of map.bySubject.values()) {
+    links.sort((a, b) => a.test.path.localeCompare(b.test.path) || a.test.line - b.test.line)
+  }
+  return map
+}
@@ -0,0 +1,75 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { findTodos, scanTodoComments } from './todo-comments.js'
+import { createIndex, type FixtureFiles, type FixtureOptions } from '../test-utils.js'
+
+const USER_SRC = `// TODO(alice): split this file
+export class User {
+  /**
+   * Save the user.
+   * FIXME: retries forever
+   */
+  save() {
+    const url = "http://example.com // TODO not a comment"
+    const note = \`
+      // HACK inside a template literal
+    \`
+    /* BUG: off by one */ return url + note
+  }
+}
+// The TODO list is long; not a marker
+// XXX
+export function helper() {}
+`
+
+const PY_SRC = `def run():
+    # FIXME(bob) flaky on CI
+    s = "# TODO not a comment"
+    return s // 2
+`
+
+const OPTIONS: FixtureOptions = { ownersOf: p => p.endsWith('.py') ? ['@org/data'] : ['@org/web'] }
+
+const FILES: FixtureFiles = {
+  'src/user.ts': [
+    { name: 'User', kind: 'class', line: 2, end_line: 14 },
+    { name: 'User.save', kind: 'method', line: 7, end_line: 13 },
+    { name: 'helper', kind: 'function', line: 17, end_line: 17 },
+    ...scanTodoComments(USER_SRC, 'typescript')
+  ],
+  'tools/run.py': [
+    { name: 'run', kind: 'function', line: 1, end_line: 4 },
+    ...scanTodoComments(PY_SRC, 'python')
+  ]
+}
+
+test('todo-comments: markers start the comment text; strings are skipped', () => {
+  assert.deepEqual(scanTodoComments(USER_SRC, 'typescript').map(t => [t.name, t.line, t.column, t.text, t.assignee]), [
+    ['TODO', 1, 4, 'split this file', 'alice'],
+    ['FIXME', 5, 6, 'retries forever', undefined],
+    ['BUG', 12, 8, 'off by one', undefined],
+    ['XXX', 16, 4, '', undefined]
+  ])
+  assert.deepEqual(scanTodoComments(PY_SRC, 'python').map(t => [t.name, t.line, t.assignee, t.text]), [
+    ['FIXME', 2, 'bob', 'flaky on CI']
+  ])
+})
+
+test('todo-comments: markers are stored on the shard and attached to the nearest symbol', () => {
+  const index = createIndex(FILES, OPTIONS)
+  assert.equal(index.getFile('src/user.ts')!.todos!.length, 4)
+  assert.deepEqual(findTodos(index).map(t => [t.path, t.line, t.marker, t.symbol?.name, t.owners]), [
+    ['src/user.ts', 1, 'TODO', 'User', ['@org/web']],
+    ['src/user.ts', 5, 'FIXME', 'User', ['@org/web']],
+    ['src/user.ts', 12, 'BUG', 'User.save', ['@org/web']],
+    ['src/user.ts', 16, 'XXX', 'helper', ['@org/web']],
+    ['tools/run.py', 2, 'FIXME', 'run', ['@org/data']]
+  ])
+})
+
+test('todo-comments: filter by marker, package and owner', () => {
+  const index = createIndex(FILES, OPTIONS)
+  assert.deepEqual(findTodos(index, { markers: ['fixme'] }).map(t => t.path), ['src/user.ts', 'tools/run.py'])
+  assert.deepEqual(findTodos(index, { packages: ['./tools/...'] }).map(t => t.line), [2])
+  assert.deepEqual(findTodos(index, { owners: ['org/web'], markers: ['BUG', 'XXX'] }).map(t => t.line), [12, 16])
+})
@@ -0,0 +1,166 @@
+/**
+ * TODO Comments Module
+ * Finds TODO / FIXME / HACK / BUG / XXX markers in comments while files are
+ * indexed, so they are stored with the file's shard and listed without
+ * re-reading sources. A marker counts when it starts the comment text (after
+ * any `*` of a block comment), optionally followed by an assignee in
+ * parentheses: `// TODO(alice): retry`, `# FIXME flaky`, ` * HACK: ...`.
+ * Each marker is attached at query time to the symbol it sits in, or to the
+ * declaration right below it.
+ */
+
+import { ownedBy } from './code-owners.js'
+import { isLocalSymbol } from './local-scopes.js'
+import { matchesPackage } from './symbol-query.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { FileShard, IndexedSymbol, SymbolInfo, TodoComment } from '../types/index.js'
+
+export const TODO_MARKERS = ['TODO', 'FIXME', 'HACK', 'BUG', 'XXX']
+
+const MARKER = new RegExp(`^[\\s*!/#-]*\\b(${TODO_MARKERS.join('|')})\\b(?:\\(([^)]*)\\))?:?(?=\\s|$)\\s*(.*)$`)
+
+// Comment syntax per language; anything else uses the C family's
+const HASH_COMMENTS = new Set(['python'])
+
+interface CommentLine {
+  text: string
+  line: number
+  column: number // 1-based column of the text
+}
+
+/**
+ * Lines of the comments in a source file, with strings skipped
+ */
+function commentLines(content: string, lang: string): CommentLine[] {
+  const lines: CommentLine[] = []
+  const hash = HASH_COMMENTS.has(lang)
+  let line = 1
+  let lineStart = 0
+  let i = 0
+
+  // Comment text from `from` to the end of the line (or `end`, if sooner)
+  const take = (from: number, end: number) => {
+    const stop = content.indexOf('\n', from)
+    const to = Math.min(stop === -1 ? content.length : stop, end)
+    lines.push({ text: content.slice(from, to), line, column: from - lineStart + 1 })
+    return to
+  }
+  const newline = (at: number) => {
+    line++
+    lineStart = at + 1
+  }
+
+  while (i < content.length) {
+    const c = content[i]
+    if (c === '\n') {
+      newline(i)
+      i++
+    } else if (hash ? c === '#' : c === '/' && content[i + 1] === '/') {
+      i = take(i + (hash ? 1 : 2), content.length)
+    } else if (!hash && c === '/' && content[i + 1] === '*') {
+      const close = content.indexOf('*/', i + 2)
+      const end = close === -1 ? content.length : close
+      let from = i + 2
+      while (from < end) {
+        const to = take(from, end)
+        if (to < end) newline(to)
+        from = to + 1
+      }
+      i = end + 2
+    } else if (c === '"' || c === "'" || c === '`') {
+      const triple = hash && content.startsWith(c.repeat(3), i)
+      const quote = triple ? c.repeat(3) : c
+      i += quote.length
+      while (i < content.length && !content.startsWith(quote, i)) {
+        if (content[i] === '\\') i++
+        else if (content[i] === '\n') {
+          // Only template literals and triple-quoted strings span lines
+          if (c !== '`' && !triple) break
+          newline(i)
+        }
+        i++
+      }
+      i += quote.length
+    } else {
+      i++
+    }
+  }
+  return lines
+}
+
+/**
+ * Marker comments of a file as extractor entries (kind "todo"), which
+ * addFile stores on the shard
+ */
+export function scanTodoComments(content: string, lang: string): Partial<SymbolInfo>[] {
+  const todos: Partial<SymbolInfo>[] = []
+  for (const comment of commentLines(content, lang)) {
+    const match = MARKER.exec(comment.text)
+    if (!match) continue
+    const text = match[3].replace(/\*\/\s*$/, '').trim()
+    todos.push({
+      name: match[1],
+      kind: 'todo',
+      line: comment.line,
+      column: comment.column + comment.text.indexOf(match[1]),
+      text,
+      ...(match[2] ? { assignee: match[2].trim() } : {})
+    })
+  }
+  return todos
+}
+
+/**
+ * Symbol a marker belongs to: the innermost declaration around it, else the
+ * next one below it in the file
+ */
+export function todoSymbol(shard: FileShard, todo: TodoComment): IndexedSymbol | null {
+  let around: IndexedSymbol | null = null
+  let below: IndexedSymbol | null = null
+  for (const sym of shard.symbols) {
+    if (isLocalSymbol(sym)) continue
+    if (sym.line <= todo.line && todo.line <= sym.end_line) {
+      if (!around || sym.line > around.line || (sym.line === around.line && sym.end_line < around.end_line)) around = sym
+    } else if (sym.line > todo.line && (!below || sym.line < below.line)) {
+      below = sym
+    }
+  }
+  return around || below
+}
+
+export interface TodoQuery {
+  /** Restrict to these markers (case-insensitive) */
+  markers?: string[]
+  /** Restrict to these package patterns (see matchesPackage) */
+  packages?: string[]
+  /** Restrict to files with one of these CODEOWNERS owners */
+  owners?: string[]
+}
+
+export interface TodoEntry extends TodoComment {
+  path: string
+  symbol: IndexedSymbol | null
+  owners: string[]
+}
+
+/**
+ * Marker comments of the index, by file and line
+ */
+export function findTodos(index: SymbolIndex, query: TodoQuery = {}): TodoEntry[] {
+  const markers = query.markers && query.markers.length > 0 ? new Set(query.markers.map(m => m.toUpperCase())) : null
+  const packages = query.packages && query.packages.length > 0 ? query.packages : null
+  const owners = query.owners && query.owners.length > 0 ? query.owners : null
+
+  const entries: TodoEntry[] = []
+  for (const shard of index.listShards()) {
+    if (!shard.todos || shard.todos.length === 0) continue
+    if (packages && !packages.some(p => matchesPackage(shard.path, p))) continue
+    const fileOwners = index.owners(shard.path)
+    if (owners && !ownedBy(fileOwners, owners)) continue
+    for (const todo of shard.todos) {
+      if (markers && !markers.has(todo.marker)) continue
+      entries.push({ ...todo, path: shard.path, symbol: todoSymbol(shard, todo), owners: fileOwners })
+    }
+  }
+  return entries.sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : a.line - b.line))
+}
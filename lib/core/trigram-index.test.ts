@@ -0,0 +1,75 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { trigramQuery, trigramsOf } from './trigram-index.js'
+import { SymbolIndex, indexContent } from './symbol-index.js'
+import { createTrigramIndex } from '../test-utils.js'
+
+const FILES: Record<string, string> = {
+  'src/user.ts': 'export class User {\n  doWork() { return fetchUser(1) }\n}\n',
+  'src/team.ts': 'export class Team {\n  members = []\n}\n',
+  'src/util.ts': 'export function fetchTeam() {}\n'
+}
+
+async function read(filePath: string): Promise<string | null> {
+  return FILES[filePath] ?? null
+}
+
+test('trigram-index: trigramsOf lists trigrams without crossing lines', () => {
+  assert.deepEqual(Array.from(trigramsOf('abcd')), ['abc', 'bcd'])
+  assert.deepEqual(Array.from(trigramsOf('ab\ncd')), [])
+})
+
+test('trigram-index: trigramQuery extracts required literals', () => {
+  assert.deepEqual(trigramQuery('fetchUser'), [['fet', 'etc', 'tch', 'chu', 'hus', 'use', 'ser']])
+  assert.deepEqual(trigramQuery('User|Team'), [['use', 'ser'], ['tea', 'eam']])
+  // Optional characters and classes break literal runs
+  assert.deepEqual(trigramQuery('abcd?'), [['abc']])
+  assert.equal(trigramQuery('\\x41bc'), null)
+  assert.equal(trigramQuery('a.*b'), null)
+  assert.equal(trigramQuery('foo|.*'), null)
+})
+
+test('trigram-index: candidates are pruned by posting lists', () => {
+  const index = createTrigramIndex(FILES)
+  assert.deepEqual(index.candidates('fetch\\w+'), ['src/user.ts', 'src/util.ts'])
+  assert.deepEqual(index.candidates('class (User|Team)'), ['src/team.ts', 'src/user.ts'])
+  assert.deepEqual(index.candidates('members'), ['src/team.ts'])
+  assert.deepEqual(index.candidates('x'), index.listFiles())
+})
+
+test('trigram-index: search verifies matches and respects case', async () => {
+  const index = createTrigramIndex(FILES)
+  assert.deepEqual(await index.search('fetch\\w+\\(', read), [
+    { path: 'src/user.ts', line: 2, column: 21, text: '  doWork() { return fetchUser(1) }' },
+    { path: 'src/util.ts', line: 1, column: 17, text: 'export function fetchTeam() {}' }
+  ])
+  assert.equal((await index.search('FETCHUSER', read)).length, 0)
+  assert.equal((await index.search('FETCHUSER', read, { ignoreCase: true })).length, 1)
+  assert.equal((await index.search('export', read, { limit: 2 })).length, 2)
+})
+
+test('trigram-index: removing a file drops its postings', () => {
+  const index = createTrigramIndex(FILES)
+  index.remove('src/util.ts')
+  assert.deepEqual(index.candidates('fetchTeam'), [])
+  assert.equal(index.has('src/util.ts'), false)
+})
+
+test('trigram-index: a snapshot keeps the postings it was taken with', () => {
+  const index = createTrigramIndex(FILES)
+  const snapshot = index.snapshot()
+  index.remove('src/util.ts')
+  index.add('src/team.ts', 'export function fetchTeam() {}\n')
+  assert.deepEqual(snapshot.candidates('fetchTeam'), ['src/util.ts'])
+  assert.deepEqual(index.candidates('fetchTeam'), ['src/team.ts'])
+  assert.deepEqual(snapshot.candidates('members'), ['src/team.ts'])
+  assert.deepEqual(index.candidates('members'), [])
+})
+
+test('trigram-index: symbol index keeps text postings in step with files', async () => {
+  const index = new SymbolIndex()
+  await indexContent(index, 'src/user.ts', FILES['src/user.ts'])
+  assert.deepEqual(index.text.candidates('fetchUser'), ['src/user.ts'])
+  index.removeFile('src/user.ts')
+  assert.deepEqual(index.text.candidates('fetchUser'), [])
+})
@@ -0,0 +1,323 @@
+/**
+ * Trigram Index Module
+ * Posting lists from (case-folded) trigrams to files, in the style of
+ * codesearch/Zoekt. A regex is reduced to the trigrams any match must
+ * contain, which prunes the candidate files before the regex itself runs.
+ */
+
+export interface TextMatch {
+  path: string
+  line: number
+  column: number
+  text: string
+}
+
+export interface TextSearchOptions {
+  ignoreCase?: boolean
+  limit?: number
+}
+
+/**
+ * Trigram requirements for a regex: a match must contain every trigram of
+ * at least one branch. Null means nothing can be ruled out.
+ */
+export type TrigramQuery = string[][] | null
+
+// Characters consumed after an escape letter (\x41, \u{1F600}, \p{L}, \cJ, \k<name>, \12)
+const ESCAPE_PAYLOADS: Record<string, RegExp> = {
+  x: /^[0-9a-fA-F]{0,2}/,
+  u: /^(\{[0-9a-fA-F]*\}|[0-9a-fA-F]{0,4})/,
+  p: /^\{[^}]*\}/,
+  P: /^\{[^}]*\}/,
+  c: /^[a-zA-Z]/,
+  k: /^<[^>]*>/,
+  ...Object.fromEntries('0123456789'.split('').map(d => [d, /^[0-9]*/]))
+}
+
+/**
+ * Distinct trigrams of a string (already case-folded)
+ */
+export function trigramsOf(text: string): Set<string> {
+  const result = new Set<string>()
+  for (let i = 0; i + 3 <= text.length; i++) {
+    const gram = text.slice(i, i + 3)
+    if (!gram.includes('\n')) result.add(gram)
+  }
+  return result
+}
+
+/**
+ * Split a pattern at top-level '|' (outside groups and classes)
+ */
+function splitAlternation(pattern: string): string[] {
+  const branches: string[] = []
+  let depth = 0
+  let inClass = false
+  let start = 0
+  for (let i = 0; i < pattern.length; i++) {
+    const c = pattern[i]
+    if (c === '\\') {
+      i++
+      continue
+    }
+    if (inClass) {
+      if (c === ']') inClass = false
+      continue
+    }
+    if (c === '[') inClass = true
+    else if (c === '(') depth++
+    else if (c === ')') depth--
+    else if (c === '|' && depth === 0) {
+      branches.push(pattern.slice(start, i))
+      start = i + 1
+    }
+  }
+  branches.push(pattern.slice(start))
+  return branches
+}
+
+/**
+ * Literal runs every match of an alternation-free pattern must contain
+ */
+function requiredLiterals(pattern: string): string[] {
+  const literals: string[] = []
+  let run = ''
+  const flush = () => {
+    if (run.length > 0) literals.push(run)
+    run = ''
+  }
+
+  for (let i = 0; i < pattern.length; i++) {
+    const c = pattern[i]
+    if (c === '\\') {
+      const next = pattern[++i]
+      if (next === undefined || !/[0-9a-zA-Z]/.test(next)) {
+        if (next !== undefined) run += next
+        continue
+      }
+      // Classes, escapes and backreferences break the run; skip their payload
+      flush()
+      const payload = pattern.slice(i + 1).match(ESCAPE_PAYLOADS[next] || /^/)
+      i += payload ? payload[0].length : 0
+      continue
+    }
+    if (c === '[') {
+      flush()
+      while (i < pattern.length && pattern[i] !== ']') {
+        if (pattern[i] === '\\') i++
+        i++
+      }
+      continue
+    }
+    if (c === '(') {
+      // Groups are opaque: skip to the matching parenthesis
+      flush()
+      let depth = 1
+      while (++i < pattern.length && depth > 0) {
+        if (pattern[i] === '\\') i++
+        else if (pattern[i] === '(') depth++
+        else if (pattern[i] === ')') depth--
+      }
+      i--
+      continue
+    }
+    if (c === '?' || c === '*' || (c === '{' && /^\{0?,/.test(pattern.slice(i)))) {
+      // The previous character is optional
+      run = run.slice(0, -1)
+      flush()
+      if (c === '{') i = pattern.indexOf('}', i)
+      if (i === -1) break
+      continue
+    }
+    if (c === '+' || c === '{') {
+      flush()
+      if (c === '{') i = pattern.indexOf('}', i)
+      if (i === -1) break
+      continue
+    }
+    if (c === '.' || c === '^' || c === '$' || c === ')') {
+      flush()
+      continue
+    }
+    run += c
+  }
+  flush()
+  return literals
+}
+
+/**
+ * Reduce a regex to the trigrams its matches must contain
+ */
+export function trigramQuery(pattern: string): TrigramQuery {
+  const branches: string[][] = []
+  for (const branch of splitAlternation(pattern)) {
+    const grams = new Set<string>()
+    for (const literal of requiredLiterals(branch)) {
+      for (const gram of trigramsOf(literal.toLowerCase())) grams.add(gram)
+    }
+    // One unconstrained branch means any file can match
+    if (grams.size === 0) return null
+    branches.push(Array.from(grams))
+  }
+  return branches
+}
+
+/**
+ * In-memory trigram posting lists over indexed source files. A layer made
+ * with layer() records its own files over a base index and hides the base's
+ * entries for them, without copying or changing the base. A copy made with
+ * snapshot() keeps the postings as they were: the tables are shared until
+ * either side writes, and posting lists are copied as writes reach them.
+ */
+export class TrigramIndex {
+  private postings = new Map<string, Set<string>>()
+  private fileTrigrams = new Map<string, string[]>()
+  private base: TrigramIndex | null = null
+  // Base files this layer replaces or removes
+  private hidden = new Set<string>()
+  // Tables are shared with a snapshot and must be copied before the next write
+  private shared = false
+  // Posting lists created since the tables were last copied, safe to change in place
+  private ownedLists = new WeakSet<Set<string>>()
+
+  /**
+   * Copy of the postings as they are now that later writes to either side
+   * do not reach
+   */
+  snapshot(): TrigramIndex {
+    const copy = new TrigramIndex()
+    copy.postings = this.postings
+    copy.fileTrigrams = this.fileTrigrams
+    copy.base = this.base
+    copy.hidden = this.hidden
+    copy.shared = true
+    this.shared = true
+    return copy
+  }
+
+  private beforeWrite() {
+    if (!this.shared) return
+    this.postings = new Map(this.postings)
+    this.fileTrigrams = new Map(this.fileTrigrams)
+    this.hidden = new Set(this.hidden)
+    this.ownedLists = new WeakSet()
+    this.shared = false
+  }
+
+  // Posting list of a trigram that this index may change
+  private ownList(gram: string): Set<string> {
+    const list = this.postings.get(gram)
+    if (list && this.ownedLists.has(list)) return list
+    const owned = new Set(list)
+    this.postings.set(gram, owned)
+    this.ownedLists.add(owned)
+    return owned
+  }
+
+  /**
+   * Writable layer over this index; later changes to this index show through
+   * for files the layer has not touched
+   */
+  layer(): TrigramIndex {
+    const layer = new TrigramIndex()
+    layer.base = this
+    return layer
+  }
+
+  /**
+   * Drop a layer's own entry for a file so the base's shows through again
+   */
+  revert(filePath: string): void {
+    this.beforeWrite()
+    this.removeOwn(filePath)
+    this.hidden.delete(filePath)
+  }
+
+  add(filePath: string, content: string): void {
+    this.remove(filePath)
+    const grams = trigramsOf(content.toLowerCase())
+    for (const gram of grams) this.ownList(gram).add(filePath)
+    this.fileTrigrams.set(filePath, Array.from(grams))
+  }
+
+  remove(filePath: string): boolean {
+    this.beforeWrite()
+    const inBase = !!this.base && !this.hidden.has(filePath) && this.base.has(filePath)
+    if (this.base) this.hidden.add(filePath)
+    return this.removeOwn(filePath) || inBase
+  }
+
+  private removeOwn(filePath: string): boolean {
+    const grams = this.fileTrigrams.get(filePath)
+    if (!grams) return false
+    for (const gram of grams) {
+      if (!this.postings.has(gram)) continue
+      const list = this.ownList(gram)
+      list.delete(filePath)
+      if (list.size === 0) this.postings.delete(gram)
+    }
+    this.fileTrigrams.delete(filePath)
+    return true
+  }
+
+  has(filePath: string): boolean {
+    if (this.fileTrigrams.has(filePath)) return true
+    return !!this.base && !this.hidden.has(filePath) && this.base.has(filePath)
+  }
+
+  listFiles(): string[] {
+    const files = new Set(this.fileTrigrams.keys())
+    for (const filePath of this.base ? this.base.listFiles() : []) {
+      if (!this.hidden.has(filePath)) files.add(filePath)
+    }
+    return Array.from(files).sort()
+  }
+
+  /**
+   * Files that may contain a match for the pattern, sorted by path
+   */
+  candidates(pattern: string): string[] {
+    const query = trigramQuery(pattern)
+    if (!query) return this.listFiles()
+
+    const result = new Set<string>()
+    for (const filePath of this.base ? this.base.candidates(pattern) : []) {
+      if (!this.hidden.has(filePath)) result.add(filePath)
+    }
+    for (const branch of query) {
+      // Intersect starting from the rarest trigram
+      const lists = branch.map(g => this.postings.get(g) || new Set<string>())
+      lists.sort((a, b) => a.size - b.size)
+      for (const filePath of lists[0]) {
+        if (lists.every(list => list.has(filePath))) result.add(filePath)
+      }
+    }
+    return Array.from(result).sort()
+  }
+
+  /**
+   * Run a regex over the candidate files, one match per line
+   * @param readFile - Returns current file content, or null if unreadable
+   */
+  async search(
+    pattern: string,
+    readFile: (filePath: string) => Promise<string | null>,
+    options: TextSearchOptions = {}
+  ): Promise<TextMatch[]> {
+    const regex = new RegExp(pattern, options.ignoreCase ? 'i' : '')
+    const matches: TextMatch[] = []
+
+    for (const filePath of this.candidates(pattern)) {
+      const content = await readFile(filePath)
+      if (content === null) continue
+      const lines = content.split('\n')
+      for (let i = 0; i < lines.length; i++) {
+        const found = regex.exec(lines[i])
+        if (!found) continue
+        matches.push({ path: filePath, line: i + 1, column: found.index + 1, text: lines[i] })
+        if (options.limit && matches.length >= options.limit) return matches
+      }
+    }
+    return matches
+  }
+}
@@ -0,0 +1,54 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { makeSymbolId } from './symbol-index.js'
+import { parseAliasUsage } from './type-aliases.js'
+import { selectSymbols } from './query-language.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+// models.ts: class User; types.ts: type Account = models.User, type Member = Account
+const FILES: FixtureFiles = {
+  'src/models.ts': [
+    { name: 'User', kind: 'class', line: 1, end_line: 3, column: 14, exported: true }
+  ],
+  'src/types.ts': [
+    { name: 'Account', kind: 'type', line: 2, end_line: 2, column: 13, exported: true, alias_of: 'models.User' },
+    { name: 'Member', kind: 'type', line: 3, end_line: 3, column: 13, exported: true, alias_of: 'Account' },
+    { name: 'Cycle', kind: 'type', line: 4, end_line: 4, column: 13, exported: true, alias_of: 'Cycle' }
+  ],
+  'src/app.ts': [
+    { name: 'User', kind: 'reference', line: 1, end_line: 1, column: 10, role: 'type' },
+    { name: 'Account', kind: 'reference', line: 2, end_line: 2, column: 10, role: 'type' },
+    { name: 'Member', kind: 'reference', line: 3, end_line: 3, column: 10, role: 'type' }
+  ]
+}
+
+const user = makeSymbolId('src/models.ts', 'User')
+const account = makeSymbolId('src/types.ts', 'Account')
+const member = makeSymbolId('src/types.ts', 'Member')
+
+test('type-aliases: aliases resolve through chains to the underlying type', () => {
+  const index = createIndex(FILES)
+
+  assert.equal(index.aliasTarget(account)?.id, user)
+  assert.equal(index.aliasTarget(member)?.id, user)
+  assert.equal(index.aliasTarget(makeSymbolId('src/types.ts', 'Cycle')), null)
+  assert.equal(index.aliasTarget(user), null)
+  assert.deepEqual(index.aliasesOf(user).map(s => s.name), ['Account', 'Member'])
+  assert.deepEqual(index.aliasesOf(account).map(s => s.name), ['Member'])
+})
+
+test('type-aliases: references report uses via aliases only when asked', () => {
+  const index = createIndex(FILES)
+
+  assert.deepEqual(index.references(user).map(r => r.line), [1])
+  assert.deepEqual(
+    index.references(user, undefined, 'all', 'alias').map(r => [r.line, r.via]),
+    [[2, account], [3, member]]
+  )
+  assert.deepEqual(index.references(user, undefined, 'all', 'all').map(r => r.line), [1, 2, 3])
+  assert.deepEqual(index.references(account, undefined, 'all', 'all').map(r => [r.line, r.via]), [[2, undefined], [3, member]])
+
+  assert.deepEqual(selectSymbols(index, 'aliasRefs.count = 2').map(s => s.name), ['User'])
+  assert.deepEqual(selectSymbols(index, 'aliasOf.name = "User"').map(s => s.name), ['Account', 'Member'])
+  assert.throws(() => parseAliasUsage('indirect'), /Unknown usage "indirect"/)
+})
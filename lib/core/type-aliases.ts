@@ -0,0 +1,80 @@
+/**
+ * Type Aliases Module
+ * Aliases declared for another type: TypeScript type aliases that name one
+ * type (type Foo = bar.Baz, type Users = Map<string, User>), C# using
+ * aliases (using Foo = Bar.Baz;) and Python type aliases (type Foo = Baz,
+ * Foo: TypeAlias = Baz). Extractors record the aliased name as alias_of on
+ * the alias's `type` symbol; this pass resolves it the way heritage names
+ * resolve (a type of the alias's own file first, then one definition
+ * anywhere, a qualified name by its last segment when the qualifier is a
+ * module binding) and follows aliases of aliases down to the underlying
+ * type. A reference to an alias is then a use of every type the chain
+ * passes through, made "via" the alias; references() reports those uses
+ * when asked for them, so renames and exports keep seeing only the names
+ * actually written.
+ */
+
+import { INTERFACE_KINDS, TYPE_KINDS } from './implementations.js'
+import { shortName, type SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+// Uses a query asks for: of the name itself, through aliases, or both
+export type AliasUsage = 'direct' | 'alias' | 'all'
+
+export const ALIAS_USAGES: AliasUsage[] = ['direct', 'alias', 'all']
+
+// Kinds an alias can name
+const ALIASED_KINDS = new Set([...TYPE_KINDS, ...INTERFACE_KINDS, 'type', 'enum'])
+
+export interface AliasTable {
+  targets: Map<string, string> // alias ID -> ID of the type it names
+  underlying: Map<string, string> // alias ID -> ID of the type at the end of its chain
+  aliases: Map<string, string[]> // type ID -> IDs of the aliases naming it, directly or through other aliases
+}
+
+/**
+ * Parse which uses to report (direct, alias or all)
+ */
+export function parseAliasUsage(value: string): AliasUsage {
+  if (!ALIAS_USAGES.includes(value as AliasUsage)) {
+    throw new Error(`Unknown usage "${value}". Use ${ALIAS_USAGES.join(', ')}`)
+  }
+  return value as AliasUsage
+}
+
+/**
+ * Type an alias's alias_of names, or null when it names none or several
+ */
+export function resolveAliasName(index: SymbolIndex, alias: IndexedSymbol): IndexedSymbol | null {
+  const name = String(alias.alias_of)
+  const matching = (found: IndexedSymbol[]) => found.filter(s => s.id !== alias.id && ALIASED_KINDS.has(s.kind))
+  let candidates = matching(index.findSymbols(name))
+  if (candidates.length === 0 && name.includes('.')) candidates = matching(index.findSymbols(shortName(name)))
+  const local = candidates.filter(s => s.path === alias.path)
+  const resolved = local.length > 0 ? local : candidates
+  return resolved.length === 1 ? resolved[0] : null
+}
+
+/**
+ * Resolve every alias in the index, and its chain down to the underlying type
+ */
+export function computeAliases(index: SymbolIndex): AliasTable {
+  const table: AliasTable = { targets: new Map(), underlying: new Map(), aliases: new Map() }
+  for (const sym of index.allSymbols()) {
+    if (!sym.alias_of) continue
+    const target = resolveAliasName(index, sym)
+    if (target) table.targets.set(sym.id, target.id)
+  }
+  for (const aliasId of table.targets.keys()) {
+    const seen = new Set([aliasId])
+    for (let id = table.targets.get(aliasId); id && !seen.has(id); id = table.targets.get(id)) {
+      seen.add(id)
+      table.underlying.set(aliasId, id)
+      const named = table.aliases.get(id)
+      if (named) named.push(aliasId)
+      else table.aliases.set(id, [aliasId])
+    }
+  }
+  for (const named of table.aliases.values()) named.sort()
+  return table
+}
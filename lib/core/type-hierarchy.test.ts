@@ -0,0 +1,79 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { makeSymbolId } from './symbol-index.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const SRC = `export interface Reader {
+  read(): string
+}
+
+export interface Closer {
+  close(): void
+}
+
+export interface ReadCloser extends Reader {
+  close(): void
+}
+
+export interface ReadSeekCloser {
+  read(): string
+  seek(n: number): void
+  close(): void
+}
+
+export class File implements ReadCloser {
+  read() { return '' }
+  close() {}
+  seek(n: number) {}
+}
+
+export class TempFile extends File {}
+
+export class Buffer {
+  read() { return '' }
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/io.ts': SRC
+}
+
+const id = (name: string) => makeSymbolId('src/io.ts', name)
+
+function names(items: { symbol: { name: string }, relation: string, depth: number }[]): string[] {
+  return items.map(i => `${i.symbol.name}:${i.relation}:${i.depth}`)
+}
+
+test('type-hierarchy: wider interfaces by extends and by member subset', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(names(index.supertypes(id('ReadCloser'))), ['Reader:extends:1', 'Closer:structural:1'])
+  // ReadCloser already covers Reader and Closer
+  assert.deepEqual(names(index.supertypes(id('ReadSeekCloser'))), ['ReadCloser:structural:1'])
+  assert.deepEqual(names(index.supertypes(id('ReadSeekCloser'), true)), [
+    'ReadCloser:structural:1',
+    'Reader:extends:2',
+    'Closer:structural:2'
+  ])
+})
+
+test('type-hierarchy: classes list base types and satisfied interfaces', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(names(index.supertypes(id('File'))), ['ReadCloser:implements:1', 'ReadSeekCloser:structural:1'])
+  assert.deepEqual(names(index.supertypes(id('TempFile'))), ['File:extends:1'])
+  assert.deepEqual(names(index.supertypes(id('Buffer'))), ['Reader:structural:1'])
+})
+
+test('type-hierarchy: subtypes are the reverse, transitively', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(names(index.subtypes(id('Reader'))), [
+    'ReadCloser:extends:1',
+    'Buffer:structural:1'
+  ])
+  assert.deepEqual(names(index.subtypes(id('Reader'), true)), [
+    'ReadCloser:extends:1',
+    'Buffer:structural:1',
+    'ReadSeekCloser:structural:2',
+    'File:implements:2',
+    'TempFile:extends:3'
+  ])
+})
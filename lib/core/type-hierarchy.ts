@@ -0,0 +1,161 @@
+/**
+ * Type Hierarchy Module
+ * Analysis pass behind LSP-style supertype / subtype queries. Types point up
+ * to their base types and to the interfaces they declare or structurally
+ * satisfy; interfaces point up to the interfaces they extend and to every
+ * wider interface whose members they include. Structural edges already
+ * implied by another path upwards are dropped, so each level lists only the
+ * nearest supertypes.
+ */
+
+import { computeImplementations, heritageOf, INTERFACE_KINDS, resolveTypeName, TYPE_KINDS } from './implementations.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export type HierarchyRelation = 'extends' | 'implements' | 'structural'
+
+export interface HierarchyEdge {
+  sub: string // symbol ID of the narrower type
+  super: string // symbol ID of the wider type
+  relation: HierarchyRelation
+}
+
+export interface TypeHierarchy {
+  supertypes: Map<string, HierarchyEdge[]> // type ID -> edges up
+  subtypes: Map<string, HierarchyEdge[]> // type ID -> edges down
+}
+
+export interface HierarchyItem {
+  symbol: IndexedSymbol
+  relation: HierarchyRelation
+  depth: number // 1 for direct supertypes / subtypes
+}
+
+/**
+ * Compute the direct supertype and subtype edges for the whole index
+ * @param include - Types and interfaces to consider (all by default), such as those of one module
+ */
+export function computeTypeHierarchy(index: SymbolIndex, include?: (sym: IndexedSymbol) => boolean): TypeHierarchy {
+  const all = include ? index.allSymbols().filter(include) : index.allSymbols()
+  const types = all.filter(s => TYPE_KINDS.has(s.kind))
+  const ifaces = all.filter(s => INTERFACE_KINDS.has(s.kind))
+  const up = new Map<string, HierarchyEdge[]>()
+
+  function add(sub: IndexedSymbol, sup: IndexedSymbol, relation: HierarchyRelation) {
+    if (sub.id === sup.id) return
+    const edges = up.get(sub.id) || []
+    if (edges.some(e => e.super === sup.id)) return
+    edges.push({ sub: sub.id, super: sup.id, relation })
+    up.set(sub.id, edges)
+  }
+
+  // Declared heritage
+  for (const sym of [...types, ...ifaces]) {
+    for (const name of heritageOf(sym)) {
+      for (const parent of resolveTypeName(index, name, sym.path)) {
+        if (INTERFACE_KINDS.has(sym.kind) && !INTERFACE_KINDS.has(parent.kind)) continue
+        if (include && !include(parent)) continue
+        add(sym, parent, INTERFACE_KINDS.has(parent.kind) && !INTERFACE_KINDS.has(sym.kind) ? 'implements' : 'extends')
+      }
+    }
+  }
+
+  // Interface member sets, including members of extended interfaces
+  const requiredCache = new Map<string, Set<string>>()
+  function requiredOf(iface: IndexedSymbol, visiting = new Set<string>()): Set<string> {
+    if (requiredCache.has(iface.id)) return requiredCache.get(iface.id)!
+    const result = new Set<string>(iface.members || [])
+    if (visiting.has(iface.id)) return result
+    visiting.add(iface.id)
+    for (const edge of up.get(iface.id) || []) {
+      const parent = index.getSymbol(edge.super)
+      if (parent) for (const m of requiredOf(parent, visiting)) result.add(m)
+    }
+    requiredCache.set(iface.id, result)
+    return result
+  }
+
+  // Structural edges: satisfied interfaces, and narrower -> wider interfaces by member subset
+  const structural: [IndexedSymbol, IndexedSymbol][] = []
+  const table = computeImplementations(index, include)
+  for (const type of types) {
+    for (const ifaceId of table.interfaces.get(type.id) || []) {
+      const iface = index.getSymbol(ifaceId)
+      if (iface) structural.push([type, iface])
+    }
+  }
+  for (const narrow of ifaces) {
+    const have = requiredOf(narrow)
+    for (const wide of ifaces) {
+      const need = requiredOf(wide)
+      if (wide === narrow || need.size === 0 || need.size >= have.size) continue
+      if ([...need].every(m => have.has(m))) structural.push([narrow, wide])
+    }
+  }
+
+  function reachable(from: string, target: string, skip: HierarchyEdge | null): boolean {
+    const seen = new Set<string>([from])
+    const queue = [from]
+    while (queue.length > 0) {
+      for (const edge of up.get(queue.shift()!) || []) {
+        if (edge === skip || seen.has(edge.super)) continue
+        if (edge.super === target) return true
+        seen.add(edge.super)
+        queue.push(edge.super)
+      }
+    }
+    return false
+  }
+
+  for (const [sub, sup] of structural) {
+    if (!reachable(sub.id, sup.id, null)) add(sub, sup, 'structural')
+  }
+  // Keep only the nearest structural supertypes
+  for (const [id, edges] of up) {
+    const kept = edges.filter(e => e.relation !== 'structural' || !reachable(id, e.super, e))
+    up.set(id, kept)
+  }
+
+  const hierarchy: TypeHierarchy = { supertypes: up, subtypes: new Map() }
+  for (const edges of up.values()) {
+    for (const edge of edges) {
+      hierarchy.subtypes.set(edge.super, [...(hierarchy.subtypes.get(edge.super) || []), edge])
+    }
+  }
+  return hierarchy
+}
+
+/**
+ * Supertypes or subtypes of a type, nearest first; with `transitive` the
+ * walk continues to the top (or bottom) of the hierarchy
+ */
+export function walkHierarchy(
+  index: SymbolIndex,
+  hierarchy: TypeHierarchy,
+  typeId: string,
+  direction: 'supertypes' | 'subtypes',
+  transitive = false
+): HierarchyItem[] {
+  const result: HierarchyItem[] = []
+  const seen = new Set<string>([typeId])
+  let level = [typeId]
+  for (let depth = 1; level.length > 0; depth++) {
+    const next: string[] = []
+    const items: HierarchyItem[] = []
+    for (const id of level) {
+      for (const edge of hierarchy[direction].get(id) || []) {
+        const other = direction === 'supertypes' ? edge.super : edge.sub
+        const symbol = index.getSymbol(other)
+        if (!symbol || seen.has(other)) continue
+        seen.add(other)
+        next.push(other)
+        items.push({ symbol, relation: edge.relation, depth })
+      }
+    }
+    items.sort((a, b) => a.symbol.path.localeCompare(b.symbol.path) || a.symbol.line - b.symbol.line)
+    result.push(...items)
+    if (!transitive) break
+    level = next
+  }
+  return result
+}
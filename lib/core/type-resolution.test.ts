@@ -0,0 +1,119 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { SymbolIndex } from './symbol-index.js'
+import { definitionAt, loadTypeScript, resolveWithChecker } from './type-resolution.js'
+import type { SymbolReference } from '../types/index.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER = `export class User {
+  save() {}
+}
+`
+const TEAM = `export class Team {
+  save() {}
+}
+`
+const MAIN = `import { User as Account } from './user'
+import { Team } from './team'
+
+export function main(team: Team) {
+  const account = new Account()
+  account.save()
+  team.save()
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': [
+    { name: 'User', kind: 'class', line: 1, end_line: 3, column: 14, exported: true },
+    { name: 'User.save', kind: 'method', line: 2, end_line: 2, column: 3 }
+  ],
+  'src/team.ts': [
+    { name: 'Team', kind: 'class', line: 1, end_line: 3, column: 14, exported: true },
+    { name: 'Team.save', kind: 'method', line: 2, end_line: 2, column: 3 }
+  ],
+  'src/main.ts': [
+    { name: 'User', kind: 'reference', line: 1, column: 10, source: './user' },
+    { name: 'Account', kind: 'reference', line: 1, column: 18, source: './user' },
+    { name: 'Team', kind: 'reference', line: 2, column: 10, source: './team' },
+    { name: 'main', kind: 'function', line: 4, end_line: 8, column: 17, exported: true },
+    { name: 'Team', kind: 'reference', line: 4, column: 28 },
+    { name: 'Account', kind: 'reference', line: 5, column: 23, call: true },
+    { name: 'save', kind: 'reference', line: 6, column: 11, call: true, property: true, receiver: 'account' },
+    { name: 'save', kind: 'reference', line: 7, column: 8, call: true, property: true, receiver: 'team' }
+  ]
+}
+
+function at(index: SymbolIndex, line: number, column: number): SymbolReference {
+  return index.getFile('src/main.ts')!.references.find(r => r.line === line && r.column === column)!
+}
+
+test('type-resolution: bound references replace the name rules', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.references('src/user.ts#User.save').map(r => r.line), [6, 7])
+
+  const targets = new Map<SymbolReference, string>([
+    [at(index, 1, 18), 'src/user.ts#User'],
+    [at(index, 5, 23), 'src/user.ts#User'],
+    [at(index, 6, 11), 'src/user.ts#User.save'],
+    [at(index, 7, 8), 'src/team.ts#Team.save']
+  ])
+  index.bindings = {
+    targetOf: ref => targets.get(ref),
+    boundTo: id => [...targets].filter(([, target]) => target === id).map(([ref]) => ref)
+  }
+
+  assert.deepEqual(index.references('src/user.ts#User.save').map(r => r.line), [6])
+  assert.deepEqual(index.references('src/team.ts#Team.save').map(r => r.line), [7])
+  // Imported under another name, unbound references keep the name rules
+  assert.deepEqual(index.references('src/user.ts#User').map(r => `${r.line}:${r.column}`), ['1:10', '1:18', '5:23'])
+  assert.deepEqual(index.symbolAt('src/main.ts', 6, 12)!.symbols.map(s => s.id), ['src/user.ts#User.save'])
+  assert.deepEqual(index.callees('src/main.ts#main').map(s => s.id).sort(), ['src/team.ts#Team.save', 'src/user.ts#User.save'])
+
+  // Declared outside the index
+  targets.set(at(index, 7, 8), '')
+  assert.deepEqual(index.symbolAt('src/main.ts', 7, 8)!.symbols, [])
+  assert.deepEqual(index.snapshot().references('src/team.ts#Team.save'), [])
+})
+
+test('type-resolution: declarations map to indexed definitions', () => {
+  const index = createIndex(FILES)
+  assert.equal(definitionAt(index, 'src/user.ts', 'save', 2, 3)!.id, 'src/user.ts#User.save')
+  // Without a column, the innermost definition of the name around the line
+  assert.equal(definitionAt(index, 'src/user.ts', 'save', 2, 1)!.id, 'src/user.ts#User.save')
+  assert.equal(definitionAt(index, 'src/user.ts', 'reset', 2, 1), null)
+})
+
+test('type-resolution: INDEXER_TYPESCRIPT picks the compiler', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'type-resolution-test-'))
+  try {
+    await fs.writeFile(path.join(root, 'patched-tsc.cjs'), 'module.exports = { version: "patched" }\n')
+    assert.equal((await loadTypeScript(root, './patched-tsc.cjs') as any).version, 'patched')
+    await assert.rejects(loadTypeScript(root, './missing-tsc.cjs'), /Cannot load the TypeScript compiler \.\/missing-tsc\.cjs/)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('type-resolution: the type checker binds references', async (t) => {
+  const ts = await loadTypeScript(process.cwd()).catch(() => null)
+  if (!ts) return t.skip('typescript is not installed')
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'type-resolution-test-'))
+  try {
+    await fs.mkdir(path.join(root, 'src'))
+    await fs.writeFile(path.join(root, 'src/user.ts'), USER)
+    await fs.writeFile(path.join(root, 'src/team.ts'), TEAM)
+    await fs.writeFile(path.join(root, 'src/main.ts'), MAIN)
+    const index = createIndex(FILES)
+
+    const stats = resolveWithChecker(ts, index, root)
+    assert.deepEqual(stats, { resolved: 7, external: 0, unresolved: 0 })
+    assert.deepEqual(index.references('src/user.ts#User.save').map(r => r.line), [6])
+    assert.deepEqual(index.references('src/user.ts#User').map(r => `${r.line}:${r.column}`), ['1:10', '1:18', '5:23'])
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,183 @@
+/**
+ * Type Resolution Module
+ * The precise indexing mode (--precision=full). The fast mode resolves a
+ * reference by its name: to the definitions of that name its file can see,
+ * fanning out when there are several. The full mode type-checks the
+ * project's JavaScript and TypeScript with the TypeScript compiler (the
+ * project's own copy, else the indexer's) and binds each reference to the
+ * definition the checker resolves it to, so imports, re-exports, shadowing
+ * and calls through typed receivers (user.save()) land on one symbol.
+ * Bindings are kept beside the index, not in its store; references the
+ * checker cannot resolve, and files in other languages, keep the name rules.
+ * The compiler, the project file and extra compiler flags can be swapped for
+ * setups the defaults do not fit (INDEXER_TYPESCRIPT, --tsconfig,
+ * INDEXER_TSFLAGS).
+ */
+
+import path from 'path'
+import { createRequire } from 'module'
+import type * as TS from 'typescript'
+import { shortName } from './symbol-index.js'
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol, SymbolReference } from '../types/index.js'
+
+export type Precision = 'fast' | 'full'
+
+export const PRECISIONS: Precision[] = ['fast', 'full']
+
+const CHECKED_LANGUAGES = new Set(['javascript', 'typescript'])
+
+export interface CheckerOptions {
+  tsconfig?: string // project file, relative to the project root; tsconfig.json when it exists
+  compilerFlags?: string // tsc command-line options laid over the project file's (--jsx react-jsx --customConditions dev)
+}
+
+export interface ResolutionStats {
+  resolved: number // bound to an indexed definition
+  external: number // bound to a declaration outside the index (lib.d.ts, unindexed packages)
+  unresolved: number // left to the name rules
+}
+
+/**
+ * The TypeScript compiler: `compiler` when given (a module name or path,
+ * resolved from the project root), else the project's own copy, else the
+ * indexer's
+ */
+export async function loadTypeScript(projectRoot: string, compiler = process.env.INDEXER_TYPESCRIPT): Promise<typeof TS> {
+  const require = createRequire(path.join(projectRoot, 'package.json'))
+  if (compiler) {
+    try {
+      return require(compiler)
+    } catch (e) {
+      throw new Error(`Cannot load the TypeScript compiler ${compiler}: ${(e as Error).message}`)
+    }
+  }
+  try {
+    return require('typescript')
+  } catch {
+    try {
+      return (await import('typescript')).default
+    } catch {
+      throw new Error('--precision=full needs the typescript package; install it in the project (npm install -D typescript)')
+    }
+  }
+}
+
+/**
+ * Indexed definition declared at a position (1-based), else the innermost
+ * definition of that name whose lines cover it
+ */
+export function definitionAt(index: SymbolIndex, filePath: string, name: string, line: number, column: number): IndexedSymbol | null {
+  const symbols = index.fileSymbols(filePath)
+  const declared = symbols.find(s => s.line === line && s.column === column)
+  if (declared) return declared
+  const covering = symbols.filter(s => shortName(s.name) === name && s.line <= line && line <= (s.end_line ?? s.line))
+  return covering.sort((a, b) => b.line - a.line)[0] || null
+}
+
+function diagnosticText(ts: typeof TS, diagnostic: TS.Diagnostic): string {
+  return ts.flattenDiagnosticMessageText(diagnostic.messageText, ' ')
+}
+
+function createProgram(ts: typeof TS, projectRoot: string, files: string[], checker: CheckerOptions): TS.Program {
+  let flags: TS.CompilerOptions = {}
+  if (checker.compilerFlags) {
+    const parsed = ts.parseCommandLine(checker.compilerFlags.split(/\s+/).filter(Boolean))
+    if (parsed.errors.length > 0) throw new Error(`Invalid compiler flags: ${diagnosticText(ts, parsed.errors[0])}`)
+    flags = parsed.options
+  }
+  let options = flags
+  let rootNames: string[] = []
+  const configPath = path.resolve(projectRoot, checker.tsconfig || 'tsconfig.json')
+  if (checker.tsconfig && !ts.sys.fileExists(configPath)) throw new Error(`TypeScript project file ${checker.tsconfig} not found`)
+  if (ts.sys.fileExists(configPath)) {
+    const parsed = ts.getParsedCommandLineOfConfigFile(configPath, flags, {
+      ...ts.sys,
+      onUnRecoverableConfigFileDiagnostic: (diagnostic) => {
+        throw new Error(`${path.relative(projectRoot, configPath)}: ${diagnosticText(ts, diagnostic)}`)
+      }
+    })
+    if (parsed) {
+      options = parsed.options
+      rootNames = parsed.fileNames
+    }
+  }
+  // Every indexed file is checked, inside the project file's includes or not
+  const names = new Set([...rootNames, ...files])
+  return ts.createProgram({ rootNames: [...names], options: { ...options, allowJs: true, noEmit: true } })
+}
+
+/**
+ * Type-check the index's JavaScript and TypeScript files and bind their
+ * references to the definitions the checker resolves them to (see
+ * SymbolIndex.bindings)
+ */
+export function resolveWithChecker(ts: typeof TS, index: SymbolIndex, projectRoot: string, options: CheckerOptions = {}): ResolutionStats {
+  const shards = index.listShards().filter(s => CHECKED_LANGUAGES.has(s.lang))
+  const program = createProgram(ts, projectRoot, shards.map(s => path.join(projectRoot, s.path)), options)
+  const checker = program.getTypeChecker()
+  const targets = new WeakMap<SymbolReference, string>()
+  const bound = new Map<string, SymbolReference[]>()
+  const stats: ResolutionStats = { resolved: 0, external: 0, unresolved: 0 }
+
+  const targetOf = (node: TS.Identifier): string | undefined => {
+    let symbol = checker.getSymbolAtLocation(node)
+    if (symbol && symbol.flags & ts.SymbolFlags.Alias) symbol = checker.getAliasedSymbol(symbol)
+    const declaration = symbol && (symbol.valueDeclaration || symbol.declarations?.[0])
+    if (!declaration) return undefined
+    const source = declaration.getSourceFile()
+    const relPath = path.relative(projectRoot, source.fileName).split(path.sep).join('/')
+    if (relPath.startsWith('..') || !index.getFile(relPath)) return ''
+    const name = ts.getNameOfDeclaration(declaration) || declaration
+    const { line, character } = source.getLineAndCharacterOfPosition(name.getStart(source))
+    return definitionAt(index, relPath, ts.isIdentifier(name) ? name.text : '', line + 1, character + 1)?.id || ''
+  }
+
+  for (const shard of shards) {
+    const source = program.getSourceFile(path.join(projectRoot, shard.path))
+    if (!source) {
+      stats.unresolved += shard.references.length
+      continue
+    }
+    const refs = new Map(shard.references.map(ref => [`${ref.line}:${ref.column}`, ref]))
+    const visit = (node: TS.Node): void => {
+      if (ts.isIdentifier(node)) {
+        const { line, character } = source.getLineAndCharacterOfPosition(node.getStart(source))
+        const ref = refs.get(`${line + 1}:${character + 1}`)
+        if (ref && ref.name === node.text) {
+          refs.delete(`${ref.line}:${ref.column}`)
+          const target = targetOf(node)
+          if (target === undefined) {
+            stats.unresolved++
+          } else {
+            targets.set(ref, target)
+            if (target) {
+              const refsOf = bound.get(target)
+              if (refsOf) refsOf.push(ref)
+              else bound.set(target, [ref])
+              stats.resolved++
+            } else {
+              stats.external++
+            }
+          }
+        }
+      }
+      ts.forEachChild(node, visit)
+    }
+    visit(source)
+    stats.unresolved += refs.size
+  }
+
+  index.bindings = {
+    targetOf: (ref) => targets.get(ref),
+    boundTo: (symbolId) => bound.get(symbolId) || []
+  }
+  return stats
+}
+
+/**
+ * Resolve an index opened from the project's sources with the type checker
+ */
+export async function resolveReferences(index: SymbolIndex, projectRoot: string, options: CheckerOptions = {}): Promise<ResolutionStats> {
+  return resolveWithChecker(await loadTypeScript(projectRoot), index, projectRoot, options)
+}
@@ -0,0 +1,43 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { querySymbols } from './symbol-query.js'
+import { selectSymbols } from './query-language.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'lib/log.ts': [
+    { name: 'log', kind: 'function', line: 1, end_line: 3, exported: true },
+    { name: 'trace', kind: 'function', line: 5, end_line: 7, exported: true },
+    { name: 'unused', kind: 'function', line: 9, end_line: 11, exported: true }
+  ],
+  'api/users.ts': [
+    { name: 'getUser', kind: 'function', line: 1, end_line: 6 },
+    { name: 'trace', kind: 'reference', line: 2, column: 3, call: true },
+    { name: 'trace', kind: 'reference', line: 3, column: 3, call: true },
+    { name: 'trace', kind: 'reference', line: 4, column: 3, call: true },
+    { name: 'log', kind: 'reference', line: 5, column: 3, call: true }
+  ],
+  'web/page.ts': [
+    { name: 'render', kind: 'function', line: 1, end_line: 3 },
+    { name: 'log', kind: 'reference', line: 2, column: 3, call: true }
+  ]
+}
+
+test('usage-stats: references, files and packages per symbol', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(index.usage('lib/log.ts#trace'), { refs: 3, files: 1, packages: 1 })
+  assert.deepEqual(index.usage('lib/log.ts#log'), { refs: 2, files: 2, packages: 2 })
+  assert.deepEqual(index.usage('lib/log.ts#unused'), { refs: 0, files: 0, packages: 0 })
+})
+
+test('usage-stats: ranking query results by usage', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(querySymbols(index, { exported: true, sort: 'refs' }).map(s => s.name), ['trace', 'log', 'unused'])
+  assert.deepEqual(querySymbols(index, { exported: true, sort: 'packages' }).map(s => s.name), ['log', 'trace', 'unused'])
+  assert.deepEqual(querySymbols(index, { exported: true, sort: 'packages', limit: 1 }).map(s => s.name), ['log'])
+  assert.deepEqual(selectSymbols(index, 'usage.packages >= 2').map(s => s.name), ['log'])
+
+  // Recomputed when the index changes
+  index.addFile('cli/main.ts', 'typescript', [{ name: 'trace', kind: 'reference', line: 1, column: 1, call: true }])
+  assert.deepEqual(index.usage('lib/log.ts#trace'), { refs: 4, files: 2, packages: 2 })
+})
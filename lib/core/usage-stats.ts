@@ -0,0 +1,78 @@
+/**
+ * Usage Stats Module
+ * How much each symbol is used: its references, the files they are in and
+ * the distinct packages those files belong to (their module, or their
+ * directory without a package graph). Computed once per index version for
+ * the whole index, so ranking by popularity and "most used APIs" reports
+ * cost one pass. References resolve as in references().
+ */
+
+import type { SymbolIndex } from './symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export interface SymbolUsage {
+  refs: number // references, declaration excluded
+  files: number // distinct referencing files
+  packages: number // distinct referencing packages
+}
+
+export type UsageTable = Map<string, SymbolUsage>
+
+export const USAGE_SORTS = ['refs', 'packages'] as const
+export type UsageSort = typeof USAGE_SORTS[number]
+
+const UNUSED: SymbolUsage = { refs: 0, files: 0, packages: 0 }
+
+/**
+ * Package a file belongs to: its module when the index has a package
+ * graph, else its directory
+ */
+export function packageOf(index: SymbolIndex, filePath: string): string {
+  const module = index.moduleOf ? index.moduleOf(filePath) : null
+  if (module) return module.name
+  return filePath.includes('/') ? filePath.slice(0, filePath.lastIndexOf('/')) : '.'
+}
+
+/**
+ * Usage of every symbol that has references
+ */
+export function computeUsageStats(index: SymbolIndex): UsageTable {
+  const table: UsageTable = new Map()
+  const packages = new Map<string, string>()
+  const pkg = (filePath: string) => {
+    let name = packages.get(filePath)
+    if (name === undefined) {
+      name = packageOf(index, filePath)
+      packages.set(filePath, name)
+    }
+    return name
+  }
+  for (const sym of index.allSymbols()) {
+    const refs = index.references(sym.id)
+    if (refs.length === 0) continue
+    table.set(sym.id, {
+      refs: refs.length,
+      files: new Set(refs.map(r => r.path)).size,
+      packages: new Set(refs.map(r => pkg(r.path))).size
+    })
+  }
+  return table
+}
+
+export function usageOf(table: UsageTable, symbolId: string): SymbolUsage {
+  return table.get(symbolId) || UNUSED
+}
+
+/**
+ * Order symbols most used first: by references or by referencing packages,
+ * the other count breaking ties. Equally used symbols compare equal.
+ */
+export function compareUsage(table: UsageTable, by: UsageSort): (a: IndexedSymbol, b: IndexedSymbol) => number {
+  return (a, b) => {
+    const ua = usageOf(table, a.id)
+    const ub = usageOf(table, b.id)
+    const [first, second] = by === 'packages' ? ['packages', 'refs'] as const : ['refs', 'packages'] as const
+    if (ua[first] !== ub[first]) return ub[first] - ua[first]
+    return ub[second] - ua[second]
+  }
+}
@@ -0,0 +1,72 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { findDeadCode } from './dead-code.js'
+import { querySymbols } from './symbol-query.js'
+import { listProjectFiles, resetConfigCache } from './file-filters.js'
+import { isVendored, resolveVendorModules, vendorModuleOf, vendoredPackageDir } from './vendor-trees.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+// lodash is both vendored and installed (indexed with --deps)
+const FILES: FixtureFiles = {
+  'node_modules/lodash/debounce.d.ts': [{ name: 'debounce', kind: 'function', line: 1, end_line: 1, column: 17, exported: true }],
+  'vendor/lodash/debounce.js': [{ name: 'debounce', kind: 'function', line: 3, end_line: 9, column: 10, exported: true }],
+  'vendor/lodash/throttle.js': [
+    { name: 'throttle', kind: 'function', line: 1, end_line: 4, exported: true },
+    { name: 'debounce', kind: 'reference', line: 2, column: 10, call: true }
+  ],
+  'src/app.ts': [
+    { name: 'start', kind: 'function', line: 1, end_line: 3 },
+    { name: 'debounce', kind: 'reference', line: 2, column: 3, call: true }
+  ]
+}
+
+test('vendor-trees: vendored symbols are external and resolve on their side of vendor/', async () => {
+  const index = createIndex(FILES, {
+    externalOf: isVendored,
+    moduleOf: vendorModuleOf(new Map([['vendor/lodash', { name: 'lodash', version: '4.17.21' }]]))
+  })
+  const vendored = index.getSymbol('vendor/lodash/debounce.js#debounce')!
+  assert.deepEqual([vendored.external, vendored.module, vendored.module_version], [true, 'lodash', '4.17.21'])
+  assert.equal(index.getSymbol('node_modules/lodash/debounce.d.ts#debounce')!.external, undefined)
+
+  assert.deepEqual(index.symbolAt('src/app.ts', 2, 3)!.symbols.map(s => s.path), ['node_modules/lodash/debounce.d.ts'])
+  assert.deepEqual(index.symbolAt('vendor/lodash/throttle.js', 2, 10)!.symbols.map(s => s.path), ['vendor/lodash/debounce.js'])
+  assert.deepEqual(index.references('node_modules/lodash/debounce.d.ts#debounce').map(r => r.path), ['src/app.ts'])
+  assert.deepEqual(index.references(vendored.id).map(r => r.path), ['vendor/lodash/throttle.js'])
+
+  assert.deepEqual(querySymbols(index, { external: true }).map(s => s.id), ['vendor/lodash/debounce.js#debounce', 'vendor/lodash/throttle.js#throttle'])
+  // throttle is unused, but not the project's to delete
+  assert.deepEqual(findDeadCode(index).map(s => s.name), ['start'])
+})
+
+test('vendor-trees: package dirs and modules of vendored code', async () => {
+  assert.equal(vendoredPackageDir('vendor/@acme/ui/src/button.ts'), 'vendor/@acme/ui')
+  assert.equal(vendoredPackageDir('services/api/vendor/left-pad/index.js'), 'services/api/vendor/left-pad')
+  assert.equal(vendoredPackageDir('src/vendors/x.ts'), null)
+
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'vendor-trees-test-'))
+  try {
+    for (const [relPath, content] of Object.entries({
+      '.indexer/to-index': 'ext: .ts\next: .js\nvendor: skip\n',
+      'vendor/lodash/package.json': JSON.stringify({ name: 'lodash', version: '4.17.21' }),
+      'vendor/lodash/debounce.js': '',
+      'vendor/legacy/util.js': '',
+      'src/app.ts': ''
+    })) {
+      await fs.mkdir(path.dirname(path.join(root, relPath)), { recursive: true })
+      await fs.writeFile(path.join(root, relPath), content)
+    }
+    resetConfigCache(root)
+    const moduleOf = vendorModuleOf(await resolveVendorModules(root))
+    assert.deepEqual(moduleOf('vendor/lodash/debounce.js'), { name: 'lodash', version: '4.17.21' })
+    assert.deepEqual(moduleOf('vendor/legacy/util.js'), { name: 'legacy', version: '' })
+    assert.equal(moduleOf('src/app.ts'), null)
+    assert.deepEqual(await listProjectFiles(root), ['src/app.ts'])
+  } finally {
+    resetConfigCache(root)
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,95 @@
+/**
+ * Vendor Trees Module
+ * How vendor/ directories (copies of third-party code checked into the
+ * project) are indexed, set by a vendor: entry in .indexer/to-index:
+ *
+ *   vendor: full         # index them like the project's own code (default)
+ *   vendor: dependency   # index them as third-party code
+ *   vendor: skip         # leave them out
+ *
+ * As dependencies, vendored definitions carry `external: true` and the
+ * vendored package as their module; dead code and API reports leave them
+ * out. Where the same definition is also indexed outside vendor/ (from
+ * node_modules with --deps), uses outside vendor/ resolve to that copy and
+ * uses inside it to the vendored one, instead of to both.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import fg from 'fast-glob'
+import type { ModuleInfo } from './package-graph.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+export type VendorMode = 'full' | 'dependency' | 'skip'
+
+export const VENDOR_MODES: VendorMode[] = ['full', 'dependency', 'skip']
+
+const VENDOR_DIR = /(^|\/)vendor\//
+
+export function parseVendorMode(value: string | undefined): VendorMode {
+  return VENDOR_MODES.includes(value as VendorMode) ? value as VendorMode : 'full'
+}
+
+/**
+ * Whether a project-relative path is inside a vendor/ directory
+ */
+export function isVendored(relPath: string): boolean {
+  return VENDOR_DIR.test(relPath)
+}
+
+/**
+ * Directory of the vendored package a path belongs to: the segment after
+ * vendor/, or two for a scoped package (vendor/@acme/ui)
+ */
+export function vendoredPackageDir(relPath: string): string | null {
+  const match = relPath.match(/^(.*?(?:^|\/)vendor\/)(@[^/]+\/[^/]+|[^/@][^/]*)\//)
+  return match ? match[1] + match[2] : null
+}
+
+/**
+ * Name and version of the vendored packages that have a package.json, by
+ * package dir
+ */
+export async function resolveVendorModules(projectRoot: string): Promise<Map<string, ModuleInfo>> {
+  const manifests = await fg(['**/vendor/*/package.json', '**/vendor/@*/*/package.json'], {
+    cwd: projectRoot, onlyFiles: true, followSymbolicLinks: false, ignore: ['**/node_modules/**', '**/.git/**']
+  })
+  const modules = new Map<string, ModuleInfo>()
+  for (const file of manifests.sort()) {
+    try {
+      const manifest = JSON.parse(await fs.readFile(path.join(projectRoot, file), 'utf8'))
+      const dir = path.posix.dirname(file)
+      if (manifest.name) modules.set(dir, { name: manifest.name, version: manifest.version || '' })
+    } catch {}
+  }
+  return modules
+}
+
+/**
+ * Module resolver for vendored files (the package dir name when it has no
+ * package.json), null outside vendor/
+ */
+export function vendorModuleOf(modules: Map<string, ModuleInfo>): (filePath: string) => ModuleInfo | null {
+  return (filePath) => {
+    const dir = vendoredPackageDir(filePath)
+    if (!dir) return null
+    return modules.get(dir) || { name: dir.slice(dir.lastIndexOf('vendor/') + 'vendor/'.length), version: '' }
+  }
+}
+
+/**
+ * Whether a definition is also indexed on the other side of vendor/
+ * (vendored and not, or the other way round)
+ */
+export function hasCopyAcrossVendor(sym: IndexedSymbol, namesakes: IndexedSymbol[]): boolean {
+  return namesakes.some(s => !!s.external !== !!sym.external && s.name === sym.name && s.kind === sym.kind)
+}
+
+/**
+ * Definitions an identifier resolves to, keeping only the copy on the
+ * identifier's side of vendor/ of those indexed on both
+ * @param external - Whether the identifier is in vendored code
+ */
+export function sameSideOfVendor(candidates: IndexedSymbol[], external: boolean): IndexedSymbol[] {
+  return candidates.filter(s => !!s.external === external || !hasCopyAcrossVendor(s, candidates))
+}
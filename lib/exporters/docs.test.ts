@@ -0,0 +1,102 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { buildDocs, exportDocs, packageComment } from './docs.js'
+import { parseJSDoc } from '../utils/doc-comments.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const INDEX_SRC = `/**
+ * User accounts and their storage.
+ */
+export * from './user'
+`
+
+const EXAMPLES_SRC = `export function ExampleUser_save() {
+  const user = newUser('ada')
+  user.save()
+}
+
+export function Example() {
+  console.log(MAX_USERS)
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/users/index.ts': [],
+  'src/users/user.ts': [
+    { name: 'MAX_USERS', kind: 'const', line: 1, end_line: 1, column: 14, exported: true },
+    { name: 'cache', kind: 'variable', line: 2, end_line: 2, column: 12, exported: true },
+    {
+      name: 'User', kind: 'class', line: 4, end_line: 10, column: 14, exported: true,
+      signature: 'class User', doc_info: parseJSDoc('A registered user.\n@since 1.2')
+    },
+    { name: 'User.save', kind: 'method', line: 5, end_line: 5, column: 3, exported: true, signature: 'save(): Promise<void>' },
+    { name: 'User.secret', kind: 'property', line: 6, end_line: 6, column: 11, exported: false },
+    {
+      name: 'newUser', kind: 'function', line: 12, end_line: 12, column: 17, exported: true,
+      signature: 'newUser(name: string): User', doc_info: parseJSDoc('Create a user.\n@param name - Login name\n@example\nnewUser("ada")')
+    },
+    {
+      name: 'loadUser', kind: 'function', line: 13, end_line: 13, column: 23, exported: true,
+      signature: 'loadUser(id: string): Promise<User>'
+    },
+    {
+      name: 'resetAll', kind: 'function', line: 14, end_line: 14, column: 17, exported: true,
+      signature: 'resetAll(): void', doc_info: parseJSDoc('@deprecated Use reset')
+    },
+    { name: 'helper', kind: 'function', line: 15, end_line: 15, column: 10 }
+  ],
+  'src/users/user.example.test.ts': [
+    { name: 'ExampleUser_save', kind: 'function', line: 1, end_line: 4, column: 17, exported: true },
+    { name: 'Example', kind: 'function', line: 6, end_line: 8, column: 17, exported: true }
+  ]
+}
+
+const readSource = (p: string) => ({ 'src/users/index.ts': INDEX_SRC, 'src/users/user.example.test.ts': EXAMPLES_SRC } as Record<string, string>)[p]
+
+test('docs: packages group exported declarations like godoc', () => {
+  const docs = buildDocs(createIndex(FILES), { readSource, toolVersion: '1.0.0' })
+  assert.equal(docs.format, 1)
+  assert.deepEqual(docs.tool, { name: 'indexer', version: '1.0.0' })
+  assert.equal(docs.packages.length, 1)
+
+  const [pkg] = docs.packages
+  assert.equal(pkg.path, 'src/users')
+  assert.equal(pkg.doc, 'User accounts and their storage.')
+  assert.deepEqual(pkg.files, ['src/users/user.ts'])
+  assert.deepEqual(pkg.constants.map(d => d.name), ['MAX_USERS'])
+  assert.deepEqual(pkg.variables.map(d => d.name), ['cache'])
+  // Constructors move under their type
+  assert.deepEqual(pkg.functions.map(d => d.name), ['resetAll'])
+  assert.equal(pkg.functions[0].deprecated, 'Use reset')
+
+  const [user] = pkg.types
+  assert.equal(user.summary, 'A registered user.')
+  assert.equal(user.since, '1.2')
+  assert.deepEqual(user.members!.map(m => m.name), ['save'])
+  assert.deepEqual(user.constructors!.map(c => c.name), ['loadUser', 'newUser'])
+  assert.deepEqual(user.constructors![1].params, [{ name: 'name', text: 'Login name' }])
+  assert.deepEqual(user.constructors![1].examples, [{ name: '', code: 'newUser("ada")' }])
+
+  // Example functions attach to the symbol they are named after, or the package
+  assert.deepEqual(user.members![0].examples, [{
+    name: 'ExampleUser_save',
+    code: "export function ExampleUser_save() {\n  const user = newUser('ada')\n  user.save()\n}",
+    path: 'src/users/user.example.test.ts',
+    line: 1
+  }])
+  assert.deepEqual(pkg.examples.map(e => e.name), ['Example'])
+})
+
+test('docs: package filter and JSON output', () => {
+  const index = createIndex(FILES)
+  assert.deepEqual(buildDocs(index, { packages: ['./lib/...'] }).packages, [])
+  const docs = JSON.parse(exportDocs(index, { packages: ['./src/...'], packageInfo: { name: 'users', version: '2.0.0' } }))
+  assert.deepEqual(docs.package, { name: 'users', version: '2.0.0' })
+  assert.deepEqual(docs.packages.map((p: any) => p.path), ['src/users'])
+})
+
+test('docs: package comments', () => {
+  assert.equal(packageComment('src/a.ts', '/**\n * Helpers.\n * @packageDocumentation\n */\n'), 'Helpers.')
+  assert.equal(packageComment('src/a.ts', '/** Not a package comment */\nexport const a = 1\n'), undefined)
+  assert.equal(packageComment('pkg/__init__.py', '"""Data loaders.\n\n.. versionadded:: 3.0\n"""\n'), 'Data loaders.')
+})
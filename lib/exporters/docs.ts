@@ -0,0 +1,258 @@
+/**
+ * Documentation Exporter
+ * Writes the index as structured package documentation for a static docs
+ * site generator, in the shape of godoc's JSON: one entry per package
+ * (directory) with its package comment, and its exported declarations
+ * grouped into constants, variables, functions and types. Types carry their
+ * members and, as in godoc, the functions that construct them (functions
+ * returning the type). Declarations carry their parsed doc comment, the
+ * version they appeared in (@since, .. versionadded::) and their examples:
+ * the doc comment's own and the example functions that document them
+ * (ExampleUser_save); examples named after no symbol belong to the package.
+ */
+
+import path from 'path'
+import { deprecationNotice } from '../core/deprecations.js'
+import { packageOf } from '../core/import-graph.js'
+import { symbolSignature } from '../core/signature-search.js'
+import { matchesPackageDir } from '../core/symbol-query.js'
+import { shortName } from '../core/symbol-index.js'
+import { computeTestMap, isTestFile, type TestCase } from '../core/test-map.js'
+import { parseJSDoc, parsePythonDoc, type DocInfo, type DocParam } from '../utils/doc-comments.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+import type { PackageInfo } from './lsif.js'
+import type { SourceReader } from './tags.js'
+
+export const DOCS_FORMAT_VERSION = 1
+
+const CONSTANT_KINDS = new Set(['const', 'constant'])
+const VARIABLE_KINDS = new Set(['variable'])
+const FUNCTION_KINDS = new Set(['function', 'hook', 'function_component'])
+const TYPE_KINDS = new Set(['class', 'scriptable_object', 'interface', 'struct', 'enum', 'type', 'namespace'])
+
+// Leading comment tags that make a file's header the package comment
+const PACKAGE_DOC_TAG = /@(packageDocumentation|module|fileoverview|file)\b/
+const PACKAGE_FILES = /(^|\/)(index\.[cm]?[jt]sx?|__init__\.py|doc\.[jt]s)$/
+
+export interface DocsExportOptions {
+  readSource?: SourceReader
+  toolVersion?: string
+  packageInfo?: PackageInfo | null
+  packages?: string[] // package patterns (./lib/...); every package by default
+}
+
+export interface DocExample {
+  name: string // example function or test title, '' for a doc comment example
+  code: string
+  path?: string
+  line?: number
+}
+
+export interface DocDeclaration {
+  id: string
+  name: string
+  kind: string
+  path: string
+  line: number
+  signature?: string
+  summary?: string
+  params?: DocParam[]
+  returns?: string
+  links?: string[]
+  deprecated?: string // notice, '' when deprecated without one
+  since?: string
+  examples?: DocExample[]
+  members?: DocDeclaration[]
+  constructors?: DocDeclaration[] // functions returning the type
+}
+
+export interface DocPackage {
+  path: string // directory, '.' for the project root
+  module?: string // package the directory belongs to (package.json, pyproject.toml)
+  doc?: string // package comment
+  files: string[]
+  constants: DocDeclaration[]
+  variables: DocDeclaration[]
+  functions: DocDeclaration[]
+  types: DocDeclaration[]
+  examples: DocExample[]
+}
+
+export interface DocsExport {
+  format: number
+  tool: { name: string, version: string }
+  package?: PackageInfo
+  packages: DocPackage[]
+}
+
+function compareNames(a: { name: string }, b: { name: string }): number {
+  return a.name < b.name ? -1 : a.name > b.name ? 1 : 0
+}
+
+// Source lines an example case spans, undented
+function exampleCode(test: TestCase, readSource: SourceReader): string {
+  const lines = readSource(test.path)?.split('\n').slice(test.line - 1, test.end_line)
+  if (!lines) return ''
+  const indent = Math.min(...lines.filter(l => l.trim()).map(l => l.match(/^\s*/)![0].length))
+  return lines.map(l => l.slice(indent).replace(/\r$/, '')).join('\n')
+}
+
+/**
+ * Package comment of a file: a leading JSDoc block tagged @packageDocumentation,
+ * @module, @fileoverview or @file (any leading block in an index file), or a
+ * Python module docstring
+ */
+export function packageComment(filePath: string, content: string): string | undefined {
+  const text = content.replace(/^#![^\n]*\n/, '').trimStart()
+  if (filePath.endsWith('.py')) {
+    const docstring = text.match(/^[rubRUB]*("""|''')([\s\S]*?)\1/)
+    return docstring ? parsePythonDoc(docstring[2]).summary || undefined : undefined
+  }
+  const block = text.match(/^\/\*\*([\s\S]*?)\*\//)
+  if (!block || (!PACKAGE_DOC_TAG.test(block[1]) && !PACKAGE_FILES.test(filePath))) return undefined
+  const body = block[1]
+    .split('\n')
+    .map(l => l.replace(/^\s*\* ?/, '').trimEnd())
+    .join('\n')
+  return parseJSDoc(body.trim()).summary || undefined
+}
+
+/**
+ * Structured documentation of the index's packages
+ */
+export function buildDocs(index: SymbolIndex, options: DocsExportOptions = {}): DocsExport {
+  const readSource = options.readSource || (() => undefined)
+  const wanted = options.packages && options.packages.length > 0 ? options.packages : null
+  const testMap = computeTestMap(index)
+  const packages = new Map<string, DocPackage>()
+
+  const packageFor = (filePath: string): DocPackage => {
+    const dir = packageOf(filePath)
+    let pkg = packages.get(dir)
+    if (!pkg) {
+      const module = index.moduleOf ? index.moduleOf(filePath) : null
+      const doc = comments.get(dir)
+      pkg = {
+        path: dir,
+        ...(module ? { module: module.name } : {}),
+        ...(doc ? { doc } : {}),
+        files: [],
+        constants: [],
+        variables: [],
+        functions: [],
+        types: [],
+        examples: []
+      }
+      packages.set(dir, pkg)
+    }
+    return pkg
+  }
+
+  const declaration = (sym: IndexedSymbol): DocDeclaration => {
+    const info: DocInfo = sym.doc_info || { summary: '' }
+    const deprecated = deprecationNotice(sym)
+    const examples: DocExample[] = (info.examples || []).map(code => ({ name: '', code }))
+    for (const link of testMap.bySubject.get(sym.id) || []) {
+      if (link.via !== 'example') continue
+      examples.push({ name: link.test.name, code: exampleCode(link.test, readSource), path: link.test.path, line: link.test.line })
+    }
+    return {
+      id: sym.id,
+      name: sym.name,
+      kind: sym.kind,
+      path: sym.path,
+      line: sym.line,
+      ...(sym.signature ? { signature: sym.signature } : {}),
+      ...(info.summary ? { summary: info.summary } : {}),
+      ...(info.params && info.params.length > 0 ? { params: info.params } : {}),
+      ...(info.returns ? { returns: info.returns } : {}),
+      ...(info.links && info.links.length > 0 ? { links: info.links } : {}),
+      ...(deprecated !== undefined ? { deprecated } : {}),
+      ...(info.since ? { since: info.since } : {}),
+      ...(examples.length > 0 ? { examples } : {})
+    }
+  }
+
+  const documented = (filePath: string) =>
+    !isTestFile(filePath) && (!wanted || wanted.some(p => matchesPackageDir(packageOf(filePath), p)))
+
+  // Package comments, an index file's first, else the first file's
+  const files = index.listFiles().filter(documented).sort()
+  const comments = new Map<string, string>()
+  for (const filePath of files) {
+    const content = readSource(filePath)
+    const comment = content === undefined ? undefined : packageComment(filePath, content)
+    if (comment && (!comments.has(packageOf(filePath)) || PACKAGE_FILES.test(filePath))) comments.set(packageOf(filePath), comment)
+  }
+
+  for (const filePath of files) {
+    const symbols = index.fileSymbols(filePath).filter(s => s.exported && !s.generated && !s.external)
+    if (symbols.length === 0) continue
+    const pkg = packageFor(filePath)
+    pkg.files.push(filePath)
+
+    const types = new Map<string, DocDeclaration>()
+    for (const sym of symbols) {
+      if (sym.name.includes('.') || !TYPE_KINDS.has(sym.kind)) continue
+      const decl = declaration(sym)
+      types.set(sym.name, decl)
+      pkg.types.push(decl)
+    }
+    for (const sym of symbols) {
+      if (sym.name.includes('.')) {
+        const owner = types.get(sym.name.slice(0, sym.name.lastIndexOf('.')))
+        if (owner) (owner.members ||= []).push({ ...declaration(sym), name: shortName(sym.name) })
+      } else if (CONSTANT_KINDS.has(sym.kind)) {
+        pkg.constants.push(declaration(sym))
+      } else if (VARIABLE_KINDS.has(sym.kind)) {
+        pkg.variables.push(declaration(sym))
+      } else if (FUNCTION_KINDS.has(sym.kind)) {
+        pkg.functions.push(declaration(sym))
+      }
+    }
+  }
+
+  // Constructors: functions whose result is a type of their package
+  for (const pkg of packages.values()) {
+    const types = new Map(pkg.types.map(t => [t.name, t]))
+    pkg.functions = pkg.functions.filter(fn => {
+      const sym = index.getSymbol(fn.id)
+      const returns = sym && symbolSignature(sym)?.returns.replace(/^Promise<(.*)>$/, '$1')
+      const type = returns ? types.get(returns) : undefined
+      if (type) (type.constructors ||= []).push(fn)
+      return !type
+    })
+  }
+
+  // Examples named after no symbol document the package they sit in, or the
+  // nearest documented package above it
+  for (const test of testMap.tests) {
+    if (test.kind !== 'example' || test.subject) continue
+    let dir = packageOf(test.path)
+    while (!packages.has(dir) && dir !== '.') dir = path.posix.dirname(dir)
+    packages.get(dir)?.examples.push({ name: test.name, code: exampleCode(test, readSource), path: test.path, line: test.line })
+  }
+
+  for (const pkg of packages.values()) {
+    for (const group of [pkg.constants, pkg.variables, pkg.functions, pkg.types]) group.sort(compareNames)
+    for (const type of pkg.types) {
+      type.members?.sort(compareNames)
+      type.constructors?.sort(compareNames)
+    }
+  }
+
+  return {
+    format: DOCS_FORMAT_VERSION,
+    tool: { name: 'indexer', version: options.toolVersion || '0.0.0' },
+    ...(options.packageInfo ? { package: options.packageInfo } : {}),
+    packages: [...packages.values()].sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : 0))
+  }
+}
+
+/**
+ * Render the package documentation as JSON
+ */
+export function exportDocs(index: SymbolIndex, options: DocsExportOptions = {}): string {
+  return JSON.stringify(buildDocs(index, options), null, 2) + '\n'
+}
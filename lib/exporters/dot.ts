@@ -0,0 +1,94 @@
+/**
+ * DOT Exporter
+ * Renders the call graph as Graphviz DOT, one cluster per file, with dynamic
+ * (dispatch) edges dashed.
+ */
+
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { CallEdge } from '../core/call-graph.js'
+
+export interface DotExportOptions {
+  focus?: string[] // symbol IDs to center the graph on
+  depth?: number // hops from the focus symbols (default 2)
+}
+
+function quote(text: string): string {
+  return `"${text.replace(/\\/g, '\\\\').replace(/"/g, '\\"')}"`
+}
+
+/**
+ * Edges within `depth` hops of the focus symbols, in either direction
+ */
+function focusedEdges(index: SymbolIndex, focus: string[], depth: number): CallEdge[] {
+  const graph = index.callGraph()
+  const seen = new Set<string>(focus)
+  const edges = new Set<CallEdge>()
+  let frontier = focus
+  for (let hop = 0; hop < depth && frontier.length > 0; hop++) {
+    const next: string[] = []
+    for (const id of frontier) {
+      for (const edge of [...(graph.callees.get(id) || []), ...(graph.callers.get(id) || [])]) {
+        edges.add(edge)
+        for (const end of [edge.caller, edge.callee]) {
+          if (!seen.has(end)) {
+            seen.add(end)
+            next.push(end)
+          }
+        }
+      }
+    }
+    frontier = next
+  }
+  return graph.edges.filter(e => edges.has(e))
+}
+
+/**
+ * Render the call graph (or the neighbourhood of some symbols) as DOT
+ */
+export function exportCallGraphDot(index: SymbolIndex, options: DotExportOptions = {}): string {
+  const edges = options.focus && options.focus.length > 0
+    ? focusedEdges(index, options.focus, options.depth ?? 2)
+    : index.callGraph().edges
+
+  const nodes = new Set<string>(options.focus || [])
+  for (const e of edges) {
+    nodes.add(e.caller)
+    nodes.add(e.callee)
+  }
+
+  const byFile = new Map<string, string[]>()
+  for (const id of nodes) {
+    const sym = index.getSymbol(id)
+    if (!sym) continue
+    const list = byFile.get(sym.path) || []
+    list.push(id)
+    byFile.set(sym.path, list)
+  }
+
+  const lines = ['digraph calls {', '  rankdir=LR;', '  node [shape=box, fontname="Helvetica"];']
+  let cluster = 0
+  for (const filePath of Array.from(byFile.keys()).sort()) {
+    lines.push(`  subgraph cluster_${cluster++} {`)
+    lines.push(`    label=${quote(filePath)};`)
+    for (const id of byFile.get(filePath)!.sort()) {
+      const sym = index.getSymbol(id)!
+      lines.push(`    ${quote(id)} [label=${quote(sym.name)}, tooltip=${quote(`${sym.path}:${sym.line}`)}];`)
+    }
+    lines.push('  }')
+  }
+
+  // One arrow per caller/callee pair; dynamic only if every call site is
+  const pairs = new Map<string, { caller: string, callee: string, dynamic: boolean }>()
+  for (const e of edges) {
+    const key = `${e.caller}\u0000${e.callee}`
+    const existing = pairs.get(key)
+    if (existing) existing.dynamic = existing.dynamic && e.dynamic
+    else pairs.set(key, { caller: e.caller, callee: e.callee, dynamic: e.dynamic })
+  }
+  for (const p of pairs.values()) {
+    lines.push(`  ${quote(p.caller)} -> ${quote(p.callee)}${p.dynamic ? ' [style=dashed]' : ''};`)
+  }
+
+  lines.push('}')
+  return lines.join('\n') + '\n'
+}
@@ -0,0 +1,61 @@
+/**
+ * Import Graph Exporter
+ * Renders the package import graph as Graphviz DOT (external packages grey,
+ * edges inside an import cycle red) or as plain JSON for tools that do not
+ * read DOT.
+ */
+
+import { findImportCycles, importersOf, importsOf, type ImportGraph } from '../core/import-graph.js'
+
+export interface ImportGraphExportOptions {
+  external?: boolean // include external packages (default false)
+}
+
+function quote(text: string): string {
+  return `"${text.replace(/\\/g, '\\\\').replace(/"/g, '\\"')}"`
+}
+
+/**
+ * Render the package import graph as DOT
+ */
+export function exportImportGraphDot(graph: ImportGraph, options: ImportGraphExportOptions = {}): string {
+  const cycleOf = new Map<string, number>()
+  findImportCycles(graph).forEach((group, i) => group.forEach(pkg => cycleOf.set(pkg, i)))
+
+  const lines = ['digraph imports {', '  rankdir=LR;', '  node [shape=box, fontname="Helvetica"];']
+  for (const pkg of graph.packages) {
+    lines.push(`  ${quote(pkg)};`)
+  }
+  if (options.external) {
+    for (const pkg of graph.external) {
+      lines.push(`  ${quote(`ext:${pkg}`)} [label=${quote(pkg)}, shape=ellipse, color=grey, fontcolor=grey];`)
+    }
+  }
+  for (const edge of graph.edges) {
+    if (edge.external && !options.external) continue
+    const attrs = [`label=${quote(String(edge.imports.length))}`]
+    if (edge.external) attrs.push('color=grey')
+    else if (cycleOf.has(edge.from) && cycleOf.get(edge.from) === cycleOf.get(edge.to)) attrs.push('color=red')
+    lines.push(`  ${quote(edge.from)} -> ${quote(edge.external ? `ext:${edge.to}` : edge.to)} [${attrs.join(', ')}];`)
+  }
+  lines.push('}')
+  return lines.join('\n') + '\n'
+}
+
+/**
+ * Render the package import graph as JSON: packages with what they import
+ * and what imports them, every edge with its import sites, and the cycles
+ */
+export function exportImportGraphJson(graph: ImportGraph, options: ImportGraphExportOptions = {}): string {
+  const keep = (external: boolean) => options.external || !external
+  return JSON.stringify({
+    packages: graph.packages.map(name => ({
+      name,
+      imports: importsOf(graph, name).filter(e => keep(e.external)).map(e => e.to),
+      imported_by: importersOf(graph, name).map(e => e.from)
+    })),
+    ...(options.external ? { external: graph.external } : {}),
+    edges: graph.edges.filter(e => keep(e.external)),
+    cycles: findImportCycles(graph)
+  }, null, 2) + '\n'
+}
@@ -0,0 +1,61 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { Writable } from 'stream'
+import { memoryFileSource } from '../core/file-source.js'
+import { shardRecords, streamJsonl } from './jsonl.js'
+
+const FILES = {
+  'src/user.ts': 'export class User {\n  save() {}\n}\n',
+  'src/admin.ts': 'import { User } from "./user"\nexport function promote(u: User) { u.save() }\n',
+  'src/gen.ts': '// Code generated by protoc-gen-ts. DO NOT EDIT.\nexport const VERSION = 1\n'
+}
+
+function collect(): { out: Writable, records: () => any[] } {
+  const chunks: string[] = []
+  // A tiny buffer makes every write wait for 'drain'
+  const out = new Writable({
+    highWaterMark: 1,
+    write(chunk, _encoding, done) {
+      chunks.push(chunk.toString())
+      setImmediate(done)
+    }
+  })
+  return { out, records: () => chunks.join('').split('\n').filter(Boolean).map(line => JSON.parse(line)) }
+}
+
+test('jsonl: each file is a file record followed by its symbols and references', async () => {
+  const { out, records } = collect()
+  const update = await streamJsonl(memoryFileSource(FILES), out, { batchSize: 1 })
+
+  const rows = records()
+  assert.deepEqual(rows.filter(r => r.type === 'file').map(r => r.path), ['src/admin.ts', 'src/gen.ts', 'src/user.ts'])
+  assert.deepEqual(update.added, ['src/admin.ts', 'src/gen.ts', 'src/user.ts'])
+  const user = rows.findIndex(r => r.type === 'file' && r.path === 'src/user.ts')
+  assert.deepEqual(rows.slice(user + 1).filter(r => r.type === 'symbol').map(r => r.name), ['User', 'User.save'])
+  assert.ok(rows.some(r => r.type === 'reference' && r.path === 'src/admin.ts' && r.name === 'save'))
+  for (const row of rows) assert.ok(!('body_minhash' in row))
+})
+
+test('jsonl: references and generated files can be left out', async () => {
+  const { out, records } = collect()
+  await streamJsonl(memoryFileSource(FILES), out, { references: false, generated: false })
+
+  const rows = records()
+  assert.deepEqual(rows.filter(r => r.type === 'file').map(r => r.path), ['src/admin.ts', 'src/user.ts'])
+  assert.equal(rows.filter(r => r.type === 'reference').length, 0)
+})
+
+test('jsonl: shardRecords counts what the file holds', () => {
+  const lines = shardRecords({
+    path: 'a.ts',
+    lang: 'typescript',
+    hash: 'abc',
+    symbols: [{ id: 'a.ts:f:1', name: 'f', kind: 'function', path: 'a.ts', lang: 'typescript', line: 1, end_line: 1, body_minhash: [1, 2] }],
+    references: [{ name: 'g', path: 'a.ts', line: 1 }]
+  }).map(line => JSON.parse(line))
+  assert.deepEqual(lines, [
+    { type: 'file', path: 'a.ts', lang: 'typescript', hash: 'abc', symbols: 1, references: 1 },
+    { type: 'symbol', id: 'a.ts:f:1', name: 'f', kind: 'function', path: 'a.ts', lang: 'typescript', line: 1, end_line: 1 },
+    { type: 'reference', name: 'g', path: 'a.ts', line: 1 }
+  ])
+})
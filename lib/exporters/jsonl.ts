@@ -0,0 +1,69 @@
+/**
+ * JSON Lines Exporter
+ * Streams the index as one JSON record per line while files are being
+ * indexed: a "file" record, then the file's "symbol" records, then its
+ * "reference" records and the "diagnostic" records of its syntax errors. Each batch of files is written and dropped before
+ * the next one is parsed, and writes wait for the output to drain, so
+ * memory stays flat however big the tree is. Records are the stored symbol
+ * and reference fields plus `type`; MinHash vectors are left out.
+ */
+
+import { once } from 'events'
+import type { Writable } from 'stream'
+import { streamIndex } from '../core/index-stream.js'
+import { isGeneratedShard } from '../core/generated-code.js'
+import { redactShard, type RedactionClass } from '../core/redaction.js'
+import type { FileSource } from '../core/file-source.js'
+import type { IndexUpdate } from '../core/symbol-index.js'
+import type { FileShard } from '../types/index.js'
+
+export interface JsonlExportOptions {
+  references?: boolean // emit reference records (default true)
+  generated?: boolean // include generated files (default true)
+  batchSize?: number // files parsed per batch
+  redact?: RedactionClass[] // text to strip from the records (see redaction)
+}
+
+/**
+ * Records of one indexed file, one JSON document per element
+ */
+export function shardRecords(shard: FileShard, options: JsonlExportOptions = {}): string[] {
+  const lines = [JSON.stringify({
+    type: 'file',
+    path: shard.path,
+    lang: shard.lang,
+    hash: shard.hash,
+    symbols: shard.symbols.length,
+    references: shard.references.length
+  })]
+  for (const sym of shard.symbols) {
+    const { body_minhash: _, ...fields } = sym
+    lines.push(JSON.stringify({ type: 'symbol', ...fields }))
+  }
+  if (options.references !== false) {
+    for (const ref of shard.references) lines.push(JSON.stringify({ type: 'reference', ...ref }))
+  }
+  for (const diagnostic of shard.diagnostics || []) {
+    lines.push(JSON.stringify({ type: 'diagnostic', path: shard.path, ...diagnostic }))
+  }
+  return lines
+}
+
+// Resolves once the stream has room again, so a slow reader throttles indexing
+async function writeLines(out: Writable, lines: string[]): Promise<void> {
+  if (!out.write(lines.join('\n') + '\n')) await once(out, 'drain')
+}
+
+/**
+ * Index the files of a source and write their records to a stream as each
+ * batch finishes
+ */
+export async function streamJsonl(source: FileSource, out: Writable, options: JsonlExportOptions = {}): Promise<IndexUpdate> {
+  const { update } = await streamIndex(source, {
+    onFile: async (shard) => {
+      if (options.generated === false && isGeneratedShard(shard)) return
+      await writeLines(out, shardRecords(options.redact ? redactShard(shard, options.redact) : shard, options))
+    }
+  }, { batchSize: options.batchSize, retain: false })
+  return update
+}
@@ -0,0 +1,100 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { buildLsif, exportLsif, monikerIdentifier } from './lsif.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER_SRC = `
+/** A user of the system */
+export class User {
+  /** Perform the work */
+  doWork(): number {
+    return 1
+  }
+}
+
+function helper() {}
+`
+
+const MAIN_SRC = `
+import { User } from './user'
+
+const u = new User()
+u.doWork()
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': USER_SRC,
+  'src/main.ts': MAIN_SRC
+}
+
+function byLabel(elements: any[], label: string): any[] {
+  return elements.filter(e => e.label === label)
+}
+
+test('lsif: emits metadata, project and one document per file', () => {
+  const elements = buildLsif(createIndex(FILES), { projectRoot: '/repo', toolVersion: '1.2.3' })
+
+  assert.equal(elements[0].label, 'metaData')
+  assert.equal(elements[0].version, '0.4.3')
+  assert.equal(elements[0].projectRoot, 'file:///repo')
+  assert.deepEqual(byLabel(elements, 'document').map(d => d.uri), ['file:///repo/src/main.ts', 'file:///repo/src/user.ts'])
+})
+
+test('lsif: definition range points at the symbol name', () => {
+  const elements = buildLsif(createIndex(FILES), { projectRoot: '/repo' })
+  const ranges = byLabel(elements, 'range')
+
+  // "export class User" on line 3 -> 0-based line 2, name at character 13
+  assert.ok(ranges.some(r => r.start.line === 2 && r.start.character === 13 && r.end.character === 17))
+})
+
+test('lsif: references link usages to the definition result set', () => {
+  const elements = buildLsif(createIndex(FILES), { projectRoot: '/repo' })
+  const refItems = byLabel(elements, 'item').filter(e => e.property === 'references')
+  const mainDoc = byLabel(elements, 'document').find(d => d.uri.endsWith('main.ts'))
+
+  assert.ok(refItems.length >= 2)
+  assert.ok(refItems.every(e => e.document === mainDoc.id))
+})
+
+test('lsif: hover includes signature and doc comment', () => {
+  const elements = buildLsif(createIndex(FILES), { projectRoot: '/repo' })
+  const hovers = byLabel(elements, 'hoverResult').map(h => h.result.contents)
+
+  assert.ok(hovers.some(c => c[0].value === 'doWork(): number' && c[1] === 'Perform the work'))
+  assert.ok(hovers.some(c => c[1] === 'A user of the system'))
+})
+
+test('lsif: exported symbols get monikers and package information', () => {
+  const elements = buildLsif(createIndex(FILES), {
+    projectRoot: '/repo',
+    packageInfo: { name: 'demo', version: '1.0.0' }
+  })
+  const identifiers = byLabel(elements, 'moniker').map(m => m.identifier)
+
+  assert.ok(identifiers.includes('src/user:User'))
+  assert.ok(identifiers.includes('src/user:User.doWork'))
+  assert.ok(!identifiers.includes('src/user:helper'))
+  assert.equal(byLabel(elements, 'packageInformation').length, 1)
+})
+
+test('lsif: edges only reference earlier vertices', () => {
+  const elements = buildLsif(createIndex(FILES), { projectRoot: '/repo' })
+  const seen = new Set<number>()
+  for (const e of elements) {
+    if (e.type === 'vertex') {
+      seen.add(e.id)
+      continue
+    }
+    assert.ok(seen.has(e.outV))
+    for (const id of e.inVs || [e.inV]) assert.ok(seen.has(id))
+  }
+})
+
+test('lsif: exportLsif writes one JSON element per line', () => {
+  const index = createIndex(FILES)
+  const lines = exportLsif(index, { projectRoot: '/repo' }).trim().split('\n')
+  assert.equal(lines.length, buildLsif(index, { projectRoot: '/repo' }).length)
+  assert.equal(JSON.parse(lines[0]).label, 'metaData')
+  assert.equal(monikerIdentifier(index.findSymbols('User')[0]), 'src/user:User')
+})
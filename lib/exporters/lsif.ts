@@ -0,0 +1,219 @@
+/**
+ * LSIF Exporter
+ * Dumps a symbol index as LSIF 0.4.3 (newline-delimited JSON) with
+ * definitions, references, hover docs and export monikers, ready for
+ * upload to Sourcegraph or GitLab code intelligence. Syntax errors of files
+ * that failed to parse and analyzer findings are their documents'
+ * diagnostic results.
+ */
+
+import path from 'path'
+import { pathToFileURL } from 'url'
+import { shortName } from '../core/symbol-index.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { DiagnosticSeverity, IndexedSymbol, Location } from '../types/index.js'
+import { symbolDocMarkdown } from '../utils/doc-comments.js'
+
+export const LSIF_VERSION = '0.4.3'
+
+export interface PackageInfo {
+  name: string
+  version: string
+  manager?: string
+}
+
+export interface LsifExportOptions {
+  projectRoot: string
+  toolVersion?: string
+  packageInfo?: PackageInfo | null
+}
+
+export type LsifElement = Record<string, any> & { id: number, type: 'vertex' | 'edge', label: string }
+
+// Moniker scheme per language, matching the package manager that owns the symbols
+const MONIKER_SCHEMES: Record<string, string> = {
+  typescript: 'npm',
+  javascript: 'npm',
+  python: 'pypi',
+  csharp: 'nuget'
+}
+
+// LSP DiagnosticSeverity
+const LSP_SEVERITY: Record<DiagnosticSeverity, number> = { error: 1, warning: 2, info: 3 }
+
+const LSIF_LANGUAGE_IDS: Record<string, string> = {
+  typescript: 'typescript',
+  javascript: 'javascript',
+  python: 'python',
+  csharp: 'csharp'
+}
+
+/**
+ * Stable moniker identifier for a symbol: "<path without extension>:<qualified name>"
+ */
+export function monikerIdentifier(sym: IndexedSymbol): string {
+  const ext = path.posix.extname(sym.path)
+  const modulePath = ext ? sym.path.slice(0, -ext.length) : sym.path
+  return `${modulePath}:${sym.name}`
+}
+
+/**
+ * Hover text for a symbol: signature as a code block plus its doc comment
+ */
+export function hoverContents(sym: IndexedSymbol): any[] {
+  const contents: any[] = [{
+    language: LSIF_LANGUAGE_IDS[sym.lang] || sym.lang,
+    value: sym.signature || `${sym.kind} ${sym.name}`
+  }]
+  const doc = symbolDocMarkdown(sym, LSIF_LANGUAGE_IDS[sym.lang] || sym.lang)
+  if (doc) contents.push(doc)
+  return contents
+}
+
+function nameRange(loc: Location, name: string) {
+  const character = Math.max((loc.column || 1) - 1, 0)
+  return {
+    start: { line: loc.line - 1, character },
+    end: { line: loc.line - 1, character: character + name.length }
+  }
+}
+
+/**
+ * Build the LSIF graph for an index
+ */
+export function buildLsif(index: SymbolIndex, options: LsifExportOptions): LsifElement[] {
+  const elements: LsifElement[] = []
+  let nextId = 1
+  const vertex = (label: string, props: Record<string, any> = {}): number => {
+    const id = nextId++
+    elements.push({ id, type: 'vertex', label, ...props })
+    return id
+  }
+  const edge = (label: string, outV: number, inV: number | number[], props: Record<string, any> = {}): void => {
+    const target = Array.isArray(inV) ? { inVs: inV } : { inV }
+    elements.push({ id: nextId++, type: 'edge', label, outV, ...target, ...props })
+  }
+
+  const projectRoot = path.resolve(options.projectRoot)
+  vertex('metaData', {
+    version: LSIF_VERSION,
+    projectRoot: pathToFileURL(projectRoot).href,
+    positionEncoding: 'utf-16',
+    toolInfo: { name: 'indexer', version: options.toolVersion || '0.0.0' }
+  })
+  const projectId = vertex('project', { kind: 'typescript' })
+
+  const documents = new Map<string, number>()
+  const documentRanges = new Map<string, number[]>()
+  for (const shard of index.listShards()) {
+    const docId = vertex('document', {
+      uri: pathToFileURL(path.join(projectRoot, shard.path)).href,
+      languageId: LSIF_LANGUAGE_IDS[shard.lang] || shard.lang
+    })
+    documents.set(shard.path, docId)
+    documentRanges.set(shard.path, [])
+    if (shard.diagnostics && shard.diagnostics.length > 0) {
+      const diagnosticsId = vertex('diagnosticResult', {
+        result: shard.diagnostics.map(d => {
+          const position = { line: d.line - 1, character: d.column - 1 }
+          return {
+            severity: LSP_SEVERITY[d.severity || 'error'],
+            code: d.rule || d.analyzer || 'syntax',
+            message: d.message,
+            source: d.analyzer || 'indexer',
+            range: { start: position, end: position }
+          }
+        })
+      })
+      edge('textDocument/diagnostic', docId, diagnosticsId)
+    }
+  }
+
+  // A position can only belong to one range; definitions claim theirs first
+  const rangeAt = new Map<string, number>()
+  const addRange = (loc: Location, name: string): number | null => {
+    const key = `${loc.path}:${loc.line}:${loc.column || 0}`
+    if (rangeAt.has(key) || !documents.has(loc.path)) return null
+    const id = vertex('range', nameRange(loc, name))
+    rangeAt.set(key, id)
+    documentRanges.get(loc.path)!.push(id)
+    return id
+  }
+
+  let packageId: number | null = null
+  const packageVertex = (): number | null => {
+    if (!options.packageInfo) return null
+    if (packageId === null) {
+      packageId = vertex('packageInformation', {
+        name: options.packageInfo.name,
+        manager: options.packageInfo.manager || 'npm',
+        version: options.packageInfo.version
+      })
+    }
+    return packageId
+  }
+
+  const resultSets = new Map<string, { resultSetId: number, defRangeId: number }>()
+  for (const shard of index.listShards()) {
+    for (const sym of shard.symbols) {
+      const name = shortName(sym.name)
+      const defRangeId = addRange({ path: sym.path, line: sym.line, column: sym.column }, name)
+      if (defRangeId === null) continue
+
+      const resultSetId = vertex('resultSet')
+      edge('next', defRangeId, resultSetId)
+      resultSets.set(sym.id, { resultSetId, defRangeId })
+
+      const defResultId = vertex('definitionResult')
+      edge('textDocument/definition', resultSetId, defResultId)
+      edge('item', defResultId, [defRangeId], { document: documents.get(sym.path) })
+
+      const hoverId = vertex('hoverResult', { result: { contents: hoverContents(sym) } })
+      edge('textDocument/hover', resultSetId, hoverId)
+
+      if (sym.exported) {
+        const scheme = MONIKER_SCHEMES[sym.lang] || 'indexer'
+        const monikerId = vertex('moniker', { scheme, identifier: monikerIdentifier(sym), kind: 'export' })
+        edge('moniker', resultSetId, monikerId)
+        const pkgId = scheme === 'npm' ? packageVertex() : null
+        if (pkgId !== null) edge('packageInformation', monikerId, pkgId)
+      }
+    }
+  }
+
+  for (const sym of index.allSymbols()) {
+    const set = resultSets.get(sym.id)
+    if (!set) continue
+
+    const byDocument = new Map<string, number[]>()
+    for (const ref of index.references(sym.id)) {
+      const rangeId = addRange(ref, shortName(sym.name))
+      if (rangeId === null) continue
+      edge('next', rangeId, set.resultSetId)
+      const list = byDocument.get(ref.path) || []
+      list.push(rangeId)
+      byDocument.set(ref.path, list)
+    }
+
+    const refResultId = vertex('referenceResult')
+    edge('textDocument/references', set.resultSetId, refResultId)
+    edge('item', refResultId, [set.defRangeId], { document: documents.get(sym.path), property: 'definitions' })
+    for (const [docPath, ranges] of byDocument) {
+      edge('item', refResultId, ranges, { document: documents.get(docPath), property: 'references' })
+    }
+  }
+
+  for (const [docPath, ranges] of documentRanges) {
+    if (ranges.length > 0) edge('contains', documents.get(docPath)!, ranges)
+  }
+  if (documents.size > 0) edge('contains', projectId, Array.from(documents.values()))
+
+  return elements
+}
+
+/**
+ * Serialize an index as an LSIF dump (one JSON element per line)
+ */
+export function exportLsif(index: SymbolIndex, options: LsifExportOptions): string {
+  return buildLsif(index, options).map(e => JSON.stringify(e)).join('\n') + '\n'
+}
@@ -0,0 +1,17 @@
+import fs from 'fs/promises'
+import path from 'path'
+import type { PackageInfo } from './lsif.js'
+
+/**
+ * Read package name and version from the project's package.json
+ * @returns Package info, or null if there is no named package
+ */
+export async function readPackageInfo(projectRoot: string): Promise<PackageInfo | null> {
+  try {
+    const pkg = JSON.parse(await fs.readFile(path.join(projectRoot, 'package.json'), 'utf8'))
+    if (!pkg.name) return null
+    return { name: pkg.name, version: pkg.version || '0.0.0', manager: 'npm' }
+  } catch {
+    return null
+  }
+}
@@ -0,0 +1,91 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { makeSymbolId } from '../core/symbol-index.js'
+import { SYMBOL_KINDS, SYMBOL_KINDS_VERSION } from '../core/symbol-kinds.js'
+import { ProtoWriter, decodeDelimited } from '../utils/protobuf.js'
+import {
+  DIAGNOSTIC_SEVERITY,
+  RECORDS_FORMAT_VERSION,
+  decodeRecord,
+  decodeRecords,
+  encodeRecord,
+  exportRecords,
+  type IndexRecord
+} from './records.js'
+import { createIndex, type FixtureFiles, type FixtureOptions } from '../test-utils.js'
+
+const API_SRC = `/**
+ * Save a user.
+ * @deprecated Use store() instead
+ */
+export function save<T extends Entity>(item: T) {}
+
+export class Store extends Base implements Sink {}
+`
+
+const APP_SRC = `import { save } from './api'
+
+save(user)
+`
+
+const FILES: FixtureFiles = { 'src/api.ts': API_SRC, 'src/app.ts': APP_SRC }
+
+const OPTIONS: FixtureOptions = {
+  ownersOf: p => p.startsWith('src/api') ? ['@org/platform'] : [],
+  hashes: { 'src/api.ts': 'h1', 'src/app.ts': 'h2' }
+}
+
+test('records: a stream starts with the header, then each file with its symbols and references', () => {
+  const records = decodeRecords(exportRecords(createIndex(FILES, OPTIONS), { projectRoot: '/repo', toolVersion: '1.2.3' }))
+
+  assert.deepEqual(records[0], {
+    header: { format_version: RECORDS_FORMAT_VERSION, tool_version: '1.2.3', project_root: '/repo', symbol_kinds_version: SYMBOL_KINDS_VERSION }
+  })
+  const kinds = records.map(r => Object.keys(r)[0])
+  assert.equal(kinds.filter(k => k === 'file').length, 2)
+  assert.equal(kinds.indexOf('diagnostic'), kinds.length - 2)
+
+  const files = records.flatMap(r => 'file' in r ? [r.file] : [])
+  assert.deepEqual(files, [{ path: 'src/api.ts', lang: 'typescript', hash: 'h1' }, { path: 'src/app.ts', lang: 'typescript', hash: 'h2' }])
+
+  const symbols = records.flatMap(r => 'symbol' in r ? [r.symbol] : [])
+  const save = symbols.find(s => s.name === 'save')!
+  assert.equal(save.id, makeSymbolId('src/api.ts', 'save'))
+  assert.equal(save.exported, true)
+  assert.equal(save.deprecated, true)
+  assert.equal(save.deprecation_notice, 'Use store() instead')
+  assert.deepEqual(save.type_params, [{ name: 'T', constraint: 'Entity', default: '' }])
+  assert.deepEqual(save.owners, ['@org/platform'])
+  assert.equal(save.kind_code, SYMBOL_KINDS.function)
+  const store = symbols.find(s => s.name === 'Store')!
+  assert.deepEqual([store.extends, store.implements, store.deprecated], [['Base'], ['Sink'], false])
+
+  const call = records.flatMap(r => 'reference' in r ? [r.reference] : []).find(r => r.path === 'src/app.ts' && r.line === 3)!
+  assert.deepEqual([call.name, call.call], ['save', true])
+})
+
+test('records: uses of deprecated symbols are diagnostics', () => {
+  const records = decodeRecords(exportRecords(createIndex(FILES, OPTIONS), { projectRoot: '/repo' }))
+  const diagnostics = records.flatMap(r => 'diagnostic' in r ? [r.diagnostic] : [])
+  assert.deepEqual(diagnostics.map(d => [d.path, d.line, d.severity, d.code, d.message, d.symbol_id]), [
+    ['src/app.ts', 1, DIAGNOSTIC_SEVERITY.WARNING, 'deprecated', 'save is deprecated: Use store() instead', makeSymbolId('src/api.ts', 'save')],
+    ['src/app.ts', 3, DIAGNOSTIC_SEVERITY.WARNING, 'deprecated', 'save is deprecated: Use store() instead', makeSymbolId('src/api.ts', 'save')]
+  ])
+})
+
+test('records: round-trip with proto3 defaults', () => {
+  const record: IndexRecord = { reference: { name: 'x', path: 'a.ts', line: 2, column: 0, call: false, receiver: '', type_args: ['string', 'number'], role: '' } }
+  const encoded = encodeRecord(record)
+  assert.deepEqual(decodeRecord(encoded), record)
+  // Only name, path, line and the two type arguments are on the wire
+  assert.equal(encoded.length, 2 + 3 + 6 + 2 + 8 + 8)
+})
+
+test('records: unknown fields and record kinds from newer schemas are skipped', () => {
+  const file = new ProtoWriter().message(2, w => w.string(1, 'a.ts').string(99, 'future')).finish()
+  const future = new ProtoWriter().message(42, w => w.string(1, 'x')).finish()
+  const stream = new ProtoWriter().delimited(file).delimited(future).finish()
+
+  assert.equal(decodeDelimited(stream).length, 2)
+  assert.deepEqual(decodeRecords(stream), [{ file: { path: 'a.ts', lang: '', hash: '' } }])
+})
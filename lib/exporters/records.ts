@@ -0,0 +1,292 @@
+/**
+ * Index Records Exporter
+ * Encodes the symbol index as the delimited Record stream described in
+ * index_records.proto (package indexer.records.v1) and decodes it again.
+ * The record types below mirror the schema field for field, with proto3
+ * defaults ('' / 0 / false / []) for absent values; readers in other
+ * languages generate theirs from the .proto. Diagnostics are the syntax
+ * errors of files that failed to parse and the findings of analyzers (code
+ * <analyzer>/<rule>), then the uses of deprecated symbols.
+ */
+
+import { ProtoWriter, WIRE_LENGTH_DELIMITED, decodeDelimited, decodeFields } from '../utils/protobuf.js'
+import { deprecationNotice, findDeprecatedUses } from '../core/deprecations.js'
+import { findParseDiagnostics } from '../core/parse-diagnostics.js'
+import { referenceRole } from '../core/reference-roles.js'
+import { SYMBOL_KINDS_VERSION, symbolKindCode } from '../core/symbol-kinds.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { DiagnosticSeverity, FileShard, IndexedSymbol, SymbolReference } from '../types/index.js'
+
+export const RECORDS_FORMAT_VERSION = 1
+
+// Diagnostic.Severity
+export const DIAGNOSTIC_SEVERITY = {
+  UNSPECIFIED: 0,
+  ERROR: 1,
+  WARNING: 2,
+  INFO: 3
+} as const
+
+export interface HeaderRecord {
+  format_version: number
+  tool_version: string
+  project_root: string
+  symbol_kinds_version: number
+}
+
+export interface FileRecord {
+  path: string
+  lang: string
+  hash: string
+}
+
+export interface TypeParameterRecord {
+  name: string
+  constraint: string
+  default: string
+}
+
+export interface SymbolRecord {
+  id: string
+  name: string
+  kind: string
+  path: string
+  line: number
+  end_line: number
+  lang: string
+  exported: boolean
+  signature: string
+  doc: string
+  module: string
+  module_version: string
+  column: number
+  generated: boolean
+  deprecated: boolean
+  deprecation_notice: string
+  extends: string[]
+  implements: string[]
+  type_params: TypeParameterRecord[]
+  owners: string[]
+  kind_code: number // SYMBOL_KINDS
+}
+
+export interface ReferenceRecord {
+  name: string
+  path: string
+  line: number
+  column: number
+  call: boolean
+  receiver: string
+  type_args: string[]
+  role: string
+}
+
+export interface DiagnosticRecord {
+  path: string
+  line: number
+  column: number
+  severity: number // DIAGNOSTIC_SEVERITY
+  code: string
+  message: string
+  symbol_id: string
+}
+
+export type IndexRecord =
+  | { header: HeaderRecord }
+  | { file: FileRecord }
+  | { symbol: SymbolRecord }
+  | { reference: ReferenceRecord }
+  | { diagnostic: DiagnosticRecord }
+
+export interface RecordsExportOptions {
+  projectRoot: string
+  toolVersion?: string
+}
+
+// Field tables in schema order: [number, name, type]
+type FieldType = 'string' | 'uint32' | 'bool' | 'strings' | MessageSpec
+type MessageSpec = [number, string, FieldType][]
+
+const TYPE_PARAMETER: MessageSpec = [[1, 'name', 'string'], [2, 'constraint', 'string'], [3, 'default', 'string']]
+
+const MESSAGES: Record<string, MessageSpec> = {
+  header: [[1, 'format_version', 'uint32'], [2, 'tool_version', 'string'], [3, 'project_root', 'string'], [4, 'symbol_kinds_version', 'uint32']],
+  file: [[1, 'path', 'string'], [2, 'lang', 'string'], [3, 'hash', 'string']],
+  symbol: [
+    [1, 'id', 'string'], [2, 'name', 'string'], [3, 'kind', 'string'], [4, 'path', 'string'],
+    [5, 'line', 'uint32'], [6, 'end_line', 'uint32'], [7, 'lang', 'string'], [8, 'exported', 'bool'],
+    [9, 'signature', 'string'], [10, 'doc', 'string'], [11, 'module', 'string'], [12, 'module_version', 'string'],
+    [13, 'column', 'uint32'], [14, 'generated', 'bool'], [15, 'deprecated', 'bool'], [16, 'deprecation_notice', 'string'],
+    [17, 'extends', 'strings'], [18, 'implements', 'strings'], [19, 'type_params', TYPE_PARAMETER], [20, 'owners', 'strings'],
+    [21, 'kind_code', 'uint32']
+  ],
+  reference: [
+    [1, 'name', 'string'], [2, 'path', 'string'], [3, 'line', 'uint32'], [4, 'column', 'uint32'],
+    [5, 'call', 'bool'], [6, 'receiver', 'string'], [7, 'type_args', 'strings'], [8, 'role', 'string']
+  ],
+  diagnostic: [
+    [1, 'path', 'string'], [2, 'line', 'uint32'], [3, 'column', 'uint32'], [4, 'severity', 'uint32'],
+    [5, 'code', 'string'], [6, 'message', 'string'], [7, 'symbol_id', 'string']
+  ]
+}
+
+// Record.record oneof
+const RECORD_FIELDS: Record<string, number> = { header: 1, file: 2, symbol: 3, reference: 4, diagnostic: 5 }
+const RECORD_KINDS = Object.fromEntries(Object.entries(RECORD_FIELDS).map(([kind, field]) => [field, kind]))
+
+function encodeMessage(spec: MessageSpec, value: Record<string, any>, w: ProtoWriter): void {
+  for (const [field, name, type] of spec) {
+    const v = value[name]
+    if (type === 'string') w.string(field, v)
+    else if (type === 'uint32') w.varint(field, v)
+    else if (type === 'bool') w.bool(field, v)
+    else if (type === 'strings') w.strings(field, v)
+    else for (const item of v || []) w.message(field, inner => encodeMessage(type, item, inner))
+  }
+}
+
+function decodeMessage(spec: MessageSpec, data: Uint8Array): Record<string, any> {
+  const value: Record<string, any> = {}
+  for (const [, name, type] of spec) {
+    value[name] = type === 'string' ? '' : type === 'uint32' ? 0 : type === 'bool' ? false : []
+  }
+  const byField = new Map(spec.map(entry => [entry[0], entry]))
+  for (const { field, wireType, value: raw } of decodeFields(data)) {
+    const entry = byField.get(field)
+    if (!entry) continue // added in a later version of the schema
+    const [, name, type] = entry
+    if (type === 'uint32') value[name] = raw as number
+    else if (type === 'bool') value[name] = raw === 1
+    else if (wireType !== WIRE_LENGTH_DELIMITED) continue
+    else if (type === 'string') value[name] = (raw as Buffer).toString('utf8')
+    else if (type === 'strings') value[name].push((raw as Buffer).toString('utf8'))
+    else value[name].push(decodeMessage(type, raw as Buffer))
+  }
+  return value
+}
+
+/**
+ * One Record message
+ */
+export function encodeRecord(record: IndexRecord): Buffer {
+  const [kind, value] = Object.entries(record)[0]
+  return new ProtoWriter().message(RECORD_FIELDS[kind], w => encodeMessage(MESSAGES[kind], value, w)).finish()
+}
+
+/**
+ * Parse one Record message; null for a record kind this version does not know
+ */
+export function decodeRecord(data: Uint8Array): IndexRecord | null {
+  for (const { field, wireType, value } of decodeFields(data)) {
+    const kind = RECORD_KINDS[field]
+    if (kind && wireType === WIRE_LENGTH_DELIMITED) return { [kind]: decodeMessage(MESSAGES[kind], value as Buffer) } as IndexRecord
+  }
+  return null
+}
+
+/**
+ * Parse a delimited Record stream, skipping unknown record kinds
+ */
+export function decodeRecords(data: Uint8Array): IndexRecord[] {
+  return decodeDelimited(data).map(decodeRecord).filter((r): r is IndexRecord => r !== null)
+}
+
+export function symbolRecord(index: SymbolIndex, sym: IndexedSymbol): SymbolRecord {
+  const notice = deprecationNotice(sym)
+  return {
+    id: sym.id,
+    name: sym.name,
+    kind: sym.kind,
+    path: sym.path,
+    line: sym.line,
+    end_line: sym.end_line,
+    lang: sym.lang,
+    exported: !!sym.exported,
+    signature: sym.signature || '',
+    doc: sym.doc || '',
+    module: sym.module || '',
+    module_version: sym.module_version || '',
+    column: sym.column || 0,
+    generated: !!sym.generated,
+    deprecated: notice !== undefined,
+    deprecation_notice: notice || '',
+    extends: sym.extends || [],
+    implements: sym.implements || [],
+    type_params: (sym.type_params || []).map(p => ({ name: p.name, constraint: p.constraint || '', default: p.default || '' })),
+    owners: index.owners(sym.path),
+    kind_code: symbolKindCode(sym.kind)
+  }
+}
+
+export function referenceRecord(ref: SymbolReference): ReferenceRecord {
+  return {
+    name: ref.name,
+    path: ref.path,
+    line: ref.line,
+    column: ref.column || 0,
+    call: !!ref.call,
+    receiver: ref.receiver || '',
+    type_args: ref.type_args || [],
+    role: referenceRole(ref)
+  }
+}
+
+function fileRecords(index: SymbolIndex, shard: FileShard): IndexRecord[] {
+  return [
+    { file: { path: shard.path, lang: shard.lang, hash: shard.hash || '' } },
+    ...shard.symbols.map(sym => ({ symbol: symbolRecord(index, sym) })),
+    ...shard.references.map(ref => ({ reference: referenceRecord(ref) }))
+  ]
+}
+
+const SEVERITY_OF: Record<DiagnosticSeverity, number> = {
+  error: DIAGNOSTIC_SEVERITY.ERROR,
+  warning: DIAGNOSTIC_SEVERITY.WARNING,
+  info: DIAGNOSTIC_SEVERITY.INFO
+}
+
+/**
+ * Diagnostics of an index: every syntax error and analyzer finding, and
+ * every use of a deprecated symbol
+ */
+export function indexDiagnostics(index: SymbolIndex): DiagnosticRecord[] {
+  const syntax = findParseDiagnostics(index).map(d => ({
+    path: d.path,
+    line: d.line,
+    column: d.column,
+    severity: SEVERITY_OF[d.severity || 'error'],
+    code: d.analyzer ? (d.rule ? `${d.analyzer}/${d.rule}` : d.analyzer) : 'syntax',
+    message: d.message,
+    symbol_id: ''
+  }))
+  const deprecated = findDeprecatedUses(index).flatMap(({ symbol, notice, uses }) => uses.map(use => ({
+    path: use.path,
+    line: use.line,
+    column: use.column || 0,
+    severity: DIAGNOSTIC_SEVERITY.WARNING,
+    code: 'deprecated',
+    message: `${symbol.name} is deprecated${notice ? `: ${notice}` : ''}`,
+    symbol_id: symbol.id
+  })))
+  return [...syntax, ...deprecated]
+}
+
+/**
+ * Encode the whole index as a delimited Record stream
+ */
+export function exportRecords(index: SymbolIndex, options: RecordsExportOptions): Buffer {
+  const w = new ProtoWriter()
+  w.delimited(encodeRecord({
+    header: {
+      format_version: RECORDS_FORMAT_VERSION,
+      tool_version: options.toolVersion || '0.0.0',
+      project_root: options.projectRoot,
+      symbol_kinds_version: SYMBOL_KINDS_VERSION
+    }
+  }))
+  for (const shard of index.listShards()) {
+    for (const record of fileRecords(index, shard)) w.delimited(encodeRecord(record))
+  }
+  for (const diagnostic of indexDiagnostics(index)) w.delimited(encodeRecord({ diagnostic }))
+  return w.finish()
+}
@@ -0,0 +1,86 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { makeSymbolId } from '../core/symbol-index.js'
+import { decodeFields, decodePacked } from '../utils/protobuf.js'
+import { buildScipIndex, escapeDescriptor, exportScip, scipSymbol, SCIP_SYMBOL_ROLE_DEFINITION } from './scip.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER_SRC = `
+export interface Worker {
+  doWork(): number
+}
+
+/** A user of the system */
+export class User implements Worker {
+  doWork(): number {
+    return 1
+  }
+}
+`
+
+const MAIN_SRC = `
+import { User } from './user'
+
+new User().doWork()
+`
+
+const PKG = { name: 'demo', version: '1.0.0' }
+
+const FILES: FixtureFiles = {
+  'src/user.ts': USER_SRC,
+  'src/main.ts': MAIN_SRC
+}
+
+test('scip: symbols use package and descriptor syntax', () => {
+  const index = createIndex(FILES)
+
+  assert.equal(
+    scipSymbol(index, index.getSymbol(makeSymbolId('src/user.ts', 'User.doWork'))!, PKG),
+    'scip-typescript npm demo 1.0.0 src/`user.ts`/User#doWork().'
+  )
+  assert.equal(
+    scipSymbol(index, index.getSymbol(makeSymbolId('src/user.ts', 'Worker'))!, null),
+    'scip-typescript npm . . src/`user.ts`/Worker#'
+  )
+})
+
+test('scip: escapeDescriptor backticks non-identifiers', () => {
+  assert.equal(escapeDescriptor('User'), 'User')
+  assert.equal(escapeDescriptor('user.ts'), '`user.ts`')
+  assert.equal(escapeDescriptor('a`b'), '`a``b`')
+})
+
+test('scip: documents carry definitions, references and relationships', () => {
+  const scip = buildScipIndex(createIndex(FILES), { projectRoot: '/repo', packageInfo: PKG })
+  const user = scip.documents.find(d => d.relativePath === 'src/user.ts')!
+  const main = scip.documents.find(d => d.relativePath === 'src/main.ts')!
+  const doWork = 'scip-typescript npm demo 1.0.0 src/`user.ts`/User#doWork().'
+
+  assert.ok(user.occurrences.some(o => o.symbol === doWork && o.symbolRoles === SCIP_SYMBOL_ROLE_DEFINITION))
+  // The call reads doWork (SymbolRole.ReadAccess)
+  assert.ok(main.occurrences.some(o => o.symbol === doWork && o.symbolRoles === 8 && o.range[0] === 3))
+
+  const info = user.symbols.find(s => s.displayName === 'User')!
+  assert.equal(info.documentation[1], 'A user of the system')
+  assert.deepEqual(info.relationships, [{
+    symbol: 'scip-typescript npm demo 1.0.0 src/`user.ts`/Worker#',
+    isImplementation: true
+  }])
+})
+
+test('scip: encoded index decodes back to metadata and documents', () => {
+  const buf = exportScip(createIndex(FILES), { projectRoot: '/repo', toolVersion: '1.2.3' })
+  const fields = decodeFields(buf)
+
+  const metadata = decodeFields(fields.find(f => f.field === 1)!.value as Buffer)
+  const tool = decodeFields(metadata.find(f => f.field === 2)!.value as Buffer)
+  assert.equal((tool[0].value as Buffer).toString(), 'indexer')
+  assert.equal((tool[1].value as Buffer).toString(), '1.2.3')
+  assert.equal((metadata.find(f => f.field === 3)!.value as Buffer).toString(), 'file:///repo')
+
+  const documents = fields.filter(f => f.field === 2).map(f => decodeFields(f.value as Buffer))
+  assert.deepEqual(documents.map(d => (d.find(f => f.field === 1)!.value as Buffer).toString()), ['src/main.ts', 'src/user.ts'])
+
+  const occurrence = decodeFields(documents[1].find(f => f.field === 2)!.value as Buffer)
+  assert.equal(decodePacked(occurrence.find(f => f.field === 1)!.value as Buffer).length, 3)
+})
@@ -0,0 +1,276 @@
+/**
+ * SCIP Exporter
+ * Encodes a symbol index as a SCIP protobuf index (index.scip) with stable
+ * global symbols ("scip-typescript npm pkg 1.0.0 src/`user.ts`/User#doWork().")
+ * so Sourcegraph can link definitions and references across repositories.
+ */
+
+import path from 'path'
+import { pathToFileURL } from 'url'
+import { shortName } from '../core/symbol-index.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { IndexedSymbol, ReferenceRole } from '../types/index.js'
+import { ProtoWriter } from '../utils/protobuf.js'
+import { symbolDocMarkdown } from '../utils/doc-comments.js'
+import type { PackageInfo } from './lsif.js'
+
+export interface ScipExportOptions {
+  projectRoot: string
+  toolVersion?: string
+  packageInfo?: PackageInfo | null
+}
+
+export const SCIP_SYMBOL_ROLE_DEFINITION = 1
+
+// scip.proto SymbolRole bits of a reference; types and heritage carry none
+const SCIP_REFERENCE_ROLES: Partial<Record<ReferenceRole, number>> = {
+  import: 2,
+  write: 4,
+  address: 4,
+  read: 8,
+  call: 8
+}
+
+// scip.proto TextEncoding / PositionEncoding
+const TEXT_ENCODING_UTF8 = 1
+const POSITION_ENCODING_UTF16 = 2
+
+// scip.proto SymbolInformation.Kind
+const SCIP_KINDS: Record<string, number> = {
+  class: 7,
+  scriptable_object: 7,
+  const: 8,
+  constant: 8,
+  enum: 11,
+  field: 15,
+  private_field: 15,
+  serialized_field: 15,
+  function: 17,
+  hook: 17,
+  function_component: 17,
+  accessor: 18,
+  interface: 21,
+  method: 26,
+  unity_lifecycle: 26,
+  namespace: 30,
+  package: 35,
+  property: 41,
+  struct: 49,
+  type: 55,
+  type_parameter: 58,
+  variable: 61
+}
+
+const SCIP_SCHEMES: Record<string, { scheme: string, manager: string }> = {
+  typescript: { scheme: 'scip-typescript', manager: 'npm' },
+  javascript: { scheme: 'scip-typescript', manager: 'npm' },
+  python: { scheme: 'scip-python', manager: 'python' },
+  csharp: { scheme: 'scip-dotnet', manager: 'nuget' }
+}
+
+const TYPE_LIKE_KINDS = new Set(['class', 'interface', 'struct', 'enum', 'type', 'scriptable_object'])
+const CALLABLE_KINDS = new Set(['function', 'method', 'hook', 'function_component', 'unity_lifecycle'])
+
+export interface ScipOccurrence {
+  range: number[]
+  symbol: string
+  symbolRoles: number
+}
+
+export interface ScipRelationship {
+  symbol: string
+  isImplementation: boolean
+}
+
+export interface ScipSymbolInformation {
+  symbol: string
+  documentation: string[]
+  kind: number
+  displayName: string
+  relationships: ScipRelationship[]
+}
+
+export interface ScipDocument {
+  language: string
+  relativePath: string
+  occurrences: ScipOccurrence[]
+  symbols: ScipSymbolInformation[]
+}
+
+export interface ScipIndex {
+  metadata: {
+    toolName: string
+    toolVersion: string
+    projectRoot: string
+  }
+  documents: ScipDocument[]
+}
+
+/**
+ * Escape a descriptor name: simple identifiers stay as-is, anything else is
+ * wrapped in backticks with inner backticks doubled
+ */
+export function escapeDescriptor(name: string): string {
+  if (/^[A-Za-z0-9_+\-$]+$/.test(name)) return name
+  return '`' + name.replace(/`/g, '``') + '`'
+}
+
+function packageField(value: string | undefined): string {
+  return value ? value.replace(/ /g, '  ') : '.'
+}
+
+/**
+ * Global SCIP symbol for an indexed symbol
+ */
+export function scipSymbol(index: SymbolIndex, sym: IndexedSymbol, packageInfo?: PackageInfo | null): string {
+  const { scheme, manager } = SCIP_SCHEMES[sym.lang] || { scheme: 'scip-indexer', manager: '.' }
+  const pkg = `${packageField(manager)} ${packageField(packageInfo?.name)} ${packageField(packageInfo?.version)}`
+
+  let descriptors = sym.path.split('/').map(part => `${escapeDescriptor(part)}/`).join('')
+
+  const parts = sym.name.split('.')
+  // Every part of a package name (Acme.Billing) is a namespace
+  const pkgName = sym.kind === 'package'
+  for (let i = 0; i < parts.length - 1; i++) {
+    const qualified = parts.slice(0, i + 1).join('.')
+    const owner = index.fileSymbols(sym.path).find(s => s.name === qualified)
+    descriptors += escapeDescriptor(parts[i]) + (pkgName || owner?.kind === 'namespace' ? '/' : '#')
+  }
+
+  const last = escapeDescriptor(parts[parts.length - 1])
+  if (sym.kind === 'namespace' || pkgName) descriptors += `${last}/`
+  else if (TYPE_LIKE_KINDS.has(sym.kind)) descriptors += `${last}#`
+  else if (CALLABLE_KINDS.has(sym.kind)) descriptors += `${last}().`
+  else descriptors += `${last}.`
+
+  return `${scheme} ${pkg} ${descriptors}`
+}
+
+function occurrenceRange(line: number, column: number | undefined, name: string): number[] {
+  const character = Math.max((column || 1) - 1, 0)
+  return [line - 1, character, character + name.length]
+}
+
+/**
+ * Build the SCIP index structure for a symbol index
+ */
+export function buildScipIndex(index: SymbolIndex, options: ScipExportOptions): ScipIndex {
+  const projectRoot = path.resolve(options.projectRoot)
+  const symbolNames = new Map<string, string>()
+  for (const sym of index.allSymbols()) {
+    symbolNames.set(sym.id, scipSymbol(index, sym, options.packageInfo))
+  }
+
+  const documents = new Map<string, ScipDocument>()
+  for (const shard of index.listShards()) {
+    documents.set(shard.path, {
+      language: shard.lang,
+      relativePath: shard.path,
+      occurrences: [],
+      symbols: []
+    })
+  }
+
+  for (const shard of index.listShards()) {
+    const doc = documents.get(shard.path)!
+    for (const sym of shard.symbols) {
+      const symbol = symbolNames.get(sym.id)!
+      const name = shortName(sym.name)
+      doc.occurrences.push({
+        range: occurrenceRange(sym.line, sym.column, name),
+        symbol,
+        symbolRoles: SCIP_SYMBOL_ROLE_DEFINITION
+      })
+
+      const documentation: string[] = []
+      if (sym.signature) documentation.push('```' + sym.lang + '\n' + sym.signature + '\n```')
+      const docText = symbolDocMarkdown(sym, sym.lang)
+      if (docText) documentation.push(docText)
+
+      doc.symbols.push({
+        symbol,
+        documentation,
+        kind: SCIP_KINDS[sym.kind] || 0,
+        displayName: name,
+        relationships: index.interfaces(sym.id).map(iface => ({
+          symbol: symbolNames.get(iface.id)!,
+          isImplementation: true
+        }))
+      })
+
+      for (const ref of index.references(sym.id)) {
+        documents.get(ref.path)?.occurrences.push({
+          range: occurrenceRange(ref.line, ref.column, name),
+          symbol,
+          symbolRoles: SCIP_REFERENCE_ROLES[ref.role] || 0
+        })
+      }
+    }
+  }
+
+  for (const doc of documents.values()) {
+    doc.occurrences.sort((a, b) => a.range[0] - b.range[0] || a.range[1] - b.range[1])
+  }
+
+  return {
+    metadata: {
+      toolName: 'indexer',
+      toolVersion: options.toolVersion || '0.0.0',
+      projectRoot: pathToFileURL(projectRoot).href
+    },
+    documents: Array.from(documents.values())
+  }
+}
+
+/**
+ * Encode a SCIP index structure as protobuf
+ */
+export function encodeScipIndex(scip: ScipIndex): Buffer {
+  const w = new ProtoWriter()
+  w.message(1, meta => {
+    meta.message(2, tool => {
+      tool.string(1, scip.metadata.toolName)
+      tool.string(2, scip.metadata.toolVersion)
+    })
+    meta.string(3, scip.metadata.projectRoot)
+    meta.varint(4, TEXT_ENCODING_UTF8)
+  })
+
+  for (const doc of scip.documents) {
+    w.message(2, d => {
+      d.string(1, doc.relativePath)
+      for (const occ of doc.occurrences) {
+        d.message(2, o => {
+          o.packed(1, occ.range)
+          o.string(2, occ.symbol)
+          o.varint(3, occ.symbolRoles)
+        })
+      }
+      for (const info of doc.symbols) {
+        d.message(3, s => {
+          s.string(1, info.symbol)
+          s.strings(3, info.documentation)
+          for (const rel of info.relationships) {
+            s.message(4, r => {
+              r.string(1, rel.symbol)
+              r.bool(3, rel.isImplementation)
+            })
+          }
+          s.varint(5, info.kind)
+          s.string(6, info.displayName)
+        })
+      }
+      d.string(4, doc.language)
+      d.varint(6, POSITION_ENCODING_UTF16)
+    })
+  }
+
+  return w.finish()
+}
+
+/**
+ * Serialize an index as a SCIP protobuf payload
+ */
+export function exportScip(index: SymbolIndex, options: ScipExportOptions): Buffer {
+  return encodeScipIndex(buildScipIndex(index, options))
+}
@@ -0,0 +1,74 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { collectTags, exportCtags, exportEtags, parameterList } from './tags.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const SRC = `export interface Store {
+  get(key: string): string
+}
+
+export class User {
+  name = 'x'
+  doWork(a: number, b = (1)): number {
+    return a
+  }
+}
+
+export function helper(path: string) {}
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': SRC
+}
+
+const readSource = (p: string) => p === 'src/user.ts' ? SRC : undefined
+
+test('tags: parameterList keeps balanced parentheses', () => {
+  assert.equal(parameterList('doWork(a: number, b = (1)): number'), '(a: number, b = (1))')
+  assert.equal(parameterList('class User'), undefined)
+  assert.equal(parameterList(undefined), undefined)
+})
+
+test('tags: collectTags is sorted by name with kinds and scopes', () => {
+  const tags = collectTags(createIndex(FILES))
+
+  assert.deepEqual(tags.map(t => [t.name, t.kind]), [
+    ['Store', 'i'],
+    ['User', 'c'],
+    ['doWork', 'm'],
+    ['helper', 'f'],
+    ['name', 'p']
+  ])
+  assert.deepEqual(tags.find(t => t.name === 'doWork')!.scope, { kind: 'class', name: 'User' })
+})
+
+test('tags: ctags lines carry address, kind, scope and signature', () => {
+  const lines = exportCtags(createIndex(FILES), { readSource }).trim().split('\n')
+
+  assert.ok(lines[0].startsWith('!_TAG_FILE_FORMAT\t2'))
+  assert.ok(lines.includes('doWork\tsrc/user.ts\t/^  doWork(a: number, b = (1)): number {$/;"\tm\tline:7\tclass:User\tsignature:(a: number, b = (1))'))
+  assert.ok(lines.includes('helper\tsrc/user.ts\t/^export function helper(path: string) {}$/;"\tf\tline:12\tsignature:(path: string)'))
+})
+
+test('tags: ctags falls back to line numbers without sources', () => {
+  const lines = exportCtags(createIndex(FILES)).split('\n')
+  assert.ok(lines.includes('User\tsrc/user.ts\t5;"\tc\tline:5'))
+})
+
+test('tags: ctags lines carry the CODEOWNERS owners of their file', () => {
+  const index = createIndex(FILES)
+  index.ownersOf = (p) => p.startsWith('src/') ? ['@web', '@ana'] : []
+  const lines = exportCtags(index).split('\n')
+  assert.ok(lines.includes('User\tsrc/user.ts\t5;"\tc\tline:5\towners:@web,@ana'))
+})
+
+test('tags: etags sections list definitions with byte offsets', () => {
+  const out = exportEtags(createIndex(FILES), { readSource })
+  const [header, sizeLine, ...entries] = out.split('\n')
+
+  assert.equal(header, '\x0c')
+  const section = entries.join('\n')
+  assert.equal(sizeLine, `src/user.ts,${Buffer.byteLength(section)}`)
+  assert.equal(entries[0], 'export interface Store {\x7fStore\x011,0')
+  assert.ok(entries.includes(`export class User {\x7fUser\x015,${SRC.indexOf('export class')}`))
+})
@@ -0,0 +1,203 @@
+/**
+ * Tag File Exporters
+ * Writes the symbol index as Vim ctags (extended format with kind, scope,
+ * signature and CODEOWNERS owners fields) or Emacs etags files.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import { shortName } from '../core/symbol-index.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { IndexedSymbol } from '../types/index.js'
+
+// Single-letter ctags kinds (universal-ctags conventions)
+const TAG_KINDS: Record<string, string> = {
+  function: 'f',
+  hook: 'f',
+  function_component: 'f',
+  method: 'm',
+  unity_lifecycle: 'm',
+  accessor: 'm',
+  class: 'c',
+  scriptable_object: 'c',
+  interface: 'i',
+  struct: 's',
+  enum: 'g',
+  type: 't',
+  namespace: 'n',
+  package: 'n',
+  property: 'p',
+  field: 'w',
+  private_field: 'w',
+  serialized_field: 'w',
+  const: 'C',
+  constant: 'C',
+  variable: 'v'
+}
+
+const SCOPE_KINDS: Record<string, string> = {
+  class: 'class',
+  scriptable_object: 'class',
+  interface: 'interface',
+  struct: 'struct',
+  enum: 'enum',
+  namespace: 'namespace'
+}
+
+const CALLABLE_KINDS = new Set(['function', 'hook', 'function_component', 'method', 'unity_lifecycle'])
+
+export interface Tag {
+  name: string
+  path: string
+  line: number
+  kind: string
+  scope?: { kind: string, name: string }
+  signature?: string
+  owners?: string[]
+}
+
+export type SourceReader = (relPath: string) => string | undefined
+
+export interface TagsExportOptions {
+  readSource?: SourceReader
+  toolVersion?: string
+}
+
+/**
+ * Parameter list of a declaration header, e.g. "(a: string, b = 1)"
+ */
+export function parameterList(signature: string | undefined): string | undefined {
+  if (!signature) return undefined
+  const start = signature.indexOf('(')
+  if (start === -1) return undefined
+  let depth = 0
+  for (let i = start; i < signature.length; i++) {
+    if (signature[i] === '(') depth++
+    else if (signature[i] === ')' && --depth === 0) return signature.slice(start, i + 1)
+  }
+  return undefined
+}
+
+function tagFor(index: SymbolIndex, sym: IndexedSymbol): Tag | null {
+  const kind = TAG_KINDS[sym.kind]
+  if (!kind) return null
+  const tag: Tag = { name: shortName(sym.name), path: sym.path, line: sym.line, kind }
+
+  const dot = sym.name.lastIndexOf('.')
+  if (dot !== -1) {
+    const ownerName = sym.name.slice(0, dot)
+    const owner = index.fileSymbols(sym.path).find(s => s.name === ownerName)
+    tag.scope = { kind: SCOPE_KINDS[owner?.kind] || 'class', name: ownerName }
+  }
+  if (CALLABLE_KINDS.has(sym.kind)) {
+    const params = parameterList(sym.signature)
+    if (params) tag.signature = params
+  }
+  const owners = index.owners(sym.path)
+  if (owners.length > 0) tag.owners = owners
+  return tag
+}
+
+/**
+ * Tags for every definition in the index, sorted by name, then file and line
+ */
+export function collectTags(index: SymbolIndex): Tag[] {
+  const tags: Tag[] = []
+  for (const sym of index.allSymbols()) {
+    const tag = tagFor(index, sym)
+    if (tag) tags.push(tag)
+  }
+  return tags.sort((a, b) => {
+    if (a.name !== b.name) return a.name < b.name ? -1 : 1
+    if (a.path !== b.path) return a.path < b.path ? -1 : 1
+    return a.line - b.line
+  })
+}
+
+// Ex search command matching the whole line, falling back to a line number
+function exAddress(text: string | undefined, line: number): string {
+  if (text === undefined) return String(line)
+  return `/^${text.replace(/[\\/]/g, c => `\\${c}`)}$/`
+}
+
+/**
+ * Render an extended-format ctags file
+ */
+export function exportCtags(index: SymbolIndex, options: TagsExportOptions = {}): string {
+  const readSource = options.readSource || (() => undefined)
+  const lines = [
+    '!_TAG_FILE_FORMAT\t2\t/extended format; --format=1 will not append ;" to lines/',
+    '!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/',
+    '!_TAG_PROGRAM_NAME\tindexer\t//',
+    `!_TAG_PROGRAM_VERSION\t${options.toolVersion || '0.0.0'}\t//`
+  ]
+
+  const fileLines = new Map<string, string[] | undefined>()
+  for (const tag of collectTags(index)) {
+    if (!fileLines.has(tag.path)) fileLines.set(tag.path, readSource(tag.path)?.split('\n'))
+    const text = fileLines.get(tag.path)?.[tag.line - 1]?.replace(/\r$/, '')
+    const address = exAddress(text, tag.line)
+    let entry = `${tag.name}\t${tag.path}\t${address};"\t${tag.kind}\tline:${tag.line}`
+    if (tag.scope) entry += `\t${tag.scope.kind}:${tag.scope.name}`
+    if (tag.signature) entry += `\tsignature:${tag.signature}`
+    if (tag.owners) entry += `\towners:${tag.owners.join(',')}`
+    lines.push(entry)
+  }
+
+  return lines.join('\n') + '\n'
+}
+
+/**
+ * Render an Emacs etags (TAGS) file
+ */
+export function exportEtags(index: SymbolIndex, options: TagsExportOptions = {}): string {
+  const readSource = options.readSource || (() => undefined)
+  const byFile = new Map<string, Tag[]>()
+  for (const tag of collectTags(index)) {
+    const list = byFile.get(tag.path) || []
+    list.push(tag)
+    byFile.set(tag.path, list)
+  }
+
+  let out = ''
+  for (const filePath of Array.from(byFile.keys()).sort()) {
+    const source = readSource(filePath)
+    const lines = source?.split('\n') || []
+    const lineOffsets: number[] = []
+    let offset = 0
+    for (const l of lines) {
+      lineOffsets.push(offset)
+      offset += Buffer.byteLength(l, 'utf8') + 1
+    }
+
+    let section = ''
+    const tags = byFile.get(filePath)!.sort((a, b) => a.line - b.line)
+    for (const tag of tags) {
+      const text = lines[tag.line - 1]?.replace(/\r$/, '').trimEnd() || tag.name
+      section += `${text}\x7f${tag.name}\x01${tag.line},${lineOffsets[tag.line - 1] ?? 0}\n`
+    }
+    out += `\x0c\n${filePath},${Buffer.byteLength(section, 'utf8')}\n${section}`
+  }
+  return out
+}
+
+/**
+ * Read the indexed files' contents for tag patterns
+ * @param readFile - Content source (defaults to the working tree)
+ */
+export async function readSources(
+  projectRoot: string,
+  relPaths: string[],
+  readFile: (relPath: string) => Promise<string | null> = relPath => fs.readFile(path.join(projectRoot, relPath), 'utf8')
+): Promise<SourceReader> {
+  const sources = new Map<string, string>()
+  for (const relPath of relPaths) {
+    try {
+      const content = await readFile(relPath)
+      if (content !== null) sources.set(relPath, content)
+    } catch {
+      // Missing files fall back to line-number addresses
+    }
+  }
+  return (relPath: string) => sources.get(relPath)
+}
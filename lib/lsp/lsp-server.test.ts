@@ -0,0 +1,215 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { PassThrough } from 'node:stream'
+import { extractJSSymbols } from '../utils/ast-js.js'
+import { SymbolIndex } from '../core/symbol-index.js'
+import { IndexLanguageServer, serveConnection, wordAt, writeMessage } from './lsp-server.js'
+import { createIndex } from '../test-utils.js'
+
+const ROOT = '/repo'
+
+const USER_SRC = `export class User {
+  doWork() {
+    return 1
+  }
+}
+`
+
+const MAIN_SRC = `import { User } from './user'
+const u = new User()
+u.doWork()
+`
+
+function createServer(): IndexLanguageServer {
+  const index = new SymbolIndex()
+  index.addFile('src/user.ts', 'typescript', extractJSSymbols(USER_SRC))
+  index.addFile('src/main.ts', 'typescript', extractJSSymbols(MAIN_SRC))
+  const server = new IndexLanguageServer(index, ROOT)
+  return server
+}
+
+async function open(server: IndexLanguageServer, relPath: string, text: string) {
+  await server.dispatch('textDocument/didOpen', {
+    textDocument: { uri: server.pathToUri(relPath), languageId: 'typescript', version: 1, text }
+  })
+}
+
+test('lsp-server: wordAt finds the identifier under the cursor', () => {
+  assert.equal(wordAt('u.doWork()', 4), 'doWork')
+  assert.equal(wordAt('u.doWork()', 2), 'doWork')
+  assert.equal(wordAt('  ', 1), null)
+})
+
+test('lsp-server: definition resolves a usage to its declaration', async () => {
+  const server = createServer()
+  await open(server, 'src/main.ts', MAIN_SRC)
+
+  const result = await server.dispatch('textDocument/definition', {
+    textDocument: { uri: server.pathToUri('src/main.ts') },
+    position: { line: 2, character: 4 }
+  })
+  assert.deepEqual(result, [{
+    uri: 'file:///repo/src/user.ts',
+    range: { start: { line: 1, character: 2 }, end: { line: 1, character: 8 } }
+  }])
+})
+
+test('lsp-server: references from the declaration include usages', async () => {
+  const server = createServer()
+  await open(server, 'src/user.ts', USER_SRC)
+
+  const result = await server.dispatch('textDocument/references', {
+    textDocument: { uri: server.pathToUri('src/user.ts') },
+    position: { line: 1, character: 3 },
+    context: { includeDeclaration: true }
+  })
+  assert.deepEqual(result.map((l: any) => [l.uri.replace('file:///repo/', ''), l.range.start.line]), [
+    ['src/user.ts', 1],
+    ['src/main.ts', 2]
+  ])
+})
+
+test('lsp-server: document and workspace symbols', async () => {
+  const server = createServer()
+
+  const docSymbols = await server.dispatch('textDocument/documentSymbol', {
+    textDocument: { uri: server.pathToUri('src/user.ts') }
+  })
+  assert.deepEqual(docSymbols.map((s: any) => [s.name, s.kind, s.containerName]), [
+    ['User', 5, undefined],
+    ['doWork', 6, 'User']
+  ])
+
+  const wsSymbols = await server.dispatch('workspace/symbol', { query: 'work' })
+  assert.deepEqual(wsSymbols.map((s: any) => s.name), ['doWork'])
+})
+
+test('lsp-server: type hierarchy from a class to its base and back', async () => {
+  const server = createServer()
+  const adminSrc = `import { User } from './user'\nexport class Admin extends User {}\n`
+  await open(server, 'src/admin.ts', adminSrc)
+
+  const [item] = await server.dispatch('textDocument/prepareTypeHierarchy', {
+    textDocument: { uri: server.pathToUri('src/admin.ts') },
+    position: { line: 1, character: 15 }
+  })
+  assert.equal(item.name, 'Admin')
+  assert.deepEqual(item.selectionRange.start, { line: 1, character: 13 })
+
+  const supertypes = await server.dispatch('typeHierarchy/supertypes', { item })
+  assert.deepEqual(supertypes.map((s: any) => [s.name, s.uri]), [['User', server.pathToUri('src/user.ts')]])
+  const subtypes = await server.dispatch('typeHierarchy/subtypes', { item: supertypes[0] })
+  assert.deepEqual(subtypes.map((s: any) => s.name), ['Admin'])
+})
+
+test('lsp-server: implementations of an interface leave out dependencies', async () => {
+  const index = createIndex({
+    'src/store.ts': [
+      { name: 'Store', kind: 'interface', line: 1, end_line: 3, column: 18, exported: true },
+      { name: 'MemoryStore', kind: 'class', line: 5, end_line: 7, column: 14, exported: true, implements: ['Store'] }
+    ],
+    'node_modules/cache-lib/index.d.ts': [
+      { name: 'LruStore', kind: 'class', line: 1, end_line: 1, column: 22, exported: true, implements: ['Store'] }
+    ]
+  })
+  const server = new IndexLanguageServer(index, ROOT)
+  const init = await server.dispatch('initialize', {})
+  assert.equal(init.capabilities.implementationProvider, true)
+
+  const result = await server.dispatch('textDocument/implementation', {
+    textDocument: { uri: server.pathToUri('src/store.ts') },
+    position: { line: 0, character: 18 }
+  })
+  assert.deepEqual(result, [{
+    uri: 'file:///repo/src/store.ts',
+    range: { start: { line: 4, character: 13 }, end: { line: 4, character: 24 } }
+  }])
+})
+
+test('lsp-server: open documents are overlaid without changing the index', async () => {
+  const index = new SymbolIndex()
+  index.addFile('src/user.ts', 'typescript', extractJSSymbols(USER_SRC))
+  const server = new IndexLanguageServer(index, ROOT)
+  await open(server, 'src/user.ts', 'export class Account {\n  doWork() {}\n}\n')
+
+  const wsSymbols = await server.dispatch('workspace/symbol', { query: 'Account' })
+  assert.deepEqual(wsSymbols.map((s: any) => s.name), ['Account'])
+  assert.equal(index.findSymbols('Account').length, 0)
+
+  await server.dispatch('textDocument/didClose', { textDocument: { uri: server.pathToUri('src/user.ts') } })
+  assert.deepEqual(await server.dispatch('workspace/symbol', { query: 'Account' }), [])
+  assert.deepEqual((await server.dispatch('workspace/symbol', { query: 'User' })).map((s: any) => s.name), ['User'])
+})
+
+test('lsp-server: highlights and renames a local within its scope', async () => {
+  const src = `export function run(user) {
+  return user.name
+}
+
+export function user() {}
+`
+  const index = new SymbolIndex()
+  index.locals = true
+  index.addFile('src/run.ts', 'typescript', extractJSSymbols(src, { locals: true }))
+  const server = new IndexLanguageServer(index, ROOT)
+  const textDocument = { uri: server.pathToUri('src/run.ts') }
+
+  const highlights = await server.dispatch('textDocument/documentHighlight', { textDocument, position: { line: 1, character: 10 } })
+  assert.deepEqual(highlights.map((h: any) => [h.range.start.line, h.range.start.character, h.kind]), [[0, 20, 3], [1, 9, 2]])
+
+  const rename = await server.dispatch('textDocument/rename', { textDocument, position: { line: 0, character: 21 }, newName: 'account' })
+  assert.deepEqual(rename.changes[textDocument.uri].map((e: any) => [e.range.start.line, e.range.start.character, e.newText]), [
+    [0, 20, 'account'],
+    [1, 9, 'account']
+  ])
+  await assert.rejects(
+    server.dispatch('textDocument/rename', { textDocument, position: { line: 0, character: 21 }, newName: '1st' }),
+    (e: any) => e.code === -32803
+  )
+})
+
+test('lsp-server: unknown requests fail with MethodNotFound', async () => {
+  const server = createServer()
+  await assert.rejects(server.dispatch('textDocument/hover', {}), (e: any) => e.code === -32601)
+})
+
+test('lsp-server: serveConnection frames responses over streams', async () => {
+  const server = createServer()
+  const input = new PassThrough()
+  const output = new PassThrough()
+  const chunks: Buffer[] = []
+  output.on('data', (c: Buffer) => chunks.push(c))
+
+  const done = serveConnection(server, input, output)
+  writeMessage(input, { jsonrpc: '2.0', id: 1, method: 'initialize', params: {} })
+  writeMessage(input, { jsonrpc: '2.0', id: 2, method: 'shutdown' })
+  writeMessage(input, { jsonrpc: '2.0', method: 'exit' })
+  await done
+
+  const text = Buffer.concat(chunks).toString('utf8')
+  const bodies = text.split(/Content-Length: \d+\r\n\r\n/).filter(Boolean).map(b => JSON.parse(b))
+  assert.equal(bodies[0].id, 1)
+  assert.equal(bodies[0].result.capabilities.definitionProvider, true)
+  assert.deepEqual(bodies[1], { jsonrpc: '2.0', id: 2, result: null })
+  assert.equal(server.isShutdown, true)
+})
+
+test('lsp-server: positions are converted to the encoding negotiated at initialize', async () => {
+  const src = 'const café = 1; export function tax() {}\n'
+  const index = new SymbolIndex()
+  index.addFile('src/tax.ts', 'typescript', extractJSSymbols(src), undefined, [{ line: 1, column: 10, count: 1, bytes: 2 }])
+  const server = new IndexLanguageServer(index, ROOT)
+  const init = await server.dispatch('initialize', { capabilities: { general: { positionEncodings: ['utf-8', 'utf-16'] } } })
+  assert.equal(init.capabilities.positionEncoding, 'utf-8')
+
+  const uri = server.pathToUri('src/tax.ts')
+  const [definition] = await server.dispatch('textDocument/definition', {
+    textDocument: { uri },
+    position: { line: 0, character: 33 }
+  })
+  assert.deepEqual(definition.range, { start: { line: 0, character: 33 }, end: { line: 0, character: 36 } })
+
+  // Clients that offer nothing get UTF-16
+  const plain = new IndexLanguageServer(index, ROOT)
+  assert.equal((await plain.dispatch('initialize', {})).capabilities.positionEncoding, 'utf-16')
+})
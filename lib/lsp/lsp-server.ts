@@ -0,0 +1,478 @@
+/**
+ * LSP Server Facade
+ * Serves the symbol index over the Language Server Protocol (stdio or TCP)
+ * with definition, implementation, references, document highlight, semantic
+ * tokens, rename, document symbol, workspace symbol and type hierarchy
+ * support, so any editor can use the index without a bespoke plugin.
+ * Highlights and renames of parameters and local variables follow their
+ * scopes when the index was built with --locals.
+ * References, implementations and subtypes are searched in the workspace,
+ * leaving out uses and derived types inside dependencies; supertypes are
+ * searched everywhere.
+ * Open documents are overlaid on the index, not written to it, so closing an
+ * unsaved buffer leaves the index as it was.
+ */
+
+import net from 'net'
+import path from 'path'
+import fs from 'fs/promises'
+import { fileURLToPath, pathToFileURL } from 'url'
+import type { Readable, Writable } from 'stream'
+import { shortName } from '../core/symbol-index.js'
+import { BufferOverlay } from '../core/buffer-overlay.js'
+import { fuzzySearch } from '../core/fuzzy-search.js'
+import { INTERFACE_KINDS, TYPE_KINDS } from '../core/implementations.js'
+import { DEFAULT_SCOPES } from '../core/search-scope.js'
+import { relativeIndexPath } from '../core/index-paths.js'
+import { TOKEN_MODIFIERS, TOKEN_TYPES, encodeSemanticTokens, fileHighlights, semanticTokens } from '../core/occurrences.js'
+import { POSITION_ENCODINGS, PositionConverter, type PositionEncoding } from '../core/positions.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { IndexedSymbol, Location } from '../types/index.js'
+
+// JSON-RPC error codes
+const METHOD_NOT_FOUND = -32601
+const INTERNAL_ERROR = -32603
+const REQUEST_FAILED = -32803
+
+// LSP DocumentHighlightKind values
+const HIGHLIGHT_READ = 2
+const HIGHLIGHT_WRITE = 3
+
+// LSP SymbolKind values
+const LSP_SYMBOL_KINDS: Record<string, number> = {
+  namespace: 3,
+  package: 4,
+  class: 5,
+  scriptable_object: 5,
+  method: 6,
+  unity_lifecycle: 6,
+  accessor: 6,
+  property: 7,
+  field: 8,
+  private_field: 8,
+  serialized_field: 8,
+  enum: 10,
+  interface: 11,
+  function: 12,
+  hook: 12,
+  function_component: 12,
+  variable: 13,
+  const: 14,
+  constant: 14,
+  struct: 23,
+  type: 26,
+  type_parameter: 26
+}
+
+const WORKSPACE_SYMBOL_LIMIT = 200
+
+interface LspPosition {
+  line: number
+  character: number
+}
+
+interface LspRange {
+  start: LspPosition
+  end: LspPosition
+}
+
+interface LspLocation {
+  uri: string
+  range: LspRange
+}
+
+/**
+ * Identifier under a 0-based character offset, or null
+ */
+export function wordAt(lineText: string, character: number): string | null {
+  const isWordChar = (c: string) => /[A-Za-z0-9_$#]/.test(c)
+  let start = Math.min(character, lineText.length)
+  let end = start
+  while (start > 0 && isWordChar(lineText[start - 1])) start--
+  while (end < lineText.length && isWordChar(lineText[end])) end++
+  return end > start ? lineText.slice(start, end) : null
+}
+
+/**
+ * Transport-independent request handler over a symbol index
+ */
+export class IndexLanguageServer {
+  // Open documents; requests see their unsaved text without it reaching the index
+  private readonly buffers: BufferOverlay
+  private shutdownRequested = false
+  // Unit of `character` agreed on at initialize; the index counts UTF-16 code units
+  private positionEncoding: PositionEncoding = 'utf-16'
+  private bufferPositions = new Map<string, { text: string, converter: PositionConverter }>()
+
+  constructor(
+    private readonly index: SymbolIndex,
+    private readonly projectRoot: string
+  ) {
+    this.buffers = new BufferOverlay(index)
+  }
+
+  /**
+   * Index path of a document URI. Editors on a case-insensitive file system
+   * may spell a path in another case than the index does; it resolves to
+   * the indexed file. Files the index does not hold yet keep their spelling.
+   */
+  uriToPath(uri: string): string | null {
+    try {
+      const rel = relativeIndexPath(this.projectRoot, fileURLToPath(uri))
+      if (rel === null) return null
+      return this.index.resolvePath(rel) ?? rel
+    } catch {
+      return null
+    }
+  }
+
+  pathToUri(relPath: string): string {
+    return pathToFileURL(path.join(this.projectRoot, relPath)).href
+  }
+
+  private converter(relPath: string): PositionConverter | null {
+    if (this.positionEncoding === 'utf-16') return null
+    const text = this.buffers.content(relPath)
+    if (text === undefined) return this.index.positions(relPath)
+    const cached = this.bufferPositions.get(relPath)
+    if (cached?.text === text) return cached.converter
+    const converter = PositionConverter.fromText(text)
+    this.bufferPositions.set(relPath, { text, converter })
+    return converter
+  }
+
+  /**
+   * 0-based character, in the client's encoding, of a 1-based index column
+   */
+  private character(relPath: string, line: number, column: number): number {
+    const converter = this.converter(relPath)
+    return Math.max((converter ? converter.convert(line, column, 'utf-16', this.positionEncoding) : column) - 1, 0)
+  }
+
+  /**
+   * 1-based index column of a client position
+   */
+  private indexColumn(relPath: string, position: LspPosition): number {
+    const converter = this.converter(relPath)
+    const column = position.character + 1
+    return converter ? converter.convert(position.line + 1, column, this.positionEncoding, 'utf-16') : column
+  }
+
+  private nameLocation(loc: Location, name: string): LspLocation {
+    const column = loc.column || 1
+    return {
+      uri: this.pathToUri(loc.path),
+      range: {
+        start: { line: loc.line - 1, character: this.character(loc.path, loc.line, column) },
+        end: { line: loc.line - 1, character: this.character(loc.path, loc.line, column + name.length) }
+      }
+    }
+  }
+
+  private symbolInformation(sym: IndexedSymbol) {
+    const dot = sym.name.lastIndexOf('.')
+    return {
+      name: shortName(sym.name),
+      kind: LSP_SYMBOL_KINDS[sym.kind] || 13,
+      location: {
+        uri: this.pathToUri(sym.path),
+        range: {
+          start: { line: sym.line - 1, character: this.character(sym.path, sym.line, sym.column || 1) },
+          end: { line: sym.end_line - 1, character: 0 }
+        }
+      },
+      ...(dot !== -1 ? { containerName: sym.name.slice(0, dot) } : {})
+    }
+  }
+
+  private typeHierarchyItem(sym: IndexedSymbol) {
+    const { location, containerName } = this.symbolInformation(sym)
+    return {
+      name: shortName(sym.name),
+      kind: LSP_SYMBOL_KINDS[sym.kind] || 5,
+      ...(containerName ? { detail: containerName } : {}),
+      uri: location.uri,
+      range: location.range,
+      selectionRange: this.nameLocation(sym, shortName(sym.name)).range,
+      data: { id: sym.id }
+    }
+  }
+
+  private async documentText(relPath: string): Promise<string | null> {
+    const buffer = this.buffers.content(relPath)
+    if (buffer !== undefined) return buffer
+    try {
+      return await fs.readFile(path.join(this.projectRoot, relPath), 'utf8')
+    } catch {
+      return null
+    }
+  }
+
+  /**
+   * Symbols a position refers to: the indexed definition or reference there,
+   * otherwise (for text the index has no columns for) every definition of the
+   * identifier under the cursor, local ones first. Resolved against a snapshot
+   * (with open documents merged in) so an edit arriving meanwhile cannot
+   * change the answer halfway.
+   */
+  async symbolsAt(uri: string, position: LspPosition, index?: SymbolIndex): Promise<IndexedSymbol[]> {
+    const relPath = this.uriToPath(uri)
+    if (!relPath) return []
+    index = index || await this.buffers.index()
+    const column = this.indexColumn(relPath, position)
+    const at = index.symbolAt(relPath, position.line + 1, column)
+    if (at) return at.symbols
+    const text = await this.documentText(relPath)
+    const word = text === null ? null : wordAt(text.split('\n')[position.line] || '', column - 1)
+    if (!word) return []
+
+    const candidates = index.findSymbols(word)
+    const declared = candidates.filter(s => s.path === relPath && s.line === position.line + 1)
+    if (declared.length > 0) return declared
+    const local = candidates.filter(s => s.path === relPath)
+    return [...local, ...candidates.filter(s => s.path !== relPath)]
+  }
+
+  private refresh(uri: string, text: string): void {
+    const relPath = this.uriToPath(uri)
+    if (!relPath) return
+    this.buffers.set(relPath, text)
+  }
+
+  /**
+   * Handle one JSON-RPC request or notification
+   * @returns Result for requests, undefined for notifications
+   */
+  async dispatch(method: string, params: any): Promise<any> {
+    switch (method) {
+      case 'initialize': {
+        // Clients list the encodings they support, most preferred first
+        const offered: string[] = params?.capabilities?.general?.positionEncodings || []
+        this.positionEncoding = (offered.find(e => POSITION_ENCODINGS.includes(e as PositionEncoding)) as PositionEncoding) || 'utf-16'
+        return {
+          capabilities: {
+            positionEncoding: this.positionEncoding,
+            textDocumentSync: 1,
+            definitionProvider: true,
+            implementationProvider: true,
+            referencesProvider: true,
+            documentHighlightProvider: true,
+            semanticTokensProvider: {
+              legend: { tokenTypes: [...TOKEN_TYPES], tokenModifiers: [...TOKEN_MODIFIERS] },
+              full: true
+            },
+            renameProvider: true,
+            documentSymbolProvider: true,
+            workspaceSymbolProvider: true,
+            typeHierarchyProvider: true
+          },
+          serverInfo: { name: 'indexer' }
+        }
+      }
+      case 'shutdown':
+        this.shutdownRequested = true
+        return null
+      case 'textDocument/didOpen':
+        this.refresh(params.textDocument.uri, params.textDocument.text)
+        return undefined
+      case 'textDocument/didChange': {
+        const changes = params.contentChanges || []
+        const last = changes[changes.length - 1]
+        if (last && last.range === undefined) this.refresh(params.textDocument.uri, last.text)
+        return undefined
+      }
+      case 'textDocument/didClose': {
+        const relPath = this.uriToPath(params.textDocument.uri)
+        if (relPath) {
+          this.buffers.close(relPath)
+          this.bufferPositions.delete(relPath)
+        }
+        return undefined
+      }
+      case 'textDocument/definition': {
+        const symbols = await this.symbolsAt(params.textDocument.uri, params.position)
+        return symbols.map(s => this.nameLocation(s, shortName(s.name)))
+      }
+      case 'textDocument/implementation': {
+        const index = await this.buffers.index()
+        const symbols = await this.symbolsAt(params.textDocument.uri, params.position, index)
+        return symbols
+          .filter(s => INTERFACE_KINDS.has(s.kind))
+          .flatMap(s => index.implementations(s.id, DEFAULT_SCOPES.implementations))
+          .map(s => this.nameLocation(s, shortName(s.name)))
+      }
+      case 'textDocument/references': {
+        const index = await this.buffers.index()
+        const symbols = await this.symbolsAt(params.textDocument.uri, params.position, index)
+        const result: LspLocation[] = []
+        for (const sym of symbols) {
+          const name = shortName(sym.name)
+          if (params.context?.includeDeclaration) result.push(this.nameLocation(sym, name))
+          for (const ref of index.references(sym.id, undefined, DEFAULT_SCOPES.references)) result.push(this.nameLocation(ref, name))
+        }
+        return result
+      }
+      case 'textDocument/documentHighlight': {
+        const relPath = this.uriToPath(params.textDocument.uri)
+        if (!relPath) return []
+        const index = await this.buffers.index()
+        const symbols = await this.symbolsAt(params.textDocument.uri, params.position, index)
+        return fileHighlights(index, relPath, symbols).map(h => ({
+          range: {
+            start: { line: h.line - 1, character: this.character(relPath, h.line, h.column) },
+            end: { line: h.line - 1, character: this.character(relPath, h.line, h.end_column) }
+          },
+          kind: h.kind === 'write' ? HIGHLIGHT_WRITE : HIGHLIGHT_READ
+        }))
+      }
+      case 'textDocument/semanticTokens/full': {
+        const relPath = this.uriToPath(params.textDocument.uri)
+        if (!relPath) return { data: [] }
+        const tokens = semanticTokens(await this.buffers.index(), relPath)
+        return { data: encodeSemanticTokens(tokens, (line, column) => this.character(relPath, line, column)) }
+      }
+      case 'textDocument/rename': {
+        const index = await this.buffers.index()
+        const [sym] = await this.symbolsAt(params.textDocument.uri, params.position, index)
+        if (!sym) return null
+        const plan = index.renameTargets(sym.id, String(params.newName))
+        // A reference that may be another symbol's is still renamed; anything else would break code
+        const blocking = plan.conflicts.find(c => c.kind !== 'ambiguous')
+        if (blocking) throw Object.assign(new Error(blocking.message), { code: REQUEST_FAILED })
+        const changes: Record<string, { range: LspRange, newText: string }[]> = {}
+        for (const edit of plan.edits) {
+          const uri = this.pathToUri(edit.path)
+          if (!changes[uri]) changes[uri] = []
+          changes[uri].push({
+            range: {
+              start: { line: edit.line - 1, character: this.character(edit.path, edit.line, edit.column) },
+              end: { line: edit.line - 1, character: this.character(edit.path, edit.line, edit.end_column) }
+            },
+            newText: edit.new_text
+          })
+        }
+        return { changes }
+      }
+      case 'textDocument/documentSymbol': {
+        const relPath = this.uriToPath(params.textDocument.uri)
+        if (!relPath) return []
+        return (await this.buffers.index()).fileSymbols(relPath).map(s => this.symbolInformation(s))
+      }
+      case 'textDocument/prepareTypeHierarchy': {
+        const symbols = await this.symbolsAt(params.textDocument.uri, params.position)
+        const types = symbols.filter(s => TYPE_KINDS.has(s.kind) || INTERFACE_KINDS.has(s.kind))
+        return types.length > 0 ? types.map(s => this.typeHierarchyItem(s)) : null
+      }
+      case 'typeHierarchy/supertypes':
+      case 'typeHierarchy/subtypes': {
+        const id = params?.item?.data?.id
+        if (typeof id !== 'string') return null
+        const index = await this.buffers.index()
+        const items = method === 'typeHierarchy/supertypes'
+          ? index.supertypes(id, false, DEFAULT_SCOPES.supertypes)
+          : index.subtypes(id, false, DEFAULT_SCOPES.subtypes)
+        return items.map(item => this.typeHierarchyItem(item.symbol))
+      }
+      case 'workspace/symbol': {
+        return fuzzySearch(await this.buffers.index(), String(params?.query || ''), { limit: WORKSPACE_SYMBOL_LIMIT })
+          .map(m => this.symbolInformation(m.symbol))
+      }
+      default:
+        if (method.startsWith('$/') || method === 'initialized' || method === 'exit') return undefined
+        throw Object.assign(new Error(`Method not found: ${method}`), { code: METHOD_NOT_FOUND })
+    }
+  }
+
+  get isShutdown(): boolean {
+    return this.shutdownRequested
+  }
+}
+
+/**
+ * Frame and write a JSON-RPC message
+ */
+export function writeMessage(output: Writable, message: any): void {
+  const content = Buffer.from(JSON.stringify(message), 'utf8')
+  output.write(`Content-Length: ${content.length}\r\n\r\n`)
+  output.write(content)
+}
+
+/**
+ * Serve one LSP connection over a pair of streams
+ * @returns Promise that resolves when the client disconnects or sends exit
+ */
+export function serveConnection(server: IndexLanguageServer, input: Readable, output: Writable): Promise<void> {
+  let buffer = Buffer.alloc(0)
+  // Messages are handled in order so edits land before the queries that follow
+  let chain: Promise<void> = Promise.resolve()
+
+  return new Promise((resolve) => {
+    const handle = async (message: any) => {
+      const isRequest = message.id !== undefined && message.id !== null
+      try {
+        const result = await server.dispatch(message.method, message.params)
+        if (isRequest) writeMessage(output, { jsonrpc: '2.0', id: message.id, result: result ?? null })
+      } catch (e: any) {
+        if (isRequest) {
+          writeMessage(output, {
+            jsonrpc: '2.0',
+            id: message.id,
+            error: { code: e.code || INTERNAL_ERROR, message: e.message }
+          })
+        }
+      }
+      if (message.method === 'exit') {
+        input.removeListener('data', onData)
+        resolve()
+      }
+    }
+
+    const onData = (chunk: Buffer) => {
+      buffer = Buffer.concat([buffer, chunk])
+      while (true) {
+        const headerEnd = buffer.indexOf('\r\n\r\n')
+        if (headerEnd === -1) break
+        const match = buffer.subarray(0, headerEnd).toString('ascii').match(/Content-Length: *(\d+)/i)
+        if (!match) {
+          buffer = buffer.subarray(headerEnd + 4)
+          continue
+        }
+        const start = headerEnd + 4
+        const end = start + parseInt(match[1], 10)
+        if (buffer.length < end) break
+        const body = buffer.subarray(start, end).toString('utf8')
+        buffer = buffer.subarray(end)
+        try {
+          const message = JSON.parse(body)
+          chain = chain.then(() => handle(message))
+        } catch (e: any) {
+          console.error(`[lsp-server] Failed to parse message: ${e.message}`)
+        }
+      }
+    }
+
+    input.on('data', onData)
+    input.once('end', () => resolve())
+    input.once('close', () => resolve())
+  })
+}
+
+/**
+ * Serve the index over stdio until the client exits
+ */
+export async function serveStdio(index: SymbolIndex, projectRoot: string): Promise<void> {
+  await serveConnection(new IndexLanguageServer(index, projectRoot), process.stdin, process.stdout)
+}
+
+/**
+ * Serve the index over TCP; every connection gets its own session
+ */
+export function serveTcp(index: SymbolIndex, projectRoot: string, port: number, host = '127.0.0.1'): Promise<net.Server> {
+  const server = net.createServer((socket) => {
+    void serveConnection(new IndexLanguageServer(index, projectRoot), socket, socket).then(() => socket.end())
+  })
+  return new Promise((resolve, reject) => {
+    server.once('error', reject)
+    server.listen(port, host, () => resolve(server))
+  })
+}
@@ -0,0 +1,104 @@
+/**
+ * Profiling Endpoints
+ * The Node.js counterpart of Go's /debug/pprof for a long-running server:
+ *   GET /debug/pprof/                    index of the profiles
+ *   GET /debug/pprof/profile?seconds=N   CPU profile over N seconds (default 30), as .cpuprofile JSON
+ *   GET /debug/pprof/heap                V8 heap snapshot, as .heapsnapshot JSON
+ *   GET /debug/pprof/cmdline             command line of the process
+ * Profiles open in Chrome DevTools (Performance and Memory panels) or
+ * speedscope. Heap snapshots contain every string in memory, so the
+ * endpoints are only mounted when asked for.
+ */
+
+import http from 'http'
+import inspector from 'inspector'
+import v8 from 'v8'
+import { pipeline } from 'stream/promises'
+
+export const PPROF_PREFIX = '/debug/pprof'
+
+const DEFAULT_PROFILE_SECONDS = 30
+const MAX_PROFILE_SECONDS = 300
+
+let profiling = false
+
+function post<T = any>(session: inspector.Session, method: string, params?: object): Promise<T> {
+  return new Promise((resolve, reject) => {
+    session.post(method, params, (err, result) => (err ? reject(err) : resolve(result as T)))
+  })
+}
+
+/**
+ * Sample the CPU of this process for a while
+ * @returns The profile in the .cpuprofile format
+ */
+export async function captureCpuProfile(seconds: number): Promise<object> {
+  if (profiling) throw new Error('A CPU profile is already being taken')
+  profiling = true
+  const session = new inspector.Session()
+  session.connect()
+  try {
+    await post(session, 'Profiler.enable')
+    await post(session, 'Profiler.start')
+    await new Promise(resolve => setTimeout(resolve, seconds * 1000))
+    const { profile } = await post<{ profile: object }>(session, 'Profiler.stop')
+    return profile
+  } finally {
+    session.disconnect()
+    profiling = false
+  }
+}
+
+function sendText(res: http.ServerResponse, status: number, text: string): void {
+  res.writeHead(status, { 'Content-Type': 'text/plain; charset=utf-8', 'Content-Length': Buffer.byteLength(text) })
+  res.end(text)
+}
+
+function attachment(res: http.ServerResponse, filename: string): void {
+  res.setHeader('Content-Type', 'application/json')
+  res.setHeader('Content-Disposition', `attachment; filename="${filename}"`)
+}
+
+/**
+ * Answer a request under /debug/pprof
+ */
+export async function handlePprofRequest(url: URL, res: http.ServerResponse): Promise<void> {
+  const name = url.pathname.slice(PPROF_PREFIX.length).replace(/^\/|\/$/g, '')
+  switch (name) {
+    case '':
+      sendText(res, 200, [
+        'Profiles:',
+        `  ${PPROF_PREFIX}/profile?seconds=${DEFAULT_PROFILE_SECONDS}  CPU profile (.cpuprofile)`,
+        `  ${PPROF_PREFIX}/heap                V8 heap snapshot (.heapsnapshot)`,
+        `  ${PPROF_PREFIX}/cmdline             command line`,
+        ''
+      ].join('\n'))
+      return
+    case 'cmdline':
+      sendText(res, 200, process.argv.join('\0'))
+      return
+    case 'profile': {
+      const raw = url.searchParams.get('seconds')
+      const seconds = raw === null ? DEFAULT_PROFILE_SECONDS : Number(raw)
+      if (!(seconds > 0 && seconds <= MAX_PROFILE_SECONDS)) {
+        sendText(res, 400, `seconds must be a number in (0, ${MAX_PROFILE_SECONDS}]\n`)
+        return
+      }
+      if (profiling) {
+        sendText(res, 409, 'A CPU profile is already being taken\n')
+        return
+      }
+      const profile = JSON.stringify(await captureCpuProfile(seconds))
+      attachment(res, `indexer-${process.pid}.cpuprofile`)
+      res.writeHead(200, { 'Content-Length': Buffer.byteLength(profile) })
+      res.end(profile)
+      return
+    }
+    case 'heap':
+      attachment(res, `indexer-${process.pid}.heapsnapshot`)
+      res.writeHead(200)
+      await pipeline(v8.getHeapSnapshot(), res)
+      return
+  }
+  sendText(res, 404, `Unknown profile ${name}\n`)
+}
@@ -0,0 +1,112 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import http2 from 'node:http2'
+import type { AddressInfo } from 'node:net'
+import { makeSymbolId } from '../core/symbol-index.js'
+import { ProtoWriter, decodeFields } from '../utils/protobuf.js'
+import { GRPC_SERVICE, GRPC_STATUS, grpcFrame, parseGrpcFrames, serveGrpc } from './grpc-server.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER_SRC = `export class User {
+  doWork() {}
+}
+`
+const MAIN_SRC = `import { User } from './user'
+export function main() {
+  new User().doWork()
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': USER_SRC,
+  'src/main.ts': MAIN_SRC
+}
+
+interface CallResult {
+  status: number
+  messages: Map<number, string | number>[]
+}
+
+async function call(port: number, method: string, request: Buffer): Promise<CallResult> {
+  const client = http2.connect(`http://127.0.0.1:${port}`)
+  try {
+    const req = client.request({
+      ':method': 'POST',
+      ':path': `/${GRPC_SERVICE}/${method}`,
+      'content-type': 'application/grpc',
+      te: 'trailers'
+    })
+    req.end(grpcFrame(request))
+    let status = -1
+    req.on('response', (headers) => {
+      if (headers['grpc-status'] !== undefined) status = Number(headers['grpc-status'])
+    })
+    req.on('trailers', (trailers) => { status = Number(trailers['grpc-status']) })
+    const chunks: Buffer[] = []
+    for await (const chunk of req) chunks.push(chunk as Buffer)
+    const messages = parseGrpcFrames(Buffer.concat(chunks)).map(m =>
+      new Map(decodeFields(m).map(f => [f.field, Buffer.isBuffer(f.value) ? f.value.toString('utf8') : f.value]))
+    )
+    return { status, messages }
+  } finally {
+    client.close()
+  }
+}
+
+test('grpc-server: streams definitions, references, search, file symbols and positions', async () => {
+  const server = await serveGrpc(createIndex(FILES), 0)
+  const { port } = server.address() as AddressInfo
+  try {
+    const defs = await call(port, 'Definitions', new ProtoWriter().string(1, 'doWork').finish())
+    assert.equal(defs.status, GRPC_STATUS.OK)
+    assert.deepEqual(defs.messages.map(m => [m.get(2), m.get(4), m.get(5)]), [['User.doWork', 'src/user.ts', 2]])
+
+    const refs = await call(port, 'References', new ProtoWriter().string(1, makeSymbolId('src/user.ts', 'User.doWork')).finish())
+    assert.equal(refs.status, GRPC_STATUS.OK)
+    assert.deepEqual(refs.messages.map(m => [m.get(1), m.get(2)]), [['src/main.ts', 3]])
+
+    const search = await call(port, 'SearchSymbols', new ProtoWriter().string(1, 'usrdw').finish())
+    assert.equal(search.messages[0].get(2), 'User.doWork')
+
+    const file = await call(port, 'FileSymbols', new ProtoWriter().string(1, 'src/user.ts').finish())
+    assert.deepEqual(file.messages.map(m => m.get(2)), ['User', 'User.doWork'])
+
+    const at = await call(port, 'SymbolAt', new ProtoWriter().string(1, 'src/main.ts').varint(2, 3).varint(3, 15).finish())
+    assert.deepEqual(at.messages.map(m => m.get(1)), [makeSymbolId('src/user.ts', 'User.doWork')])
+  } finally {
+    server.close()
+  }
+})
+
+test('grpc-server: reports errors as gRPC status codes', async () => {
+  const server = await serveGrpc(createIndex(FILES), 0)
+  const { port } = server.address() as AddressInfo
+  try {
+    assert.equal((await call(port, 'Definitions', Buffer.alloc(0))).status, GRPC_STATUS.INVALID_ARGUMENT)
+    assert.equal((await call(port, 'References', new ProtoWriter().string(1, 'nope').finish())).status, GRPC_STATUS.NOT_FOUND)
+    assert.equal((await call(port, 'Rename', Buffer.alloc(0))).status, GRPC_STATUS.UNIMPLEMENTED)
+  } finally {
+    server.close()
+  }
+})
+
+test('grpc-server: references leave out dependencies unless the request scopes them in', async () => {
+  const server = await serveGrpc(createIndex({
+    'src/user.ts': [{ name: 'User', kind: 'class', line: 1, end_line: 3, column: 14, exported: true }],
+    'src/main.ts': [{ name: 'User', kind: 'reference', line: 2, column: 7, call: true }],
+    'node_modules/orm/index.d.ts': [{ name: 'User', kind: 'reference', line: 4, column: 9 }]
+  }), 0)
+  const { port } = server.address() as AddressInfo
+  const refs = async (scope?: string) => {
+    const request = new ProtoWriter().string(1, makeSymbolId('src/user.ts', 'User'))
+    if (scope) request.string(3, scope)
+    return call(port, 'References', request.finish())
+  }
+  try {
+    assert.deepEqual((await refs()).messages.map(m => m.get(1)), ['src/main.ts'])
+    assert.deepEqual((await refs('all')).messages.map(m => m.get(1)).sort(), ['node_modules/orm/index.d.ts', 'src/main.ts'])
+    assert.equal((await refs('galaxy')).status, GRPC_STATUS.INVALID_ARGUMENT)
+  } finally {
+    server.close()
+  }
+})
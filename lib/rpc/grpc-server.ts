@@ -0,0 +1,267 @@
+/**
+ * gRPC Query Service
+ * Serves the symbol index over gRPC (plaintext HTTP/2) so other services can
+ * query a centrally built index. All methods stream their results; messages
+ * are encoded with the protobuf helpers, following indexer.proto.
+ * Serving several workspaces, a request names its workspace in field 15.
+ * With metrics, every call is timed by method and status code.
+ */
+
+import http2 from 'http2'
+import { once } from 'events'
+import { ProtoWriter, WIRE_LENGTH_DELIMITED, WIRE_VARINT, decodeFields } from '../utils/protobuf.js'
+import { fuzzySearch } from '../core/fuzzy-search.js'
+import { parseAliasUsage, type AliasUsage } from '../core/type-aliases.js'
+import { DEFAULT_SCOPES, parseScope, type SearchScope } from '../core/search-scope.js'
+import { WorkspaceError, WorkspaceSet } from './workspaces.js'
+import type { ServerMetrics } from './metrics.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { IndexedSymbol, Location, ReferenceLocation } from '../types/index.js'
+
+export const GRPC_SERVICE = 'indexer.v1.SymbolIndex'
+
+// gRPC status codes
+export const GRPC_STATUS = {
+  OK: 0,
+  INVALID_ARGUMENT: 3,
+  NOT_FOUND: 5,
+  UNIMPLEMENTED: 12,
+  INTERNAL: 13
+} as const
+
+const DEFAULT_SEARCH_LIMIT = 100
+// Field of the workspace ID in every request message
+const WORKSPACE_FIELD = 15
+// Length-prefixed message header: compressed flag u8 + length u32
+const FRAME_HEADER_SIZE = 5
+
+export class GrpcError extends Error {
+  constructor(readonly code: number, message: string) {
+    super(message)
+  }
+}
+
+type RequestFields = Map<number, string | number>
+type Method = (index: SymbolIndex, request: RequestFields) => Buffer[]
+
+export function encodeSymbol(sym: IndexedSymbol): Buffer {
+  return new ProtoWriter()
+    .string(1, sym.id)
+    .string(2, sym.name)
+    .string(3, sym.kind)
+    .string(4, sym.path)
+    .varint(5, sym.line)
+    .varint(6, sym.end_line)
+    .string(7, sym.lang)
+    .bool(8, !!sym.exported)
+    .string(9, sym.signature)
+    .string(10, sym.doc)
+    .string(11, sym.module)
+    .string(12, sym.module_version)
+    .finish()
+}
+
+export function encodeLocation(loc: Location): Buffer {
+  return new ProtoWriter()
+    .string(1, loc.path)
+    .varint(2, loc.line)
+    .varint(3, loc.column)
+    .varint(4, loc.end_line)
+    .string(5, (loc as ReferenceLocation).via)
+    .finish()
+}
+
+/**
+ * Prefix a message with the gRPC frame header
+ */
+export function grpcFrame(message: Uint8Array): Buffer {
+  const frame = Buffer.alloc(FRAME_HEADER_SIZE + message.length)
+  frame.writeUInt8(0, 0)
+  frame.writeUInt32BE(message.length, 1)
+  frame.set(message, FRAME_HEADER_SIZE)
+  return frame
+}
+
+/**
+ * Split a body into gRPC messages
+ */
+export function parseGrpcFrames(body: Buffer): Buffer[] {
+  const messages: Buffer[] = []
+  let pos = 0
+  while (pos < body.length) {
+    if (pos + FRAME_HEADER_SIZE > body.length) throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, 'Truncated message frame')
+    if (body[pos] !== 0) throw new GrpcError(GRPC_STATUS.UNIMPLEMENTED, 'Compressed messages are not supported')
+    const length = body.readUInt32BE(pos + 1)
+    const start = pos + FRAME_HEADER_SIZE
+    if (start + length > body.length) throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, 'Truncated message frame')
+    messages.push(body.subarray(start, start + length))
+    pos = start + length
+  }
+  return messages
+}
+
+/**
+ * Top-level scalar fields of a request message (strings and varints)
+ */
+function requestFields(message: Buffer | undefined): RequestFields {
+  const fields: RequestFields = new Map()
+  try {
+    for (const f of decodeFields(message || Buffer.alloc(0))) {
+      if (f.wireType === WIRE_LENGTH_DELIMITED) fields.set(f.field, (f.value as Buffer).toString('utf8'))
+      else if (f.wireType === WIRE_VARINT) fields.set(f.field, f.value as number)
+    }
+  } catch (e: any) {
+    throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, `Malformed request: ${e.message}`)
+  }
+  return fields
+}
+
+function requiredString(request: RequestFields, field: number, name: string): string {
+  const value = request.get(field)
+  if (typeof value !== 'string' || value === '') {
+    throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, `${name} is required`)
+  }
+  return value
+}
+
+// Index a request is for
+function requestIndex(source: SymbolIndex | WorkspaceSet, request: RequestFields): SymbolIndex {
+  if (!(source instanceof WorkspaceSet)) return source
+  const id = request.get(WORKSPACE_FIELD)
+  try {
+    return source.resolve(typeof id === 'string' ? id : null).index
+  } catch (e) {
+    if (e instanceof WorkspaceError) throw new GrpcError(e.unknown ? GRPC_STATUS.NOT_FOUND : GRPC_STATUS.INVALID_ARGUMENT, e.message)
+    throw e
+  }
+}
+
+// Indexed path a request names, with its separators (and case, see index-paths) resolved
+function indexedFile(index: SymbolIndex, value: string): string {
+  const filePath = index.resolvePath(value)
+  if (filePath === null) throw new GrpcError(GRPC_STATUS.NOT_FOUND, `File not indexed: ${value}`)
+  return filePath
+}
+
+const METHODS: Record<string, Method> = {
+  Definitions: (index, request) =>
+    index.findSymbols(requiredString(request, 1, 'name')).map(encodeSymbol),
+
+  References: (index, request) => {
+    const symbolId = index.resolveSymbolId(requiredString(request, 1, 'symbol_id'))
+    if (!index.getSymbol(symbolId)) throw new GrpcError(GRPC_STATUS.NOT_FOUND, `Unknown symbol ${symbolId}`)
+    const via = request.get(2)
+    const scope = request.get(3)
+    let usage: AliasUsage = 'direct'
+    let searchScope: SearchScope = DEFAULT_SCOPES.references
+    try {
+      if (typeof via === 'string' && via) usage = parseAliasUsage(via)
+      if (typeof scope === 'string' && scope) searchScope = parseScope(scope)
+    } catch (e: any) {
+      throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, e.message)
+    }
+    return index.references(symbolId, undefined, searchScope, usage).map(encodeLocation)
+  },
+
+  SearchSymbols: (index, request) => {
+    const query = requiredString(request, 1, 'query')
+    const limit = Number(request.get(2)) || DEFAULT_SEARCH_LIMIT
+    const offset = Number(request.get(3)) || 0
+    return fuzzySearch(index, query, { limit, offset }).map(m => encodeSymbol(m.symbol))
+  },
+
+  FileSymbols: (index, request) => {
+    const filePath = indexedFile(index, requiredString(request, 1, 'path'))
+    return index.fileSymbols(filePath).map(encodeSymbol)
+  },
+
+  SymbolAt: (index, request) => {
+    const filePath = requiredString(request, 1, 'path')
+    const line = Number(request.get(2))
+    const column = Number(request.get(3))
+    if (!line || !column) throw new GrpcError(GRPC_STATUS.INVALID_ARGUMENT, 'line and column are required')
+    return (index.symbolAt(indexedFile(index, filePath), line, column)?.symbols || []).map(encodeSymbol)
+  }
+}
+
+function respondWithStatus(stream: http2.ServerHttp2Stream, code: number, message: string): void {
+  stream.respond({
+    ':status': 200,
+    'content-type': 'application/grpc',
+    'grpc-status': String(code),
+    'grpc-message': encodeURIComponent(message)
+  }, { endStream: true })
+}
+
+async function readBody(stream: http2.ServerHttp2Stream): Promise<Buffer> {
+  const chunks: Buffer[] = []
+  for await (const chunk of stream) chunks.push(chunk as Buffer)
+  return Buffer.concat(chunks)
+}
+
+/**
+ * Handle one gRPC call on an HTTP/2 stream, against an index or the
+ * workspace the request names
+ */
+export async function handleGrpcStream(
+  source: SymbolIndex | WorkspaceSet,
+  stream: http2.ServerHttp2Stream,
+  headers: http2.IncomingHttpHeaders,
+  metrics?: ServerMetrics
+): Promise<void> {
+  const contentType = String(headers['content-type'] || '')
+  if (headers[':method'] !== 'POST' || !contentType.startsWith('application/grpc')) {
+    stream.respond({ ':status': 415 }, { endStream: true })
+    return
+  }
+
+  const [service, methodName] = String(headers[':path'] || '').replace(/^\//, '').split('/')
+  const method = service === GRPC_SERVICE ? METHODS[methodName] : undefined
+
+  const start = process.hrtime.bigint()
+  const observe = (code: number) => {
+    const seconds = Number(process.hrtime.bigint() - start) / 1e9
+    metrics?.observeQuery('grpc', method ? methodName : 'unknown', code, seconds)
+  }
+
+  let messages: Buffer[]
+  try {
+    const body = await readBody(stream)
+    if (!method) throw new GrpcError(GRPC_STATUS.UNIMPLEMENTED, `Unknown method ${headers[':path']}`)
+    const request = requestFields(parseGrpcFrames(body)[0])
+    messages = method(requestIndex(source, request), request)
+  } catch (e: any) {
+    const code = e instanceof GrpcError ? e.code : GRPC_STATUS.INTERNAL
+    observe(code)
+    if (!stream.destroyed) respondWithStatus(stream, code, e.message)
+    return
+  }
+  observe(GRPC_STATUS.OK)
+
+  stream.respond({ ':status': 200, 'content-type': 'application/grpc' }, { waitForTrailers: true })
+  stream.once('wantTrailers', () => stream.sendTrailers({ 'grpc-status': String(GRPC_STATUS.OK) }))
+  for (const message of messages) {
+    if (stream.destroyed) return
+    if (!stream.write(grpcFrame(message))) await Promise.race([once(stream, 'drain'), once(stream, 'close')])
+  }
+  stream.end()
+}
+
+/**
+ * Serve the index, or a set of workspaces, over gRPC on a plaintext HTTP/2 port
+ */
+export function serveGrpc(
+  source: SymbolIndex | WorkspaceSet,
+  port: number,
+  host = '127.0.0.1',
+  metrics?: ServerMetrics
+): Promise<http2.Http2Server> {
+  const server = http2.createServer()
+  server.on('stream', (stream, headers) => {
+    void handleGrpcStream(source, stream, headers, metrics).catch(() => stream.destroy())
+  })
+  return new Promise((resolve, reject) => {
+    server.once('error', reject)
+    server.listen(port, host, () => resolve(server))
+  })
+}
@@ -0,0 +1,274 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import type { AddressInfo } from 'node:net'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { SymbolIndex, makeSymbolId } from '../core/symbol-index.js'
+import { serveHttp, type HttpServerOptions } from './http-server.js'
+import { ServerMetrics } from './metrics.js'
+import { SnippetCache } from '../core/snippets.js'
+import { WorkspaceSet, checkWorkspaceRoots, parseWorkspaceSpecs } from './workspaces.js'
+import { findProjectRoot } from '../cli/cli-config.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const USER_SRC = `export class User {
+  save() {}
+  load() {}
+  remove() {}
+}
+`
+const MAIN_SRC = `import { User } from './user'
+export function main(u) {
+  u.save()
+  u.save()
+  u.save()
+}
+`
+
+const FILES: FixtureFiles = {
+  'src/user.ts': USER_SRC,
+  'src/main.ts': MAIN_SRC
+}
+
+async function withServer(
+  fn: (base: string) => Promise<void>,
+  options: (index: SymbolIndex) => HttpServerOptions = () => ({})
+) {
+  const index = createIndex(FILES)
+  const server = await serveHttp(index, 0, '127.0.0.1', options(index))
+  try {
+    await fn(`http://127.0.0.1:${(server.address() as AddressInfo).port}`)
+  } finally {
+    server.close()
+  }
+}
+
+test('http-server: symbol search pages with cursors', async () => {
+  await withServer(async (base) => {
+    const first = await (await fetch(`${base}/symbols?kind=method&limit=2`)).json()
+    assert.deepEqual(first.items.map((s: any) => s.name), ['User.save', 'User.load'])
+    assert.ok(first.next_cursor)
+
+    const second = await (await fetch(`${base}/symbols?kind=method&limit=2&cursor=${first.next_cursor}`)).json()
+    assert.deepEqual(second.items.map((s: any) => s.name), ['User.remove'])
+    assert.equal(second.next_cursor, null)
+
+    const fuzzy = await (await fetch(`${base}/symbols?q=usrld`)).json()
+    assert.equal(fuzzy.items[0].name, 'User.load')
+  })
+})
+
+test('http-server: definitions, references, file symbols and positions', async () => {
+  await withServer(async (base) => {
+    const id = encodeURIComponent(makeSymbolId('src/user.ts', 'User.save'))
+    const def = await (await fetch(`${base}/defs/${id}`)).json()
+    assert.deepEqual([def.name, def.kind, def.line], ['User.save', 'method', 2])
+
+    const refs = await (await fetch(`${base}/refs/${id}?limit=2`)).json()
+    assert.deepEqual(refs.items.map((l: any) => l.line), [3, 4])
+    const rest = await (await fetch(`${base}/refs/${id}?limit=2&cursor=${refs.next_cursor}`)).json()
+    assert.deepEqual(rest.items.map((l: any) => l.line), [5])
+
+    const file = await (await fetch(`${base}/files/src/main.ts`)).json()
+    assert.deepEqual(file.items.map((s: any) => s.name), ['main'])
+
+    const at = await (await fetch(`${base}/at/src/main.ts?line=3&column=6`)).json()
+    assert.deepEqual([at.name, at.column, at.declaration], ['save', 5, false])
+    assert.deepEqual(at.symbols.map((s: any) => s.id), [makeSymbolId('src/user.ts', 'User.save')])
+  })
+})
+
+test('http-server: batches answer many requests in one round trip', async () => {
+  await withServer(async (base) => {
+    const id = encodeURIComponent(makeSymbolId('src/user.ts', 'User.save'))
+    const res = await fetch(`${base}/batch`, {
+      method: 'POST',
+      body: JSON.stringify({
+        requests: [
+          '/at/src/main.ts?line=3&column=6',
+          '/at/src/main.ts?line=4&column=5',
+          `/defs/${id}`,
+          '/at/src/main.ts?line=3&column=6',
+          '/at/src/missing.ts?line=1&column=1',
+          '/at/src/main.ts?line=1&column=40',
+          42
+        ]
+      })
+    })
+    assert.equal(res.status, 200)
+    const { responses } = await res.json()
+    assert.deepEqual(responses.map((r: any) => r.status), [200, 200, 200, 200, 404, 404, 400])
+    assert.deepEqual(responses[0].body.symbols.map((s: any) => s.id), [makeSymbolId('src/user.ts', 'User.save')])
+    assert.equal(responses[1].body.line, 4)
+    assert.equal(responses[2].body.name, 'User.save')
+    assert.deepEqual(responses[3], responses[0])
+
+    assert.equal((await fetch(`${base}/batch`)).status, 405)
+    assert.equal((await fetch(`${base}/batch`, { method: 'POST', body: 'nope' })).status, 400)
+    assert.equal((await fetch(`${base}/batch`, { method: 'POST', body: '{}' })).status, 400)
+  })
+})
+
+test('http-server: results carry the source lines around them with ?context=', async () => {
+  const sources: Record<string, string> = { 'src/user.ts': USER_SRC, 'src/main.ts': MAIN_SRC }
+  await withServer(async (base) => {
+    const id = encodeURIComponent(makeSymbolId('src/user.ts', 'User'))
+    const def = await (await fetch(`${base}/defs/${id}?context=1`)).json()
+    assert.deepEqual(def.context, { start_line: 1, lines: ['export class User {', '  save() {}'] })
+
+    const saveId = encodeURIComponent(makeSymbolId('src/user.ts', 'User.save'))
+    const refs = await (await fetch(`${base}/refs/${saveId}?context=0`)).json()
+    assert.deepEqual(refs.items.map((r: any) => r.context), [3, 4, 5].map(line => ({ start_line: line, lines: ['  u.save()'] })))
+
+    const { responses } = await (await fetch(`${base}/batch`, {
+      method: 'POST',
+      body: JSON.stringify({ requests: [`/defs/${id}?context=0`, `/defs/${id}`, `/defs/${id}?context=many`] })
+    })).json()
+    assert.deepEqual(responses[0].body.context, { start_line: 1, lines: ['export class User {'] })
+    assert.equal(responses[1].body.context, undefined)
+    assert.equal(responses[2].status, 400)
+  }, () => ({ snippets: new SnippetCache(async relPath => sources[relPath] ?? null) }))
+
+  await withServer(async (base) => {
+    const res = await fetch(`${base}/symbols?name=User&context=2`)
+    assert.equal(res.status, 400)
+    assert.match((await res.json()).error, /no sources/)
+  })
+})
+
+test('http-server: ETags, 304s and errors', async () => {
+  await withServer(async (base) => {
+    const res = await fetch(`${base}/symbols?name=User`)
+    const etag = res.headers.get('etag')!
+    assert.ok(etag)
+    const cached = await fetch(`${base}/symbols?name=User`, { headers: { 'If-None-Match': etag } })
+    assert.equal(cached.status, 304)
+
+    assert.equal((await fetch(`${base}/defs/missing`)).status, 404)
+    assert.equal((await fetch(`${base}/symbols?cursor=bogus`)).status, 400)
+    assert.equal((await fetch(`${base}/nowhere`)).status, 404)
+    assert.equal((await fetch(`${base}/at/src/main.ts?line=0&column=1`)).status, 400)
+    assert.equal((await fetch(`${base}/at/src/main.ts?line=1&column=40`)).status, 404)
+    assert.equal((await fetch(`${base}/symbols`, { method: 'POST' })).status, 405)
+  })
+})
+
+test('http-server: cursors from before a change and malformed paths are rejected', async () => {
+  const index = createIndex({
+    'src/user.ts': [
+      { name: 'User', kind: 'class', line: 1, end_line: 4, exported: true },
+      { name: 'User.save', kind: 'method', line: 2 },
+      { name: 'User.load', kind: 'method', line: 3 }
+    ]
+  })
+  const server = await serveHttp(index, 0, '127.0.0.1')
+  const base = `http://127.0.0.1:${(server.address() as AddressInfo).port}`
+  try {
+    const first = await (await fetch(`${base}/symbols?kind=method&limit=1`)).json()
+    assert.equal((await fetch(`${base}/symbols?kind=method&limit=1&cursor=${first.next_cursor}`)).status, 200)
+    const files = await (await fetch(`${base}/files/src/user.ts?limit=1`)).json()
+
+    // An edit moves every later result, so the offsets in old cursors no longer hold
+    index.addFile('src/admin.ts', 'typescript', [{ name: 'Admin.save', kind: 'method', line: 1 }] as any)
+    const stale = await fetch(`${base}/symbols?kind=method&limit=1&cursor=${first.next_cursor}`)
+    assert.equal(stale.status, 410)
+    assert.match((await stale.json()).error, /changed since this cursor/)
+    assert.equal((await fetch(`${base}/files/src/user.ts?limit=1&cursor=${files.next_cursor}`)).status, 410)
+
+    assert.equal((await fetch(`${base}/files/src/%E0%A4%A.ts`)).status, 400)
+    const batch = await (await fetch(`${base}/batch`, {
+      method: 'POST',
+      body: JSON.stringify({ requests: ['/at/src/%ZZ.ts?line=1&column=1', '/defs/%E0'] })
+    })).json()
+    assert.deepEqual(batch.responses.map((r: any) => r.status), [400, 400])
+  } finally {
+    server.close()
+  }
+})
+
+test('http-server: serves metrics and times queries', async () => {
+  await withServer(async (base) => {
+    await fetch(`${base}/symbols?name=User`)
+    await fetch(`${base}/defs/missing`)
+    const res = await fetch(`${base}/metrics`)
+    assert.match(res.headers.get('content-type')!, /^text\/plain; version=0\.0\.4/)
+    const text = await res.text()
+    assert.match(text, /^indexer_index_files 2$/m)
+    assert.match(text, /^indexer_index_symbols [1-9][0-9]*$/m)
+    assert.match(text, /^indexer_query_duration_seconds_count\{api="http",route="symbols"\} 1$/m)
+    assert.match(text, /^indexer_queries_total\{api="http",route="defs",status="404"\} 1$/m)
+  }, index => ({ metrics: new ServerMetrics(index) }))
+})
+
+test('http-server: profiling endpoints only with pprof', async () => {
+  await withServer(async (base) => {
+    assert.equal((await fetch(`${base}/debug/pprof/`)).status, 404)
+    assert.equal((await fetch(`${base}/metrics`)).status, 404)
+  })
+  await withServer(async (base) => {
+    assert.match(await (await fetch(`${base}/debug/pprof/`)).text(), /profile\?seconds=/)
+    const profile = await (await fetch(`${base}/debug/pprof/profile?seconds=0.05`)).json()
+    assert.ok(Array.isArray(profile.nodes) && profile.nodes.length > 0)
+    assert.equal((await fetch(`${base}/debug/pprof/profile?seconds=-1`)).status, 400)
+    assert.equal((await fetch(`${base}/debug/pprof/goroutine`)).status, 404)
+  }, () => ({ pprof: true }))
+})
+
+test('http-server: several workspaces, each query naming one', async () => {
+  const workspaces = new WorkspaceSet()
+  const api = new SymbolIndex()
+  api.addFile('src/api.py', 'python', [{ name: 'handle', kind: 'function', line: 1, end_line: 2 }])
+  const web = new SymbolIndex()
+  web.addFile('src/web.py', 'python', [
+    { name: 'render', kind: 'function', line: 1, end_line: 2 },
+    { name: 'handle', kind: 'function', line: 4, end_line: 5 }
+  ])
+  workspaces.add({ id: 'api', root: '/srv/api', index: api })
+  workspaces.add({ id: 'web', root: '/srv/web', index: web })
+  const metrics = new ServerMetrics(workspaces)
+  const server = await serveHttp(workspaces, 0, '127.0.0.1', { metrics })
+  const base = `http://127.0.0.1:${(server.address() as AddressInfo).port}`
+  try {
+    const listed = await (await fetch(`${base}/workspaces`)).json()
+    assert.deepEqual(listed.workspaces.map((w: any) => [w.id, w.root, w.files]), [['api', '/srv/api', 1], ['web', '/srv/web', 1]])
+
+    const found = await (await fetch(`${base}/symbols?name=handle&workspace=web`)).json()
+    assert.deepEqual(found.items.map((s: any) => s.path), ['src/web.py'])
+    const batch = await (await fetch(`${base}/batch?workspace=api`, {
+      method: 'POST',
+      body: JSON.stringify({ requests: ['/files/src/api.py', '/files/src/web.py'] })
+    })).json()
+    assert.deepEqual(batch.responses.map((r: any) => r.status), [200, 404])
+
+    assert.equal((await fetch(`${base}/symbols?name=handle`)).status, 400)
+    assert.equal((await fetch(`${base}/symbols?workspace=billing`)).status, 404)
+    assert.match(metrics.render(), /^indexer_index_symbols\{workspace="web"\} 2$/m)
+  } finally {
+    server.close()
+  }
+
+  assert.deepEqual(parseWorkspaceSpecs(['api=../api', '/srv/web']), [{ id: 'api', path: '../api' }, { id: 'web', path: '/srv/web' }])
+  assert.throws(() => parseWorkspaceSpecs(['web=a', 'web=b']), /given twice/)
+})
+
+test('workspaces: service directories of one project are rejected', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'workspaces-test-'))
+  try {
+    await fs.mkdir(path.join(root, '.indexer'))
+    await fs.mkdir(path.join(root, 'services/api'), { recursive: true })
+    await fs.mkdir(path.join(root, 'services/web'), { recursive: true })
+    await fs.mkdir(path.join(root, 'tools/.indexer'), { recursive: true })
+    const resolve = async (specs: string[]) => Promise.all(parseWorkspaceSpecs(specs).map(async s => ({
+      id: s.id,
+      root: await findProjectRoot(path.join(root, s.path))
+    })))
+
+    // Both walk up to the monorepo's .indexer
+    const shared = await resolve(['api=services/api', 'web=services/web'])
+    assert.throws(() => checkWorkspaceRoots(shared), /"api" and "web" are both the project at/)
+    checkWorkspaceRoots(await resolve(['api=services/api', 'tools=tools']))
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
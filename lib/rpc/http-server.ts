@@ -0,0 +1,554 @@
+/**
+ * HTTP/JSON Query API
+ * Read-only REST endpoints over the symbol index for scripts and web UIs:
+ *   GET /symbols?q=&name=&kind=&package=&lang=&exported=   symbol search
+ *   GET /defs/{id}                                         one symbol
+ *   GET /refs/{id}?role=&scope=&via=                       references to a symbol (role=write,call; scope=module|workspace|all; via=direct|alias|all)
+ *   GET /files/{path}                                      symbols in a file
+ *   GET /at/{path}?line=&column=                           symbol under a cursor
+ *   GET /highlights/{path}?line=&column=                   occurrences in the file of the symbol under a cursor
+ *   GET /tokens/{path}                                     semantic tokens of a file (see occurrences)
+ *   GET /diagnostics?package=&lang=&analyzer=&rule=        syntax errors and analyzer findings
+ *   GET /stats?package=                                    per-package metrics (see package-stats)
+ *   POST /batch {"requests": ["/at/...", "/defs/..."]}     many of the above in one round trip
+ *   GET /workspaces                                        the workspaces served, with their roots and sizes
+ * List endpoints page with ?limit= and an opaque ?cursor= taken from the
+ * previous page's next_cursor; a cursor from before the index last changed
+ * gets 410 Gone, as its offset may skip or repeat results. Every response
+ * carries an ETag; a matching
+ * If-None-Match gets 304 Not Modified. A batch answers each request with its
+ * status and body, in order; identical requests are answered once and the
+ * /at positions of a file are resolved in one pass over it. References are
+ * those in the workspace unless scope= says otherwise, and of the name itself
+ * unless via= asks for uses through type aliases too. With ?context=N the
+ * results of symbols, defs, refs, files and at carry the N lines on either
+ * side of them ({start_line, lines}), read from the sources the server was
+ * given.
+ * Serving several workspaces, every query names one with ?workspace=<id>
+ * (a batch queries the workspace of its own URL) and GET /workspaces lists
+ * them; a single workspace also answers queries without it.
+ * With metrics, queries are timed and GET /metrics serves them to
+ * Prometheus; with pprof, /debug/pprof serves CPU and heap profiles.
+ */
+
+import http from 'http'
+import crypto from 'crypto'
+import { querySymbols, type SymbolQuery } from '../core/symbol-query.js'
+import { findParseDiagnostics } from '../core/parse-diagnostics.js'
+import { parseRoles } from '../core/reference-roles.js'
+import { DEFAULT_SCOPES, parseScope, type SearchScope } from '../core/search-scope.js'
+import { parseAliasUsage, type AliasUsage } from '../core/type-aliases.js'
+import { METRICS_CONTENT_TYPE, type ServerMetrics } from './metrics.js'
+import { PPROF_PREFIX, handlePprofRequest } from './debug-pprof.js'
+import { parseContextLines, type SnippetCache } from '../core/snippets.js'
+import { computePackageStats } from '../core/package-stats.js'
+import { fileHighlights, semanticTokens } from '../core/occurrences.js'
+import { matchesPackageDir } from '../core/symbol-query.js'
+import { WorkspaceError, WorkspaceSet } from './workspaces.js'
+import type { SymbolAt, SymbolIndex } from '../core/symbol-index.js'
+import type { IndexedSymbol, ReferenceRole } from '../types/index.js'
+
+const DEFAULT_PAGE_SIZE = 50
+const MAX_PAGE_SIZE = 500
+const ROUTES = new Set(['symbols', 'defs', 'refs', 'files', 'at', 'highlights', 'tokens', 'diagnostics', 'stats', 'batch', 'workspaces'])
+const MAX_BATCH_SIZE = 1000
+const MAX_BATCH_BYTES = 16 * 1024 * 1024
+
+export interface HttpServerOptions {
+  metrics?: ServerMetrics // time queries and serve GET /metrics
+  pprof?: boolean // serve /debug/pprof
+  snippets?: SnippetCache // sources for ?context=
+}
+
+export class HttpError extends Error {
+  constructor(readonly status: number, message: string) {
+    super(message)
+  }
+}
+
+export interface Page<T> {
+  items: T[]
+  next_cursor: string | null
+}
+
+export interface BatchResponse {
+  status: number
+  body: unknown
+}
+
+// Positions resolved ahead of a batch: file, then "line:column"
+type ResolvedPositions = Map<string, Map<string, SymbolAt | null>>
+
+// Cursors carry the index generation (see SymbolIndex.generation) their page was read at
+export function encodeCursor(offset: number, generation: number): string {
+  return Buffer.from(JSON.stringify({ offset, generation }), 'utf8').toString('base64url')
+}
+
+/**
+ * Offset a cursor continues from
+ * @throws HttpError 400 if the cursor is malformed, 410 if the index has changed since it was issued
+ */
+export function decodeCursor(cursor: string | null, generation: number): number {
+  if (!cursor) return 0
+  let parsed: any = null
+  try {
+    parsed = JSON.parse(Buffer.from(cursor, 'base64url').toString('utf8'))
+  } catch {
+    // fall through
+  }
+  if (!Number.isInteger(parsed?.offset) || parsed.offset < 0 || !Number.isInteger(parsed.generation)) {
+    throw new HttpError(400, 'Invalid cursor')
+  }
+  if (parsed.generation !== generation) throw new HttpError(410, 'The index has changed since this cursor was issued; start again from the first page')
+  return parsed.offset
+}
+
+// A path parameter, which must be valid percent-encoding
+function decodeParam(segments: string[]): string {
+  try {
+    return decodeURIComponent(segments.join('/'))
+  } catch {
+    throw new HttpError(400, `Malformed percent-encoding in ${segments.join('/')}`)
+  }
+}
+
+function pageSize(params: URLSearchParams): number {
+  const raw = params.get('limit')
+  if (raw === null) return DEFAULT_PAGE_SIZE
+  const limit = Number(raw)
+  if (!Number.isInteger(limit) || limit < 1) throw new HttpError(400, 'limit must be a positive integer')
+  return Math.min(limit, MAX_PAGE_SIZE)
+}
+
+/**
+ * One page of a result list; a lazy one is read only up to the end of the
+ * page (and one past it)
+ */
+function paginate<T>(index: SymbolIndex, items: Iterable<T>, params: URLSearchParams): Page<T> {
+  const offset = decodeCursor(params.get('cursor'), index.generation)
+  const limit = pageSize(params)
+  const page: T[] = []
+  let seen = 0
+  let more = false
+  for (const item of items) {
+    if (seen++ < offset) continue
+    if (page.length === limit) {
+      more = true
+      break
+    }
+    page.push(item)
+  }
+  return { items: page, next_cursor: more ? encodeCursor(offset + limit, index.generation) : null }
+}
+
+export function symbolRecord(sym: IndexedSymbol) {
+  return {
+    id: sym.id,
+    name: sym.name,
+    kind: sym.kind,
+    path: sym.path,
+    line: sym.line,
+    end_line: sym.end_line,
+    lang: sym.lang,
+    exported: !!sym.exported,
+    ...(sym.module ? { module: sym.module, module_version: sym.module_version } : {}),
+    ...(sym.signature ? { signature: sym.signature } : {}),
+    ...(sym.doc ? { doc: sym.doc } : {})
+  }
+}
+
+function searchSymbols(index: SymbolIndex, params: URLSearchParams): Page<ReturnType<typeof symbolRecord>> {
+  const list = (key: string) => params.getAll(key).flatMap(v => v.split(',')).filter(Boolean)
+  const query: SymbolQuery = {
+    fuzzy: params.get('q') || undefined,
+    name: params.get('name') || undefined,
+    kinds: list('kind'),
+    packages: list('package'),
+    lang: params.get('lang') || undefined
+  }
+  if (params.has('exported')) query.exported = params.get('exported') !== 'false'
+
+  // Fetch one extra row to learn whether another page exists
+  const offset = decodeCursor(params.get('cursor'), index.generation)
+  const limit = pageSize(params)
+  const rows = querySymbols(index, { ...query, offset, limit: limit + 1 })
+  return {
+    items: rows.slice(0, limit).map(symbolRecord),
+    next_cursor: rows.length > limit ? encodeCursor(offset + limit, index.generation) : null
+  }
+}
+
+function positionParam(params: URLSearchParams, key: string): number {
+  const value = Number(params.get(key))
+  if (!Number.isInteger(value) || value < 1) throw new HttpError(400, `${key} must be a positive integer`)
+  return value
+}
+
+function symbolAt(index: SymbolIndex, param: string, params: URLSearchParams, resolved?: ResolvedPositions) {
+  const filePath = requireFile(index, param)
+  const line = positionParam(params, 'line')
+  const column = positionParam(params, 'column')
+  const ofFile = resolved?.get(filePath)
+  const key = `${line}:${column}`
+  const at = ofFile?.has(key) ? ofFile.get(key)! : index.symbolAt(filePath, line, column)
+  if (!at) throw new HttpError(404, 'No symbol at this position')
+  return {
+    name: at.name,
+    line: at.line,
+    column: at.column,
+    end_column: at.end_column,
+    declaration: at.declaration,
+    symbols: at.symbols.map(symbolRecord)
+  }
+}
+
+// Ids and paths resolve like the index stores them, whatever the separators and, on a case-insensitive file system, the case
+function requireSymbol(index: SymbolIndex, id: string): IndexedSymbol {
+  const sym = index.getSymbol(index.resolveSymbolId(id))
+  if (!sym) throw new HttpError(404, `Unknown symbol ${id}`)
+  return sym
+}
+
+function requireFile(index: SymbolIndex, param: string): string {
+  const filePath = index.resolvePath(param)
+  if (filePath === null) throw new HttpError(404, `File not indexed: ${param}`)
+  return filePath
+}
+
+// Repeated and comma-separated values of a parameter (?package=a,b&package=c)
+function listParam(params: URLSearchParams, name: string): string[] {
+  return params.getAll(name).flatMap(v => v.split(',')).filter(Boolean)
+}
+
+function referenceRoles(value: string): ReferenceRole[] {
+  try {
+    return parseRoles(value)
+  } catch (e) {
+    throw new HttpError(400, (e as Error).message)
+  }
+}
+
+function searchScope(value: string): SearchScope {
+  try {
+    return parseScope(value)
+  } catch (e) {
+    throw new HttpError(400, (e as Error).message)
+  }
+}
+
+function aliasUsage(value: string): AliasUsage {
+  try {
+    return parseAliasUsage(value)
+  } catch (e) {
+    throw new HttpError(400, (e as Error).message)
+  }
+}
+
+/**
+ * Route a request to a JSON body
+ */
+export function routeRequest(index: SymbolIndex, method: string, url: URL, resolved?: ResolvedPositions): unknown {
+  if (method !== 'GET' && method !== 'HEAD') throw new HttpError(405, 'Only GET is supported')
+
+  const [, resource, ...rest] = url.pathname.split('/')
+  const param = decodeParam(rest)
+  switch (resource) {
+    case 'symbols':
+      if (param) break
+      return searchSymbols(index, url.searchParams)
+    case 'defs':
+      return symbolRecord(requireSymbol(index, param))
+    case 'refs': {
+      const sym = requireSymbol(index, param)
+      const role = url.searchParams.get('role')
+      const scope = url.searchParams.get('scope')
+      const via = url.searchParams.get('via')
+      return paginate(
+        index,
+        index.iterReferences(
+          sym.id,
+          role ? referenceRoles(role) : undefined,
+          scope ? searchScope(scope) : DEFAULT_SCOPES.references,
+          via ? aliasUsage(via) : 'direct'
+        ),
+        url.searchParams
+      )
+    }
+    case 'files':
+      return paginate(index, index.fileSymbols(requireFile(index, param)).map(symbolRecord), url.searchParams)
+    case 'at':
+      return symbolAt(index, param, url.searchParams, resolved)
+    case 'highlights': {
+      const filePath = requireFile(index, param)
+      const at = index.symbolAt(filePath, positionParam(url.searchParams, 'line'), positionParam(url.searchParams, 'column'))
+      if (!at) throw new HttpError(404, 'No symbol at this position')
+      return paginate(index, fileHighlights(index, filePath, at.symbols), url.searchParams)
+    }
+    case 'tokens':
+      return paginate(index, semanticTokens(index, requireFile(index, param)), url.searchParams)
+    case 'diagnostics': {
+      if (param) break
+      return paginate(index, findParseDiagnostics(index, {
+        packages: listParam(url.searchParams, 'package'),
+        lang: url.searchParams.get('lang') || undefined,
+        analyzers: listParam(url.searchParams, 'analyzer'),
+        rules: listParam(url.searchParams, 'rule')
+      }), url.searchParams)
+    }
+    case 'stats': {
+      if (param) break
+      // Computed once per index version; every package is needed for fan-in and fan-out anyway
+      const packages = listParam(url.searchParams, 'package')
+      const stats = index.memo('package-stats', () => computePackageStats(index))
+      return paginate(index, packages.length ? stats.filter(s => packages.some(p => matchesPackageDir(s.package, p))) : stats, url.searchParams)
+    }
+  }
+  throw new HttpError(404, `No route for ${url.pathname}`)
+}
+
+/**
+ * Add the lines around each result of a routed body, as ?context= asks
+ */
+export async function withContext(index: SymbolIndex, url: URL, body: any, snippets?: SnippetCache): Promise<unknown> {
+  const value = url.searchParams.get('context')
+  if (value === null) return body
+  let lines: number
+  try {
+    lines = parseContextLines(value)
+  } catch (e) {
+    throw new HttpError(400, (e as Error).message)
+  }
+  if (!snippets) throw new HttpError(400, 'This server has no sources to show context from')
+  const decorate = async (results: any[]) => {
+    const found = await snippets.snippets(index, results, lines)
+    return results.map((r, i) => (found[i] ? { ...r, context: found[i] } : r))
+  }
+  if (Array.isArray(body?.items)) return { ...body, items: await decorate(body.items) }
+  if (Array.isArray(body?.symbols)) return { ...body, symbols: await decorate(body.symbols) }
+  if (typeof body?.path === 'string' && Number.isInteger(body?.line)) return (await decorate([body]))[0]
+  return body
+}
+
+/**
+ * Answer a batch of GET requests (paths with their query strings, such as
+ * "/at/src/user.ts?line=3&column=5"), each with its own status and body
+ */
+export function routeBatch(index: SymbolIndex, requests: unknown[]): BatchResponse[] {
+  if (requests.length > MAX_BATCH_SIZE) throw new HttpError(400, `A batch holds at most ${MAX_BATCH_SIZE} requests`)
+  const urls = requests.map(r => (typeof r === 'string' && r.startsWith('/') ? new URL(r, 'http://localhost') : null))
+
+  // Every position asked of a file, resolved in one pass over its shard
+  const positions = new Map<string, { line: number, column: number }[]>()
+  for (const url of urls) {
+    if (!url) continue
+    const [, resource, ...rest] = url.pathname.split('/')
+    if (resource !== 'at') continue
+    const line = Number(url.searchParams.get('line'))
+    const column = Number(url.searchParams.get('column'))
+    if (!Number.isInteger(line) || !Number.isInteger(column)) continue
+    let filePath: string | null
+    try {
+      filePath = index.resolvePath(decodeParam(rest))
+    } catch {
+      // Answered with its error below
+      continue
+    }
+    if (filePath === null) continue
+    const ofFile = positions.get(filePath)
+    if (ofFile) ofFile.push({ line, column })
+    else positions.set(filePath, [{ line, column }])
+  }
+  const resolved: ResolvedPositions = new Map()
+  for (const [filePath, wanted] of positions) {
+    const found = index.symbolsAt(filePath, wanted)
+    resolved.set(filePath, new Map(wanted.map((p, i) => [`${p.line}:${p.column}`, found[i]])))
+  }
+
+  const answered = new Map<string, BatchResponse>()
+  return urls.map(url => {
+    if (!url) return { status: 400, body: { error: 'A batch request is a path such as /defs/{id}' } }
+    const key = url.pathname + url.search
+    let response = answered.get(key)
+    if (!response) {
+      try {
+        response = { status: 200, body: routeRequest(index, 'GET', url, resolved) }
+      } catch (e: any) {
+        response = { status: e instanceof HttpError ? e.status : 500, body: { error: e.message } }
+      }
+      answered.set(key, response)
+    }
+    return response
+  })
+}
+
+async function batchRequest(index: SymbolIndex, req: http.IncomingMessage, snippets?: SnippetCache): Promise<{ responses: BatchResponse[] }> {
+  if (req.method !== 'POST') throw new HttpError(405, 'POST a JSON body to /batch')
+  const chunks: Buffer[] = []
+  let size = 0
+  for await (const chunk of req) {
+    size += chunk.length
+    if (size > MAX_BATCH_BYTES) throw new HttpError(413, 'Batch body too large')
+    chunks.push(chunk as Buffer)
+  }
+  let body: any
+  try {
+    body = JSON.parse(Buffer.concat(chunks).toString('utf8'))
+  } catch {
+    throw new HttpError(400, 'Batch body is not JSON')
+  }
+  if (!Array.isArray(body?.requests)) throw new HttpError(400, 'Batch body needs a requests array')
+  const responses = routeBatch(index, body.requests)
+  return {
+    responses: await Promise.all(responses.map(async (response, i) => {
+      if (response.status !== 200) return response
+      try {
+        return { status: 200, body: await withContext(index, new URL(body.requests[i], 'http://localhost'), response.body, snippets) }
+      } catch (e: any) {
+        return { status: e instanceof HttpError ? e.status : 500, body: { error: e.message } }
+      }
+    }))
+  }
+}
+
+/**
+ * Answer /metrics and /debug/pprof when they are enabled
+ * @returns false if the request is for neither
+ */
+export function handleOpsRequest(
+  req: http.IncomingMessage,
+  res: http.ServerResponse,
+  url: URL,
+  options: HttpServerOptions
+): boolean {
+  if (url.pathname === '/metrics' && options.metrics) {
+    const text = options.metrics.render()
+    res.writeHead(200, { 'Content-Type': METRICS_CONTENT_TYPE, 'Content-Length': Buffer.byteLength(text) })
+    res.end(req.method === 'HEAD' ? undefined : text)
+    return true
+  }
+  if ((url.pathname === PPROF_PREFIX || url.pathname.startsWith(`${PPROF_PREFIX}/`)) && options.pprof) {
+    handlePprofRequest(url, res).catch((e: any) => {
+      if (res.headersSent) res.destroy(e)
+      else res.writeHead(500, { 'Content-Type': 'text/plain; charset=utf-8' }).end(`${e.message}\n`)
+    })
+    return true
+  }
+  return false
+}
+
+// Index and sources a request is for
+function requestWorkspace(source: SymbolIndex | WorkspaceSet, url: URL, options: HttpServerOptions): { index: SymbolIndex, snippets?: SnippetCache } {
+  if (!(source instanceof WorkspaceSet)) return { index: source, snippets: options.snippets }
+  try {
+    const workspace = source.resolve(url.searchParams.get('workspace'))
+    return { index: workspace.index, snippets: workspace.snippets }
+  } catch (e) {
+    if (e instanceof WorkspaceError) throw new HttpError(e.unknown ? 404 : 400, e.message)
+    throw e
+  }
+}
+
+function listWorkspaces(workspaces: WorkspaceSet) {
+  return {
+    workspaces: workspaces.list().map(w => ({
+      id: w.id,
+      root: w.root,
+      files: w.index.listFiles().length,
+      generation: w.index.generation
+    }))
+  }
+}
+
+/**
+ * Handle one HTTP request, against an index or the workspace it names
+ */
+export function handleHttpRequest(
+  source: SymbolIndex | WorkspaceSet,
+  req: http.IncomingMessage,
+  res: http.ServerResponse,
+  options: HttpServerOptions = {}
+): void {
+  const url = new URL(req.url || '/', 'http://localhost')
+  if (handleOpsRequest(req, res, url, options)) return
+
+  const start = process.hrtime.bigint()
+  const fail = (e: any) => sendJson(req, res, url, e instanceof HttpError ? e.status : 500, { error: e.message }, start, options)
+  if (url.pathname === '/workspaces' && source instanceof WorkspaceSet) {
+    sendJson(req, res, url, 200, listWorkspaces(source), start, options)
+    return
+  }
+  let workspace: ReturnType<typeof requestWorkspace>
+  try {
+    workspace = requestWorkspace(source, url, options)
+  } catch (e: any) {
+    fail(e)
+    return
+  }
+  const { index, snippets } = workspace
+  if (url.pathname === '/batch') {
+    batchRequest(index, req, snippets).then(body => sendJson(req, res, url, 200, body, start, options), fail)
+    return
+  }
+  Promise.resolve()
+    .then(() => withContext(index, url, routeRequest(index, req.method || 'GET', url), snippets))
+    .then(body => sendJson(req, res, url, 200, body, start, options), fail)
+}
+
+function sendJson(
+  req: http.IncomingMessage,
+  res: http.ServerResponse,
+  url: URL,
+  status: number,
+  body: unknown,
+  start: bigint,
+  options: HttpServerOptions
+): void {
+  if (options.metrics) {
+    const resource = url.pathname.split('/')[1]
+    const route = ROUTES.has(resource) ? resource : 'other'
+    options.metrics.observeQuery('http', route, status, Number(process.hrtime.bigint() - start) / 1e9)
+  }
+
+  const json = JSON.stringify(body)
+  const etag = `"${crypto.createHash('sha1').update(json).digest('base64url')}"`
+  res.setHeader('Content-Type', 'application/json; charset=utf-8')
+  if (status === 200 && req.method !== 'POST') {
+    res.setHeader('ETag', etag)
+    res.setHeader('Cache-Control', 'no-cache')
+    if (req.headers['if-none-match']?.split(/\s*,\s*/).includes(etag)) {
+      res.writeHead(304).end()
+      return
+    }
+  }
+  res.writeHead(status, { 'Content-Length': Buffer.byteLength(json) })
+  res.end(req.method === 'HEAD' ? undefined : json)
+}
+
+function listen(server: http.Server, port: number, host: string): Promise<http.Server> {
+  return new Promise((resolve, reject) => {
+    server.once('error', reject)
+    server.listen(port, host, () => resolve(server))
+  })
+}
+
+/**
+ * Serve the HTTP API on a port, for one index or a set of workspaces
+ */
+export function serveHttp(
+  source: SymbolIndex | WorkspaceSet,
+  port: number,
+  host = '127.0.0.1',
+  options: HttpServerOptions = {}
+): Promise<http.Server> {
+  return listen(http.createServer((req, res) => handleHttpRequest(source, req, res, options)), port, host)
+}
+
+/**
+ * Serve only /metrics and /debug/pprof on a port, apart from the query APIs
+ */
+export function serveMetrics(port: number, host = '127.0.0.1', options: HttpServerOptions = {}): Promise<http.Server> {
+  return listen(http.createServer((req, res) => {
+    if (handleOpsRequest(req, res, new URL(req.url || '/', 'http://localhost'), options)) return
+    res.writeHead(404, { 'Content-Type': 'text/plain; charset=utf-8' }).end('Not found\n')
+  }), port, host)
+}
@@ -0,0 +1,54 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { EventEmitter } from 'events'
+import { SymbolIndex } from '../core/symbol-index.js'
+import { Counter, Histogram, MetricsRegistry, ServerMetrics } from './metrics.js'
+import type { SymbolIndexWatcher } from '../services/symbol-index-watcher.js'
+
+test('metrics: renders counters and cumulative histogram buckets', () => {
+  const registry = new MetricsRegistry()
+  const counter = registry.register(new Counter('requests_total', 'Requests'))
+  const histogram = registry.register(new Histogram('latency_seconds', 'Latency', [0.1, 1]))
+  counter.inc({ path: 'a"b' })
+  counter.inc({ path: 'a"b' }, 2)
+  histogram.observe({}, 0.05)
+  histogram.observe({}, 0.5)
+  histogram.observe({}, 5)
+
+  assert.equal(registry.render(), [
+    '# HELP requests_total Requests',
+    '# TYPE requests_total counter',
+    'requests_total{path="a\\"b"} 3',
+    '# HELP latency_seconds Latency',
+    '# TYPE latency_seconds histogram',
+    'latency_seconds_bucket{le="0.1"} 1',
+    'latency_seconds_bucket{le="1"} 2',
+    'latency_seconds_bucket{le="+Inf"} 3',
+    'latency_seconds_sum 5.55',
+    'latency_seconds_count 3',
+    ''
+  ].join('\n'))
+  assert.throws(() => registry.register(new Counter('requests_total', 'Again')), /already registered/)
+})
+
+test('metrics: records watcher updates, errors and events', () => {
+  const metrics = new ServerMetrics(new SymbolIndex())
+  const watcher = new EventEmitter()
+  metrics.watch(watcher as SymbolIndexWatcher)
+  watcher.emit('change', 'change', 'src/a.ts')
+  watcher.emit('change', 'add', 'src/b.ts')
+  watcher.emit('update', {
+    projectRoot: '/p',
+    update: { added: ['src/b.ts'], modified: ['src/a.ts'], removed: [], unchanged: [] },
+    durationMs: 120
+  })
+  watcher.emit('error', new Error('boom'))
+
+  assert.equal(metrics.watcherEvents.get({ event: 'change' }), 1)
+  assert.equal(metrics.reindexDuration.count(), 1)
+  assert.equal(metrics.reindexedFiles.get({ change: 'modified' }), 1)
+  assert.equal(metrics.reindexErrors.get(), 1)
+  const text = metrics.render()
+  assert.match(text, /^indexer_reindex_duration_seconds_bucket\{le="0\.25"\} 1$/m)
+  assert.match(text, /^indexer_index_files 0$/m)
+})
@@ -0,0 +1,243 @@
+/**
+ * Server Metrics Module
+ * Prometheus counters, gauges and histograms for `indexer serve`, rendered
+ * in the text exposition format at GET /metrics: index size, query latency
+ * per API and route, reindex durations and file watcher events, plus
+ * process memory and uptime. Serving several workspaces, the index size
+ * and reindex series carry a workspace label.
+ */
+
+import { WorkspaceSet } from './workspaces.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { IndexUpdateEvent, SymbolIndexWatcher } from '../services/symbol-index-watcher.js'
+
+export type Labels = Record<string, string>
+
+// Seconds; queries are expected to take well under a second
+export const QUERY_BUCKETS = [0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5]
+export const REINDEX_BUCKETS = [0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60]
+
+function labelKey(labels: Labels): string {
+  return Object.keys(labels).sort().map(k => `${k}=${labels[k]}`).join(',')
+}
+
+function escapeLabel(value: string): string {
+  return value.replace(/\\/g, '\\\\').replace(/\n/g, '\\n').replace(/"/g, '\\"')
+}
+
+function formatLabels(labels: Labels, extra: Labels = {}): string {
+  const all = { ...labels, ...extra }
+  const keys = Object.keys(all)
+  if (keys.length === 0) return ''
+  return `{${keys.map(k => `${k}="${escapeLabel(all[k])}"`).join(',')}}`
+}
+
+function formatValue(value: number): string {
+  if (value === Infinity) return '+Inf'
+  if (value === -Infinity) return '-Inf'
+  return Number.isNaN(value) ? 'NaN' : String(value)
+}
+
+interface Metric {
+  readonly name: string
+  render(): string[]
+}
+
+abstract class LabeledMetric<T> implements Metric {
+  protected readonly series = new Map<string, { labels: Labels, value: T }>()
+
+  constructor(readonly name: string, readonly help: string, private readonly type: string) {}
+
+  protected entry(labels: Labels, init: () => T): { labels: Labels, value: T } {
+    const key = labelKey(labels)
+    let entry = this.series.get(key)
+    if (!entry) {
+      entry = { labels: { ...labels }, value: init() }
+      this.series.set(key, entry)
+    }
+    return entry
+  }
+
+  protected abstract samples(): string[]
+
+  render(): string[] {
+    return [`# HELP ${this.name} ${this.help}`, `# TYPE ${this.name} ${this.type}`, ...this.samples()]
+  }
+}
+
+export class Counter extends LabeledMetric<number> {
+  constructor(name: string, help: string) {
+    super(name, help, 'counter')
+  }
+
+  inc(labels: Labels = {}, amount = 1): void {
+    this.entry(labels, () => 0).value += amount
+  }
+
+  get(labels: Labels = {}): number {
+    return this.series.get(labelKey(labels))?.value ?? 0
+  }
+
+  protected samples(): string[] {
+    return Array.from(this.series.values(), s => `${this.name}${formatLabels(s.labels)} ${formatValue(s.value)}`)
+  }
+}
+
+/**
+ * Gauge read at scrape time from a callback returning one value per label set
+ */
+export class Gauge implements Metric {
+  constructor(
+    readonly name: string,
+    readonly help: string,
+    private readonly collect: () => number | Array<{ labels: Labels, value: number }>
+  ) {}
+
+  render(): string[] {
+    const values = this.collect()
+    const series = typeof values === 'number' ? [{ labels: {}, value: values }] : values
+    return [
+      `# HELP ${this.name} ${this.help}`,
+      `# TYPE ${this.name} gauge`,
+      ...series.map(s => `${this.name}${formatLabels(s.labels)} ${formatValue(s.value)}`)
+    ]
+  }
+}
+
+interface HistogramSeries {
+  counts: number[] // per bucket, not cumulative
+  sum: number
+  count: number
+}
+
+export class Histogram extends LabeledMetric<HistogramSeries> {
+  constructor(name: string, help: string, readonly buckets: number[]) {
+    super(name, help, 'histogram')
+  }
+
+  observe(labels: Labels, value: number): void {
+    const series = this.entry(labels, () => ({ counts: this.buckets.map(() => 0), sum: 0, count: 0 })).value
+    const bucket = this.buckets.findIndex(b => value <= b)
+    if (bucket !== -1) series.counts[bucket]++
+    series.sum += value
+    series.count++
+  }
+
+  /** Run a function and observe how long it took, in seconds */
+  async time<T>(labels: Labels, fn: () => T | Promise<T>): Promise<T> {
+    const start = process.hrtime.bigint()
+    try {
+      return await fn()
+    } finally {
+      this.observe(labels, Number(process.hrtime.bigint() - start) / 1e9)
+    }
+  }
+
+  count(labels: Labels = {}): number {
+    return this.series.get(labelKey(labels))?.value.count ?? 0
+  }
+
+  protected samples(): string[] {
+    const lines: string[] = []
+    for (const { labels, value } of this.series.values()) {
+      let cumulative = 0
+      this.buckets.forEach((bound, i) => {
+        cumulative += value.counts[i]
+        lines.push(`${this.name}_bucket${formatLabels(labels, { le: formatValue(bound) })} ${cumulative}`)
+      })
+      lines.push(`${this.name}_bucket${formatLabels(labels, { le: '+Inf' })} ${value.count}`)
+      lines.push(`${this.name}_sum${formatLabels(labels)} ${formatValue(value.sum)}`)
+      lines.push(`${this.name}_count${formatLabels(labels)} ${value.count}`)
+    }
+    return lines
+  }
+}
+
+export class MetricsRegistry {
+  private readonly metrics: Metric[] = []
+
+  register<M extends Metric>(metric: M): M {
+    if (this.metrics.some(m => m.name === metric.name)) throw new Error(`Metric ${metric.name} is already registered`)
+    this.metrics.push(metric)
+    return metric
+  }
+
+  /** All metrics in the Prometheus text exposition format */
+  render(): string {
+    return this.metrics.flatMap(m => m.render()).join('\n') + '\n'
+  }
+}
+
+export const METRICS_CONTENT_TYPE = 'text/plain; version=0.0.4; charset=utf-8'
+
+/**
+ * The metrics a served index exports
+ */
+export class ServerMetrics {
+  readonly registry = new MetricsRegistry()
+  readonly queryDuration = this.registry.register(new Histogram(
+    'indexer_query_duration_seconds', 'Time to answer a query, by API and route', QUERY_BUCKETS))
+  readonly queries = this.registry.register(new Counter(
+    'indexer_queries_total', 'Queries answered, by API, route and status'))
+  readonly reindexDuration = this.registry.register(new Histogram(
+    'indexer_reindex_duration_seconds', 'Time to apply one batch of file changes to the index', REINDEX_BUCKETS))
+  readonly reindexedFiles = this.registry.register(new Counter(
+    'indexer_reindexed_files_total', 'Files added, modified or removed by reindexing, by change'))
+  readonly reindexErrors = this.registry.register(new Counter(
+    'indexer_reindex_errors_total', 'Reindex batches that failed'))
+  readonly watcherEvents = this.registry.register(new Counter(
+    'indexer_watcher_events_total', 'File system events seen by the watcher, by event'))
+  private readonly startedAt = Date.now()
+
+  constructor(source: SymbolIndex | WorkspaceSet) {
+    const indexes = () => source instanceof WorkspaceSet && source.size > 1
+      ? source.list().map(w => ({ labels: { workspace: w.id }, index: w.index }))
+      : [{ labels: {}, index: source instanceof WorkspaceSet ? source.list()[0].index : source }]
+    const size = (index: SymbolIndex) => index.memo('metrics:size', () => {
+      let symbols = 0
+      let references = 0
+      for (const shard of index.listShards()) {
+        symbols += shard.symbols.length
+        references += shard.references.length
+      }
+      return { files: index.listFiles().length, symbols, references }
+    })
+    const series = (value: (index: SymbolIndex) => number) => () => indexes().map(({ labels, index }) => ({ labels, value: value(index) }))
+    this.registry.register(new Gauge('indexer_index_files', 'Files in the symbol index', series(index => size(index).files)))
+    this.registry.register(new Gauge('indexer_index_symbols', 'Symbols in the symbol index', series(index => size(index).symbols)))
+    this.registry.register(new Gauge('indexer_index_references', 'References in the symbol index', series(index => size(index).references)))
+    this.registry.register(new Gauge('indexer_index_generation', 'Write batches applied to the index', series(index => index.generation)))
+    this.registry.register(new Gauge('process_resident_memory_bytes', 'Resident memory size in bytes', () => process.memoryUsage().rss))
+    this.registry.register(new Gauge('nodejs_heap_used_bytes', 'V8 heap in use in bytes', () => process.memoryUsage().heapUsed))
+    this.registry.register(new Gauge('process_uptime_seconds', 'Seconds since the server started', () => (Date.now() - this.startedAt) / 1000))
+  }
+
+  /**
+   * Time one query and count it by outcome
+   */
+  observeQuery(api: string, route: string, status: string | number, seconds: number): void {
+    this.queryDuration.observe({ api, route }, seconds)
+    this.queries.inc({ api, route, status: String(status) })
+  }
+
+  /**
+   * Record the reindex batches and file events of a watcher, labeled with
+   * its workspace when given one
+   */
+  watch(watcher: SymbolIndexWatcher, workspace?: string): void {
+    const labels: Labels = workspace ? { workspace } : {}
+    watcher.on('update', (event: IndexUpdateEvent) => {
+      this.reindexDuration.observe(labels, event.durationMs / 1000)
+      const { added, modified, removed } = event.update
+      if (added.length) this.reindexedFiles.inc({ ...labels, change: 'added' }, added.length)
+      if (modified.length) this.reindexedFiles.inc({ ...labels, change: 'modified' }, modified.length)
+      if (removed.length) this.reindexedFiles.inc({ ...labels, change: 'removed' }, removed.length)
+    })
+    watcher.on('error', () => this.reindexErrors.inc(labels))
+    watcher.on('change', (event: string) => this.watcherEvents.inc({ ...labels, event }))
+  }
+
+  render(): string {
+    return this.registry.render()
+  }
+}
@@ -0,0 +1,93 @@
+/**
+ * Workspaces Module
+ * The roots one `indexer serve` process answers for: several checkouts or
+ * services, each with its own index, sources and watcher, addressed by a
+ * workspace ID (?workspace= over HTTP, the workspace field over gRPC). A
+ * server with a single workspace also answers queries that name none.
+ */
+
+import path from 'path'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { SnippetCache } from '../core/snippets.js'
+
+const WORKSPACE_ID = /^[\w.-]+$/
+
+export interface Workspace {
+  id: string
+  root: string
+  index: SymbolIndex
+  snippets?: SnippetCache // sources for ?context=
+}
+
+export interface WorkspaceSpec {
+  id: string
+  path: string
+}
+
+export class WorkspaceError extends Error {
+  constructor(readonly unknown: boolean, message: string) {
+    super(message)
+  }
+}
+
+/**
+ * Parse --workspace values: id=path, or a bare path named after its directory
+ */
+export function parseWorkspaceSpecs(values: string[]): WorkspaceSpec[] {
+  const specs: WorkspaceSpec[] = []
+  for (const value of values) {
+    const eq = value.indexOf('=')
+    const dir = eq === -1 ? value : value.slice(eq + 1)
+    const id = eq === -1 ? path.basename(path.resolve(value)) : value.slice(0, eq)
+    if (!dir) throw new Error(`Workspace "${value}" has no path`)
+    if (!WORKSPACE_ID.test(id)) throw new Error(`Invalid workspace ID "${id}" (letters, digits, ".", "_" and "-")`)
+    if (specs.some(s => s.id === id)) throw new Error(`Workspace "${id}" is given twice`)
+    specs.push({ id, path: dir })
+  }
+  return specs
+}
+
+/**
+ * Fail when two workspaces resolve to one project root (service directories
+ * of a monorepo with one .indexer): they would hold the same index twice
+ * and, with --watch, race on its store
+ */
+export function checkWorkspaceRoots(workspaces: Array<{ id: string, root: string }>): void {
+  const seen = new Map<string, string>()
+  for (const { id, root } of workspaces) {
+    const other = seen.get(root)
+    if (other !== undefined) throw new Error(`Workspaces "${other}" and "${id}" are both the project at ${root}; serve it once`)
+    seen.set(root, id)
+  }
+}
+
+export class WorkspaceSet {
+  private readonly workspaces = new Map<string, Workspace>()
+
+  add(workspace: Workspace): Workspace {
+    if (this.workspaces.has(workspace.id)) throw new Error(`Workspace "${workspace.id}" already exists`)
+    this.workspaces.set(workspace.id, workspace)
+    return workspace
+  }
+
+  get size(): number {
+    return this.workspaces.size
+  }
+
+  list(): Workspace[] {
+    return [...this.workspaces.values()]
+  }
+
+  /**
+   * The workspace a query names; with one workspace, the ID may be left out
+   */
+  resolve(id: string | null | undefined): Workspace {
+    if (!id) {
+      if (this.workspaces.size === 1) return this.list()[0]
+      throw new WorkspaceError(false, `workspace is required (one of ${[...this.workspaces.keys()].join(', ')})`)
+    }
+    const workspace = this.workspaces.get(id)
+    if (!workspace) throw new WorkspaceError(true, `Unknown workspace ${id}`)
+    return workspace
+  }
+}
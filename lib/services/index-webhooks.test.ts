@@ -0,0 +1,97 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import http from 'http'
+import type { AddressInfo } from 'net'
+import { SymbolIndex } from '../core/symbol-index.js'
+import { WebhookNotifier, signPayload, updatePayload, verifySignature } from './index-webhooks.js'
+import type { IndexUpdateEvent } from './symbol-index-watcher.js'
+
+interface Received {
+  headers: http.IncomingHttpHeaders
+  body: string
+}
+
+async function startReceiver(statuses: number[]): Promise<{ url: string, received: Received[], close: () => Promise<void> }> {
+  const received: Received[] = []
+  const server = http.createServer((req, res) => {
+    let body = ''
+    req.on('data', chunk => { body += chunk })
+    req.on('end', () => {
+      received.push({ headers: req.headers, body })
+      res.statusCode = statuses[received.length - 1] ?? 200
+      res.end()
+    })
+  })
+  await new Promise<void>(resolve => server.listen(0, '127.0.0.1', resolve))
+  const { port } = server.address() as AddressInfo
+  return { url: `http://127.0.0.1:${port}/hook`, received, close: () => new Promise(resolve => server.close(() => resolve())) }
+}
+
+// src/user.ts lost User.remove and gained User.rename; lib/old.ts was deleted
+function createUpdate(): { index: SymbolIndex, event: IndexUpdateEvent } {
+  const index = new SymbolIndex()
+  const previous = [
+    index.addFile('src/user.ts', 'typescript', [
+      { name: 'User', kind: 'class', line: 1, end_line: 9 },
+      { name: 'User.remove', kind: 'method', line: 2, end_line: 4 }
+    ]),
+    index.addFile('lib/old.ts', 'typescript', [{ name: 'legacy', kind: 'function', line: 1, end_line: 1 }])
+  ]
+  index.addFile('src/user.ts', 'typescript', [
+    { name: 'User', kind: 'class', line: 1, end_line: 9 },
+    { name: 'User.rename', kind: 'method', line: 2, end_line: 4 }
+  ])
+  index.removeFile('lib/old.ts')
+  const event: IndexUpdateEvent = {
+    projectRoot: '/repo',
+    update: { added: [], modified: ['src/user.ts'], removed: ['lib/old.ts'], unchanged: [] },
+    durationMs: 42,
+    previous
+  }
+  return { index, event }
+}
+
+test('index-webhooks: the payload lists changed packages and symbol changes', () => {
+  const { index, event } = createUpdate()
+  const payload = updatePayload(index, event)
+  assert.equal(payload.event, 'index.update')
+  assert.equal(payload.duration_ms, 42)
+  assert.deepEqual(payload.packages, ['lib', 'src'])
+  assert.deepEqual(payload.symbols.added.map(s => [s.name, s.kind, s.path]), [['User.rename', 'method', 'src/user.ts']])
+  assert.deepEqual(payload.symbols.removed.map(s => s.name).sort(), ['User.remove', 'legacy'])
+  assert.equal(payload.symbols.truncated, undefined)
+
+  index.moduleOf = p => ({ name: p.startsWith('src/') ? '@acme/users' : '@acme/legacy', version: '1.0.0' })
+  assert.deepEqual(updatePayload(index, event).packages, ['@acme/legacy', '@acme/users'])
+})
+
+test('index-webhooks: deliveries are signed and retried on server errors', async () => {
+  const receiver = await startReceiver([503, 200])
+  try {
+    const { index, event } = createUpdate()
+    await new WebhookNotifier(index, { urls: [receiver.url], secret: 's3cret', retryDelayMs: 1 }).notify(event)
+
+    assert.equal(receiver.received.length, 2)
+    const [first, second] = receiver.received
+    assert.equal(second.headers['x-indexer-event'], 'index.update')
+    assert.equal(second.headers['x-indexer-delivery'], first.headers['x-indexer-delivery'])
+    assert.equal(second.headers['x-indexer-signature-256'], signPayload(second.body, 's3cret'))
+    assert.ok(verifySignature(second.body, second.headers['x-indexer-signature-256'] as string, 's3cret'))
+    assert.ok(!verifySignature(second.body, signPayload(second.body, 'other'), 's3cret'))
+    assert.deepEqual(JSON.parse(second.body).files.removed, ['lib/old.ts'])
+  } finally {
+    await receiver.close()
+  }
+})
+
+test('index-webhooks: client errors are not retried and unsigned without a secret', async () => {
+  const receiver = await startReceiver([400])
+  try {
+    const { index, event } = createUpdate()
+    await new WebhookNotifier(index, { urls: [receiver.url], retryDelayMs: 1 }).notify(event)
+    assert.equal(receiver.received.length, 1)
+    assert.equal(receiver.received[0].headers['x-indexer-signature-256'], undefined)
+  } finally {
+    await receiver.close()
+  }
+})
@@ -0,0 +1,157 @@
+/**
+ * Index Webhooks Module
+ * Tells downstream systems (docs generators, search, caches) when the
+ * watcher has reindexed: each batch is POSTed as JSON to every configured
+ * URL with the changed files and packages and the symbols added and removed.
+ * With a secret, the body is signed with HMAC-SHA256 and the signature sent
+ * as X-Indexer-Signature-256: sha256=<hex>, as GitHub does, so receivers can
+ * check where a delivery came from. Failed deliveries are retried with
+ * backoff and then dropped with a warning; they never hold up indexing.
+ */
+
+import crypto from 'crypto'
+import { isLocalSymbol } from '../core/local-scopes.js'
+import { packageOf } from '../core/usage-stats.js'
+import { warn } from '../cli/cli-ui.js'
+import type { SymbolIndex } from '../core/symbol-index.js'
+import type { IndexUpdateEvent } from './symbol-index-watcher.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+export const WEBHOOK_EVENT = 'index.update'
+// Symbols listed per delivery; larger batches are marked truncated
+export const MAX_WEBHOOK_SYMBOLS = 1000
+const ATTEMPTS = 3
+const RETRY_DELAY_MS = 1000
+const TIMEOUT_MS = 10_000
+
+export interface WebhookSymbol {
+  id: string
+  name: string
+  kind: string
+  path: string
+  line: number
+}
+
+export interface IndexUpdatePayload {
+  event: typeof WEBHOOK_EVENT
+  project: string
+  timestamp: string // ISO 8601
+  duration_ms: number
+  files: { added: string[], modified: string[], removed: string[] }
+  packages: string[] // packages (or, without a package graph, directories) with changed files
+  symbols: { added: WebhookSymbol[], removed: WebhookSymbol[], truncated?: boolean }
+}
+
+export interface WebhookOptions {
+  urls: string[]
+  /** HMAC-SHA256 key; deliveries are unsigned without one */
+  secret?: string
+  /** First retry delay, doubled for each further attempt */
+  retryDelayMs?: number
+}
+
+function webhookSymbol(sym: IndexedSymbol): WebhookSymbol {
+  return { id: sym.id, name: sym.name, kind: sym.kind, path: sym.path, line: sym.line }
+}
+
+function symbolsOf(shards: (FileShard | undefined)[]): Map<string, IndexedSymbol> {
+  const symbols = new Map<string, IndexedSymbol>()
+  for (const shard of shards) {
+    for (const sym of shard?.symbols || []) {
+      if (!isLocalSymbol(sym)) symbols.set(sym.id, sym)
+    }
+  }
+  return symbols
+}
+
+/**
+ * Payload describing one watcher batch
+ */
+export function updatePayload(index: SymbolIndex, event: IndexUpdateEvent): IndexUpdatePayload {
+  const { added, modified, removed } = event.update
+  const before = symbolsOf(event.previous)
+  const after = symbolsOf([...added, ...modified].map(p => index.getFile(p)))
+  const addedSymbols = [...after.values()].filter(s => !before.has(s.id)).map(webhookSymbol)
+  const removedSymbols = [...before.values()].filter(s => !after.has(s.id)).map(webhookSymbol)
+  const truncated = addedSymbols.length + removedSymbols.length > MAX_WEBHOOK_SYMBOLS
+
+  return {
+    event: WEBHOOK_EVENT,
+    project: event.projectRoot,
+    timestamp: new Date().toISOString(),
+    duration_ms: event.durationMs,
+    files: { added, modified, removed },
+    packages: [...new Set([...added, ...modified, ...removed].map(p => packageOf(index, p)))].sort(),
+    symbols: {
+      added: addedSymbols.slice(0, MAX_WEBHOOK_SYMBOLS),
+      removed: removedSymbols.slice(0, Math.max(0, MAX_WEBHOOK_SYMBOLS - addedSymbols.length)),
+      ...(truncated ? { truncated: true } : {})
+    }
+  }
+}
+
+/**
+ * X-Indexer-Signature-256 value of a body
+ */
+export function signPayload(body: string, secret: string): string {
+  return `sha256=${crypto.createHmac('sha256', secret).update(body).digest('hex')}`
+}
+
+/**
+ * Whether a received body matches its X-Indexer-Signature-256 header, for
+ * receivers written in Node
+ */
+export function verifySignature(body: string, signature: string, secret: string): boolean {
+  const expected = Buffer.from(signPayload(body, secret))
+  const actual = Buffer.from(signature)
+  return expected.length === actual.length && crypto.timingSafeEqual(expected, actual)
+}
+
+/**
+ * POST a body to one URL, retrying network errors and 5xx responses
+ */
+export async function deliverWebhook(url: string, body: string, options: Omit<WebhookOptions, 'urls'> = {}): Promise<void> {
+  const headers: Record<string, string> = {
+    'Content-Type': 'application/json',
+    'User-Agent': 'indexer-webhook',
+    'X-Indexer-Event': WEBHOOK_EVENT,
+    'X-Indexer-Delivery': crypto.randomUUID()
+  }
+  if (options.secret) headers['X-Indexer-Signature-256'] = signPayload(body, options.secret)
+
+  let lastError = ''
+  for (let attempt = 0; attempt < ATTEMPTS; attempt++) {
+    if (attempt > 0) await new Promise(resolve => setTimeout(resolve, (options.retryDelayMs ?? RETRY_DELAY_MS) * 2 ** (attempt - 1)))
+    try {
+      const res = await fetch(url, { method: 'POST', headers, body, signal: AbortSignal.timeout(TIMEOUT_MS) })
+      if (res.ok) return
+      lastError = `HTTP ${res.status}`
+      if (res.status < 500) break // the receiver rejected it; retrying will not help
+    } catch (e: any) {
+      lastError = e.message
+    }
+  }
+  throw new Error(`Webhook ${url} failed: ${lastError}`)
+}
+
+/**
+ * Sends every watcher batch to the configured webhooks. Deliveries to one
+ * URL go out in order; a slow receiver delays only its own.
+ */
+export class WebhookNotifier {
+  private queues = new Map<string, Promise<void>>()
+
+  constructor(private readonly index: SymbolIndex, private readonly options: WebhookOptions) {}
+
+  notify(event: IndexUpdateEvent): Promise<void> {
+    const body = JSON.stringify(updatePayload(this.index, event))
+    const deliveries = this.options.urls.map(url => {
+      const next = (this.queues.get(url) || Promise.resolve())
+        .then(() => deliverWebhook(url, body, this.options))
+        .catch((e: Error) => warn(e.message))
+      this.queues.set(url, next)
+      return next
+    })
+    return Promise.all(deliveries).then(() => {})
+  }
+}
@@ -249,13 +249,13 @@ function startProjectWatcher(projectPath) {
 }
 
 /**
- * Handle config file changes (.gitignore, .indexer/to-index)
+ * Handle config file changes (.gitignore, .indexerignore, .indexer/to-index)
  * @param {string} projectPath - Project root path
  * @param {string} relPath - Relative path to changed file
  * @returns {Promise<void>}
  */
 export async function handleConfigFileChange(projectPath, relPath) {
-  if (relPath === '.gitignore' || relPath === '.indexer/to-index') {
+  if (relPath === '.gitignore' || relPath === '.indexerignore' || relPath === '.indexer/to-index') {
     log(`Config file changed: ${relPath}`)
     resetConfigCache(projectPath)
     // Force full sync to rebuild snapshot with new rules
@@ -0,0 +1,81 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import os from 'os'
+import path from 'path'
+import { SymbolIndex } from '../core/symbol-index.js'
+import { fail } from '../cli/cli-ui.js'
+import { commandContext, type CommandContext } from '../cli/command-context.js'
+import { QueryDaemon, queryDaemonStatus, runInQueryDaemon, stopQueryDaemon, type DaemonCommand } from './query-daemon.js'
+import { createIndex, type FixtureFiles } from '../test-utils.js'
+
+const FILES: FixtureFiles = {
+  'src/user.ts': [
+    { name: 'User', kind: 'class', line: 1, end_line: 9 },
+    { name: 'User.save', kind: 'method', line: 2, end_line: 4 }
+  ]
+}
+
+const COMMANDS: Record<string, DaemonCommand> = {
+  async files(cwd, args) {
+    const { index } = commandContext.getStore()!.resident
+    console.log(`${cwd} ${args.join(' ')}: ${index.listFiles().join(',')}`)
+    process.stderr.write('done\n')
+  },
+  async broken() {
+    fail('no such symbol', 3)
+  },
+  async partial() {
+    console.log('1 match')
+    process.exitCode = 2
+  },
+  async crash() {
+    throw new Error('boom')
+  }
+}
+
+// Run a command through the daemon, collecting what the client would print
+async function runCaptured(socketPath: string, command: string, args: string[] = []) {
+  const output = { stdout: '', stderr: '' }
+  const capture: CommandContext = {
+    write: (stream, data) => { output[stream] += data },
+    resident: { root: '', index: new SymbolIndex(), deps: false, locals: false, strings: 0 }
+  }
+  const code = await commandContext.run(capture, () => runInQueryDaemon(socketPath, { command, args, cwd: '/repo' }))
+  return { code, ...output }
+}
+
+test('query-daemon: commands run against the resident index with their output and exit code', async () => {
+  const socketPath = path.join(os.tmpdir(), `indexer-daemon-test-${process.pid}.sock`)
+  const resident = { root: '/repo', index: createIndex(FILES), deps: false, locals: false, strings: 0 }
+  let stopped = false
+  const daemon = new QueryDaemon({
+    socketPath,
+    resident,
+    commands: COMMANDS,
+    onStop: () => { stopped = true }
+  })
+  await daemon.listen()
+  try {
+    await assert.rejects(new QueryDaemon({ socketPath, resident, commands: {} }).listen(), /already listening/)
+
+    assert.deepEqual(await runCaptured(socketPath, 'files', ['--json']), {
+      code: 0, stdout: '/repo --json: src/user.ts\n', stderr: 'done\n'
+    })
+    assert.deepEqual(await runCaptured(socketPath, 'broken'), { code: 3, stdout: '', stderr: '[indexer] no such symbol\n' })
+    assert.deepEqual(await runCaptured(socketPath, 'partial'), { code: 2, stdout: '1 match\n', stderr: '' })
+    assert.deepEqual(await runCaptured(socketPath, 'crash'), { code: 1, stdout: '', stderr: '[indexer] boom\n' })
+    assert.equal((await runCaptured(socketPath, 'index')).code, 1)
+    assert.equal(process.exitCode, undefined)
+
+    const status = (await queryDaemonStatus(socketPath))!
+    assert.deepEqual([status.pid, status.root, status.files, status.symbols, status.requests], [process.pid, '/repo', 1, 2, 4])
+
+    assert.equal(await stopQueryDaemon(socketPath), true)
+    await new Promise(resolve => setTimeout(resolve, 50))
+    assert.ok(stopped)
+    assert.equal(await queryDaemonStatus(socketPath), null)
+    assert.equal(await runInQueryDaemon(socketPath, { command: 'files', args: [], cwd: '/repo' }), null)
+  } finally {
+    await daemon.close()
+  }
+})
@@ -0,0 +1,269 @@
+/**
+ * Query Daemon Module
+ * Keeps one project's symbol index in memory so repeated CLI queries skip
+ * loading it. The daemon listens on a Unix socket (a named pipe on Windows)
+ * and speaks newline-delimited JSON: a client sends one request per
+ * connection and reads frames back until the last one.
+ *
+ *   {"method":"run","command":"query","args":["--kind=class"],"cwd":"/repo"}
+ *     -> {"stream":"stdout","data":"..."} ... {"exit":0}
+ *   {"method":"status"} -> {"status":{...}}
+ *   {"method":"stop"}   -> {"stopped":true}
+ *
+ * Commands run one at a time, with their stdout / stderr routed to the
+ * client that asked and fail() ending the command instead of the process.
+ */
+
+import fs from 'fs/promises'
+import net from 'net'
+import path from 'path'
+import readline from 'readline'
+import { CommandExit, commandContext, type ResidentIndex } from '../cli/command-context.js'
+
+/** A CLI command handler, as in cli-commands */
+export type DaemonCommand = (cwd: string, args: string[]) => Promise<void>
+
+export interface QueryDaemonOptions {
+  socketPath: string
+  resident: ResidentIndex
+  commands: Record<string, DaemonCommand>
+  /** Called once a stop request has closed the socket */
+  onStop?: () => void | Promise<void>
+}
+
+export interface QueryDaemonStatus {
+  pid: number
+  root: string
+  socket: string
+  files: number
+  symbols: number
+  deps: boolean
+  locals: boolean
+  strings: number
+  uptime_ms: number
+  requests: number // commands served
+}
+
+export interface RunRequest {
+  command: string
+  args: string[]
+  cwd: string
+}
+
+type DaemonRequest = { method: 'run' } & RunRequest | { method: 'status' } | { method: 'stop' }
+
+type DaemonFrame =
+  | { stream: 'stdout' | 'stderr', data: string }
+  | { exit: number }
+  | { status: QueryDaemonStatus }
+  | { stopped: true }
+  | { error: string }
+
+let outputRouted = false
+
+/**
+ * Send process.stdout / process.stderr writes made inside a command context
+ * to that context; everything else still reaches the daemon's own streams
+ */
+function routeOutput(): void {
+  if (outputRouted) return
+  outputRouted = true
+  for (const name of ['stdout', 'stderr'] as const) {
+    const stream = process[name]
+    const write = stream.write.bind(stream) as (...args: any[]) => boolean
+    stream.write = ((chunk: string | Uint8Array, ...rest: any[]) => {
+      const context = commandContext.getStore()
+      if (!context) return write(chunk, ...rest)
+      context.write(name, typeof chunk === 'string' ? chunk : Buffer.from(chunk).toString('utf8'))
+      const callback = rest.find(arg => typeof arg === 'function')
+      if (callback) callback()
+      return true
+    }) as typeof stream.write
+  }
+}
+
+function sendFrame(socket: net.Socket, frame: DaemonFrame): void {
+  if (!socket.destroyed) socket.write(JSON.stringify(frame) + '\n')
+}
+
+export class QueryDaemon {
+  private server: net.Server | null = null
+  private queue: Promise<void> = Promise.resolve()
+  private requests = 0
+  private readonly startedAt = Date.now()
+
+  constructor(private readonly options: QueryDaemonOptions) {}
+
+  /**
+   * Start listening, replacing a socket left behind by a daemon that died.
+   * Fails when another daemon already answers on the socket.
+   */
+  async listen(): Promise<void> {
+    const { socketPath } = this.options
+    if (await queryDaemonStatus(socketPath)) {
+      throw new Error(`A query daemon is already listening on ${socketPath}`)
+    }
+    if (process.platform !== 'win32') {
+      await fs.mkdir(path.dirname(socketPath), { recursive: true })
+      await fs.rm(socketPath, { force: true })
+    }
+    routeOutput()
+    const server = net.createServer(socket => this.accept(socket))
+    await new Promise<void>((resolve, reject) => {
+      server.once('error', reject)
+      server.listen(socketPath, () => {
+        server.off('error', reject)
+        resolve()
+      })
+    })
+    // Only the user who started the daemon may talk to it
+    if (process.platform !== 'win32') await fs.chmod(socketPath, 0o600)
+    this.server = server
+  }
+
+  status(): QueryDaemonStatus {
+    const { index, root, deps, locals, strings } = this.options.resident
+    return {
+      pid: process.pid,
+      root,
+      socket: this.options.socketPath,
+      files: index.listFiles().length,
+      symbols: index.allSymbols().length,
+      deps,
+      locals,
+      strings,
+      uptime_ms: Date.now() - this.startedAt,
+      requests: this.requests
+    }
+  }
+
+  async close(): Promise<void> {
+    const server = this.server
+    this.server = null
+    if (server) await new Promise<void>(resolve => server.close(() => resolve()))
+  }
+
+  private accept(socket: net.Socket): void {
+    socket.on('error', () => {}) // a client that went away
+    const lines = readline.createInterface({ input: socket })
+    lines.once('line', line => {
+      lines.close()
+      let request: DaemonRequest
+      try {
+        request = JSON.parse(line)
+      } catch {
+        sendFrame(socket, { error: 'Malformed request' })
+        socket.end()
+        return
+      }
+      this.handle(socket, request)
+    })
+  }
+
+  private handle(socket: net.Socket, request: DaemonRequest): void {
+    switch (request.method) {
+      case 'run':
+        this.queue = this.queue.then(() => this.run(socket, request)).then(() => { socket.end() })
+        return
+      case 'status':
+        sendFrame(socket, { status: this.status() })
+        socket.end()
+        return
+      case 'stop':
+        sendFrame(socket, { stopped: true })
+        socket.end()
+        void this.queue.then(() => this.close()).then(() => this.options.onStop?.())
+        return
+      default:
+        sendFrame(socket, { error: `Unknown method "${(request as { method: string }).method}"` })
+        socket.end()
+    }
+  }
+
+  // One command at a time: process.exitCode and the routed streams are process-wide
+  private async run(socket: net.Socket, request: RunRequest): Promise<void> {
+    const command = this.options.commands[request.command]
+    if (!command) {
+      sendFrame(socket, { stream: 'stderr', data: `[indexer] The query daemon does not run "${request.command}"\n` })
+      sendFrame(socket, { exit: 1 })
+      return
+    }
+    const context = {
+      write: (stream: 'stdout' | 'stderr', data: string) => sendFrame(socket, { stream, data }),
+      resident: this.options.resident
+    }
+    const savedExitCode = process.exitCode
+    process.exitCode = undefined
+    let code: number
+    try {
+      await commandContext.run(context, () => command(request.cwd, request.args))
+      code = Number(process.exitCode ?? 0)
+    } catch (e: any) {
+      if (e instanceof CommandExit) {
+        code = e.code
+      } else {
+        context.write('stderr', `[indexer] ${e.message}\n`)
+        code = 1
+      }
+    } finally {
+      process.exitCode = savedExitCode
+    }
+    this.requests++
+    sendFrame(socket, { exit: code })
+  }
+}
+
+/**
+ * Send one request and pass each frame to onFrame until the daemon closes
+ * the connection. Resolves false when no daemon listens on the socket.
+ */
+async function request(socketPath: string, body: DaemonRequest, onFrame: (frame: DaemonFrame) => void): Promise<boolean> {
+  const socket = await new Promise<net.Socket | null>(resolve => {
+    const conn = net.connect(socketPath)
+    conn.once('connect', () => resolve(conn))
+    conn.once('error', () => resolve(null))
+  })
+  if (!socket) return false
+  socket.on('error', () => {})
+  socket.write(JSON.stringify(body) + '\n')
+  for await (const line of readline.createInterface({ input: socket })) {
+    if (line) onFrame(JSON.parse(line))
+  }
+  return true
+}
+
+/**
+ * Run a command in the daemon, writing its output to this process's
+ * streams. Resolves its exit code, or null when no daemon is running.
+ */
+export async function runInQueryDaemon(socketPath: string, run: RunRequest): Promise<number | null> {
+  let code: number | null = null
+  const reached = await request(socketPath, { method: 'run', ...run }, frame => {
+    if ('stream' in frame) process[frame.stream].write(frame.data)
+    else if ('exit' in frame) code = frame.exit
+    else if ('error' in frame) process.stderr.write(`[indexer] ${frame.error}\n`)
+  })
+  if (!reached) return null
+  // The daemon went away before the command finished
+  return code ?? 1
+}
+
+export async function queryDaemonStatus(socketPath: string): Promise<QueryDaemonStatus | null> {
+  let status: QueryDaemonStatus | null = null
+  await request(socketPath, { method: 'status' }, frame => {
+    if ('status' in frame) status = frame.status
+  })
+  return status
+}
+
+/**
+ * Ask the daemon to exit once the command it is running finishes.
+ * Resolves false when none is running.
+ */
+export async function stopQueryDaemon(socketPath: string): Promise<boolean> {
+  let stopped = false
+  await request(socketPath, { method: 'stop' }, frame => {
+    if ('stopped' in frame) stopped = true
+  })
+  return stopped
+}
@@ -0,0 +1,198 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import path from 'path'
+import { tmpdir } from 'os'
+import { SymbolIndex, syncSymbolIndex } from '../core/symbol-index.js'
+import { SymbolIndexWatcher, retryDelay, type IndexUpdateEvent } from './symbol-index-watcher.js'
+import { takeCheckpoint } from '../core/index-checkpoint.js'
+import type { SymbolStore } from '../utils/symbol-store.js'
+import type { FileShard } from '../types/index.js'
+
+// Store in memory whose next updates can be made to fail
+class MemoryStore implements SymbolStore {
+  shards = new Map<string, FileShard>()
+  failures = 0
+
+  async load(): Promise<FileShard[] | null> {
+    return Array.from(this.shards.values())
+  }
+
+  async save(shards: FileShard[]): Promise<void> {
+    this.shards = new Map(shards.map(s => [s.path, s]))
+  }
+
+  async update(shards: FileShard[], removed: string[]): Promise<void> {
+    if (this.failures > 0) {
+      this.failures--
+      throw new Error('disk full')
+    }
+    for (const relPath of removed) this.shards.delete(relPath)
+    for (const shard of shards) this.shards.set(shard.path, shard)
+  }
+
+  async findSymbols(): Promise<[]> {
+    return []
+  }
+
+  async clear(): Promise<void> {
+    this.shards.clear()
+  }
+}
+
+async function writeFiles(root: string, files: Record<string, string>) {
+  for (const [relPath, content] of Object.entries(files)) {
+    await fs.mkdir(path.join(root, path.dirname(relPath)), { recursive: true })
+    await fs.writeFile(path.join(root, relPath), content)
+  }
+}
+
+async function createWatcher(root: string) {
+  await writeFiles(root, {
+    'src/user.ts': 'export class User {}\n',
+    'src/legacy.ts': 'export function legacy() {}\n'
+  })
+  const index = new SymbolIndex()
+  await syncSymbolIndex(index, root, ['src/legacy.ts', 'src/user.ts'])
+  const store = new MemoryStore()
+  await store.save(index.listShards())
+  const watcher = new SymbolIndexWatcher(root, index, { store })
+  const updates: IndexUpdateEvent[] = []
+  const errors: Error[] = []
+  watcher.on('update', (event: IndexUpdateEvent) => updates.push(event))
+  watcher.on('error', (e: Error) => errors.push(e))
+  return { index, store, watcher, updates, errors }
+}
+
+function storedNames(store: MemoryStore): string[] {
+  return Array.from(store.shards.values()).flatMap(s => s.symbols.map(sym => sym.name)).sort()
+}
+
+test('symbol-index-watcher: a batch applies added, modified and removed files', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'watcher-test-'))
+  try {
+    const { index, store, watcher, updates, errors } = await createWatcher(root)
+    await writeFiles(root, {
+      'src/user.ts': 'export class User {}\nexport function login() {}\n',
+      'src/admin.ts': 'export class Admin {}\n'
+    })
+    await fs.rm(path.join(root, 'src/legacy.ts'))
+    watcher.queue(['src/user.ts', 'src/admin.ts', 'src/legacy.ts'])
+    await watcher.flush()
+
+    assert.deepEqual(errors, [])
+    assert.equal(updates.length, 1)
+    const { update, previous } = updates[0]
+    assert.deepEqual([update.added, update.modified, update.removed], [['src/admin.ts'], ['src/user.ts'], ['src/legacy.ts']])
+    assert.deepEqual(previous.map(s => s.path).sort(), ['src/legacy.ts', 'src/user.ts'])
+    assert.deepEqual(index.listFiles(), ['src/admin.ts', 'src/user.ts'])
+    assert.equal(index.findSymbols('login').length, 1)
+    assert.equal(index.findSymbols('legacy').length, 0)
+    assert.deepEqual(storedNames(store), ['Admin', 'User', 'login'])
+
+    // Nothing dirty, nothing to do
+    await watcher.flush()
+    assert.equal(updates.length, 1)
+    await watcher.close()
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('symbol-index-watcher: a failed batch is retried until the store catches up', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'watcher-test-'))
+  try {
+    const { index, store, watcher, updates, errors } = await createWatcher(root)
+    store.failures = 1
+    await writeFiles(root, { 'src/user.ts': 'export class Account {}\n' })
+    watcher.queue(['src/user.ts'])
+    await watcher.flush()
+
+    assert.deepEqual(errors.map(e => e.message), ['disk full'])
+    assert.equal(updates.length, 0)
+    // The index took the change, the store did not
+    assert.equal(index.findSymbols('Account').length, 1)
+    assert.deepEqual(storedNames(store), ['User', 'legacy'])
+
+    // The retry stores the file even though the index already has it
+    await watcher.flush()
+    assert.equal(errors.length, 1)
+    assert.deepEqual(storedNames(store), ['Account', 'legacy'])
+
+    // Later batches store only their own files again
+    await fs.rm(path.join(root, 'src/legacy.ts'))
+    watcher.queue(['src/legacy.ts'])
+    await watcher.flush()
+    assert.deepEqual(updates.at(-1)!.update.removed, ['src/legacy.ts'])
+    assert.deepEqual(storedNames(store), ['Account'])
+    await watcher.close()
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('symbol-index-watcher: shutdown checkpoints once the store has caught up', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'watcher-test-'))
+  try {
+    const { store, watcher, errors } = await createWatcher(root)
+    store.failures = 1
+    await writeFiles(root, { 'src/user.ts': 'export class Account {}\n' })
+    watcher.queue(['src/user.ts'])
+    await watcher.flush()
+    assert.equal(errors.length, 1)
+    // The retry on shutdown succeeds, so the failure no longer stands in the way
+    assert.equal(await watcher.shutdown(), true)
+    assert.deepEqual(storedNames(store), ['Account', 'legacy'])
+    assert.deepEqual(Object.keys((await takeCheckpoint(root))!.files).sort(), ['src/legacy.ts', 'src/user.ts'])
+
+    const failing = await createWatcher(root)
+    failing.store.failures = 2
+    await writeFiles(root, { 'src/user.ts': 'export class Customer {}\n' })
+    failing.watcher.queue(['src/user.ts'])
+    await failing.watcher.flush()
+    assert.equal(await failing.watcher.shutdown(), false)
+    assert.equal(await takeCheckpoint(root), null)
+  } finally {
+    await takeCheckpoint(root)
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('symbol-index-watcher: failed batches are retried after doubling delays, up to a limit', () => {
+  assert.deepEqual([1, 2, 3].map(n => retryDelay(300, n)), [600, 1200, 2400])
+  assert.equal(retryDelay(300, 10), 60_000)
+  assert.equal(retryDelay(300, 11), null)
+})
+
+test('symbol-index-watcher: failures without an error listener and throwing update listeners leave later batches running', async () => {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'watcher-test-'))
+  try {
+    const { index, store } = await createWatcher(root)
+    const watcher = new SymbolIndexWatcher(root, index, { store })
+    // Nothing listens for the failure, which must not reject the batch
+    store.failures = 1
+    await writeFiles(root, { 'src/user.ts': 'export class Account {}\n' })
+    watcher.queue(['src/user.ts'])
+    await watcher.flush()
+    assert.deepEqual(storedNames(store), ['User', 'legacy'])
+    await watcher.flush()
+    assert.deepEqual(storedNames(store), ['Account', 'legacy'])
+
+    let updates = 0
+    watcher.on('update', () => {
+      updates++
+      throw new Error('listener bug')
+    })
+    await writeFiles(root, { 'src/user.ts': 'export class Customer {}\n' })
+    watcher.queue(['src/user.ts'])
+    await assert.rejects(watcher.flush(), /listener bug/)
+    assert.deepEqual(storedNames(store), ['Customer', 'legacy'])
+    // The batch was stored and is not re-indexed because its listener threw
+    await watcher.flush()
+    assert.equal(updates, 1)
+    assert.equal(await watcher.shutdown(), true)
+  } finally {
+    await takeCheckpoint(root)
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
@@ -0,0 +1,251 @@
+import chokidar from 'chokidar'
+import path from 'path'
+import { EventEmitter } from 'events'
+import { log } from '../cli/cli-ui.js'
+import { applyFileChanges, checkpointSymbolIndex, persistUpdate, type IndexUpdate, type SymbolIndex } from '../core/symbol-index.js'
+import type { IndexLimits } from '../core/index-limits.js'
+import { relativeIndexPath } from '../core/index-paths.js'
+import { recordGeneration } from '../core/index-history.js'
+import { getSymbolStore, type SymbolStore } from '../utils/symbol-store.js'
+import type { FileShard } from '../types/index.js'
+import { withSpan } from '../utils/tracing.js'
+
+// Directory names that never contain indexable sources
+const IGNORED_DIRS = new Set([
+  '.git', 'node_modules', 'dist', 'build', '.next', '.cache', 'coverage',
+  '.idea', '.vscode', '.indexer', 'Library', 'Temp', 'obj', 'Logs'
+])
+
+const WATCH_DEBOUNCE_MS = Number(process.env.WATCH_DEBOUNCE_MS) || 300
+// Failed batches are retried after doubling delays up to a minute, and no
+// longer on their own after this many in a row
+const MAX_RETRY_DELAY_MS = 60_000
+const MAX_RETRIES = 10
+
+/**
+ * Delay before retrying after a number of failed batches in a row
+ * @returns null once they are no longer retried until the next change
+ */
+export function retryDelay(debounceMs: number, failures: number): number | null {
+  if (failures > MAX_RETRIES) return null
+  return Math.min(debounceMs * 2 ** failures, MAX_RETRY_DELAY_MS)
+}
+
+export interface IndexUpdateEvent {
+  projectRoot: string
+  update: IndexUpdate
+  durationMs: number
+  previous: FileShard[] // shards of the batch's files before it, for the ones that were indexed
+}
+
+/** Besides its own settings, the concurrency and IO rate of each reindex */
+export interface SymbolIndexWatcherOptions extends IndexLimits {
+  debounceMs?: number
+  persist?: boolean
+  store?: SymbolStore
+}
+
+/**
+ * Keeps a symbol index hot while files are edited.
+ * Emits 'change' (event, relPath) for every file system event,
+ * 'update' (IndexUpdateEvent) after each debounced batch and
+ * 'error' (Error) when a batch fails, which is logged when nothing listens;
+ * failed batches are retried with backoff. Closing it cancels a reindex in
+ * progress, which leaves the index as it was; shutting it down finishes the
+ * pending changes first and checkpoints the index, so the next start
+ * resumes from there (see index-checkpoint).
+ */
+export class SymbolIndexWatcher extends EventEmitter {
+  private watcher: ReturnType<typeof chokidar.watch> | null = null
+  private dirty = new Set<string>()
+  // Files of failed batches whose shards in memory may be ahead of the store
+  private unsaved = new Set<string>()
+  private timer: NodeJS.Timeout | null = null
+  private chain: Promise<void> = Promise.resolve()
+  private readonly debounceMs: number
+  private readonly persist: boolean
+  private readonly store: SymbolStore
+  private readonly limits: IndexLimits
+  private readonly controller = new AbortController()
+  // A failed batch leaves the store behind the tree, so it cannot be
+  // checkpointed until a later batch has retried its files
+  private failed = false
+  // Failed batches in a row, for the retry backoff
+  private failures = 0
+
+  constructor(
+    private readonly projectRoot: string,
+    private readonly index: SymbolIndex,
+    options: SymbolIndexWatcherOptions = {}
+  ) {
+    super()
+    this.debounceMs = options.debounceMs ?? WATCH_DEBOUNCE_MS
+    this.persist = options.persist ?? true
+    this.store = options.store ?? getSymbolStore(projectRoot)
+    this.limits = { concurrency: options.concurrency, readConcurrency: options.readConcurrency, ioRate: options.ioRate }
+  }
+
+  start(): void {
+    if (this.watcher) return
+    this.watcher = chokidar.watch(this.projectRoot, {
+      ignored: (p: string) => {
+        const rel = path.relative(this.projectRoot, p)
+        return rel.split(path.sep).some(part => IGNORED_DIRS.has(part))
+      },
+      ignoreInitial: true,
+      persistent: true
+    })
+
+    this.watcher.on('all', (event: string, absPath: string) => {
+      if (event === 'addDir' || event === 'unlinkDir') return
+      const rel = relativeIndexPath(this.projectRoot, absPath)
+      if (rel === null) return
+      this.dirty.add(rel)
+      this.emit('change', event, rel)
+      this.schedule()
+    })
+    log(`Watching ${this.projectRoot} for symbol index updates`)
+  }
+
+  /**
+   * Queue files (paths relative to the project) for the next batch, as file
+   * system events do; for callers that know what changed, such as an editor
+   * that saved them
+   */
+  queue(relPaths: string[]): void {
+    for (const rel of relPaths) this.dirty.add(rel)
+    if (this.watcher) this.schedule()
+  }
+
+  private schedule(delay = this.debounceMs): void {
+    if (this.timer) clearTimeout(this.timer)
+    this.timer = setTimeout(() => {
+      this.timer = null
+      this.flush().catch(e => log(`Symbol index update listener failed: ${e.message}`))
+    }, delay)
+  }
+
+  /**
+   * Re-index all dirty files now.
+   * Batches are chained so a slow re-index never overlaps the next one;
+   * the returned promise rejects when an 'update' listener throws, which
+   * leaves later batches to run as usual.
+   */
+  flush(): Promise<void> {
+    const batch = this.chain.then(() => this.runBatch())
+    this.chain = batch.catch(() => {})
+    return batch
+  }
+
+  private async runBatch(): Promise<void> {
+    if (this.dirty.size === 0 || this.controller.signal.aborted) return
+    const batch = Array.from(this.dirty)
+    this.dirty.clear()
+
+    const startTime = Date.now()
+    // Shards are replaced, never changed in place, so these stay as they were
+    const previous = batch.map(rel => this.index.getFile(rel)).filter((s): s is FileShard => !!s)
+    let update: IndexUpdate
+    try {
+      update = await withSpan('indexer.reindex', { 'indexer.files': batch.length }, async () => {
+        const update = await applyFileChanges(this.index, this.projectRoot, batch, { ...this.limits, signal: this.controller.signal })
+        if (this.persist) await this.save(update)
+        return update
+      })
+    } catch (e: any) {
+      if (this.controller.signal.aborted) return
+      // Retry the batch; whatever of it reached the index is stored from there
+      for (const rel of batch) {
+        this.dirty.add(rel)
+        if (this.persist) this.unsaved.add(rel)
+      }
+      this.failed = true
+      this.failures++
+      if (this.listenerCount('error') > 0) this.emit('error', e)
+      else log(`Symbol index update failed: ${e.message}`)
+      const delay = retryDelay(this.debounceMs, this.failures)
+      if (delay === null) log(`Symbol index update failed ${this.failures} times in a row; retrying on the next change`)
+      else if (this.watcher) this.schedule(delay)
+      return
+    }
+    // The batch retried the files of any failed one and stored them
+    this.failed = false
+    this.failures = 0
+    if (update.added.length + update.modified.length + update.removed.length === 0) return
+    const event: IndexUpdateEvent = {
+      projectRoot: this.projectRoot,
+      update,
+      durationMs: Date.now() - startTime,
+      previous
+    }
+    this.emit('update', event)
+  }
+
+  // Store an update, along with the files earlier failed batches left unstored
+  private async save(update: IndexUpdate): Promise<void> {
+    let stored = update
+    if (this.unsaved.size > 0) {
+      for (const rel of [...update.added, ...update.modified, ...update.removed]) this.unsaved.add(rel)
+      const paths = Array.from(this.unsaved)
+      stored = {
+        added: [],
+        modified: paths.filter(rel => this.index.getFile(rel)),
+        removed: paths.filter(rel => !this.index.getFile(rel)),
+        unchanged: []
+      }
+    }
+    if (stored.added.length + stored.modified.length + stored.removed.length === 0) return
+    await persistUpdate(this.index, this.store, stored)
+    this.unsaved.clear()
+    await recordGeneration(this.projectRoot, this.index.listShards())
+  }
+
+  /**
+   * Stop watching, apply the changes seen so far and, when the store is up
+   * to date with them, write a checkpoint of the index
+   * @returns Whether a checkpoint was written
+   */
+  async shutdown(): Promise<boolean> {
+    if (this.timer) {
+      clearTimeout(this.timer)
+      this.timer = null
+    }
+    if (this.watcher) {
+      await this.watcher.close()
+      this.watcher = null
+    }
+    await this.flush()
+    const checkpoint = this.persist && !this.failed && !this.controller.signal.aborted
+    if (checkpoint) await checkpointSymbolIndex(this.index, this.projectRoot)
+    this.controller.abort()
+    return checkpoint
+  }
+
+  async close(): Promise<void> {
+    this.controller.abort()
+    if (this.timer) {
+      clearTimeout(this.timer)
+      this.timer = null
+    }
+    if (this.watcher) {
+      await this.watcher.close()
+      this.watcher = null
+    }
+  }
+}
+
+/**
+ * Start watching a project and invoke a callback for every index update
+ */
+export function watchSymbolIndex(
+  projectRoot: string,
+  index: SymbolIndex,
+  onUpdate: (event: IndexUpdateEvent) => void,
+  options: SymbolIndexWatcherOptions = {}
+): SymbolIndexWatcher {
+  const watcher = new SymbolIndexWatcher(projectRoot, index, options)
+  watcher.on('update', onUpdate)
+  watcher.on('error', (e: Error) => log(`Symbol index update failed: ${e.message}`))
+  watcher.start()
+  return watcher
+}
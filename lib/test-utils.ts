@@ -0,0 +1,111 @@
+/**
+ * Test Utilities
+ * Fixtures shared by the unit tests: symbol and trigram indexes of files
+ * given in memory, and archives built in memory.
+ */
+
+import zlib from 'zlib'
+import { SymbolIndex, indexFileContents } from './core/symbol-index.js'
+import { TrigramIndex } from './core/trigram-index.js'
+import { detectLanguage } from './tools/common/utils.js'
+import { extractJSSymbols } from './utils/ast-js.js'
+import type { SymbolInfo } from './types/index.js'
+
+// Files of an index by path: their extracted symbols, or JavaScript /
+// TypeScript source for extractJSSymbols
+export type FixtureFiles = Record<string, Partial<SymbolInfo>[] | string>
+
+export interface FixtureOptions extends Partial<Pick<SymbolIndex, 'moduleOf' | 'externalOf' | 'ownersOf' | 'caseInsensitive'>> {
+  hashes?: Record<string, string> // content hash per file
+}
+
+/**
+ * Index of files added in order, each in the language of its extension; the
+ * options are set on the index before the first file is added
+ */
+export function createIndex(files: FixtureFiles, options: FixtureOptions = {}): SymbolIndex {
+  const { hashes = {}, ...settings } = options
+  const index = Object.assign(new SymbolIndex(), settings)
+  for (const [filePath, symbols] of Object.entries(files)) {
+    index.addFile(filePath, detectLanguage(filePath), typeof symbols === 'string' ? extractJSSymbols(symbols) : symbols, hashes[filePath])
+  }
+  return index
+}
+
+// Index of files parsed from their source, as the indexer parses them
+export async function indexSources(sources: Record<string, string>): Promise<SymbolIndex> {
+  const index = new SymbolIndex()
+  await indexFileContents(index, Object.keys(sources), Object.values(sources))
+  return index
+}
+
+export function createTrigramIndex(sources: Record<string, string>): TrigramIndex {
+  const index = new TrigramIndex()
+  for (const [filePath, content] of Object.entries(sources)) index.add(filePath, content)
+  return index
+}
+
+// Zip of files, deflated or stored; names ending in / are directories
+export function zip(files: Record<string, string>, deflate = true): Buffer {
+  const locals: Buffer[] = []
+  const centrals: Buffer[] = []
+  let offset = 0
+  for (const [name, content] of Object.entries(files)) {
+    const nameBytes = Buffer.from(name)
+    const raw = Buffer.from(content)
+    const data = deflate ? zlib.deflateRawSync(raw) : raw
+    const local = Buffer.alloc(30)
+    local.writeUInt32LE(0x04034b50, 0)
+    local.writeUInt16LE(20, 4)
+    local.writeUInt16LE(deflate ? 8 : 0, 8)
+    local.writeUInt32LE(data.length, 18)
+    local.writeUInt32LE(raw.length, 22)
+    local.writeUInt16LE(nameBytes.length, 26)
+    const central = Buffer.alloc(46)
+    central.writeUInt32LE(0x02014b50, 0)
+    central.writeUInt16LE(3 << 8 | 20, 4)
+    central.writeUInt16LE(deflate ? 8 : 0, 10)
+    central.writeUInt32LE(data.length, 20)
+    central.writeUInt32LE(raw.length, 24)
+    central.writeUInt16LE(nameBytes.length, 28)
+    central.writeUInt32LE((name.endsWith('/') ? 0o40755 : 0o100644) << 16 >>> 0, 38)
+    central.writeUInt32LE(offset, 42)
+    locals.push(local, nameBytes, data)
+    centrals.push(central, nameBytes)
+    offset += local.length + nameBytes.length + data.length
+  }
+  const directory = Buffer.concat(centrals)
+  const end = Buffer.alloc(22)
+  end.writeUInt32LE(0x06054b50, 0)
+  end.writeUInt16LE(Object.keys(files).length, 8)
+  end.writeUInt16LE(Object.keys(files).length, 10)
+  end.writeUInt32LE(directory.length, 12)
+  end.writeUInt32LE(offset, 16)
+  return Buffer.concat([...locals, directory, end])
+}
+
+export function tarEntry(name: string, content: string, type = '0'): Buffer {
+  const data = Buffer.from(content)
+  const header = Buffer.alloc(512)
+  header.write(name.slice(0, 100), 0)
+  header.write('0000644\0', 100)
+  header.write(`${data.length.toString(8).padStart(11, '0')}\0`, 124)
+  header.write(type, 156)
+  header.write('ustar\x0000', 257, 'latin1')
+  header.fill(0x20, 148, 156)
+  const sum = header.reduce((a, b) => a + b, 0)
+  header.write(`${sum.toString(8).padStart(6, '0')}\0 `, 148)
+  return Buffer.concat([header, data, Buffer.alloc((512 - data.length % 512) % 512)])
+}
+
+// "<length> key=value\n", the length counting its own digits
+export function paxRecord(key: string, value: string): string {
+  const body = ` ${key}=${value}\n`
+  let length = body.length + 1
+  while (String(length).length + body.length !== length) length++
+  return `${length}${body}`
+}
+
+export function tar(...entries: Buffer[]): Buffer {
+  return Buffer.concat([...entries, Buffer.alloc(1024)])
+}
@@ -1,11 +1,6 @@
 import path from 'path'
 import {spawn} from 'child_process'
-import {extractJSSymbols, isJSCodeAtPosition} from '../../utils/ast-js.js'
-import {
-  extractCSharpSymbols,
-  extractPythonSymbols,
-  isCodeAtPosition
-} from '../../utils/tree-sitter.js'
+import {getLanguageBackend} from '../../utils/language-backends.js'
 import type { RipgrepResult } from './types.js'
 
 export function detectLanguage(filePath: string): string {
@@ -39,9 +34,9 @@ export async function filterReferences(results: RipgrepResult[], workspaceDir: s
   const fileCache = new Map()
 
   for (const res of results) {
-    const lang = detectLanguage(res.path)
+    const backend = getLanguageBackend(detectLanguage(res.path))
     // If we don't support the language for AST filtering, keep the result
-    if (lang === 'text') {
+    if (!backend) {
       filtered.push(res)
       continue
     }
@@ -61,11 +56,7 @@ export async function filterReferences(results: RipgrepResult[], workspaceDir: s
     let isCode = true
 
     try {
-      if (lang === 'javascript' || lang === 'typescript') {
-        isCode = isJSCodeAtPosition(content, res.line, res.column - 1)
-      } else if (['python', 'csharp'].includes(lang)) {
-        isCode = await isCodeAtPosition(content, lang, res.line, res.column - 1)
-      }
+      isCode = await backend.isCodeAtPosition(content, res.line, res.column - 1)
     } catch (e: any) {
       console.error(`Error filtering reference in ${res.path}:`, e)
       // On error, we prefer to keep the result (fail-open)
@@ -79,18 +70,9 @@ export async function filterReferences(results: RipgrepResult[], workspaceDir: s
   return filtered
 }
 
-export async function extractSymbols(filePath, content) {
-  const lang = detectLanguage(filePath)
-  if (lang === 'javascript' || lang === 'typescript') {
-    return extractJSSymbols(content)
-  }
-  if (lang === 'python') {
-    return await extractPythonSymbols(content)
-  }
-  if (lang === 'csharp') {
-    return await extractCSharpSymbols(content)
-  }
-  return []
+export async function extractSymbols(filePath, content, options = {}) {
+  const backend = getLanguageBackend(detectLanguage(filePath))
+  return backend ? backend.extractSymbols(content, options) : []
 }
 
 export function buildTreeText(files) {
@@ -8,7 +8,7 @@ export async function getFileOutline(
   const symbols = await deps.extractSymbols(filePath, content)
 
   const formatted = symbols
-    .filter(s => s.kind !== 'reference')
+    .filter(s => s.kind !== 'reference' && s.kind !== 'diagnostic')
     .map(s => ({
       name: s.name,
       kind: s.kind,
@@ -79,9 +79,15 @@ export type SymbolKind =
     | 'variable' | 'constant' | 'property' | 'field' | 'import' | 'export'
     | 'reference' | 'unity_lifecycle' | 'serialized_field' | 'scriptable_object'
     | 'hook' | 'function_component' | 'accessor' | 'private_field' | 'type'
-    | 'namespace' | 'const' | 'default_export'
+    | 'namespace' | 'const' | 'default_export' | 'local' | 'parameter' | 'todo' | 'string' | 'diagnostic'
+    | 'label' | 'type_parameter' | 'package'
     | 'unknown'
 
+export interface ExtractOptions {
+  locals?: boolean // also extract parameters and local variables with their scopes
+  strings?: number // also extract string, template and regexp literals at least this long
+}
+
 export interface SymbolInfo {
   name: string
   kind: SymbolKind
@@ -103,6 +109,115 @@ export interface TextChunk {
   text: string
 }
 
+// ============================================================================
+// Symbol Index
+// ============================================================================
+
+export interface Location {
+  path: string
+  line: number
+  column?: number
+  end_line?: number
+}
+
+export interface ReferenceLocation extends Location {
+  role: ReferenceRole
+  via?: string // ID of the alias a use of a type goes through (see type-aliases)
+}
+
+export interface TypeParameter {
+  name: string
+  constraint?: string // K extends string / where T : IEntity
+  default?: string
+}
+
+export interface IndexedSymbol {
+  id: string
+  name: string
+  kind: SymbolKind
+  path: string
+  lang: string
+  line: number
+  end_line: number
+  type_params?: TypeParameter[]
+  [key: string]: any
+}
+
+/**
+ * What a reference does with the name; stored only when it is not implied
+ * (a plain read, or a call with `call` set)
+ */
+export type ReferenceRole = 'read' | 'write' | 'call' | 'import' | 'type' | 'implement' | 'address'
+
+export interface SymbolReference extends Location {
+  name: string
+  call?: boolean // reference is the target of a call
+  role?: ReferenceRole // write (x = 1, x++), import, type (a type annotation), implement (extends / implements), address (C# ref / out / &)
+  receiver?: string // object a method is called on ("this", "self", a variable name)
+  type_args?: string[] // type arguments at a generic instantiation (Map<string, number>)
+  test_title?: string // title of the test case this test() / it() call declares
+  test_end_line?: number // last line of that test case
+  property?: boolean // name after a dot (obj.name), recorded with --locals
+  label?: boolean // target of break / continue, recorded with --locals
+  caught?: boolean // call in the protected block of a try with a catch clause
+  source?: string // module a named import is taken from (import { User } from '@org/users')
+  repository?: string // repository of a merged index the reference resolves into (indexer merge)
+}
+
+export interface TodoComment {
+  marker: string // TODO, FIXME, HACK, BUG or XXX
+  text: string // rest of the comment line
+  line: number
+  column: number // 1-based column of the marker
+  assignee?: string // TODO(alice)
+}
+
+/** Non-ASCII characters in a row on a line, each taking `bytes` bytes in UTF-8 (see positions) */
+export interface WideCharRun {
+  line: number
+  column: number // 1-based UTF-16 column of the first
+  count: number
+  bytes: number // 2 to 4; 4 takes two UTF-16 code units
+}
+
+export interface ParseDiagnostic {
+  message: string // parser message, without its position
+  line: number
+  column: number // 1-based
+  analyzer?: string // analyzer that reported it (see analyzers); syntax errors have none
+  rule?: string // the analyzer's rule or check (no-unused-vars, @typescript-eslint/no-deprecated)
+  severity?: DiagnosticSeverity // error when absent
+}
+
+export type DiagnosticSeverity = 'error' | 'warning' | 'info'
+
+export interface IndexedString {
+  value: string // cooked text, a template's ${} holes kept empty; cut at 512 characters
+  kind: 'string' | 'template' | 'regexp'
+  line: number // first occurrence in the file
+  column: number
+  count?: number // occurrences in the file, when more than one
+}
+
+export interface FileShard {
+  path: string
+  lang: string
+  hash?: string
+  symbols: IndexedSymbol[]
+  references: SymbolReference[]
+  todos?: TodoComment[] // marker comments, when the file has any
+  strings?: IndexedString[] // distinct literals, when extracted (--strings)
+  diagnostics?: ParseDiagnostic[] // syntax errors, when the file has any; its symbols are what parsed around them
+  wide_chars?: WideCharRun[] // non-ASCII runs, when the file has any, for converting columns between encodings
+  checksum?: string // SHA-1 of the stored contents, checked on load
+}
+
+export interface FileChange {
+  status: 'added' | 'modified' | 'deleted' | 'renamed'
+  path: string
+  oldPath?: string // previous path of a rename
+}
+
 // ============================================================================
 // Qdrant / Vector Storage
 // ============================================================================
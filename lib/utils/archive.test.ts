@@ -0,0 +1,57 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import zlib from 'zlib'
+import { archiveFormat, readArchiveEntries } from './archive.js'
+import { paxRecord, tar, tarEntry, zip } from '../test-utils.js'
+
+test('archive: zip entries, stored or deflated, directories and unsafe paths skipped', async () => {
+  const files = { 'mod@v1/': '', 'mod@v1/a.go': 'package a\n', 'mod@v1/sub/b.go': 'package sub\n', '../evil.go': 'x' }
+  for (const deflate of [true, false]) {
+    const archive = zip(files, deflate)
+    assert.equal(archiveFormat(archive), 'zip')
+    assert.deepEqual((await readArchiveEntries(archive)).map(e => [e.path, e.data.toString()]), [
+      ['mod@v1/a.go', 'package a\n'],
+      ['mod@v1/sub/b.go', 'package sub\n']
+    ])
+  }
+})
+
+test('archive: tar and tar.gz entries, pax long names, links skipped', async () => {
+  const longName = `pkg/${'deep/'.repeat(30)}main.py`
+  const archive = tar(
+    tarEntry('./pkg/a.py', 'print(1)\n'),
+    tarEntry('pkg/link.py', '', '2'),
+    tarEntry('PaxHeader', paxRecord('path', longName), 'x'),
+    tarEntry('pkg/truncated', 'x = 2\n')
+  )
+  assert.equal(archiveFormat(archive), 'tar')
+  const gz = zlib.gzipSync(archive)
+  assert.equal(archiveFormat(gz), 'tar.gz')
+  for (const data of [archive, gz]) {
+    assert.deepEqual((await readArchiveEntries(data)).map(e => [e.path, e.data.toString()]), [
+      ['pkg/a.py', 'print(1)\n'],
+      [longName, 'x = 2\n']
+    ])
+  }
+  await assert.rejects(readArchiveEntries(Buffer.from('not an archive')), /Not a zip or tar archive/)
+})
+
+test('archive: entries are inflated no further than the limits', async () => {
+  const big = 'x'.repeat(100_000)
+  const limits = { maxEntryBytes: 1000, maxTotalBytes: 10_000 }
+  for (const deflate of [true, false]) {
+    const entries = await readArchiveEntries(zip({ 'big.js': big, 'small.js': 'a\n' }, deflate), limits)
+    assert.deepEqual(entries.map(e => [e.path, e.data.length, e.size]), [['big.js', 1000, 100_000], ['small.js', 2, 2]])
+  }
+  const entries = await readArchiveEntries(tar(tarEntry('big.py', big)), limits)
+  assert.deepEqual(entries.map(e => [e.path, e.data.length, e.size]), [['big.py', 1000, 100_000]])
+
+  // An entry recording a smaller size than it inflates to is cut at that size
+  const lying = zip({ 'bomb.js': big })
+  lying.writeUInt32LE(10, lying.length - 22 - 46 - 'bomb.js'.length + 24)
+  assert.equal((await readArchiveEntries(lying)).at(0)!.data.length, 10)
+
+  const total = { maxEntryBytes: 0, maxTotalBytes: 10_000 }
+  await assert.rejects(readArchiveEntries(zip({ 'big.js': big }), total), /inflates to more than 10000 bytes/)
+  await assert.rejects(readArchiveEntries(zlib.gzipSync(tar(tarEntry('big.py', big))), total), /inflates to more than 10000 bytes/)
+})
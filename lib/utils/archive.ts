@@ -0,0 +1,234 @@
+/**
+ * Archive Reader
+ * Reads the regular files of a zip, tar or gzipped tar archive into memory
+ * without extracting anything to disk. Zip entries may be stored or
+ * deflated; tar archives may use ustar prefixes, pax path records and GNU
+ * long names. Directories, links and device entries are skipped, and so are
+ * entries whose path is absolute or climbs out of the archive with "..".
+ * Compressed data is inflated as a stream that stops at the limits (see
+ * ArchiveLimits), so a zip bomb costs no more memory than they allow.
+ */
+
+import fs from 'fs/promises'
+import zlib from 'zlib'
+
+export type ArchiveFormat = 'zip' | 'tar' | 'tar.gz'
+
+export interface ArchiveEntry {
+  path: string // relative, forward slashes
+  data: Buffer // the start of the entry when it is over maxEntryBytes
+  size: number // bytes of the whole entry
+}
+
+export interface ArchiveLimits {
+  /** Bytes kept of one entry, 0 for no limit; the rest is never inflated */
+  maxEntryBytes: number
+  /** Bytes the kept entries (the whole tar of a .tar.gz) may inflate to, 0 for no limit */
+  maxTotalBytes: number
+}
+
+export const DEFAULT_ARCHIVE_LIMITS: ArchiveLimits = { maxEntryBytes: 0, maxTotalBytes: 1024 * 1024 * 1024 }
+
+const ZIP_LOCAL_HEADER = 0x04034b50
+const ZIP_CENTRAL_HEADER = 0x02014b50
+const ZIP_END_OF_CENTRAL_DIR = 0x06054b50
+// End of central directory record without comment; comments are up to 64 KiB
+const ZIP_EOCD_SIZE = 22
+const ZIP_MAX_COMMENT = 0xffff
+const TAR_BLOCK = 512
+const S_IFMT = 0o170000
+const S_IFREG = 0o100000
+
+/**
+ * Format of an archive, from its first bytes
+ */
+export function archiveFormat(data: Buffer): ArchiveFormat | null {
+  if (data.length >= 4 && data.readUInt32LE(0) === ZIP_LOCAL_HEADER) return 'zip'
+  if (data.length >= 4 && data.readUInt32LE(0) === ZIP_END_OF_CENTRAL_DIR) return 'zip' // empty zip
+  if (data.length >= 2 && data[0] === 0x1f && data[1] === 0x8b) return 'tar.gz'
+  if (data.length >= 263 && data.toString('latin1', 257, 262) === 'ustar') return 'tar'
+  // Pre-POSIX tars have no magic; accept a header whose checksum holds
+  if (data.length >= TAR_BLOCK && tarChecksumOk(data.subarray(0, TAR_BLOCK))) return 'tar'
+  return null
+}
+
+// Entry path as stored in the index, or null when it is unsafe or a directory
+function entryPath(name: string): string | null {
+  const normalized = name.replace(/\\/g, '/').replace(/^(\.\/)+/, '')
+  if (normalized === '' || normalized.endsWith('/') || normalized.startsWith('/') || /^[a-zA-Z]:/.test(normalized)) return null
+  const parts = normalized.split('/').filter(p => p !== '' && p !== '.')
+  if (parts.includes('..')) return null
+  return parts.join('/')
+}
+
+// Inflate raw deflate data (or a gzip stream) up to max bytes, stopping there
+function inflate(compressed: Buffer, max: number, gzip = false): Promise<{ data: Buffer, more: boolean }> {
+  return new Promise((resolve, reject) => {
+    const stream = gzip ? zlib.createGunzip() : zlib.createInflateRaw()
+    const chunks: Buffer[] = []
+    let length = 0
+    stream.on('data', (chunk: Buffer) => {
+      chunks.push(chunk)
+      length += chunk.length
+      if (length > max) {
+        stream.destroy()
+        resolve({ data: Buffer.concat(chunks).subarray(0, max), more: true })
+      }
+    })
+    stream.on('end', () => resolve({ data: Buffer.concat(chunks), more: false }))
+    stream.on('error', reject)
+    stream.end(compressed)
+  })
+}
+
+function overTotal(limits: ArchiveLimits): Error {
+  return new Error(`Archive inflates to more than ${limits.maxTotalBytes} bytes`)
+}
+
+async function readZip(data: Buffer, limits: ArchiveLimits): Promise<ArchiveEntry[]> {
+  let eocd = -1
+  for (let at = data.length - ZIP_EOCD_SIZE; at >= Math.max(0, data.length - ZIP_EOCD_SIZE - ZIP_MAX_COMMENT); at--) {
+    if (data.readUInt32LE(at) === ZIP_END_OF_CENTRAL_DIR) {
+      eocd = at
+      break
+    }
+  }
+  if (eocd === -1) throw new Error('Not a zip archive: no end of central directory')
+  const count = data.readUInt16LE(eocd + 10)
+  let offset = data.readUInt32LE(eocd + 16)
+  if (count === 0xffff || offset === 0xffffffff) throw new Error('ZIP64 archives are not supported')
+
+  const entries: ArchiveEntry[] = []
+  let total = 0
+  for (let i = 0; i < count; i++) {
+    if (offset + 46 > data.length || data.readUInt32LE(offset) !== ZIP_CENTRAL_HEADER) {
+      throw new Error('Corrupt zip archive: bad central directory entry')
+    }
+    const method = data.readUInt16LE(offset + 10)
+    const compressedSize = data.readUInt32LE(offset + 20)
+    const size = data.readUInt32LE(offset + 24)
+    const nameLength = data.readUInt16LE(offset + 28)
+    const extraLength = data.readUInt16LE(offset + 30)
+    const commentLength = data.readUInt16LE(offset + 32)
+    const madeBy = data.readUInt16LE(offset + 4) >> 8
+    const mode = data.readUInt32LE(offset + 38) >>> 16
+    const localOffset = data.readUInt32LE(offset + 42)
+    const name = data.toString('utf8', offset + 46, offset + 46 + nameLength)
+    offset += 46 + nameLength + extraLength + commentLength
+
+    const relPath = entryPath(name)
+    // Unix zips record the file type; skip symlinks and the like
+    if (relPath === null || (madeBy === 3 && mode !== 0 && (mode & S_IFMT) !== S_IFREG)) continue
+    if (data.readUInt32LE(localOffset) !== ZIP_LOCAL_HEADER) throw new Error(`Corrupt zip archive: bad local header for ${name}`)
+    const start = localOffset + 30 + data.readUInt16LE(localOffset + 26) + data.readUInt16LE(localOffset + 28)
+    const raw = data.subarray(start, start + compressedSize)
+    if (method !== 0 && method !== 8) throw new Error(`Unsupported zip compression method ${method} for ${name}`)
+    // Checked against the recorded size before inflating; inflating stops there
+    // too, so an entry that lies about its size is cut short
+    const kept = limits.maxEntryBytes ? Math.min(size, limits.maxEntryBytes) : size
+    total += kept
+    if (limits.maxTotalBytes && total > limits.maxTotalBytes) throw overTotal(limits)
+    const content = method === 0 ? raw.subarray(0, kept) : (await inflate(raw, kept)).data
+    entries.push({ path: relPath, data: content, size })
+  }
+  return entries
+}
+
+function tarChecksumOk(header: Buffer): boolean {
+  const stored = parseInt(header.toString('latin1', 148, 156).replace(/\0.*$/, '').trim(), 8)
+  if (!Number.isFinite(stored)) return false
+  let sum = 0
+  for (let i = 0; i < TAR_BLOCK; i++) sum += i >= 148 && i < 156 ? 0x20 : header[i]
+  return sum === stored
+}
+
+function tarString(header: Buffer, start: number, length: number): string {
+  const field = header.subarray(start, start + length)
+  const end = field.indexOf(0)
+  return field.toString('utf8', 0, end === -1 ? length : end)
+}
+
+function tarSize(header: Buffer): number {
+  // GNU base-256 for sizes past 8 GiB
+  if (header[124] & 0x80) return header.readUIntBE(130, 6)
+  return parseInt(tarString(header, 124, 12).trim() || '0', 8)
+}
+
+// "path" of a pax extended header ("<len> <key>=<value>\n" records)
+function paxPath(data: Buffer): string | undefined {
+  let path: string | undefined
+  let at = 0
+  while (at < data.length) {
+    const space = data.indexOf(0x20, at)
+    if (space === -1) break
+    const length = parseInt(data.toString('latin1', at, space), 10)
+    if (!length) break
+    const record = data.toString('utf8', space + 1, at + length - 1)
+    const eq = record.indexOf('=')
+    if (record.slice(0, eq) === 'path') path = record.slice(eq + 1)
+    at += length
+  }
+  return path
+}
+
+function readTar(data: Buffer, limits: ArchiveLimits): ArchiveEntry[] {
+  const entries: ArchiveEntry[] = []
+  let longName: string | undefined
+  let offset = 0
+  while (offset + TAR_BLOCK <= data.length) {
+    const header = data.subarray(offset, offset + TAR_BLOCK)
+    if (header.every(b => b === 0)) break
+    if (!tarChecksumOk(header)) throw new Error('Corrupt tar archive: bad header checksum')
+    const size = tarSize(header)
+    const type = String.fromCharCode(header[156] || 0x30)
+    const body = data.subarray(offset + TAR_BLOCK, offset + TAR_BLOCK + size)
+    offset += TAR_BLOCK + Math.ceil(size / TAR_BLOCK) * TAR_BLOCK
+
+    if (type === 'x') {
+      longName = paxPath(body) ?? longName
+      continue
+    }
+    if (type === 'L') {
+      longName = body.toString('utf8').replace(/\0+$/, '')
+      continue
+    }
+    const prefix = header.toString('latin1', 257, 262) === 'ustar' ? tarString(header, 345, 155) : ''
+    const name = longName ?? (prefix ? `${prefix}/${tarString(header, 0, 100)}` : tarString(header, 0, 100))
+    longName = undefined
+    // Regular files only ('0', old '\0' and contiguous '7')
+    if (type !== '0' && type !== '7') continue
+    const relPath = entryPath(name)
+    if (relPath !== null) entries.push({ path: relPath, data: limits.maxEntryBytes ? body.subarray(0, limits.maxEntryBytes) : body, size })
+  }
+  return entries
+}
+
+/**
+ * Regular files of an archive held in memory, in archive order; a later
+ * entry for the same path replaces an earlier one
+ * @throws Error when the archive inflates past limits.maxTotalBytes
+ */
+export async function readArchiveEntries(data: Buffer, limits: ArchiveLimits = DEFAULT_ARCHIVE_LIMITS): Promise<ArchiveEntry[]> {
+  const format = archiveFormat(data)
+  if (format === null) throw new Error('Not a zip or tar archive')
+  let entries: ArchiveEntry[]
+  if (format === 'zip') {
+    entries = await readZip(data, limits)
+  } else if (format === 'tar.gz') {
+    const tar = await inflate(data, limits.maxTotalBytes || Infinity, true)
+    if (tar.more) throw overTotal(limits)
+    entries = readTar(tar.data, limits)
+  } else {
+    entries = readTar(data, limits)
+  }
+  const byPath = new Map<string, ArchiveEntry>()
+  for (const entry of entries) byPath.set(entry.path, entry)
+  return [...byPath.values()]
+}
+
+/**
+ * Regular files of an archive on disk
+ */
+export async function readArchive(filePath: string, limits: ArchiveLimits = DEFAULT_ARCHIVE_LIMITS): Promise<ArchiveEntry[]> {
+  return readArchiveEntries(await fs.readFile(filePath), limits)
+}
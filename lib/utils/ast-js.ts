@@ -1,14 +1,47 @@
 import { parse } from '@babel/parser'
 import _traverse from '@babel/traverse'
 import type { Node, NodePath } from '@babel/traverse'
-import type { SymbolInfo } from '../types/index.js'
+import type { ExtractOptions, SymbolInfo } from '../types/index.js'
+import { parseJSDoc } from './doc-comments.js'
+import { fieldTags } from './field-tags.js'
+import { babelTokens, fingerprintTokens } from './fingerprint.js'
+import { babelErrorFacts } from './error-facts.js'
+import { babelLandmarks } from './landmarks.js'
+import type { TagArg, TagSource } from './field-tags.js'
 
 const traverse = (_traverse as any).default || _traverse
 
+// Test runner globals whose calls declare a test case: test('title', fn), bench('title', fn)
+const TEST_CALLEES = new Set(['test', 'it', 'bench'])
+
+type ConstValue = string | number | boolean
+
+// Babel binding kinds indexed as locals; hoisted inner functions stay functions
+const LOCAL_BINDINGS = new Set(['var', 'let', 'const', 'param'])
+
+// TypeScript implements / interface extends clauses (the node type differs across babel versions)
+const HERITAGE_NODES = new Set(['TSExpressionWithTypeArguments', 'TSClassImplements', 'TSInterfaceHeritage'])
+
+// Longer literals are stored cut to this many characters
+const MAX_STRING_LENGTH = 512
+
+/**
+ * Babel error as an extractor entry (kind "diagnostic"), which addFile
+ * stores on the shard
+ */
+function parseDiagnostic(err: any): Partial<SymbolInfo> {
+  return {
+    name: String(err?.message || err).replace(/\s*\(\d+:\d+\)$/, ''),
+    kind: 'diagnostic',
+    line: err?.loc?.line || 1,
+    column: (err?.loc?.column ?? 0) + 1
+  }
+}
+
 /*
   Extract symbols from JS / TS source
 */
-export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
+export function extractJSSymbols(code: string, options: ExtractOptions = {}): Partial<SymbolInfo>[] {
   const symbols: Partial<SymbolInfo>[] = []
 
   function hasJSX(node: any): boolean {
@@ -50,21 +83,435 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
     return names.reverse().join('.')
   }
 
+  function heritageName(expr: any): string | null {
+    if (!expr) return null
+    if (expr.type === 'Identifier') return expr.name
+    if (expr.type === 'MemberExpression' && !expr.computed && expr.property?.type === 'Identifier') {
+      const obj = heritageName(expr.object)
+      return obj ? `${obj}.${expr.property.name}` : expr.property.name
+    }
+    if (expr.type === 'TSQualifiedName') {
+      const left = heritageName(expr.left)
+      return left ? `${left}.${expr.right.name}` : expr.right.name
+    }
+    // TSExpressionWithTypeArguments / TSClassImplements / TSInterfaceHeritage
+    if (expr.expression) return heritageName(expr.expression)
+    return null
+  }
+
+  function heritageNames(list: any[] | null | undefined): string[] {
+    return (list || []).map(heritageName).filter((n): n is string => !!n)
+  }
+
+  function exportWrapper(path: any): any {
+    const parent = path?.parentPath
+    if (parent && (parent.isExportNamedDeclaration() || parent.isExportDefaultDeclaration())) {
+      return parent
+    }
+    return null
+  }
+
+  // JSDoc block or run of line comments directly above a node
+  function docComment(node: any): string | undefined {
+    const comments = node?.leadingComments || []
+    if (comments.length === 0 || !node.loc) return undefined
+    const last = comments[comments.length - 1]
+    if (last.type === 'CommentBlock') {
+      if (!last.value.startsWith('*')) return undefined
+      const text = last.value.replace(/^\*/, '')
+        .split('\n')
+        .map((l: string) => l.replace(/^\s*\* ?/, '').trimEnd())
+        .join('\n')
+        .trim()
+      return text || undefined
+    }
+    const lines: string[] = []
+    let expected = node.loc.start.line - 1
+    for (let i = comments.length - 1; i >= 0; i--) {
+      const c = comments[i]
+      if (c.type !== 'CommentLine' || c.loc.end.line !== expected) break
+      lines.unshift(c.value.replace(/^ /, ''))
+      expected--
+    }
+    const text = lines.join('\n').trim()
+    return text || undefined
+  }
+
+  // Declaration header up to the body, e.g. "function foo(a: string): number"
+  function signatureOf(node: any): string | undefined {
+    const body = node.body
+    if (!body || typeof body.start !== 'number' || typeof node.start !== 'number') return undefined
+    const header = code.slice(node.start, body.start).replace(/\s+/g, ' ').trim()
+    return header || undefined
+  }
+
+  // Header of a function-valued constant, e.g. "save = async (user: User): Promise<void> =>"
+  function arrowSignature(declarator: any): Partial<SymbolInfo> {
+    const init = declarator.init
+    if (!init || (init.type !== 'ArrowFunctionExpression' && init.type !== 'FunctionExpression')) return {}
+    const header = code.slice(declarator.start, init.body.start).replace(/\s+/g, ' ').trim()
+    return header ? { signature: header } : {}
+  }
+
+  // Structural fingerprint of a function body, for duplicate detection
+  function bodyFingerprint(fn: any): Partial<SymbolInfo> {
+    if (!fn?.body || !/Function|Method/.test(fn.type)) return {}
+    return fingerprintTokens(babelTokens(fn.body)) || {}
+  }
+
+  // Error types a function throws and wraps, for the error-path analysis
+  function bodyErrorFacts(fn: any): Partial<SymbolInfo> {
+    if (!fn?.body || !/Function|Method/.test(fn.type)) return {}
+    return babelErrorFacts(fn.body)
+  }
+
+  // Labels, labeled break / continue and handlers in a function body
+  function bodyLandmarks(fn: any): Partial<SymbolInfo> {
+    if (!fn?.body || !/Function|Method/.test(fn.type)) return {}
+    return babelLandmarks(fn.body)
+  }
+
+  // Calls in the protected block of a try with a catch clause, in the same function
+  function caughtInfo(path: any): Partial<SymbolInfo> {
+    const guard = path.findParent((p: any) => p.isFunction() || (p.key === 'block' && p.parentPath?.isTryStatement() && !!p.parentPath.node.handler))
+    return guard && !guard.isFunction() ? { caught: true } : {}
+  }
+
+  function sourceText(node: any): string {
+    return code.slice(node.start, node.end).replace(/\s+/g, ' ').trim()
+  }
+
+  // Type parameters of a generic declaration: <K extends string, V = unknown>
+  function typeParamInfo(node: any): Partial<SymbolInfo> {
+    const params = node?.typeParameters?.params
+    if (!params || params.length === 0) return {}
+    return {
+      type_params: params.map((p: any) => ({
+        name: typeof p.name === 'string' ? p.name : p.name?.name,
+        ...(p.constraint ? { constraint: sourceText(p.constraint) } : {}),
+        ...(p.default ? { default: sourceText(p.default) } : {})
+      }))
+    }
+  }
+
+  // Type arguments at a generic instantiation site: Map<string, number>, new Box<T>(), f<T>()
+  function typeArgInfo(path: any): Partial<SymbolInfo> {
+    const parent = path.parentPath?.node
+    if (!parent) return {}
+    let args: any = null
+    if (parent.type === 'TSTypeReference' && parent.typeName === path.node) args = parent.typeParameters
+    else if ((parent.type === 'CallExpression' || parent.type === 'NewExpression') && parent.callee === path.node) args = parent.typeParameters
+    else if (parent.type === 'ClassDeclaration' && parent.superClass === path.node) args = parent.superTypeParameters
+    else if (parent.expression === path.node && /^TS(ExpressionWithTypeArguments|InterfaceHeritage|ClassImplements)$/.test(parent.type)) args = parent.typeParameters
+    const params = args?.params
+    if (!params || params.length === 0) return {}
+    return { type_args: params.map(sourceText) }
+  }
+
+  // Value of a constant expression: literals, negation, arithmetic and bit
+  // operators, and earlier members of the same enum (by name or Enum.Member)
+  function constantValue(node: any, known: Map<string, ConstValue> = new Map()): ConstValue | undefined {
+    if (!node) return undefined
+    switch (node.type) {
+      case 'NumericLiteral':
+      case 'StringLiteral':
+      case 'BooleanLiteral':
+        return node.value
+      case 'TemplateLiteral':
+        return node.expressions.length === 0 ? node.quasis[0].value.cooked : undefined
+      case 'TSLiteralType':
+        return constantValue(node.literal, known)
+      case 'TSAsExpression':
+      case 'TSSatisfiesExpression':
+      case 'ParenthesizedExpression':
+        return constantValue(node.expression, known)
+      case 'Identifier':
+        return known.get(node.name)
+      case 'MemberExpression':
+        return !node.computed && node.property.type === 'Identifier' ? known.get(node.property.name) : undefined
+      case 'UnaryExpression': {
+        const v = constantValue(node.argument, known)
+        if (typeof v !== 'number') return undefined
+        return node.operator === '-' ? -v : node.operator === '+' ? v : node.operator === '~' ? ~v : undefined
+      }
+      case 'BinaryExpression': {
+        const a = constantValue(node.left, known)
+        const b = constantValue(node.right, known)
+        if (node.operator === '+' && (typeof a === 'string' || typeof b === 'string') && a !== undefined && b !== undefined) {
+          return String(a) + String(b)
+        }
+        if (typeof a !== 'number' || typeof b !== 'number') return undefined
+        switch (node.operator) {
+          case '+': return a + b
+          case '-': return a - b
+          case '*': return a * b
+          case '/': return a / b
+          case '%': return a % b
+          case '**': return a ** b
+          case '|': return a | b
+          case '&': return a & b
+          case '^': return a ^ b
+          case '<<': return a << b
+          case '>>': return a >> b
+          case '>>>': return a >>> b
+        }
+      }
+    }
+    return undefined
+  }
+
+  // Type named by an annotation or `satisfies`: const Active: Status = ...
+  function namedType(typeNode: any): string | undefined {
+    const t = typeNode?.type === 'TSTypeAnnotation' ? typeNode.typeAnnotation : typeNode
+    return t?.type === 'TSTypeReference' && !t.typeParameters ? heritageName(t.typeName) || undefined : undefined
+  }
+
+  // Members of a union of literal types: 'active' | 'inactive'
+  function literalUnion(typeNode: any): ConstValue[] | undefined {
+    if (typeNode?.type !== 'TSUnionType') return undefined
+    const values = typeNode.types.map((t: any) => (t.type === 'TSLiteralType' ? constantValue(t) : undefined))
+    return values.every((v: ConstValue | undefined) => v !== undefined) ? values : undefined
+  }
+
+  // Decorator argument as a tag argument: constants, and objects of constants
+  function tagArg(node: any): TagArg {
+    if (node?.type === 'ObjectExpression') {
+      const options: Record<string, ConstValue | null> = {}
+      for (const prop of node.properties) {
+        if (prop.type !== 'ObjectProperty' || prop.computed) continue
+        const key = prop.key.type === 'Identifier' ? prop.key.name : prop.key.value
+        if (typeof key === 'string') options[key] = constantValue(prop.value) ?? null
+      }
+      return options
+    }
+    return constantValue(node) ?? null
+  }
+
+  // Decorators of a class member: @Column({ name: 'user_id' }) -> Column
+  function decoratorSources(node: any): TagSource[] {
+    return (node.decorators || []).flatMap((d: any) => {
+      const call = d.expression.type === 'CallExpression' ? d.expression : null
+      const name = heritageName(call ? call.callee : d.expression)
+      return name ? [{ name, args: call ? call.arguments.map(tagArg) : [] }] : []
+    })
+  }
+
+  // Exported flag, doc comment, signature and name column of a declaration
+  function declInfo(path: any, id: any): Partial<SymbolInfo> {
+    const member = path.isClassMethod() || path.isClassProperty() ||
+      path.isClassAccessorProperty() || path.isClassPrivateProperty()
+    const owner = member ? path.findParent((p: NodePath<any>) => p.isClassDeclaration()) : path
+    const wrapper = exportWrapper(owner)
+    const hidden = member && (
+      path.isClassPrivateProperty() ||
+      path.node.accessibility === 'private' ||
+      path.node.accessibility === 'protected'
+    )
+    const info: Partial<SymbolInfo> = { exported: !!wrapper && !hidden }
+    const doc = docComment(member ? path.node : (wrapper || owner).node)
+    if (doc) {
+      info.doc = doc
+      info.doc_info = parseJSDoc(doc)
+    }
+    const signature = signatureOf(path.node)
+    if (signature) info.signature = signature
+    if (id?.loc) info.column = id.loc.start.column + 1
+    return { ...info, ...typeParamInfo(path.node), ...bodyFingerprint(path.node), ...bodyErrorFacts(path.node), ...bodyLandmarks(path.node) }
+  }
+
+  // True when the node is what a call or `new` expression invokes
+  function isCallee(path: any): boolean {
+    const parent = path.parentPath
+    return !!parent && (parent.isCallExpression() || parent.isNewExpression()) && parent.node.callee === path.node
+  }
+
+  // `x = 1`, `x += 1`, `x++`, `[x] = pair`, `({ x } = obj)`, `for (x of xs)`
+  function isWriteTarget(path: any): boolean {
+    let child = path
+    let parent = path.parentPath
+    while (parent && (parent.isArrayPattern() || parent.isObjectPattern() || parent.isRestElement() ||
+        (parent.isObjectProperty() && parent.node.value === child.node) ||
+        (parent.isAssignmentPattern() && parent.node.left === child.node))) {
+      child = parent
+      parent = parent.parentPath
+    }
+    if (!parent) return false
+    if (parent.isAssignmentExpression() || parent.isForOfStatement() || parent.isForInStatement()) return parent.node.left === child.node
+    return parent.isUpdateExpression()
+  }
+
+  // What a name (an identifier or obj.name) does besides being read: written,
+  // named as a type, or extended / implemented
+  function roleInfo(path: any): Partial<SymbolInfo> {
+    if (isWriteTarget(path)) return { role: 'write' }
+    let child = path
+    let parent = path.parentPath
+    while (parent?.isTSQualifiedName()) {
+      child = parent
+      parent = parent.parentPath
+    }
+    if (!parent) return {}
+    const node = parent.node
+    if (HERITAGE_NODES.has(node.type) || ((node.type === 'ClassDeclaration' || node.type === 'ClassExpression') && node.superClass === child.node)) {
+      return { role: 'implement' }
+    }
+    return node.type === 'TSTypeReference' ? { role: 'type' } : {}
+  }
+
+  // Title and extent of a test case when the node calls test() / it()
+  function testCaseInfo(path: any): Partial<SymbolInfo> {
+    if (!TEST_CALLEES.has(path.node.name) || !isCallee(path)) return {}
+    const call = path.parentPath.node
+    const title = call.arguments[0]
+    let text: string | undefined
+    if (title?.type === 'StringLiteral') text = title.value
+    else if (title?.type === 'TemplateLiteral' && title.expressions.length === 0) text = title.quasis[0].value.cooked
+    if (text === undefined || !call.loc) return {}
+    return { test_title: text, test_end_line: call.loc.end.line }
+  }
+
+  function receiverOf(object: any): Partial<SymbolInfo> {
+    if (object?.type === 'ThisExpression') return { receiver: 'this' }
+    if (object?.type === 'Identifier') return { receiver: object.name }
+    return {}
+  }
+
+  function interfaceMembers(body: any): string[] {
+    const members: string[] = []
+    for (const m of body?.body || []) {
+      if ((m.type === 'TSMethodSignature' || m.type === 'TSPropertySignature') && m.key?.type === 'Identifier') {
+        members.push(m.key.name)
+      }
+    }
+    return members
+  }
+
+  // Binding declared inside a function (parameters, let / const / var, catch
+  // clauses), which --locals indexes with its scope
+  function localBinding(path: any, name: string): any {
+    const binding = path.scope.getBinding(name)
+    if (!binding || !LOCAL_BINDINGS.has(binding.kind) || !binding.scope.getFunctionParent()) return null
+    return binding
+  }
+
+  // Name of the function a scope belongs to: "User.save", "handler", "<anonymous>"
+  function scopeOwner(scope: any): string {
+    const fn = scope.getFunctionParent()?.path
+    if (!fn) return '<anonymous>'
+    if (fn.node.id?.name) return fn.node.id.name
+    if ((fn.isClassMethod() || fn.isObjectMethod()) && fn.node.key?.type === 'Identifier') {
+      const cls = fn.isClassMethod() ? fn.findParent((p: NodePath<any>) => p.isClassDeclaration()) : null
+      return cls?.node.id ? `${cls.node.id.name}.${fn.node.key.name}` : fn.node.key.name
+    }
+    const parent = fn.parentPath?.node
+    if (parent?.type === 'VariableDeclarator' && parent.id.type === 'Identifier') return parent.id.name
+    if (parent?.type === 'ObjectProperty' && parent.key.type === 'Identifier') return parent.key.name
+    return '<anonymous>'
+  }
+
+  // Extent of the node a scoped name is visible in
+  function scopeExtent(node: any): Partial<SymbolInfo> {
+    return {
+      scope_line: node.loc.start.line,
+      scope_column: node.loc.start.column + 1,
+      scope_end_line: node.loc.end.line,
+      scope_end_column: node.loc.end.column + 1
+    }
+  }
+
+  // A parameter or local variable and the extent of the scope it is visible in
+  function localSymbol(binding: any): Partial<SymbolInfo> | null {
+    const id = binding.identifier
+    const block = binding.scope.block
+    if (!id.loc || !block.loc) return null
+    return {
+      name: id.name,
+      kind: binding.kind === 'param' ? 'parameter' : 'local',
+      line: id.loc.start.line,
+      end_line: id.loc.end.line,
+      column: id.loc.start.column + 1,
+      scope: scopeOwner(binding.scope),
+      ...scopeExtent(block)
+    }
+  }
+
+  // Declaration a type parameter list belongs to: "Box", "Box.map", "identity"
+  function typeParamOwner(decl: any): string {
+    if (decl.isFunction()) return scopeOwner(decl.scope)
+    if (decl.node.id?.name) return decl.node.id.name
+    if (decl.node.key?.type === 'Identifier') {
+      const owner = decl.findParent((p: NodePath<any>) => p.isTSInterfaceDeclaration() || p.isClassDeclaration())
+      return owner?.node.id ? `${owner.node.id.name}.${decl.node.key.name}` : decl.node.key.name
+    }
+    return scopeOwner(decl.scope)
+  }
+
+  // The label of break outer / continue outer, which only a label can bind
+  function labelReference(path: NodePath<any>) {
+    const label = path.node.label
+    if (!options.locals || !label?.loc) return
+    symbols.push({
+      name: label.name,
+      kind: 'reference',
+      line: label.loc.start.line,
+      end_line: label.loc.end.line,
+      column: label.loc.start.column + 1,
+      label: true
+    })
+  }
+
+  // One entry per distinct literal of the file, counting its repeats
+  const literals = new Map<string, Partial<SymbolInfo>>()
+  function literal(node: any, value: string, kind: 'string' | 'template' | 'regexp') {
+    if (!options.strings || value.length < options.strings || !node.loc) return
+    value = value.slice(0, MAX_STRING_LENGTH)
+    const key = `${kind}:${value}`
+    const seen = literals.get(key)
+    if (seen) {
+      seen.count = (seen.count || 1) + 1
+      return
+    }
+    const entry: Partial<SymbolInfo> = {
+      name: value,
+      kind: 'string',
+      literal: kind,
+      line: node.loc.start.line,
+      end_line: node.loc.end.line,
+      column: node.loc.start.column + 1
+    }
+    literals.set(key, entry)
+    symbols.push(entry)
+  }
+
+  // Strings that name code rather than hold data: module specifiers, quoted keys, literal types
+  function isNameString(path: any): boolean {
+    const parent = path.parentPath?.node
+    if (!parent) return false
+    if (parent.source === path.node || parent.key === path.node && !parent.computed) return true
+    return parent.type === 'TSLiteralType' || parent.type === 'TSModuleDeclaration' || parent.type === 'TSExternalModuleReference' ||
+      (parent.type === 'TSEnumMember' && parent.id === path.node)
+  }
+
+  // Errors the parser recovers from leave a partial AST, which is indexed as usual
   let ast: Node | null = null
   try {
     ast = parse(code, {
       sourceType: 'module',
+      errorRecovery: true,
       plugins: [
         'typescript',
         'jsx',
         'classProperties',
         'classPrivateProperties',
-        'classPrivateMethods'
+        'classPrivateMethods',
+        'decorators-legacy'
       ]
     })
-  } catch {
+  } catch (err) {
+    symbols.push(parseDiagnostic(err))
     return symbols
   }
+  for (const err of (ast as any).errors || []) symbols.push(parseDiagnostic(err))
 
   traverse(ast, {
     FunctionDeclaration(path: NodePath<any>) {
@@ -75,7 +522,8 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
           name,
           kind: 'hook',
           line: path.node.loc.start.line,
-          end_line: path.node.loc.end.line
+          end_line: path.node.loc.end.line,
+          ...declInfo(path, path.node.id)
         })
         return
       }
@@ -84,7 +532,8 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
           name,
           kind: 'function_component',
           line: path.node.loc.start.line,
-          end_line: path.node.loc.end.line
+          end_line: path.node.loc.end.line,
+          ...declInfo(path, path.node.id)
         })
         return
       }
@@ -92,18 +541,25 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
         name,
         kind: 'function',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...declInfo(path, path.node.id)
       })
     },
 
     ClassDeclaration(path: NodePath<any>) {
       if (!path.node.id || !path.node.loc) return
-      symbols.push({
+      const sym: Partial<SymbolInfo> = {
         name: path.node.id.name,
         kind: 'class',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
-      })
+        end_line: path.node.loc.end.line,
+        ...declInfo(path, path.node.id)
+      }
+      const superName = heritageName(path.node.superClass)
+      if (superName) sym.extends = [superName]
+      const implemented = heritageNames(path.node.implements)
+      if (implemented.length > 0) sym.implements = implemented
+      symbols.push(sym)
     },
 
     ClassMethod(path: NodePath<any>) {
@@ -117,7 +573,8 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
         name: `${(cls.node as any).id.name}.${path.node.key.name}`,
         kind: isAccessor ? 'accessor' : 'method',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...declInfo(path, path.node.key)
       })
     },
 
@@ -127,11 +584,14 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
       if (!cls || !(cls.node as any)?.id || !path.node.key || path.node.key.type !== 'Identifier') {
         return
       }
+      const tags = fieldTags(decoratorSources(path.node), path.node.key.name, 'typescript')
       symbols.push({
         name: `${(cls.node as any).id.name}.${path.node.key.name}`,
         kind: 'property',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...declInfo(path, path.node.key),
+        ...(tags ? { tags } : {})
       })
     },
 
@@ -141,11 +601,14 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
       if (!cls || !(cls.node as any)?.id || !path.node.key || path.node.key.type !== 'Identifier') {
         return
       }
+      const tags = fieldTags(decoratorSources(path.node), path.node.key.name, 'typescript')
       symbols.push({
         name: `${(cls.node as any).id.name}.${path.node.key.name}`,
         kind: 'accessor',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...declInfo(path, path.node.key),
+        ...(tags ? { tags } : {})
       })
     },
 
@@ -160,38 +623,75 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
         name: `${(cls.node as any).id.name}.#${privateName}`,
         kind: 'private_field',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...declInfo(path, path.node.key)
       })
     },
 
     TSInterfaceDeclaration(path: NodePath<any>) {
       if (!path.node.id || !path.node.loc) return
-      symbols.push({
+      const sym: Partial<SymbolInfo> = {
         name: path.node.id.name,
         kind: 'interface',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
-      })
+        end_line: path.node.loc.end.line,
+        members: interfaceMembers(path.node.body),
+        ...declInfo(path, path.node.id)
+      }
+      const extended = heritageNames(path.node.extends)
+      if (extended.length > 0) sym.extends = extended
+      symbols.push(sym)
     },
 
     TSTypeAliasDeclaration(path: NodePath<any>) {
       if (!path.node.id || !path.node.loc) return
+      const values = literalUnion(path.node.typeAnnotation)
+      // type Foo = bar.Baz names another type; Map<string, User> names Map
+      const aliased = path.node.typeAnnotation?.type === 'TSTypeReference' ? heritageName(path.node.typeAnnotation.typeName) : null
       symbols.push({
         name: path.node.id.name,
         kind: 'type',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...(values ? { literal_values: values } : {}),
+        ...(aliased ? { alias_of: aliased } : {}),
+        ...declInfo(path, path.node.id)
       })
     },
 
     TSEnumDeclaration(path: NodePath<any>) {
       if (!path.node.id || !path.node.loc) return
+      const enumName = path.node.id.name
+      const info = declInfo(path, path.node.id)
       symbols.push({
-        name: path.node.id.name,
+        name: enumName,
         kind: 'enum',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...info
       })
+
+      // Members without an initializer count up from the previous numeric one, like iota
+      const known = new Map<string, ConstValue>()
+      let next: number | undefined = 0
+      for (const member of path.node.members) {
+        const memberName = member.id.type === 'Identifier' ? member.id.name : member.id.value
+        if (!memberName || !member.loc) continue
+        const value = member.initializer ? constantValue(member.initializer, known) : next
+        if (value !== undefined) known.set(memberName, value)
+        next = typeof value === 'number' ? value + 1 : undefined
+        const doc = docComment(member)
+        symbols.push({
+          name: `${enumName}.${memberName}`,
+          kind: 'constant',
+          line: member.loc.start.line,
+          end_line: member.loc.end.line,
+          column: member.id.loc.start.column + 1,
+          exported: info.exported,
+          ...(doc ? { doc, doc_info: parseJSDoc(doc) } : {}),
+          ...(value !== undefined ? { const_value: value } : {})
+        })
+      }
     },
 
     TSModuleDeclaration(path: NodePath<any>) {
@@ -204,10 +704,50 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
         name,
         kind: 'namespace',
         line: path.node.loc.start.line,
-        end_line: path.node.loc.end.line
+        end_line: path.node.loc.end.line,
+        ...declInfo(path, path.node.id)
+      })
+    },
+
+    // Type parameters are visible in the whole declaration that lists them
+    TSTypeParameterDeclaration(path: NodePath<any>) {
+      const decl = path.parentPath
+      if (!options.locals || !decl?.node.loc) return
+      const scope = typeParamOwner(decl)
+      for (const param of path.node.params) {
+        const name = typeof param.name === 'string' ? param.name : param.name?.name
+        if (!name || !param.loc) continue
+        symbols.push({
+          name,
+          kind: 'type_parameter',
+          line: param.loc.start.line,
+          end_line: param.loc.end.line,
+          column: param.loc.start.column + 1,
+          scope,
+          ...scopeExtent(decl.node),
+          ...(param.constraint ? { constraint: sourceText(param.constraint) } : {})
+        })
+      }
+    },
+
+    LabeledStatement(path: NodePath<any>) {
+      const label = path.node.label
+      if (!options.locals || !label.loc || !path.node.loc) return
+      symbols.push({
+        name: label.name,
+        kind: 'label',
+        line: label.loc.start.line,
+        end_line: label.loc.end.line,
+        column: label.loc.start.column + 1,
+        scope: scopeOwner(path.scope),
+        ...scopeExtent(path.node)
       })
     },
 
+    BreakStatement: labelReference,
+
+    ContinueStatement: labelReference,
+
     VariableDeclaration(path: NodePath<any>) {
       if (path.node.kind !== 'const' || !path.node.loc) {
         return
@@ -217,6 +757,8 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
           continue
         }
         const name = declarator.id.name
+        // Indexed as a local with its scope instead
+        if (options.locals && localBinding(path, name)) continue
         const init = declarator.init
         if (init && (init.type === 'ArrowFunctionExpression' || init.type === 'FunctionExpression')) {
           if (hasJSX(init.body) && /^[A-Z]/.test(name)) {
@@ -224,7 +766,13 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
               name,
               kind: 'function_component',
               line: path.node.loc.start.line,
-              end_line: path.node.loc.end.line
+              end_line: path.node.loc.end.line,
+              ...declInfo(path, declarator.id),
+              ...arrowSignature(declarator),
+              ...bodyFingerprint(init),
+              ...bodyErrorFacts(init),
+              ...bodyLandmarks(init),
+              ...typeParamInfo(init)
             })
             continue
           }
@@ -233,16 +781,34 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
               name,
               kind: 'hook',
               line: path.node.loc.start.line,
-              end_line: path.node.loc.end.line
+              end_line: path.node.loc.end.line,
+              ...declInfo(path, declarator.id),
+              ...arrowSignature(declarator),
+              ...bodyFingerprint(init),
+              ...bodyErrorFacts(init),
+              ...bodyLandmarks(init),
+              ...typeParamInfo(init)
             })
             continue
           }
         }
+        // Typed constants join the value set of their type: const Active: Status = 'active'
+        const valueType = namedType(declarator.id.typeAnnotation) ||
+          (init?.type === 'TSSatisfiesExpression' ? namedType(init.typeAnnotation) : undefined)
+        const value = valueType ? constantValue(init) : undefined
         symbols.push({
           name,
           kind: 'const',
           line: path.node.loc.start.line,
-          end_line: path.node.loc.end.line
+          end_line: path.node.loc.end.line,
+          ...(valueType ? { value_type: valueType } : {}),
+          ...(value !== undefined ? { const_value: value } : {}),
+          ...declInfo(path, declarator.id),
+          ...arrowSignature(declarator),
+          ...bodyFingerprint(init),
+          ...bodyErrorFacts(init),
+          ...bodyLandmarks(init),
+          ...typeParamInfo(init)
         })
       }
     },
@@ -265,15 +831,26 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
     },
 
     Identifier(path: NodePath<any>) {
-      if (path.isReferencedIdentifier() && path.node.loc) {
+      // Assignment targets are not "referenced" to babel, but they are uses
+      if ((path.isReferencedIdentifier() || isWriteTarget(path)) && path.node.loc) {
         symbols.push({
           name: path.node.name,
           kind: 'reference',
           line: path.node.loc.start.line,
           end_line: path.node.loc.end.line,
-          column: path.node.loc.start.column + 1
+          column: path.node.loc.start.column + 1,
+          ...(isCallee(path) ? { call: true, ...caughtInfo(path) } : {}),
+          ...roleInfo(path),
+          ...typeArgInfo(path),
+          ...testCaseInfo(path)
         })
+        return
       }
+      if (!options.locals || !path.isBindingIdentifier()) return
+      const binding = localBinding(path, path.node.name)
+      if (!binding || binding.identifier !== path.node) return
+      const local = localSymbol(binding)
+      if (local) symbols.push(local)
     },
 
     MemberExpression(path: NodePath<any>) {
@@ -283,7 +860,41 @@ export function extractJSSymbols(code: string): Partial<SymbolInfo>[] {
           kind: 'reference',
           line: path.node.property.loc.start.line,
           end_line: path.node.property.loc.end.line,
-          column: path.node.property.loc.start.column + 1
+          column: path.node.property.loc.start.column + 1,
+          ...(isCallee(path) ? { call: true, ...receiverOf(path.node.object), ...typeArgInfo(path), ...caughtInfo(path) } : {}),
+          ...roleInfo(path),
+          // obj.total never names a local total; only scope resolution needs to know
+          ...(options.locals && !path.node.computed ? { property: true } : {})
+        })
+      }
+    },
+
+    StringLiteral(path: NodePath<any>) {
+      if (options.strings && !isNameString(path)) literal(path.node, path.node.value, 'string')
+    },
+
+    TemplateLiteral(path: NodePath<any>) {
+      if (!options.strings) return
+      const text = path.node.quasis.map((q: any) => q.value.cooked ?? q.value.raw).join('${}')
+      literal(path.node, text, 'template')
+    },
+
+    RegExpLiteral(path: NodePath<any>) {
+      if (options.strings) literal(path.node, `/${path.node.pattern}/${path.node.flags}`, 'regexp')
+    },
+
+    // `import { User }` names the exported symbol; renaming User edits it too
+    ImportSpecifier(path: NodePath<any>) {
+      const imported = path.node.imported
+      if (imported?.type === 'Identifier' && imported.loc) {
+        symbols.push({
+          name: imported.name,
+          kind: 'reference',
+          line: imported.loc.start.line,
+          end_line: imported.loc.end.line,
+          column: imported.loc.start.column + 1,
+          role: 'import',
+          ...(typeof path.parent?.source?.value === 'string' ? { source: path.parent.source.value } : {})
         })
       }
     }
@@ -302,7 +913,8 @@ export function isJSCodeAtPosition(code: string, line: number, column: number):
         'jsx',
         'classProperties',
         'classPrivateProperties',
-        'classPrivateMethods'
+        'classPrivateMethods',
+        'decorators-legacy'
       ]
     })
   } catch {
@@ -375,6 +987,7 @@ export function extractImports(code: string): ImportInfo[] {
         'classProperties',
         'classPrivateProperties',
         'classPrivateMethods',
+        'decorators-legacy',
         'dynamicImport'
       ]
     })
@@ -0,0 +1,113 @@
+/**
+ * AWS Signature Version 4
+ * Signs HTTP requests to AWS services (the index registry's S3 buckets)
+ * with an access key, as the AWS SDKs do, without depending on them.
+ * Credentials come from the standard environment variables
+ * (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN for
+ * temporary ones) and the region from AWS_REGION or AWS_DEFAULT_REGION.
+ * Every header given is signed along with the host, the date and the
+ * payload hash.
+ */
+
+import crypto from 'crypto'
+
+export interface AwsCredentials {
+  accessKeyId: string
+  secretAccessKey: string
+  sessionToken?: string
+}
+
+export interface SignableRequest {
+  method: string
+  url: string
+  headers?: Record<string, string>
+  body?: Buffer | string
+}
+
+export interface SigningOptions {
+  credentials: AwsCredentials
+  region: string
+  service: string // s3, ...
+  date?: Date // signing time; now by default
+}
+
+const ALGORITHM = 'AWS4-HMAC-SHA256'
+
+/**
+ * Credentials in the environment, or null when none are set
+ */
+export function awsCredentialsFromEnv(env: NodeJS.ProcessEnv = process.env): AwsCredentials | null {
+  const accessKeyId = env.AWS_ACCESS_KEY_ID
+  const secretAccessKey = env.AWS_SECRET_ACCESS_KEY
+  if (!accessKeyId || !secretAccessKey) return null
+  return { accessKeyId, secretAccessKey, ...(env.AWS_SESSION_TOKEN ? { sessionToken: env.AWS_SESSION_TOKEN } : {}) }
+}
+
+export function awsRegionFromEnv(env: NodeJS.ProcessEnv = process.env): string {
+  return env.AWS_REGION || env.AWS_DEFAULT_REGION || 'us-east-1'
+}
+
+function sha256(data: Buffer | string): string {
+  return crypto.createHash('sha256').update(data).digest('hex')
+}
+
+function hmac(key: Buffer | string, data: string): Buffer {
+  return crypto.createHmac('sha256', key).update(data).digest()
+}
+
+// RFC 3986 encoding: everything but A-Z a-z 0-9 - _ . ~
+function uriEncode(value: string): string {
+  return encodeURIComponent(value).replace(/[!'()*]/g, c => `%${c.charCodeAt(0).toString(16).toUpperCase()}`)
+}
+
+// Path segments encoded once, whether or not the URL already encoded them
+function canonicalPath(pathname: string): string {
+  return pathname.split('/').map(segment => uriEncode(decodeURIComponent(segment))).join('/') || '/'
+}
+
+function canonicalQuery(params: URLSearchParams): string {
+  return Array.from(params)
+    .map(([key, value]) => [uriEncode(key), uriEncode(value)])
+    .sort(([a, x], [b, y]) => (a === b ? (x < y ? -1 : x > y ? 1 : 0) : a < b ? -1 : 1))
+    .map(([key, value]) => `${key}=${value}`)
+    .join('&')
+}
+
+/**
+ * Headers to send a request with: the given ones plus X-Amz-Date,
+ * X-Amz-Content-Sha256, X-Amz-Security-Token (for temporary credentials)
+ * and Authorization. Host is signed but left for the HTTP client to set.
+ */
+export function signRequest(request: SignableRequest, options: SigningOptions): Record<string, string> {
+  const url = new URL(request.url)
+  const amzDate = (options.date || new Date()).toISOString().replace(/[-:]/g, '').replace(/\.\d{3}/, '')
+  const day = amzDate.slice(0, 8)
+  const scope = `${day}/${options.region}/${options.service}/aws4_request`
+  const { credentials } = options
+
+  const headers: Record<string, string> = {
+    ...request.headers,
+    'x-amz-date': amzDate,
+    'x-amz-content-sha256': sha256(request.body ?? ''),
+    ...(credentials.sessionToken ? { 'x-amz-security-token': credentials.sessionToken } : {})
+  }
+  const signed = new Map<string, string>([['host', url.host]])
+  for (const [name, value] of Object.entries(headers)) signed.set(name.toLowerCase(), value.trim().replace(/\s+/g, ' '))
+  const names = Array.from(signed.keys()).sort()
+  const signedHeaders = names.join(';')
+
+  const canonicalRequest = [
+    request.method.toUpperCase(),
+    canonicalPath(url.pathname),
+    canonicalQuery(url.searchParams),
+    names.map(name => `${name}:${signed.get(name)}\n`).join(''),
+    signedHeaders,
+    headers['x-amz-content-sha256']
+  ].join('\n')
+  const stringToSign = [ALGORITHM, amzDate, scope, sha256(canonicalRequest)].join('\n')
+
+  const key = hmac(hmac(hmac(hmac(`AWS4${credentials.secretAccessKey}`, day), options.region), options.service), 'aws4_request')
+  const signature = hmac(key, stringToSign).toString('hex')
+  headers.authorization = `${ALGORITHM} Credential=${credentials.accessKeyId}/${scope}, SignedHeaders=${signedHeaders}, Signature=${signature}`
+  return headers
+}
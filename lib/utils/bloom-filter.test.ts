@@ -0,0 +1,26 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { BloomFilter, mayHaveName, mayHaveNameContaining, nameFilter } from './bloom-filter.js'
+
+test('bloom-filter: no false negatives, few false positives, round-trips', () => {
+  const filter = BloomFilter.forKeys(1000)
+  for (let i = 0; i < 1000; i++) filter.add(`key${i}`)
+  for (let i = 0; i < 1000; i++) assert.ok(filter.mightContain(`key${i}`))
+  let falsePositives = 0
+  for (let i = 0; i < 10000; i++) if (filter.mightContain(`other${i}`)) falsePositives++
+  assert.ok(falsePositives < 300, `${falsePositives} false positives`)
+
+  const decoded = BloomFilter.decode(filter.encode())!
+  for (let i = 0; i < 1000; i++) assert.ok(decoded.mightContain(`key${i}`))
+  assert.equal(BloomFilter.decode(Buffer.from([0, 0xff])), null)
+})
+
+test('bloom-filter: name filters answer exact names and case-folded substrings', () => {
+  const filter = nameFilter(['InvoiceTotal', 'render'])
+  assert.ok(mayHaveName(filter, 'InvoiceTotal'))
+  assert.ok(mayHaveNameContaining(filter, 'invoicet'))
+  assert.ok(mayHaveNameContaining(filter, 'ND')) // too short to rule out
+  assert.equal(mayHaveName(filter, 'Zygomorphic'), false)
+  assert.equal(mayHaveNameContaining(filter, 'zygomorph'), false)
+  assert.ok(mayHaveNameContaining(filter, 'invoice') && !mayHaveNameContaining(nameFilter(['InvoiceTotal'], false), 'invoice'))
+})
@@ -0,0 +1,115 @@
+/**
+ * Bloom Filter Module
+ * Compact set membership with false positives but no false negatives, kept
+ * beside stored shards so a lookup can skip any shard whose filter says a
+ * name is definitely absent. Name filters hold each short name exactly and
+ * the case-folded trigrams of every name, for substring searches.
+ *
+ * Encoding: hash count u8, then the bit array (bit i is byte i >> 3, bit i & 7).
+ */
+
+// Bits per key sized for this false positive rate
+const FALSE_POSITIVE_RATE = 0.01
+const MAX_HASHES = 16
+// Trigram keys start with a character no symbol name contains
+const TRIGRAM_MARK = '\0'
+
+// FNV-1a over UTF-16 code units
+function fnv1a(key: string, seed: number): number {
+  let hash = seed
+  for (let i = 0; i < key.length; i++) {
+    hash ^= key.charCodeAt(i)
+    hash = Math.imul(hash, 0x01000193)
+  }
+  return hash >>> 0
+}
+
+export class BloomFilter {
+  private constructor(private readonly bits: Uint8Array, private readonly hashes: number) {}
+
+  /**
+   * Empty filter sized for a number of keys
+   */
+  static forKeys(count: number, falsePositiveRate = FALSE_POSITIVE_RATE): BloomFilter {
+    const n = Math.max(1, count)
+    const bitCount = Math.max(8, Math.ceil(-n * Math.log(falsePositiveRate) / (Math.LN2 * Math.LN2)))
+    const hashes = Math.min(MAX_HASHES, Math.max(1, Math.round(bitCount / n * Math.LN2)))
+    return new BloomFilter(new Uint8Array(Math.ceil(bitCount / 8)), hashes)
+  }
+
+  /**
+   * Filter from its encoding, or null if the data is not one
+   */
+  static decode(data: Uint8Array): BloomFilter | null {
+    if (data.length < 2 || data[0] < 1 || data[0] > MAX_HASHES) return null
+    return new BloomFilter(Uint8Array.from(data.subarray(1)), data[0])
+  }
+
+  // Double hashing: bit positions h1 + i * h2
+  private positions(key: string): number[] {
+    const size = this.bits.length * 8
+    const h1 = fnv1a(key, 0x811c9dc5)
+    const h2 = fnv1a(key, 0x050c5d1f) | 1
+    const result: number[] = []
+    for (let i = 0; i < this.hashes; i++) result.push((h1 + Math.imul(i, h2) >>> 0) % size)
+    return result
+  }
+
+  add(key: string): void {
+    for (const bit of this.positions(key)) this.bits[bit >> 3] |= 1 << (bit & 7)
+  }
+
+  /**
+   * False only if the key was never added
+   */
+  mightContain(key: string): boolean {
+    return this.positions(key).every(bit => (this.bits[bit >> 3] & (1 << (bit & 7))) !== 0)
+  }
+
+  encode(): Buffer {
+    const data = Buffer.alloc(1 + this.bits.length)
+    data[0] = this.hashes
+    data.set(this.bits, 1)
+    return data
+  }
+}
+
+function nameTrigrams(text: string): Set<string> {
+  const folded = text.toLowerCase()
+  const result = new Set<string>()
+  for (let i = 0; i + 3 <= folded.length; i++) result.add(folded.slice(i, i + 3))
+  return result
+}
+
+/**
+ * Filter over short symbol names and, unless left out, their case-folded trigrams
+ */
+export function nameFilter(names: Iterable<string>, trigrams = true): BloomFilter {
+  const keys = new Set<string>()
+  for (const name of names) {
+    keys.add(name)
+    if (!trigrams) continue
+    for (const gram of nameTrigrams(name)) keys.add(TRIGRAM_MARK + gram)
+  }
+  const filter = BloomFilter.forKeys(keys.size)
+  for (const key of keys) filter.add(key)
+  return filter
+}
+
+/**
+ * Whether a name filter may hold a short name
+ */
+export function mayHaveName(filter: BloomFilter, shortName: string): boolean {
+  return filter.mightContain(shortName)
+}
+
+/**
+ * Whether a name filter may hold a name containing the text, ignoring case;
+ * text under three characters cannot be ruled out
+ */
+export function mayHaveNameContaining(filter: BloomFilter, text: string): boolean {
+  for (const gram of nameTrigrams(text)) {
+    if (!filter.mightContain(TRIGRAM_MARK + gram)) return false
+  }
+  return true
+}
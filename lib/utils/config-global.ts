@@ -10,6 +10,7 @@ const LOG_FILE = path.join(INDEXER_DIR, 'daemon.log')
 const DAEMON_PID_FILE = path.join(INDEXER_DIR, 'daemon.pid')
 const DAEMON_PORT_FILE = path.join(INDEXER_DIR, 'daemon.port')
 const SNAPSHOT_DB_PATH = path.join(INDEXER_DIR, 'snapshots.db')
+const SYMBOL_INDEX_DB_PATH = path.join(INDEXER_DIR, 'symbol-index.db')
 
 export const DEFAULT_SETTINGS = {
   QDRANT_URL: 'http://localhost:6333',
@@ -223,6 +224,25 @@ export async function writeDaemonPortFile(port: number): Promise<void> {
   await fs.writeFile(DAEMON_PORT_FILE, String(port), 'utf8')
 }
 
+/**
+ * Socket the query daemon of a project listens on (a named pipe on Windows).
+ * Kept under the global directory, since socket paths are limited to about
+ * 100 characters and project paths are not.
+ */
+export function getQueryDaemonSocketPath(projectPath: string): string {
+  const name = getProjectCollectionName(projectPath)
+  if (process.platform === 'win32') return `\\\\.\\pipe\\indexer-${name}`
+  return path.join(INDEXER_DIR, 'daemons', `${name}.sock`)
+}
+
+export function getQueryDaemonLogPath(projectPath: string): string {
+  return path.join(INDEXER_DIR, 'daemons', `${getProjectCollectionName(projectPath)}.log`)
+}
+
 export function getSnapshotDbPath(): string {
   return SNAPSHOT_DB_PATH
 }
+
+export function getSymbolIndexDbPath(): string {
+  return SYMBOL_INDEX_DB_PATH
+}
@@ -0,0 +1,89 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { parseJSDoc, parsePythonDoc, parseXmlDoc, renderDocMarkdown } from './doc-comments.js'
+import { extractJSSymbols } from './ast-js.js'
+
+test('doc-comments: JSDoc tags, inline links and examples', () => {
+  const info = parseJSDoc([
+    'Saves the user. See {@link Store|the store} and https://example.com/docs.',
+    '@param {User} user - The user to save',
+    '@param [opts] Options',
+    '@returns {Promise<void>} Resolves when done',
+    '@deprecated Use {@link saveAll} instead',
+    '@example',
+    'await save(user)',
+    '@see Repository',
+    '@since 2.1.0'
+  ].join('\n'))
+
+  assert.equal(info.summary, 'Saves the user. See the store and https://example.com/docs.')
+  assert.deepEqual(info.params, [{ name: 'user', text: 'The user to save' }, { name: 'opts', text: 'Options' }])
+  assert.equal(info.returns, 'Resolves when done')
+  assert.equal(info.deprecated, 'Use saveAll instead')
+  assert.deepEqual(info.examples, ['await save(user)'])
+  assert.deepEqual(info.links, ['Store', 'saveAll', 'Repository', 'https://example.com/docs.'])
+  assert.equal(info.since, '2.1.0')
+})
+
+test('doc-comments: godoc-style Deprecated paragraph', () => {
+  const info = parseJSDoc('Old helper.\n\nDeprecated: use newHelper.')
+  assert.equal(info.summary, 'Old helper.')
+  assert.equal(info.deprecated, 'use newHelper.')
+})
+
+test('doc-comments: Python Google-style sections and doctests', () => {
+  const info = parsePythonDoc([
+    'Add two numbers. See :func:`math.fsum`.',
+    '',
+    '>>> add(1, 2)',
+    '3',
+    '',
+    'Args:',
+    '    a (int): First operand',
+    '    b: Second operand',
+    'Returns:',
+    '    The sum',
+    '',
+    '.. versionadded:: 1.4',
+    '.. deprecated:: 2.0 Use operator.add'
+  ].join('\n'))
+
+  assert.equal(info.summary, 'Add two numbers. See :func:`math.fsum`.')
+  assert.deepEqual(info.params, [{ name: 'a', text: 'First operand' }, { name: 'b', text: 'Second operand' }])
+  assert.equal(info.returns, 'The sum')
+  assert.deepEqual(info.examples, ['>>> add(1, 2)\n3'])
+  assert.equal(info.deprecated, '2.0 Use operator.add')
+  assert.equal(info.since, '1.4')
+  assert.deepEqual(info.links, ['math.fsum'])
+})
+
+test('doc-comments: C# XML doc elements', () => {
+  const info = parseXmlDoc([
+    '<summary>Loads a <see cref="T:Game.Level"/> by name.</summary>',
+    '<param name="name">Level name</param>',
+    '<returns>The level</returns>',
+    '<example><code>Load("intro");</code></example>'
+  ].join('\n'))
+
+  assert.equal(info.summary, 'Loads a Game.Level by name.')
+  assert.deepEqual(info.params, [{ name: 'name', text: 'Level name' }])
+  assert.equal(info.returns, 'The level')
+  assert.deepEqual(info.examples, ['Load("intro");'])
+  assert.deepEqual(info.links, ['Game.Level'])
+})
+
+test('doc-comments: markdown rendering and extractor metadata', () => {
+  const [sym] = extractJSSymbols(`
+/**
+ * Old API.
+ * @deprecated Use v2
+ * @param id The id
+ */
+export function load(id) {}
+`)
+  assert.equal(sym.doc_info.deprecated, 'Use v2')
+  assert.equal(
+    renderDocMarkdown(sym.doc_info, 'typescript'),
+    '**Deprecated:** Use v2\n\nOld API.\n\n**Parameters**\n- `id` — The id'
+  )
+})
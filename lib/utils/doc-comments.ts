@@ -0,0 +1,252 @@
+/**
+ * Doc Comments Module
+ * Turns JSDoc, Python docstrings and C# XML doc comments into structured
+ * hover metadata: summary, deprecation notice, parameters, return value,
+ * examples, links and the version a symbol appeared in.
+ */
+
+export interface DocParam {
+  name: string
+  text: string
+}
+
+export interface DocInfo {
+  summary: string
+  deprecated?: string // notice text ('' when deprecated without a reason)
+  params?: DocParam[]
+  returns?: string
+  examples?: string[]
+  links?: string[] // symbol names or URLs the doc points at
+  since?: string // version the symbol was added in (@since, .. versionadded::)
+}
+
+const URL_RE = /https?:\/\/[^\s)>\]"']+/g
+// Godoc convention, also common elsewhere: a paragraph starting "Deprecated:"
+const DEPRECATED_PARAGRAPH_RE = /(?:^|\n\n)Deprecated:\s*([\s\S]*?)(?=\n\n|$)/
+
+function addLink(info: DocInfo, target: string): void {
+  const link = target.trim()
+  if (!link) return
+  info.links = info.links || []
+  if (!info.links.includes(link)) info.links.push(link)
+}
+
+function addParam(info: DocInfo, name: string, text: string): void {
+  info.params = info.params || []
+  info.params.push({ name, text: text.trim() })
+}
+
+function addExample(info: DocInfo, text: string): void {
+  const example = text.replace(/^\n+|\s+$/g, '')
+  if (!example) return
+  info.examples = info.examples || []
+  info.examples.push(example)
+}
+
+// Shared post-processing: URLs, godoc deprecation paragraphs, tidy summary
+function finish(info: DocInfo, text: string): DocInfo {
+  for (const url of text.match(URL_RE) || []) addLink(info, url)
+  const deprecated = info.summary.match(DEPRECATED_PARAGRAPH_RE)
+  if (deprecated) {
+    if (info.deprecated === undefined) info.deprecated = deprecated[1].trim()
+    info.summary = info.summary.replace(DEPRECATED_PARAGRAPH_RE, '')
+  }
+  info.summary = info.summary.replace(/\n{3,}/g, '\n\n').trim()
+  return info
+}
+
+/**
+ * Parse a JSDoc body (leading "*" already stripped)
+ */
+export function parseJSDoc(text: string): DocInfo {
+  const info: DocInfo = { summary: '' }
+  // {@link Target}, {@link Target|label}, {@linkcode Target label}
+  const inline = text.replace(/\{@link(?:code|plain)?\s+([^}|\s]+)(?:[|\s]([^}]*))?\}/g, (_m, target: string, label?: string) => {
+    addLink(info, target)
+    return label?.trim() || target
+  })
+
+  const blocks = inline.split(/\n(?=\s*@\w)/)
+  info.summary = blocks[0].startsWith('@') ? '' : blocks.shift()!
+  for (const block of blocks) {
+    const match = block.trim().match(/^@(\w+)\s*([\s\S]*)$/)
+    if (!match) continue
+    const [, tag, body] = match
+    switch (tag) {
+      case 'deprecated':
+        info.deprecated = body.trim()
+        break
+      case 'param':
+      case 'arg':
+      case 'argument': {
+        const param = body.replace(/^\{[^}]*\}\s*/, '').match(/^\[?([\w$.]+)[^\s\]]*\]?\s*(?:-\s*)?([\s\S]*)$/)
+        if (param) addParam(info, param[1], param[2])
+        break
+      }
+      case 'returns':
+      case 'return':
+        info.returns = body.replace(/^\{[^}]*\}\s*/, '').trim()
+        break
+      case 'example':
+        addExample(info, body)
+        break
+      case 'see':
+        addLink(info, body.split(/\s/)[0])
+        break
+      case 'since':
+        info.since = body.trim()
+        break
+    }
+  }
+  return finish(info, text)
+}
+
+/**
+ * Parse a Python docstring (Google, NumPy-lite and reST field styles)
+ */
+export function parsePythonDoc(text: string): DocInfo {
+  const info: DocInfo = { summary: '' }
+  const summary: string[] = []
+  let section: string | null = null
+  let example: string[] = []
+
+  for (const line of text.split('\n')) {
+    const trimmed = line.trim()
+    const heading = trimmed.match(/^(Args|Arguments|Parameters|Returns|Return|Yields|Examples?|See Also|Deprecated):$/i)
+    const rest = trimmed.match(/^\.\. deprecated::\s*(.*)$/) || trimmed.match(/^:(param|returns?|raises)\s*([\w*]*)\s*:\s*(.*)$/)
+    const added = trimmed.match(/^\.\. versionadded::\s*(\S+)/)
+
+    if (added) {
+      info.since = added[1]
+      continue
+    }
+
+    if (heading) {
+      if (example.length > 0) addExample(info, example.join('\n'))
+      example = []
+      section = heading[1].toLowerCase()
+      if (section === 'deprecated') info.deprecated = ''
+      continue
+    }
+    if (rest && rest[0].startsWith('..')) {
+      info.deprecated = rest[1]
+      section = 'deprecated'
+      continue
+    }
+    if (rest) {
+      if (rest[1] === 'param') addParam(info, rest[2], rest[3])
+      else if (rest[1].startsWith('return')) info.returns = rest[3]
+      continue
+    }
+    for (const role of trimmed.matchAll(/:(?:func|class|meth|mod|attr|obj):`~?([^`]+)`/g)) addLink(info, role[1])
+
+    if (trimmed.startsWith('>>>') && section !== 'examples') {
+      example.push(trimmed)
+      continue
+    }
+    if (example.length > 0 && section !== 'examples') {
+      if (trimmed === '') {
+        addExample(info, example.join('\n'))
+        example = []
+      } else {
+        example.push(trimmed)
+      }
+      continue
+    }
+
+    switch (section) {
+      case 'args':
+      case 'arguments':
+      case 'parameters': {
+        const param = trimmed.match(/^([\w*]+)\s*(?:\([^)]*\))?\s*:\s*(.*)$/)
+        if (param) addParam(info, param[1], param[2])
+        else if (trimmed && info.params) info.params[info.params.length - 1].text += ` ${trimmed}`
+        break
+      }
+      case 'returns':
+      case 'return':
+      case 'yields':
+        if (trimmed) info.returns = info.returns ? `${info.returns} ${trimmed}` : trimmed
+        break
+      case 'example':
+      case 'examples':
+        example.push(line)
+        break
+      case 'see also':
+        if (trimmed) addLink(info, trimmed.split(/[\s:,]/)[0])
+        break
+      case 'deprecated':
+        if (trimmed) info.deprecated = info.deprecated ? `${info.deprecated} ${trimmed}` : trimmed
+        else section = null
+        break
+      default:
+        summary.push(line)
+    }
+  }
+  if (example.length > 0) addExample(info, example.join('\n'))
+
+  info.summary = summary.join('\n')
+  return finish(info, text)
+}
+
+function xmlText(xml: string): string {
+  return xml
+    .replace(/<(?:see|seealso)\s+(?:cref|href|langword)="([^"]*)"\s*\/>/g, (_m, target: string) => target.replace(/^[A-Z]:/, ''))
+    .replace(/<paramref\s+name="([^"]*)"\s*\/>/g, '$1')
+    .replace(/<\/?[a-zA-Z]+[^>]*>/g, '')
+    .split('\n')
+    .map(l => l.trim())
+    .join('\n')
+    .trim()
+}
+
+/**
+ * Parse a C# XML doc comment (/// lines, markers stripped)
+ */
+export function parseXmlDoc(xml: string): DocInfo {
+  const info: DocInfo = { summary: '' }
+  const summary = xml.match(/<summary>([\s\S]*?)<\/summary>/)
+  info.summary = xmlText(summary ? summary[1] : xml.replace(/<(param|returns|example|remarks)[\s\S]*?<\/\1>/g, ''))
+
+  for (const m of xml.matchAll(/<param\s+name="([^"]*)"\s*>([\s\S]*?)<\/param>/g)) addParam(info, m[1], xmlText(m[2]))
+  const returns = xml.match(/<returns>([\s\S]*?)<\/returns>/)
+  if (returns) info.returns = xmlText(returns[1])
+  for (const m of xml.matchAll(/<example>([\s\S]*?)<\/example>/g)) {
+    const code = m[1].match(/<code>([\s\S]*?)<\/code>/)
+    addExample(info, code ? code[1] : xmlText(m[1]))
+  }
+  for (const m of xml.matchAll(/<(?:see|seealso)\s+(?:cref|href)="([^"]*)"/g)) addLink(info, m[1].replace(/^[A-Z]:/, ''))
+  return finish(info, xml)
+}
+
+/**
+ * Markdown hover text: summary, deprecation, parameters, returns, examples
+ * and links
+ */
+export function renderDocMarkdown(info: DocInfo, exampleLanguage = ''): string {
+  const parts: string[] = []
+  if (info.deprecated !== undefined) {
+    parts.push(info.deprecated ? `**Deprecated:** ${info.deprecated}` : '**Deprecated**')
+  }
+  if (info.summary) parts.push(info.summary)
+  if (info.params && info.params.length > 0) {
+    parts.push(['**Parameters**', ...info.params.map(p => `- \`${p.name}\`${p.text ? ` — ${p.text}` : ''}`)].join('\n'))
+  }
+  if (info.returns) parts.push(`**Returns** ${info.returns}`)
+  if (info.since) parts.push(`**Since** ${info.since}`)
+  for (const example of info.examples || []) {
+    parts.push(`**Example**\n\`\`\`${exampleLanguage}\n${example}\n\`\`\``)
+  }
+  if (info.links && info.links.length > 0) {
+    parts.push(`**See** ${info.links.map(l => (/^https?:/.test(l) ? `<${l}>` : `\`${l}\``)).join(', ')}`)
+  }
+  return parts.join('\n\n')
+}
+
+/**
+ * Hover markdown for a symbol: structured docs when present, else raw doc
+ */
+export function symbolDocMarkdown(sym: { doc?: string, doc_info?: DocInfo }, exampleLanguage = ''): string | undefined {
+  if (sym.doc_info) return renderDocMarkdown(sym.doc_info, exampleLanguage) || undefined
+  return sym.doc
+}
@@ -0,0 +1,152 @@
+/**
+ * Error Facts Module
+ * What a function body does with errors, for the error-path analysis:
+ * the error types it throws without catching them itself (`throws`, "*"
+ * for a rethrow or a thrown value of unknown type) and the error types it
+ * builds around a cause (`wraps`: `new AppError(msg, { cause: err })`,
+ * `raise AppError(...) from err`, `new AppException(msg, ex)` with the
+ * exception of an enclosing catch). Throws inside the protected block of a
+ * try with a catch clause are caught; nested functions are left to their
+ * own symbols.
+ */
+
+// Any thrown value whose type the body does not spell out
+export const UNKNOWN_THROW = '*'
+
+export interface ErrorFacts {
+  throws?: string[] // error types thrown out of the body
+  wraps?: string[] // error types constructed with a cause
+}
+
+// Babel keys that hold no statements or expressions
+const SKIPPED_KEYS = new Set([
+  'loc', 'start', 'end', 'range', 'extra', 'leadingComments', 'trailingComments', 'innerComments',
+  'typeAnnotation', 'returnType', 'typeParameters', 'typeArguments'
+])
+
+function lastSegment(name: string): string {
+  return name.replace(/<.*$/s, '').split('.').pop() || name
+}
+
+function facts(throws: Set<string>, wraps: Set<string>): ErrorFacts {
+  return {
+    ...(throws.size > 0 ? { throws: [...throws] } : {}),
+    ...(wraps.size > 0 ? { wraps: [...wraps] } : {})
+  }
+}
+
+function babelTypeName(callee: any): string | undefined {
+  if (callee?.type === 'Identifier') return callee.name
+  if (callee?.type === 'MemberExpression' && !callee.computed && callee.property?.type === 'Identifier') return callee.property.name
+  return undefined
+}
+
+/**
+ * Error facts of a Babel function body
+ */
+export function babelErrorFacts(body: any): ErrorFacts {
+  const throws = new Set<string>()
+  const wraps = new Set<string>()
+
+  const visit = (node: any, guarded: boolean) => {
+    if (!node || typeof node.type !== 'string') return
+    if (node.type === 'ThrowStatement' && !guarded) {
+      const arg = node.argument
+      const type = arg?.type === 'NewExpression' || arg?.type === 'CallExpression' ? babelTypeName(arg.callee) : undefined
+      throws.add(type || UNKNOWN_THROW)
+    }
+    if (node.type === 'NewExpression') {
+      const options = node.arguments?.find((a: any) => a.type === 'ObjectExpression')
+      const hasCause = options?.properties?.some((p: any) => !p.computed && (p.key?.name === 'cause' || p.key?.value === 'cause'))
+      const type = babelTypeName(node.callee)
+      if (hasCause && type) wraps.add(type)
+    }
+    for (const key of Object.keys(node)) {
+      if (SKIPPED_KEYS.has(key)) continue
+      const value = node[key]
+      const children = Array.isArray(value) ? value : [value]
+      for (const child of children) {
+        if (!child || typeof child.type !== 'string' || /Function|Method/.test(child.type)) continue
+        visit(child, guarded || (node.type === 'TryStatement' && key === 'block' && !!node.handler))
+      }
+    }
+  }
+
+  visit(body, false)
+  return facts(throws, wraps)
+}
+
+// Tree-sitter nodes that start a function of their own
+const TREE_SITTER_FUNCTIONS = new Set([
+  'function_definition', 'lambda', 'method_declaration', 'constructor_declaration', 'local_function_statement',
+  'lambda_expression', 'anonymous_method_expression'
+])
+
+const TREE_SITTER_HANDLERS = new Set(['except_clause', 'catch_clause'])
+
+function sameRange(a: any, b: any): boolean {
+  return !!a && !!b && a.startIndex === b.startIndex && a.endIndex === b.endIndex
+}
+
+/**
+ * Whether a tree-sitter node is in the protected block of a try with an
+ * except / catch clause, within its own function
+ */
+export function treeSitterCaught(node: any): boolean {
+  let child = node
+  for (let parent = node.parent; parent; child = parent, parent = parent.parent) {
+    if (TREE_SITTER_FUNCTIONS.has(parent.type)) return false
+    if (parent.type !== 'try_statement') continue
+    if (!sameRange(parent.childForFieldName('body'), child)) continue
+    if (parent.namedChildren.some((c: any) => TREE_SITTER_HANDLERS.has(c.type))) return true
+  }
+  return false
+}
+
+// Error type a Python raise or C# throw spells out
+function treeSitterThrowType(value: any): string | undefined {
+  if (!value) return undefined
+  if (value.type === 'call') return lastSegment(value.childForFieldName('function')?.text || '') || undefined
+  if (value.type === 'object_creation_expression') return lastSegment(value.childForFieldName('type')?.text || '') || undefined
+  // `raise ValueError` names the class; `raise err` re-raises a value
+  if ((value.type === 'identifier' || value.type === 'attribute') && /^[A-Z]/.test(lastSegment(value.text))) return lastSegment(value.text)
+  return undefined
+}
+
+/**
+ * Error facts of a Python or C# function body
+ */
+export function treeSitterErrorFacts(body: any): ErrorFacts {
+  const throws = new Set<string>()
+  const wraps = new Set<string>()
+  const catchNames = new Set<string>()
+
+  const collectCatchNames = (node: any) => {
+    if (node !== body && TREE_SITTER_FUNCTIONS.has(node.type)) return
+    if (node.type === 'catch_declaration') {
+      const name = node.childForFieldName('name')?.text
+      if (name) catchNames.add(name)
+    }
+    for (const child of node.namedChildren) collectCatchNames(child)
+  }
+  collectCatchNames(body)
+
+  const visit = (node: any) => {
+    if (node !== body && TREE_SITTER_FUNCTIONS.has(node.type)) return
+    if (node.type === 'raise_statement' || node.type === 'throw_statement' || node.type === 'throw_expression') {
+      const value = node.namedChildren.find((c: any) => c.type !== 'comment' && !sameRange(c, node.childForFieldName('cause')))
+      const type = treeSitterThrowType(value)
+      if (!treeSitterCaught(node)) throws.add(type || UNKNOWN_THROW)
+      if (node.childForFieldName('cause') && type) wraps.add(type)
+    }
+    if (node.type === 'object_creation_expression' && catchNames.size > 0) {
+      const args = node.childForFieldName('arguments')?.namedChildren || []
+      const type = treeSitterThrowType(node)
+      if (type && args.some((a: any) => catchNames.has(a.text))) wraps.add(type)
+    }
+    for (const child of node.namedChildren) visit(child)
+  }
+
+  visit(body)
+  return facts(throws, wraps)
+}
@@ -0,0 +1,81 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { extractJSSymbols } from './ast-js.js'
+import { csharpTagLiteral, fieldTags, formatTag } from './field-tags.js'
+import { SymbolIndex } from '../core/symbol-index.js'
+import { querySymbols } from '../core/symbol-query.js'
+import { parseQuery, selectSymbols } from '../core/query-language.js'
+
+const ENTITY = `
+@Entity()
+export class User {
+  @PrimaryGeneratedColumn()
+  id: number
+
+  @Column({ name: 'user_id', nullable: true, length: 64 })
+  @Expose({ name: 'user_id' })
+  @IsEmail()
+  @Length(1, 20)
+  userId: string
+
+  @Exclude()
+  password: string
+
+  @Field.d(1, 'string', 'optional')
+  nickname?: string
+
+  plain = 1
+}
+`
+
+const tagsOf = (name: string) => extractJSSymbols(ENTITY).find(s => s.name === name)?.tags
+
+test('field-tags: reads TypeORM, class-transformer and class-validator decorators', () => {
+  assert.deepEqual(tagsOf('User.id'), { db: { name: 'id', options: ['primary', 'generated'] } })
+  assert.deepEqual(tagsOf('User.userId'), {
+    db: { name: 'user_id', options: ['nullable', 'length=64'] },
+    json: { name: 'user_id' },
+    validate: { options: ['email', 'length=1,20'] }
+  })
+  assert.deepEqual(tagsOf('User.password'), { json: { name: '-' } })
+  assert.deepEqual(tagsOf('User.nickname'), { protobuf: { name: 'nickname', options: ['field=1', 'type=string', 'optional'] } })
+  assert.equal(tagsOf('User.plain'), undefined)
+})
+
+test('field-tags: maps C# attributes with positional and named arguments', () => {
+  const tags = fieldTags([
+    { name: 'JsonPropertyName', args: ['user_id'] },
+    { name: 'Column', args: [{ Name: 'user_id', TypeName: 'varchar(64)' }] },
+    { name: 'Key', args: [] },
+    { name: 'System.ComponentModel.DataAnnotations.RangeAttribute', args: [1, 10] },
+    { name: 'Required', args: [] }
+  ], 'UserId', 'csharp')
+  assert.deepEqual(tags, {
+    json: { name: 'user_id' },
+    db: { name: 'user_id', options: ['type=varchar(64)', 'primary'] },
+    validate: { options: ['range=1,10', 'required'] }
+  })
+  assert.equal(fieldTags([{ name: 'Obsolete', args: [] }], 'X', 'csharp'), undefined)
+})
+
+test('field-tags: formats tags Go style and parses C# literals', () => {
+  assert.equal(formatTag('json', { name: 'user_id', options: ['omitempty'] }), 'json:"user_id,omitempty"')
+  assert.equal(formatTag('validate', { options: ['email'] }), 'validate:"email"')
+  assert.equal(csharpTagLiteral('"user_id"'), 'user_id')
+  assert.equal(csharpTagLiteral('10L'), 10)
+  assert.equal(csharpTagLiteral('true'), true)
+  assert.equal(csharpTagLiteral('nameof(Id)'), null)
+})
+
+test('field-tags: queries fields by tag name and option', () => {
+  const index = new SymbolIndex()
+  index.addFile('lib/user.ts', 'typescript', extractJSSymbols(ENTITY))
+  const names = (tags: string[]) => querySymbols(index, { tags }).map(s => s.name)
+  assert.deepEqual(names(['json:user_id']), ['User.userId'])
+  assert.deepEqual(names(['db']), ['User.id', 'User.userId'])
+  assert.deepEqual(names(['db:primary']), ['User.id'])
+  assert.deepEqual(names(['validate:length*', 'json']), ['User.userId'])
+  assert.deepEqual(names(['json:-']), ['User.password'])
+  assert.deepEqual(selectSymbols(index, parseQuery('tag("json", "user_id") OR tags.protobuf.name = "nickname"')).map(s => s.name),
+    ['User.userId', 'User.nickname'])
+})
@@ -0,0 +1,220 @@
+/**
+ * Field Tags Module
+ * Serialization and validation metadata of fields, the counterpart of Go
+ * struct tags (`json:"user_id,omitempty" db:"user_id" validate:"required"`).
+ * Decorators (TypeORM, MikroORM, Sequelize, class-transformer,
+ * class-validator, protobufjs, type-graphql, Nest Swagger) and C# attributes
+ * (System.Text.Json, Newtonsoft, EF Core, DataContract, protobuf-net, XML,
+ * MongoDB, DataAnnotations) are read into one shape per tag key:
+ *   { json: { name: 'user_id' }, db: { name: 'user_id', options: ['nullable'] } }
+ * A name of "-" means the field is left out, as in Go.
+ */
+
+export type TagLiteral = string | number | boolean | null // null: not a constant
+export type TagArg = TagLiteral | Record<string, TagLiteral>
+
+export interface TagSource {
+  name: string // decorator or attribute name as written (Column, Field.d, JsonPropertyName)
+  args: TagArg[] // named C# arguments are gathered into one trailing object
+}
+
+export interface FieldTag {
+  name?: string
+  options?: string[]
+}
+
+export type FieldTags = Record<string, FieldTag>
+
+type Rule = (args: TagArg[], field: string) => [string, FieldTag]
+
+const isObject = (arg: TagArg | undefined): arg is Record<string, TagLiteral> =>
+  !!arg && typeof arg === 'object'
+
+function firstString(args: TagArg[]): string | undefined {
+  return args.find((a): a is string => typeof a === 'string')
+}
+
+function named(args: TagArg[]): Record<string, TagLiteral> {
+  return Object.assign({}, ...args.filter(isObject))
+}
+
+function lowerFirst(name: string): string {
+  return name.charAt(0).toLowerCase() + name.slice(1)
+}
+
+/**
+ * Options as tag flags: `nullable` for true, `length=255` for other values
+ */
+function flags(options: Record<string, TagLiteral>, skip: string[] = []): string[] {
+  return Object.entries(options)
+    .filter(([key, value]) => !skip.includes(key) && value !== null && value !== false)
+    .map(([key, value]) => (value === true ? lowerFirst(key) : `${lowerFirst(key)}=${value}`))
+}
+
+function tag(name: string | undefined, options: string[] = []): FieldTag {
+  return { ...(name !== undefined ? { name } : {}), ...(options.length > 0 ? { options } : {}) }
+}
+
+const TS_VALIDATORS = new Set([
+  'Min', 'Max', 'Length', 'MinLength', 'MaxLength', 'Matches', 'Contains', 'NotContains', 'Equals', 'NotEquals',
+  'ArrayMinSize', 'ArrayMaxSize', 'ArrayNotEmpty', 'ArrayUnique', 'ValidateNested', 'ValidateIf', 'Allow', 'MinDate', 'MaxDate'
+])
+const CS_VALIDATORS = new Set([
+  'Required', 'MaxLength', 'MinLength', 'StringLength', 'Range', 'EmailAddress', 'Phone', 'Url',
+  'RegularExpression', 'Compare', 'CreditCard'
+])
+
+function typeormColumn(implied: string[]): Rule {
+  return (args, field) => {
+    const options = named(args)
+    const type = firstString(args)
+    const name = typeof options.name === 'string' ? options.name : typeof options.field === 'string' ? options.field : field
+    return ['db', tag(name, [...implied, ...(type ? [`type=${type}`] : []), ...flags(options, ['name', 'field'])])]
+  }
+}
+
+const TS_RULES: Record<string, Rule> = {
+  Column: typeormColumn([]),
+  PrimaryColumn: typeormColumn(['primary']),
+  PrimaryGeneratedColumn: typeormColumn(['primary', 'generated']),
+  CreateDateColumn: typeormColumn(['createDate']),
+  UpdateDateColumn: typeormColumn(['updateDate']),
+  DeleteDateColumn: typeormColumn(['deleteDate']),
+  VersionColumn: typeormColumn(['version']),
+  JoinColumn: (args) => ['db', tag(named(args).name as string | undefined, flags(named(args), ['name']))],
+  Property: (args, field) => {
+    const options = named(args)
+    return ['db', tag(typeof options.fieldName === 'string' ? options.fieldName : field, flags(options, ['fieldName']))]
+  },
+  PrimaryKey: (args, field) => {
+    const options = named(args)
+    return ['db', tag(typeof options.fieldName === 'string' ? options.fieldName : field, ['primary', ...flags(options, ['fieldName'])])]
+  },
+  Expose: (args, field) => {
+    const options = named(args)
+    return ['json', tag(typeof options.name === 'string' ? options.name : field, flags(options, ['name']))]
+  },
+  Exclude: () => ['json', tag('-')],
+  JsonProperty: (args, field) => ['json', tag(firstString(args) ?? (named(args).name as string | undefined) ?? field)],
+  SerializedName: (args, field) => ['json', tag(firstString(args) ?? field)],
+  'Field.d': (args, field) => {
+    const [id, type, rule] = args
+    return ['protobuf', tag(field, [
+      ...(typeof id === 'number' ? [`field=${id}`] : []),
+      ...(typeof type === 'string' ? [`type=${type}`] : []),
+      ...(typeof rule === 'string' ? [rule] : [])
+    ])]
+  },
+  Field: (args, field) => {
+    const options = named(args)
+    return ['graphql', tag(typeof options.name === 'string' ? options.name : field, flags(options, ['name', 'description']))]
+  },
+  ApiProperty: (args, field) => {
+    const options = named(args)
+    return ['openapi', tag(typeof options.name === 'string' ? options.name : field, flags(options, ['name', 'description', 'example']))]
+  },
+  ApiPropertyOptional: (args, field) => {
+    const options = named(args)
+    return ['openapi', tag(typeof options.name === 'string' ? options.name : field, ['optional', ...flags(options, ['name', 'description', 'example'])])]
+  }
+}
+
+const CS_RULES: Record<string, Rule> = {
+  JsonPropertyName: (args, field) => ['json', tag(firstString(args) ?? field)],
+  JsonProperty: (args, field) => {
+    const options = named(args)
+    return ['json', tag(firstString(args) ?? (options.PropertyName as string | undefined) ?? field, flags(options, ['PropertyName']))]
+  },
+  JsonIgnore: () => ['json', tag('-')],
+  JsonRequired: (_args, field) => ['json', tag(field, ['required'])],
+  Column: (args, field) => {
+    const options = named(args)
+    return ['db', tag(firstString(args) ?? (options.Name as string | undefined) ?? field, flags(options, ['Name']).map(f => f.replace(/^typeName=/, 'type=')))]
+  },
+  Key: (_args, field) => ['db', tag(field, ['primary'])],
+  ForeignKey: (args, field) => ['db', tag(field, [`foreignKey=${firstString(args)}`])],
+  NotMapped: () => ['db', tag('-')],
+  DataMember: (args, field) => {
+    const options = named(args)
+    return ['datamember', tag((options.Name as string | undefined) ?? field, flags(options, ['Name']))]
+  },
+  IgnoreDataMember: () => ['datamember', tag('-')],
+  ProtoMember: (args, field) => {
+    const options = named(args)
+    const id = args.find((a): a is number => typeof a === 'number')
+    return ['protobuf', tag((options.Name as string | undefined) ?? field, [...(id !== undefined ? [`field=${id}`] : []), ...flags(options, ['Name'])])]
+  },
+  XmlElement: (args, field) => ['xml', tag(firstString(args) ?? (named(args).ElementName as string | undefined) ?? field)],
+  XmlAttribute: (args, field) => ['xml', tag(firstString(args) ?? (named(args).AttributeName as string | undefined) ?? field, ['attr'])],
+  XmlIgnore: () => ['xml', tag('-')],
+  BsonElement: (args, field) => ['bson', tag(firstString(args) ?? field)],
+  BsonId: () => ['bson', tag('_id')],
+  BsonIgnore: () => ['bson', tag('-')]
+}
+
+/**
+ * One validate option per validation decorator: IsEmail() -> email,
+ * Length(1, 20) -> length=1,20, [Range(1, 10)] -> range=1,10
+ */
+function validation(name: string): Rule {
+  const rule = lowerFirst(name.replace(/^Is(?=[A-Z])/, '').replace(/Address$/, ''))
+  return (args) => {
+    const values = args.filter((a): a is string | number | boolean => a !== null && typeof a !== 'object')
+    return ['validate', tag(undefined, [values.length > 0 ? `${rule}=${values.join(',')}` : rule])]
+  }
+}
+
+function ruleFor(source: TagSource, lang: string): Rule | undefined {
+  if (lang === 'csharp') {
+    const name = source.name.replace(/^.*\./, '').replace(/Attribute$/, '')
+    if (CS_RULES[name]) return CS_RULES[name]
+    return CS_VALIDATORS.has(name) ? validation(name) : undefined
+  }
+  // Qualified names first: Field.d is protobufjs, Field is type-graphql
+  if (TS_RULES[source.name]) return TS_RULES[source.name]
+  const name = source.name.replace(/^.*\./, '')
+  if (TS_RULES[name] && name !== 'Field') return TS_RULES[name]
+  return /^Is[A-Z]/.test(name) || TS_VALIDATORS.has(name) ? validation(name) : undefined
+}
+
+/**
+ * Tags of a field from its decorators or attributes, undefined if none map
+ * to a tag. Tags with the same key merge: the first name wins, options add up.
+ * @param sources - Decorators / attributes in source order
+ * @param field - Field name, the default serialized name
+ * @param lang - 'csharp' for C# attributes; anything else reads decorators
+ */
+export function fieldTags(sources: TagSource[], field: string, lang: string): FieldTags | undefined {
+  const tags: FieldTags = {}
+  for (const source of sources) {
+    const rule = ruleFor(source, lang)
+    if (!rule) continue
+    const [key, next] = rule(source.args, field)
+    const current = tags[key]
+    if (!current) {
+      tags[key] = next
+      continue
+    }
+    const options = [...(current.options || []), ...(next.options || []).filter(o => !current.options?.includes(o))]
+    tags[key] = tag(current.name ?? next.name, options)
+  }
+  return Object.keys(tags).length > 0 ? tags : undefined
+}
+
+/**
+ * A tag in Go struct tag notation: json:"user_id,omitempty", validate:"email"
+ */
+export function formatTag(key: string, value: FieldTag): string {
+  return `${key}:"${[...(value.name !== undefined ? [value.name] : []), ...(value.options || [])].join(',')}"`
+}
+
+/**
+ * Literal value of a C# attribute argument, null when it is not a literal
+ */
+export function csharpTagLiteral(text: string): TagLiteral {
+  const t = text.trim()
+  if (/^@?"/.test(t)) return t.replace(/^@?"/, '').replace(/"$/, '').replace(/\\"/g, '"')
+  if (/^-?\d+(\.\d+)?[fFdDmMuUlL]*$/.test(t)) return Number(t.replace(/[fFdDmMuUlL]+$/, ''))
+  if (t === 'true' || t === 'false') return t === 'true'
+  return null
+}
@@ -0,0 +1,108 @@
+/**
+ * Fingerprint Module
+ * Structural fingerprints of function bodies for duplicate detection. A body
+ * is reduced to its sequence of AST node types (operators kept, names,
+ * literal values and types dropped), so renaming variables or changing
+ * constants leaves the fingerprint alone. The exact fingerprint hashes the
+ * whole sequence; a MinHash over 4-node shingles estimates how much two
+ * bodies have in common.
+ */
+
+import crypto from 'crypto'
+
+// Bodies smaller than this (in AST nodes) get no fingerprint
+export const MIN_FINGERPRINT_SIZE = 20
+export const MINHASH_SIZE = 64
+const SHINGLE_SIZE = 4
+
+export interface BodyFingerprint {
+  body_hash: string // hex, same for structurally identical bodies
+  body_size: number // AST nodes
+  body_minhash: number[] // MINHASH_SIZE unsigned 32-bit minimums
+}
+
+// Babel keys that are not part of a body's structure
+const SKIPPED_KEYS = new Set([
+  'loc', 'start', 'end', 'range', 'extra', 'leadingComments', 'trailingComments', 'innerComments',
+  'typeAnnotation', 'returnType', 'typeParameters', 'typeArguments'
+])
+
+/**
+ * Node types of a Babel AST in preorder
+ */
+export function babelTokens(node: any, out: string[] = []): string[] {
+  if (!node || typeof node.type !== 'string') return out
+  out.push(typeof node.operator === 'string' ? `${node.type}${node.operator}` : node.type)
+  for (const key of Object.keys(node)) {
+    if (SKIPPED_KEYS.has(key)) continue
+    const value = node[key]
+    if (Array.isArray(value)) {
+      for (const child of value) babelTokens(child, out)
+    } else if (value && typeof value === 'object') {
+      babelTokens(value, out)
+    }
+  }
+  return out
+}
+
+/**
+ * Node types of a tree-sitter tree in preorder; anonymous nodes are the
+ * keywords and operators, named leaves stand for any name or literal
+ */
+export function treeSitterTokens(node: any, out: string[] = []): string[] {
+  if (!node || node.type === 'comment') return out
+  out.push(node.type)
+  for (let i = 0; i < node.childCount; i++) treeSitterTokens(node.child(i), out)
+  return out
+}
+
+// FNV-1a
+function hashString(text: string): number {
+  let h = 0x811c9dc5
+  for (let i = 0; i < text.length; i++) {
+    h ^= text.charCodeAt(i)
+    h = Math.imul(h, 0x01000193)
+  }
+  return h >>> 0
+}
+
+// murmur3 finalizer, one independent permutation per seed
+function mix(x: number, seed: number): number {
+  let h = (x ^ seed) >>> 0
+  h = Math.imul(h ^ (h >>> 16), 0x85ebca6b)
+  h = Math.imul(h ^ (h >>> 13), 0xc2b2ae35)
+  return (h ^ (h >>> 16)) >>> 0
+}
+
+const SEEDS = Array.from({ length: MINHASH_SIZE }, (_, i) => mix(i + 1, 0x9e3779b9))
+
+/**
+ * Fingerprint of a token sequence, undefined below MIN_FINGERPRINT_SIZE
+ */
+export function fingerprintTokens(tokens: string[]): BodyFingerprint | undefined {
+  if (tokens.length < MIN_FINGERPRINT_SIZE) return undefined
+  const minhash = new Array<number>(MINHASH_SIZE).fill(0xffffffff)
+  for (let i = 0; i + SHINGLE_SIZE <= tokens.length; i++) {
+    const shingle = hashString(tokens.slice(i, i + SHINGLE_SIZE).join(' '))
+    for (let k = 0; k < MINHASH_SIZE; k++) {
+      const h = mix(shingle, SEEDS[k])
+      if (h < minhash[k]) minhash[k] = h
+    }
+  }
+  return {
+    body_hash: crypto.createHash('sha1').update(tokens.join(' ')).digest('hex').slice(0, 16),
+    body_size: tokens.length,
+    body_minhash: minhash
+  }
+}
+
+/**
+ * Estimated Jaccard similarity of two bodies' shingle sets, from 0 to 1
+ */
+export function minhashSimilarity(a: number[], b: number[]): number {
+  const n = Math.min(a.length, b.length)
+  if (n === 0) return 0
+  let same = 0
+  for (let i = 0; i < n; i++) if (a[i] === b[i]) same++
+  return same / n
+}
@@ -0,0 +1,213 @@
+/**
+ * Git Module
+ * Thin wrappers over git plumbing commands for indexing trees at a given
+ * revision without touching the working copy.
+ */
+
+import path from 'path'
+import readline from 'readline'
+import { execFile, spawn } from 'child_process'
+import type { FileChange } from '../types/index.js'
+
+/**
+ * Run a git command in a repository and return its stdout
+ */
+export function runGit(cwd: string, args: string[]): Promise<string> {
+  return new Promise((resolve, reject) => {
+    execFile('git', args, { cwd, maxBuffer: 256 * 1024 * 1024 }, (err, stdout, stderr) => {
+      if (err) reject(new Error(stderr.trim() || err.message))
+      else resolve(stdout)
+    })
+  })
+}
+
+/**
+ * Resolve a revision (HEAD~3, a branch, a tag, a short SHA) to a commit SHA
+ */
+export async function resolveRevision(cwd: string, rev: string): Promise<string> {
+  const out = await runGit(cwd, ['rev-parse', '--verify', '--quiet', `${rev}^{commit}`]).catch(() => '')
+  const sha = out.trim()
+  if (!sha) throw new Error(`Unknown revision: ${rev}`)
+  return sha
+}
+
+/**
+ * Files under cwd in the tree of a commit, relative to cwd
+ */
+export async function listRevisionFiles(cwd: string, commit: string): Promise<string[]> {
+  const out = await runGit(cwd, ['ls-tree', '-r', '-z', '--name-only', commit])
+  return out.split('\0').filter(Boolean)
+}
+
+/**
+ * Read file contents at a commit in one `git cat-file --batch` process
+ * @returns Contents in input order, null for paths missing from the tree
+ */
+export async function readRevisionFiles(cwd: string, commit: string, relPaths: string[]): Promise<(string | null)[]> {
+  if (relPaths.length === 0) return []
+  const output = await new Promise<Buffer>((resolve, reject) => {
+    const child = spawn('git', ['cat-file', '--batch'], { cwd })
+    const chunks: Buffer[] = []
+    child.stdout.on('data', (chunk: Buffer) => chunks.push(chunk))
+    child.on('error', reject)
+    child.on('close', (code) => {
+      if (code === 0) resolve(Buffer.concat(chunks))
+      else reject(new Error(`git cat-file exited with code ${code}`))
+    })
+    child.stdin.end(relPaths.map(p => `${commit}:./${p}\n`).join(''))
+  })
+
+  // Each answer is "<oid> <type> <size>\n<content>\n" or "<object> missing\n"
+  const contents: (string | null)[] = []
+  let pos = 0
+  for (let i = 0; i < relPaths.length; i++) {
+    const eol = output.indexOf(0x0a, pos)
+    if (eol === -1) throw new Error('Truncated git cat-file output')
+    const header = output.subarray(pos, eol).toString('utf8').split(' ')
+    pos = eol + 1
+    if (header[header.length - 1] === 'missing' || header.length !== 3) {
+      contents.push(null)
+      continue
+    }
+    const size = Number(header[2])
+    contents.push(header[1] === 'blob' ? output.subarray(pos, pos + size).toString('utf8') : null)
+    pos += size + 1
+  }
+  return contents
+}
+
+/**
+ * Read one file at a commit, or null if it is not in the tree
+ */
+export async function readRevisionFile(cwd: string, commit: string, relPath: string): Promise<string | null> {
+  const [content] = await readRevisionFiles(cwd, commit, [relPath])
+  return content
+}
+
+const DIFF_STATUSES: Record<string, FileChange['status']> = {
+  A: 'added',
+  C: 'added',
+  M: 'modified',
+  T: 'modified',
+  D: 'deleted',
+  R: 'renamed'
+}
+
+/**
+ * Files changed between two commits under cwd, paths relative to cwd
+ */
+export async function diffRevisions(cwd: string, from: string, to: string): Promise<FileChange[]> {
+  const out = await runGit(cwd, ['diff', '--name-status', '-z', '-M', '--relative', from, to])
+  const fields = out.split('\0')
+  const changes: FileChange[] = []
+  let i = 0
+  while (i < fields.length && fields[i]) {
+    const letter = fields[i++][0]
+    const status = DIFF_STATUSES[letter] || 'modified'
+    if (letter === 'R' || letter === 'C') {
+      const oldPath = fields[i++]
+      const newPath = fields[i++]
+      changes.push(letter === 'R' ? { status, path: newPath, oldPath } : { status, path: newPath })
+    } else {
+      changes.push({ status, path: fields[i++] })
+    }
+  }
+  return changes
+}
+
+/**
+ * Author email of every line of a working-tree file, from `git blame`
+ * (index 0 is line 1); empty if the file is not tracked
+ */
+export async function blameAuthors(cwd: string, relPath: string): Promise<string[]> {
+  const out = await runGit(cwd, ['blame', '--line-porcelain', '--', relPath]).catch(() => '')
+  const authors: string[] = []
+  for (const line of out.split('\n')) {
+    if (line.startsWith('author-mail ')) authors.push(line.slice('author-mail '.length).replace(/^<|>$/g, ''))
+  }
+  return authors
+}
+
+/**
+ * The author of most lines in a 1-based inclusive line range
+ */
+export function topAuthor(authors: string[], startLine: number, endLine: number): string | undefined {
+  const counts = new Map<string, number>()
+  for (const author of authors.slice(startLine - 1, endLine)) counts.set(author, (counts.get(author) || 0) + 1)
+  let top: string | undefined
+  for (const [author, count] of counts) if (!top || count > counts.get(top)!) top = author
+  return top
+}
+
+// Commits read for last commit times; older files count as old as the last of them
+const COMMIT_TIMES_MAX_COUNT = 20_000
+// Last commit times by work tree and its HEAD
+const commitTimesCache = new Map<string, Promise<Map<string, number>>>()
+
+/**
+ * Time of the last commit touching each file under cwd, in milliseconds,
+ * keyed by path relative to cwd; empty outside a git work tree. The log is
+ * read as git writes it and stops at the commit where every tracked file has
+ * been seen, or after COMMIT_TIMES_MAX_COUNT commits. Cached per HEAD.
+ */
+export async function lastCommitTimes(cwd: string): Promise<Map<string, number>> {
+  const head = (await runGit(cwd, ['rev-parse', '--verify', '--quiet', 'HEAD']).catch(() => '')).trim()
+  if (!head) return new Map() // not a work tree, or nothing committed yet
+  const root = path.resolve(cwd)
+  const key = `${root}\0${head}`
+  let times = commitTimesCache.get(key)
+  if (!times) {
+    // A new HEAD replaces the times of the old one
+    for (const cached of commitTimesCache.keys()) if (cached.startsWith(`${root}\0`)) commitTimesCache.delete(cached)
+    times = readCommitTimes(cwd, head).catch((e: Error) => {
+      console.error(`Cannot read commit times under ${root}: ${e.message}`)
+      commitTimesCache.delete(key)
+      return new Map<string, number>()
+    })
+    commitTimesCache.set(key, times)
+  }
+  return times
+}
+
+async function readCommitTimes(cwd: string, head: string): Promise<Map<string, number>> {
+  const tracked = new Set((await runGit(cwd, ['-c', 'core.quotepath=off', 'ls-files', '-z'])).split('\0').filter(Boolean))
+  const times = new Map<string, number>()
+  if (tracked.size === 0) return times
+  return new Promise((resolve, reject) => {
+    const child = spawn('git', [
+      '-c', 'core.quotepath=off', 'log', '--format=%x00%ct', '--name-only', '--no-renames', '--relative',
+      `--max-count=${COMMIT_TIMES_MAX_COUNT}`, head
+    ], { cwd })
+    let stderr = ''
+    let time = 0
+    let commits = 0
+    let unseen = tracked.size
+    let done = false
+    child.stderr.on('data', (chunk: Buffer) => { stderr += chunk })
+    // Each commit is "\0<time>", a blank line and the files it touched
+    const lines = readline.createInterface({ input: child.stdout })
+    lines.on('line', (line) => {
+      if (done) return
+      if (line.startsWith('\0')) {
+        time = Number(line.slice(1)) * 1000
+        commits++
+      } else if (line && !times.has(line)) {
+        times.set(line, time)
+        if (tracked.has(line) && --unseen === 0) {
+          done = true
+          lines.close()
+          child.kill()
+        }
+      }
+    })
+    child.on('error', reject)
+    child.on('close', (code) => {
+      if (!done && code !== 0) return reject(new Error(stderr.trim() || `git log exited with code ${code}`))
+      // Cut off by the commit limit: the rest were last touched before it
+      if (!done && commits === COMMIT_TIMES_MAX_COUNT) {
+        for (const file of tracked) if (!times.has(file)) times.set(file, time)
+      }
+      resolve(times)
+    })
+  })
+}
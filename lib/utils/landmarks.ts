@@ -0,0 +1,191 @@
+/**
+ * Landmarks Module
+ * Control-flow landmarks in a function body, stored on its symbol
+ * (`landmarks`) for navigation and for queries such as "functions that use
+ * goto": labels, `goto` (C#) and the labeled `break` / `continue` of
+ * JavaScript and TypeScript. A jump records the line of the label it goes
+ * to when that label is in the same body. Defer and recover sites are
+ * recorded as the handlers that play their part in these languages, as the
+ * error-path analysis takes throw / catch for panic / recover: `finally`
+ * blocks (code that runs however the body exits) and `catch` / `except`
+ * clauses. Nested functions are left to their own symbols.
+ */
+
+export type LandmarkKind = 'label' | 'goto' | 'break' | 'continue' | 'catch' | 'finally'
+
+export const LANDMARK_KINDS: LandmarkKind[] = ['label', 'goto', 'break', 'continue', 'catch', 'finally']
+
+export interface Landmark {
+  kind: LandmarkKind
+  line: number
+  column: number
+  label?: string // label declared, or jumped to (goto case 2 / goto default for C# switch jumps)
+  end_line?: number // last line of a labeled statement or a handler
+  target?: number // line of the label a jump goes to, when it is in the body
+}
+
+// Babel keys that hold no statements or expressions
+const SKIPPED_KEYS = new Set([
+  'loc', 'start', 'end', 'range', 'extra', 'leadingComments', 'trailingComments', 'innerComments',
+  'typeAnnotation', 'returnType', 'typeParameters', 'typeArguments'
+])
+
+// Tree-sitter nodes that start a function of their own
+const TREE_SITTER_FUNCTIONS = new Set([
+  'function_definition', 'lambda', 'method_declaration', 'constructor_declaration', 'local_function_statement',
+  'lambda_expression', 'anonymous_method_expression'
+])
+
+// Tree-sitter handler clauses: C# catch, Python except (except* too), and finally in both
+const TREE_SITTER_HANDLERS: Record<string, LandmarkKind> = {
+  catch_clause: 'catch',
+  except_clause: 'catch',
+  except_group_clause: 'catch',
+  finally_clause: 'finally'
+}
+
+// Jumps go to the innermost enclosing label of their name (break / continue)
+// or to the body's label of that name (goto)
+function resolveTargets(landmarks: Landmark[]): Landmark[] {
+  const labels = landmarks.filter(l => l.kind === 'label')
+  for (const jump of landmarks) {
+    if (jump.kind === 'label' || jump.label === undefined) continue
+    const named = labels.filter(l => l.label === jump.label)
+    const target = jump.kind === 'goto'
+      ? named[0]
+      : named.filter(l => l.line <= jump.line && (l.end_line ?? l.line) >= jump.line).pop()
+    if (target) jump.target = target.line
+  }
+  return landmarks.sort((a, b) => a.line - b.line || a.column - b.column)
+}
+
+function landmarkFacts(landmarks: Landmark[]): { landmarks?: Landmark[] } {
+  return landmarks.length > 0 ? { landmarks: resolveTargets(landmarks) } : {}
+}
+
+/**
+ * Landmarks of a Babel function body
+ */
+export function babelLandmarks(body: any): { landmarks?: Landmark[] } {
+  const landmarks: Landmark[] = []
+
+  const visit = (node: any) => {
+    if (!node || typeof node.type !== 'string') return
+    if (node.type === 'LabeledStatement' && node.label?.loc) {
+      landmarks.push({
+        kind: 'label',
+        line: node.label.loc.start.line,
+        column: node.label.loc.start.column + 1,
+        label: node.label.name,
+        end_line: node.loc?.end.line ?? node.label.loc.end.line
+      })
+    }
+    if ((node.type === 'BreakStatement' || node.type === 'ContinueStatement') && node.label && node.loc) {
+      landmarks.push({
+        kind: node.type === 'BreakStatement' ? 'break' : 'continue',
+        line: node.loc.start.line,
+        column: node.loc.start.column + 1,
+        label: node.label.name
+      })
+    }
+    if (node.type === 'CatchClause' && node.loc) {
+      landmarks.push({ kind: 'catch', line: node.loc.start.line, column: node.loc.start.column + 1, end_line: node.loc.end.line })
+    }
+    // The finalizer's block; Babel keeps no position for the finally keyword
+    if (node.type === 'TryStatement' && node.finalizer?.loc) {
+      const { start, end } = node.finalizer.loc
+      landmarks.push({ kind: 'finally', line: start.line, column: start.column + 1, end_line: end.line })
+    }
+    for (const key of Object.keys(node)) {
+      if (SKIPPED_KEYS.has(key)) continue
+      const value = node[key]
+      const children = Array.isArray(value) ? value : [value]
+      for (const child of children) {
+        if (!child || typeof child.type !== 'string' || /Function|Method/.test(child.type)) continue
+        visit(child)
+      }
+    }
+  }
+
+  visit(body)
+  return landmarkFacts(landmarks)
+}
+
+// Label a C# goto names: the identifier, or "case <value>" / "default" in a switch
+function gotoLabel(node: any): string | undefined {
+  const keyword = node.children.find((c: any) => c.type === 'case' || c.type === 'default')
+  const value = node.namedChildren.find((c: any) => c.type !== 'comment')
+  if (keyword?.type === 'default') return 'default'
+  if (keyword) return value ? `case ${value.text}` : undefined
+  return value?.text
+}
+
+/**
+ * Landmarks of a C# method or Python function body
+ */
+export function treeSitterLandmarks(body: any): { landmarks?: Landmark[] } {
+  const landmarks: Landmark[] = []
+
+  const visit = (node: any) => {
+    if (node !== body && TREE_SITTER_FUNCTIONS.has(node.type)) return
+    const at = { line: node.startPosition.row + 1, column: node.startPosition.column + 1 }
+    if (node.type === 'labeled_statement') {
+      const name = node.namedChildren.find((c: any) => c.type === 'identifier')
+      if (name) landmarks.push({ kind: 'label', ...at, label: name.text, end_line: node.endPosition.row + 1 })
+    }
+    if (node.type === 'goto_statement') {
+      const label = gotoLabel(node)
+      landmarks.push({ kind: 'goto', ...at, ...(label ? { label } : {}) })
+    }
+    if (Object.hasOwn(TREE_SITTER_HANDLERS, node.type)) {
+      landmarks.push({ kind: TREE_SITTER_HANDLERS[node.type], ...at, end_line: node.endPosition.row + 1 })
+    }
+    for (const child of node.namedChildren) visit(child)
+  }
+
+  visit(body)
+  return landmarkFacts(landmarks)
+}
+
+/**
+ * Landmarks of a symbol, of one kind or all
+ */
+export function symbolLandmarks(sym: { landmarks?: Landmark[] }, kind?: LandmarkKind): Landmark[] {
+  const landmarks = Array.isArray(sym.landmarks) ? sym.landmarks : []
+  return kind ? landmarks.filter(l => l.kind === kind) : landmarks
+}
+
+export interface LandmarkFilter {
+  kind: LandmarkKind
+  op: '=' | '<' | '<=' | '>' | '>='
+  count: number
+}
+
+/**
+ * Parse a landmark filter: a kind ("goto", at least one) or a kind compared
+ * with a count ("label>2", "continue=0")
+ * @throws Error on an unknown kind or a malformed count
+ */
+export function parseLandmarkFilter(spec: string): LandmarkFilter {
+  const match = /^\s*([a-z]+)\s*(?:(>=|<=|>|<|=)\s*(\d+))?\s*$/.exec(spec)
+  if (!match || !LANDMARK_KINDS.includes(match[1] as LandmarkKind)) {
+    throw new Error(`Invalid landmark filter "${spec}". Use a kind (${LANDMARK_KINDS.join(', ')}), optionally with a count: goto, label>2`)
+  }
+  return match[2]
+    ? { kind: match[1] as LandmarkKind, op: match[2] as LandmarkFilter['op'], count: Number(match[3]) }
+    : { kind: match[1] as LandmarkKind, op: '>=', count: 1 }
+}
+
+/**
+ * Whether a symbol's landmarks of the filter's kind number as it asks
+ */
+export function matchesLandmarkFilter(sym: { landmarks?: Landmark[] }, filter: LandmarkFilter): boolean {
+  const n = symbolLandmarks(sym, filter.kind).length
+  switch (filter.op) {
+    case '=': return n === filter.count
+    case '<': return n < filter.count
+    case '<=': return n <= filter.count
+    case '>': return n > filter.count
+    default: return n >= filter.count
+  }
+}
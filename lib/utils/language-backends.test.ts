@@ -0,0 +1,38 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { getLanguageBackend, registerLanguageBackend, supportedLanguages } from './language-backends.js'
+import { initTreeSitter } from './tree-sitter.js'
+import { extractSymbols } from '../tools/common/utils.js'
+import { SymbolIndex, indexFileContents } from '../core/symbol-index.js'
+
+test('language-backends: built-in backends by language', () => {
+  assert.equal(getLanguageBackend('typescript')!.name, 'babel')
+  assert.equal(getLanguageBackend('javascript')!.name, 'babel')
+  assert.equal(getLanguageBackend('python')!.name, 'tree-sitter-python')
+  assert.equal(getLanguageBackend('csharp')!.name, 'tree-sitter-csharp')
+  assert.equal(getLanguageBackend('text'), null)
+})
+
+test('language-backends: registered backends are used by the extractors', async () => {
+  registerLanguageBackend({
+    name: 'fake-lua',
+    languages: ['lua'],
+    extractSymbols: async code => [{ name: code.trim(), kind: 'function', line: 1, end_line: 1 }],
+    extractImports: async () => [],
+    isCodeAtPosition: async () => true
+  })
+  assert.ok(supportedLanguages().includes('lua'))
+  assert.deepEqual(await extractSymbols('init.lua', 'setup\n'), [{ name: 'setup', kind: 'function', line: 1, end_line: 1 }])
+})
+
+test('language-backends: mixed TypeScript and Python files share one index', async () => {
+  await initTreeSitter()
+  const index = new SymbolIndex()
+  await indexFileContents(index, ['web/client.ts', 'svc/server.py'], [
+    'export class Client {\n  fetch() {}\n}\n',
+    'class Server:\n    def handle(self):\n        pass\n'
+  ])
+  assert.deepEqual(index.findSymbols('Client').map(s => s.lang), ['typescript'])
+  assert.deepEqual(index.findSymbols('Server').map(s => s.lang), ['python'])
+  assert.deepEqual(index.listFiles(), ['svc/server.py', 'web/client.ts'])
+})
@@ -0,0 +1,84 @@
+/**
+ * Language Backends Module
+ * One parser backend per language family behind a common interface, so the
+ * symbol index, the semantic indexer, the dependency graph and the reference
+ * filter all dispatch the same way and mixed repos end up in one index.
+ * Python and C# use tree-sitter grammars. JavaScript/TypeScript stay on
+ * Babel: locals and their scopes (--locals) come from its scope analysis
+ * and string literals (--strings) from its AST, and no TypeScript grammar is
+ * bundled, so a tree-sitter backend for them would index less. Support for
+ * a new language is one more registered backend.
+ */
+
+import { extractImports as extractJSImports, extractJSSymbols, isJSCodeAtPosition, type ImportInfo as JSImportInfo } from './ast-js.js'
+import {
+  extractCSharpImports,
+  extractCSharpSymbols,
+  extractPythonImports,
+  extractPythonSymbols,
+  isCodeAtPosition,
+  type ImportInfo as TreeSitterImportInfo
+} from './tree-sitter.js'
+import type { ExtractOptions, SymbolInfo } from '../types/index.js'
+
+export type ImportInfo = JSImportInfo | TreeSitterImportInfo
+
+export interface LanguageBackend {
+  /** Backend name for diagnostics ("babel", "tree-sitter-python") */
+  name: string
+  /** Language IDs (as returned by detectLanguage) the backend parses */
+  languages: string[]
+  /** Definitions and references in a file; backends without scope analysis ignore `locals` */
+  extractSymbols(code: string, options?: ExtractOptions): Promise<Partial<SymbolInfo>[]>
+  /** Import statements in a file */
+  extractImports(code: string): Promise<ImportInfo[]>
+  /** False when a position (1-based line, 0-based column) is inside a comment or string */
+  isCodeAtPosition(code: string, line: number, column: number): Promise<boolean>
+}
+
+const backends = new Map<string, LanguageBackend>()
+
+/**
+ * Register a backend for its languages, replacing any earlier backend for them
+ */
+export function registerLanguageBackend(backend: LanguageBackend): void {
+  for (const lang of backend.languages) backends.set(lang, backend)
+}
+
+/**
+ * Backend for a language ID, or null if the language is not parsed
+ */
+export function getLanguageBackend(lang: string): LanguageBackend | null {
+  return backends.get(lang) || null
+}
+
+/**
+ * Language IDs with a registered backend
+ */
+export function supportedLanguages(): string[] {
+  return Array.from(backends.keys()).sort()
+}
+
+registerLanguageBackend({
+  name: 'babel',
+  languages: ['javascript', 'typescript'],
+  extractSymbols: async (code, options) => extractJSSymbols(code, options),
+  extractImports: async (code) => extractJSImports(code),
+  isCodeAtPosition: async (code, line, column) => isJSCodeAtPosition(code, line, column)
+})
+
+registerLanguageBackend({
+  name: 'tree-sitter-python',
+  languages: ['python'],
+  extractSymbols: extractPythonSymbols,
+  extractImports: extractPythonImports,
+  isCodeAtPosition: (code, line, column) => isCodeAtPosition(code, 'python', line, column)
+})
+
+registerLanguageBackend({
+  name: 'tree-sitter-csharp',
+  languages: ['csharp'],
+  extractSymbols: extractCSharpSymbols,
+  extractImports: extractCSharpImports,
+  isCodeAtPosition: (code, line, column) => isCodeAtPosition(code, 'csharp', line, column)
+})
@@ -0,0 +1,57 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { ProtoWriter, decodeDelimited, decodeFields, decodePacked, WIRE_VARINT, WIRE_LENGTH_DELIMITED } from './protobuf.js'
+
+test('protobuf: varints round-trip including multi-byte values', () => {
+  const buf = new ProtoWriter().varint(1, 1).varint(2, 300).varint(3, 2 ** 40).finish()
+  const fields = decodeFields(buf)
+
+  assert.deepEqual(fields.map(f => [f.field, f.wireType, f.value]), [
+    [1, WIRE_VARINT, 1],
+    [2, WIRE_VARINT, 300],
+    [3, WIRE_VARINT, 2 ** 40]
+  ])
+  // 300 encodes as 0xac 0x02
+  assert.deepEqual([...buf.subarray(2, 5)], [0x10, 0xac, 0x02])
+})
+
+test('protobuf: default values are omitted', () => {
+  const buf = new ProtoWriter().varint(1, 0).string(2, '').bool(3, false).packed(4, []).finish()
+  assert.equal(buf.length, 0)
+})
+
+test('protobuf: strings, packed values and nested messages', () => {
+  const buf = new ProtoWriter()
+    .string(1, 'héllo')
+    .packed(2, [1, 2, 300])
+    .message(3, w => w.string(1, 'inner'))
+    .strings(4, ['a', 'b'])
+    .finish()
+  const fields = decodeFields(buf)
+
+  assert.equal((fields[0].value as Buffer).toString('utf8'), 'héllo')
+  assert.equal(fields[0].wireType, WIRE_LENGTH_DELIMITED)
+  assert.deepEqual(decodePacked(fields[1].value as Buffer), [1, 2, 300])
+  assert.equal((decodeFields(fields[2].value as Buffer)[0].value as Buffer).toString(), 'inner')
+  assert.deepEqual(fields.filter(f => f.field === 4).map(f => (f.value as Buffer).toString()), ['a', 'b'])
+})
+
+test('protobuf: writer grows past its initial buffer', () => {
+  const long = 'x'.repeat(1000)
+  const fields = decodeFields(new ProtoWriter().string(1, long).finish())
+  assert.equal((fields[0].value as Buffer).toString(), long)
+})
+
+test('protobuf: truncated input is rejected', () => {
+  const buf = new ProtoWriter().string(1, 'hello').finish()
+  assert.throws(() => decodeFields(buf.subarray(0, 4)), /Truncated/)
+})
+
+test('protobuf: delimited streams split back into messages', () => {
+  const first = new ProtoWriter().string(1, 'a').finish()
+  const second = new ProtoWriter().string(1, 'x'.repeat(200)).finish()
+  const stream = new ProtoWriter().delimited(first).delimited(new Uint8Array(0)).delimited(second).finish()
+
+  assert.deepEqual(decodeDelimited(stream).map(m => [...m]), [[...first], [], [...second]])
+  assert.throws(() => decodeDelimited(stream.subarray(0, stream.length - 1)), /Truncated/)
+})
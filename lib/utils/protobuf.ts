@@ -0,0 +1,183 @@
+/**
+ * Minimal protobuf wire-format encoder/decoder.
+ * Covers what the exporters need (varints, length-delimited fields, packed
+ * repeated integers, nested messages) without a codegen dependency.
+ */
+
+export const WIRE_VARINT = 0
+export const WIRE_FIXED64 = 1
+export const WIRE_LENGTH_DELIMITED = 2
+export const WIRE_FIXED32 = 5
+
+export class ProtoWriter {
+  private buf: Buffer = Buffer.alloc(256)
+  private pos = 0
+
+  private ensure(size: number): void {
+    if (this.pos + size <= this.buf.length) return
+    let length = this.buf.length * 2
+    while (length < this.pos + size) length *= 2
+    const next = Buffer.alloc(length)
+    this.buf.copy(next, 0, 0, this.pos)
+    this.buf = next
+  }
+
+  private rawVarint(value: number): void {
+    this.ensure(10)
+    let v = value < 0 ? value + 2 ** 64 : value
+    while (v >= 0x80) {
+      this.buf[this.pos++] = (v % 0x80) | 0x80
+      v = Math.floor(v / 0x80)
+    }
+    this.buf[this.pos++] = v
+  }
+
+  private tag(field: number, wireType: number): void {
+    this.rawVarint(field * 8 + wireType)
+  }
+
+  /** Varint field; zero values are omitted (proto3 defaults) */
+  varint(field: number, value: number | undefined): this {
+    if (!value) return this
+    this.tag(field, WIRE_VARINT)
+    this.rawVarint(value)
+    return this
+  }
+
+  bool(field: number, value: boolean | undefined): this {
+    return value ? this.varint(field, 1) : this
+  }
+
+  /** String field; empty strings are omitted */
+  string(field: number, value: string | undefined): this {
+    if (!value) return this
+    return this.bytes(field, Buffer.from(value, 'utf8'))
+  }
+
+  bytes(field: number, data: Uint8Array): this {
+    this.tag(field, WIRE_LENGTH_DELIMITED)
+    this.rawVarint(data.length)
+    this.ensure(data.length)
+    this.buf.set(data, this.pos)
+    this.pos += data.length
+    return this
+  }
+
+  /** Repeated string field, one entry per value */
+  strings(field: number, values: string[] | undefined): this {
+    for (const v of values || []) {
+      this.bytes(field, Buffer.from(v, 'utf8'))
+    }
+    return this
+  }
+
+  /** Packed repeated varint field */
+  packed(field: number, values: number[] | undefined): this {
+    if (!values || values.length === 0) return this
+    const inner = new ProtoWriter()
+    for (const v of values) inner.rawVarint(v)
+    return this.bytes(field, inner.finish())
+  }
+
+  /** Message prefixed with its varint length, as in a delimited stream */
+  delimited(data: Uint8Array): this {
+    this.rawVarint(data.length)
+    this.ensure(data.length)
+    this.buf.set(data, this.pos)
+    this.pos += data.length
+    return this
+  }
+
+  /** Nested message field built by a callback */
+  message(field: number, build: (w: ProtoWriter) => void): this {
+    const inner = new ProtoWriter()
+    build(inner)
+    return this.bytes(field, inner.finish())
+  }
+
+  finish(): Buffer {
+    return this.buf.subarray(0, this.pos)
+  }
+}
+
+export interface ProtoField {
+  field: number
+  wireType: number
+  value: number | Buffer
+}
+
+function readVarint(buf: Uint8Array, offset: number): [number, number] {
+  let result = 0
+  let multiplier = 1
+  let pos = offset
+  while (pos < buf.length) {
+    const byte = buf[pos++]
+    result += (byte & 0x7f) * multiplier
+    if ((byte & 0x80) === 0) return [result, pos]
+    multiplier *= 0x80
+  }
+  throw new Error('Truncated varint')
+}
+
+/**
+ * Decode the top-level fields of a message
+ */
+export function decodeFields(data: Uint8Array): ProtoField[] {
+  const buf = Buffer.from(data.buffer, data.byteOffset, data.byteLength)
+  const fields: ProtoField[] = []
+  let pos = 0
+  while (pos < buf.length) {
+    const [key, afterKey] = readVarint(buf, pos)
+    const field = Math.floor(key / 8)
+    const wireType = key % 8
+    pos = afterKey
+    if (wireType === WIRE_VARINT) {
+      const [value, next] = readVarint(buf, pos)
+      fields.push({ field, wireType, value })
+      pos = next
+    } else if (wireType === WIRE_LENGTH_DELIMITED) {
+      const [length, start] = readVarint(buf, pos)
+      if (start + length > buf.length) throw new Error('Truncated field')
+      fields.push({ field, wireType, value: buf.subarray(start, start + length) })
+      pos = start + length
+    } else if (wireType === WIRE_FIXED64 || wireType === WIRE_FIXED32) {
+      const size = wireType === WIRE_FIXED64 ? 8 : 4
+      if (pos + size > buf.length) throw new Error('Truncated field')
+      fields.push({ field, wireType, value: buf.subarray(pos, pos + size) })
+      pos += size
+    } else {
+      throw new Error(`Unsupported wire type ${wireType}`)
+    }
+  }
+  return fields
+}
+
+/**
+ * Decode a packed repeated varint payload
+ */
+export function decodePacked(data: Uint8Array): number[] {
+  const values: number[] = []
+  let pos = 0
+  while (pos < data.length) {
+    const [value, next] = readVarint(data, pos)
+    values.push(value)
+    pos = next
+  }
+  return values
+}
+
+/**
+ * Split a stream of varint length-prefixed messages
+ */
+export function decodeDelimited(data: Uint8Array): Buffer[] {
+  const buf = Buffer.from(data.buffer, data.byteOffset, data.byteLength)
+  const messages: Buffer[] = []
+  let pos = 0
+  while (pos < buf.length) {
+    const [length, start] = readVarint(buf, pos)
+    if (start + length > buf.length) throw new Error('Truncated message')
+    messages.push(buf.subarray(start, start + length))
+    pos = start + length
+  }
+  return messages
+}
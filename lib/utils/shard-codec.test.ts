@@ -0,0 +1,59 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import {
+  SHARD_COMPRESSIONS,
+  StringDictionary,
+  decodeDictionary,
+  decodeShard,
+  encodeDictionary,
+  encodeShard,
+  zstdAvailable
+} from './shard-codec.js'
+import { shardChecksum } from '../core/index-integrity.js'
+import type { FileShard } from '../types/index.js'
+
+function shard(filePath: string): FileShard {
+  return {
+    path: filePath,
+    lang: 'typescript',
+    hash: 'h1',
+    symbols: [
+      { id: `${filePath}#User`, name: 'User', kind: 'class', path: filePath, lang: 'typescript', line: 1, end_line: 9 },
+      {
+        id: `${filePath}#User.find`,
+        name: 'User.find',
+        kind: 'method',
+        path: filePath,
+        lang: 'typescript',
+        line: 2,
+        end_line: 4,
+        type_params: [{ name: 'T', constraint: 'Model' }],
+        module: { name: 'odd', nested: true }
+      },
+      { id: 'vendored#Other', name: 'Other', kind: 'class', path: filePath, lang: 'typescript', line: 5, end_line: 5 }
+    ],
+    references: [{ name: 'User', path: filePath, line: 7, column: 3, call: true, receiver: 'this' }]
+  }
+}
+
+test('shard-codec: shards decode to the same contents and checksum', () => {
+  for (const compression of SHARD_COMPRESSIONS) {
+    if (compression === 'zstd' && !zstdAvailable()) continue
+    const dict = new StringDictionary()
+    const original = shard('src/user.ts')
+    const data = encodeShard(original, dict, compression)
+    const restored = decodeShard(data, decodeDictionary(encodeDictionary(dict, compression), compression), compression)
+    assert.deepEqual(restored, original, compression)
+    assert.equal(shardChecksum(restored), shardChecksum(original))
+  }
+})
+
+test('shard-codec: repeated strings are stored once in the dictionary', () => {
+  const dict = new StringDictionary()
+  const a = encodeShard(shard('src/user.ts'), dict, 'none')
+  const b = encodeShard(shard('src/user.ts'), dict, 'none')
+  assert.equal(dict.strings.filter(s => s === 'src/user.ts').length, 1)
+  assert.ok(a.length < Buffer.byteLength(JSON.stringify(shard('src/user.ts'))) * 0.8, `${a.length} bytes`)
+  assert.deepEqual(a, b)
+  assert.throws(() => decodeShard(a, new StringDictionary(), 'none'), /not in the dictionary/)
+})
@@ -0,0 +1,158 @@
+/**
+ * Shard Codec Module
+ * Compact encoding of symbol index shards for persisted formats. Strings
+ * repeated across the index (paths, names, kinds, languages) are interned in
+ * a shared dictionary and replaced by their number there, ids lose the path
+ * prefix they repeat, and the result is compressed with zstd (Node 22.15+) or
+ * brotli. Key order is preserved, so decoded shards keep their checksums.
+ */
+
+import zlib from 'zlib'
+import type { FileShard } from '../types/index.js'
+
+export type ShardCompression = 'none' | 'brotli' | 'zstd'
+
+export const SHARD_COMPRESSIONS: ShardCompression[] = ['none', 'brotli', 'zstd']
+
+// Stable codes stored in pack headers
+const COMPRESSION_CODES: Record<ShardCompression, number> = { none: 0, brotli: 1, zstd: 2 }
+
+// Keys whose string values are interned; anything else under them is boxed
+const INTERNED_KEYS = new Set(['path', 'name', 'kind', 'lang', 'receiver', 'module', 'parent', 'source'])
+
+const BROTLI_QUALITY = 5
+
+const zstd = zlib as typeof zlib & {
+  zstdCompressSync?: (data: Buffer) => Buffer
+  zstdDecompressSync?: (data: Buffer) => Buffer
+}
+
+export function zstdAvailable(): boolean {
+  return typeof zstd.zstdCompressSync === 'function'
+}
+
+/**
+ * Compression for new packs: INDEXER_COMPRESSION if set and usable, else
+ * zstd when this Node has it, else brotli
+ */
+export function defaultCompression(): ShardCompression {
+  const requested = process.env.INDEXER_COMPRESSION as ShardCompression | undefined
+  if (requested === 'none' || requested === 'brotli') return requested
+  return zstdAvailable() ? 'zstd' : 'brotli'
+}
+
+export function compressionCode(compression: ShardCompression): number {
+  return COMPRESSION_CODES[compression]
+}
+
+export function compressionOfCode(code: number): ShardCompression | null {
+  return SHARD_COMPRESSIONS.find(c => COMPRESSION_CODES[c] === code) || null
+}
+
+export function compress(compression: ShardCompression, data: Buffer): Buffer {
+  if (compression === 'brotli') {
+    return zlib.brotliCompressSync(data, { params: { [zlib.constants.BROTLI_PARAM_QUALITY]: BROTLI_QUALITY } })
+  }
+  if (compression === 'zstd') {
+    if (!zstd.zstdCompressSync) throw new Error('zstd compression needs Node 22.15 or newer')
+    return zstd.zstdCompressSync(data)
+  }
+  return data
+}
+
+export function decompress(compression: ShardCompression, data: Buffer): Buffer {
+  if (compression === 'brotli') return zlib.brotliDecompressSync(data)
+  if (compression === 'zstd') {
+    if (!zstd.zstdDecompressSync) throw new Error('zstd decompression needs Node 22.15 or newer')
+    return zstd.zstdDecompressSync(data)
+  }
+  return data
+}
+
+/**
+ * Interned strings, numbered in first-seen order
+ */
+export class StringDictionary {
+  readonly strings: string[]
+  private readonly numbers = new Map<string, number>()
+
+  constructor(strings: string[] = []) {
+    this.strings = strings
+    strings.forEach((s, i) => this.numbers.set(s, i))
+  }
+
+  intern(value: string): number {
+    let n = this.numbers.get(value)
+    if (n === undefined) {
+      n = this.strings.length
+      this.strings.push(value)
+      this.numbers.set(value, n)
+    }
+    return n
+  }
+
+  lookup(n: number): string {
+    const value = this.strings[n]
+    if (value === undefined) throw new Error(`String ${n} is not in the dictionary`)
+    return value
+  }
+}
+
+function encodeValue(value: any, dict: StringDictionary, filePath: string): any {
+  if (Array.isArray(value)) return value.map(v => encodeValue(v, dict, filePath))
+  if (!value || typeof value !== 'object') return value
+  const out: Record<string, any> = {}
+  for (const [key, v] of Object.entries(value)) {
+    if (v === undefined) continue
+    if (key === 'id' && typeof v === 'string') {
+      // '#Name' for ids under the shard's path, '=id' for anything else
+      out[key] = v.startsWith(`${filePath}#`) ? v.slice(filePath.length) : `=${v}`
+    } else if (INTERNED_KEYS.has(key)) {
+      out[key] = typeof v === 'string' ? dict.intern(v) : [encodeValue(v, dict, filePath)]
+    } else {
+      out[key] = encodeValue(v, dict, filePath)
+    }
+  }
+  return out
+}
+
+function decodeValue(value: any, dict: StringDictionary, filePath: string): any {
+  if (Array.isArray(value)) return value.map(v => decodeValue(v, dict, filePath))
+  if (!value || typeof value !== 'object') return value
+  const out: Record<string, any> = {}
+  for (const [key, v] of Object.entries(value)) {
+    if (key === 'id' && typeof v === 'string') {
+      out[key] = v.startsWith('=') ? v.slice(1) : `${filePath}${v}`
+    } else if (INTERNED_KEYS.has(key)) {
+      out[key] = typeof v === 'number' ? dict.lookup(v) : decodeValue((v as any[])[0], dict, filePath)
+    } else {
+      out[key] = decodeValue(v, dict, filePath)
+    }
+  }
+  return out
+}
+
+/**
+ * Encode a shard against a dictionary, interning its strings
+ */
+export function encodeShard(shard: FileShard, dict: StringDictionary, compression: ShardCompression): Buffer {
+  const json = JSON.stringify(encodeValue(shard, dict, shard.path))
+  return compress(compression, Buffer.from(json, 'utf8'))
+}
+
+/**
+ * Decode a shard written by encodeShard with the same dictionary
+ */
+export function decodeShard(data: Buffer, dict: StringDictionary, compression: ShardCompression): FileShard {
+  const encoded = JSON.parse(decompress(compression, data).toString('utf8'))
+  const filePath = typeof encoded.path === 'number' ? dict.lookup(encoded.path) : ''
+  return decodeValue(encoded, dict, filePath) as FileShard
+}
+
+export function encodeDictionary(dict: StringDictionary, compression: ShardCompression): Buffer {
+  return compress(compression, Buffer.from(JSON.stringify(dict.strings), 'utf8'))
+}
+
+export function decodeDictionary(data: Buffer, compression: ShardCompression): StringDictionary {
+  return new StringDictionary(JSON.parse(decompress(compression, data).toString('utf8')))
+}
@@ -0,0 +1,77 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import {
+  loadShards,
+  saveShards,
+  updateShards,
+  findStoredSymbols,
+  deleteShards,
+  closeDb
+} from './symbol-index-db.js'
+import type { FileShard } from '../types/index.js'
+
+const testCollectionId = 'test_symbols_' + Date.now()
+
+function shard(filePath: string, names: string[], hash = 'h1'): FileShard {
+  return {
+    path: filePath,
+    lang: 'typescript',
+    hash,
+    symbols: names.map((name, i) => ({
+      id: `${filePath}#${name}`,
+      name,
+      kind: 'function',
+      path: filePath,
+      lang: 'typescript',
+      line: i + 1,
+      end_line: i + 1
+    })),
+    references: [{ name: 'doWork', path: filePath, line: 10 }]
+  }
+}
+
+test('symbol-index-db: unknown collection loads as null', async () => {
+  assert.equal(await loadShards(testCollectionId + '_missing'), null)
+})
+
+test('symbol-index-db: save and load round-trips shards', async () => {
+  const diagnostics = [{ message: 'Unexpected token', line: 3, column: 5 }, { message: 'x is unused', line: 1, column: 7, analyzer: 'eslint', rule: 'no-unused-vars', severity: 'warning' as const }]
+  await saveShards(testCollectionId, [shard('src/a.ts', ['alpha']), { ...shard('src/b.ts', ['User.doWork']), diagnostics }])
+
+  const shards = await loadShards(testCollectionId)
+  assert.deepEqual(shards?.map(s => s.path), ['src/a.ts', 'src/b.ts'])
+  assert.equal(shards?.[1].symbols[0].name, 'User.doWork')
+  assert.deepEqual(shards?.[0].references, [{ name: 'doWork', path: 'src/a.ts', line: 10 }])
+  assert.equal(shards?.[0].diagnostics, undefined)
+  assert.deepEqual(shards?.[1].diagnostics, diagnostics)
+
+  await deleteShards(testCollectionId)
+})
+
+test('symbol-index-db: updateShards replaces and removes files', async () => {
+  await saveShards(testCollectionId, [shard('src/a.ts', ['alpha']), shard('src/b.ts', ['beta'])])
+  await updateShards(testCollectionId, [shard('src/b.ts', ['gamma'], 'h2')], ['src/a.ts'])
+
+  const shards = await loadShards(testCollectionId)
+  assert.deepEqual(shards?.map(s => [s.path, s.hash]), [['src/b.ts', 'h2']])
+  assert.equal((await findStoredSymbols(testCollectionId, 'beta')).length, 0)
+  assert.equal((await findStoredSymbols(testCollectionId, 'gamma')).length, 1)
+
+  await deleteShards(testCollectionId)
+})
+
+test('symbol-index-db: findStoredSymbols matches short and qualified names', async () => {
+  await saveShards(testCollectionId, [shard('src/user.ts', ['User', 'User.doWork'])])
+
+  assert.equal((await findStoredSymbols(testCollectionId, 'doWork'))[0].name, 'User.doWork')
+  assert.equal((await findStoredSymbols(testCollectionId, 'User.doWork')).length, 1)
+  assert.equal((await findStoredSymbols(testCollectionId, 'Other.doWork')).length, 0)
+
+  await deleteShards(testCollectionId)
+  assert.equal(await loadShards(testCollectionId), null)
+})
+
+// Cleanup after all tests
+test.after(() => {
+  closeDb()
+})
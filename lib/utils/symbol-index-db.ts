@@ -0,0 +1,326 @@
+import Database from 'better-sqlite3'
+import { getSymbolIndexDbPath } from './config-global.js'
+import fs from 'fs'
+import path from 'path'
+import type { FileShard, IndexedString, IndexedSymbol, ParseDiagnostic, SymbolReference, TodoComment, WideCharRun } from '../types/index.js'
+
+interface FileRow {
+  file_path: string
+  lang: string
+  hash: string | null
+  refs: string
+  checksum: string | null
+  todos: string | null
+  strings: string | null
+  diagnostics: string | null
+  wide_chars: string | null
+}
+
+interface SymbolRow {
+  file_path: string
+  data: string
+}
+
+let db: Database.Database | null = null
+
+/**
+ * Initialize and get database instance
+ */
+function getDb(): Database.Database {
+  if (!db) {
+    const dbPath = getSymbolIndexDbPath()
+    const dbDir = path.dirname(dbPath)
+
+    // Ensure directory exists
+    if (!fs.existsSync(dbDir)) {
+      fs.mkdirSync(dbDir, { recursive: true })
+    }
+
+    db = new Database(dbPath)
+    db.pragma('journal_mode = WAL')
+    // Readers in other processes wait for a writer instead of failing
+    db.pragma('busy_timeout = 5000')
+
+    // One row per indexed file
+    db.exec(`
+      CREATE TABLE IF NOT EXISTS symbol_files (
+        collection_id TEXT NOT NULL,
+        file_path TEXT NOT NULL,
+        lang TEXT NOT NULL,
+        hash TEXT,
+        refs TEXT NOT NULL,
+        checksum TEXT,
+        todos TEXT,
+        strings TEXT,
+        diagnostics TEXT,
+        wide_chars TEXT,
+        PRIMARY KEY (collection_id, file_path)
+      )
+    `)
+    // Databases created before shard checksums, TODO comments, string literals, diagnostics and wide character runs lack the columns
+    const columns = db.prepare('PRAGMA table_info(symbol_files)').all() as { name: string }[]
+    if (!columns.some(c => c.name === 'checksum')) {
+      db.exec('ALTER TABLE symbol_files ADD COLUMN checksum TEXT')
+    }
+    if (!columns.some(c => c.name === 'todos')) {
+      db.exec('ALTER TABLE symbol_files ADD COLUMN todos TEXT')
+    }
+    if (!columns.some(c => c.name === 'strings')) {
+      db.exec('ALTER TABLE symbol_files ADD COLUMN strings TEXT')
+    }
+    if (!columns.some(c => c.name === 'diagnostics')) {
+      db.exec('ALTER TABLE symbol_files ADD COLUMN diagnostics TEXT')
+    }
+    if (!columns.some(c => c.name === 'wide_chars')) {
+      db.exec('ALTER TABLE symbol_files ADD COLUMN wide_chars TEXT')
+    }
+
+    // One row per symbol definition
+    db.exec(`
+      CREATE TABLE IF NOT EXISTS symbols (
+        collection_id TEXT NOT NULL,
+        id TEXT NOT NULL,
+        file_path TEXT NOT NULL,
+        name TEXT NOT NULL,
+        short_name TEXT NOT NULL,
+        kind TEXT NOT NULL,
+        line INTEGER NOT NULL,
+        data TEXT NOT NULL,
+        PRIMARY KEY (collection_id, id)
+      )
+    `)
+
+    db.exec(`
+      CREATE INDEX IF NOT EXISTS idx_symbols_short_name
+      ON symbols(collection_id, short_name)
+    `)
+
+    db.exec(`
+      CREATE INDEX IF NOT EXISTS idx_symbols_file
+      ON symbols(collection_id, file_path)
+    `)
+
+    // Shard format each collection was written in; collections stored before formats were recorded have no row
+    db.exec(`
+      CREATE TABLE IF NOT EXISTS symbol_collections (
+        collection_id TEXT PRIMARY KEY,
+        format INTEGER NOT NULL
+      )
+    `)
+  }
+
+  return db
+}
+
+/**
+ * Close database connection
+ */
+export function closeDb(): void {
+  if (db) {
+    db.close()
+    db = null
+  }
+}
+
+function symbolShortName(name: string): string {
+  const idx = name.lastIndexOf('.')
+  return idx === -1 ? name : name.slice(idx + 1)
+}
+
+/**
+ * Parse stored JSON, or undefined if the row is damaged (the shard's
+ * checksum then fails and the file is re-indexed)
+ */
+function parseRow<T>(text: string): T | undefined {
+  try {
+    return JSON.parse(text) as T
+  } catch {
+    return undefined
+  }
+}
+
+function deleteFileRows(database: Database.Database, collectionId: string, filePath: string): void {
+  database.prepare('DELETE FROM symbols WHERE collection_id = ? AND file_path = ?').run(collectionId, filePath)
+  database.prepare('DELETE FROM symbol_files WHERE collection_id = ? AND file_path = ?').run(collectionId, filePath)
+}
+
+function insertShard(database: Database.Database, collectionId: string, shard: FileShard): void {
+  database.prepare(`
+    INSERT INTO symbol_files (collection_id, file_path, lang, hash, refs, checksum, todos, strings, diagnostics, wide_chars)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `).run(
+    collectionId,
+    shard.path,
+    shard.lang,
+    shard.hash ?? null,
+    JSON.stringify(shard.references),
+    shard.checksum ?? null,
+    shard.todos ? JSON.stringify(shard.todos) : null,
+    shard.strings ? JSON.stringify(shard.strings) : null,
+    shard.diagnostics ? JSON.stringify(shard.diagnostics) : null,
+    shard.wide_chars ? JSON.stringify(shard.wide_chars) : null
+  )
+
+  const insertSymbol = database.prepare(`
+    INSERT INTO symbols (collection_id, id, file_path, name, short_name, kind, line, data)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+  `)
+  for (const sym of shard.symbols) {
+    insertSymbol.run(
+      collectionId,
+      sym.id,
+      shard.path,
+      sym.name,
+      symbolShortName(sym.name),
+      sym.kind,
+      sym.line,
+      JSON.stringify(sym)
+    )
+  }
+}
+
+/**
+ * Load every file shard stored for a collection
+ * @returns Shards, or null if the collection has never been stored
+ */
+export async function loadShards(collectionId: string): Promise<FileShard[] | null> {
+  return new Promise((resolve) => {
+    const database = getDb()
+
+    const files = database.prepare(
+      'SELECT file_path, lang, hash, refs, checksum, todos, strings, diagnostics, wide_chars FROM symbol_files WHERE collection_id = ? ORDER BY file_path'
+    ).all(collectionId) as FileRow[]
+
+    if (files.length === 0) {
+      resolve(null)
+      return
+    }
+
+    const symbolRows = database.prepare(
+      'SELECT file_path, data FROM symbols WHERE collection_id = ? ORDER BY file_path, line'
+    ).all(collectionId) as SymbolRow[]
+
+    const symbolsByFile = new Map<string, IndexedSymbol[]>()
+    for (const row of symbolRows) {
+      const sym = parseRow<IndexedSymbol>(row.data)
+      if (!sym) continue
+      const list = symbolsByFile.get(row.file_path) || []
+      list.push(sym)
+      symbolsByFile.set(row.file_path, list)
+    }
+
+    resolve(files.map(row => ({
+      path: row.file_path,
+      lang: row.lang,
+      hash: row.hash ?? undefined,
+      symbols: symbolsByFile.get(row.file_path) || [],
+      references: parseRow<SymbolReference[]>(row.refs) || [],
+      ...(row.todos ? { todos: parseRow<TodoComment[]>(row.todos) || [] } : {}),
+      ...(row.strings ? { strings: parseRow<IndexedString[]>(row.strings) || [] } : {}),
+      ...(row.diagnostics ? { diagnostics: parseRow<ParseDiagnostic[]>(row.diagnostics) || [] } : {}),
+      ...(row.wide_chars ? { wide_chars: parseRow<WideCharRun[]>(row.wide_chars) || [] } : {}),
+      ...(row.checksum ? { checksum: row.checksum } : {})
+    })))
+  })
+}
+
+/**
+ * Shard format a collection was stored in
+ * @returns The format, 0 if it was stored without one, or null if the collection has never been stored
+ */
+export async function loadShardFormat(collectionId: string): Promise<number | null> {
+  return new Promise((resolve) => {
+    const database = getDb()
+    const stored = database.prepare('SELECT 1 FROM symbol_files WHERE collection_id = ? LIMIT 1').get(collectionId)
+    if (!stored) {
+      resolve(null)
+      return
+    }
+    const row = database.prepare('SELECT format FROM symbol_collections WHERE collection_id = ?').get(collectionId) as { format: number } | undefined
+    resolve(row ? row.format : 0)
+  })
+}
+
+/**
+ * Replace everything stored for a collection
+ * @param format - Shard format to record with the shards
+ */
+export async function saveShards(collectionId: string, shards: FileShard[], format?: number): Promise<void> {
+  return new Promise((resolve) => {
+    const database = getDb()
+
+    const transaction = database.transaction(() => {
+      database.prepare('DELETE FROM symbols WHERE collection_id = ?').run(collectionId)
+      database.prepare('DELETE FROM symbol_files WHERE collection_id = ?').run(collectionId)
+      database.prepare('DELETE FROM symbol_collections WHERE collection_id = ?').run(collectionId)
+      for (const shard of shards) {
+        insertShard(database, collectionId, shard)
+      }
+      if (format !== undefined) {
+        database.prepare('INSERT INTO symbol_collections (collection_id, format) VALUES (?, ?)').run(collectionId, format)
+      }
+    })
+
+    transaction()
+    resolve()
+  })
+}
+
+/**
+ * Write changed shards and drop removed files in a single transaction
+ */
+export async function updateShards(collectionId: string, shards: FileShard[], removed: string[]): Promise<void> {
+  return new Promise((resolve) => {
+    const database = getDb()
+
+    const transaction = database.transaction(() => {
+      for (const filePath of removed) {
+        deleteFileRows(database, collectionId, filePath)
+      }
+      for (const shard of shards) {
+        deleteFileRows(database, collectionId, shard.path)
+        insertShard(database, collectionId, shard)
+      }
+    })
+
+    transaction()
+    resolve()
+  })
+}
+
+/**
+ * Look up symbols by short or qualified name straight from the database
+ */
+export async function findStoredSymbols(collectionId: string, name: string): Promise<IndexedSymbol[]> {
+  return new Promise((resolve) => {
+    const database = getDb()
+    const rows = database.prepare(
+      'SELECT data FROM symbols WHERE collection_id = ? AND short_name = ? ORDER BY file_path, line'
+    ).all(collectionId, symbolShortName(name)) as { data: string }[]
+
+    const symbols = rows.map(row => parseRow<IndexedSymbol>(row.data)).filter((s): s is IndexedSymbol => !!s)
+    if (!name.includes('.')) {
+      resolve(symbols)
+      return
+    }
+    resolve(symbols.filter(s => s.name === name || s.name.endsWith(`.${name}`)))
+  })
+}
+
+/**
+ * Delete everything stored for a collection
+ */
+export async function deleteShards(collectionId: string): Promise<void> {
+  return new Promise((resolve) => {
+    const database = getDb()
+
+    const transaction = database.transaction(() => {
+      database.prepare('DELETE FROM symbols WHERE collection_id = ?').run(collectionId)
+      database.prepare('DELETE FROM symbol_files WHERE collection_id = ?').run(collectionId)
+      database.prepare('DELETE FROM symbol_collections WHERE collection_id = ?').run(collectionId)
+    })
+
+    transaction()
+    resolve()
+  })
+}
@@ -0,0 +1,143 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import os from 'os'
+import path from 'path'
+import { PACK_FORMAT_VERSION, SymbolPackReader, readSymbolPack, writeSymbolPack } from './symbol-pack.js'
+import { SHARD_COMPRESSIONS, zstdAvailable } from './shard-codec.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+function sym(filePath: string, name: string, line: number): IndexedSymbol {
+  return { id: `${filePath}#${name}`, name, kind: 'method', path: filePath, line, exported: true } as IndexedSymbol
+}
+
+const SHARDS: FileShard[] = [
+  { path: 'src/b.ts', lang: 'typescript', hash: 'h2', symbols: [sym('src/b.ts', 'Admin', 1), sym('src/b.ts', 'Admin.save', 2)], references: [] },
+  {
+    path: 'src/a.ts',
+    lang: 'typescript',
+    hash: 'h1',
+    symbols: [sym('src/a.ts', 'User', 1), sym('src/a.ts', 'User.save', 3), sym('src/a.ts', 'User.load', 5)],
+    references: [{ name: 'Admin', path: 'src/a.ts', line: 7, column: 2 } as any]
+  }
+]
+
+async function withPack(fn: (file: string) => Promise<void>) {
+  const dir = await fs.mkdtemp(path.join(os.tmpdir(), 'symbol-pack-'))
+  try {
+    const file = path.join(dir, 'test.idxpack')
+    await writeSymbolPack(file, SHARDS)
+    await fn(file)
+  } finally {
+    await fs.rm(dir, { recursive: true, force: true })
+  }
+}
+
+test('symbol-pack: round-trips shards sorted by path', async () => {
+  await withPack(async (file) => {
+    const shards = await readSymbolPack(file)
+    assert.deepEqual(shards, [SHARDS[1], SHARDS[0]])
+  })
+})
+
+test('symbol-pack: random-access lookups by file and symbol name', async () => {
+  await withPack(async (file) => {
+    const reader = (await SymbolPackReader.open(file))!
+    try {
+      assert.equal(reader.fileCount, 2)
+      assert.deepEqual(await reader.listFiles(), ['src/a.ts', 'src/b.ts'])
+      assert.equal((await reader.getShard('src/b.ts'))!.hash, 'h2')
+      assert.equal(await reader.getShard('src/missing.ts'), null)
+
+      assert.deepEqual((await reader.findSymbols('save')).map(s => s.name), ['User.save', 'Admin.save'])
+      assert.deepEqual((await reader.findSymbols('Admin.save')).map(s => s.path), ['src/b.ts'])
+      assert.deepEqual(await reader.findSymbols('nothing'), [])
+    } finally {
+      await reader.close()
+    }
+  })
+})
+
+test('symbol-pack: name filters per repository of a merged pack', async () => {
+  const dir = await fs.mkdtemp(path.join(os.tmpdir(), 'symbol-pack-'))
+  try {
+    const file = path.join(dir, 'merged.idxpack')
+    const merged = SHARDS.map(s => ({ ...s, path: `billing/${s.path}` }))
+    merged.push({ path: 'web/src/app.ts', lang: 'typescript', symbols: [sym('web/src/app.ts', 'App', 1)], references: [] })
+    await writeSymbolPack(file, merged, { merged: true })
+    const reader = (await SymbolPackReader.open(file))!
+    try {
+      assert.deepEqual(await reader.repositoriesDeclaring('User.save'), ['billing'])
+      assert.deepEqual(await reader.repositoriesDeclaring('App'), ['web'])
+      assert.deepEqual(await reader.repositoriesDeclaring('rarelyUsedIdentifier'), [])
+      assert.deepEqual((await reader.findSymbols('App')).map(s => s.path), ['web/src/app.ts'])
+    } finally {
+      await reader.close()
+    }
+
+    // Version 3 packs have no filters; lookups go to the name table
+    const data = await fs.readFile(file)
+    data.writeUInt32LE(3, 8)
+    await fs.writeFile(file, data)
+    const v3 = (await SymbolPackReader.open(file))!
+    try {
+      assert.equal(await v3.repositoriesDeclaring('App'), null)
+      assert.deepEqual((await v3.findSymbols('save')).map(s => s.name), ['User.save', 'Admin.save'])
+    } finally {
+      await v3.close()
+    }
+  } finally {
+    await fs.rm(dir, { recursive: true, force: true })
+  }
+})
+
+test('symbol-pack: files of another format version are not read', async () => {
+  await withPack(async (file) => {
+    const data = await fs.readFile(file)
+    data.writeUInt32LE(PACK_FORMAT_VERSION + 1, 8)
+    await fs.writeFile(file, data)
+    assert.equal(await SymbolPackReader.open(file), null)
+    assert.equal(await readSymbolPack(path.join(path.dirname(file), 'missing.idxpack')), null)
+  })
+})
+
+test('symbol-pack: round-trips with every compression', async () => {
+  const dir = await fs.mkdtemp(path.join(os.tmpdir(), 'symbol-pack-'))
+  try {
+    for (const compression of SHARD_COMPRESSIONS) {
+      if (compression === 'zstd' && !zstdAvailable()) continue
+      const file = path.join(dir, `${compression}.idxpack`)
+      await writeSymbolPack(file, SHARDS, { compression })
+      assert.deepEqual(await readSymbolPack(file), [SHARDS[1], SHARDS[0]], compression)
+    }
+  } finally {
+    await fs.rm(dir, { recursive: true, force: true })
+  }
+})
+
+test('symbol-pack: version 1 packs without a dictionary are still read', async () => {
+  const dir = await fs.mkdtemp(path.join(os.tmpdir(), 'symbol-pack-'))
+  try {
+    // Header, path, shard JSON, one file table entry, empty name table
+    const shard = SHARDS[0]
+    const pathBytes = Buffer.from(shard.path)
+    const shardBytes = Buffer.from(JSON.stringify(shard))
+    const entry = Buffer.alloc(24)
+    entry.writeBigUInt64LE(40n, 0)
+    entry.writeUInt32LE(pathBytes.length, 8)
+    entry.writeUInt32LE(shardBytes.length, 12)
+    entry.writeBigUInt64LE(BigInt(40 + pathBytes.length), 16)
+    const header = Buffer.alloc(40)
+    header.write('IDXPACK\0', 0, 'latin1')
+    header.writeUInt32LE(1, 8)
+    header.writeUInt32LE(1, 12)
+    const tableOffset = 40 + pathBytes.length + shardBytes.length
+    header.writeBigUInt64LE(BigInt(tableOffset), 24)
+    header.writeBigUInt64LE(BigInt(tableOffset + 24), 32)
+    const file = path.join(dir, 'v1.idxpack')
+    await fs.writeFile(file, Buffer.concat([header, pathBytes, shardBytes, entry]))
+    assert.deepEqual(await readSymbolPack(file), [shard])
+  } finally {
+    await fs.rm(dir, { recursive: true, force: true })
+  }
+})
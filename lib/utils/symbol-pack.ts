@@ -0,0 +1,469 @@
+/**
+ * Symbol Pack Module
+ * Compact read-only binary format for large symbol indexes
+ * (~/.indexer/symbol-packs/<collection>[@<revision>].idxpack). Readers fetch only the
+ * table entries and shards a query touches, using positional reads, so the
+ * OS page cache plays the role of a memory mapping and nothing is
+ * deserialized up front beyond the string dictionary.
+ *
+ * Layout (little-endian):
+ *   header      magic "IDXPACK\0", version u32, file count u32, name count u32,
+ *               compression u32, file table offset u64, name table offset u64,
+ *               dictionary offset u64, dictionary length u32, flags u32,
+ *               shard format u32, filter offset u64, filter length u32
+ *   data        path strings, encoded shards, name strings, posting lists,
+ *               dictionary, name filters
+ *   file table  per file, sorted by path: path offset u64, path length u32,
+ *               shard length u32, shard offset u64
+ *   name table  per short symbol name, sorted: name offset u64, name length u32,
+ *               posting count u32, posting offset u64
+ *   postings    per symbol: file index u32, symbol index u32
+ *   filters     filter count u32, then per repository: name length u32, name
+ *               (empty for an unmerged pack), filter length u32, bloom filter
+ *               over the short names its files declare
+ *
+ * Shards are encoded by shard-codec against the pack's string dictionary and
+ * compressed one by one, so a lookup still decompresses only what it reads.
+ * The only flag is PACK_MERGED, set on packs written by indexer merge.
+ * A name lookup first asks the name filters, so a name no repository
+ * declares costs no reads past them.
+ * Version 1 packs (plain shard JSON, 40-byte header) and version 2 packs
+ * (56-byte header, no shard format) are still read; their shard format is 0.
+ * Version 3 packs (60-byte header) have no name filters.
+ */
+
+import fs, { type FileHandle } from 'fs/promises'
+import path from 'path'
+import { getGlobalConfigDir, getProjectCollectionName } from './config-global.js'
+import { BloomFilter, mayHaveName, nameFilter } from './bloom-filter.js'
+import {
+  StringDictionary,
+  compressionCode,
+  compressionOfCode,
+  decodeDictionary,
+  decodeShard,
+  defaultCompression,
+  encodeDictionary,
+  encodeShard,
+  type ShardCompression
+} from './shard-codec.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+export const PACK_FORMAT_VERSION = 4
+
+const MAGIC = Buffer.from('IDXPACK\0', 'latin1')
+const HEADER_SIZE = 72
+// Header of version 1 packs, which had no compression or dictionary
+const V1_HEADER_SIZE = 40
+// Header of version 2 packs, which had no shard format
+const V2_HEADER_SIZE = 56
+// Header of version 3 packs, which had no name filters
+const V3_HEADER_SIZE = 60
+const ENTRY_SIZE = 24
+const POSTING_SIZE = 8
+// Pack of several repositories' indexes, each under its own top-level directory
+export const PACK_MERGED = 1
+// Buffered writes are flushed once this many bytes are pending
+const WRITE_CHUNK = 1 << 20
+
+interface PackHeader {
+  version: number
+  fileCount: number
+  nameCount: number
+  compression: ShardCompression
+  flags: number
+  shardFormat: number
+  fileTableOffset: number
+  nameTableOffset: number
+  filterOffset: number
+  filterLength: number // 0 for packs without name filters
+}
+
+export interface WriteSymbolPackOptions {
+  compression?: ShardCompression // defaults to defaultCompression()
+  merged?: boolean // sets PACK_MERGED
+  format?: number // shard format recorded in the header, 0 if not given
+}
+
+/**
+ * Path of the symbol pack for a project, or for one of its revisions
+ */
+export function getSymbolPackPath(projectRoot: string, revision?: string): string {
+  const collectionId = getProjectCollectionName(projectRoot)
+  const name = revision ? `${collectionId}@${revision}` : collectionId
+  return path.join(getGlobalConfigDir(), 'symbol-packs', `${name}.idxpack`)
+}
+
+function packShortName(name: string): string {
+  const idx = name.lastIndexOf('.')
+  return idx === -1 ? name : name.slice(idx + 1)
+}
+
+// Repository of a file in a merged pack, its top-level directory
+function packRepositoryOf(filePath: string): string {
+  const slash = filePath.indexOf('/')
+  return slash === -1 ? '' : filePath.slice(0, slash)
+}
+
+function encodeFilters(namesByRepository: Map<string, Set<string>>): Buffer {
+  const parts: Buffer[] = [Buffer.alloc(4)]
+  parts[0].writeUInt32LE(namesByRepository.size, 0)
+  for (const [repository, names] of [...namesByRepository].sort(([a], [b]) => (a < b ? -1 : a > b ? 1 : 0))) {
+    const nameBytes = Buffer.from(repository, 'utf8')
+    const filterBytes = nameFilter(names, false).encode()
+    const nameLength = Buffer.alloc(4)
+    nameLength.writeUInt32LE(nameBytes.length, 0)
+    const filterLength = Buffer.alloc(4)
+    filterLength.writeUInt32LE(filterBytes.length, 0)
+    parts.push(nameLength, nameBytes, filterLength, filterBytes)
+  }
+  return Buffer.concat(parts)
+}
+
+function decodeFilters(data: Buffer): Map<string, BloomFilter> | null {
+  const filters = new Map<string, BloomFilter>()
+  let at = 4
+  for (let i = 0; i < data.readUInt32LE(0); i++) {
+    const nameLength = data.readUInt32LE(at)
+    const repository = data.toString('utf8', at + 4, at + 4 + nameLength)
+    at += 4 + nameLength
+    const filterLength = data.readUInt32LE(at)
+    const filter = BloomFilter.decode(data.subarray(at + 4, at + 4 + filterLength))
+    if (!filter) return null
+    filters.set(repository, filter)
+    at += 4 + filterLength
+  }
+  return filters
+}
+
+function tableEntry(offset: number, length: number, count: number, dataOffset: number): Buffer {
+  const entry = Buffer.alloc(ENTRY_SIZE)
+  entry.writeBigUInt64LE(BigInt(offset), 0)
+  entry.writeUInt32LE(length, 8)
+  entry.writeUInt32LE(count, 12)
+  entry.writeBigUInt64LE(BigInt(dataOffset), 16)
+  return entry
+}
+
+/**
+ * Sequential writer that tracks absolute offsets
+ */
+class PackWriter {
+  offset = HEADER_SIZE
+  private pending: Buffer[] = []
+  private pendingBytes = 0
+
+  constructor(private readonly handle: FileHandle) {}
+
+  async append(data: Buffer): Promise<number> {
+    const at = this.offset
+    this.pending.push(data)
+    this.pendingBytes += data.length
+    this.offset += data.length
+    if (this.pendingBytes >= WRITE_CHUNK) await this.flush()
+    return at
+  }
+
+  async flush(): Promise<void> {
+    if (this.pending.length === 0) return
+    const chunk = Buffer.concat(this.pending)
+    await this.handle.write(chunk, 0, chunk.length, this.offset - chunk.length)
+    this.pending = []
+    this.pendingBytes = 0
+  }
+}
+
+/**
+ * Write shards to a pack file, replacing it atomically
+ */
+export async function writeSymbolPack(filePath: string, shards: FileShard[], options: WriteSymbolPackOptions = {}): Promise<void> {
+  const compression = options.compression || defaultCompression()
+  const dict = new StringDictionary()
+  await fs.mkdir(path.dirname(filePath), { recursive: true })
+  const tmpPath = `${filePath}.tmp`
+  const handle = await fs.open(tmpPath, 'w')
+  try {
+    const writer = new PackWriter(handle)
+    const sorted = [...shards].sort((a, b) => (a.path < b.path ? -1 : a.path > b.path ? 1 : 0))
+    const fileEntries: Buffer[] = []
+    const postings = new Map<string, number[]>()
+    const namesByRepository = new Map<string, Set<string>>()
+
+    for (let fileIndex = 0; fileIndex < sorted.length; fileIndex++) {
+      const shard = sorted[fileIndex]
+      const pathBytes = Buffer.from(shard.path, 'utf8')
+      const shardBytes = encodeShard(shard, dict, compression)
+      const pathOffset = await writer.append(pathBytes)
+      const shardOffset = await writer.append(shardBytes)
+      fileEntries.push(tableEntry(pathOffset, pathBytes.length, shardBytes.length, shardOffset))
+      const repository = options.merged ? packRepositoryOf(shard.path) : ''
+      const repositoryNames = namesByRepository.get(repository) || new Set<string>()
+      namesByRepository.set(repository, repositoryNames)
+      shard.symbols.forEach((sym, symbolIndex) => {
+        const key = packShortName(sym.name)
+        repositoryNames.add(key)
+        const list = postings.get(key) || []
+        list.push(fileIndex, symbolIndex)
+        postings.set(key, list)
+      })
+    }
+
+    const names = Array.from(postings.keys()).sort()
+    const nameEntries: Buffer[] = []
+    for (const name of names) {
+      const list = postings.get(name)!
+      const nameBytes = Buffer.from(name, 'utf8')
+      const postingBytes = Buffer.alloc(list.length * 4)
+      list.forEach((n, i) => postingBytes.writeUInt32LE(n, i * 4))
+      const nameOffset = await writer.append(nameBytes)
+      const postingOffset = await writer.append(postingBytes)
+      nameEntries.push(tableEntry(nameOffset, nameBytes.length, list.length / 2, postingOffset))
+    }
+
+    const fileTableOffset = await writer.append(Buffer.concat(fileEntries))
+    const nameTableOffset = await writer.append(Buffer.concat(nameEntries))
+    const dictBytes = encodeDictionary(dict, compression)
+    const dictOffset = await writer.append(dictBytes)
+    const filterBytes = encodeFilters(namesByRepository)
+    const filterOffset = await writer.append(filterBytes)
+    await writer.flush()
+
+    const header = Buffer.alloc(HEADER_SIZE)
+    MAGIC.copy(header, 0)
+    header.writeUInt32LE(PACK_FORMAT_VERSION, 8)
+    header.writeUInt32LE(sorted.length, 12)
+    header.writeUInt32LE(names.length, 16)
+    header.writeUInt32LE(compressionCode(compression), 20)
+    header.writeBigUInt64LE(BigInt(fileTableOffset), 24)
+    header.writeBigUInt64LE(BigInt(nameTableOffset), 32)
+    header.writeBigUInt64LE(BigInt(dictOffset), 40)
+    header.writeUInt32LE(dictBytes.length, 48)
+    header.writeUInt32LE(options.merged ? PACK_MERGED : 0, 52)
+    header.writeUInt32LE(options.format ?? 0, 56)
+    header.writeBigUInt64LE(BigInt(filterOffset), 60)
+    header.writeUInt32LE(filterBytes.length, 68)
+    await handle.write(header, 0, HEADER_SIZE, 0)
+  } finally {
+    await handle.close()
+  }
+  await fs.rename(tmpPath, filePath)
+}
+
+/**
+ * Random-access reader over a pack file
+ */
+export class SymbolPackReader {
+  private readonly shardCache = new Map<number, FileShard>()
+  // Name filters by repository, read on the first name lookup; null when the pack has none
+  private filters: Promise<Map<string, BloomFilter> | null> | null = null
+
+  private constructor(
+    private readonly handle: FileHandle,
+    private readonly header: PackHeader,
+    private readonly dict: StringDictionary | null // null for version 1 packs
+  ) {}
+
+  /**
+   * Open a pack file
+   * @returns The reader, or null if the file is missing or not a pack of
+   *   a known format version and compression
+   */
+  static async open(filePath: string): Promise<SymbolPackReader | null> {
+    let handle: FileHandle
+    try {
+      handle = await fs.open(filePath, 'r')
+    } catch {
+      return null
+    }
+    try {
+      const header = Buffer.alloc(HEADER_SIZE)
+      const { bytesRead } = await handle.read(header, 0, HEADER_SIZE, 0)
+      const version = bytesRead >= V1_HEADER_SIZE ? header.readUInt32LE(8) : 0
+      const size = version === 1 ? V1_HEADER_SIZE : version === 2 ? V2_HEADER_SIZE : version === 3 ? V3_HEADER_SIZE : HEADER_SIZE
+      const compression = version === 1 ? 'none' : compressionOfCode(header.readUInt32LE(20))
+      if (bytesRead < size || !header.subarray(0, MAGIC.length).equals(MAGIC) ||
+        (version < 1 || version > PACK_FORMAT_VERSION) || !compression) {
+        await handle.close()
+        return null
+      }
+
+      let dict: StringDictionary | null = null
+      if (version !== 1) {
+        const dictBytes = Buffer.alloc(header.readUInt32LE(48))
+        await handle.read(dictBytes, 0, dictBytes.length, Number(header.readBigUInt64LE(40)))
+        dict = decodeDictionary(dictBytes, compression)
+      }
+      return new SymbolPackReader(handle, {
+        version,
+        fileCount: header.readUInt32LE(12),
+        nameCount: header.readUInt32LE(16),
+        compression,
+        flags: version === 1 ? 0 : header.readUInt32LE(52),
+        shardFormat: version < 3 ? 0 : header.readUInt32LE(56),
+        fileTableOffset: Number(header.readBigUInt64LE(24)),
+        nameTableOffset: Number(header.readBigUInt64LE(32)),
+        filterOffset: version < 4 ? 0 : Number(header.readBigUInt64LE(60)),
+        filterLength: version < 4 ? 0 : header.readUInt32LE(68)
+      }, dict)
+    } catch {
+      // Damaged dictionary, or a compression this Node cannot decode
+      await handle.close()
+      return null
+    }
+  }
+
+  get fileCount(): number {
+    return this.header.fileCount
+  }
+
+  /** Shard format the pack was written in, 0 if it predates recorded formats */
+  get shardFormat(): number {
+    return this.header.shardFormat
+  }
+
+  /** Whether the pack was written by indexer merge */
+  get merged(): boolean {
+    return (this.header.flags & PACK_MERGED) !== 0
+  }
+
+  private async readAt(offset: number, length: number): Promise<Buffer> {
+    const buffer = Buffer.alloc(length)
+    await this.handle.read(buffer, 0, length, offset)
+    return buffer
+  }
+
+  private async entry(tableOffset: number, i: number): Promise<{ key: string, count: number, dataOffset: number }> {
+    const raw = await this.readAt(tableOffset + i * ENTRY_SIZE, ENTRY_SIZE)
+    const key = await this.readAt(Number(raw.readBigUInt64LE(0)), raw.readUInt32LE(8))
+    return { key: key.toString('utf8'), count: raw.readUInt32LE(12), dataOffset: Number(raw.readBigUInt64LE(16)) }
+  }
+
+  /**
+   * Binary search a sorted table for a key
+   */
+  private async lookup(tableOffset: number, size: number, key: string): Promise<{ index: number, count: number, dataOffset: number } | null> {
+    let lo = 0
+    let hi = size - 1
+    while (lo <= hi) {
+      const mid = (lo + hi) >> 1
+      const entry = await this.entry(tableOffset, mid)
+      if (entry.key === key) return { index: mid, count: entry.count, dataOffset: entry.dataOffset }
+      if (entry.key < key) lo = mid + 1
+      else hi = mid - 1
+    }
+    return null
+  }
+
+  /**
+   * Shard at a position in the file table
+   */
+  async readShard(fileIndex: number): Promise<FileShard> {
+    const cached = this.shardCache.get(fileIndex)
+    if (cached) return cached
+    const { key, count: length, dataOffset } = await this.entry(this.header.fileTableOffset, fileIndex)
+    let shard: FileShard
+    try {
+      const data = await this.readAt(dataOffset, length)
+      shard = this.dict
+        ? decodeShard(data, this.dict, this.header.compression)
+        : JSON.parse(data.toString('utf8')) as FileShard
+    } catch {
+      // Unreadable shard: an empty one without a checksum, so the file is re-indexed
+      shard = { path: key, lang: '', symbols: [], references: [] }
+    }
+    this.shardCache.set(fileIndex, shard)
+    return shard
+  }
+
+  /**
+   * Shard for a file path, or null if the file is not in the pack
+   */
+  async getShard(filePath: string): Promise<FileShard | null> {
+    const found = await this.lookup(this.header.fileTableOffset, this.header.fileCount, filePath)
+    return found ? this.readShard(found.index) : null
+  }
+
+  async listFiles(): Promise<string[]> {
+    const files: string[] = []
+    for (let i = 0; i < this.header.fileCount; i++) {
+      files.push((await this.entry(this.header.fileTableOffset, i)).key)
+    }
+    return files
+  }
+
+  async readShards(): Promise<FileShard[]> {
+    const shards: FileShard[] = []
+    for (let i = 0; i < this.header.fileCount; i++) {
+      shards.push(await this.readShard(i))
+    }
+    return shards
+  }
+
+  private nameFilters(): Promise<Map<string, BloomFilter> | null> {
+    if (!this.filters) {
+      const { filterOffset, filterLength } = this.header
+      this.filters = filterLength === 0
+        ? Promise.resolve(null)
+        : this.readAt(filterOffset, filterLength).then(decodeFilters).catch(() => null)
+    }
+    return this.filters
+  }
+
+  /**
+   * Repositories that may declare a short or qualified name ('' for an
+   * unmerged pack), or null when the pack has no name filters
+   */
+  async repositoriesDeclaring(name: string): Promise<string[] | null> {
+    const filters = await this.nameFilters()
+    if (!filters) return null
+    const short = packShortName(name)
+    return [...filters].filter(([, filter]) => mayHaveName(filter, short)).map(([repository]) => repository)
+  }
+
+  /**
+   * Symbols by short or qualified name; reads only the shards that define them
+   */
+  async findSymbols(name: string): Promise<IndexedSymbol[]> {
+    const repositories = await this.repositoriesDeclaring(name)
+    if (repositories && repositories.length === 0) return []
+    const found = await this.lookup(this.header.nameTableOffset, this.header.nameCount, packShortName(name))
+    if (!found) return []
+    const postings = await this.readAt(found.dataOffset, found.count * POSTING_SIZE)
+    const result: IndexedSymbol[] = []
+    for (let i = 0; i < found.count; i++) {
+      const shard = await this.readShard(postings.readUInt32LE(i * POSTING_SIZE))
+      const sym = shard.symbols[postings.readUInt32LE(i * POSTING_SIZE + 4)]
+      if (sym && (sym.name === name || sym.name.endsWith(`.${name}`))) result.push(sym)
+    }
+    return result
+  }
+
+  async close(): Promise<void> {
+    await this.handle.close()
+  }
+}
+
+/**
+ * Shard format of a pack file
+ * @returns The format, 0 if it was written without one, or null if there is no usable pack
+ */
+export async function readSymbolPackFormat(filePath: string): Promise<number | null> {
+  const reader = await SymbolPackReader.open(filePath)
+  if (!reader) return null
+  await reader.close()
+  return reader.shardFormat
+}
+
+/**
+ * Load every shard from a pack file
+ * @returns Shards, or null if there is no usable pack
+ */
+export async function readSymbolPack(filePath: string): Promise<FileShard[] | null> {
+  const reader = await SymbolPackReader.open(filePath)
+  if (!reader) return null
+  try {
+    return await reader.readShards()
+  } finally {
+    await reader.close()
+  }
+}
@@ -0,0 +1,144 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import os from 'os'
+import path from 'path'
+import {
+  findPackageSymbols,
+  readPackageManifest,
+  readPackageShards,
+  searchPackageSymbols,
+  updatePackageShards,
+  writePackageShards
+} from './symbol-package-shards.js'
+import { openSymbolIndex } from '../core/symbol-index.js'
+import type { SymbolStore } from './symbol-store.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+function sym(filePath: string, name: string): IndexedSymbol {
+  return { id: `${filePath}#${name}`, name, kind: 'function', path: filePath, line: 1, exported: true } as IndexedSymbol
+}
+
+function shard(filePath: string, hash: string, ...names: string[]): FileShard {
+  return { path: filePath, lang: 'typescript', hash, symbols: names.map(n => sym(filePath, n)), references: [] }
+}
+
+const SHARDS: FileShard[] = [
+  shard('services/api/server.ts', 'h1', 'serve'),
+  shard('services/api/routes.ts', 'h2', 'Router', 'Router.get'),
+  shard('services/billing/invoice.ts', 'h3', 'Invoice', 'Invoice.total'),
+  shard('index.ts', 'h4', 'main')
+]
+
+async function withDir(fn: (dir: string) => Promise<void>) {
+  const dir = await fs.mkdtemp(path.join(os.tmpdir(), 'package-shards-'))
+  try {
+    await fn(path.join(dir, 'store'))
+  } finally {
+    await fs.rm(dir, { recursive: true, force: true })
+  }
+}
+
+test('symbol-package-shards: manifest lists each package with its files and name filter', async () => {
+  await withDir(async (dir) => {
+    assert.equal(await readPackageShards(dir), null)
+    await writePackageShards(dir, SHARDS)
+
+    const manifest = (await readPackageManifest(dir))!
+    assert.deepEqual(Object.keys(manifest.packages).sort(), ['.', 'services/api', 'services/billing'])
+    assert.deepEqual(manifest.packages['services/api'].files, ['services/api/routes.ts', 'services/api/server.ts'])
+    assert.ok(manifest.packages['services/api'].bloom)
+    assert.equal(manifest.packages['services/api'].names, undefined)
+    assert.equal((await fs.readdir(dir)).length, 4)
+
+    assert.deepEqual((await readPackageShards(dir))!.map(s => s.path).sort(), SHARDS.map(s => s.path).sort())
+  })
+})
+
+test('symbol-package-shards: loads only the packages a filter accepts', async () => {
+  await withDir(async (dir) => {
+    await writePackageShards(dir, SHARDS)
+    const shards = (await readPackageShards(dir, pkg => pkg === 'services/billing'))!
+    assert.deepEqual(shards.map(s => s.path), ['services/billing/invoice.ts'])
+
+    const found = await findPackageSymbols(dir, 'Invoice.total')
+    assert.deepEqual(found.map(s => s.id), ['services/billing/invoice.ts#Invoice.total'])
+  })
+})
+
+test('symbol-package-shards: name filters skip packages that cannot match', async () => {
+  await withDir(async (dir) => {
+    await writePackageShards(dir, SHARDS)
+    const manifest = (await readPackageManifest(dir))!
+    assert.ok(Object.values(manifest.packages).every(entry => entry.bloom))
+
+    assert.deepEqual((await searchPackageSymbols(dir, 'VOICE')).map(s => s.name), ['Invoice'])
+    assert.deepEqual((await searchPackageSymbols(dir, 'ut')).map(s => s.name), ['Router'])
+    assert.deepEqual(await searchPackageSymbols(dir, 'rarely_used_identifier'), [])
+
+    // A package ruled out by its filter is never read, even when its shard is gone
+    await fs.rm(path.join(dir, manifest.packages['services/billing'].file))
+    assert.deepEqual(await findPackageSymbols(dir, 'serve'), [sym('services/api/server.ts', 'serve')])
+
+    // Manifests written before filters fall back to the name lists
+    const names: Record<string, string[]> = { '.': ['main'], 'services/api': ['Router', 'get', 'serve'], 'services/billing': ['Invoice', 'total'] }
+    for (const [pkg, entry] of Object.entries(manifest.packages)) {
+      delete entry.bloom
+      entry.names = names[pkg]
+    }
+    await fs.writeFile(path.join(dir, 'manifest.json'), JSON.stringify(manifest))
+    assert.deepEqual((await findPackageSymbols(dir, 'Router.get')).map(s => s.path), ['services/api/routes.ts'])
+    assert.deepEqual((await searchPackageSymbols(dir, 'erv')).map(s => s.name), ['serve'])
+  })
+})
+
+test('symbol-package-shards: updates rewrite only the touched packages', async () => {
+  await withDir(async (dir) => {
+    await writePackageShards(dir, SHARDS)
+    const before = (await readPackageManifest(dir))!
+    const billing = path.join(dir, before.packages['services/billing'].file)
+    const stamp = (await fs.stat(billing)).mtimeMs
+
+    await updatePackageShards(dir, [shard('services/api/server.ts', 'h5', 'serve', 'listen')], ['index.ts'])
+    const after = (await readPackageManifest(dir))!
+    assert.deepEqual(Object.keys(after.packages).sort(), ['services/api', 'services/billing'])
+    assert.deepEqual((await findPackageSymbols(dir, 'listen')).map(s => s.path), ['services/api/server.ts'])
+    assert.equal((await fs.stat(billing)).mtimeMs, stamp)
+    assert.equal((await fs.readdir(dir)).length, 3)
+
+    const api = (await readPackageShards(dir, pkg => pkg === 'services/api'))!
+    assert.deepEqual(api.map(s => [s.path, s.hash]), [['services/api/routes.ts', 'h2'], ['services/api/server.ts', 'h5']])
+  })
+})
+
+test('symbol-package-shards: openSymbolIndex loads and re-indexes only requested packages', async () => {
+  const root = await fs.mkdtemp(path.join(os.tmpdir(), 'package-shards-project-'))
+  try {
+    await fs.mkdir(path.join(root, 'services/api'), { recursive: true })
+    await fs.mkdir(path.join(root, 'services/billing'), { recursive: true })
+    await fs.writeFile(path.join(root, 'services/api/server.ts'), 'export function serve() {}\n')
+    await fs.writeFile(path.join(root, 'services/billing/invoice.ts'), 'export class Invoice {}\n')
+
+    const dir = path.join(root, '.store')
+    const store: SymbolStore = {
+      load: () => readPackageShards(dir),
+      loadPackages: (include) => readPackageShards(dir, include),
+      save: (shards) => writePackageShards(dir, shards),
+      update: (shards, removed) => updatePackageShards(dir, shards, removed),
+      findSymbols: (name) => findPackageSymbols(dir, name),
+      clear: () => fs.rm(dir, { recursive: true, force: true })
+    }
+    const full = (await openSymbolIndex(root, store)).index
+    assert.deepEqual(full.listFiles().sort(), ['services/api/server.ts', 'services/billing/invoice.ts'])
+
+    await fs.writeFile(path.join(root, 'services/api/server.ts'), 'export function serve() {}\nexport function listen() {}\n')
+    const { index, update } = await openSymbolIndex(root, store, { packages: ['./services/api/...'] })
+    assert.deepEqual(index.listFiles(), ['services/api/server.ts'])
+    assert.deepEqual(update.modified, ['services/api/server.ts'])
+    assert.deepEqual(update.removed, [])
+    assert.equal((await store.findSymbols('Invoice')).length, 1)
+    assert.equal((await store.findSymbols('listen')).length, 1)
+  } finally {
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
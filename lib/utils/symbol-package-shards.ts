@@ -0,0 +1,248 @@
+/**
+ * Package Shards Module
+ * Symbol index split into one JSON shard per package (source directory),
+ * with a manifest (~/.indexer/symbol-packages/<collection>/manifest.json)
+ * listing each package's shard file, its files and a bloom filter over the
+ * short names of the symbols it declares and their trigrams, which keeps
+ * the manifest small however many symbols there are. Readers open the
+ * manifest and load only the packages a query needs, skipping any package
+ * whose filter rules the name out and matching names exactly in the ones
+ * they load; updates rewrite only the packages whose files changed.
+ */
+
+import fs from 'fs/promises'
+import path from 'path'
+import crypto from 'crypto'
+import { getGlobalConfigDir, getProjectCollectionName } from './config-global.js'
+import { BloomFilter, mayHaveName, mayHaveNameContaining, nameFilter } from './bloom-filter.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+export const PACKAGE_SHARDS_VERSION = 1
+
+const MANIFEST = 'manifest.json'
+
+export interface PackageEntry {
+  file: string // shard file name inside the store directory
+  files: string[] // indexed files of the package, sorted
+  bloom?: string // base64 name filter over the short names of its symbols, missing in stores written before filters
+  names?: string[] // short names of its symbols, sorted, only in stores written before filters
+}
+
+export interface PackageManifest {
+  version: number
+  format?: number // shard format, missing in stores written before formats were recorded
+  timestamp: number
+  packages: Record<string, PackageEntry>
+}
+
+/**
+ * Directory holding the manifest and package shards of a project
+ */
+export function getPackageShardDir(projectRoot: string): string {
+  return path.join(getGlobalConfigDir(), 'symbol-packages', getProjectCollectionName(projectRoot))
+}
+
+/**
+ * Package (source directory) a file is stored under, '.' for the root
+ */
+export function shardPackageOf(filePath: string): string {
+  return path.posix.dirname(filePath.replace(/\\/g, '/'))
+}
+
+function shardFileName(pkg: string): string {
+  return `${crypto.createHash('sha1').update(pkg).digest('hex').slice(0, 16)}.json`
+}
+
+function packageEntry(pkg: string, shards: FileShard[]): PackageEntry {
+  const names = new Set<string>()
+  for (const shard of shards) {
+    for (const sym of shard.symbols) names.add(sym.name.slice(sym.name.lastIndexOf('.') + 1))
+  }
+  return {
+    file: shardFileName(pkg),
+    files: shards.map(s => s.path).sort(),
+    bloom: nameFilter(names).encode().toString('base64')
+  }
+}
+
+// Name filter of a package, or null when it has none
+function entryFilter(entry: PackageEntry): BloomFilter | null {
+  return entry.bloom ? BloomFilter.decode(Buffer.from(entry.bloom, 'base64')) : null
+}
+
+// Whether a package may declare a short name: its filter says so, or the
+// name list of a store written before filters does (with neither, it may)
+function mayDeclare(entry: PackageEntry, short: string): boolean {
+  const filter = entryFilter(entry)
+  if (filter) return mayHaveName(filter, short)
+  return !entry.names || entry.names.includes(short)
+}
+
+function mayDeclareContaining(entry: PackageEntry, text: string): boolean {
+  const filter = entryFilter(entry)
+  if (filter) return mayHaveNameContaining(filter, text)
+  const folded = text.toLowerCase()
+  return !entry.names || entry.names.some(n => n.toLowerCase().includes(folded))
+}
+
+function groupByPackage(shards: FileShard[]): Map<string, FileShard[]> {
+  const groups = new Map<string, FileShard[]>()
+  for (const shard of shards) {
+    const pkg = shardPackageOf(shard.path)
+    groups.set(pkg, [...(groups.get(pkg) || []), shard])
+  }
+  return groups
+}
+
+async function writeAtomic(filePath: string, text: string): Promise<void> {
+  const tmpPath = `${filePath}.tmp`
+  await fs.writeFile(tmpPath, text, 'utf8')
+  await fs.rename(tmpPath, filePath)
+}
+
+async function writePackage(dir: string, pkg: string, shards: FileShard[]): Promise<PackageEntry> {
+  const sorted = [...shards].sort((a, b) => a.path.localeCompare(b.path))
+  const entry = packageEntry(pkg, sorted)
+  await writeAtomic(path.join(dir, entry.file), JSON.stringify(sorted))
+  return entry
+}
+
+async function writeManifest(dir: string, packages: Record<string, PackageEntry>, format?: number): Promise<void> {
+  const manifest: PackageManifest = {
+    version: PACKAGE_SHARDS_VERSION,
+    ...(format !== undefined ? { format } : {}),
+    timestamp: Date.now(),
+    packages
+  }
+  await writeAtomic(path.join(dir, MANIFEST), JSON.stringify(manifest))
+}
+
+/**
+ * Read the manifest of a store directory
+ * @returns Manifest, or null if there is no usable store
+ */
+export async function readPackageManifest(dir: string): Promise<PackageManifest | null> {
+  try {
+    const data = JSON.parse(await fs.readFile(path.join(dir, MANIFEST), 'utf8')) as PackageManifest
+    if (data.version !== PACKAGE_SHARDS_VERSION || typeof data.packages !== 'object') return null
+    return data
+  } catch {
+    return null
+  }
+}
+
+async function readPackage(dir: string, entry: PackageEntry): Promise<FileShard[]> {
+  try {
+    return JSON.parse(await fs.readFile(path.join(dir, entry.file), 'utf8')) as FileShard[]
+  } catch {
+    // Unreadable package: empty shards without checksums, so its files are re-indexed
+    return entry.files.map(filePath => ({ path: filePath, lang: '', symbols: [], references: [] }))
+  }
+}
+
+/**
+ * Load the shards of the packages accepted by a filter (all packages by default)
+ * @returns Shards, or null if nothing has been stored yet
+ */
+export async function readPackageShards(dir: string, include: (pkg: string) => boolean = () => true): Promise<FileShard[] | null> {
+  const manifest = await readPackageManifest(dir)
+  if (!manifest) return null
+  const result: FileShard[] = []
+  for (const pkg of Object.keys(manifest.packages).sort()) {
+    if (include(pkg)) result.push(...await readPackage(dir, manifest.packages[pkg]))
+  }
+  return result
+}
+
+/**
+ * Shard format of a store directory
+ * @returns The format, 0 if it was written without one, or null if there is no usable store
+ */
+export async function readPackageShardFormat(dir: string): Promise<number | null> {
+  const manifest = await readPackageManifest(dir)
+  return manifest ? manifest.format ?? 0 : null
+}
+
+/**
+ * Replace everything stored in a directory with the given shards
+ * @param format - Shard format to record in the manifest
+ */
+export async function writePackageShards(dir: string, shards: FileShard[], format?: number): Promise<void> {
+  await fs.rm(dir, { recursive: true, force: true })
+  await fs.mkdir(dir, { recursive: true })
+  const packages: Record<string, PackageEntry> = {}
+  for (const [pkg, group] of groupByPackage(shards)) {
+    packages[pkg] = await writePackage(dir, pkg, group)
+  }
+  await writeManifest(dir, packages, format)
+}
+
+/**
+ * Write changed shards and drop removed files, rewriting only the packages
+ * they belong to
+ */
+export async function updatePackageShards(dir: string, shards: FileShard[], removed: string[]): Promise<void> {
+  const manifest = await readPackageManifest(dir)
+  if (!manifest) {
+    await writePackageShards(dir, shards)
+    return
+  }
+  const changed = groupByPackage(shards)
+  const touched = new Set([...changed.keys(), ...removed.map(shardPackageOf)])
+  const gone = new Set([...removed, ...shards.map(s => s.path)])
+
+  for (const pkg of touched) {
+    const entry = manifest.packages[pkg]
+    const kept = entry ? (await readPackage(dir, entry)).filter(s => !gone.has(s.path)) : []
+    const merged = [...kept, ...(changed.get(pkg) || [])]
+    if (merged.length > 0) {
+      manifest.packages[pkg] = await writePackage(dir, pkg, merged)
+    } else if (entry) {
+      delete manifest.packages[pkg]
+      await fs.rm(path.join(dir, entry.file), { force: true })
+    }
+  }
+  await writeManifest(dir, manifest.packages, manifest.format)
+}
+
+/**
+ * Symbols with a short or qualified name, loading only the packages whose
+ * filter may hold the short name
+ */
+export async function findPackageSymbols(dir: string, name: string): Promise<IndexedSymbol[]> {
+  const manifest = await readPackageManifest(dir)
+  if (!manifest) return []
+  const short = name.slice(name.lastIndexOf('.') + 1)
+  const result: IndexedSymbol[] = []
+  for (const pkg of Object.keys(manifest.packages).sort()) {
+    const entry = manifest.packages[pkg]
+    if (!mayDeclare(entry, short)) continue
+    for (const shard of await readPackage(dir, entry)) {
+      for (const sym of shard.symbols) {
+        if (sym.name === name || sym.name.endsWith(`.${name}`)) result.push(sym)
+      }
+    }
+  }
+  return result
+}
+
+/**
+ * Symbols whose short name contains some text, ignoring case, loading only
+ * the packages whose filter holds every trigram of the text
+ */
+export async function searchPackageSymbols(dir: string, text: string): Promise<IndexedSymbol[]> {
+  const manifest = await readPackageManifest(dir)
+  if (!manifest) return []
+  const folded = text.toLowerCase()
+  const result: IndexedSymbol[] = []
+  for (const pkg of Object.keys(manifest.packages).sort()) {
+    const entry = manifest.packages[pkg]
+    if (!mayDeclareContaining(entry, text)) continue
+    for (const shard of await readPackage(dir, entry)) {
+      for (const sym of shard.symbols) {
+        if (sym.name.slice(sym.name.lastIndexOf('.') + 1).toLowerCase().includes(folded)) result.push(sym)
+      }
+    }
+  }
+  return result
+}
@@ -0,0 +1,76 @@
+import fs from 'fs/promises'
+import path from 'path'
+import { getGlobalConfigDir, getProjectCollectionName } from './config-global.js'
+import type { FileShard } from '../types/index.js'
+
+const SHARD_CACHE_VERSION = 1
+
+interface ShardCacheFile {
+  version: number
+  format?: number // shard format, missing in caches written before formats were recorded
+  timestamp: number
+  shards: FileShard[]
+}
+
+/**
+ * Path of the shard cache file for a project
+ */
+export function getShardCachePath(projectRoot: string): string {
+  const collectionId = getProjectCollectionName(projectRoot)
+  return path.join(getGlobalConfigDir(), 'symbol-shards', `${collectionId}.json`)
+}
+
+async function readShardCache(projectRoot: string): Promise<ShardCacheFile | null> {
+  try {
+    const text = await fs.readFile(getShardCachePath(projectRoot), 'utf8')
+    const data = JSON.parse(text) as ShardCacheFile
+    if (data.version !== SHARD_CACHE_VERSION || !Array.isArray(data.shards)) {
+      return null
+    }
+    return data
+  } catch {
+    return null
+  }
+}
+
+/**
+ * Load cached per-file symbol shards
+ * @returns Shards, or null if there is no usable cache
+ */
+export async function loadShardCache(projectRoot: string): Promise<FileShard[] | null> {
+  return (await readShardCache(projectRoot))?.shards ?? null
+}
+
+/**
+ * Shard format of the cache
+ * @returns The format, 0 if it was written without one, or null if there is no usable cache
+ */
+export async function loadShardCacheFormat(projectRoot: string): Promise<number | null> {
+  const data = await readShardCache(projectRoot)
+  return data ? data.format ?? 0 : null
+}
+
+/**
+ * Persist per-file symbol shards
+ * @param format - Shard format to record with the shards
+ */
+export async function saveShardCache(projectRoot: string, shards: FileShard[], format?: number): Promise<void> {
+  const cachePath = getShardCachePath(projectRoot)
+  await fs.mkdir(path.dirname(cachePath), { recursive: true })
+  const data: ShardCacheFile = {
+    version: SHARD_CACHE_VERSION,
+    ...(format !== undefined ? { format } : {}),
+    timestamp: Date.now(),
+    shards
+  }
+  const tmpPath = `${cachePath}.tmp`
+  await fs.writeFile(tmpPath, JSON.stringify(data), 'utf8')
+  await fs.rename(tmpPath, cachePath)
+}
+
+/**
+ * Delete the shard cache for a project
+ */
+export async function deleteShardCache(projectRoot: string): Promise<void> {
+  await fs.rm(getShardCachePath(projectRoot), { force: true })
+}
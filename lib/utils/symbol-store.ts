@@ -0,0 +1,238 @@
+import fs from 'fs/promises'
+import { getProjectCollectionName } from './config-global.js'
+import { loadShardCache, loadShardCacheFormat, saveShardCache, deleteShardCache } from './symbol-shard-cache.js'
+import { SymbolPackReader, getSymbolPackPath, readSymbolPack, readSymbolPackFormat, writeSymbolPack } from './symbol-pack.js'
+import { loadShards, loadShardFormat, saveShards, updateShards, findStoredSymbols, deleteShards } from './symbol-index-db.js'
+import {
+  findPackageSymbols,
+  getPackageShardDir,
+  readPackageShardFormat,
+  readPackageShards,
+  updatePackageShards,
+  writePackageShards
+} from './symbol-package-shards.js'
+import type { FileShard, IndexedSymbol } from '../types/index.js'
+
+/**
+ * Persistent storage for a project's symbol index
+ */
+export interface SymbolStore {
+  /** Load all stored shards, or null if nothing has been stored yet */
+  load(): Promise<FileShard[] | null>
+  /** Load only the shards of packages (source directories) accepted by a filter, for stores split per package */
+  loadPackages?(include: (pkg: string) => boolean): Promise<FileShard[] | null>
+  /**
+   * Shard format the stored index was written in: 0 if it was stored before
+   * formats were recorded, null if nothing has been stored yet. Stores
+   * without it are taken to hold the current format.
+   */
+  loadFormat?(): Promise<number | null>
+  /** Replace the stored index, recording the shard format it is in */
+  save(shards: FileShard[], format?: number): Promise<void>
+  /** Write changed shards and drop removed files */
+  update(shards: FileShard[], removed: string[]): Promise<void>
+  /** Query stored symbols by short or qualified name without loading the index */
+  findSymbols(name: string): Promise<IndexedSymbol[]>
+  /** Delete everything stored for the project */
+  clear(): Promise<void>
+}
+
+/**
+ * SQLite-backed store (~/.indexer/symbol-index.db).
+ * Supports incremental updates, concurrent readers and direct queries.
+ */
+export class SqliteSymbolStore implements SymbolStore {
+  private readonly collectionId: string
+
+  constructor(projectRoot: string) {
+    this.collectionId = getProjectCollectionName(projectRoot)
+  }
+
+  load(): Promise<FileShard[] | null> {
+    return loadShards(this.collectionId)
+  }
+
+  loadFormat(): Promise<number | null> {
+    return loadShardFormat(this.collectionId)
+  }
+
+  save(shards: FileShard[], format?: number): Promise<void> {
+    return saveShards(this.collectionId, shards, format)
+  }
+
+  update(shards: FileShard[], removed: string[]): Promise<void> {
+    return updateShards(this.collectionId, shards, removed)
+  }
+
+  findSymbols(name: string): Promise<IndexedSymbol[]> {
+    return findStoredSymbols(this.collectionId, name)
+  }
+
+  clear(): Promise<void> {
+    return deleteShards(this.collectionId)
+  }
+}
+
+/**
+ * JSON file store (~/.indexer/symbol-shards/<collection>.json).
+ * Rewrites the whole file on every update.
+ */
+export class JsonSymbolStore implements SymbolStore {
+  constructor(private readonly projectRoot: string) {}
+
+  load(): Promise<FileShard[] | null> {
+    return loadShardCache(this.projectRoot)
+  }
+
+  loadFormat(): Promise<number | null> {
+    return loadShardCacheFormat(this.projectRoot)
+  }
+
+  save(shards: FileShard[], format?: number): Promise<void> {
+    return saveShardCache(this.projectRoot, shards, format)
+  }
+
+  async update(shards: FileShard[], removed: string[]): Promise<void> {
+    const format = await this.loadFormat()
+    const byPath = new Map((await this.load() || []).map(s => [s.path, s]))
+    for (const filePath of removed) byPath.delete(filePath)
+    for (const shard of shards) byPath.set(shard.path, shard)
+    const merged = Array.from(byPath.keys()).sort().map(p => byPath.get(p)!)
+    await this.save(merged, format ?? undefined)
+  }
+
+  async findSymbols(name: string): Promise<IndexedSymbol[]> {
+    const result: IndexedSymbol[] = []
+    for (const shard of await this.load() || []) {
+      for (const sym of shard.symbols) {
+        if (sym.name === name || sym.name.endsWith(`.${name}`)) result.push(sym)
+      }
+    }
+    return result
+  }
+
+  clear(): Promise<void> {
+    return deleteShardCache(this.projectRoot)
+  }
+}
+
+/**
+ * Binary pack store (~/.indexer/symbol-packs/<collection>.idxpack).
+ * Lookups read only the shards they need; updates rewrite the pack.
+ */
+export class PackSymbolStore implements SymbolStore {
+  private readonly packPath: string
+
+  constructor(projectRoot: string) {
+    this.packPath = getSymbolPackPath(projectRoot)
+  }
+
+  load(): Promise<FileShard[] | null> {
+    return readSymbolPack(this.packPath)
+  }
+
+  loadFormat(): Promise<number | null> {
+    return readSymbolPackFormat(this.packPath)
+  }
+
+  save(shards: FileShard[], format?: number): Promise<void> {
+    return writeSymbolPack(this.packPath, shards, { format })
+  }
+
+  async update(shards: FileShard[], removed: string[]): Promise<void> {
+    const format = await this.loadFormat()
+    const byPath = new Map((await this.load() || []).map(s => [s.path, s]))
+    for (const filePath of removed) byPath.delete(filePath)
+    for (const shard of shards) byPath.set(shard.path, shard)
+    await this.save(Array.from(byPath.values()), format ?? undefined)
+  }
+
+  async findSymbols(name: string): Promise<IndexedSymbol[]> {
+    const reader = await SymbolPackReader.open(this.packPath)
+    if (!reader) return []
+    try {
+      return await reader.findSymbols(name)
+    } finally {
+      await reader.close()
+    }
+  }
+
+  async clear(): Promise<void> {
+    await fs.rm(this.packPath, { force: true })
+  }
+}
+
+/**
+ * Per-package store (~/.indexer/symbol-packages/<collection>/).
+ * A manifest maps packages to JSON shards, so opening part of a large
+ * monorepo loads only that part; updates rewrite only changed packages.
+ */
+export class ShardedSymbolStore implements SymbolStore {
+  private readonly dir: string
+
+  constructor(projectRoot: string) {
+    this.dir = getPackageShardDir(projectRoot)
+  }
+
+  load(): Promise<FileShard[] | null> {
+    return readPackageShards(this.dir)
+  }
+
+  loadPackages(include: (pkg: string) => boolean): Promise<FileShard[] | null> {
+    return readPackageShards(this.dir, include)
+  }
+
+  loadFormat(): Promise<number | null> {
+    return readPackageShardFormat(this.dir)
+  }
+
+  save(shards: FileShard[], format?: number): Promise<void> {
+    return writePackageShards(this.dir, shards, format)
+  }
+
+  update(shards: FileShard[], removed: string[]): Promise<void> {
+    return updatePackageShards(this.dir, shards, removed)
+  }
+
+  findSymbols(name: string): Promise<IndexedSymbol[]> {
+    return findPackageSymbols(this.dir, name)
+  }
+
+  async clear(): Promise<void> {
+    await fs.rm(this.dir, { recursive: true, force: true })
+  }
+}
+
+export const SYMBOL_STORE_KINDS = ['sqlite', 'json', 'pack', 'sharded'] as const
+export type SymbolStoreKind = typeof SYMBOL_STORE_KINDS[number]
+
+/**
+ * Store of a given kind for a project
+ */
+export function createSymbolStore(kind: SymbolStoreKind, projectRoot: string): SymbolStore {
+  switch (kind) {
+    case 'json':
+      return new JsonSymbolStore(projectRoot)
+    case 'pack':
+      return new PackSymbolStore(projectRoot)
+    case 'sharded':
+      return new ShardedSymbolStore(projectRoot)
+    default:
+      return new SqliteSymbolStore(projectRoot)
+  }
+}
+
+/**
+ * Kind selected by SYMBOL_STORE ('sqlite' by default, 'json', 'pack' or 'sharded')
+ */
+export function symbolStoreKind(): SymbolStoreKind {
+  const kind = process.env.SYMBOL_STORE as SymbolStoreKind
+  return SYMBOL_STORE_KINDS.includes(kind) ? kind : 'sqlite'
+}
+
+/**
+ * Store selected by SYMBOL_STORE
+ */
+export function getSymbolStore(projectRoot: string): SymbolStore {
+  return createSymbolStore(symbolStoreKind(), projectRoot)
+}
@@ -0,0 +1,74 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { SymbolIndex, indexFileContents } from '../core/symbol-index.js'
+import { initTreeSitter } from './tree-sitter.js'
+import {
+  configureTracing,
+  exporterFromEnv,
+  flushTracing,
+  otlpTraceRequest,
+  withSpan,
+  type SpanData
+} from './tracing.js'
+
+test('tracing: parse and resolve spans per package, under the caller', async () => {
+  await initTreeSitter()
+  const spans: SpanData[] = []
+  configureTracing({ export: async (batch) => { spans.push(...batch) } })
+  try {
+    await withSpan('test.root', {}, () => indexFileContents(
+      new SymbolIndex(),
+      ['a/one.ts', 'a/two.ts', 'b/three.ts'],
+      ['export function one() {}', 'export function two() {}', 'export function three() {}']
+    ))
+    await flushTracing()
+  } finally {
+    configureTracing(null)
+  }
+
+  const byName = (name: string) => spans.filter(s => s.name === name)
+  const [root] = byName('test.root')
+  const [parse] = byName('indexer.parse')
+  const [resolve] = byName('indexer.resolve')
+  assert.equal(parse.parentSpanId, root.spanId)
+  assert.equal(resolve.parentSpanId, root.spanId)
+  assert.ok(spans.every(s => s.traceId === root.traceId))
+  assert.equal(parse.attributes['indexer.files'], 3)
+
+  const packages = byName('indexer.parse.package')
+  assert.deepEqual(packages.map(s => [s.attributes['indexer.package'], s.attributes['indexer.files']]), [['a', 2], ['b', 1]])
+  assert.ok(packages.every(s => s.parentSpanId === parse.spanId && s.endTimeNs >= s.startTimeNs))
+  assert.equal(byName('indexer.resolve.package').length, 2)
+})
+
+test('tracing: records errors and builds OTLP requests', async () => {
+  const spans: SpanData[] = []
+  configureTracing({ export: async (batch) => { spans.push(...batch) } })
+  try {
+    await assert.rejects(withSpan('failing', { 'indexer.files': 2, ratio: 0.5 }, () => {
+      throw new Error('nope')
+    }), /nope/)
+    await flushTracing()
+  } finally {
+    configureTracing(null)
+  }
+
+  const request: any = otlpTraceRequest(spans, 'svc')
+  assert.deepEqual(request.resourceSpans[0].resource.attributes, [{ key: 'service.name', value: { stringValue: 'svc' } }])
+  const [span] = request.resourceSpans[0].scopeSpans[0].spans
+  assert.equal(span.name, 'failing')
+  assert.match(span.traceId, /^[0-9a-f]{32}$/)
+  assert.deepEqual(span.status, { code: 2, message: 'nope' })
+  assert.deepEqual(span.attributes, [
+    { key: 'indexer.files', value: { intValue: '2' } },
+    { key: 'ratio', value: { doubleValue: 0.5 } }
+  ])
+})
+
+test('tracing: exporter from the environment', () => {
+  assert.equal(exporterFromEnv({}), null)
+  assert.ok(exporterFromEnv({ OTEL_EXPORTER_OTLP_ENDPOINT: 'http://collector:4318' }))
+  assert.ok(exporterFromEnv({ OTEL_TRACES_EXPORTER: 'console' }))
+  assert.equal(exporterFromEnv({ OTEL_TRACES_EXPORTER: 'console', OTEL_SDK_DISABLED: 'true' }), null)
+  assert.throws(() => exporterFromEnv({ OTEL_TRACES_EXPORTER: 'zipkin' }), /Unknown OTEL_TRACES_EXPORTER/)
+})
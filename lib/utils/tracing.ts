@@ -0,0 +1,290 @@
+/**
+ * Tracing Module
+ * OpenTelemetry-compatible spans for the indexing pipeline, without the
+ * SDK: spans nest through async context and are exported in batches over
+ * OTLP/HTTP (JSON) to a collector, or printed as JSON lines.
+ *
+ * Configured from the standard environment variables:
+ *   OTEL_TRACES_EXPORTER                    otlp, console or none (otlp when an endpoint is set, else none)
+ *   OTEL_EXPORTER_OTLP_TRACES_ENDPOINT      full URL of the traces endpoint
+ *   OTEL_EXPORTER_OTLP_ENDPOINT             collector base URL (default http://localhost:4318), /v1/traces is appended
+ *   OTEL_EXPORTER_OTLP_HEADERS              key=value,key=value sent with every export
+ *   OTEL_SERVICE_NAME                       service.name resource attribute (default indexer)
+ *   OTEL_SDK_DISABLED                       true turns tracing off
+ * or in code with configureTracing(). Without an exporter, spans cost a
+ * function call.
+ */
+
+import crypto from 'crypto'
+import { AsyncLocalStorage } from 'async_hooks'
+
+export type AttributeValue = string | number | boolean | string[]
+export type Attributes = Record<string, AttributeValue>
+
+export interface SpanData {
+  name: string
+  traceId: string // 32 hex digits
+  spanId: string // 16 hex digits
+  parentSpanId?: string
+  startTimeNs: bigint // Unix epoch nanoseconds
+  endTimeNs: bigint
+  attributes: Attributes
+  error?: string
+}
+
+export interface SpanExporter {
+  export(spans: SpanData[]): Promise<void>
+}
+
+const DEFAULT_OTLP_ENDPOINT = 'http://localhost:4318'
+const MAX_BATCH_SIZE = 512
+const FLUSH_INTERVAL_MS = 2000
+
+const timeOriginNs = BigInt(Math.round(performance.timeOrigin * 1e6))
+
+/** Current time in Unix epoch nanoseconds */
+export function nowNs(): bigint {
+  return timeOriginNs + BigInt(Math.round(performance.now() * 1e6))
+}
+
+export class Span {
+  readonly spanId = crypto.randomBytes(8).toString('hex')
+  readonly traceId: string
+  private readonly data: SpanData
+  private ended = false
+
+  constructor(name: string, attributes: Attributes, parent: Span | undefined, startTimeNs = nowNs()) {
+    this.traceId = parent ? parent.traceId : crypto.randomBytes(16).toString('hex')
+    this.data = {
+      name,
+      traceId: this.traceId,
+      spanId: this.spanId,
+      parentSpanId: parent?.spanId,
+      startTimeNs,
+      endTimeNs: startTimeNs,
+      attributes: { ...attributes }
+    }
+  }
+
+  setAttribute(key: string, value: AttributeValue): this {
+    this.data.attributes[key] = value
+    return this
+  }
+
+  setAttributes(attributes: Attributes): this {
+    Object.assign(this.data.attributes, attributes)
+    return this
+  }
+
+  recordError(e: Error): this {
+    this.data.error = e.message
+    return this
+  }
+
+  end(endTimeNs = nowNs()): void {
+    if (this.ended) return
+    this.ended = true
+    this.data.endTimeNs = endTimeNs
+    enqueue(this.data)
+  }
+}
+
+let exporter: SpanExporter | null | undefined // undefined until configured
+const queue: SpanData[] = []
+const inFlight = new Set<Promise<void>>()
+let timer: NodeJS.Timeout | null = null
+let warned = false
+let flushOnExit = false
+const context = new AsyncLocalStorage<Span>()
+
+function parseHeaders(raw: string | undefined): Record<string, string> {
+  const headers: Record<string, string> = {}
+  for (const pair of (raw || '').split(',')) {
+    const eq = pair.indexOf('=')
+    if (eq > 0) headers[decodeURIComponent(pair.slice(0, eq).trim())] = decodeURIComponent(pair.slice(eq + 1).trim())
+  }
+  return headers
+}
+
+function otlpValue(value: AttributeValue): object {
+  if (Array.isArray(value)) return { arrayValue: { values: value.map(v => ({ stringValue: v })) } }
+  if (typeof value === 'boolean') return { boolValue: value }
+  if (typeof value === 'number') return Number.isInteger(value) ? { intValue: String(value) } : { doubleValue: value }
+  return { stringValue: value }
+}
+
+function otlpAttributes(attributes: Attributes): object[] {
+  return Object.entries(attributes).map(([key, value]) => ({ key, value: otlpValue(value) }))
+}
+
+/**
+ * OTLP/HTTP JSON request body for a batch of spans
+ */
+export function otlpTraceRequest(spans: SpanData[], serviceName = 'indexer'): object {
+  return {
+    resourceSpans: [{
+      resource: { attributes: otlpAttributes({ 'service.name': serviceName }) },
+      scopeSpans: [{
+        scope: { name: 'indexer' },
+        spans: spans.map(s => ({
+          traceId: s.traceId,
+          spanId: s.spanId,
+          ...(s.parentSpanId ? { parentSpanId: s.parentSpanId } : {}),
+          name: s.name,
+          kind: 1, // SPAN_KIND_INTERNAL
+          startTimeUnixNano: String(s.startTimeNs),
+          endTimeUnixNano: String(s.endTimeNs),
+          attributes: otlpAttributes(s.attributes),
+          status: s.error === undefined ? { code: 1 } : { code: 2, message: s.error }
+        }))
+      }]
+    }]
+  }
+}
+
+/**
+ * Export spans to an OpenTelemetry collector over OTLP/HTTP with JSON bodies
+ * @param endpoint - Traces endpoint, e.g. http://localhost:4318/v1/traces
+ */
+export function otlpExporter(endpoint: string, headers: Record<string, string> = {}, serviceName?: string): SpanExporter {
+  return {
+    async export(spans) {
+      const res = await fetch(endpoint, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', ...headers },
+        body: JSON.stringify(otlpTraceRequest(spans, serviceName))
+      })
+      if (!res.ok) throw new Error(`${endpoint} answered ${res.status}`)
+    }
+  }
+}
+
+/**
+ * Print every span as a JSON line on stderr
+ */
+export function consoleExporter(write: (line: string) => void = line => process.stderr.write(line)): SpanExporter {
+  return {
+    async export(spans) {
+      for (const s of spans) {
+        const durationMs = Number(s.endTimeNs - s.startTimeNs) / 1e6
+        write(JSON.stringify({
+          type: 'span',
+          name: s.name,
+          trace_id: s.traceId,
+          span_id: s.spanId,
+          parent_span_id: s.parentSpanId,
+          duration_ms: durationMs,
+          attributes: s.attributes,
+          ...(s.error === undefined ? {} : { error: s.error })
+        }) + '\n')
+      }
+    }
+  }
+}
+
+/**
+ * The exporter the environment asks for, or null when tracing is off
+ * @throws Error on an unknown OTEL_TRACES_EXPORTER
+ */
+export function exporterFromEnv(env: NodeJS.ProcessEnv = process.env): SpanExporter | null {
+  if (env.OTEL_SDK_DISABLED === 'true') return null
+  const endpoint = env.OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+    || (env.OTEL_EXPORTER_OTLP_ENDPOINT ? `${env.OTEL_EXPORTER_OTLP_ENDPOINT.replace(/\/$/, '')}/v1/traces` : undefined)
+  const kind = env.OTEL_TRACES_EXPORTER || (endpoint ? 'otlp' : 'none')
+  switch (kind) {
+    case 'none':
+      return null
+    case 'console':
+      return consoleExporter()
+    case 'otlp':
+      return otlpExporter(endpoint || `${DEFAULT_OTLP_ENDPOINT}/v1/traces`, parseHeaders(env.OTEL_EXPORTER_OTLP_HEADERS), env.OTEL_SERVICE_NAME)
+  }
+  throw new Error(`Unknown OTEL_TRACES_EXPORTER "${kind}" (expected otlp, console or none)`)
+}
+
+/**
+ * Send spans to an exporter, or turn tracing off with null. Replaces the
+ * exporter configured from the environment.
+ */
+export function configureTracing(next: SpanExporter | null): void {
+  exporter = next
+}
+
+export function tracingEnabled(): boolean {
+  if (exporter === undefined) {
+    try {
+      exporter = exporterFromEnv()
+    } catch (e: any) {
+      process.emitWarning(e.message)
+      exporter = null
+    }
+  }
+  return exporter !== null
+}
+
+function exportQueued(): void {
+  if (timer) {
+    clearTimeout(timer)
+    timer = null
+  }
+  if (!exporter || queue.length === 0) return
+  const batch = queue.splice(0, queue.length)
+  const pending = exporter.export(batch).catch((e: Error) => {
+    if (!warned) process.emitWarning(`Trace export failed: ${e.message}`)
+    warned = true
+  }).finally(() => inFlight.delete(pending))
+  inFlight.add(pending)
+}
+
+function enqueue(span: SpanData): void {
+  if (!tracingEnabled()) return
+  if (!flushOnExit) {
+    flushOnExit = true
+    process.once('beforeExit', () => void flushTracing())
+  }
+  queue.push(span)
+  if (queue.length >= MAX_BATCH_SIZE) exportQueued()
+  else if (!timer) {
+    timer = setTimeout(exportQueued, FLUSH_INTERVAL_MS)
+    timer.unref()
+  }
+}
+
+/**
+ * Export every ended span now and wait for the exports to finish
+ */
+export async function flushTracing(): Promise<void> {
+  exportQueued()
+  await Promise.all(inFlight)
+}
+
+/** The span the current async context runs in */
+export function activeSpan(): Span | undefined {
+  return context.getStore()
+}
+
+/**
+ * Start a span under the active one. The caller ends it; it does not
+ * become active. Null when tracing is off.
+ */
+export function startSpan(name: string, attributes: Attributes = {}, startTimeNs?: bigint): Span | null {
+  if (!tracingEnabled()) return null
+  return new Span(name, attributes, activeSpan(), startTimeNs)
+}
+
+/**
+ * Run a function in a new span that is active for its duration, recording
+ * a thrown error on it
+ */
+export async function withSpan<T>(name: string, attributes: Attributes, fn: (span: Span | null) => T | Promise<T>): Promise<T> {
+  const span = startSpan(name, attributes)
+  if (!span) return fn(null)
+  try {
+    return await context.run(span, () => fn(span))
+  } catch (e: any) {
+    span.recordError(e)
+    throw e
+  } finally {
+    span.end()
+  }
+}
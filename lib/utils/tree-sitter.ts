@@ -2,6 +2,11 @@ import path from 'path'
 import { fileURLToPath } from 'url'
 import { Parser, Language } from 'web-tree-sitter'
 import type { SymbolInfo } from '../types/index.js'
+import { parsePythonDoc, parseXmlDoc, type DocInfo } from './doc-comments.js'
+import { csharpTagLiteral, fieldTags, type TagLiteral, type TagSource } from './field-tags.js'
+import { fingerprintTokens, treeSitterTokens } from './fingerprint.js'
+import { treeSitterCaught, treeSitterErrorFacts } from './error-facts.js'
+import { treeSitterLandmarks } from './landmarks.js'
 
 type SyntaxNode = any
 type Point = any
@@ -54,6 +59,75 @@ function walk(node: SyntaxNode, cb: (node: SyntaxNode) => void): void {
   }
 }
 
+function sameNode(a: SyntaxNode | null | undefined, b: SyntaxNode | null | undefined): boolean {
+  return !!a && !!b && a.type === b.type && a.startIndex === b.startIndex && a.endIndex === b.endIndex
+}
+
+function isCallNode(node: SyntaxNode | null | undefined): boolean {
+  return !!node && (node.type === 'call' || node.type === 'invocation_expression')
+}
+
+// Call-site details when an identifier is the target of a call (foo(), obj.foo())
+function callInfo(node: SyntaxNode): Partial<SymbolInfo> {
+  const parent = node.parent
+  if (!parent) return {}
+  if (isCallNode(parent) && sameNode(parent.childForFieldName('function'), node)) {
+    return { call: true, ...(treeSitterCaught(parent) ? { caught: true } : {}) }
+  }
+  const isMember =
+    (parent.type === 'attribute' && sameNode(parent.childForFieldName('attribute'), node)) ||
+    (parent.type === 'member_access_expression' && sameNode(parent.childForFieldName('name'), node))
+  const grand = parent.parent
+  if (!isMember || !isCallNode(grand) || !sameNode(grand.childForFieldName('function'), parent)) {
+    return {}
+  }
+  const object = parent.childForFieldName('object') || parent.childForFieldName('expression')
+  return { call: true, ...(object ? { receiver: object.text } : {}), ...(treeSitterCaught(grand) ? { caught: true } : {}) }
+}
+
+// Type arguments when an identifier names a generic instantiation (List<int>)
+function typeArgInfo(node: SyntaxNode): Partial<SymbolInfo> {
+  const parent = node.parent
+  if (!parent || parent.type !== 'generic_name' || !sameNode(parent.namedChildren[0], node)) return {}
+  const list = parent.namedChildren.find((c: any) => c.type === 'type_argument_list')
+  const args = (list?.namedChildren || []).map((c: any) => c.text.replace(/\s+/g, ' '))
+  return args.length > 0 ? { type_args: args } : {}
+}
+
+// Nodes whose `left` an identifier in them is written to, and the target lists in between (a, b = pair)
+const WRITING_NODES = new Set(['assignment', 'augmented_assignment', 'assignment_expression', 'for_statement', 'for_in_clause'])
+const TARGET_LISTS = new Set(['pattern_list', 'tuple_pattern', 'list_pattern'])
+const IMPORT_NODES = new Set(['import_statement', 'import_from_statement', 'using_directive'])
+
+// What an identifier does besides being read: written, passed by reference
+// (C# ref / out / &), imported, named as a type or as a base
+function roleInfo(node: SyntaxNode): Partial<SymbolInfo> {
+  let target = node
+  const parent = node.parent
+  if (parent && (parent.type === 'attribute' || parent.type === 'member_access_expression') && !sameNode(parent.namedChildren[0], node)) {
+    target = parent
+  }
+  let outer = target.parent
+  while (outer && TARGET_LISTS.has(outer.type)) {
+    target = outer
+    outer = outer.parent
+  }
+  if (outer && WRITING_NODES.has(outer.type) && sameNode(outer.childForFieldName('left'), target)) return { role: 'write' }
+  if (outer && (outer.type === 'postfix_unary_expression' || outer.type === 'prefix_unary_expression')) {
+    if (/^(\+\+|--)|(\+\+|--)$/.test(outer.text)) return { role: 'write' }
+    if (outer.text.startsWith('&')) return { role: 'address' }
+  }
+  if (outer?.type === 'argument' && outer.children.some((c: SyntaxNode) => c.type === 'ref' || c.type === 'out')) return { role: 'address' }
+
+  for (let child = node, above = node.parent; above; child = above, above = above.parent) {
+    if (IMPORT_NODES.has(above.type)) return { role: 'import' }
+    if (above.type === 'base_list' || (above.type === 'argument_list' && above.parent?.type === 'class_definition')) return { role: 'implement' }
+    if (above.type === 'type' || sameNode(above.childForFieldName('type'), child)) return { role: 'type' }
+    if (above.type === 'block' || above.type.endsWith('statement') || above.type.endsWith('declaration')) break
+  }
+  return {}
+}
+
 function collectReferences(node: SyntaxNode, symbols: Partial<SymbolInfo>[]): void {
   if (node.type === 'identifier' || node.type === 'type_identifier' || node.type === 'field_identifier') {
     symbols.push({
@@ -61,7 +135,10 @@ function collectReferences(node: SyntaxNode, symbols: Partial<SymbolInfo>[]): vo
       kind: 'reference',
       line: node.startPosition.row + 1,
       end_line: node.endPosition.row + 1,
-      column: node.startPosition.column + 1
+      column: node.startPosition.column + 1,
+      ...callInfo(node),
+      ...roleInfo(node),
+      ...typeArgInfo(node)
     })
   }
   for (let i = 0; i < node.childCount; i++) {
@@ -69,9 +146,94 @@ function collectReferences(node: SyntaxNode, symbols: Partial<SymbolInfo>[]): vo
   }
 }
 
+/**
+ * Syntax errors tree-sitter recovered from, as extractor entries (kind
+ * "diagnostic"): text it had to skip and tokens it assumed were there
+ */
+function collectSyntaxErrors(node: SyntaxNode, symbols: Partial<SymbolInfo>[]): void {
+  if (!node.hasError && !node.isMissing) return
+  const at = { line: node.startPosition.row + 1, column: node.startPosition.column + 1 }
+  if (node.isMissing) {
+    symbols.push({ name: `Syntax error: missing ${JSON.stringify(node.type)}`, kind: 'diagnostic', ...at })
+    return
+  }
+  if (node.isError) {
+    const text = node.text.split('\n')[0].trim()
+    symbols.push({ name: `Syntax error: unexpected ${JSON.stringify(text.length > 40 ? `${text.slice(0, 40)}...` : text)}`, kind: 'diagnostic', ...at })
+  }
+  for (let i = 0; i < node.childCount; i++) {
+    collectSyntaxErrors(node.child(i)!, symbols)
+  }
+}
+
 /*
   -------- Python --------
 */
+// Name column and declaration header (up to the body) of a definition
+function declInfo(node: SyntaxNode, exported: boolean, doc?: string, docInfo?: DocInfo): Partial<SymbolInfo> {
+  const info: Partial<SymbolInfo> = { exported }
+  const nameNode = node.childForFieldName('name')
+  if (nameNode) info.column = nameNode.startPosition.column + 1
+  const body = node.childForFieldName('body')
+  if (body) {
+    const header = node.text.slice(0, body.startIndex - node.startIndex)
+      .replace(/\s+/g, ' ')
+      .replace(/:$/, '')
+      .trim()
+    if (header) info.signature = header
+  }
+  if (doc) info.doc = doc
+  if (docInfo) info.doc_info = docInfo
+  return info
+}
+
+// Structural fingerprint of a function or method body, for duplicate detection
+function bodyFingerprint(node: SyntaxNode): Partial<SymbolInfo> {
+  const body = node.childForFieldName('body')
+  return body ? fingerprintTokens(treeSitterTokens(body)) || {} : {}
+}
+
+// Error types a function or method throws and wraps, for the error-path analysis
+function bodyErrorFacts(node: SyntaxNode): Partial<SymbolInfo> {
+  const body = node.childForFieldName('body')
+  return body ? treeSitterErrorFacts(body) : {}
+}
+
+// Labels, goto and handlers in a C# method or Python function body
+function bodyLandmarks(node: SyntaxNode): Partial<SymbolInfo> {
+  const body = node.childForFieldName('body')
+  return body ? treeSitterLandmarks(body) : {}
+}
+
+function pythonDocstring(node: SyntaxNode): string | undefined {
+  const first = node.childForFieldName('body')?.namedChildren[0]
+  const str = first?.type === 'expression_statement' ? first.namedChildren[0] : null
+  if (!str || str.type !== 'string') return undefined
+  const text = str.text
+    .replace(/^[rRuUbB]*("{3}|'{3}|"|')/, '')
+    .replace(/("{3}|'{3}|"|')$/, '')
+    .split('\n')
+    .map((l: string) => l.trim())
+    .join('\n')
+    .trim()
+  return text || undefined
+}
+
+// Decorator names on a definition: @given(st.integers()) -> given
+function pythonDecorators(node: SyntaxNode): Partial<SymbolInfo> {
+  if (node.parent?.type !== 'decorated_definition') return {}
+  const names = node.parent.namedChildren
+    .filter((c: any) => c.type === 'decorator')
+    .map((c: any) => c.text.replace(/^@/, '').replace(/\(.*$/s, '').trim())
+    .filter(Boolean)
+  return names.length > 0 ? { decorators: names } : {}
+}
+
+function pythonDeclInfo(node: SyntaxNode, exported: boolean): Partial<SymbolInfo> {
+  const doc = pythonDocstring(node)
+  return { ...declInfo(node, exported, doc, doc ? parsePythonDoc(doc) : undefined), ...pythonDecorators(node) }
+}
+
 export async function extractPythonSymbols(code: string): Promise<Partial<SymbolInfo>[]> {
   const symbols: Partial<SymbolInfo>[] = []
   const lang = await loadLanguage('python')
@@ -79,6 +241,7 @@ export async function extractPythonSymbols(code: string): Promise<Partial<Symbol
   const tree = parser.parse(code)
   if (!tree) return []
   collectReferences(tree.rootNode, symbols)
+  collectSyntaxErrors(tree.rootNode, symbols)
 
   walk(tree.rootNode, (n) => {
     if (n.type === 'function_definition') {
@@ -88,7 +251,11 @@ export async function extractPythonSymbols(code: string): Promise<Partial<Symbol
           name,
           kind: 'function',
           line: n.startPosition.row + 1,
-          end_line: n.endPosition.row + 1
+          end_line: n.endPosition.row + 1,
+          ...pythonDeclInfo(n, !name.startsWith('_')),
+          ...bodyFingerprint(n),
+          ...bodyErrorFacts(n),
+          ...bodyLandmarks(n)
         })
       }
     }
@@ -96,11 +263,36 @@ export async function extractPythonSymbols(code: string): Promise<Partial<Symbol
     if (n.type === 'class_definition') {
       const name = n.childForFieldName('name')?.text
       if (name) {
-        symbols.push({
+        const sym: Partial<SymbolInfo> = {
           name,
           kind: 'class',
           line: n.startPosition.row + 1,
-          end_line: n.endPosition.row + 1
+          end_line: n.endPosition.row + 1,
+          ...pythonDeclInfo(n, !name.startsWith('_'))
+        }
+        const bases = (n.childForFieldName('superclasses')?.namedChildren || [])
+          .filter((c: any) => c.type === 'identifier' || c.type === 'attribute')
+          .map((c: any) => c.text)
+        if (bases.length > 0) sym.bases = bases
+        symbols.push(sym)
+      }
+    }
+
+    // type Foo = Baz, and at module level Foo: TypeAlias = Baz
+    const annotated = n.type === 'assignment' && /(^|\.)TypeAlias$/.test(n.childForFieldName('type')?.text || '') &&
+      n.parent?.parent?.type === 'module'
+    if (n.type === 'type_alias_statement' || annotated) {
+      const nameNode = annotated ? n.childForFieldName('left') : n.childForFieldName('left')?.descendantsOfType('identifier')[0]
+      const target = pythonAliasedName(n.childForFieldName('right'))
+      if (nameNode?.type === 'identifier' && target) {
+        symbols.push({
+          name: nameNode.text,
+          kind: 'type',
+          line: n.startPosition.row + 1,
+          end_line: n.endPosition.row + 1,
+          column: nameNode.startPosition.column + 1,
+          exported: !nameNode.text.startsWith('_'),
+          alias_of: target
         })
       }
     }
@@ -109,6 +301,15 @@ export async function extractPythonSymbols(code: string): Promise<Partial<Symbol
   return symbols
 }
 
+// Type a Python alias's value names: Baz, models.Baz, Mapping[str, Baz] -> Mapping
+function pythonAliasedName(node: SyntaxNode | null): string | null {
+  let target = node
+  if (target?.type === 'type') target = target.namedChildren[0]
+  if (target?.type === 'subscript') target = target.childForFieldName('value')
+  if (target?.type === 'generic_type') target = target.namedChildren[0]
+  return target && (target.type === 'identifier' || target.type === 'attribute') ? target.text : null
+}
+
 // -----------------------------------------------------------------------
 
 function hasAttribute(node: SyntaxNode, names: string[]): boolean {
@@ -130,6 +331,111 @@ function hasModifier(node: SyntaxNode, modifier: string): boolean {
   )
 }
 
+function baseTypeNames(node: SyntaxNode): string[] {
+  const base = node.children.find((c: any) => c.type === 'base_list')
+  if (!base) {
+    return []
+  }
+  return base.namedChildren
+    .map((c: any) => c.text.replace(/<.*$/s, '').trim())
+    .filter((t: string) => t.length > 0)
+}
+
+function interfaceMemberNames(node: SyntaxNode): string[] {
+  return node.descendantsOfType(['method_declaration', 'property_declaration'])
+    .map((m: any) => m.childForFieldName('name')?.text)
+    .filter(Boolean)
+}
+
+// Contiguous /// comments above a declaration, as raw XML
+function xmlDocComment(node: SyntaxNode): string | undefined {
+  const lines: string[] = []
+  let prev = node.previousNamedSibling
+  while (prev && prev.type === 'comment' && prev.text.startsWith('///')) {
+    lines.unshift(prev.text.replace(/^\/\/\/ ?/, ''))
+    prev = prev.previousNamedSibling
+  }
+  const xml = lines.join('\n').trim()
+  return xml || undefined
+}
+
+// Message of an [Obsolete] / [Obsolete("...")] attribute, or undefined
+function obsoleteMessage(node: SyntaxNode): string | undefined {
+  for (const attr of node.children.filter((c: any) => c.type === 'attribute_list')) {
+    const match = attr.text.match(/\b(?:System\.)?Obsolete(?:Attribute)?\b(?:\s*\(\s*@?"((?:[^"\\]|\\.)*)")?/)
+    if (match) return match[1] || ''
+  }
+  return undefined
+}
+
+// Type parameters and their where-clause constraints: class Repo<T> where T : IEntity, new()
+function csharpTypeParams(node: SyntaxNode): Partial<SymbolInfo> {
+  const list = node.childForFieldName('type_parameters') ||
+    node.namedChildren.find((c: any) => c.type === 'type_parameter_list')
+  const params = (list?.namedChildren || []).filter((c: any) => c.type === 'type_parameter')
+  if (params.length === 0) return {}
+
+  const constraints = new Map<string, string>()
+  for (const clause of node.namedChildren.filter((c: any) => c.type === 'type_parameter_constraints_clause')) {
+    const target = clause.childForFieldName('target') || clause.namedChildren.find((c: any) => c.type === 'identifier')
+    const parts = clause.namedChildren.filter((c: any) => c.type === 'type_parameter_constraint').map((c: any) => c.text)
+    if (target && parts.length > 0) constraints.set(target.text, parts.join(', '))
+  }
+
+  return {
+    type_params: params.map((p: any) => {
+      const name = (p.childForFieldName('name') || p.namedChildren.find((c: any) => c.type === 'identifier') || p).text
+      return constraints.has(name) ? { name, constraint: constraints.get(name) } : { name }
+    })
+  }
+}
+
+// Attribute names on a declaration: [Benchmark], [Xunit.FactAttribute] -> Benchmark, Fact
+function csharpAttributes(node: SyntaxNode): Partial<SymbolInfo> {
+  const names = node.children
+    .filter((c: any) => c.type === 'attribute_list')
+    .flatMap((list: any) => list.namedChildren.filter((c: any) => c.type === 'attribute'))
+    .map((attr: any) => (attr.childForFieldName('name')?.text || '').replace(/^.*\./, '').replace(/Attribute$/, ''))
+    .filter(Boolean)
+  return names.length > 0 ? { decorators: names } : {}
+}
+
+// Serialization tags from the attributes of a field or property:
+// [JsonPropertyName("user_id")], [Column(Name = "user_id")]
+function csharpFieldTags(node: SyntaxNode, field: string): Partial<SymbolInfo> {
+  const sources: TagSource[] = node.children
+    .filter((c: any) => c.type === 'attribute_list')
+    .flatMap((list: any) => list.namedChildren.filter((c: any) => c.type === 'attribute'))
+    .map((attr: any) => {
+      const args = attr.namedChildren.find((c: any) => c.type === 'attribute_argument_list')?.namedChildren
+        .filter((c: any) => c.type === 'attribute_argument') || []
+      const positional: TagLiteral[] = []
+      const options: Record<string, TagLiteral> = {}
+      for (const arg of args) {
+        const named = /^([A-Za-z_]\w*)\s*(=|:)(?!=)\s*([\s\S]+)$/.exec(arg.text)
+        if (named) options[named[1]] = csharpTagLiteral(named[3])
+        else positional.push(csharpTagLiteral(arg.text))
+      }
+      return {
+        name: attr.childForFieldName('name')?.text || '',
+        args: Object.keys(options).length > 0 ? [...positional, options] : positional
+      }
+    })
+  const tags = fieldTags(sources, field, 'csharp')
+  return tags ? { tags } : {}
+}
+
+function csharpDeclInfo(node: SyntaxNode): Partial<SymbolInfo> {
+  const xml = xmlDocComment(node)
+  const docInfo = xml ? parseXmlDoc(xml) : undefined
+  const obsolete = obsoleteMessage(node)
+  const info = obsolete !== undefined
+    ? { ...(docInfo || { summary: '' }), deprecated: obsolete || docInfo?.deprecated || '' }
+    : docInfo
+  const doc = xml ? xml.replace(/<\/?[a-zA-Z]+[^>]*>/g, '').trim() || undefined : undefined
+  return { ...declInfo(node, hasModifier(node, 'public'), doc, info), ...csharpTypeParams(node), ...csharpAttributes(node) }
+}
+
 function extendsScriptableObject(node: SyntaxNode): boolean {
   const base = node.childForFieldName('base_list')
   if (!base) {
@@ -158,6 +464,55 @@ const UNITY_LIFECYCLE = new Set([
   'OnGUI'
 ])
 
+/**
+ * Value of a C# enum initializer: an integer, an earlier member, or one
+ * shift / or / plus of those
+ */
+function csharpEnumValue(text: string, known: Map<string, number>): number | undefined {
+  const operand = (t: string) => {
+    const trimmed = t.trim()
+    const literal = trimmed.match(/^(-?)(0[xX][0-9a-fA-F]+|\d+)[uUlL]*$/)
+    if (literal) return literal[1] ? -Number(literal[2]) : Number(literal[2]) // Number('-0x1') is NaN
+    return known.get(trimmed.replace(/^.*\./, ''))
+  }
+  const binary = text.match(/^\(?\s*([^<|+()]+?)\s*(<<|\||\+)\s*([^<|+()]+?)\s*\)?$/)
+  if (!binary) return operand(text)
+  const [, left, op, right] = binary
+  const a = operand(left)
+  const b = operand(right)
+  if (a === undefined || b === undefined) return undefined
+  return op === '<<' ? a << b : op === '|' ? a | b : a + b
+}
+
+/**
+ * Members of an enum, numbered as the compiler does: from 0, or one past
+ * the previous member
+ */
+function csharpEnumMembers(node: SyntaxNode, enumName: string, exported: boolean): Partial<SymbolInfo>[] {
+  const members: Partial<SymbolInfo>[] = []
+  const known = new Map<string, number>()
+  let next: number | undefined = 0
+  for (const member of node.descendantsOfType('enum_member_declaration')) {
+    if (!member) continue
+    const memberName = member.childForFieldName('name')?.text
+    if (!memberName) continue
+    const init = member.childForFieldName('value')
+    const value = init ? csharpEnumValue(init.text, known) : next
+    if (value !== undefined) known.set(memberName, value)
+    next = value === undefined ? undefined : value + 1
+    members.push({
+      name: `${enumName}.${memberName}`,
+      kind: 'constant',
+      line: member.startPosition.row + 1,
+      end_line: member.endPosition.row + 1,
+      ...csharpDeclInfo(member),
+      exported,
+      ...(value !== undefined ? { const_value: value } : {})
+    })
+  }
+  return members
+}
+
 /*
   -------- C# --------
 */
@@ -169,8 +524,42 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
   const tree = parser.parse(code)
   if (!tree) return []
   collectReferences(tree.rootNode, symbols)
+  collectSyntaxErrors(tree.rootNode, symbols)
 
   function csharpWalk(node: SyntaxNode) {
+    // ----- PACKAGE -----
+    // A file-scoped namespace (namespace Acme.Billing;) is the file's package clause
+    if (node.type === 'file_scoped_namespace_declaration') {
+      const name = node.childForFieldName('name')?.text
+      if (name) {
+        symbols.push({
+          name,
+          kind: 'package',
+          line: node.startPosition.row + 1,
+          end_line: node.endPosition.row + 1,
+          ...declInfo(node, true)
+        })
+      }
+    }
+
+    // ----- USING ALIAS -----
+    // using Foo = Acme.Billing.Invoice; names a type for the file
+    if (node.type === 'using_directive' && node.text.includes('=')) {
+      const alias = node.childForFieldName('name')
+      const target = node.text.slice(node.text.indexOf('=') + 1).replace(/<.*$/s, '').replace(/;\s*$/, '').trim()
+      if (alias && target) {
+        symbols.push({
+          name: alias.text,
+          kind: 'type',
+          line: node.startPosition.row + 1,
+          end_line: node.endPosition.row + 1,
+          column: alias.startPosition.column + 1,
+          exported: false,
+          alias_of: target.replace(/^global::/, '')
+        })
+      }
+    }
+
     // ----- CLASS -----
     if (node.type === 'class_declaration') {
       const name = node.childForFieldName('name')?.text
@@ -182,11 +571,14 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
         extendsScriptableObject(node) ||
         hasAttribute(node, ['CreateAssetMenu'])
 
+      const bases = baseTypeNames(node)
       symbols.push({
         name,
         kind: isScriptable ? 'scriptable_object' : 'class',
         line: node.startPosition.row + 1,
-        end_line: node.endPosition.row + 1
+        end_line: node.endPosition.row + 1,
+        ...(bases.length > 0 ? { bases } : {}),
+        ...csharpDeclInfo(node)
       })
     }
 
@@ -194,11 +586,14 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
     if (node.type === 'struct_declaration') {
       const name = node.childForFieldName('name')?.text
       if (name) {
+        const bases = baseTypeNames(node)
         symbols.push({
           name,
           kind: 'struct',
           line: node.startPosition.row + 1,
-          end_line: node.endPosition.row + 1
+          end_line: node.endPosition.row + 1,
+          ...(bases.length > 0 ? { bases } : {}),
+          ...csharpDeclInfo(node)
         })
       }
     }
@@ -207,11 +602,15 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
     if (node.type === 'interface_declaration') {
       const name = node.childForFieldName('name')?.text
       if (name) {
+        const bases = baseTypeNames(node)
         symbols.push({
           name,
           kind: 'interface',
           line: node.startPosition.row + 1,
-          end_line: node.endPosition.row + 1
+          end_line: node.endPosition.row + 1,
+          members: interfaceMemberNames(node),
+          ...(bases.length > 0 ? { extends: bases } : {}),
+          ...csharpDeclInfo(node)
         })
       }
     }
@@ -220,12 +619,15 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
     if (node.type === 'enum_declaration') {
       const name = node.childForFieldName('name')?.text
       if (name) {
+        const info = csharpDeclInfo(node)
         symbols.push({
           name,
           kind: 'enum',
           line: node.startPosition.row + 1,
-          end_line: node.endPosition.row + 1
+          end_line: node.endPosition.row + 1,
+          ...info
         })
+        symbols.push(...csharpEnumMembers(node, name, !!info.exported))
       }
     }
 
@@ -253,7 +655,11 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
         name: className ? `${className}.${methodName}` : methodName,
         kind: isUnityLifecycle ? 'unity_lifecycle' : 'method',
         line: node.startPosition.row + 1,
-        end_line: node.endPosition.row + 1
+        end_line: node.endPosition.row + 1,
+        ...csharpDeclInfo(node),
+        ...bodyFingerprint(node),
+        ...bodyErrorFacts(node),
+        ...bodyLandmarks(node)
       })
     }
 
@@ -265,7 +671,9 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
           name,
           kind: 'property',
           line: node.startPosition.row + 1,
-          end_line: node.endPosition.row + 1
+          end_line: node.endPosition.row + 1,
+          ...csharpDeclInfo(node),
+          ...csharpFieldTags(node, name)
         })
       }
     }
@@ -306,7 +714,9 @@ export async function extractCSharpSymbols(code: string): Promise<Partial<Symbol
           name: className ? `${className}.${fieldName}` : fieldName,
           kind: 'serialized_field',
           line: node.startPosition.row + 1,
-          end_line: node.endPosition.row + 1
+          end_line: node.endPosition.row + 1,
+          ...csharpDeclInfo(node),
+          ...csharpFieldTags(node, fieldName)
         })
       }
     }
@@ -0,0 +1,89 @@
+
+// Size and speed of the symbol pack with each shard compression, against
+// the plain JSON the json store writes. Usage (after npm run build):
+//   node scripts/bench_pack_compression.js [project dir]
+
+import fs from 'fs/promises';
+import os from 'os';
+import path from 'path';
+import { performance } from 'perf_hooks';
+import { openSymbolIndex } from '../build/lib/core/symbol-index.js';
+import { SymbolPackReader, readSymbolPack, writeSymbolPack } from '../build/lib/utils/symbol-pack.js';
+import { SHARD_COMPRESSIONS, zstdAvailable } from '../build/lib/utils/shard-codec.js';
+
+const LOOKUPS = 200;
+
+// Index the project without touching its stored index
+const memoryStore = {
+  load: async () => null,
+  save: async () => {},
+  update: async () => {},
+  findSymbols: async () => [],
+  clear: async () => {}
+};
+
+async function time(fn) {
+  const start = performance.now();
+  await fn();
+  return performance.now() - start;
+}
+
+function kb(bytes) {
+  return `${(bytes / 1024).toFixed(1)} KB`;
+}
+
+async function main() {
+  const root = path.resolve(process.argv[2] || process.cwd());
+  console.log(`Indexing ${root}...`);
+  const { index } = await openSymbolIndex(root, memoryStore);
+  const shards = index.listShards();
+  const names = index.allSymbols().map(s => s.name);
+  const sample = Array.from({ length: LOOKUPS }, (_, i) => names[(i * 7919) % names.length]);
+  console.log(`${shards.length} files, ${names.length} symbols\n`);
+
+  const dir = await fs.mkdtemp(path.join(os.tmpdir(), 'bench-pack-'));
+  const rows = [];
+  try {
+    const jsonFile = path.join(dir, 'shards.json');
+    const jsonWrite = await time(() => fs.writeFile(jsonFile, JSON.stringify(shards)));
+    const jsonRead = await time(async () => JSON.parse(await fs.readFile(jsonFile, 'utf8')));
+    rows.push(['json', (await fs.stat(jsonFile)).size, jsonWrite, jsonRead, null]);
+
+    for (const compression of SHARD_COMPRESSIONS) {
+      if (compression === 'zstd' && !zstdAvailable()) {
+        console.log('zstd skipped: needs Node 22.15 or newer');
+        continue;
+      }
+      const file = path.join(dir, `${compression}.idxpack`);
+      const write = await time(() => writeSymbolPack(file, shards, { compression }));
+      const read = await time(() => readSymbolPack(file));
+      const reader = await SymbolPackReader.open(file);
+      const lookup = await time(async () => {
+        for (const name of sample) await reader.findSymbols(name);
+      });
+      await reader.close();
+      rows.push([`pack/${compression}`, (await fs.stat(file)).size, write, read, lookup]);
+    }
+  } finally {
+    await fs.rm(dir, { recursive: true, force: true });
+  }
+
+  const base = rows[0][1];
+  console.log('FORMAT         SIZE        RATIO  WRITE ms  LOAD ms  LOOKUP ms');
+  for (const [name, size, write, read, lookup] of rows) {
+    console.log([
+      name.padEnd(14),
+      kb(size).padStart(10),
+      `${(size / base * 100).toFixed(0)}%`.padStart(7),
+      write.toFixed(1).padStart(9),
+      read.toFixed(1).padStart(8),
+      (lookup === null ? '-' : lookup.toFixed(1)).padStart(10)
+    ].join(' '));
+  }
+  console.log(`\nLOOKUP is ${LOOKUPS} findSymbols calls on a fresh reader; LOAD reads every shard.`);
+}
+
+main().catch(e => {
+  console.error(e);
+  process.exit(1);
+});
@@ -0,0 +1,115 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import fs from 'fs/promises'
+import http from 'http'
+import net from 'net'
+import path from 'path'
+import { tmpdir } from 'os'
+import crypto from 'crypto'
+import { spawn, ChildProcess } from 'child_process'
+
+/**
+ * End-to-end test: indexer serve --watch through the real command line,
+ * reindexing edits while it serves them and posting them to webhooks
+ */
+
+const INDEXER_SCRIPT = path.resolve(process.cwd(), 'build/indexer.js')
+const TIMEOUT_MS = 20_000
+
+async function freePort(): Promise<number> {
+  return new Promise((resolve, reject) => {
+    const server = net.createServer()
+    server.once('error', reject)
+    server.listen(0, '127.0.0.1', () => {
+      const { port } = server.address() as net.AddressInfo
+      server.close(() => resolve(port))
+    })
+  })
+}
+
+async function until<T>(what: string, check: () => Promise<T | null | undefined> | T | null | undefined): Promise<T> {
+  const deadline = Date.now() + TIMEOUT_MS
+  while (Date.now() < deadline) {
+    const value = await check()
+    if (value) return value
+    await new Promise(r => setTimeout(r, 100))
+  }
+  throw new Error(`Timed out waiting for ${what}`)
+}
+
+async function createProject(): Promise<string> {
+  const root = await fs.mkdtemp(path.join(tmpdir(), 'serve-watch-'))
+  await fs.mkdir(path.join(root, '.indexer'))
+  await fs.mkdir(path.join(root, 'src'))
+  await fs.writeFile(path.join(root, 'src/user.ts'), 'export class User {}\n')
+  return root
+}
+
+// Run indexer serve in a project, collecting what it prints
+function serve(root: string, args: string[], env: Record<string, string> = {}): { child: ChildProcess, output: () => string, exited: Promise<number | null> } {
+  const child = spawn(process.execPath, [INDEXER_SCRIPT, 'serve', ...args], {
+    cwd: root,
+    stdio: ['ignore', 'pipe', 'pipe'],
+    env: { ...process.env, NODE_ENV: 'test', WATCH_DEBOUNCE_MS: '50', ...env }
+  })
+  let output = ''
+  child.stdout!.on('data', data => { output += data })
+  child.stderr!.on('data', data => { output += data })
+  const exited = new Promise<number | null>(resolve => child.on('exit', code => resolve(code)))
+  return { child, output: () => output, exited }
+}
+
+test('serve-watch: indexer serve --watch reindexes edits and checkpoints on shutdown', async () => {
+  const root = await createProject()
+  const httpPort = await freePort()
+  const server = serve(root, ['--watch', `--port=${await freePort()}`, `--http-port=${httpPort}`])
+  try {
+    await until('the watcher to start', () => server.output().includes('Watching'))
+    await fs.writeFile(path.join(root, 'src/admin.ts'), 'export class Admin {}\n')
+    const found = await until('Admin to be served', async () => {
+      const res = await fetch(`http://127.0.0.1:${httpPort}/symbols?q=Admin`)
+      const body = await res.json() as { items: Array<{ name: string }> }
+      return body.items.some(s => s.name === 'Admin')
+    })
+    assert.equal(found, true)
+    assert.match(server.output(), /Reindexed 1 files/)
+
+    server.child.kill('SIGTERM')
+    await server.exited
+    assert.match(server.output(), /Checkpointed the symbol index/)
+  } finally {
+    server.child.kill('SIGKILL')
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})
+
+test('serve-watch: indexer serve --watch --webhook= posts signed reindex summaries', async () => {
+  const root = await createProject()
+  const deliveries: Array<{ body: string, signature: string | undefined }> = []
+  const receiver = http.createServer((req, res) => {
+    let body = ''
+    req.on('data', chunk => { body += chunk })
+    req.on('end', () => {
+      deliveries.push({ body, signature: req.headers['x-indexer-signature-256'] as string | undefined })
+      res.end()
+    })
+  })
+  await new Promise<void>(resolve => receiver.listen(0, '127.0.0.1', resolve))
+  const hook = `http://127.0.0.1:${(receiver.address() as net.AddressInfo).port}/hook`
+  const secret = 'e2e-secret'
+  const server = serve(root, ['--watch', `--webhook=${hook}`, `--port=${await freePort()}`], { INDEXER_WEBHOOK_SECRET: secret })
+  try {
+    await until('the watcher to start', () => server.output().includes('Posting index updates to 1 webhook'))
+    await fs.writeFile(path.join(root, 'src/admin.ts'), 'export class Admin {}\n')
+    const delivery = await until('a webhook delivery', () => deliveries[0])
+    const payload = JSON.parse(delivery.body)
+    assert.equal(payload.event, 'index.update')
+    assert.deepEqual(payload.files.added, ['src/admin.ts'])
+    assert.deepEqual(payload.symbols.added.map((s: { name: string }) => s.name), ['Admin'])
+    assert.equal(delivery.signature, `sha256=${crypto.createHmac('sha256', secret).update(delivery.body).digest('hex')}`)
+  } finally {
+    server.child.kill('SIGKILL')
+    receiver.close()
+    await fs.rm(root, { recursive: true, force: true })
+  }
+})